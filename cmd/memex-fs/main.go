@@ -1,17 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/systemshift/memex-fs/internal/dag"
 	"github.com/systemshift/memex-fs/internal/dagit"
 	memexfuse "github.com/systemshift/memex-fs/internal/fuse"
+	"github.com/systemshift/memex-fs/internal/mcp"
+	"github.com/systemshift/memex-fs/internal/tui"
+	"github.com/systemshift/memex-fs/internal/web"
 )
 
 func main() {
@@ -26,9 +38,66 @@ func main() {
 		case "pull":
 			runPull(os.Args[2:])
 			return
+		case "clone":
+			runClone(os.Args[2:])
+			return
+		case "remote":
+			runRemote(os.Args[2:])
+			return
+		case "replicate":
+			runReplicate(os.Args[2:])
+			return
+		case "sync-serve":
+			runSyncServe(os.Args[2:])
+			return
 		case "mount":
 			runMount(os.Args[2:])
 			return
+		case "audit":
+			runAudit(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "retype":
+			runRetype(os.Args[2:])
+			return
+		case "tag":
+			runTag(os.Args[2:])
+			return
+		case "protect":
+			runProtect(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "trash":
+			runTrash(os.Args[2:])
+			return
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "overlay":
+			runOverlay(os.Args[2:])
+			return
+		case "mcp":
+			runMCP(os.Args[2:])
+			return
+		case "tui":
+			runTUI(os.Args[2:])
+			return
+		case "token":
+			runToken(os.Args[2:])
+			return
+		case "acl":
+			runACL(os.Args[2:])
+			return
+		case "gateway":
+			runGateway(os.Args[2:])
+			return
+		case "bridge":
+			runBridge(os.Args[2:])
+			return
 		case "-h", "--help":
 			printUsage()
 			return
@@ -44,8 +113,27 @@ func printUsage() {
 
 Commands:
   mount     Mount the repo as a FUSE filesystem (default)
-  push      Upload every object reachable from HEAD to IPFS
-  pull      Fetch a commit CID and its reachable objects from IPFS
+  push      Upload every object reachable from HEAD to IPFS, or what a remote lacks to it (--remote)
+  pull      Fetch a commit CID, or a remote's HEAD, and its reachable objects (--depth, --types, --tags for part; --merge)
+  clone     Create a repo from a peer's published HEAD (DID, IPNS name or CID) and remember it for pull
+  remote    List, add and remove the remotes push and pull exchange with (list, add, remove)
+  replicate Publish HEAD under the repo's own IPNS name for other devices to clone and pull (--every to keep at it)
+  sync-serve Let other memexes push and pull over HTTP, or over ssh with --stdio
+  audit     Report broken links and orphan nodes (--prune to remove broken links)
+  export    Render the graph as DOT, GraphML or JSON (--types, --tags, --from and --depth for part)
+  retype    Change a node's type, or every node of one type (--all)
+  tag       List tags, or name a commit (HEAD by default)
+  protect   Keep tags or commit ranges from retention, trash purges and tag moves (list, add, remove)
+  import    Add a node from a signed bundle (nodes/{id}/.bundle), calendar, contacts or shell history (--format)
+  trash     List deleted nodes past their retention (--purge to remove them)
+  backup    Archive the repo, verify an archive, or restore one (create, verify, restore --dry-run)
+  overlay   Make a scratch copy of a commit to mount (mount --overlay) and experiment in, then merge or discard it
+  mcp       Serve the repo to LLM agents over MCP on stdin/stdout
+  tui       Search, capture notes and link from the terminal (--mount to go through a running mount)
+  token     Mint, list and revoke capability tokens for the web UI and MCP (mint, list, revoke)
+  acl       Show or set the ACL remote interfaces enforce on a node and what is PART_OF it
+  gateway   Serve nodes marked visibility=public read-only over HTTP until interrupted
+  bridge    Mirror real directories into the repo as Source nodes until interrupted
 
 Run 'memex-fs <command> -h' for command-specific flags.
 `)
@@ -57,6 +145,19 @@ func runMount(args []string) {
 		dataDir    = fs.String("data", ".", "Data directory (contains .mx/)")
 		mountpoint = fs.String("mount", "", "FUSE mount point (required)")
 		debug      = fs.Bool("debug", false, "Enable FUSE debug logging")
+		auditEvery = fs.Duration("audit-interval", 15*time.Minute, "How often to re-run the integrity audit behind /graph/")
+		tagEvery   = fs.Duration("snapshot-interval", time.Hour, "How often to check for due daily/weekly snapshot tags (0 disables)")
+		maintEvery = fs.Duration("maintenance-interval", time.Hour, "How often to run maintenance, such as expiring tombstones (0 disables)")
+		kuboAPI    = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL, for objects a partial pull left out")
+		clipboard  = fs.Bool("capture-clipboard", false, "Capture copied text and URLs as Clipboard nodes")
+		clipEvery  = fs.Duration("clipboard-interval", time.Second, "How often to check the clipboard with --capture-clipboard")
+		bridge     = fs.String("bridge", "", "Comma-separated directories to mirror into the repo as Source nodes")
+		bridgeScan = fs.Duration("bridge-interval", time.Minute, "How often to rescan --bridge directories besides change events")
+		uiAddr     = fs.String("ui-addr", "", "Serve the web UI on this address, e.g. localhost:8080 (unauthenticated without --ui-tokens; keep it on loopback)")
+		uiTokens   = fs.Bool("ui-tokens", false, "Require a capability token (see 'memex-fs token') for the web UI's API")
+		gwAddr     = fs.String("gateway-addr", "", "Serve nodes marked visibility=public read-only on this address")
+		profile    = fs.String("profile", "", "Resource profile, overriding the config's: low-memory for Raspberry Pi-class devices")
+		overlay    = fs.String("overlay", "", "Mount this overlay of the repo (see 'memex-fs overlay') instead of the repo itself")
 	)
 	fs.Parse(args)
 
@@ -68,11 +169,90 @@ func runMount(args []string) {
 		log.Fatalf("memex-fs: create mountpoint: %v", err)
 	}
 
+	if *overlay != "" {
+		parent, err := dag.OpenRepository(*dataDir)
+		if err != nil {
+			log.Fatalf("memex-fs: failed to open repository: %v", err)
+		}
+		if *dataDir, err = parent.OverlayRoot(*overlay); err != nil {
+			log.Fatalf("memex-fs: --overlay: %v", err)
+		}
+	}
+
 	log.Printf("memex-fs: opening repository at %s", *dataDir)
-	repo, err := dag.OpenRepository(*dataDir)
+	repo, err := dag.OpenRepositoryBackground(*dataDir, *profile)
 	if err != nil {
 		log.Fatalf("memex-fs: failed to open repository: %v", err)
 	}
+	if ov := repo.Overlay(); ov != nil {
+		log.Printf("memex-fs: overlay %s of commit %s", ov.Name, ov.Base)
+	}
+	if repo.Profile() != "" {
+		applyProfileIntervals(fs, profileIntervals[repo.Profile()])
+		log.Printf("memex-fs: %s profile", repo.Profile())
+	}
+	go func() {
+		if repo.WaitReady(context.Background()) == nil {
+			log.Printf("memex-fs: indexes ready")
+		}
+	}()
+
+	if repo.Shallow() != nil {
+		repo.Store.SetFetcher(dagit.Fetcher(context.Background(), ipfsClient(repo, *kuboAPI)))
+	}
+
+	stopAudit := repo.Audit.Start(*auditEvery)
+	defer stopAudit()
+
+	if *tagEvery > 0 {
+		stopTags := repo.StartAutoTag(*tagEvery)
+		defer stopTags()
+	}
+
+	if *maintEvery > 0 {
+		stopMaint := repo.StartMaintenance(*maintEvery)
+		defer stopMaint()
+	}
+
+	if len(repo.Config.Schedule) > 0 {
+		stopSched := repo.Scheduler.Start()
+		defer stopSched()
+	}
+
+	if *clipboard {
+		read, err := dag.SystemClipboard()
+		if err != nil {
+			log.Fatalf("memex-fs: --capture-clipboard: %v", err)
+		}
+		stopClip := repo.StartClipboardCapture(*clipEvery, read)
+		defer stopClip()
+	}
+
+	if dirs := splitList(*bridge); len(dirs) > 0 {
+		stopBridge, err := repo.StartBridge(dirs, *bridgeScan)
+		if err != nil {
+			log.Fatalf("memex-fs: --bridge: %v", err)
+		}
+		defer stopBridge()
+	}
+
+	if *uiAddr != "" {
+		stopUI, err := web.Start(repo, *uiAddr, *uiTokens)
+		if err != nil {
+			log.Fatalf("memex-fs: --ui-addr: %v", err)
+		}
+		defer stopUI()
+		log.Printf("memex-fs: web UI at http://%s/", *uiAddr)
+	}
+
+	if *gwAddr != "" {
+		stopGateway, err := web.StartGateway(repo, *gwAddr)
+		if err != nil {
+			log.Fatalf("memex-fs: --gateway-addr: %v", err)
+		}
+		defer stopGateway()
+		log.Printf("memex-fs: public gateway at http://%s/n/{id}", *gwAddr)
+	}
 
 	log.Printf("memex-fs: mounting at %s", *mountpoint)
 	server, err := memexfuse.MountFS(*mountpoint, repo, *debug)
@@ -91,34 +271,80 @@ func runMount(args []string) {
 
 	log.Printf("memex-fs: ready (pid %d)", os.Getpid())
 	server.Wait()
+	waitBackground(repo)
+	if err := repo.SaveIndexes(); err != nil {
+		log.Printf("memex-fs: %v", err)
+	}
 	log.Println("memex-fs: stopped")
 }
 
+// profileIntervals are the mount's interval defaults under each resource
+// profile, for the flags a profile changes. The low-memory profile runs
+// background work less often, trading freshness for idle CPU and I/O.
+var profileIntervals = map[string]map[string]time.Duration{
+	dag.ProfileLowMemory: {
+		"audit-interval":       time.Hour,
+		"snapshot-interval":    3 * time.Hour,
+		"maintenance-interval": 6 * time.Hour,
+		"clipboard-interval":   5 * time.Second,
+		"bridge-interval":      15 * time.Minute,
+	},
+}
+
+// applyProfileIntervals sets each flag in intervals to its profile
+// default, unless it was given on the command line.
+func applyProfileIntervals(fs *flag.FlagSet, intervals map[string]time.Duration) {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, d := range intervals {
+		if !set[name] {
+			fs.Set(name, d.String())
+		}
+	}
+}
+
+// signalContext returns a context cancelled by Ctrl-C or SIGTERM, so a
+// long push, pull or scan stops cleanly instead of being killed midway.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 // runPush uploads every object reachable from HEAD to IPFS. With --publish
 // it also imports the repo's Ed25519 identity into the Kubo keystore (if
 // not already) and publishes the HEAD CID under an IPNS name derived from
 // the identity's DID. Prints the HEAD CID on success, and the IPNS name
-// when publishing.
+// when publishing. With --remote it pushes to a named remote instead (see
+// runRemote), sending only what the remote lacks and resuming a push that
+// was interrupted.
 func runPush(args []string) {
 	fs := flag.NewFlagSet("push", flag.ExitOnError)
 	var (
 		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
 		kuboAPI = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL")
 		publish = fs.Bool("publish", false, "Publish HEAD CID over IPNS under the repo's identity")
+		remote  = fs.String("remote", "", "Push to this remote, which merges what it lacks")
+		token   = fs.String("token", os.Getenv("MEMEX_TOKEN"), "Capability token for a remote sync server (default $MEMEX_TOKEN)")
 	)
 	fs.Parse(args)
 
+	ctx, stop := signalContext()
+	defer stop()
+
 	repo, err := dag.OpenRepository(*dataDir)
 	if err != nil {
 		log.Fatalf("memex-fs push: open repository: %v", err)
 	}
 
-	kubo := dagit.NewKuboClient(*kuboAPI)
-	if !kubo.IsAvailable() {
+	kubo := ipfsClient(repo, *kuboAPI)
+	if *remote != "" {
+		pushRemote(ctx, repo, kubo, *remote, *token)
+		return
+	}
+	if !kubo.IsAvailable(ctx) {
 		log.Fatalf("memex-fs push: Kubo not available at %s", *kuboAPI)
 	}
 
-	headCID, err := dagit.Push(repo, kubo)
+	headCID, err := dagit.Push(ctx, repo, kubo)
 	if err != nil {
 		log.Fatalf("memex-fs push: %v", err)
 	}
@@ -129,10 +355,13 @@ func runPush(args []string) {
 		if err != nil {
 			log.Fatalf("memex-fs push: load identity: %v", err)
 		}
-		if err := dagit.EnsureKey(kubo, identity, dagit.HeadKeyName); err != nil {
+		if err := dagit.EnsureKey(ctx, kubo, identity, dagit.HeadKeyName); err != nil {
 			log.Fatalf("memex-fs push: key import: %v", err)
 		}
-		if err := kubo.NamePublish(headCID, dagit.HeadKeyName); err != nil {
+		if err := kubo.NamePublish(ctx, headCID, dagit.HeadKeyName); errors.Is(err, dagit.ErrPublishQueued) {
+			fmt.Fprintf(os.Stderr, "memex-fs: Kubo went away; IPNS publish queued for the next push or pull\n")
+			return
+		} else if err != nil {
 			log.Fatalf("memex-fs push: IPNS publish: %v", err)
 		}
 		ipnsName, err := dagit.DIDToIPNSName(identity.DID)
@@ -143,49 +372,1251 @@ func runPush(args []string) {
 	}
 }
 
+// pushRemote pushes HEAD to the remote name: objects the remote's HEAD or
+// the commit last exchanged with it already reach are skipped, and a
+// journal under .mx/dagit/ lets an interrupted push pick up where it
+// stopped. The remote then merges the pushed commit.
+func pushRemote(ctx context.Context, repo *dag.Repository, kubo *dagit.FallbackClient, name, token string) {
+	r, err := repo.GetRemote(name)
+	if err != nil {
+		log.Fatalf("memex-fs push: %v", err)
+	}
+	if r == nil {
+		log.Fatalf("memex-fs push: no remote %s (see 'memex-fs remote add')", name)
+	}
+	t, err := dagit.OpenTransport(r.Source, kubo, token)
+	if err != nil {
+		log.Fatalf("memex-fs push: %s: %v", name, err)
+	}
+	defer t.Close()
+
+	known := r.Head
+	if head, err := t.Head(ctx); err == nil && head != "" {
+		if c, _, err := repo.Commits.ResolveCID(head); err == nil && repo.Store.Has(c) {
+			known = head
+		}
+	}
+	progress := newProgress("pushed")
+	headCID, err := dagit.PushWith(ctx, repo, t, dagit.PushOptions{
+		Known:    known,
+		Journal:  filepath.Join(repo.MxDir(), "dagit", "push-"+name+".journal"),
+		Progress: progress.report,
+	})
+	progress.done()
+	if err != nil {
+		log.Fatalf("memex-fs push: %v", err)
+	}
+	res, err := t.Update(ctx, headCID)
+	if err != nil {
+		log.Fatalf("memex-fs push: %s: %v", name, err)
+	}
+	if err := repo.SetRemote(name, r.Source, headCID); err != nil {
+		log.Fatalf("memex-fs push: %v", err)
+	}
+	fmt.Println(headCID)
+	fmt.Fprintf(os.Stderr, "memex-fs: %s is at %s (%d nodes updated, %d conflicts)\n", name, res.Head, len(res.Updated), len(res.Conflicts))
+}
+
+// progress prints a running count of objects moved to stderr.
+type progress struct {
+	verb string
+	n    int
+}
+
+func newProgress(verb string) *progress {
+	return &progress{verb: verb}
+}
+
+func (p *progress) report(n int) {
+	p.n = n
+	fmt.Fprintf(os.Stderr, "\rmemex-fs: %s %d objects", p.verb, n)
+}
+
+// done ends the progress line, if anything was reported.
+func (p *progress) done() {
+	if p.n > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// ipfsClient talks to Kubo at apiURL, falling back to the configured
+// gateways for reads and queueing IPNS publishes while Kubo is down.
+func ipfsClient(repo *dag.Repository, apiURL string) *dagit.FallbackClient {
+	gateways := repo.Config.Gateways
+	if len(gateways) == 0 {
+		gateways = dagit.DefaultGateways
+	}
+	return dagit.NewFallbackClient(
+		dagit.NewKuboClient(apiURL),
+		dagit.NewGatewayClient(gateways),
+		filepath.Join(repo.MxDir(), "dagit", "publish-queue.json"),
+	)
+}
+
 // runPull fetches a commit and everything reachable from it into the local
-// ObjectStore. Accepts either a commit CID (base32, e.g. bafk...) or a
-// did:key DID, which is resolved via IPNS to the DID holder's latest
-// published HEAD CID. Does not update refs or HEAD — browse via /at/{cid}/.
+// ObjectStore. Accepts a commit CID (base32, e.g. bafk...), or a did:key DID
+// or IPNS name, which is resolved via IPNS to the latest published HEAD CID
+// (see dagit.ResolveSource), or the ssh:// or http:// address of another
+// memex. Does not update refs or HEAD — browse via /at/{cid}/ — unless
+// --checkout adopts the commit in an empty repository, or --merge merges
+// it into the working state, leaving conflicts in /conflicts/. Given the
+// name of a remote, or nothing for the one a clone recorded, it pulls the
+// remote's HEAD and merges. Objects already held are not fetched again,
+// so an interrupted pull resumes where it stopped. --depth,
+// --types and --tags make a partial pull; mounting the repository then
+// fetches what was left out as it is read. Without a reachable Kubo daemon,
+// network steps go through public gateways.
 func runPull(args []string) {
 	fs := flag.NewFlagSet("pull", flag.ExitOnError)
 	var (
-		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
-		kuboAPI = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL")
+		dataDir  = fs.String("data", ".", "Data directory (contains .mx/)")
+		kuboAPI  = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL")
+		depth    = fs.Int("depth", 0, "Pull only the newest N commits (0 pulls all history)")
+		types    = fs.String("types", "", "Comma-separated node types to pull in full")
+		tags     = fs.String("tags", "", "Comma-separated tags (meta \"tags\") of nodes to pull in full")
+		checkout = fs.Bool("checkout", false, "Make the pulled commit HEAD of an empty repository")
+		merge    = fs.Bool("merge", false, "Merge the pulled commit into the working state; conflicts appear under /conflicts/")
+		token    = fs.String("token", os.Getenv("MEMEX_TOKEN"), "Capability token for a remote sync server (default $MEMEX_TOKEN)")
+	)
+	fs.Parse(args)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs pull: open repository: %v", err)
+	}
+
+	remote, source := fs.Arg(0), fs.Arg(0)
+	if fs.NArg() < 1 {
+		remote = dag.DefaultRemote
+	}
+	r, err := repo.GetRemote(remote)
+	if err != nil {
+		log.Fatalf("memex-fs pull: %v", err)
+	}
+	switch {
+	case r != nil:
+		source, *merge = r.Source, true
+	case fs.NArg() < 1:
+		log.Fatal("memex-fs pull: missing CID or DID argument, and no remote to pull from")
+	default:
+		remote = ""
+	}
+
+	kubo := ipfsClient(repo, *kuboAPI)
+	t, err := dagit.OpenTransport(source, kubo, *token)
+	if err != nil {
+		log.Fatalf("memex-fs pull: %v", err)
+	}
+	defer t.Close()
+	if k, ok := t.(interface{ IsAvailable(context.Context) bool }); ok && !k.IsAvailable(ctx) {
+		fmt.Fprintf(os.Stderr, "memex-fs: Kubo not available at %s; reading through gateways\n", *kuboAPI)
+	}
+
+	headCID, err := t.Head(ctx)
+	if err != nil {
+		log.Fatalf("memex-fs pull: %v", err)
+	}
+	if headCID == "" {
+		log.Fatalf("memex-fs pull: %s has no commits", source)
+	}
+	if headCID != source {
+		fmt.Fprintf(os.Stderr, "memex-fs: resolved %s -> %s\n", source, headCID)
+	}
+
+	progress := newProgress("fetched")
+	opts := dagit.PullOptions{
+		Depth:    *depth,
+		Sparse:   dag.SparseFilter{Types: splitList(*types), Tags: splitList(*tags)},
+		Progress: progress.report,
+	}
+	err = dagit.PullWith(ctx, repo, t, headCID, opts)
+	progress.done()
+	if err != nil {
+		log.Fatalf("memex-fs pull: %v", err)
+	}
+	if !*checkout && !*merge {
+		fmt.Fprintf(os.Stderr, "memex-fs: pulled %s; browse at /at/%s/ on a mounted repo\n", headCID, headCID)
+		return
+	}
+	c, _, err := repo.Commits.ResolveCID(headCID)
+	if err != nil {
+		log.Fatalf("memex-fs pull: %v", err)
+	}
+	if *merge {
+		res, err := repo.MergeCommit(c)
+		if err != nil {
+			log.Fatalf("memex-fs pull: merge: %v", err)
+		}
+		if remote != "" {
+			if err := repo.SetRemote(remote, source, headCID); err != nil {
+				log.Fatalf("memex-fs pull: %v", err)
+			}
+		}
+		waitBackground(repo)
+		fmt.Fprintf(os.Stderr, "memex-fs: merged %s (%d nodes updated, %d conflicts)\n", headCID, len(res.Updated), len(res.Conflicts))
+		for _, id := range res.Conflicts {
+			fmt.Printf("conflict %s: resolve by writing /conflicts/%s/resolve\n", id, id)
+		}
+		return
+	}
+	repo.Store.SetFetcher(dagit.Fetcher(ctx, t))
+	n, err := repo.Checkout(c, opts.Sparse)
+	if err != nil {
+		log.Fatalf("memex-fs pull: checkout: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "memex-fs: checked out %s (%d nodes)\n", headCID, n)
+}
+
+// runClone creates a repository in a new directory from another memex's
+// published HEAD, like git clone: it pulls the source, verifies the pulled
+// commits, checks HEAD out and records the source as the "origin" remote,
+// so a bare `memex-fs pull` in the clone fetches and merges what was
+// published since.
+func runClone(args []string) {
+	fs := flag.NewFlagSet("clone", flag.ExitOnError)
+	var (
+		kuboAPI       = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL")
+		depth         = fs.Int("depth", 0, "Clone only the newest N commits (0 clones all history)")
+		types         = fs.String("types", "", "Comma-separated node types to clone in full")
+		tags          = fs.String("tags", "", "Comma-separated tags (meta \"tags\") of nodes to clone in full")
+		requireSigned = fs.Bool("require-signed", false, "Refuse a history with unsigned commits")
 	)
 	fs.Parse(args)
 
 	if fs.NArg() < 1 {
-		log.Fatal("memex-fs pull: missing CID or DID argument")
+		log.Fatal("memex-fs clone: missing DID, IPNS name or CID argument")
+	}
+	source, dir := fs.Arg(0), "."
+	if fs.NArg() > 1 {
+		dir = fs.Arg(1)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".mx")); err == nil {
+		log.Fatalf("memex-fs clone: %s already holds a repository", dir)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(dir)
+	if err != nil {
+		log.Fatalf("memex-fs clone: open repository: %v", err)
+	}
+	kubo := ipfsClient(repo, *kuboAPI)
+	if !kubo.IsAvailable(ctx) {
+		fmt.Fprintf(os.Stderr, "memex-fs: Kubo not available at %s; reading through gateways\n", *kuboAPI)
+	}
+	opts := dagit.PullOptions{
+		Depth:  *depth,
+		Sparse: dag.SparseFilter{Types: splitList(*types), Tags: splitList(*tags)},
+	}
+	res, err := dagit.Clone(ctx, repo, kubo, source, opts, *requireSigned)
+	if err != nil {
+		os.RemoveAll(repo.MxDir())
+		log.Fatalf("memex-fs clone: %v", err)
 	}
-	source := fs.Arg(0)
+	waitBackground(repo)
+	fmt.Fprintf(os.Stderr, "memex-fs: cloned %s at %s into %s (%d nodes; %d signed commits, %d unsigned)\n",
+		source, res.Head, dir, res.Nodes, res.Signed, res.Unsigned)
+}
+
+// runReplicate publishes the repo's HEAD, and everything it reaches, under
+// the repo's own IPNS name (see dagit.Replicate), and prints the name. On
+// another device, `memex-fs clone <name>` starts a copy and a bare
+// `memex-fs pull` there merges what was replicated since. With --every it
+// replicates again at that interval until interrupted, sending only what
+// changed. --name prints the name without publishing.
+func runReplicate(args []string) {
+	fs := flag.NewFlagSet("replicate", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		kuboAPI = fs.String("kubo-api", "http://localhost:5001/api/v0", "Kubo API URL")
+		every   = fs.Duration("every", 0, "Replicate again at this interval until interrupted, e.g. 5m")
+		name    = fs.Bool("name", false, "Print the repo's IPNS name without publishing")
+	)
+	fs.Parse(args)
 
 	repo, err := dag.OpenRepository(*dataDir)
 	if err != nil {
-		log.Fatalf("memex-fs pull: open repository: %v", err)
+		log.Fatalf("memex-fs replicate: open repository: %v", err)
+	}
+	if *name {
+		r, err := dagit.LoadReplica(repo)
+		if err != nil {
+			log.Fatalf("memex-fs replicate: %v", err)
+		}
+		fmt.Println(r.Name)
+		return
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	kubo := ipfsClient(repo, *kuboAPI)
+	if !kubo.IsAvailable(ctx) {
+		log.Fatalf("memex-fs replicate: Kubo not available at %s", *kuboAPI)
+	}
+	for {
+		progress := newProgress("pushed")
+		r, err := dagit.Replicate(ctx, repo, kubo, progress.report)
+		progress.done()
+		switch {
+		case errors.Is(err, dagit.ErrPublishQueued):
+			fmt.Fprintf(os.Stderr, "memex-fs: Kubo went away; IPNS publish queued for the next push or pull\n")
+		case err != nil && *every == 0:
+			log.Fatalf("memex-fs replicate: %v", err)
+		case err != nil:
+			log.Printf("memex-fs replicate: %v", err)
+		default:
+			fmt.Fprintf(os.Stderr, "memex-fs: replicated %s as ipns://%s\n", r.Head, r.Name)
+			if *every == 0 {
+				fmt.Println(r.Name)
+			}
+		}
+		if *every == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			waitBackground(repo)
+			return
+		case <-time.After(*every):
+		}
 	}
+}
 
-	kubo := dagit.NewKuboClient(*kuboAPI)
-	if !kubo.IsAvailable() {
-		log.Fatalf("memex-fs pull: Kubo not available at %s", *kuboAPI)
+// runRemote manages the remotes push --remote and pull exchange with:
+// with no verb or "list" it prints each with its source and the commit last
+// exchanged, "add" names a source and "remove" forgets one.
+func runRemote(args []string) {
+	verb := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		verb, args = args[0], args[1:]
 	}
+	fs := flag.NewFlagSet("remote "+verb, flag.ExitOnError)
+	dataDir := fs.String("data", ".", "Data directory (contains .mx/)")
+	fs.Parse(args)
 
-	headCID := source
-	if strings.HasPrefix(source, "did:key:") {
-		ipnsName, err := dagit.DIDToIPNSName(source)
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs remote: open repository: %v", err)
+	}
+	switch verb {
+	case "list":
+		remotes, err := repo.Remotes()
 		if err != nil {
-			log.Fatalf("memex-fs pull: invalid DID: %v", err)
+			log.Fatalf("memex-fs remote: %v", err)
+		}
+		names := make([]string, 0, len(remotes))
+		for name := range remotes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			r := remotes[name]
+			fmt.Printf("%s\t%s\t%s\n", name, r.Source, cmp.Or(r.Head, "-"))
+		}
+	case "add":
+		if fs.NArg() != 2 {
+			log.Fatal("memex-fs remote: usage: remote add <name> <source>")
+		}
+		if err := repo.AddRemote(fs.Arg(0), fs.Arg(1)); err != nil {
+			log.Fatalf("memex-fs remote: %v", err)
+		}
+	case "remove":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs remote: usage: remote remove <name>")
+		}
+		if err := repo.RemoveRemote(fs.Arg(0)); err != nil {
+			log.Fatalf("memex-fs remote: %v", err)
+		}
+	default:
+		log.Fatal("memex-fs remote: usage: remote [list|add|remove] [flags]")
+	}
+}
+
+// runSyncServe lets other memexes push to and pull from the repo (see
+// web.SyncServer). With --stdio it speaks the protocol on stdin and stdout
+// for one client, which is how an ssh:// remote runs it; ssh has already
+// authenticated the user, so no token is asked for. Otherwise it listens on
+// --addr until interrupted, requiring a capability token unless --open.
+func runSyncServe(args []string) {
+	fs := flag.NewFlagSet("sync-serve", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		addr    = fs.String("addr", "localhost:8082", "Address to serve on")
+		stdio   = fs.Bool("stdio", false, "Serve one client on stdin/stdout, as over ssh")
+		open    = fs.Bool("open", false, "Serve without requiring capability tokens")
+	)
+	fs.Parse(args)
+
+	// Stdout carries the protocol with --stdio; anything else printed on
+	// it, such as a first-run notice, goes to stderr instead.
+	out := os.Stdout
+	if *stdio {
+		os.Stdout = os.Stderr
+	}
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs sync-serve: open repository: %v", err)
+	}
+	if *stdio {
+		conn := dagit.StdioConn(os.Stdin, out)
+		if err := dagit.ServeConn(web.NewSyncServer(repo, false), conn); err != nil {
+			log.Fatalf("memex-fs sync-serve: %v", err)
 		}
-		resolved, err := kubo.NameResolve(ipnsName)
+		waitBackground(repo)
+		return
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stopSync, err := web.StartSync(repo, *addr, !*open)
+	if err != nil {
+		log.Fatalf("memex-fs sync-serve: %v", err)
+	}
+	log.Printf("memex-fs: sync at http://%s (add it with 'memex-fs remote add <name> http://%s')", *addr, *addr)
+	<-ctx.Done()
+	stopSync()
+	waitBackground(repo)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// runBridge mirrors the directories given as arguments into the repo
+// until interrupted, for use without a mount.
+func runBridge(args []string) {
+	fs := flag.NewFlagSet("bridge", flag.ExitOnError)
+	var (
+		dataDir  = fs.String("data", ".", "Data directory (contains .mx/)")
+		interval = fs.Duration("interval", time.Minute, "How often to rescan besides change events")
+	)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatal("memex-fs bridge: usage: bridge <dir>...")
+	}
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs bridge: open repository: %v", err)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stopBridge, err := repo.StartBridge(fs.Args(), *interval)
+	if err != nil {
+		log.Fatalf("memex-fs bridge: %v", err)
+	}
+	log.Printf("memex-fs: mirroring %s", strings.Join(fs.Args(), ", "))
+	<-ctx.Done()
+	stopBridge()
+	waitBackground(repo)
+}
+
+// runGateway serves the repo's public nodes over HTTP until interrupted,
+// for sharing notes by link without mounting or exposing the rest.
+func runGateway(args []string) {
+	fs := flag.NewFlagSet("gateway", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		addr    = fs.String("addr", "localhost:8081", "Address to serve on")
+	)
+	fs.Parse(args)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs gateway: open repository: %v", err)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stopGateway, err := web.StartGateway(repo, *addr)
+	if err != nil {
+		log.Fatalf("memex-fs gateway: %v", err)
+	}
+	log.Printf("memex-fs: public gateway at http://%s/n/{id}", *addr)
+	<-ctx.Done()
+	stopGateway()
+	waitBackground(repo)
+}
+
+// runAudit prints the integrity report: links whose endpoints no longer
+// exist, and linkless nodes nobody has read recently. With --prune, broken
+// links are removed in a single commit.
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		prune   = fs.Bool("prune", false, "Remove every broken link")
+	)
+	fs.Parse(args)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs audit: open repository: %v", err)
+	}
+
+	if *prune {
+		n, err := repo.PruneBrokenLinks(ctx)
 		if err != nil {
-			log.Fatalf("memex-fs pull: IPNS resolve: %v", err)
+			log.Fatalf("memex-fs audit: prune: %v", err)
 		}
-		headCID = resolved
-		fmt.Fprintf(os.Stderr, "memex-fs: resolved %s -> %s\n", source, headCID)
+		fmt.Fprintf(os.Stderr, "memex-fs: pruned %d broken links\n", n)
 	}
 
-	if err := dagit.Pull(repo, kubo, headCID); err != nil {
-		log.Fatalf("memex-fs pull: %v", err)
+	report, err := repo.Audit.Run(ctx)
+	if err != nil {
+		log.Fatalf("memex-fs audit: %v", err)
+	}
+	fmt.Printf("broken links: %d\n", len(report.BrokenLinks))
+	for _, l := range report.BrokenLinks {
+		fmt.Printf("  %s -[%s]-> %s  (missing %s)\n", l.Source, l.Type, l.Target, l.Missing)
+	}
+	fmt.Printf("orphans: %d\n", len(report.Orphans))
+	for _, id := range report.Orphans {
+		fmt.Printf("  %s\n", id)
+	}
+}
+
+// runExport writes the graph, or the part the filter flags select, to
+// stdout or --out as Graphviz DOT, GraphML or D3-style JSON.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		format  = fs.String("format", "dot", "Output format: dot, graphml or json")
+		types   = fs.String("types", "", "Comma-separated node types to include")
+		tags    = fs.String("tags", "", "Comma-separated tags (meta \"tags\") of nodes to include")
+		from    = fs.String("from", "", "Include only nodes within --depth links of this node")
+		depth   = fs.Int("depth", 1, "Links to follow from --from")
+		out     = fs.String("out", "-", "File to write (- for stdout)")
+	)
+	fs.Parse(args)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs export: open repository: %v", err)
+	}
+	g, err := repo.ExportGraph(ctx, dag.GraphFilter{
+		SparseFilter: dag.SparseFilter{Types: splitList(*types), Tags: splitList(*tags)},
+		From:         *from,
+		Depth:        *depth,
+	})
+	if err != nil {
+		log.Fatalf("memex-fs export: %v", err)
+	}
+	var data []byte
+	switch *format {
+	case "dot":
+		data = g.DOT()
+	case "graphml":
+		data = g.GraphML()
+	case "json":
+		data = g.JSON()
+	default:
+		log.Fatalf("memex-fs export: unknown format %q (want dot, graphml or json)", *format)
+	}
+	if *out == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatalf("memex-fs export: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "memex-fs: exported %d nodes and %d links to %s\n", len(g.Nodes), len(g.Links), *out)
+}
+
+// runTrash lists the tombstones whose retention has run out. With --purge
+// they are removed, with their history and links, in a single commit.
+func runTrash(args []string) {
+	fs := flag.NewFlagSet("trash", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		purge   = fs.Bool("purge", false, "Remove the expired tombstones instead of listing them")
+	)
+	fs.Parse(args)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs trash: open repository: %v", err)
+	}
+
+	expired, err := repo.ExpireTrash(ctx, time.Now(), !*purge)
+	if err != nil {
+		log.Fatalf("memex-fs trash: %v", err)
+	}
+	verb := "would purge"
+	if *purge {
+		verb = "purged"
+	}
+	fmt.Printf("%s %d expired tombstones\n", verb, len(expired))
+	for _, e := range expired {
+		fmt.Printf("  %s  (deleted %s, %d versions)\n", e.ID, e.Deleted.Local().Format("2006-01-02"), e.Versions)
+	}
+}
+
+// runBackup archives .mx/ into the configured backup directory ("create",
+// the default), checks an archive's objects and commit chain ("verify"),
+// or replaces .mx/ with an archive's ("restore"), which --dry-run turns
+// into a report of what would change.
+func runBackup(args []string) {
+	verb := "create"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		verb, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("backup "+verb, flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		dir     = fs.String("dir", "", "Directory to write the archive to (default: the config's backup.dir)")
+		dryRun  = fs.Bool("dry-run", false, "With restore, report what would change without changing it")
+	)
+	fs.Parse(args)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	switch verb {
+	case "create":
+		repo, err := dag.OpenRepository(*dataDir)
+		if err != nil {
+			log.Fatalf("memex-fs backup: open repository: %v", err)
+		}
+		if *dir != "" {
+			cfg := dag.BackupConfig{Dir: *dir}
+			if repo.Config.Backup != nil {
+				cfg.Keep = repo.Config.Backup.Keep
+			}
+			repo.Config.Backup = &cfg
+		}
+		path, err := repo.Backup(ctx, time.Now())
+		if err != nil {
+			log.Fatalf("memex-fs backup: %v", err)
+		}
+		fmt.Println(path)
+	case "verify":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs backup: usage: backup verify <archive>")
+		}
+		check, err := dag.VerifyBackup(ctx, fs.Arg(0))
+		if err != nil {
+			log.Fatalf("memex-fs backup: %v", err)
+		}
+		printBackupCheck(check)
+		if !check.OK() {
+			os.Exit(1)
+		}
+	case "restore":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs backup: usage: backup restore [--dry-run] <archive>")
+		}
+		if !*dryRun {
+			saved, err := dag.RestoreBackup(ctx, fs.Arg(0), *dataDir)
+			if err != nil {
+				log.Fatalf("memex-fs backup: %v", err)
+			}
+			fmt.Printf("restored %s\n", fs.Arg(0))
+			if saved != "" {
+				fmt.Printf("the previous .mx is at %s\n", saved)
+			}
+			return
+		}
+		repo, err := dag.OpenRepository(*dataDir)
+		if err != nil {
+			log.Fatalf("memex-fs backup: open repository: %v", err)
+		}
+		plan, err := repo.PlanRestore(ctx, fs.Arg(0))
+		if err != nil {
+			log.Fatalf("memex-fs backup: %v", err)
+		}
+		printBackupCheck(plan.Check)
+		fmt.Printf("HEAD %s -> %s\n", cmp.Or(plan.HeadFrom, "(none)"), cmp.Or(plan.HeadTo, "(none)"))
+		for _, c := range plan.Nodes {
+			fmt.Printf("  %-8s %s\n", c.Action(), c.ID)
+		}
+		fmt.Printf("%d nodes would change, %d links added, %d removed\n", len(plan.Nodes), plan.LinksAdded, plan.LinksRemoved)
+		if !plan.Check.OK() {
+			os.Exit(1)
+		}
+	default:
+		log.Fatal("memex-fs backup: usage: backup [create|verify|restore] [flags]")
+	}
+}
+
+// printBackupCheck prints what verifying an archive found.
+func printBackupCheck(check *dag.BackupCheck) {
+	fmt.Printf("%d objects, %d commits, %d nodes\n", check.Objects, check.Commits, check.Refs)
+	for _, c := range check.Corrupt {
+		fmt.Printf("  corrupt  %s\n", c)
+	}
+	for _, m := range check.Missing {
+		fmt.Printf("  missing  %s\n", m)
+	}
+	if check.Chain != "" {
+		fmt.Printf("  chain    %s\n", check.Chain)
+	}
+	if check.OK() {
+		fmt.Println("ok")
+	}
+}
+
+// runRetype changes the type of one node, or with --all of every node of a
+// given type, e.g. to normalize "note" into "Note" in one commit.
+func runRetype(args []string) {
+	fs := flag.NewFlagSet("retype", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		all     = fs.Bool("all", false, "Treat the first argument as a type and retype every node of it")
+	)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		log.Fatal("memex-fs retype: usage: retype [--all] <id|old-type> <new-type>")
+	}
+	from, to := fs.Arg(0), fs.Arg(1)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs retype: open repository: %v", err)
+	}
+
+	if *all {
+		n, err := repo.RetypeAll(ctx, from, to)
+		if err != nil {
+			log.Fatalf("memex-fs retype: %v (%d nodes retyped before failure)", err, n)
+		}
+		fmt.Fprintf(os.Stderr, "memex-fs: retyped %d nodes %s -> %s\n", n, from, to)
+		return
+	}
+
+	if _, err := repo.Retype(from, to); err != nil {
+		log.Fatalf("memex-fs retype: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "memex-fs: retyped %s -> %s\n", from, to)
+}
+
+// runImport adds the node in a bundle exported from another memex through
+// nodes/{id}/.bundle, after checking its signature and version chain.
+// "-" reads the bundle from stdin.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dataDir := fs.String("data", ".", "Data directory (contains .mx/)")
+	depth := fs.Int("depth", 0, "Keep only the newest N versions of the node (0 keeps all)")
+	format := fs.String("format", "bundle", "What to import: bundle, ics (a calendar), vcard (contacts), or a shell history (bash, zsh, fish)")
+	fs.Parse(args)
+
+	switch *format {
+	case "bundle":
+	case "bash", "zsh", "fish":
+		importHistory(*dataDir, *format, fs.Args())
+		return
+	case "ics":
+		importCalendar(*dataDir, fs.Args())
+		return
+	case "vcard":
+		importContacts(*dataDir, fs.Args())
+		return
+	default:
+		log.Fatalf("memex-fs import: unknown format %q", *format)
+	}
+
+	if fs.NArg() != 1 {
+		log.Fatal("memex-fs import: usage: import <bundle.json|->")
+	}
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("memex-fs import: read bundle: %v", err)
+	}
+	var bundle dag.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		log.Fatalf("memex-fs import: parse bundle: %v", err)
+	}
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs import: open repository: %v", err)
+	}
+	node, err := repo.ImportBundleWith(&bundle, dag.ImportOptions{Depth: *depth})
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+	waitBackground(repo)
+	fmt.Fprintf(os.Stderr, "memex-fs: imported %s (%d versions, %d links) signed by %s\n",
+		node.ID, len(bundle.Versions), len(bundle.Links), bundle.Signer)
+}
+
+// importHistory imports a shell history file, by default the shell's own.
+func importHistory(dataDir, shell string, args []string) {
+	home, _ := os.UserHomeDir()
+	var path string
+	switch {
+	case len(args) == 1:
+		path = args[0]
+	case len(args) > 1:
+		log.Fatalf("memex-fs import: usage: import -format %s [history-file|-]", shell)
+	case shell == "bash":
+		path = filepath.Join(home, ".bash_history")
+	case shell == "zsh" && os.Getenv("HISTFILE") != "":
+		path = os.Getenv("HISTFILE")
+	case shell == "zsh":
+		path = filepath.Join(home, ".zsh_history")
+	case shell == "fish":
+		path = filepath.Join(home, ".local", "share", "fish", "fish_history")
+	}
+	data, err := readInput(path)
+	if err != nil {
+		log.Fatalf("memex-fs import: read history: %v", err)
+	}
+	entries, err := dag.ParseShellHistory(shell, bytes.NewReader(data), home)
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+
+	repo, err := dag.OpenRepository(dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs import: open repository: %v", err)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stats, err := repo.ImportShellHistory(ctx, shell, entries)
+	waitBackground(repo)
+	fmt.Fprintf(os.Stderr, "memex-fs: imported %d commands (%d already present), %d linked to projects\n",
+		stats.Created, stats.Skipped, stats.Linked)
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+}
+
+// importCalendar imports the events of an iCalendar file.
+func importCalendar(dataDir string, args []string) {
+	if len(args) != 1 {
+		log.Fatal("memex-fs import: usage: import -format ics <calendar.ics|->")
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		log.Fatalf("memex-fs import: read calendar: %v", err)
+	}
+	events, err := dag.ParseICS(bytes.NewReader(data))
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+
+	repo, err := dag.OpenRepository(dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs import: open repository: %v", err)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stats, err := repo.ImportEvents(ctx, events)
+	waitBackground(repo)
+	fmt.Fprintf(os.Stderr, "memex-fs: imported %d events (%d updated, %d unchanged), %d attendee links\n",
+		stats.Created, stats.Updated, stats.Skipped, stats.Linked)
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+}
+
+// importContacts imports the people of a vCard file.
+func importContacts(dataDir string, args []string) {
+	if len(args) != 1 {
+		log.Fatal("memex-fs import: usage: import -format vcard <contacts.vcf|->")
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		log.Fatalf("memex-fs import: read contacts: %v", err)
+	}
+	contacts, err := dag.ParseVCard(bytes.NewReader(data))
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+
+	repo, err := dag.OpenRepository(dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs import: open repository: %v", err)
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	stats, err := repo.ImportContacts(ctx, contacts)
+	waitBackground(repo)
+	fmt.Fprintf(os.Stderr, "memex-fs: imported %d people (%d merged into existing, %d unchanged), %d KNOWS links\n",
+		stats.Created, stats.Updated, stats.Skipped, stats.Linked)
+	if err != nil {
+		log.Fatalf("memex-fs import: %v", err)
+	}
+}
+
+// waitBackground blocks until the repository's background work (summaries,
+// OCR, transcription, hooks and webhooks) has finished.
+func waitBackground(repo *dag.Repository) {
+	repo.Summarizer.Wait()
+	repo.Recognizer.Wait()
+	repo.Transcriber.Wait()
+	repo.Hooks.Wait()
+	repo.Webhooks.Wait()
+}
+
+// readInput reads the named file, or stdin for "-".
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// runTag lists tags, or with a name tags a commit: HEAD, or the commit a
+// CID, RFC3339 timestamp or existing tag resolves to. With -d it deletes
+// the tag instead.
+func runTag(args []string) {
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		force   = fs.Bool("f", false, "Move an existing tag")
+		del     = fs.Bool("d", false, "Delete the named tag")
+	)
+	fs.Parse(args)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs tag: open repository: %v", err)
+	}
+
+	switch {
+	case fs.NArg() == 0:
+		for _, t := range repo.Tags.List() {
+			fmt.Printf("%s\t%s\n", t.Name, t.Commit)
+		}
+	case *del:
+		removed, err := repo.DeleteTag(fs.Arg(0))
+		if err != nil {
+			log.Fatalf("memex-fs tag: %v", err)
+		}
+		if !removed {
+			log.Fatalf("memex-fs tag: no tag %s", fs.Arg(0))
+		}
+	case fs.NArg() <= 2:
+		t, err := repo.Tag(fs.Arg(0), fs.Arg(1), *force)
+		if err != nil {
+			log.Fatalf("memex-fs tag: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "memex-fs: tagged %s as %s\n", t.Commit, t.Name)
+	default:
+		log.Fatal("memex-fs tag: usage: tag [-f] [-d] [<name> [<commit|timestamp|tag>]]")
+	}
+}
+
+// runProtect manages protected history: with no verb or "list" it prints
+// each spec and how many commits they cover, "add" protects a tag,
+// tag pattern, commit or A..B range (see dag.Repository.Protect) and
+// "remove" lifts a protection.
+func runProtect(args []string) {
+	verb := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		verb, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("protect "+verb, flag.ExitOnError)
+	dataDir := fs.String("data", ".", "Data directory (contains .mx/)")
+	fs.Parse(args)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs protect: open repository: %v", err)
+	}
+	switch verb {
+	case "list":
+		specs, err := repo.Protected()
+		if err != nil {
+			log.Fatalf("memex-fs protect: %v", err)
+		}
+		for _, spec := range specs {
+			fmt.Println(spec)
+		}
+		commits, err := repo.ProtectedCommits()
+		if err != nil {
+			log.Fatalf("memex-fs protect: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "memex-fs: %d commits protected\n", len(commits))
+	case "add":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs protect: usage: protect add <tag|pattern|commit|A..B>")
+		}
+		if err := repo.Protect(fs.Arg(0)); err != nil {
+			log.Fatalf("memex-fs protect: %v", err)
+		}
+	case "remove":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs protect: usage: protect remove <spec>")
+		}
+		removed, err := repo.Unprotect(fs.Arg(0))
+		if err != nil {
+			log.Fatalf("memex-fs protect: %v", err)
+		}
+		if !removed {
+			log.Fatalf("memex-fs protect: %s is not protected", fs.Arg(0))
+		}
+	default:
+		log.Fatal("memex-fs protect: usage: protect [list|add|remove] [flags]")
+	}
+}
+
+// runOverlay manages overlays, scratch copies of the repo as of a commit:
+// "create <name>" makes one (--commit, default HEAD), "list" shows them,
+// "merge <name>" merges what was done in one into the repo and removes it,
+// and "discard <name>" removes one unmerged. Mount one with
+// 'memex-fs mount --overlay <name>'.
+func runOverlay(args []string) {
+	verb := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		verb, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("overlay "+verb, flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		commit  = fs.String("commit", "", "Commit CID, tag or RFC3339 timestamp to start from (default HEAD)")
+	)
+	fs.Parse(args)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs overlay: open repository: %v", err)
+	}
+	switch verb {
+	case "list":
+		overlays, err := repo.Overlays()
+		if err != nil {
+			log.Fatalf("memex-fs overlay: %v", err)
+		}
+		for _, ov := range overlays {
+			state := "unchanged"
+			if ov.Head != ov.Base {
+				state = "head " + ov.Head
+			}
+			fmt.Printf("%s\t%s\tbase %s\t%s\n", ov.Name, ov.Created.Format(time.RFC3339), ov.Base, state)
+		}
+	case "create":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs overlay: usage: overlay create <name> [--commit C]")
+		}
+		ov, err := repo.CreateOverlay(fs.Arg(0), *commit)
+		if err != nil {
+			log.Fatalf("memex-fs overlay: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "memex-fs: overlay %s at %s; mount it with --overlay %s\n", ov.Name, ov.Base, ov.Name)
+	case "merge":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs overlay: usage: overlay merge <name>")
+		}
+		result, err := repo.MergeOverlay(fs.Arg(0))
+		if err != nil {
+			log.Fatalf("memex-fs overlay: %v", err)
+		}
+		waitBackground(repo)
+		fmt.Fprintf(os.Stderr, "memex-fs: merged overlay %s (%d nodes updated, %d conflicts)\n", fs.Arg(0), len(result.Updated), len(result.Conflicts))
+		for _, id := range result.Conflicts {
+			fmt.Printf("conflict %s: resolve by writing /conflicts/%s/resolve\n", id, id)
+		}
+	case "discard":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs overlay: usage: overlay discard <name>")
+		}
+		if err := repo.DiscardOverlay(fs.Arg(0)); err != nil {
+			log.Fatalf("memex-fs overlay: %v", err)
+		}
+	default:
+		log.Fatal("memex-fs overlay: usage: overlay [list|create|merge|discard] [flags]")
+	}
+}
+
+// runToken manages capability tokens: "mint" issues one and prints it,
+// "list" shows those not revoked, "revoke <id>" withdraws one.
+func runToken(args []string) {
+	verb := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		verb, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("token "+verb, flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		label   = fs.String("label", "", "What the token is for, shown by list")
+		holder  = fs.String("holder", "", "DID of the bearer, checked against node ACLs (default anonymous)")
+		ops     = fs.String("ops", dag.OpRead, "Comma-separated operations to grant: read, write")
+		types   = fs.String("types", "", "Comma-separated node types to limit the token to (default all)")
+		ttl     = fs.Duration("ttl", 0, "How long the token is valid, e.g. 24h (default forever)")
+	)
+	fs.Parse(args)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs token: open repository: %v", err)
+	}
+
+	switch verb {
+	case "mint":
+		token, c, err := repo.MintToken(*label, *holder, splitList(*ops), splitList(*types), *ttl)
+		if err != nil {
+			log.Fatalf("memex-fs token: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "memex-fs: minted token %s\n", c.ID)
+		fmt.Println(token)
+	case "list":
+		tokens, err := repo.Tokens()
+		if err != nil {
+			log.Fatalf("memex-fs token: %v", err)
+		}
+		now := time.Now()
+		for _, c := range tokens {
+			expires := "never"
+			if !c.Expires.IsZero() {
+				expires = c.Expires.Local().Format(time.RFC3339)
+				if c.Expired(now) {
+					expires += " (expired)"
+				}
+			}
+			typ := strings.Join(c.Types, ",")
+			if typ == "" {
+				typ = "*"
+			}
+			holder := c.Holder
+			if holder == "" {
+				holder = "anonymous"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, strings.Join(c.Ops, ","), typ, holder, expires, c.Label)
+		}
+	case "revoke":
+		if fs.NArg() != 1 {
+			log.Fatal("memex-fs token: usage: token revoke <id>")
+		}
+		if err := repo.RevokeToken(fs.Arg(0)); err != nil {
+			log.Fatalf("memex-fs token: %v", err)
+		}
+	default:
+		log.Fatal("memex-fs token: usage: token [mint|list|revoke] [flags]")
+	}
+}
+
+// runACL prints the ACL governing a node or, given --owner, sets the
+// node's own ACL; --clear removes it.
+func runACL(args []string) {
+	fs := flag.NewFlagSet("acl", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		owner   = fs.String("owner", "", "Set the ACL, owned by this DID")
+		readers = fs.String("readers", "", "Comma-separated DIDs that may read (* for anyone)")
+		writers = fs.String("writers", "", "Comma-separated DIDs that may write besides the owner (* for anyone)")
+		remove  = fs.Bool("clear", false, "Remove the node's own ACL")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("memex-fs acl: usage: acl [--owner <did> [--readers ...] [--writers ...] | --clear] <id>")
+	}
+	id := fs.Arg(0)
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs acl: open repository: %v", err)
+	}
+	defer waitBackground(repo)
+
+	switch {
+	case *remove:
+		if _, err := repo.SetACL(id, nil); err != nil {
+			log.Fatalf("memex-fs acl: %v", err)
+		}
+	case *owner != "":
+		acl := &dag.ACL{Owner: *owner, Readers: splitList(*readers), Writers: splitList(*writers)}
+		if _, err := repo.SetACL(id, acl); err != nil {
+			log.Fatalf("memex-fs acl: %v", err)
+		}
+	default:
+		if _, err := repo.GetNode(id); err != nil {
+			log.Fatalf("memex-fs acl: %v", err)
+		}
+		acl, governor := repo.ACLFor(id)
+		if acl == nil {
+			fmt.Println("no ACL: open to every token")
+			return
+		}
+		if governor != id {
+			fmt.Printf("inherited from %s\n", governor)
+		}
+		fmt.Printf("owner\t%s\nreaders\t%s\nwriters\t%s\n", acl.Owner, strings.Join(acl.Readers, ","), strings.Join(acl.Writers, ","))
+	}
+}
+
+// runTUI runs the terminal interface, on the repository directly or, with
+// --mount, through a running mount's files.
+func runTUI(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		mount   = fs.String("mount", "", "Work through the memex-fs mounted here instead of opening --data")
+	)
+	fs.Parse(args)
+
+	var backend tui.Backend
+	if *mount != "" {
+		backend = tui.MountBackend{Dir: *mount}
+	} else {
+		repo, err := dag.OpenRepository(*dataDir)
+		if err != nil {
+			log.Fatalf("memex-fs tui: open repository: %v", err)
+		}
+		defer waitBackground(repo)
+		backend = tui.RepoBackend{Repo: repo}
+	}
+	if err := tui.Run(backend, os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("memex-fs tui: %v", err)
+	}
+}
+
+// runMCP serves the repository over the Model Context Protocol on stdin and
+// stdout, for an MCP client that launches memex-fs as a subprocess.
+func runMCP(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	var (
+		dataDir = fs.String("data", ".", "Data directory (contains .mx/)")
+		token   = fs.String("token", os.Getenv("MEMEX_TOKEN"), "Capability token limiting what the session may do (default $MEMEX_TOKEN)")
+	)
+	fs.Parse(args)
+
+	// stdout carries the protocol. The repository prints its warnings with
+	// fmt.Printf, so point os.Stdout at stderr before anything can.
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	repo, err := dag.OpenRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("memex-fs mcp: open repository: %v", err)
+	}
+	srv := mcp.NewServer(repo)
+	if *token != "" {
+		if err := srv.RequireToken(*token); err != nil {
+			log.Fatalf("memex-fs mcp: --token: %v", err)
+		}
+	}
+	err = srv.Serve(ctx, os.Stdin, out)
+	waitBackground(repo)
+	if err != nil && ctx.Err() == nil {
+		log.Fatalf("memex-fs mcp: %v", err)
 	}
-	fmt.Fprintf(os.Stderr, "memex-fs: pulled %s; browse at /at/%s/ on a mounted repo\n", headCID, headCID)
 }
@@ -0,0 +1,361 @@
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// MetaACL is the meta key holding a node's access control list. It is a
+// reserved key: the mount and MCP refuse meta writes to it, and it is set
+// only with SetACL.
+const MetaACL = "acl"
+
+// LinkPartOf links a node to the node it belongs to. A node without an
+// ACL of its own is governed by the ACL of the nearest node it is
+// PART_OF, so one ACL covers a whole subtree.
+const LinkPartOf = "PART_OF"
+
+// Anyone, listed among an ACL's readers or writers, grants that access to
+// every identity, including unauthenticated ones.
+const Anyone = "*"
+
+// ErrAccessDenied is returned when an ACL forbids a remote write.
+var ErrAccessDenied = errors.New("access denied")
+
+// maxACLDepth bounds the PART_OF chain searched for a governing ACL.
+const maxACLDepth = 16
+
+// ACL says who may read and write the nodes it governs, by DID. The owner
+// may do both and is the only one who may change the ACL. ACLs bind the
+// remote interfaces — the web API, the gateway and sync — and never the
+// local mount, whose user owns the repository.
+type ACL struct {
+	Owner   string   `json:"owner"`
+	Readers []string `json:"readers,omitempty"`
+	Writers []string `json:"writers,omitempty"`
+}
+
+// CanRead reports whether did may read what a governs. A nil ACL governs
+// nothing and allows everyone; an empty did is an anonymous client.
+func (a *ACL) CanRead(did string) bool {
+	return a.CanWrite(did) || slices.Contains(a.Readers, Anyone) || did != "" && slices.Contains(a.Readers, did)
+}
+
+// CanWrite reports whether did may change what a governs.
+func (a *ACL) CanWrite(did string) bool {
+	if a == nil || slices.Contains(a.Writers, Anyone) {
+		return true
+	}
+	return did != "" && (did == a.Owner || slices.Contains(a.Writers, did))
+}
+
+// nodeACL reads node's own ACL, if it has one.
+func nodeACL(node *NodeEnvelope) *ACL {
+	if node == nil || node.Meta[MetaACL] == nil {
+		return nil
+	}
+	data, err := json.Marshal(node.Meta[MetaACL])
+	if err != nil {
+		return nil
+	}
+	var a ACL
+	if err := json.Unmarshal(data, &a); err != nil || a.Owner == "" {
+		return nil
+	}
+	return &a
+}
+
+// resolveACL finds the ACL governing id: its own, or that of the nearest
+// node up its PART_OF links. Returns nil if none is found.
+func resolveACL(id string, node func(string) *NodeEnvelope, partOf func(string) []string) (acl *ACL, governor string) {
+	seen := map[string]bool{}
+	frontier := []string{id}
+	for depth := 0; depth <= maxACLDepth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, cur := range frontier {
+			if seen[cur] {
+				continue
+			}
+			seen[cur] = true
+			if a := nodeACL(node(cur)); a != nil {
+				return a, cur
+			}
+			next = append(next, partOf(cur)...)
+		}
+		sort.Strings(next) // a node in two subtrees takes the first by ID
+		frontier = next
+	}
+	return nil, ""
+}
+
+// partOfTargets returns the targets of the PART_OF links from id in links.
+func partOfTargets(links []LinkEntry, id string) []string {
+	var out []string
+	for _, l := range links {
+		if l.Source == id && l.Type == LinkPartOf {
+			out = append(out, LinkTargetParent(l.Target))
+		}
+	}
+	return out
+}
+
+// ACLFor returns the ACL governing the live node id and the ID of the node
+// it is set on, or nil when id is ungoverned.
+func (r *Repository) ACLFor(id string) (*ACL, string) {
+	return resolveACL(id, func(id string) *NodeEnvelope {
+		node, _ := r.GetNode(id)
+		return node
+	}, func(id string) []string {
+		return partOfTargets(r.Links.LinksFrom(id), id)
+	})
+}
+
+// SetACL sets id's own ACL, or removes it when acl is nil. This is the
+// local user's call and is not itself access-checked.
+func (r *Repository) SetACL(id string, acl *ACL) (*NodeEnvelope, error) {
+	var value interface{}
+	if acl != nil {
+		if _, err := DecodeDIDKey(acl.Owner); err != nil {
+			return nil, fmt.Errorf("acl owner: %w", err)
+		}
+		m := map[string]interface{}{"owner": acl.Owner}
+		if len(acl.Readers) > 0 {
+			m["readers"] = toInterfaces(acl.Readers)
+		}
+		if len(acl.Writers) > 0 {
+			m["writers"] = toInterfaces(acl.Writers)
+		}
+		value = m
+	}
+	unlock := r.lockNode(id)
+	defer unlock()
+	return r.updateMeta(id, map[string]interface{}{MetaACL: value}, "set acl "+id)
+}
+
+// Authorized reports whether a remote client holding c may perform op on
+// node: c must allow it, and so must node's ACL for c's holder. A nil c is
+// the local user, who may do anything.
+func (r *Repository) Authorized(c *Capability, node *NodeEnvelope, op string) bool {
+	if c == nil {
+		return true
+	}
+	if !c.Allows(op, node.Type) {
+		return false
+	}
+	acl, _ := r.ACLFor(node.ID)
+	if op == OpWrite {
+		return acl.CanWrite(c.Holder)
+	}
+	return acl.CanRead(c.Holder)
+}
+
+// UnreadableContent returns the content blocks, by CID filename, of the
+// nodes Authorized refuses c read: those of every version the store holds.
+// A block the current version of a readable node shares is left out. An
+// envelope names its node and type, so it can be checked on its own;
+// content blocks do not, and are checked against this.
+func (r *Repository) UnreadableContent(c *Capability) (map[string]bool, error) {
+	ids, err := r.Refs.List()
+	if err != nil {
+		return nil, err
+	}
+	hidden, shared := make(map[string]bool), make(map[string]bool)
+	for _, id := range ids {
+		head, err := r.Refs.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !r.Store.Has(head) {
+			continue // a sparse copy's missing version
+		}
+		data, err := r.Store.Get(head)
+		if err != nil {
+			return nil, err
+		}
+		node, err := DecodeNode(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", id, err)
+		}
+		if r.Authorized(c, node, OpRead) {
+			if block, ok := ContentLink(data); ok {
+				shared[CIDToFilename(block)] = true
+			}
+			continue
+		}
+		for data != nil {
+			if block, ok := ContentLink(data); ok {
+				hidden[CIDToFilename(block)] = true
+			}
+			version, err := DecodeNode(data, nil)
+			if err != nil || version.Prev == "" {
+				break
+			}
+			prev, err := cidFromFilename(version.Prev)
+			if err != nil || !r.Store.Has(prev) {
+				break // history a shallow copy does not hold
+			}
+			data, _ = r.Store.Get(prev)
+		}
+	}
+	for name := range shared {
+		delete(hidden, name)
+	}
+	return hidden, nil
+}
+
+// ACLViolation is a change in a pulled commit that its author was not
+// allowed to make.
+type ACLViolation struct {
+	Commit   string // CID of the offending commit
+	Author   string // its DID, or "" if the commit's signature did not verify
+	ID       string // node changed, or source of the link changed
+	Governor string // node whose ACL forbids it
+	Reason   string
+}
+
+// ACLError lists the ACL violations found in a commit chain.
+type ACLError struct {
+	Violations []ACLViolation
+}
+
+func (e *ACLError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		author := v.Author
+		if author == "" {
+			author = "an unsigned author"
+		}
+		lines[i] = fmt.Sprintf("commit %s: %s %s (governed by %s)", v.Commit, author, v.Reason, v.Governor)
+	}
+	return fmt.Sprintf("%d ACL violation(s):\n%s", len(lines), strings.Join(lines, "\n"))
+}
+
+// Is makes an ACLError match ErrAccessDenied.
+func (e *ACLError) Is(target error) bool { return target == ErrAccessDenied }
+
+// CheckACLs walks the commit chain from head back to the history HEAD
+// already holds, or as far as the store holds it, and checks every change
+// each new commit made against the ACLs in force in its parent: a changed
+// node, or the source of an added or removed link, must be writable by
+// the commit's author, and only an ACL's owner may change it. An author
+// counts only if the commit is signed by them. Unsigned commits are
+// checked, as by no one, only when this repository signs its own (see
+// Config.AppendOnly); otherwise no author could be told apart, and a
+// remote writer's changes are checked as they are merged instead (see
+// MergeCommitFor). Violations are returned as an *ACLError.
+func (r *Repository) CheckACLs(head gocid.Cid) error {
+	var known map[string]bool
+	if local, err := r.Commits.Head(); err == nil && local.Defined() {
+		known = r.ancestors(local)
+	}
+	var violations []ACLViolation
+	current := head
+	for current.Defined() && !known[CIDToFilename(current)] {
+		commit, err := r.Commits.GetCommit(current)
+		if err != nil {
+			return err
+		}
+		if commit.Parent == "" {
+			break // the root commit creates everything; nothing governed it
+		}
+		parentCID, err := cidFromFilename(commit.Parent)
+		if err != nil {
+			return fmt.Errorf("commit %s: bad parent %s", CIDToFilename(current), commit.Parent)
+		}
+		if !r.Store.Has(parentCID) {
+			break // a shallow pull's boundary
+		}
+		parent, err := r.Commits.GetCommit(parentCID)
+		if err != nil {
+			return err
+		}
+		author, check := "", r.Commits.signer != nil
+		if _, err := r.Commits.verifyCommit(current); err == nil {
+			author, check = commit.Author, true
+		}
+		if check {
+			violations = append(violations, r.commitACLViolations(CIDToFilename(current), author, parent, commit)...)
+		}
+		current = parentCID
+	}
+	if len(violations) > 0 {
+		return &ACLError{Violations: violations}
+	}
+	return nil
+}
+
+// commitACLViolations checks one commit's changes against parent's ACLs.
+func (r *Repository) commitACLViolations(name, author string, parent, commit *CommitObject) []ACLViolation {
+	envelope := func(refs map[string]string) func(string) *NodeEnvelope {
+		return func(id string) *NodeEnvelope { return r.Commits.envelope(refs[id]) }
+	}
+	before := func(id string) (*ACL, string) {
+		links := parent.Links
+		if _, ok := parent.Refs[id]; !ok {
+			links = commit.Links // a new node joins the subtree it links into
+		}
+		return resolveACL(id, envelope(parent.Refs), func(cur string) []string {
+			if cur == id {
+				return partOfTargets(links, cur)
+			}
+			return partOfTargets(parent.Links, cur)
+		})
+	}
+
+	var out []ACLViolation
+	check := func(id, what string) {
+		acl, governor := before(id)
+		if !acl.CanWrite(author) {
+			out = append(out, ACLViolation{Commit: name, Author: author, ID: id, Governor: governor, Reason: what})
+		}
+	}
+
+	ids := make(map[string]bool)
+	for id := range parent.Refs {
+		ids[id] = true
+	}
+	for id := range commit.Refs {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		if parent.Refs[id] == commit.Refs[id] {
+			continue
+		}
+		check(id, "changed "+id)
+		oldACL := nodeACL(r.Commits.envelope(parent.Refs[id]))
+		newACL := nodeACL(r.Commits.envelope(commit.Refs[id]))
+		if oldACL != nil && !reflect.DeepEqual(oldACL, newACL) && author != oldACL.Owner {
+			out = append(out, ACLViolation{Commit: name, Author: author, ID: id, Governor: id, Reason: "changed the ACL of " + id})
+		}
+	}
+
+	old := make(map[LinkEntry]bool, len(parent.Links))
+	for _, l := range parent.Links {
+		old[l] = true
+	}
+	cur := make(map[LinkEntry]bool, len(commit.Links))
+	for _, l := range commit.Links {
+		cur[l] = true
+		if !old[l] && parent.Refs[l.Source] != "" {
+			check(l.Source, fmt.Sprintf("added link %s -[%s]-> %s", l.Source, l.Type, l.Target))
+		}
+	}
+	for _, l := range parent.Links {
+		if !cur[l] {
+			check(l.Source, fmt.Sprintf("removed link %s -[%s]-> %s", l.Source, l.Type, l.Target))
+		}
+	}
+	return out
+}
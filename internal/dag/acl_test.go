@@ -0,0 +1,139 @@
+package dag
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func newDID(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeDIDKey(pub)
+}
+
+func TestACL_Inheritance(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := openTestRepo(t)
+	alice, bob := newDID(t), newDID(t)
+	repo.CreateNode("project:x", "Project", nil, nil)
+	repo.CreateNode("note:y", "Note", nil, nil)
+	repo.CreateNode("note:free", "Note", nil, nil)
+	repo.CreateLink("note:y", "project:x", LinkPartOf)
+	if _, err := repo.SetACL("project:x", &ACL{Owner: alice, Readers: []string{bob}}); err != nil {
+		t.Fatal(err)
+	}
+
+	acl, governor := repo.ACLFor("note:y")
+	if acl == nil || governor != "project:x" || acl.Owner != alice {
+		t.Fatalf("ACLFor(note:y) = %+v, %q", acl, governor)
+	}
+	if acl, _ := repo.ACLFor("note:free"); acl != nil {
+		t.Errorf("ungoverned node has ACL %+v", acl)
+	}
+	if err := ValidateMeta(repo.mustNode(t, "project:x"), map[string]interface{}{MetaACL: nil}); err == nil {
+		t.Error("a meta write removed the ACL")
+	}
+
+	y := repo.mustNode(t, "note:y")
+	for _, tc := range []struct {
+		holder string
+		op     string
+		want   bool
+	}{
+		{alice, OpWrite, true},
+		{bob, OpRead, true},
+		{bob, OpWrite, false},
+		{"", OpRead, false},
+	} {
+		c := &Capability{Holder: tc.holder, Ops: []string{OpWrite}}
+		if got := repo.Authorized(c, y, tc.op); got != tc.want {
+			t.Errorf("Authorized(%s, %s) = %v, want %v", tc.holder, tc.op, got, tc.want)
+		}
+	}
+	if !repo.Authorized(nil, y, OpWrite) {
+		t.Error("the local user was refused")
+	}
+	if !repo.Authorized(&Capability{Ops: []string{OpRead}}, repo.mustNode(t, "note:free"), OpRead) {
+		t.Error("an ungoverned node was refused")
+	}
+}
+
+func (r *Repository) mustNode(t *testing.T, id string) *NodeEnvelope {
+	t.Helper()
+	node, err := r.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func TestCheckACLs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := openTestRepo(t)
+	repo.CreateNode("project:x", "Project", nil, nil)
+	repo.CreateNode("note:y", "Note", []byte("one"), nil)
+	repo.CreateLink("note:y", "project:x", LinkPartOf)
+	if _, err := repo.SetACL("project:x", &ACL{Owner: repo.author()}); err != nil {
+		t.Fatal(err)
+	}
+	// The owner edits under their ACL; their own history is not rechecked.
+	repo.UpdateContent("project:x", []byte("edited"))
+	head, _ := repo.Commits.Head()
+	if err := repo.CheckACLs(head); err != nil {
+		t.Fatalf("the owner's own history: %v", err)
+	}
+
+	// An unsigned edit made elsewhere, in an overlay sharing the store.
+	if _, err := repo.CreateOverlay("elsewhere", ""); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := repo.OverlayRoot("elsewhere")
+	other, err := OpenRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.UpdateContent("note:y", []byte("two"))
+	theirs, _ := other.Commits.Head()
+	if err := repo.CheckACLs(theirs); err != nil {
+		t.Fatalf("unsigned commits checked in a repository that signs none: %v", err)
+	}
+
+	// A repository that signs its commits takes an unsigned one as no one's.
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	repo.Commits.signer = key
+	var aclErr *ACLError
+	if err := repo.CheckACLs(theirs); !errors.As(err, &aclErr) {
+		t.Fatalf("CheckACLs = %v, want *ACLError", err)
+	}
+	if len(aclErr.Violations) != 1 || aclErr.Violations[0].ID != "note:y" || aclErr.Violations[0].Governor != "project:x" {
+		t.Errorf("violations = %+v", aclErr.Violations)
+	}
+}
+
+func TestImportBundle_ACL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	src := openTestRepo(t)
+	src.CreateNode("project:x", "Project", nil, nil)
+	src.CreateNode("note:y", "Note", []byte("hi"), nil)
+	src.CreateLink("note:y", "project:x", LinkPartOf)
+	b, err := src.ExportBundle("note:y")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestRepo(t)
+	dst.CreateNode("project:x", "Project", nil, nil)
+	dst.SetACL("project:x", &ACL{Owner: newDID(t)})
+	if _, err := dst.ImportBundle(b); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("import into a governed subtree: %v, want ErrAccessDenied", err)
+	}
+	dst.SetACL("project:x", &ACL{Owner: newDID(t), Writers: []string{b.Signer}})
+	if _, err := dst.ImportBundle(b); err != nil {
+		t.Fatalf("import by a writer: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package dag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// activityCommitLimit bounds how far back the activity report walks the
+// commit chain.
+const activityCommitLimit = 10000
+
+// DayActivity is one local calendar day of repository activity.
+type DayActivity struct {
+	Day     string // YYYY-MM-DD
+	Commits int
+	Changes int // node versions written (creates, edits, deletes)
+	Reads   int // access-log entries
+}
+
+// TypeActivity aggregates activity over every node of one type.
+type TypeActivity struct {
+	Nodes   int `json:"nodes"` // live nodes
+	Changes int `json:"changes"`
+	Reads   int `json:"reads"`
+}
+
+// NodeActivity is one node's change and read counts.
+type NodeActivity struct {
+	ID      string
+	Changes int
+	Reads   int
+}
+
+// ActivityReport summarizes how the repository has been written and read
+// over time, from the commit chain and the access log.
+type ActivityReport struct {
+	Days  []DayActivity // oldest first
+	Types map[string]TypeActivity
+	Nodes []NodeActivity // busiest first: reads, then changes, then ID
+}
+
+// activityCache holds the last report, keyed by HEAD and access-log size:
+// the only two inputs, and both append-only.
+type activityCache struct {
+	mu     sync.Mutex
+	key    string
+	report *ActivityReport
+}
+
+// Activity returns the activity report, recomputing it only when a commit
+// or a read has happened since the last call.
+func (r *Repository) Activity(ctx context.Context) (*ActivityReport, error) {
+	logPath := filepath.Join(r.MxDir(), "access.jsonl")
+	var logSize int64
+	if fi, err := os.Stat(logPath); err == nil {
+		logSize = fi.Size()
+	}
+	key := fmt.Sprintf("%s:%d", r.headKey(), logSize)
+
+	r.shared.activity.mu.Lock()
+	defer r.shared.activity.mu.Unlock()
+	if r.shared.activity.report != nil && r.shared.activity.key == key {
+		return r.shared.activity.report, nil
+	}
+	report, err := r.buildActivity(ctx, logPath)
+	if err != nil {
+		return nil, err
+	}
+	r.shared.activity.key, r.shared.activity.report = key, report
+	return report, nil
+}
+
+func (r *Repository) buildActivity(ctx context.Context, logPath string) (*ActivityReport, error) {
+	days := make(map[string]*DayActivity)
+	day := func(t time.Time) *DayActivity {
+		k := t.In(time.Local).Format(DayLayout)
+		d, ok := days[k]
+		if !ok {
+			d = &DayActivity{Day: k}
+			days[k] = d
+		}
+		return d
+	}
+	nodes := make(map[string]*NodeActivity)
+	node := func(id string) *NodeActivity {
+		n, ok := nodes[id]
+		if !ok {
+			n = &NodeActivity{ID: id}
+			nodes[id] = n
+		}
+		return n
+	}
+
+	commits, err := r.Commits.History(activityCommitLimit)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d := day(c.Timestamp)
+		d.Commits++
+		d.Changes += len(c.Changes)
+		for _, ch := range c.Changes {
+			node(ch.ID).Changes++
+		}
+	}
+
+	if f, err := os.Open(logPath); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry accessLogEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+			ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+			if err != nil {
+				continue
+			}
+			day(ts).Reads++
+			node(entry.NodeID).Reads++
+		}
+		f.Close()
+	}
+
+	report := &ActivityReport{Types: make(map[string]TypeActivity)}
+	for _, d := range days {
+		report.Days = append(report.Days, *d)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Day < report.Days[j].Day })
+
+	// Attribute each node to its current type. Hard-deleted nodes have no
+	// envelope left to ask and are only counted per day and per node.
+	for id, n := range nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		env, err := r.getNodeEnvelope(id)
+		if err != nil {
+			continue
+		}
+		t := report.Types[env.Type]
+		t.Changes += n.Changes
+		t.Reads += n.Reads
+		report.Types[env.Type] = t
+	}
+	for _, typ := range r.Search.AllTypes() {
+		t := report.Types[typ]
+		t.Nodes = len(r.Search.FilterByType(typ, 0))
+		report.Types[typ] = t
+	}
+
+	for _, n := range nodes {
+		report.Nodes = append(report.Nodes, *n)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		a, b := report.Nodes[i], report.Nodes[j]
+		if a.Reads != b.Reads {
+			return a.Reads > b.Reads
+		}
+		if a.Changes != b.Changes {
+			return a.Changes > b.Changes
+		}
+		return a.ID < b.ID
+	})
+	return report, nil
+}
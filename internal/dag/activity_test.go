@@ -0,0 +1,58 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestActivity(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.UpdateContent("note:a", []byte("a2"))
+	repo.CreateNode("task:b", "Task", []byte("b"), nil)
+
+	// Two reads of task:b, one of note:a, in the fuse.AccessLog format.
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	var log []byte
+	for _, id := range []string{"task:b", "note:a", "task:b"} {
+		log = append(log, fmt.Sprintf(`{"ts":%q,"node":%q,"field":"content"}`+"\n", now, id)...)
+	}
+	if err := os.WriteFile(filepath.Join(repo.MxDir(), "access.jsonl"), log, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := repo.Activity(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Days) != 1 {
+		t.Fatalf("days = %+v, want one day", report.Days)
+	}
+	if d := report.Days[0]; d.Commits != 3 || d.Changes != 3 || d.Reads != 3 {
+		t.Errorf("day = %+v, want 3 commits, 3 changes, 3 reads", d)
+	}
+	if got := report.Types["Note"]; got != (TypeActivity{Nodes: 1, Changes: 2, Reads: 1}) {
+		t.Errorf("Note = %+v", got)
+	}
+	if got := report.Types["Task"]; got != (TypeActivity{Nodes: 1, Changes: 1, Reads: 2}) {
+		t.Errorf("Task = %+v", got)
+	}
+	if len(report.Nodes) != 2 || report.Nodes[0].ID != "task:b" {
+		t.Errorf("top nodes = %+v, want task:b first", report.Nodes)
+	}
+
+	// Cached until HEAD or the access log moves.
+	again, _ := repo.Activity(context.Background())
+	if again != report {
+		t.Error("report recomputed with no new activity")
+	}
+	repo.CreateNode("note:c", "Note", nil, nil)
+	again, _ = repo.Activity(context.Background())
+	if again == report || again.Types["Note"].Nodes != 2 {
+		t.Errorf("report not refreshed after a commit: %+v", again.Types)
+	}
+}
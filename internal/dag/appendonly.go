@@ -0,0 +1,182 @@
+package dag
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// ErrAppendOnly is returned for operations that would destroy history in
+// an append-only repository: hard deletes and purges.
+var ErrAppendOnly = errors.New("repository is append-only")
+
+// appendOnlyAnchorFile records, under .mx, the HEAD commit when append-only
+// mode was first enabled. Commits up to and including it predate the mode
+// and are not required to be signed; every commit after it must be.
+const appendOnlyAnchorFile = "append-only"
+
+// ChainError is a commit that failed verification of the append-only
+// commit chain.
+type ChainError struct {
+	CID    string
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("commit %s: %s", e.CID, e.Reason)
+}
+
+// signingPayload is what a commit's signature covers: the commit without
+// its signature, canonically encoded.
+func (c *CommitObject) signingPayload() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = ""
+	return CanonicalJSON(&unsigned)
+}
+
+// openAppendOnly puts r into append-only mode: commits are signed with the
+// local identity from now on, and the existing chain is verified back to
+// the anchor, which is recorded on first use.
+func (r *Repository) openAppendOnly() error {
+	if r.identity == nil {
+		return fmt.Errorf("append-only mode needs an identity to sign commits")
+	}
+	key, err := r.identity.SigningKey()
+	if err != nil {
+		return err
+	}
+	r.Commits.signer = key
+
+	path := filepath.Join(r.MxDir(), appendOnlyAnchorFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		head, err := r.Commits.Head()
+		if err != nil {
+			return err
+		}
+		anchor := ""
+		if head.Defined() {
+			anchor = CIDToFilename(head)
+		}
+		return SafeWrite(path, []byte(anchor+"\n"), 0444)
+	}
+	if err != nil {
+		return fmt.Errorf("read append-only anchor: %w", err)
+	}
+	if _, err := r.Commits.VerifyChain(strings.TrimSpace(string(data))); err != nil {
+		return fmt.Errorf("verify commit chain: %w", err)
+	}
+	return nil
+}
+
+// VerifyChain walks the commit chain from HEAD back to anchor, the CID of
+// the last commit allowed to be unsigned ("" if none is), checking that
+// each commit's bytes hash to its CID and that it carries a valid
+// signature by its author. Returns the number of commits verified; a
+// failure is a *ChainError.
+func (cl *CommitLog) VerifyChain(anchor string) (int, error) {
+	current, err := cl.Head()
+	if err != nil {
+		return 0, err
+	}
+	verified := 0
+	for current.Defined() {
+		name := CIDToFilename(current)
+		if name == anchor {
+			return verified, nil
+		}
+		commit, err := cl.verifyCommit(current)
+		if err != nil {
+			return verified, &ChainError{CID: name, Reason: err.Error()}
+		}
+		verified++
+		if commit.Parent == "" {
+			break
+		}
+		parent, err := cidFromFilename(commit.Parent)
+		if err != nil {
+			return verified, &ChainError{CID: name, Reason: "bad parent " + commit.Parent}
+		}
+		current = parent
+	}
+	if anchor != "" {
+		return verified, &ChainError{CID: anchor, Reason: "append-only anchor is not in the chain"}
+	}
+	return verified, nil
+}
+
+// VerifyHistory checks the commits reachable from head, as far back as
+// the store holds them, for a clone: each commit's bytes must hash to its
+// CID, and a signed commit must carry a valid signature by its author.
+// Returns how many commits were signed and how many were not; a failure
+// is a *ChainError.
+func (cl *CommitLog) VerifyHistory(head gocid.Cid) (signed, unsigned int, err error) {
+	for current := head; current.Defined() && cl.store.Has(current); {
+		name := CIDToFilename(current)
+		data, err := cl.store.Get(current)
+		if err != nil {
+			return signed, unsigned, &ChainError{CID: name, Reason: err.Error()}
+		}
+		if !CIDMatches(current, data) {
+			return signed, unsigned, &ChainError{CID: name, Reason: "content does not match its CID"}
+		}
+		commit, err := DecodeCommit(data)
+		if err != nil {
+			return signed, unsigned, &ChainError{CID: name, Reason: err.Error()}
+		}
+		if commit.Signature == "" {
+			unsigned++
+		} else if _, err := cl.verifyCommit(current); err != nil {
+			return signed, unsigned, &ChainError{CID: name, Reason: err.Error()}
+		} else {
+			signed++
+		}
+		if commit.Parent == "" {
+			break
+		}
+		if current, err = cidFromFilename(commit.Parent); err != nil {
+			return signed, unsigned, &ChainError{CID: name, Reason: "bad parent " + commit.Parent}
+		}
+	}
+	return signed, unsigned, nil
+}
+
+// verifyCommit loads the commit at c and checks its hash and signature.
+func (cl *CommitLog) verifyCommit(c gocid.Cid) (*CommitObject, error) {
+	data, err := cl.store.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	if !CIDMatches(c, data) {
+		return nil, fmt.Errorf("content does not match its CID")
+	}
+	commit, err := DecodeCommit(data)
+	if err != nil {
+		return nil, err
+	}
+	if commit.Signature == "" {
+		return nil, fmt.Errorf("unsigned")
+	}
+	pub, err := DecodeDIDKey(commit.Author)
+	if err != nil {
+		return nil, fmt.Errorf("author: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(commit.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	payload, err := commit.signingPayload()
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(pub, payload, sig) {
+		return nil, fmt.Errorf("signature does not match %s", commit.Author)
+	}
+	return commit, nil
+}
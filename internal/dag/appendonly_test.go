@@ -0,0 +1,108 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openAppendOnlyRepo(t *testing.T, dir string) (*Repository, error) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, ".mx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".mx", "config.json"), []byte(`{"append_only": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return OpenRepository(dir)
+}
+
+func TestAppendOnly(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	repo, err := openAppendOnlyRepo(t, dir)
+	if err != nil {
+		t.Fatalf("OpenRepository: %v", err)
+	}
+
+	if _, err := repo.CreateNode("a", "Note", []byte("one"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteNode("a", true); !errors.Is(err, ErrAppendOnly) {
+		t.Fatalf("hard delete: got %v, want ErrAppendOnly", err)
+	}
+	if err := repo.DeleteNode("a", false); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, err := repo.ExpireTrash(context.Background(), time.Now(), false); !errors.Is(err, ErrAppendOnly) {
+		t.Fatalf("purge: got %v, want ErrAppendOnly", err)
+	}
+
+	n, err := repo.Commits.VerifyChain("")
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("verified %d commits, want 2", n)
+	}
+
+	// A clean reopen verifies the chain.
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	// An unsigned commit on top of the chain fails verification.
+	forged := NewCommitLog(filepath.Join(dir, ".mx", "HEAD"), repo.Store, repo.Commits.author)
+	if _, err := forged.Commit(repo.Refs, repo.Links, "forged"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenRepository(dir)
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("reopen after unsigned commit: got %v, want *ChainError", err)
+	}
+}
+
+func TestAppendOnly_AnchorsExistingHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateNode("old", "Note", []byte("before"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enabling the mode anchors the unsigned history already there.
+	repo, err = openAppendOnlyRepo(t, dir)
+	if err != nil {
+		t.Fatalf("enable append-only: %v", err)
+	}
+	if _, err := repo.CreateNode("new", "Note", []byte("after"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	// Tampering with a signed commit breaks its hash.
+	head, err := repo.Commits.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := repo.Store.locate(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, 0644)
+	if err := os.WriteFile(path, []byte(`{"v":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRepository(dir); err == nil {
+		t.Fatal("reopen after tampering succeeded")
+	}
+}
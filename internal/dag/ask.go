@@ -0,0 +1,174 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	askSearchHits    = 5    // search results to start from
+	askRelatedPerHit = 2    // related nodes added per search result
+	askMaxSources    = 8    // nodes given to the model
+	askSourceBytes   = 4000 // content bytes per node in the prompt
+	askCacheSize     = 64   // see also lowMemoryAskCacheSize
+
+	askPrompt = "Answer the question using only the notes provided. Cite the " +
+		"IDs of the notes you rely on in square brackets. If the notes do not " +
+		"contain the answer, say so."
+)
+
+// askStopWords are question words too common to help retrieval.
+var askStopWords = map[string]bool{
+	"what": true, "who": true, "whom": true, "when": true, "where": true,
+	"why": true, "how": true, "which": true, "is": true, "are": true,
+	"was": true, "were": true, "do": true, "does": true, "did": true,
+	"the": true, "an": true, "of": true, "to": true, "in": true, "on": true,
+	"for": true, "and": true, "or": true, "about": true, "my": true,
+	"me": true, "can": true, "should": true, "it": true, "that": true,
+}
+
+// Answer is the reply to a question asked of the graph.
+type Answer struct {
+	Question string
+	Text     string
+	// Sources are the nodes the answer was drawn from, most relevant
+	// first.
+	Sources []string
+	// Generated is false for the retrieval-only fallback used when no
+	// LLM is configured or the model call failed.
+	Generated bool
+}
+
+// askCache memoizes answers until the next commit, so the repeated stats
+// and reads of one /ask/ lookup cost a single model call.
+type askCache struct {
+	mu      sync.Mutex
+	key     string
+	answers map[string]*askEntry
+}
+
+type askEntry struct {
+	once   sync.Once
+	answer *Answer
+}
+
+// Ask answers question from the repository: nodes matching the question in
+// full-text search, widened by their most related nodes, are handed to the
+// LLM as context. Without an LLM the answer lists those nodes instead.
+// Answers are cached until the next commit.
+func (r *Repository) Ask(ctx context.Context, question string) *Answer {
+	key := r.headKey()
+	r.shared.asks.mu.Lock()
+	if r.shared.asks.key != key || len(r.shared.asks.answers) >= r.askCacheLimit() {
+		r.shared.asks.key = key
+		r.shared.asks.answers = make(map[string]*askEntry)
+	}
+	entry, ok := r.shared.asks.answers[question]
+	if !ok {
+		entry = &askEntry{}
+		r.shared.asks.answers[question] = entry
+	}
+	r.shared.asks.mu.Unlock()
+
+	// Shared by every caller waiting on this entry, so one caller's
+	// cancellation must not fail the rest.
+	entry.once.Do(func() {
+		entry.answer = r.answer(context.WithoutCancel(ctx), question)
+	})
+	return entry.answer
+}
+
+func (r *Repository) answer(ctx context.Context, question string) *Answer {
+	sources := r.retrieve(question)
+	a := &Answer{Question: question}
+	for _, n := range sources {
+		a.Sources = append(a.Sources, n.ID)
+	}
+	if len(sources) == 0 {
+		a.Text = "No notes match this question."
+		return a
+	}
+
+	if r.LLM != nil {
+		var prompt strings.Builder
+		for _, n := range sources {
+			content := n.Content
+			if len(content) > askSourceBytes {
+				content = content[:askSourceBytes]
+			}
+			fmt.Fprintf(&prompt, "## [%s] (%s)\n%s\n\n", n.ID, n.Type, content)
+		}
+		fmt.Fprintf(&prompt, "Question: %s", question)
+		text, err := r.LLM.Complete(ctx, askPrompt, prompt.String())
+		if err == nil && strings.TrimSpace(text) != "" {
+			a.Text = strings.TrimSpace(text)
+			a.Generated = true
+			return a
+		}
+		if err != nil {
+			fmt.Printf("memex-fs: ask: %v\n", err)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("These notes best match the question:\n")
+	for _, n := range sources {
+		fmt.Fprintf(&b, "\n- %s (%s)", n.ID, n.Type)
+		if line := firstLine(n.Content); line != "" {
+			b.WriteString(": " + line)
+		}
+	}
+	a.Text = b.String()
+	return a
+}
+
+// retrieve returns the nodes to answer question from: the top search hits
+// for its meaningful terms, each followed by its most related nodes.
+func (r *Repository) retrieve(question string) []*NodeEnvelope {
+	var terms []string
+	for _, t := range tokenize(question) {
+		if !askStopWords[t] {
+			terms = append(terms, t)
+		}
+	}
+
+	var sources []*NodeEnvelope
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if seen[id] || len(sources) == askMaxSources {
+			return
+		}
+		seen[id] = true
+		if node, err := r.GetNode(id); err == nil {
+			sources = append(sources, node)
+		}
+	}
+	hits := r.Search.Search(strings.Join(terms, " "), askSearchHits)
+	for _, id := range hits {
+		add(id)
+	}
+	for _, id := range hits {
+		for _, s := range r.Relatedness.RelatedScored(id, askRelatedPerHit) {
+			add(s.ID)
+		}
+	}
+	return sources
+}
+
+// firstLine returns the first non-blank line of content, shortened for a
+// listing.
+func firstLine(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > 80 {
+			line = string(r[:80]) + "…"
+		}
+		return line
+	}
+	return ""
+}
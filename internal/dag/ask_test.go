@@ -0,0 +1,62 @@
+package dag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// promptLLM records the last prompt and answers with a fixed reply.
+type promptLLM struct {
+	calls  int
+	prompt string
+}
+
+func (l *promptLLM) Complete(ctx context.Context, system, prompt string) (string, error) {
+	l.calls++
+	l.prompt = prompt
+	return "Alice runs the graph project [person:alice].", nil
+}
+
+func TestAsk_RetrievalFallback(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("person:alice", "Person", []byte("Alice leads the graph project\nmore"), nil)
+	repo.CreateNode("note:lunch", "Note", []byte("sandwiches"), nil)
+
+	a := repo.Ask(t.Context(), "who leads the graph project?")
+	if a.Generated || len(a.Sources) != 1 || a.Sources[0] != "person:alice" {
+		t.Fatalf("answer = %+v", a)
+	}
+	if !strings.Contains(a.Text, "person:alice (Person): Alice leads the graph project") {
+		t.Errorf("fallback text = %q", a.Text)
+	}
+	if a := repo.Ask(t.Context(), "what about zebras?"); len(a.Sources) != 0 {
+		t.Errorf("unmatched question has sources %v", a.Sources)
+	}
+}
+
+func TestAsk_GeneratedAndCached(t *testing.T) {
+	repo := openTestRepo(t)
+	llm := &promptLLM{}
+	repo.LLM = llm
+	repo.CreateNode("person:alice", "Person", []byte("Alice leads the graph project"), nil)
+
+	a := repo.Ask(t.Context(), "who leads the graph project?")
+	if !a.Generated || a.Text != "Alice runs the graph project [person:alice]." {
+		t.Fatalf("answer = %+v", a)
+	}
+	if !strings.Contains(llm.prompt, "## [person:alice] (Person)\nAlice leads") ||
+		!strings.HasSuffix(llm.prompt, "Question: who leads the graph project?") {
+		t.Errorf("prompt = %q", llm.prompt)
+	}
+
+	repo.Ask(t.Context(), "who leads the graph project?")
+	if llm.calls != 1 {
+		t.Errorf("%d LLM calls for a repeated question, want 1", llm.calls)
+	}
+	repo.CreateNode("note:b", "Note", nil, nil)
+	repo.Ask(t.Context(), "who leads the graph project?")
+	if llm.calls != 2 {
+		t.Errorf("%d LLM calls after a commit, want 2", llm.calls)
+	}
+}
@@ -0,0 +1,49 @@
+package dag
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LinkHasAttachment is the link type connecting a node to the Source nodes
+// holding its attachments.
+const LinkHasAttachment = "HAS_ATTACHMENT"
+
+// AddAttachment stores data as its own content-addressed Source node and
+// links it from nodeID via HAS_ATTACHMENT. The original filename is kept in
+// the Source node's meta; the format is derived from its extension.
+//
+// Attaching identical bytes twice (to the same or another node) reuses the
+// existing Source node, so attachments dedup across the whole repo.
+func (r *Repository) AddAttachment(nodeID, filename string, data []byte) (string, error) {
+	if _, err := r.GetNode(nodeID); err != nil {
+		return "", err
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if format == "" {
+		format = "binary"
+	}
+	id, _, err := r.ingest(string(data), format, map[string]interface{}{
+		"filename": filename,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ingest attachment: %w", err)
+	}
+	if err := r.CreateLink(nodeID, id, LinkHasAttachment); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Attachments returns the IDs of the Source nodes attached to nodeID, in
+// the order they were attached.
+func (r *Repository) Attachments(nodeID string) []string {
+	var ids []string
+	for _, l := range r.Links.LinksFrom(nodeID) {
+		if l.Type == LinkHasAttachment {
+			ids = append(ids, l.Target)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,62 @@
+package dag
+
+import "testing"
+
+func TestAddAttachment_CreatesLinkedSource(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.CreateNode("note:1", "Note", []byte("see attached"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := repo.AddAttachment("note:1", "Figure.PNG", []byte("\x89PNG fake"))
+	if err != nil {
+		t.Fatalf("AddAttachment: %v", err)
+	}
+
+	src, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatalf("GetNode(%s): %v", id, err)
+	}
+	if src.Type != "Source" {
+		t.Errorf("Type = %q, want Source", src.Type)
+	}
+	if src.Meta["filename"] != "Figure.PNG" {
+		t.Errorf("Meta[filename] = %v, want Figure.PNG", src.Meta["filename"])
+	}
+	if src.Meta["format"] != "png" {
+		t.Errorf("Meta[format] = %v, want png", src.Meta["format"])
+	}
+
+	got := repo.Attachments("note:1")
+	if len(got) != 1 || got[0] != id {
+		t.Errorf("Attachments = %v, want [%s]", got, id)
+	}
+}
+
+func TestAddAttachment_DedupsAcrossNodes(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateNode("note:b", "Note", nil, nil)
+
+	idA, err := repo.AddAttachment("note:a", "a.txt", []byte("same bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := repo.AddAttachment("note:b", "b.txt", []byte("same bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idA != idB {
+		t.Errorf("identical attachments got different IDs: %s vs %s", idA, idB)
+	}
+	if len(repo.Attachments("note:b")) != 1 {
+		t.Errorf("note:b should still list the shared attachment")
+	}
+}
+
+func TestAddAttachment_MissingNode(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.AddAttachment("note:missing", "x.txt", []byte("x")); err == nil {
+		t.Error("expected error attaching to a missing node")
+	}
+}
@@ -0,0 +1,201 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// orphanIdleWindow is how long a linkless node must go unread before the
+// audit reports it as an orphan. Fresh or recently-read notes are simply
+// unfiled, not forgotten.
+const orphanIdleWindow = 30 * 24 * time.Hour
+
+// BrokenLink is a link whose source or target no longer resolves to a
+// node — hard-deleted or never created. A tombstoned node's links are not
+// broken: it can still be restored, and they go when its tombstone
+// expires.
+type BrokenLink struct {
+	LinkEntry
+	Missing string // "source", "target", or "both"
+}
+
+// AuditReport is the result of one integrity pass over the graph.
+type AuditReport struct {
+	At          time.Time
+	BrokenLinks []BrokenLink
+	Orphans     []string // live nodes with no links and no recent access
+}
+
+// IntegrityAudit computes AuditReports and caches the latest one so FUSE
+// reads never pay for a full scan. Start runs it periodically in the
+// background; Report computes one on demand if none exists yet.
+type IntegrityAudit struct {
+	repo *Repository
+
+	mu     sync.Mutex
+	latest *AuditReport
+}
+
+// NewIntegrityAudit creates an audit over repo. Nothing runs until Run,
+// Report, or Start is called.
+func NewIntegrityAudit(repo *Repository) *IntegrityAudit {
+	return &IntegrityAudit{repo: repo}
+}
+
+// Run performs a full pass now and caches the result. A pass cut short
+// by ctx is discarded; the previous report stays cached.
+func (a *IntegrityAudit) Run(ctx context.Context) (*AuditReport, error) {
+	report, err := a.repo.audit(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.latest = report
+	a.mu.Unlock()
+	return report, nil
+}
+
+// Report returns the latest cached report, running a pass first if none
+// has completed yet.
+func (a *IntegrityAudit) Report(ctx context.Context) (*AuditReport, error) {
+	a.mu.Lock()
+	latest := a.latest
+	a.mu.Unlock()
+	if latest != nil {
+		return latest, nil
+	}
+	return a.Run(ctx)
+}
+
+// Start runs the audit every interval until the returned stop function is
+// called. Failures are logged and retried on the next tick. Stopping also
+// cancels a pass that is still in flight.
+func (a *IntegrityAudit) Start(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := a.Run(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("memex-fs: audit warning: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
+
+// audit walks every ref and link once. now anchors the orphan idle window.
+func (r *Repository) audit(ctx context.Context, now time.Time) (*AuditReport, error) {
+	ids, err := r.ListNodes(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		live[id] = true
+	}
+
+	// present reports whether id is live or in the trash.
+	present := func(id string) bool { return live[id] || r.Refs.Has(id) }
+
+	report := &AuditReport{At: now}
+	linked := make(map[string]bool)
+	for _, l := range r.Links.AllEntries() {
+		target := LinkTargetParent(l.Target)
+		linked[l.Source] = true
+		linked[target] = true
+
+		srcOK, dstOK := present(l.Source), present(target)
+		switch {
+		case !srcOK && !dstOK:
+			report.BrokenLinks = append(report.BrokenLinks, BrokenLink{l, "both"})
+		case !srcOK:
+			report.BrokenLinks = append(report.BrokenLinks, BrokenLink{l, "source"})
+		case !dstOK:
+			report.BrokenLinks = append(report.BrokenLinks, BrokenLink{l, "target"})
+		}
+	}
+	sort.Slice(report.BrokenLinks, func(i, j int) bool {
+		a, b := report.BrokenLinks[i], report.BrokenLinks[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if linked[id] {
+			continue
+		}
+		if last, ok := r.Recency.LastAccess(id); ok && now.Sub(last) < orphanIdleWindow {
+			continue
+		}
+		node, err := r.GetNode(id)
+		if err != nil || now.Sub(node.Modified) < orphanIdleWindow {
+			continue
+		}
+		report.Orphans = append(report.Orphans, id)
+	}
+	sort.Strings(report.Orphans)
+	return report, nil
+}
+
+// PruneBrokenLinks runs a fresh audit and removes every link it finds
+// broken, then caches a new one. Returns how many links were removed.
+func (r *Repository) PruneBrokenLinks(ctx context.Context) (int, error) {
+	report, err := r.audit(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, b := range report.BrokenLinks {
+		removed, err := r.Links.Remove(b.LinkEntry)
+		if err != nil {
+			return pruned, err
+		}
+		if removed {
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		r.commit(fmt.Sprintf("prune %d broken links", pruned))
+	}
+	if r.Audit != nil {
+		r.Audit.Run(ctx)
+	}
+	return pruned, nil
+}
+
+// RetargetLink repairs a link by pointing it at newTarget instead, keeping
+// its source and type. newTarget must be a live node (or a block of one).
+func (r *Repository) RetargetLink(l LinkEntry, newTarget string) error {
+	if _, err := r.GetNode(LinkTargetParent(newTarget)); err != nil {
+		return err
+	}
+	removed, err := r.Links.Remove(l)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("link %w: %s -[%s]-> %s", ErrNotFound, l.Source, l.Type, l.Target)
+	}
+	if err := r.Links.Add(LinkEntry{Source: l.Source, Target: newTarget, Type: l.Type}); err != nil {
+		return err
+	}
+	r.commit(fmt.Sprintf("retarget %s -[%s]-> %s (was %s)", l.Source, l.Type, newTarget, l.Target))
+	return nil
+}
@@ -0,0 +1,107 @@
+package dag
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAudit_BrokenLinksAfterHardDelete(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateNode("note:b", "Note", nil, nil)
+	repo.CreateLink("note:a", "note:b", "refs")
+	repo.CreateLink("note:a", "note:never", "refs")
+	repo.CreateNode("note:trashed", "Note", nil, nil)
+	repo.CreateLink("note:trashed", "note:a", "refs")
+	repo.DeleteNode("note:b", true)
+	repo.DeleteNode("note:trashed", false)
+
+	report, err := repo.Audit.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.BrokenLinks) != 2 {
+		t.Fatalf("BrokenLinks = %v, want 2", report.BrokenLinks)
+	}
+	for _, b := range report.BrokenLinks {
+		if b.Missing != "target" {
+			t.Errorf("%v: Missing = %q, want target", b.LinkEntry, b.Missing)
+		}
+	}
+
+	n, err := repo.PruneBrokenLinks(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("PruneBrokenLinks = %d, %v; want 2", n, err)
+	}
+	if got := repo.Links.LinksFrom("note:a"); len(got) != 0 {
+		t.Errorf("links after prune = %v, want none", got)
+	}
+	if got := repo.Links.LinksFrom("note:trashed"); len(got) != 1 {
+		t.Errorf("links of a tombstoned node after prune = %v, want its one", got)
+	}
+	report, _ = repo.Audit.Report(context.Background())
+	if len(report.BrokenLinks) != 0 {
+		t.Errorf("cached report after prune still has %d broken links", len(report.BrokenLinks))
+	}
+}
+
+func TestAudit_Orphans(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:linked", "Note", nil, nil)
+	repo.CreateNode("note:peer", "Note", nil, nil)
+	repo.CreateNode("note:alone", "Note", nil, nil)
+	repo.CreateNode("note:read", "Note", nil, nil)
+	repo.CreateLink("note:linked", "note:peer", "refs")
+
+	// Fresh nodes are never orphans.
+	report, err := repo.audit(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 0 {
+		t.Errorf("fresh Orphans = %v, want none", report.Orphans)
+	}
+
+	later := time.Now().Add(orphanIdleWindow + time.Hour)
+	repo.Recency.RecordAccess("note:read", later.Add(-time.Hour))
+	report, err = repo.audit(context.Background(), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0] != "note:alone" {
+		t.Errorf("Orphans = %v, want [note:alone]", report.Orphans)
+	}
+}
+
+func TestLinkIndex_RemoveSurvivesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.jsonl")
+	idx, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := LinkEntry{Source: "a", Target: "b", Type: "refs"}
+	gone := LinkEntry{Source: "a", Target: "c#b2", Type: "cites"}
+	idx.Add(keep)
+	idx.Add(gone)
+
+	removed, err := idx.Remove(gone)
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v", removed, err)
+	}
+	if removed, _ := idx.Remove(gone); removed {
+		t.Error("second Remove should report missing")
+	}
+
+	reloaded, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.LinksFrom("a"); len(got) != 1 || got[0] != keep {
+		t.Errorf("LinksFrom after reload = %v, want [%v]", got, keep)
+	}
+	if got := reloaded.LinksTo("c"); len(got) != 0 {
+		t.Errorf("LinksTo(c) after reload = %v, want none", got)
+	}
+}
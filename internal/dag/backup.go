@@ -0,0 +1,167 @@
+package dag
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix and backupSuffix bracket the timestamp in a backup's name.
+const (
+	backupPrefix = "memex-"
+	backupSuffix = ".tar.gz"
+)
+
+// Backup archives the repository's .mx directory as a gzipped tar in
+// Config.Backup.Dir, named for now, and removes all but the newest
+// Config.Backup.Keep archives there. It runs while the repository is in
+// use: everything else is archived before the object store, so every ref
+// and commit in the archive names objects that are in it too. Returns the
+// archive's path.
+func (r *Repository) Backup(ctx context.Context, now time.Time) (string, error) {
+	cfg := r.Config.Backup
+	if cfg == nil || cfg.Dir == "" {
+		return "", errorOf(ErrValidation, "no backup directory configured")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	path := filepath.Join(cfg.Dir, backupPrefix+now.UTC().Format("20060102T150405Z")+backupSuffix)
+	tmp := path + ".tmp"
+	if err := writeBackup(ctx, r.MxDir(), tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, pruneBackups(cfg.Dir, cfg.Keep)
+}
+
+// writeBackup archives the .mx directory mx as the gzipped tar path.
+func writeBackup(ctx context.Context, mx, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	entries, err := os.ReadDir(mx)
+	if err != nil {
+		return err
+	}
+	var objects []string
+	for _, e := range entries {
+		if e.Name() == "search-spill" {
+			continue // rebuilt on open
+		}
+		if e.Name() == "objects" {
+			objects = append(objects, filepath.Join(mx, e.Name()))
+			continue
+		}
+		if err := addToTar(ctx, tw, mx, filepath.Join(mx, e.Name())); err != nil {
+			return err
+		}
+	}
+	for _, dir := range objects {
+		if err := addToTar(ctx, tw, mx, dir); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// addToTar writes root, and everything under it, to tw under ".mx/" and
+// its path relative to base. A file removed mid-walk is skipped.
+func addToTar(ctx context.Context, tw *tar.Writer, base, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(".mx", rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		src, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = io.CopyN(tw, src, hdr.Size)
+		return err
+	})
+}
+
+// pruneBackups removes all but the newest keep archives in dir. keep 0
+// keeps them all.
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names) // timestamps sort chronologically
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
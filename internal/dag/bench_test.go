@@ -0,0 +1,144 @@
+package dag_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+	"github.com/systemshift/memex-fs/internal/dag/testutil"
+)
+
+// benchSizes are the repo sizes benchmarks run at. The 100k repo takes a
+// while to seed, so -short skips it.
+func benchSizes(b *testing.B) []int {
+	if testing.Short() {
+		return []int{10_000}
+	}
+	return []int{10_000, 100_000}
+}
+
+// seeded caches seeded repo dirs by size so each size is built once per
+// run; TestMain removes them.
+var seeded struct {
+	sync.Mutex
+	dirs map[int]string
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	for _, dir := range seeded.dirs {
+		os.RemoveAll(dir)
+	}
+	os.Exit(code)
+}
+
+// seedRepo returns the path of an n-node repo with n links, building it on
+// first use.
+func seedRepo(b *testing.B, n int) string {
+	b.Helper()
+	seeded.Lock()
+	defer seeded.Unlock()
+	if dir, ok := seeded.dirs[n]; ok {
+		return dir
+	}
+	dir, err := os.MkdirTemp("", "memex-bench-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if seeded.dirs == nil {
+		seeded.dirs = make(map[int]string)
+	}
+	seeded.dirs[n] = dir
+
+	if err := testutil.Generate(dir, testutil.Spec{Seed: 1, Nodes: n, Links: n}); err != nil {
+		b.Fatal(err)
+	}
+	return dir
+}
+
+func openBenchRepo(b *testing.B, dir string) *dag.Repository {
+	b.Helper()
+	repo, err := dag.OpenRepository(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return repo
+}
+
+func BenchmarkOpenRepository(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		dir := seedRepo(b, n)
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				openBenchRepo(b, dir)
+			}
+		})
+	}
+}
+
+func BenchmarkGetNode(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		repo := openBenchRepo(b, seedRepo(b, n))
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetNode(testutil.NodeID(i % n)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListNodes(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		repo := openBenchRepo(b, seedRepo(b, n))
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.ListNodes(context.Background(), 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		repo := openBenchRepo(b, seedRepo(b, n))
+		b.Run(fmt.Sprintf("ids/nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				repo.Search.Search("alpha bravo", 100)
+			}
+		})
+		b.Run(fmt.Sprintf("first/nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for range repo.SearchIter(context.Background(), "alpha bravo", 0) {
+					break
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("nodes/nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.SearchNodes(context.Background(), "alpha bravo", 100); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCommit(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		repo := openBenchRepo(b, seedRepo(b, n))
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Commits.Commit(repo.Refs, repo.Links, "bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
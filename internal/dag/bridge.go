@@ -0,0 +1,267 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBridgeFileBytes bounds the files a Bridge mirrors; larger ones
+	// are skipped.
+	maxBridgeFileBytes = 16 << 20
+	// bridgeSettle is how long a Bridge waits after a change event for
+	// more to arrive, so a burst (a checkout, a build) syncs once.
+	bridgeSettle = 300 * time.Millisecond
+)
+
+// BridgeNodeID returns the ID of the Source node mirroring the file at
+// the absolute path.
+func BridgeNodeID(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return "file:" + hex.EncodeToString(sum[:8])
+}
+
+// BridgeStats counts what a Bridge sync did.
+type BridgeStats struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+// fileStamp is what a Bridge remembers of a file to tell it changed
+// without reading it.
+type fileStamp struct {
+	size  int64
+	mtime time.Time
+}
+
+// Bridge mirrors real directories into the repository: each regular file
+// is a Source node (ID from BridgeNodeID, "path" and "filename" in meta)
+// whose content follows the file, one version per change seen. Removing
+// the file deletes the node; a file created again later starts a new
+// history. Hidden files and directories, such as .git, are skipped, as
+// are files over 16 MiB.
+type Bridge struct {
+	repo *Repository
+	dirs []string
+
+	mu    sync.Mutex
+	known map[string]fileStamp // path -> stamp when last mirrored
+}
+
+// NewBridge creates a Bridge over dirs, which are made absolute.
+func (r *Repository) NewBridge(dirs []string) (*Bridge, error) {
+	b := &Bridge{repo: r, known: make(map[string]fileStamp)}
+	for _, d := range dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", abs)
+		}
+		b.dirs = append(b.dirs, abs)
+	}
+	// Files mirrored by an earlier run are checked on the first sync, so
+	// ones removed in the meantime are deleted too.
+	for _, id := range r.Search.FilterByType("Source", 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		if path, _ := node.Meta["path"].(string); b.covers(path) && id == BridgeNodeID(path) {
+			b.known[path] = fileStamp{}
+		}
+	}
+	return b, nil
+}
+
+// covers reports whether path lies in one of b's directories.
+func (b *Bridge) covers(path string) bool {
+	for _, d := range b.dirs {
+		if strings.HasPrefix(path, d+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync brings the mirror up to date with the directories: new files are
+// created together in one commit, and changed and removed ones are
+// updated and deleted.
+func (b *Bridge) Sync(ctx context.Context) (BridgeStats, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.repo
+	var stats BridgeStats
+
+	present := make(map[string]bool)
+	var created []*NodeEnvelope
+	var walkErr error
+	for _, dir := range b.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil && path == dir {
+				// Without the directory itself (an unmounted drive, say)
+				// nothing can be told about its files, so none is deleted.
+				return err
+			}
+			if err != nil {
+				return nil // unreadable entries are skipped, not fatal
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil || fi.Size() > maxBridgeFileBytes {
+				return nil
+			}
+			present[path] = true
+			stamp := fileStamp{size: fi.Size(), mtime: fi.ModTime()}
+			if b.known[path] == stamp {
+				return nil
+			}
+			node, changed, err := b.mirror(path)
+			if err != nil {
+				fmt.Printf("memex-fs: bridge %s: %v\n", path, err)
+				return nil
+			}
+			b.known[path] = stamp
+			switch {
+			case node != nil:
+				created = append(created, node)
+				stats.Created++
+			case changed:
+				stats.Updated++
+			}
+			return nil
+		})
+		if err != nil {
+			walkErr = err
+			break
+		}
+	}
+	if len(created) > 0 {
+		r.commit(fmt.Sprintf("bridge: add %d files", len(created)))
+		for _, node := range created {
+			r.afterCreate(node)
+		}
+	}
+	if walkErr != nil {
+		return stats, walkErr
+	}
+
+	for path := range b.known {
+		if present[path] {
+			continue
+		}
+		delete(b.known, path)
+		if err := r.DeleteNode(BridgeNodeID(path), false); err != nil {
+			fmt.Printf("memex-fs: bridge %s: %v\n", path, err)
+			continue
+		}
+		stats.Deleted++
+	}
+	return stats, nil
+}
+
+// mirror brings path's node up to date with the file. A new node is
+// stored uncommitted and returned; an existing one is updated (and
+// committed) when its content differs, reported by changed.
+func (b *Bridge) mirror(path string) (created *NodeEnvelope, changed bool, err error) {
+	r := b.repo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	id := BridgeNodeID(path)
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	if cur, err := r.getNodeEnvelope(id); err == nil && !cur.Deleted {
+		if bytes.Equal(cur.Content, data) {
+			return nil, false, nil
+		}
+		_, err := r.updateContent(id, data, map[string]interface{}{"size_bytes": len(data)})
+		return nil, err == nil, err
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format == "" {
+		format = "binary"
+	}
+	node, err := r.storeNewNode(id, "Source", data, map[string]interface{}{
+		"path":       path,
+		"filename":   filepath.Base(path),
+		"format":     format,
+		"size_bytes": len(data),
+	})
+	return node, false, err
+}
+
+// StartBridge mirrors dirs until the returned stop function is called:
+// it syncs at once, then whenever the directories change (on Linux,
+// through inotify) and at least every interval. Sync failures are logged
+// and retried. Stopping waits for a sync in flight to commit what it has.
+func (r *Repository) StartBridge(dirs []string, interval time.Duration) (stop func(), err error) {
+	b, err := r.NewBridge(dirs)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := watchDirs(ctx, b.dirs)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := b.Sync(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("memex-fs: bridge warning: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-events:
+				// Let a burst of changes settle, then sync once.
+				settle := time.NewTimer(bridgeSettle)
+			drain:
+				for {
+					select {
+					case <-events:
+					case <-settle.C:
+						break drain
+					case <-ctx.Done():
+						settle.Stop()
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
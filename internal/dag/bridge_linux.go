@@ -0,0 +1,78 @@
+package dag
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const bridgeWatchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+// watchDirs watches dirs and their non-hidden subdirectories with
+// inotify, sending on the returned channel whenever something in them
+// changes. New subdirectories are watched as they appear. Returns nil,
+// leaving the caller to poll, if inotify is unavailable.
+func watchDirs(ctx context.Context, dirs []string) <-chan struct{} {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil
+	}
+	// A non-blocking descriptor goes through the runtime poller, so
+	// closing the file interrupts a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	watched := make(map[int32]string) // watch descriptor -> directory
+	addTree := func(root string) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if wd, err := syscall.InotifyAddWatch(fd, path, bridgeWatchMask); err == nil {
+				watched[int32(wd)] = path
+			}
+			return nil
+		})
+	}
+	for _, d := range dirs {
+		addTree(d)
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 64<<10)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			// Watch directories created since, before they fill up.
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				end := off + syscall.SizeofInotifyEvent + int(ev.Len)
+				if ev.Mask&syscall.IN_ISDIR != 0 && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					name := strings.TrimRight(string(buf[off+syscall.SizeofInotifyEvent:end]), "\x00")
+					if parent, ok := watched[ev.Wd]; ok {
+						addTree(filepath.Join(parent, name))
+					}
+				}
+				off = end
+			}
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return events
+}
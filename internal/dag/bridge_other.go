@@ -0,0 +1,10 @@
+//go:build !linux
+
+package dag
+
+import "context"
+
+// watchDirs returns nil: without inotify, a Bridge polls.
+func watchDirs(ctx context.Context, dirs []string) <-chan struct{} {
+	return nil
+}
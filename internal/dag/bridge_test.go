@@ -0,0 +1,93 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBridge_Sync(t *testing.T) {
+	repo := openTestRepo(t)
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("notes/plan.md", "first draft")
+	write("readme.txt", "hello")
+	write(".git/HEAD", "ref: main")
+
+	b, err := repo.NewBridge([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := b.Sync(t.Context())
+	if err != nil || stats != (BridgeStats{Created: 2}) {
+		t.Fatalf("first sync = %+v, %v", stats, err)
+	}
+	plan := filepath.Join(dir, "notes", "plan.md")
+	id := BridgeNodeID(plan)
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != "Source" || node.Meta["path"] != plan || node.Meta["format"] != "md" || string(node.Content) != "first draft" {
+		t.Errorf("node = %s %v %q", node.Type, node.Meta, node.Content)
+	}
+	if repo.Refs.Has(BridgeNodeID(filepath.Join(dir, ".git", "HEAD"))) {
+		t.Error("mirrored a file in a hidden directory")
+	}
+
+	if stats, _ := b.Sync(t.Context()); stats != (BridgeStats{}) {
+		t.Errorf("unchanged sync = %+v", stats)
+	}
+
+	write("notes/plan.md", "second draft, longer")
+	os.Remove(filepath.Join(dir, "readme.txt"))
+	stats, err = b.Sync(t.Context())
+	if err != nil || stats != (BridgeStats{Updated: 1, Deleted: 1}) {
+		t.Fatalf("change sync = %+v, %v", stats, err)
+	}
+	node, _ = repo.GetNode(id)
+	if string(node.Content) != "second draft, longer" || node.Prev == "" || node.Meta["size_bytes"] != float64(20) {
+		t.Errorf("updated node = %q prev=%q meta=%v", node.Content, node.Prev, node.Meta)
+	}
+	if _, err := repo.GetNode(BridgeNodeID(filepath.Join(dir, "readme.txt"))); err == nil {
+		t.Error("removed file's node is still live")
+	}
+
+	// A new bridge (a restart) deletes files removed while it was down.
+	os.Remove(plan)
+	b, _ = repo.NewBridge([]string{dir})
+	if stats, _ := b.Sync(t.Context()); stats != (BridgeStats{Deleted: 1}) {
+		t.Errorf("sync after restart = %+v", stats)
+	}
+}
+
+func TestStartBridge_WatchesChanges(t *testing.T) {
+	repo := openTestRepo(t)
+	dir := t.TempDir()
+	stop, err := repo.StartBridge([]string{dir}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	time.Sleep(50 * time.Millisecond)
+	path := filepath.Join(dir, "sub", "new.txt")
+	os.WriteFile(path, []byte("appeared"), 0644)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !repo.Refs.Has(BridgeNodeID(path)) {
+		if time.Now().After(deadline) {
+			t.Skip("no change notification; inotify may be unavailable here")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,291 @@
+package dag
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// BundleVersion is the bundle format version.
+const BundleVersion = 1
+
+// Bundle is a single node packaged for another memex: every stored version
+// of it, newest first, plus its outgoing links, signed by the exporting
+// identity. Versions are the stored envelopes verbatim, so an import
+// reproduces the exporter's CIDs.
+type Bundle struct {
+	V         int               `json:"v"`
+	ID        string            `json:"id"`
+	Versions  []json.RawMessage `json:"versions"`
+	Links     []LinkEntry       `json:"links,omitempty"`
+	Blobs     map[string][]byte `json:"blobs,omitempty"`     // content blocks DAG-JSON versions link to, by CID
+	Signer    string            `json:"signer"`              // DID of the exporter
+	Signature string            `json:"signature,omitempty"` // base64 Ed25519 over the bundle without this field
+}
+
+// signingPayload is the canonical encoding of b with its signature
+// cleared.
+func (b *Bundle) signingPayload() ([]byte, error) {
+	unsigned := *b
+	unsigned.Signature = ""
+	return CanonicalJSON(&unsigned)
+}
+
+// ExportBundle packages id as a Bundle signed by the local identity. The
+// version chain stops at the first version no longer in the store.
+func (r *Repository) ExportBundle(id string) (*Bundle, error) {
+	if r.identity == nil {
+		return nil, errors.New("no identity to sign with")
+	}
+	if _, err := r.GetNode(id); err != nil {
+		return nil, err
+	}
+	c, err := r.Refs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{V: BundleVersion, ID: id, Links: r.Links.LinksFrom(id), Signer: r.identity.DID}
+	for {
+		data, err := r.Store.Get(c)
+		if err != nil {
+			if len(b.Versions) == 0 {
+				return nil, err
+			}
+			break
+		}
+		b.Versions = append(b.Versions, data)
+		if bc, ok := ContentLink(data); ok {
+			blob, err := r.Store.Get(bc)
+			if err != nil {
+				return nil, fmt.Errorf("read content: %w", err)
+			}
+			if b.Blobs == nil {
+				b.Blobs = make(map[string][]byte)
+			}
+			b.Blobs[CIDToFilename(bc)] = blob
+		}
+		v, err := DecodeNode(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if v.Prev == "" {
+			break
+		}
+		if c, err = cidFromFilename(v.Prev); err != nil {
+			break
+		}
+	}
+
+	key, err := r.identity.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := b.signingPayload()
+	if err != nil {
+		return nil, err
+	}
+	b.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return b, nil
+}
+
+// Verify checks b's signature against its signer's DID and that its
+// versions form one chain of the node it names, each pointing at the next
+// by CID. Returns the current version.
+func (b *Bundle) Verify() (*NodeEnvelope, error) {
+	if b.V != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.V)
+	}
+	pub, err := DecodeDIDKey(b.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("bundle signer: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(b.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("decode bundle signature: %w", err)
+	}
+	payload, err := b.signingPayload()
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(pub, payload, sig) {
+		return nil, fmt.Errorf("bundle signature does not match %s", b.Signer)
+	}
+
+	if err := ValidateID(b.ID); err != nil {
+		return nil, err
+	}
+	if len(b.Versions) == 0 {
+		return nil, errors.New("bundle has no versions")
+	}
+	var head *NodeEnvelope
+	expect := "" // CID the previous (newer) version points at
+	for i, raw := range b.Versions {
+		data, err := CanonicalJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", i, err)
+		}
+		if i > 0 {
+			c, err := cidFromFilename(expect)
+			if err != nil || !CIDMatches(c, data) {
+				return nil, fmt.Errorf("version %d is not the prev of version %d", i, i-1)
+			}
+		}
+		v, err := DecodeNode(data, b.blob)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", i, err)
+		}
+		if v.ID != b.ID {
+			return nil, fmt.Errorf("version %d is of %s, not %s", i, v.ID, b.ID)
+		}
+		if i == 0 {
+			head = v
+		}
+		expect = v.Prev
+	}
+	if head.Deleted {
+		return nil, fmt.Errorf("bundle of deleted node %s", b.ID)
+	}
+	for _, l := range b.Links {
+		if l.Source != b.ID {
+			return nil, fmt.Errorf("bundle link from %s, not %s", l.Source, b.ID)
+		}
+	}
+	return head, nil
+}
+
+// blob returns the content block c from b, checked against its CID.
+func (b *Bundle) blob(c gocid.Cid) ([]byte, error) {
+	data, ok := b.Blobs[CIDToFilename(c)]
+	if !ok || !CIDMatches(c, data) {
+		return nil, fmt.Errorf("bundle lacks content block %s", CIDToFilename(c))
+	}
+	return data, nil
+}
+
+// putVersionOf stores data under the CID the newer version names as its
+// prev.
+func (r *Repository) putVersionOf(newer json.RawMessage, data []byte) error {
+	v, err := DecodeNode(newer, nil)
+	if err != nil {
+		return err
+	}
+	c, err := cidFromFilename(v.Prev)
+	if err != nil {
+		return err
+	}
+	return r.Store.PutCID(c, data)
+}
+
+// ImportOptions narrows what ImportBundleWith keeps of a bundle. The zero
+// value keeps everything.
+type ImportOptions struct {
+	// Depth keeps only the newest Depth versions; the oldest one kept
+	// still names its prev, which is read on demand if the store has a
+	// fetcher. 0 keeps every version.
+	Depth int
+}
+
+// checkBundleACL refuses a bundle whose PART_OF links would put its node
+// into a subtree whose ACL its signer may not write. The node's own ACL,
+// which the signer chose, grants nothing here.
+func (r *Repository) checkBundleACL(b *Bundle) error {
+	acl, governor := resolveACL(b.ID, func(id string) *NodeEnvelope {
+		if id == b.ID {
+			return nil
+		}
+		node, _ := r.GetNode(id)
+		return node
+	}, func(id string) []string {
+		if id == b.ID {
+			return partOfTargets(b.Links, id)
+		}
+		return partOfTargets(r.Links.LinksFrom(id), id)
+	})
+	if !acl.CanWrite(b.Signer) {
+		return fmt.Errorf("%s may not write under %s: %w", b.Signer, governor, ErrAccessDenied)
+	}
+	return nil
+}
+
+// ImportBundle verifies b and adds its node, with its version history and
+// outgoing links, as a new node. A live node with the same ID is never
+// overwritten.
+func (r *Repository) ImportBundle(b *Bundle) (*NodeEnvelope, error) {
+	return r.ImportBundleWith(b, ImportOptions{})
+}
+
+// ImportBundleWith is ImportBundle narrowed by opts. The whole bundle is
+// verified either way.
+func (r *Repository) ImportBundleWith(b *Bundle, opts ImportOptions) (*NodeEnvelope, error) {
+	head, err := b.Verify()
+	if err != nil {
+		return nil, err
+	}
+	unlock := r.lockNode(b.ID)
+	defer unlock()
+
+	if _, err := r.GetNode(b.ID); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, b.ID)
+	}
+	if err := r.checkBundleACL(b); err != nil {
+		return nil, err
+	}
+	if err := r.EditLocks.Check(b.ID); err != nil {
+		return nil, err
+	}
+	if err := r.Hooks.preWrite(HookCreate, head); err != nil {
+		return nil, err
+	}
+
+	// Versions oldest first, each after its content block, so a failure
+	// part way leaves no dangling link. Older versions keep the CIDs their
+	// successors name, whatever the exporter's object format; the head is
+	// stored in ours.
+	keep := len(b.Versions)
+	if opts.Depth > 0 {
+		keep = min(keep, opts.Depth)
+	}
+	for i := keep - 1; i > 0; i-- {
+		data, err := CanonicalJSON(b.Versions[i])
+		if err != nil {
+			return nil, err
+		}
+		if bc, ok := ContentLink(data); ok {
+			blob, err := b.blob(bc)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.Store.PutCID(bc, blob); err != nil {
+				return nil, fmt.Errorf("store content: %w", err)
+			}
+		}
+		if err := r.putVersionOf(b.Versions[i-1], data); err != nil {
+			return nil, fmt.Errorf("store object: %w", err)
+		}
+	}
+	c, err := r.Store.PutNode(head)
+	if err != nil {
+		return nil, fmt.Errorf("store object: %w", err)
+	}
+	if err := r.Refs.Set(b.ID, c); err != nil {
+		return nil, fmt.Errorf("set ref: %w", err)
+	}
+	for _, l := range b.Links {
+		if err := r.Links.Add(l); err != nil {
+			return nil, err
+		}
+	}
+
+	r.indexNode(b.ID, head)
+	r.commit(fmt.Sprintf("import %s from %s", b.ID, b.Signer))
+	r.Hooks.postWrite(HookCreate, head)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeCreated, ID: b.ID, Node: head})
+	r.Summarizer.onWrite(head)
+	r.Recognizer.onWrite(head)
+	r.Transcriber.onWrite(head)
+	return head, nil
+}
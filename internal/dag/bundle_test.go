@@ -0,0 +1,85 @@
+package dag
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBundle_ExportImportRoundTrip(t *testing.T) {
+	src := openTestRepo(t)
+	src.identity = testIdentity(t)
+	src.CreateNode("note:a", "Note", []byte("first"), map[string]interface{}{"tag": "x"})
+	src.UpdateContent("note:a", []byte("second"))
+	src.CreateLink("note:a", "note:b", "cites")
+
+	b, err := src.ExportBundle("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Versions) != 2 || len(b.Links) != 1 || b.Signer != testDID {
+		t.Fatalf("bundle = %d versions, %d links, signer %s", len(b.Versions), len(b.Links), b.Signer)
+	}
+
+	// Through JSON, as it would travel.
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Bundle
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestRepo(t)
+	node, err := dst.ImportBundle(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(node.Content) != "second" {
+		t.Errorf("content = %q", node.Content)
+	}
+	srcCID, _ := src.Refs.Get("note:a")
+	dstCID, _ := dst.Refs.Get("note:a")
+	if srcCID != dstCID {
+		t.Errorf("imported CID %s, want %s", dstCID, srcCID)
+	}
+	if links := dst.Links.LinksFrom("note:a"); len(links) != 1 || links[0].Target != "note:b" {
+		t.Errorf("links = %+v", links)
+	}
+	if p, _ := dst.Provenance("note:a"); p.Origin != "imported from a bundle signed by "+testDID {
+		t.Errorf("origin = %q", p.Origin)
+	}
+
+	if _, err := dst.ImportBundle(&got); err == nil {
+		t.Error("import over a live node should fail")
+	}
+}
+
+func TestBundle_VerifyRejectsTampering(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.identity = testIdentity(t)
+	repo.CreateNode("note:a", "Note", []byte("first"), nil)
+	repo.UpdateContent("note:a", []byte("second"))
+
+	for name, tamper := range map[string]func(b *Bundle){
+		"version": func(b *Bundle) {
+			b.Versions[0] = json.RawMessage(strings.Replace(string(b.Versions[0]), `"Note"`, `"Task"`, 1))
+		},
+		"signer": func(b *Bundle) { b.Signer = "did:key:z6MkExample" },
+		"id":     func(b *Bundle) { b.ID = "note:other" },
+		"link":   func(b *Bundle) { b.Links = append(b.Links, LinkEntry{Source: "note:a", Target: "x", Type: "t"}) },
+	} {
+		b, err := repo.ExportBundle("note:a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Verify(); err != nil {
+			t.Fatalf("untampered bundle: %v", err)
+		}
+		tamper(b)
+		if _, err := b.Verify(); err == nil {
+			t.Errorf("%s: tampered bundle verified", name)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package dag
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar import creates an Event node per ICS event, linked by
+// ATTENDED_BY to the Person nodes whose email matches an attendee.
+const (
+	EventType      = "Event"
+	PersonType     = "Person"
+	LinkAttendedBy = "ATTENDED_BY"
+)
+
+// Event meta keys.
+const (
+	eventStartKey = "start"
+	eventEndKey   = "end"
+)
+
+// ICSEvent is one VEVENT read from an iCalendar file.
+type ICSEvent struct {
+	UID          string
+	RecurrenceID string // set on an overridden occurrence of a recurring event
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time // exclusive; for all-day events, midnight after the last day
+	AllDay       bool
+	Status       string // lower-cased STATUS, e.g. "confirmed", "cancelled"
+	RRule        string // recurrence rule, kept as written; not expanded
+	Organizer    string // lower-cased email
+	Attendees    []string
+}
+
+// ParseICS reads the events of an iCalendar (RFC 5545) file. Times with a
+// TZID are read in that zone, falling back to local time when the zone is
+// unknown; floating times are local.
+func ParseICS(r io.Reader) ([]ICSEvent, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, fmt.Errorf("read ics: %w", err)
+	}
+	var events []ICSEvent
+	var cur *ICSEvent
+	var duration string
+	depth := 0 // nesting inside the current VEVENT, e.g. VALARM
+	for _, line := range lines {
+		name, params, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			cur, duration, depth = &ICSEvent{}, "", 0
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && depth > 0:
+			depth--
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if err := finishICSEvent(cur, duration); err != nil {
+				return nil, err
+			}
+			events = append(events, *cur)
+			cur = nil
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "RECURRENCE-ID":
+			cur.RecurrenceID = value
+		case "SUMMARY":
+			cur.Summary = unescapeICS(value)
+		case "DESCRIPTION":
+			cur.Description = unescapeICS(value)
+		case "LOCATION":
+			cur.Location = unescapeICS(value)
+		case "STATUS":
+			cur.Status = strings.ToLower(value)
+		case "RRULE":
+			cur.RRule = value
+		case "DTSTART":
+			cur.Start, cur.AllDay, err = parseICSTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("ics event %q: DTSTART: %w", cur.UID, err)
+			}
+		case "DTEND":
+			cur.End, _, err = parseICSTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("ics event %q: DTEND: %w", cur.UID, err)
+			}
+		case "DURATION":
+			duration = value
+		case "ORGANIZER":
+			cur.Organizer = icsEmail(value)
+		case "ATTENDEE":
+			if email := icsEmail(value); email != "" {
+				cur.Attendees = append(cur.Attendees, email)
+			}
+		}
+	}
+	return events, nil
+}
+
+// finishICSEvent checks e and fills in its end from duration, or from
+// the start when neither DTEND nor DURATION was given.
+func finishICSEvent(e *ICSEvent, duration string) error {
+	if e.Start.IsZero() {
+		return fmt.Errorf("ics event %q has no DTSTART", e.UID)
+	}
+	if e.End.IsZero() && duration != "" {
+		d, err := parseICSDuration(duration)
+		if err != nil {
+			return fmt.Errorf("ics event %q: DURATION: %w", e.UID, err)
+		}
+		e.End = e.Start.Add(d)
+	}
+	if e.End.IsZero() {
+		// RFC 5545: an all-day event without an end lasts its day, a
+		// timed one takes no time.
+		e.End = e.Start
+		if e.AllDay {
+			e.End = e.Start.AddDate(0, 0, 1)
+		}
+	}
+	return nil
+}
+
+// unfoldICS splits r into content lines, joining folded continuations
+// (lines starting with a space or tab) onto the line before.
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// splitICSLine splits a content line into its upper-cased name, its
+// parameters (names upper-cased, quotes removed) and its value.
+func splitICSLine(line string) (name string, params map[string]string, value string) {
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	head := strings.Split(line[:colon], ";")
+	params = make(map[string]string)
+	for _, p := range head[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(head[0]), params, line[colon+1:]
+}
+
+// unescapeICS undoes the backslash escapes of an ICS TEXT value.
+func unescapeICS(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// parseICSTime reads a DATE or DATE-TIME value, reporting whether it was
+// a DATE (an all-day event).
+func parseICSTime(value string, params map[string]string) (time.Time, bool, error) {
+	loc := time.Local
+	if tz := params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tz, "/")); err == nil {
+			loc = l
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICSDuration reads an ICS DURATION such as "PT1H30M" or "P1D".
+func parseICSDuration(s string) (time.Duration, error) {
+	m := icsDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("bad duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// icsEmail returns the lower-cased address of a "mailto:" CAL-ADDRESS, or
+// "" if it is not one.
+func icsEmail(value string) string {
+	if len(value) < 7 || !strings.EqualFold(value[:7], "mailto:") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(value[7:]))
+}
+
+// eventNodeID derives an Event node's ID from the event's UID (and, for
+// an overridden occurrence, its RECURRENCE-ID), so importing a calendar
+// again finds the events imported before.
+func eventNodeID(e ICSEvent) string {
+	key := e.UID + "\x00" + e.RecurrenceID
+	if e.UID == "" {
+		key = "\x00" + e.Start.Format(time.RFC3339) + "\x00" + e.Summary
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "event:" + hex.EncodeToString(sum[:8])
+}
+
+// eventMeta returns the meta an Event node records for e. Timed events
+// keep RFC3339 start and end times; all-day ones keep YYYY-MM-DD days,
+// the end being the last day of the event.
+func eventMeta(e ICSEvent) map[string]interface{} {
+	meta := map[string]interface{}{"title": e.Summary}
+	if e.AllDay {
+		meta[eventStartKey] = e.Start.Format(DayLayout)
+		end := e.End.AddDate(0, 0, -1)
+		if end.Before(e.Start) {
+			end = e.Start
+		}
+		meta[eventEndKey] = end.Format(DayLayout)
+		meta["all_day"] = true
+	} else {
+		meta[eventStartKey] = e.Start.Format(time.RFC3339)
+		meta[eventEndKey] = e.End.Format(time.RFC3339)
+	}
+	meta[journalDateKey] = meta[eventStartKey]
+	for k, v := range map[string]string{
+		"uid": e.UID, "location": e.Location, "status": e.Status,
+		"rrule": e.RRule, "organizer": e.Organizer,
+	} {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	if len(e.Attendees) > 0 {
+		attendees := make([]interface{}, len(e.Attendees))
+		for i, a := range e.Attendees {
+			attendees[i] = a
+		}
+		meta["attendees"] = attendees
+	}
+	return meta
+}
+
+// eventMetaKeys are the meta keys eventMeta may set; a re-import clears
+// those an event no longer has.
+var eventMetaKeys = []string{"title", eventStartKey, eventEndKey, journalDateKey, "all_day",
+	"uid", "location", "status", "rrule", "organizer", "attendees"}
+
+// personsByEmail maps each lower-cased address in a live Person node's
+// "email" or "emails" meta to the node's ID.
+func (r *Repository) personsByEmail() map[string][]string {
+	byEmail := make(map[string][]string)
+	for _, id := range r.Search.FilterByType(PersonType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		for _, key := range []string{"email", "emails"} {
+			for _, email := range metaStrings(node.Meta[key]) {
+				email = strings.ToLower(strings.TrimSpace(email))
+				if email != "" {
+					byEmail[email] = append(byEmail[email], id)
+				}
+			}
+		}
+	}
+	return byEmail
+}
+
+// metaStrings returns a meta value that is a string or a list of strings
+// as a slice.
+func metaStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var out []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// ImportEvents creates an Event node for each event not imported before,
+// with the description as content and its times, title, location and
+// attendees in meta ("date", the start, files it in the journal). Events
+// imported before are updated when the calendar changed them. Every
+// event is linked ATTENDED_BY to the Person nodes matching its attendees
+// and organizer by email, so people added since an earlier import are
+// linked on the next. New events and links are committed at once; if ctx
+// is cancelled midway, what was imported so far is committed and ctx's
+// error returned.
+func (r *Repository) ImportEvents(ctx context.Context, events []ICSEvent) (ImportStats, error) {
+	var stats ImportStats
+	persons := r.personsByEmail()
+	var created []*NodeEnvelope
+	var links []LinkEntry
+	var err error
+	for _, e := range events {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		id := eventNodeID(e)
+		meta := eventMeta(e)
+		content := []byte(e.Description)
+
+		unlock := r.lockNode(id)
+		var node *NodeEnvelope
+		live := true
+		if cur, gerr := r.getNodeEnvelope(id); gerr == nil && !cur.Deleted {
+			updates := make(map[string]interface{})
+			for _, k := range eventMetaKeys {
+				if !reflect.DeepEqual(cur.Meta[k], meta[k]) {
+					updates[k] = meta[k]
+				}
+			}
+			if len(updates) == 0 && string(cur.Content) == e.Description {
+				stats.Skipped++
+			} else if _, err = r.updateContent(id, content, updates); err == nil {
+				stats.Updated++
+			}
+		} else if r.Refs.Has(id) {
+			stats.Skipped++ // deleted here; not brought back
+			live = false
+		} else {
+			node, err = r.storeNewNode(id, EventType, content, meta)
+		}
+		unlock()
+		if err != nil {
+			break
+		}
+		if node != nil {
+			stats.Created++
+			created = append(created, node)
+		}
+		if !live {
+			continue
+		}
+
+		linked := make(map[string]bool)
+		for _, l := range r.Links.LinksFrom(id) {
+			if l.Type == LinkAttendedBy {
+				linked[l.Target] = true
+			}
+		}
+		for _, email := range append([]string{e.Organizer}, e.Attendees...) {
+			for _, pid := range persons[email] {
+				if linked[pid] {
+					continue
+				}
+				linked[pid] = true
+				link := LinkEntry{Source: id, Target: pid, Type: LinkAttendedBy}
+				if err = r.Links.Add(link); err != nil {
+					break
+				}
+				links = append(links, link)
+				stats.Linked++
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if stats.Created > 0 || len(links) > 0 {
+		r.commit(fmt.Sprintf("import %d events (%d updated, %d links)", stats.Created, stats.Updated, len(links)))
+		for _, node := range created {
+			r.afterCreate(node)
+		}
+		for i := range links {
+			r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &links[i]})
+		}
+	}
+	return stats, err
+}
+
+// CalendarEvent is an event filed in a calendar month.
+type CalendarEvent struct {
+	ID     string
+	Start  time.Time // local; midnight for all-day events
+	AllDay bool
+}
+
+// CalendarMonths returns the months (YYYY-MM, local) in which some event
+// starts, oldest first.
+func (r *Repository) CalendarMonths() []string {
+	seen := make(map[string]bool)
+	var months []string
+	for _, ev := range r.calendarEvents() {
+		if m := ev.Start.Format("2006-01"); !seen[m] {
+			seen[m] = true
+			months = append(months, m)
+		}
+	}
+	sort.Strings(months)
+	return months
+}
+
+// EventsIn returns the events starting in month (YYYY-MM, local), in
+// order of start.
+func (r *Repository) EventsIn(month string) []CalendarEvent {
+	var events []CalendarEvent
+	for _, ev := range r.calendarEvents() {
+		if ev.Start.Format("2006-01") == month {
+			events = append(events, ev)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events
+}
+
+// calendarEvents returns every live Event node with a readable start.
+func (r *Repository) calendarEvents() []CalendarEvent {
+	var events []CalendarEvent
+	for _, id := range r.Search.FilterByType(EventType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		s, _ := node.Meta[eventStartKey].(string)
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			events = append(events, CalendarEvent{ID: id, Start: t.In(time.Local)})
+		} else if t, err := time.ParseInLocation(DayLayout, s, time.Local); err == nil {
+			events = append(events, CalendarEvent{ID: id, Start: t, AllDay: true})
+		}
+	}
+	return events
+}
@@ -0,0 +1,117 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup-1@example.com\r\n" +
+	"DTSTART:20250312T090000Z\r\n" +
+	"DURATION:PT30M\r\n" +
+	"SUMMARY:Standup\\, weekly\r\n" +
+	"DESCRIPTION:Agenda:\\n- roadmap\r\n" +
+	" review\r\n" +
+	"ORGANIZER;CN=Ann:mailto:Ann@Example.com\r\n" +
+	"ATTENDEE;CN=\"Bob: the builder\";PARTSTAT=ACCEPTED:MAILTO:bob@example.com\r\n" +
+	"ATTENDEE:mailto:nobody@example.com\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"DESCRIPTION:not the event\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:offsite@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20250401\r\n" +
+	"DTEND;VALUE=DATE:20250403\r\n" +
+	"SUMMARY:Offsite\r\n" +
+	"LOCATION:Lisbon\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v", events)
+	}
+	e := events[0]
+	if e.Summary != "Standup, weekly" || e.Description != "Agenda:\n- roadmapreview" {
+		t.Errorf("text = %q, %q", e.Summary, e.Description)
+	}
+	if !e.Start.Equal(time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC)) || e.End.Sub(e.Start) != 30*time.Minute {
+		t.Errorf("times = %v - %v", e.Start, e.End)
+	}
+	if e.Organizer != "ann@example.com" || !reflect.DeepEqual(e.Attendees, []string{"bob@example.com", "nobody@example.com"}) {
+		t.Errorf("people = %q, %q", e.Organizer, e.Attendees)
+	}
+	if e := events[1]; !e.AllDay || e.Start.Format(DayLayout) != "2025-04-01" || e.Location != "Lisbon" {
+		t.Errorf("all-day event = %+v", e)
+	}
+
+	if _, err := ParseICS(strings.NewReader("BEGIN:VEVENT\nUID:x\nEND:VEVENT\n")); err == nil {
+		t.Error("event without DTSTART parsed")
+	}
+	if d, err := parseICSDuration("-P1DT2H"); err != nil || d != -26*time.Hour {
+		t.Errorf("parseICSDuration = %v, %v", d, err)
+	}
+}
+
+func TestImportEvents(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("person:ann", PersonType, nil, map[string]interface{}{"email": "ann@example.com"})
+	events, _ := ParseICS(strings.NewReader(testICS))
+
+	stats, err := repo.ImportEvents(t.Context(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Created: 2, Linked: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+	id := eventNodeID(events[0])
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != EventType || node.Meta["title"] != "Standup, weekly" ||
+		node.Meta["start"] != "2025-03-12T09:00:00Z" || node.Meta["end"] != "2025-03-12T09:30:00Z" {
+		t.Errorf("node = %s %v", node.Type, node.Meta)
+	}
+	offsite, _ := repo.GetNode(eventNodeID(events[1]))
+	if offsite.Meta["start"] != "2025-04-01" || offsite.Meta["end"] != "2025-04-02" || offsite.Meta["all_day"] != true {
+		t.Errorf("all-day meta = %v", offsite.Meta)
+	}
+	if links := repo.Links.LinksFrom(id); len(links) != 1 || links[0].Target != "person:ann" || links[0].Type != LinkAttendedBy {
+		t.Errorf("links = %+v", links)
+	}
+
+	// A re-import updates what changed and links people added since.
+	repo.CreateNode("person:bob", PersonType, nil, map[string]interface{}{"emails": []interface{}{"Bob@Example.com"}})
+	events[0].Location = "Room 4"
+	stats, err = repo.ImportEvents(t.Context(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Updated: 1, Skipped: 1, Linked: 1}) {
+		t.Errorf("re-import stats = %+v", stats)
+	}
+	if node, _ := repo.GetNode(id); node.Meta["location"] != "Room 4" {
+		t.Errorf("location = %v", node.Meta["location"])
+	}
+	if links := repo.Links.LinksFrom(id); len(links) != 2 {
+		t.Errorf("links after re-import = %+v", links)
+	}
+
+	if got := repo.CalendarMonths(); !reflect.DeepEqual(got, []string{"2025-03", "2025-04"}) {
+		t.Errorf("CalendarMonths = %v", got)
+	}
+	if got := repo.EventsIn("2025-04"); len(got) != 1 || got[0].ID != offsite.ID || !got[0].AllDay {
+		t.Errorf("EventsIn(2025-04) = %+v", got)
+	}
+}
@@ -0,0 +1,46 @@
+package dag
+
+import "fmt"
+
+// ConflictError reports a compare-and-swap update that lost a race: the
+// node's ref had moved past the version the caller read, so the write was
+// refused rather than clobbering the newer one. Callers re-read, merge, and
+// retry with the new CID.
+type ConflictError struct {
+	ID       string
+	Expected string // CID the caller passed as ifMatch
+	Actual   string // CID the ref points at now
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict on %s: expected version %s, ref is at %s", e.ID, e.Expected, e.Actual)
+}
+
+// Is makes a ConflictError match ErrConflict.
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// NodeCID returns the CID id's ref points at: the version token for the
+// IfMatch update variants, and what an API would hand out as an ETag.
+func (r *Repository) NodeCID(id string) (string, error) {
+	c, err := r.Refs.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return CIDToFilename(c), nil
+}
+
+// checkMatch enforces an ifMatch precondition. Empty ifMatch always
+// passes. Callers hold id's lock so the ref cannot move after the check.
+func (r *Repository) checkMatch(id, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	actual, err := r.NodeCID(id)
+	if err != nil {
+		return err
+	}
+	if actual != ifMatch {
+		return &ConflictError{ID: id, Expected: ifMatch, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateIfMatch(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("v1"), nil)
+
+	v1, err := repo.NodeCID("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateContentIfMatch("note:a", v1, []byte("v2")); err != nil {
+		t.Fatalf("update at current version: %v", err)
+	}
+	v2, _ := repo.NodeCID("note:a")
+
+	// A second writer still holding v1 must not clobber v2.
+	_, err = repo.UpdateContentIfMatch("note:a", v1, []byte("stale"))
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("stale content update: err = %v, want *ConflictError", err)
+	}
+	if conflict.Expected != v1 || conflict.Actual != v2 {
+		t.Errorf("conflict = %+v, want expected %s actual %s", conflict, v1, v2)
+	}
+	if _, err := repo.UpdateNodeIfMatch("note:a", v1, map[string]interface{}{"k": "v"}); !errors.As(err, &conflict) {
+		t.Errorf("stale meta update: err = %v, want *ConflictError", err)
+	}
+
+	node, _ := repo.GetNode("note:a")
+	if string(node.Content) != "v2" || node.Meta["k"] != nil {
+		t.Errorf("refused updates were written: content %q meta %v", node.Content, node.Meta)
+	}
+	if now, _ := repo.NodeCID("note:a"); now != v2 {
+		t.Error("ref moved after refused updates")
+	}
+
+	// Retrying against the fresh version succeeds.
+	if _, err := repo.UpdateNodeIfMatch("note:a", v2, map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ClipboardType is the type of nodes captured from the clipboard.
+const ClipboardType = "Clipboard"
+
+// maxClipBytes bounds what clipboard capture stores; larger copies are
+// usually whole documents, not research fragments.
+const maxClipBytes = 256 << 10
+
+// Clip is one clipboard reading.
+type Clip struct {
+	Text string
+	App  string // application copied from; "" when it cannot be told
+}
+
+// ClipboardReader reads the current clipboard.
+type ClipboardReader func(ctx context.Context) (Clip, error)
+
+// ClipID returns the ID of the Clipboard node holding text. It is derived
+// from the content hash, so copying the same text again finds the node
+// captured the first time.
+func ClipID(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "clip:" + hex.EncodeToString(sum[:8])
+}
+
+// CaptureClip stores clip as a Clipboard node unless the same text was
+// captured before. Text that is a single URL is recorded as such in meta.
+// Reports whether a node was created.
+func (r *Repository) CaptureClip(clip Clip) (string, bool, error) {
+	text := strings.TrimSpace(clip.Text)
+	if text == "" {
+		return "", false, fmt.Errorf("empty clip")
+	}
+	if len(text) > maxClipBytes {
+		return "", false, fmt.Errorf("clip is %d bytes, over the %d byte limit", len(text), maxClipBytes)
+	}
+	id := ClipID(text)
+	unlock := r.lockNode(id)
+	defer unlock()
+	if r.Refs.Has(id) {
+		return id, false, nil
+	}
+
+	meta := map[string]interface{}{"kind": "text"}
+	if u, err := url.Parse(text); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		!strings.ContainsAny(text, " \t\n") {
+		meta["kind"] = "url"
+		meta["url"] = text
+	}
+	if clip.App != "" {
+		meta["app"] = clip.App
+	}
+	if _, err := r.createNode(id, ClipboardType, []byte(text), meta); err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+// StartClipboardCapture polls read every interval and captures each new
+// clipboard text with CaptureClip until the returned stop function is
+// called. Whatever is on the clipboard at start is left alone: only
+// copies made while capturing are taken. Failures are logged once until
+// reading works again. stop returns once capturing has stopped.
+func (r *Repository) StartClipboardCapture(interval time.Duration, read ClipboardReader) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last string
+		first, failing := true, false
+		for {
+			clip, err := read(ctx)
+			switch {
+			case err != nil:
+				if !failing && ctx.Err() == nil {
+					fmt.Printf("memex-fs: clipboard warning: %v\n", err)
+				}
+				failing = true
+			case clip.Text != last:
+				failing = false
+				last = clip.Text
+				if !first && strings.TrimSpace(clip.Text) != "" {
+					if _, _, err := r.CaptureClip(clip); err != nil {
+						fmt.Printf("memex-fs: clipboard warning: %v\n", err)
+					}
+				}
+				first = false
+			default:
+				failing = false
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// SystemClipboard returns a ClipboardReader for the desktop clipboard,
+// using whichever of wl-paste (Wayland), xclip or xsel (X11) and pbpaste
+// (macOS) is available. The source app comes from xdotool on X11 and
+// System Events on macOS, when they answer.
+func SystemClipboard() (ClipboardReader, error) {
+	var paste, app []string
+	switch {
+	case runtime.GOOS == "darwin":
+		paste = []string{"pbpaste"}
+		app = []string{"osascript", "-e",
+			`tell application "System Events" to get name of first process whose frontmost is true`}
+	case os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand("wl-paste"):
+		paste = []string{"wl-paste", "--no-newline", "--type", "text"}
+	case os.Getenv("DISPLAY") != "" && hasCommand("xclip"):
+		paste = []string{"xclip", "-selection", "clipboard", "-out"}
+		app = []string{"xdotool", "getactivewindow", "getwindowclassname"}
+	case os.Getenv("DISPLAY") != "" && hasCommand("xsel"):
+		paste = []string{"xsel", "--clipboard", "--output"}
+		app = []string{"xdotool", "getactivewindow", "getwindowclassname"}
+	default:
+		return nil, fmt.Errorf("no clipboard tool found (need wl-paste, xclip, xsel or pbpaste)")
+	}
+	if len(app) > 0 && !hasCommand(app[0]) {
+		app = nil
+	}
+	return func(ctx context.Context) (Clip, error) {
+		out, err := exec.CommandContext(ctx, paste[0], paste[1:]...).Output()
+		if err != nil {
+			// An empty clipboard makes some tools exit non-zero.
+			if _, ok := err.(*exec.ExitError); ok {
+				return Clip{}, nil
+			}
+			return Clip{}, fmt.Errorf("%s: %w", paste[0], err)
+		}
+		clip := Clip{Text: string(out)}
+		if len(app) > 0 {
+			if name, err := exec.CommandContext(ctx, app[0], app[1:]...).Output(); err == nil {
+				clip.App = string(bytes.TrimSpace(name))
+			}
+		}
+		return clip, nil
+	}, nil
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
@@ -0,0 +1,68 @@
+package dag
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCaptureClip_DedupAndURL(t *testing.T) {
+	repo := openTestRepo(t)
+	id, created, err := repo.CaptureClip(Clip{Text: " https://example.com/paper \n", App: "Firefox"})
+	if err != nil || !created {
+		t.Fatalf("CaptureClip = %s, %v, %v", id, created, err)
+	}
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != ClipboardType || string(node.Content) != "https://example.com/paper" {
+		t.Errorf("node = %s %q", node.Type, node.Content)
+	}
+	if node.Meta["kind"] != "url" || node.Meta["url"] != "https://example.com/paper" || node.Meta["app"] != "Firefox" {
+		t.Errorf("meta = %v", node.Meta)
+	}
+
+	again, created, err := repo.CaptureClip(Clip{Text: "https://example.com/paper"})
+	if err != nil || created || again != id {
+		t.Errorf("recapture = %s, %v, %v; want existing %s", again, created, err, id)
+	}
+
+	id, _, _ = repo.CaptureClip(Clip{Text: "see https://example.com for details"})
+	if node, _ := repo.GetNode(id); node.Meta["kind"] != "text" || node.Meta["app"] != nil {
+		t.Errorf("text clip meta = %v", node.Meta)
+	}
+	if _, _, err := repo.CaptureClip(Clip{Text: "  "}); err == nil {
+		t.Error("captured an empty clip")
+	}
+}
+
+func TestStartClipboardCapture(t *testing.T) {
+	repo := openTestRepo(t)
+	var mu sync.Mutex
+	current := "already there"
+	read := func(ctx context.Context) (Clip, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return Clip{Text: current}, nil
+	}
+	stop := repo.StartClipboardCapture(5*time.Millisecond, read)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	current = "freshly copied"
+	mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !repo.Refs.Has(ClipID("freshly copied")) {
+		if time.Now().After(deadline) {
+			t.Fatal("new clip was not captured")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if repo.Refs.Has(ClipID("already there")) {
+		t.Error("clipboard content from before capture started was captured")
+	}
+}
@@ -19,6 +19,7 @@ type CoAccessIndex struct {
 	currentWindow map[string]bool           // deduplicated nodes in active session
 	windowStart   time.Time                 // when current session started
 	lastAccess    time.Time                 // timestamp of most recent access
+	budget        int64                     // estimated bytes of pairs kept; 0 is unbounded
 }
 
 // accessLogEntry matches the JSONL format written by fuse.AccessLog.
@@ -30,13 +31,25 @@ type accessLogEntry struct {
 
 // NewCoAccessIndex creates a CoAccessIndex, loading historical data from the access log.
 func NewCoAccessIndex(logPath string, window time.Duration) *CoAccessIndex {
-	idx := &CoAccessIndex{
+	idx := newCoAccessIndex(window)
+	idx.load(logPath)
+	return idx
+}
+
+// newCoAccessIndex creates an empty CoAccessIndex, for loadLocked to fill.
+func newCoAccessIndex(window time.Duration) *CoAccessIndex {
+	return &CoAccessIndex{
 		pairs:         make(map[string]map[string]int),
 		window:        window,
 		currentWindow: make(map[string]bool),
 	}
+}
+
+// loadLocked is load for an index already in use.
+func (idx *CoAccessIndex) loadLocked(logPath string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 	idx.load(logPath)
-	return idx
 }
 
 // load replays the access.jsonl file into sessions.
@@ -86,6 +99,7 @@ func (idx *CoAccessIndex) load(logPath string) {
 	}
 	// Flush final session
 	idx.flushSession(session)
+	prunePairs(idx.pairs, idx.budget)
 }
 
 // flushSession increments co-occurrence counts for all unique pairs in the session.
@@ -121,6 +135,7 @@ func (idx *CoAccessIndex) Record(nodeID string, ts time.Time) {
 			session = append(session, id)
 		}
 		idx.flushSession(session)
+		prunePairs(idx.pairs, idx.budget)
 		idx.currentWindow = make(map[string]bool)
 		idx.windowStart = ts
 	}
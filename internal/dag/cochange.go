@@ -13,6 +13,7 @@ type CoChangeIndex struct {
 	pairs   map[string]map[string]int // nodeA → nodeB → count
 	commits *CommitLog
 	window  time.Duration // temporal grouping window
+	budget  int64         // estimated bytes of pairs kept; 0 is unbounded
 }
 
 // changeEvent is a single commit's changed refs with timestamp, used for windowing.
@@ -38,34 +39,22 @@ func (idx *CoChangeIndex) Build() {
 	defer idx.mu.Unlock()
 
 	// Walk up to 1000 commits (newest first)
-	commits, err := idx.commits.Log(1000)
+	commits, err := idx.commits.History(1000)
 	if err != nil || len(commits) < 2 {
 		return
 	}
 
-	// Collect per-commit changed refs by diffing against parent.
-	// commits are newest-first, so commits[i+1] is the parent of commits[i].
+	// Collect per-commit changed refs from the commit index.
 	var events []changeEvent
-
-	for i := 0; i < len(commits)-1; i++ {
-		child := commits[i]
-		parent := commits[i+1]
-		changed := diffRefs(parent.Refs, child.Refs)
-		if len(changed) > 0 {
-			events = append(events, changeEvent{ts: child.Timestamp, changed: changed})
+	for _, c := range commits {
+		if len(c.Changes) == 0 {
+			continue
 		}
-	}
-
-	// Also handle the first commit (no parent — all refs are "new")
-	if len(commits) > 0 {
-		first := commits[len(commits)-1]
-		if len(first.Refs) > 0 {
-			changed := make([]string, 0, len(first.Refs))
-			for id := range first.Refs {
-				changed = append(changed, id)
-			}
-			events = append(events, changeEvent{ts: first.Timestamp, changed: changed})
+		changed := make([]string, len(c.Changes))
+		for i, ch := range c.Changes {
+			changed[i] = ch.ID
 		}
+		events = append(events, changeEvent{ts: c.Timestamp, changed: changed})
 	}
 
 	// Sort events by time (oldest first) for windowing
@@ -89,6 +78,7 @@ func (idx *CoChangeIndex) Build() {
 		windowEvents = append(windowEvents, evt)
 	}
 	idx.flushWindow(windowEvents)
+	prunePairs(idx.pairs, idx.budget)
 }
 
 // flushWindow collects all unique changed nodes across events in the window,
@@ -126,33 +116,6 @@ func (idx *CoChangeIndex) flushWindow(events []changeEvent) {
 	}
 }
 
-// diffRefs compares two ref snapshots and returns the IDs that changed
-// (different CID, added, or removed).
-func diffRefs(parent, child map[string]string) []string {
-	changed := make(map[string]bool)
-
-	// Check for changed or added refs
-	for id, cid := range child {
-		parentCID, exists := parent[id]
-		if !exists || parentCID != cid {
-			changed[id] = true
-		}
-	}
-
-	// Check for removed refs
-	for id := range parent {
-		if _, exists := child[id]; !exists {
-			changed[id] = true
-		}
-	}
-
-	result := make([]string, 0, len(changed))
-	for id := range changed {
-		result = append(result, id)
-	}
-	return result
-}
-
 // Related returns the top co-changed nodes for the given node, sorted by count.
 func (idx *CoChangeIndex) Related(nodeID string, limit int) []string {
 	idx.mu.RLock()
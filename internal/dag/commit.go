@@ -5,11 +5,20 @@ import "time"
 // CommitObject is a Merkle DAG commit — a snapshot of all refs and links at a point in time.
 // Serialized via CanonicalJSON and stored in the ObjectStore like any other object.
 type CommitObject struct {
-	V         int               `json:"v"`
-	Parent    string            `json:"parent,omitempty"` // CID (base32) of previous commit
+	V      int    `json:"v"`
+	Parent string `json:"parent,omitempty"` // CID (base32) of previous commit
+	// Merged is the CID of the commit a merge commit brought in, its
+	// second parent; "" for ordinary commits.
+	Merged    string            `json:"merged,omitempty"`
 	Author    string            `json:"author,omitempty"` // DID of the committer
 	Timestamp time.Time         `json:"timestamp"`
 	Refs      map[string]string `json:"refs"`  // id → CID (base32)
 	Links     []LinkEntry       `json:"links"` // sorted snapshot of all links
 	Message   string            `json:"message,omitempty"`
+	// Changes is what this commit changed relative to Parent. Commits
+	// written before it was recorded lack it.
+	Changes *CommitChanges `json:"changes,omitempty"`
+	// Signature is a base64 Ed25519 signature by Author over the commit
+	// without this field. Append-only repositories sign every commit.
+	Signature string `json:"signature,omitempty"`
 }
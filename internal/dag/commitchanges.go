@@ -0,0 +1,114 @@
+package dag
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+)
+
+// CommitChanges is what a commit changed relative to its parent, recorded
+// in the commit so tooling need not diff the full snapshots.
+type CommitChanges struct {
+	Nodes        []NodeChange `json:"nodes,omitempty"` // sorted by ID
+	LinksAdded   []LinkEntry  `json:"links_added,omitempty"`
+	LinksRemoved []LinkEntry  `json:"links_removed,omitempty"`
+}
+
+// NodeChange is how one commit changed one node: the ref move, plus which
+// parts of the envelope differ when both versions could be read.
+type NodeChange struct {
+	RefChange
+	ContentChanged bool     `json:"content_changed,omitempty"`
+	TypeChanged    bool     `json:"type_changed,omitempty"`
+	MetaAdded      []string `json:"meta_added,omitempty"`
+	MetaRemoved    []string `json:"meta_removed,omitempty"`
+	MetaModified   []string `json:"meta_modified,omitempty"`
+}
+
+// refChanges returns the ref moves of c.
+func (c *CommitChanges) refChanges() []RefChange {
+	out := make([]RefChange, len(c.Nodes))
+	for i, n := range c.Nodes {
+		out[i] = n.RefChange
+	}
+	return out
+}
+
+// diffCommit describes the change from parent (nil for a root commit) to a
+// commit of refs and links. Node versions that cannot be read are still
+// reported as ref moves.
+func (cl *CommitLog) diffCommit(parent *CommitObject, refs map[string]string, links []LinkEntry) *CommitChanges {
+	var parentRefs map[string]string
+	var parentLinks []LinkEntry
+	if parent != nil {
+		parentRefs, parentLinks = parent.Refs, parent.Links
+	}
+	changes := &CommitChanges{}
+	for _, rc := range refChanges(parentRefs, refs) {
+		nc := NodeChange{RefChange: rc}
+		oldNode, newNode := cl.envelope(rc.Old), cl.envelope(rc.New)
+		if oldNode != nil && newNode != nil {
+			nc.ContentChanged = !bytes.Equal(oldNode.Content, newNode.Content)
+			nc.TypeChanged = oldNode.Type != newNode.Type
+			nc.MetaAdded, nc.MetaRemoved, nc.MetaModified = diffMeta(oldNode.Meta, newNode.Meta)
+		}
+		changes.Nodes = append(changes.Nodes, nc)
+	}
+
+	had := make(map[LinkEntry]bool, len(parentLinks))
+	for _, l := range parentLinks {
+		had[l] = true
+	}
+	for _, l := range links {
+		if had[l] {
+			delete(had, l)
+		} else {
+			changes.LinksAdded = append(changes.LinksAdded, l)
+		}
+	}
+	for _, l := range parentLinks {
+		if had[l] {
+			changes.LinksRemoved = append(changes.LinksRemoved, l)
+		}
+	}
+	return changes
+}
+
+// envelope reads the node version stored under name, or nil.
+func (cl *CommitLog) envelope(name string) *NodeEnvelope {
+	if name == "" {
+		return nil
+	}
+	c, err := cidFromFilename(name)
+	if err != nil {
+		return nil
+	}
+	node, err := cl.store.GetNode(c)
+	if err != nil {
+		return nil
+	}
+	return node
+}
+
+// diffMeta returns the sorted keys added to, removed from and modified
+// between two meta maps.
+func diffMeta(old, cur map[string]interface{}) (added, removed, modified []string) {
+	for k, v := range cur {
+		ov, ok := old[k]
+		switch {
+		case !ok:
+			added = append(added, k)
+		case !reflect.DeepEqual(ov, v):
+			modified = append(modified, k)
+		}
+	}
+	for k := range old {
+		if _, ok := cur[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(modified)
+	return added, removed, modified
+}
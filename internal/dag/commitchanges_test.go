@@ -0,0 +1,55 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func headCommit(t *testing.T, repo *Repository) *CommitObject {
+	t.Helper()
+	head, err := repo.Commits.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.Commits.GetCommit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Changes == nil {
+		t.Fatal("commit records no changes")
+	}
+	return commit
+}
+
+func TestCommit_RecordsChanges(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), map[string]interface{}{"x": 1.0, "gone": true})
+	if c := headCommit(t, repo).Changes; len(c.Nodes) != 1 || c.Nodes[0].Action() != "created" {
+		t.Fatalf("create changes = %+v", c)
+	}
+
+	repo.UpdateNode("note:a", map[string]interface{}{"x": 2.0, "y": "new", "gone": nil})
+	nc := headCommit(t, repo).Changes.Nodes[0]
+	if nc.ContentChanged || nc.TypeChanged ||
+		!reflect.DeepEqual(nc.MetaAdded, []string{"y"}) ||
+		!reflect.DeepEqual(nc.MetaRemoved, []string{"gone"}) ||
+		!reflect.DeepEqual(nc.MetaModified, []string{"x"}) {
+		t.Errorf("meta update change = %+v", nc)
+	}
+
+	repo.UpdateContent("note:a", []byte("a2"))
+	if nc := headCommit(t, repo).Changes.Nodes[0]; !nc.ContentChanged || nc.MetaModified != nil {
+		t.Errorf("content update change = %+v", nc)
+	}
+
+	repo.CreateNode("note:b", "Note", nil, nil)
+	repo.CreateLink("note:a", "note:b", "cites")
+	c := headCommit(t, repo).Changes
+	if len(c.Nodes) != 0 || len(c.LinksAdded) != 1 || c.LinksAdded[0].Target != "note:b" {
+		t.Errorf("link changes = %+v", c)
+	}
+	repo.RemoveLink("note:a", "note:b", "cites")
+	if c := headCommit(t, repo).Changes; len(c.LinksRemoved) != 1 || len(c.LinksAdded) != 0 {
+		t.Errorf("unlink changes = %+v", c)
+	}
+}
@@ -0,0 +1,334 @@
+package dag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// CommitSummary is one commit as the commit index records it: enough to
+// list history and answer per-node and point-in-time queries without
+// loading commit objects, which carry a full snapshot of refs and links.
+type CommitSummary struct {
+	CID       string      `json:"cid"` // base32
+	Parent    string      `json:"parent,omitempty"`
+	Author    string      `json:"author,omitempty"`
+	Timestamp time.Time   `json:"ts"`
+	Message   string      `json:"msg,omitempty"`
+	Changes   []RefChange `json:"changes,omitempty"` // sorted by ID
+	// Partial is set when the commit does not record its changes and the
+	// parent commit is not in the store, or is past a shallow boundary, so
+	// Changes could not be computed.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// RefChange is one ref a commit moved.
+type RefChange struct {
+	ID  string `json:"id"`
+	Old string `json:"old,omitempty"` // CID before the commit; "" if created
+	New string `json:"new,omitempty"` // CID after the commit; "" if deleted
+}
+
+// Action is "created", "changed" or "deleted".
+func (c RefChange) Action() string {
+	switch {
+	case c.Old == "":
+		return "created"
+	case c.New == "":
+		return "deleted"
+	}
+	return "changed"
+}
+
+// Change returns the change s made to id's ref, if any.
+func (s *CommitSummary) Change(id string) (RefChange, bool) {
+	i := sort.Search(len(s.Changes), func(i int) bool { return s.Changes[i].ID >= id })
+	if i < len(s.Changes) && s.Changes[i].ID == id {
+		return s.Changes[i], true
+	}
+	return RefChange{}, false
+}
+
+// refChanges diffs two ref snapshots, sorted by ID.
+func refChanges(parent, child map[string]string) []RefChange {
+	var changes []RefChange
+	for id, c := range child {
+		if old := parent[id]; old != c {
+			changes = append(changes, RefChange{ID: id, Old: old, New: c})
+		}
+	}
+	for id, old := range parent {
+		if _, ok := child[id]; !ok {
+			changes = append(changes, RefChange{ID: id, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes
+}
+
+// commitIndex mirrors the commit chain ending at HEAD as a JSONL file of
+// CommitSummary, oldest first, at .mx/commitidx. New commits are appended;
+// when HEAD moves some other way (a pull, a rewritten chain, another
+// process) the index walks back from HEAD to the newest commit it already
+// has and rewrites itself from there.
+type commitIndex struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool            // file disagrees with entries; rewrite on next sync
+	entries []CommitSummary // oldest first; never modified in place
+	pos     map[string]int  // CID → index in entries
+}
+
+// load reads the index file, keeping its longest valid prefix: a torn
+// last line or a broken parent chain drops everything from that point.
+// Callers hold x.mu.
+func (x *commitIndex) load() {
+	x.loaded = true
+	x.entries, x.pos = nil, make(map[string]int)
+	data, err := os.ReadFile(x.path)
+	if err != nil {
+		x.dirty = !os.IsNotExist(err)
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var s CommitSummary
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil || s.CID == "" {
+			x.dirty = true
+			return
+		}
+		if n := len(x.entries); n > 0 && s.Parent != x.entries[n-1].CID {
+			x.dirty = true
+			return
+		}
+		x.pos[s.CID] = len(x.entries)
+		x.entries = append(x.entries, s)
+	}
+}
+
+// sync brings the index up to date with head and returns its entries.
+// Callers hold x.mu.
+func (x *commitIndex) sync(cl *CommitLog, head gocid.Cid) ([]CommitSummary, error) {
+	if !x.loaded {
+		x.load()
+	}
+	if !head.Defined() {
+		if len(x.entries) > 0 || x.dirty {
+			x.entries, x.pos = nil, make(map[string]int)
+			return nil, x.rewrite()
+		}
+		return nil, nil
+	}
+	name := CIDToFilename(head)
+	if n := len(x.entries); n > 0 && x.entries[n-1].CID == name && !x.dirty {
+		return x.entries, nil
+	}
+
+	// Walk back from HEAD to a commit the index has, or to the root.
+	var added []CommitSummary // newest first
+	keep := 0
+	current := head
+	var commit *CommitObject
+	if i, ok := x.pos[name]; ok {
+		// HEAD moved back along the indexed chain.
+		keep = i + 1
+	} else {
+		c, err := cl.GetCommit(current)
+		if err != nil {
+			return nil, err
+		}
+		commit = c
+	}
+	for commit != nil {
+		s := CommitSummary{
+			CID:       CIDToFilename(current),
+			Parent:    commit.Parent,
+			Author:    commit.Author,
+			Timestamp: commit.Timestamp,
+			Message:   commit.Message,
+		}
+		// Commits that record their changes need no parent to diff against.
+		recorded := commit.Changes != nil
+		if recorded {
+			s.Changes = commit.Changes.refChanges()
+		}
+		if commit.Parent == "" {
+			if !recorded {
+				s.Changes = refChanges(nil, commit.Refs)
+			}
+			added = append(added, s)
+			break
+		}
+		if cl.boundary[s.CID] {
+			// A shallow commit: its parent was never fetched.
+			s.Partial = !recorded
+			added = append(added, s)
+			break
+		}
+		if i, ok := x.pos[commit.Parent]; ok && recorded {
+			added = append(added, s)
+			keep = i + 1
+			break
+		}
+		parentCID, err := cidFromFilename(commit.Parent)
+		var parent *CommitObject
+		if err == nil {
+			parent, err = cl.GetCommit(parentCID)
+		}
+		if err != nil {
+			s.Partial = !recorded
+			added = append(added, s)
+			break
+		}
+		if !recorded {
+			s.Changes = refChanges(parent.Refs, commit.Refs)
+		}
+		added = append(added, s)
+		if i, ok := x.pos[commit.Parent]; ok {
+			keep = i + 1
+			break
+		}
+		current, commit = parentCID, parent
+	}
+
+	appendOnly := keep == len(x.entries) && len(added) > 0 && !x.dirty
+	entries := x.entries[:keep:keep]
+	for i := len(added) - 1; i >= 0; i-- {
+		entries = append(entries, added[i])
+	}
+	x.entries = entries
+	x.pos = make(map[string]int, len(entries))
+	for i, s := range entries {
+		x.pos[s.CID] = i
+	}
+	if appendOnly {
+		return x.entries, x.append(len(added))
+	}
+	return x.entries, x.rewrite()
+}
+
+// append writes the last n entries to the end of the file. Callers hold
+// x.mu.
+func (x *commitIndex) append(n int) error {
+	f, err := os.OpenFile(x.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open commit index: %w", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range x.entries[len(x.entries)-n:] {
+		if err := enc.Encode(s); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		x.dirty = true
+		return fmt.Errorf("write commit index: %w", err)
+	}
+	return f.Close()
+}
+
+// rewrite replaces the file with entries. Callers hold x.mu.
+func (x *commitIndex) rewrite() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range x.entries {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	if err := SafeWrite(x.path, buf.Bytes(), 0644); err != nil {
+		x.dirty = true
+		return fmt.Errorf("write commit index: %w", err)
+	}
+	x.dirty = false
+	return nil
+}
+
+// summaries returns the indexed chain ending at HEAD, oldest first. The
+// slice is shared; callers must not modify it.
+func (cl *CommitLog) summaries() ([]CommitSummary, error) {
+	head, err := cl.Head()
+	if err != nil {
+		return nil, err
+	}
+	cl.index.mu.Lock()
+	defer cl.index.mu.Unlock()
+	return cl.index.sync(cl, head)
+}
+
+// History returns up to n commits from HEAD back, newest first, from the
+// commit index.
+func (cl *CommitLog) History(n int) ([]CommitSummary, error) {
+	entries, err := cl.summaries()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CommitSummary, 0, min(n, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, entries[i])
+	}
+	return out, nil
+}
+
+// HistoryBy is History limited to the commits by author.
+func (cl *CommitLog) HistoryBy(author string, n int) ([]CommitSummary, error) {
+	entries, err := cl.summaries()
+	if err != nil {
+		return nil, err
+	}
+	var out []CommitSummary
+	for i := len(entries) - 1; i >= 0 && len(out) < n; i-- {
+		if entries[i].Author == author {
+			out = append(out, entries[i])
+		}
+	}
+	return out, nil
+}
+
+// Authors returns the distinct authors of the commit history, sorted.
+func (cl *CommitLog) Authors() ([]string, error) {
+	entries, err := cl.summaries()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, e := range entries {
+		if e.Author != "" && !seen[e.Author] {
+			seen[e.Author] = true
+			out = append(out, e.Author)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Changelog returns the commits that created, changed or deleted id,
+// newest first. truncated reports that the history does not reach the
+// root commit, so older changes may be missing.
+func (cl *CommitLog) Changelog(id string) (log []CommitSummary, truncated bool, err error) {
+	entries, err := cl.summaries()
+	if err != nil {
+		return nil, false, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if _, ok := entries[i].Change(id); ok {
+			log = append(log, entries[i])
+		}
+	}
+	truncated = len(entries) > 0 && entries[0].Parent != ""
+	return log, truncated, nil
+}
@@ -0,0 +1,127 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommitIndex_HistoryAndChangelog(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("first"), nil)
+	repo.CreateNode("note:b", "Note", []byte("other"), nil)
+	repo.UpdateContent("note:a", []byte("second"))
+	repo.DeleteNode("note:b", true)
+
+	hist, err := repo.Commits.History(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 4 {
+		t.Fatalf("history has %d commits, want 4", len(hist))
+	}
+	head, _ := repo.Commits.Head()
+	if hist[0].CID != CIDToFilename(head) || hist[0].Parent != hist[1].CID {
+		t.Errorf("history is not the chain from HEAD: %+v", hist)
+	}
+	if ch, ok := hist[0].Change("note:b"); !ok || ch.Action() != "deleted" {
+		t.Errorf("latest change to note:b = %+v, %v", ch, ok)
+	}
+	if short, _ := repo.Commits.History(2); len(short) != 2 || short[1].CID != hist[1].CID {
+		t.Errorf("History(2) = %+v", short)
+	}
+
+	log, truncated, err := repo.Commits.Changelog("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated {
+		t.Error("changelog truncated")
+	}
+	if len(log) != 2 || log[0].Message != "update content note:a" || log[1].Message != "create note:a" {
+		t.Fatalf("changelog = %+v", log)
+	}
+	if ch, _ := log[1].Change("note:a"); ch.Action() != "created" {
+		t.Errorf("oldest change = %+v", ch)
+	}
+}
+
+func TestCommitIndex_PersistsAndAppends(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+
+	path := filepath.Join(repo.MxDir(), "commitidx")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Errorf("index has %d lines after two commits, want 2", n)
+	}
+
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.CreateNode("note:c", "Note", []byte("c"), nil)
+	data, _ = os.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("index has %d lines after a third commit, want 3", n)
+	}
+}
+
+func TestCommitIndex_RepairsAndFollowsHEAD(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	repo.CreateNode("note:c", "Note", []byte("c"), nil)
+	hist, _ := repo.Commits.History(10)
+
+	// A torn write leaves half a line; reopening rebuilds from the chain.
+	path := filepath.Join(repo.MxDir(), "commitidx")
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"cid":"bafy`)
+	f.Close()
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.Commits.History(10)
+	if err != nil || len(got) != 3 || got[0].CID != hist[0].CID {
+		t.Fatalf("rebuilt history = %+v, %v", got, err)
+	}
+
+	// HEAD moved back, as after a reset, trims the index.
+	head := filepath.Join(repo.MxDir(), "HEAD")
+	if err := os.WriteFile(head, []byte(hist[1].CID+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = reopened.Commits.History(10)
+	if len(got) != 2 || got[0].CID != hist[1].CID {
+		t.Errorf("history after reset = %+v", got)
+	}
+	if log, _, _ := reopened.Commits.Changelog("note:c"); len(log) != 0 {
+		t.Errorf("note:c changelog after reset = %+v", log)
+	}
+}
+
+func TestCommitLog_AtUsesIndex(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	first, _ := repo.Commits.History(1)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+
+	c, commit, err := repo.Commits.At(first[0].Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CIDToFilename(c) != first[0].CID || commit.Refs["note:b"] != "" {
+		t.Errorf("At(first) = %s with refs %v", CIDToFilename(c), commit.Refs)
+	}
+	if _, _, err := repo.Commits.At(first[0].Timestamp.Add(-time.Hour)); err == nil {
+		t.Error("At before the first commit should fail")
+	}
+}
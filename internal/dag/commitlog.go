@@ -1,9 +1,11 @@
 package dag
 
 import (
-	"encoding/json"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -12,16 +14,36 @@ import (
 	"github.com/multiformats/go-multibase"
 )
 
-// CommitLog manages the commit chain. HEAD is stored as a single-line file at .mx/HEAD.
+// CommitLog manages the commit chain. HEAD is stored as a single-line file at .mx/HEAD,
+// and a summary of the chain is indexed next to it in .mx/commitidx.
 type CommitLog struct {
 	headPath string
 	store    *ObjectStore
 	author   string // DID of the local identity, stamped on every commit
+	index    *commitIndex
+	signer   ed25519.PrivateKey // signs new commits when set; see openAppendOnly
+	boundary map[string]bool    // shallow commits: history stops at them
 }
 
 // NewCommitLog creates a CommitLog that reads/writes HEAD from headPath.
 func NewCommitLog(headPath string, store *ObjectStore, author string) *CommitLog {
-	return &CommitLog{headPath: headPath, store: store, author: author}
+	return &CommitLog{
+		headPath: headPath,
+		store:    store,
+		author:   author,
+		index:    &commitIndex{path: filepath.Join(filepath.Dir(headPath), "commitidx")},
+	}
+}
+
+// setBoundary makes history stop at the named commits, whose parents a
+// partial pull did not fetch.
+func (cl *CommitLog) setBoundary(cids []string) {
+	cl.index.mu.Lock()
+	defer cl.index.mu.Unlock()
+	cl.boundary = make(map[string]bool, len(cids))
+	for _, c := range cids {
+		cl.boundary[c] = true
+	}
 }
 
 // Head returns the CID of the current HEAD commit, or gocid.Undef if none.
@@ -47,6 +69,21 @@ func (cl *CommitLog) Head() (gocid.Cid, error) {
 // Commit creates a new commit object from the current state of refs and links.
 // Returns the CID of the new commit.
 func (cl *CommitLog) Commit(refs *RefStore, links *LinkIndex, message string) (gocid.Cid, error) {
+	return cl.CommitAs(cl.author, refs, links, message)
+}
+
+// CommitAs is Commit attributed to author. A signed commit must be by the
+// signing identity, so when commits are signed author is ignored.
+func (cl *CommitLog) CommitAs(author string, refs *RefStore, links *LinkIndex, message string) (gocid.Cid, error) {
+	return cl.commitMerge(author, "", refs, links, message)
+}
+
+// commitMerge is CommitAs recording merged, if set, as the commit's second
+// parent.
+func (cl *CommitLog) commitMerge(author, merged string, refs *RefStore, links *LinkIndex, message string) (gocid.Cid, error) {
+	if cl.signer != nil || author == "" {
+		author = cl.author
+	}
 	// 1. Snapshot refs: id → base32 CID
 	ids, err := refs.List()
 	if err != nil {
@@ -76,52 +113,62 @@ func (cl *CommitLog) Commit(refs *RefStore, links *LinkIndex, message string) (g
 
 	// 3. Read current HEAD as parent
 	parent := ""
+	var parentCommit *CommitObject
 	head, err := cl.Head()
 	if err == nil && head != gocid.Undef {
 		parent = CIDToFilename(head)
+		parentCommit, _ = cl.GetCommit(head)
 	}
 
 	// 4. Build commit object
 	commit := &CommitObject{
 		V:         1,
 		Parent:    parent,
-		Author:    cl.author,
+		Merged:    merged,
+		Author:    author,
 		Timestamp: time.Now().UTC(),
 		Refs:      refsMap,
 		Links:     allLinks,
 		Message:   message,
+		Changes:   cl.diffCommit(parentCommit, refsMap, allLinks),
 	}
 
-	// 5. Serialize and store
-	data, err := CanonicalJSON(commit)
-	if err != nil {
-		return gocid.Undef, fmt.Errorf("serialize commit: %w", err)
+	if cl.signer != nil {
+		payload, err := commit.signingPayload()
+		if err != nil {
+			return gocid.Undef, fmt.Errorf("serialize commit: %w", err)
+		}
+		commit.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(cl.signer, payload))
 	}
-	c, err := cl.store.Put(data)
+
+	// 5. Serialize and store
+	c, err := cl.store.PutCommit(commit)
 	if err != nil {
 		return gocid.Undef, fmt.Errorf("store commit: %w", err)
 	}
 
-	// 6. Update HEAD
-	encoded := CIDToFilename(c)
-	if err := SafeWrite(cl.headPath, []byte(encoded+"\n"), 0644); err != nil {
-		return gocid.Undef, fmt.Errorf("write HEAD: %w", err)
+	// 6. Update HEAD and index it
+	if err := cl.setHead(c); err != nil {
+		return gocid.Undef, err
 	}
-
 	return c, nil
 }
 
-// GetCommit reads and unmarshals a commit by CID.
-func (cl *CommitLog) GetCommit(c gocid.Cid) (*CommitObject, error) {
-	data, err := cl.store.Get(c)
-	if err != nil {
-		return nil, err
+// setHead points HEAD at c and brings the commit index up to date. The
+// index catches up on its next read if that fails.
+func (cl *CommitLog) setHead(c gocid.Cid) error {
+	if err := SafeWrite(cl.headPath, []byte(CIDToFilename(c)+"\n"), 0644); err != nil {
+		return fmt.Errorf("write HEAD: %w", err)
 	}
-	var commit CommitObject
-	if err := json.Unmarshal(data, &commit); err != nil {
-		return nil, fmt.Errorf("unmarshal commit: %w", err)
+	if _, err := cl.summaries(); err != nil {
+		fmt.Printf("memex-fs: commit index: %v\n", err)
 	}
-	return &commit, nil
+	return nil
+}
+
+// GetCommit reads and unmarshals a commit by CID.
+func (cl *CommitLog) GetCommit(c gocid.Cid) (*CommitObject, error) {
+	return cl.store.GetCommit(c)
 }
 
 // Resolve accepts either a base32 CID string or an RFC3339 timestamp and
@@ -130,86 +177,75 @@ func (cl *CommitLog) GetCommit(c gocid.Cid) (*CommitObject, error) {
 // Returns an error if nothing matches (e.g. timestamp is before the first
 // commit, or CID is unknown).
 func (cl *CommitLog) Resolve(key string) (*CommitObject, error) {
+	_, commit, err := cl.ResolveCID(key)
+	return commit, err
+}
+
+// ResolveCID is Resolve that also returns the commit's CID.
+func (cl *CommitLog) ResolveCID(key string) (gocid.Cid, *CommitObject, error) {
 	if t, err := time.Parse(time.RFC3339, key); err == nil {
-		return cl.resolveByTime(t)
+		return cl.At(t)
 	}
 	if t, err := time.Parse(time.RFC3339Nano, key); err == nil {
-		return cl.resolveByTime(t)
+		return cl.At(t)
 	}
-	return cl.resolveByCIDString(key)
-}
-
-func (cl *CommitLog) resolveByCIDString(key string) (*CommitObject, error) {
 	_, cidBytes, err := multibase.Decode(key)
 	if err != nil {
-		return nil, fmt.Errorf("not a valid CID or RFC3339 timestamp: %s", key)
+		return gocid.Undef, nil, errorOf(ErrNotFound, "not a valid CID or RFC3339 timestamp: %s", key)
 	}
 	c, err := gocid.Cast(cidBytes)
 	if err != nil {
-		return nil, fmt.Errorf("parse CID: %w", err)
+		return gocid.Undef, nil, fmt.Errorf("parse CID: %w", err)
+	}
+	commit, err := cl.GetCommit(c)
+	if err != nil {
+		return gocid.Undef, nil, err
 	}
-	return cl.GetCommit(c)
+	return c, commit, nil
 }
 
-// resolveByTime walks backwards from HEAD and returns the newest commit
-// whose Timestamp is at or before t.
-func (cl *CommitLog) resolveByTime(t time.Time) (*CommitObject, error) {
-	head, err := cl.Head()
-	if err != nil || head == gocid.Undef {
-		return nil, fmt.Errorf("no commits yet")
+// At returns the CID and body of the newest commit, walking back from
+// HEAD, whose Timestamp is at or before t.
+func (cl *CommitLog) At(t time.Time) (gocid.Cid, *CommitObject, error) {
+	entries, err := cl.summaries()
+	if err != nil || len(entries) == 0 {
+		return gocid.Undef, nil, errorOf(ErrNotFound, "no commits yet")
 	}
-	current := head
-	for current != gocid.Undef {
-		commit, err := cl.GetCommit(current)
-		if err != nil {
-			return nil, err
-		}
-		if !commit.Timestamp.After(t) {
-			return commit, nil
-		}
-		if commit.Parent == "" {
-			break
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Timestamp.After(t) {
+			continue
 		}
-		_, cidBytes, err := multibase.Decode(commit.Parent)
+		c, err := cidFromFilename(entries[i].CID)
 		if err != nil {
-			return nil, fmt.Errorf("decode parent CID: %w", err)
+			return gocid.Undef, nil, err
 		}
-		current, err = gocid.Cast(cidBytes)
+		commit, err := cl.GetCommit(c)
 		if err != nil {
-			return nil, fmt.Errorf("parse parent CID: %w", err)
+			return gocid.Undef, nil, err
 		}
+		return c, commit, nil
 	}
-	return nil, fmt.Errorf("no commit at or before %s", t.Format(time.RFC3339))
+	return gocid.Undef, nil, errorOf(ErrNotFound, "no commit at or before %s", t.Format(time.RFC3339))
 }
 
-// Log walks the parent chain from HEAD, returning up to n commits (newest first).
+// Log returns up to n commits from HEAD back (newest first). It loads
+// every commit in full; History is cheaper when summaries will do.
 func (cl *CommitLog) Log(n int) ([]CommitObject, error) {
-	head, err := cl.Head()
-	if err != nil || head == gocid.Undef {
+	entries, err := cl.History(n)
+	if err != nil {
 		return nil, err
 	}
-
-	var commits []CommitObject
-	current := head
-	for i := 0; i < n && current != gocid.Undef; i++ {
-		commit, err := cl.GetCommit(current)
+	commits := make([]CommitObject, 0, len(entries))
+	for _, s := range entries {
+		c, err := cidFromFilename(s.CID)
 		if err != nil {
 			break
 		}
-		commits = append(commits, *commit)
-
-		// Follow parent
-		if commit.Parent == "" {
-			break
-		}
-		_, cidBytes, err := multibase.Decode(commit.Parent)
-		if err != nil {
-			break
-		}
-		current, err = gocid.Cast(cidBytes)
+		commit, err := cl.GetCommit(c)
 		if err != nil {
 			break
 		}
+		commits = append(commits, *commit)
 	}
 	return commits, nil
 }
@@ -0,0 +1,155 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Config is the repo-local configuration at .mx/config.json. Every field is
+// optional; a missing file is the zero Config.
+type Config struct {
+	// MetaIndexes lists meta keys to keep in the MetaIndex for exact and
+	// range lookup. Schema nodes can declare more.
+	MetaIndexes []string `json:"meta_indexes,omitempty"`
+
+	// Retention caps the version history kept per node type: content
+	// updates to a node of a listed type keep only its last N prior
+	// versions, and 0 keeps none. Unlisted types keep everything, and so
+	// does protected history (see Repository.Protect).
+	Retention map[string]int `json:"retention,omitempty"`
+
+	// TrashRetentionDays purges a deleted node, with its whole version
+	// history, this many days after deletion; 0 keeps tombstones forever.
+	// A node's trash_retention_days meta overrides it.
+	TrashRetentionDays int `json:"trash_retention_days,omitempty"`
+
+	// AppendOnly puts the repository in a compliance mode for audited
+	// use: hard deletes, trash purges and Retention are refused, every
+	// commit is signed with the local identity, and the signed part of
+	// the commit chain is verified each time the repository is opened.
+	AppendOnly bool `json:"append_only,omitempty"`
+
+	// Webhooks receive a JSON POST for every node and link change.
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+
+	// Gateways are the public IPFS HTTP gateways pull reads through when
+	// Kubo is unreachable, e.g. "https://ipfs.io". Empty uses the
+	// defaults.
+	Gateways []string `json:"gateways,omitempty"`
+
+	// LLM is the language model behind Summary nodes and /ask/. Unset
+	// disables summaries, and /ask/ answers by retrieval alone.
+	LLM *LLMConfig `json:"llm,omitempty"`
+
+	// Extractors maps a Source format to a command that extracts its
+	// text for search, e.g. {"pdf": ["pdftotext", "-", "-"]}: the document
+	// arrives on stdin and the text is read from stdout. A listed format
+	// overrides the built-in extractor.
+	Extractors map[string][]string `json:"extractors,omitempty"`
+
+	// Subtrees maps a directory under the mount root to a command that
+	// serves it, e.g. {"weather": ["memex-weather"]}: the command is run
+	// with "ls" or "cat" and a path to list a directory or read a file.
+	Subtrees map[string][]string `json:"subtrees,omitempty"`
+
+	// OCR recognizes text in image nodes, stored as their ocr_text meta.
+	// Unset disables it.
+	OCR *OCRConfig `json:"ocr,omitempty"`
+
+	// Transcription turns audio nodes into linked Transcript nodes.
+	// Unset disables it.
+	Transcription *TranscriptionConfig `json:"transcription,omitempty"`
+
+	// Self is the Person node standing for the repository's owner, e.g.
+	// "person:me". Contact import links it KNOWS each imported person.
+	Self string `json:"self,omitempty"`
+
+	// SummarizeMinBytes summarizes new nodes automatically once their
+	// content is at least this long; 0 summarizes only on request.
+	SummarizeMinBytes int `json:"summarize_min_bytes,omitempty"`
+
+	// Users maps local user IDs, as decimal strings, to DIDs, for a repo
+	// mounted for several people on one server: writes through the mount
+	// by a listed user are attributed to their DID in node versions and
+	// commits. Unlisted users, and the repo's own tools, write as the
+	// local identity. Signed (append-only) commits stay by the identity.
+	Users map[string]string `json:"users,omitempty"`
+
+	// Schedule maps a maintenance job to the cron spec it runs at while
+	// mounted, e.g. {"gc": "0 3 * * *", "backup": "@weekly"}; see
+	// ScheduledJobs for the jobs.
+	Schedule map[string]string `json:"schedule,omitempty"`
+
+	// Backup is where the backup job writes archives of the repository.
+	Backup *BackupConfig `json:"backup,omitempty"`
+
+	// Memory bounds the in-memory indexes, for large repositories on
+	// small devices. Unset leaves them unbounded.
+	Memory *MemoryConfig `json:"memory,omitempty"`
+
+	// Profile tunes the repository for the device it runs on: "" is the
+	// default, and "low-memory" (ProfileLowMemory) suits Raspberry
+	// Pi-class devices. The mount command's --profile overrides it.
+	Profile string `json:"profile,omitempty"`
+}
+
+// BackupConfig configures Backup.
+type BackupConfig struct {
+	Dir  string `json:"dir"`
+	Keep int    `json:"keep,omitempty"` // newest archives kept; 0 keeps all
+}
+
+// MemoryConfig bounds the in-memory indexes, in megabytes of their
+// estimated size; 0 leaves an index unbounded. /stats/memory shows what
+// each uses.
+type MemoryConfig struct {
+	// SearchMB is the search postings kept in memory; beyond it the
+	// largest move to .mx/search-spill/ and are read from disk.
+	SearchMB int `json:"search_mb,omitempty"`
+	// CoIndexMB bounds each of the co-access and co-change indexes;
+	// beyond it their weakest pairs are forgotten.
+	CoIndexMB int `json:"co_index_mb,omitempty"`
+}
+
+// UserDID returns the DID uid's writes are attributed to, or "" if uid
+// is not listed in Users.
+func (c *Config) UserDID(uid uint32) string {
+	return c.Users[strconv.FormatUint(uint64(uid), 10)]
+}
+
+// LoadConfig reads the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	for uid, did := range cfg.Users {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return nil, fmt.Errorf("config users: %q is not a uid", uid)
+		}
+		if _, err := DecodeDIDKey(did); err != nil {
+			return nil, fmt.Errorf("config users: uid %s: %w", uid, err)
+		}
+	}
+	for job, spec := range cfg.Schedule {
+		if _, ok := scheduledJobs[job]; !ok {
+			return nil, fmt.Errorf("config schedule: unknown job %q", job)
+		}
+		if _, err := parseCron(spec); err != nil {
+			return nil, fmt.Errorf("config schedule: %s: %w", job, err)
+		}
+	}
+	if err := checkProfile(cfg.Profile); err != nil {
+		return nil, fmt.Errorf("config profile: %w", err)
+	}
+	return cfg, nil
+}
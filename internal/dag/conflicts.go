@@ -0,0 +1,370 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// Conflict is a node both sides of a merge changed in different ways. Our
+// version stays live until the conflict is resolved; the three versions are
+// kept by CID.
+type Conflict struct {
+	ID     string `json:"id"`
+	Base   string `json:"base,omitempty"` // "" when the node is new on both sides
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+	Merged string `json:"merged"` // the commit merged in
+}
+
+// MergeResult is what MergeCommit did.
+type MergeResult struct {
+	Base      string   // common ancestor's CID, "" if none is held locally
+	Updated   []string // nodes taken from their side, sorted
+	Conflicts []string // nodes left in conflict, sorted
+}
+
+func (r *Repository) conflictsPath() string {
+	return filepath.Join(r.MxDir(), "conflicts.json")
+}
+
+func (r *Repository) loadConflicts() (map[string]*Conflict, error) {
+	out := make(map[string]*Conflict)
+	data, err := os.ReadFile(r.conflictsPath())
+	if os.IsNotExist(err) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read conflicts: %w", err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("parse conflicts: %w", err)
+	}
+	return out, nil
+}
+
+func (r *Repository) saveConflicts(conflicts map[string]*Conflict) error {
+	if len(conflicts) == 0 {
+		if err := os.Remove(r.conflictsPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(r.conflictsPath(), data, 0644)
+}
+
+// Conflicts returns the unresolved merge conflicts, by node ID.
+func (r *Repository) Conflicts() ([]*Conflict, error) {
+	conflicts, err := r.loadConflicts()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Conflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// GetConflict returns the unresolved conflict on id, or nil.
+func (r *Repository) GetConflict(id string) (*Conflict, error) {
+	conflicts, err := r.loadConflicts()
+	if err != nil {
+		return nil, err
+	}
+	return conflicts[id], nil
+}
+
+// ConflictVersion loads one side of a conflict by its CID, or returns nil
+// for a side that does not exist.
+func (r *Repository) ConflictVersion(name string) *NodeEnvelope {
+	return r.Commits.envelope(name)
+}
+
+// MergeCommit merges the pulled commit theirs into the working state, node
+// by node, against the nearest ancestor both histories share: a node only
+// they changed takes their version, and a node both sides changed
+// differently is recorded as a Conflict and keeps ours. A change wins over
+// a deletion. Links are merged as sets, applying what they added and
+// removed. The result is committed as one merge commit.
+func (r *Repository) MergeCommit(theirs gocid.Cid) (*MergeResult, error) {
+	return r.MergeCommitFor(theirs, nil)
+}
+
+// MergeCommitFor is MergeCommit for a remote client holding c. The
+// changes the merge would make to the working state are first checked
+// against its ACLs, as CheckACLs checks a commit's against its parent's,
+// with c's holder as the author; if any is not allowed, nothing is merged
+// and the violations are returned as an *ACLError. A nil c is the local
+// user, who may merge anything.
+func (r *Repository) MergeCommitFor(theirs gocid.Cid, c *Capability) (*MergeResult, error) {
+	r.shared.conflictsMu.Lock()
+	defer r.shared.conflictsMu.Unlock()
+	plan, err := r.planMerge(theirs)
+	if err != nil {
+		return nil, err
+	}
+	result := &MergeResult{Base: plan.base}
+	if plan.base == CIDToFilename(theirs) {
+		return result, nil // already merged
+	}
+	if c != nil {
+		if v := r.commitACLViolations(CIDToFilename(theirs), c.Holder, plan.ours, plan.merged()); len(v) > 0 {
+			return nil, &ACLError{Violations: v}
+		}
+	}
+
+	conflicts, err := r.loadConflicts()
+	if err != nil {
+		return nil, err
+	}
+	for _, cf := range plan.conflicts {
+		conflicts[cf.ID] = cf
+		result.Conflicts = append(result.Conflicts, cf.ID)
+	}
+	for _, id := range plan.order {
+		if err := r.takeVersion(id, plan.take[id], r.Commits.envelope(plan.take[id])); err != nil {
+			return nil, err
+		}
+		result.Updated = append(result.Updated, id)
+	}
+	for _, l := range plan.addLinks {
+		if err := r.Links.Add(l); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range plan.removeLinks {
+		if _, err := r.Links.Remove(l); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.saveConflicts(conflicts); err != nil {
+		return nil, fmt.Errorf("save conflicts: %w", err)
+	}
+	msg := "merge " + CIDToFilename(theirs)
+	if n := len(result.Conflicts); n > 0 {
+		msg += fmt.Sprintf(" (%d conflicts)", n)
+	}
+	r.commitMerge(msg, CIDToFilename(theirs))
+	return result, nil
+}
+
+// mergePlan is what merging a commit into the working state would do.
+type mergePlan struct {
+	base      string            // merge base's CID, "" if none is held locally
+	ours      *CommitObject     // HEAD, which the working state matches
+	take      map[string]string // node → their version to take
+	order     []string          // the nodes in take, sorted
+	conflicts []*Conflict       // sorted by node
+	// addLinks and removeLinks are the links they added and removed
+	// since the base.
+	addLinks, removeLinks []LinkEntry
+}
+
+// planMerge works out what MergeCommit would do with theirs. The caller
+// holds conflictsMu.
+func (r *Repository) planMerge(theirs gocid.Cid) (*mergePlan, error) {
+	oursHead, err := r.Commits.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !oursHead.Defined() {
+		return nil, fmt.Errorf("nothing to merge into; check the commit out instead")
+	}
+	ours, err := r.Commits.GetCommit(oursHead)
+	if err != nil {
+		return nil, fmt.Errorf("load HEAD: %w", err)
+	}
+	their, err := r.Commits.GetCommit(theirs)
+	if err != nil {
+		return nil, fmt.Errorf("load commit to merge: %w", err)
+	}
+	plan := &mergePlan{base: r.mergeBase(oursHead, theirs), ours: ours, take: make(map[string]string)}
+	if plan.base == CIDToFilename(theirs) {
+		return plan, nil
+	}
+	base := &CommitObject{}
+	if plan.base != "" {
+		c, _ := cidFromFilename(plan.base)
+		if base, err = r.Commits.GetCommit(c); err != nil {
+			return nil, fmt.Errorf("load merge base: %w", err)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for id := range their.Refs {
+		ids[id] = true
+	}
+	for id := range ours.Refs {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		b, o, t := base.Refs[id], ours.Refs[id], their.Refs[id]
+		if t == "" || o == t || t == b {
+			continue
+		}
+		theirNode := r.Commits.envelope(t)
+		if theirNode == nil {
+			return nil, fmt.Errorf("merge %s: version %s not in the store", id, t)
+		}
+		ourNode := r.Commits.envelope(o)
+		if o != b && ourNode != nil && !ourNode.Deleted && !theirNode.Deleted {
+			plan.conflicts = append(plan.conflicts, &Conflict{ID: id, Base: b, Ours: o, Theirs: t, Merged: CIDToFilename(theirs)})
+			continue
+		}
+		if o != b && theirNode.Deleted {
+			continue // ours changed what they deleted
+		}
+		plan.take[id] = t
+		plan.order = append(plan.order, id)
+	}
+
+	inBase := make(map[LinkEntry]bool, len(base.Links))
+	for _, l := range base.Links {
+		inBase[l] = true
+	}
+	inTheirs := make(map[LinkEntry]bool, len(their.Links))
+	for _, l := range their.Links {
+		inTheirs[l] = true
+		if !inBase[l] {
+			plan.addLinks = append(plan.addLinks, l)
+		}
+	}
+	for _, l := range base.Links {
+		if !inTheirs[l] {
+			plan.removeLinks = append(plan.removeLinks, l)
+		}
+	}
+	return plan, nil
+}
+
+// merged returns the state the plan leaves, as a commit over ours.
+func (p *mergePlan) merged() *CommitObject {
+	out := &CommitObject{Refs: make(map[string]string, len(p.ours.Refs))}
+	for id, v := range p.ours.Refs {
+		out.Refs[id] = v
+	}
+	for id, v := range p.take {
+		out.Refs[id] = v
+	}
+	removed := make(map[LinkEntry]bool, len(p.removeLinks))
+	for _, l := range p.removeLinks {
+		removed[l] = true
+	}
+	have := make(map[LinkEntry]bool, len(p.ours.Links))
+	for _, l := range p.ours.Links {
+		have[l] = true
+		if !removed[l] {
+			out.Links = append(out.Links, l)
+		}
+	}
+	for _, l := range p.addLinks {
+		if !have[l] {
+			out.Links = append(out.Links, l)
+		}
+	}
+	return out
+}
+
+// takeVersion points id's ref at the version name and reindexes it.
+func (r *Repository) takeVersion(id, name string, node *NodeEnvelope) error {
+	c, err := cidFromFilename(name)
+	if err != nil {
+		return err
+	}
+	unlock := r.lockNode(id)
+	defer unlock()
+	if err := r.Refs.Set(id, c); err != nil {
+		return fmt.Errorf("set ref: %w", err)
+	}
+	r.unindexNode(id)
+	if !node.Deleted {
+		r.indexNode(id, node)
+	}
+	return nil
+}
+
+// mergeBase returns the newest commit in theirs' history that is also in
+// ours', following merge commits' second parents, or "" if none is held
+// locally.
+func (r *Repository) mergeBase(ours, theirs gocid.Cid) string {
+	seen := r.ancestors(ours)
+	for c := theirs; c.Defined() && r.Store.Has(c); {
+		name := CIDToFilename(c)
+		if seen[name] {
+			return name
+		}
+		commit, err := r.Commits.GetCommit(c)
+		if err != nil || commit.Parent == "" {
+			break
+		}
+		if c, err = cidFromFilename(commit.Parent); err != nil {
+			break
+		}
+	}
+	return ""
+}
+
+// ancestors returns the commits in head's history the store holds, head
+// included, following merge commits' second parents, by CID filename.
+func (r *Repository) ancestors(head gocid.Cid) map[string]bool {
+	seen := make(map[string]bool)
+	queue := []gocid.Cid{head}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		name := CIDToFilename(c)
+		if seen[name] || !r.Store.Has(c) {
+			continue
+		}
+		seen[name] = true
+		commit, err := r.Commits.GetCommit(c)
+		if err != nil {
+			continue
+		}
+		for _, p := range []string{commit.Parent, commit.Merged} {
+			if pc, err := cidFromFilename(p); p != "" && err == nil {
+				queue = append(queue, pc)
+			}
+		}
+	}
+	return seen
+}
+
+// ResolveConflict settles the conflict on id with content, written as a
+// new version of the node over ours, and clears it.
+func (r *Repository) ResolveConflict(id string, content []byte) (*NodeEnvelope, error) {
+	r.shared.conflictsMu.Lock()
+	defer r.shared.conflictsMu.Unlock()
+	conflicts, err := r.loadConflicts()
+	if err != nil {
+		return nil, err
+	}
+	if conflicts[id] == nil {
+		return nil, fmt.Errorf("no conflict on %s", id)
+	}
+	node, err := r.UpdateContent(id, content)
+	if err != nil {
+		return nil, err
+	}
+	delete(conflicts, id)
+	if err := r.saveConflicts(conflicts); err != nil {
+		return nil, fmt.Errorf("save conflicts: %w", err)
+	}
+	return node, nil
+}
@@ -0,0 +1,376 @@
+package dag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// LinkKnows links the repository owner's Person node (Config.Self) to each
+// person imported from their contacts.
+const LinkKnows = "KNOWS"
+
+// Contact is one vCard read from a contacts file.
+type Contact struct {
+	UID      string
+	Name     string
+	Emails   []string // normalized by NormalizeEmail
+	URLs     []string // normalized by NormalizeURL
+	Phones   []string
+	Org      string
+	Title    string
+	Birthday string
+	Note     string
+}
+
+// ParseVCard reads the contacts of a vCard (3.0 or 4.0) file. The name is
+// FN, or the given and family names of N when FN is missing.
+func ParseVCard(r io.Reader) ([]Contact, error) {
+	lines, err := unfoldICS(r) // vCard shares iCalendar's line format
+	if err != nil {
+		return nil, fmt.Errorf("read vcard: %w", err)
+	}
+	var contacts []Contact
+	var cur *Contact
+	var n string
+	for _, line := range lines {
+		name, _, value := splitICSLine(line)
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:] // grouped, as in "item1.EMAIL"
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			cur, n = &Contact{}, ""
+			continue
+		case cur == nil:
+			continue
+		case name == "END" && strings.EqualFold(value, "VCARD"):
+			if cur.Name == "" && n != "" {
+				cur.Name = nameFromN(n)
+			}
+			contacts = append(contacts, *cur)
+			cur = nil
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "FN":
+			cur.Name = strings.TrimSpace(unescapeICS(value))
+		case "N":
+			n = value
+		case "EMAIL":
+			if email := NormalizeEmail(unescapeICS(value)); email != "" {
+				cur.Emails = appendNew(cur.Emails, email)
+			}
+		case "URL":
+			if u := NormalizeURL(unescapeICS(value)); u != "" {
+				cur.URLs = appendNew(cur.URLs, u)
+			}
+		case "TEL":
+			if tel := strings.TrimSpace(strings.TrimPrefix(value, "tel:")); tel != "" {
+				cur.Phones = appendNew(cur.Phones, tel)
+			}
+		case "ORG":
+			org, _, _ := strings.Cut(value, ";") // the organization, not its units
+			cur.Org = strings.TrimSpace(unescapeICS(org))
+		case "TITLE":
+			cur.Title = unescapeICS(value)
+		case "BDAY":
+			cur.Birthday = value
+		case "NOTE":
+			cur.Note = unescapeICS(value)
+		}
+	}
+	return contacts, nil
+}
+
+// nameFromN turns an N value ("Family;Given;Additional;Prefix;Suffix")
+// into "Given Family".
+func nameFromN(n string) string {
+	parts := strings.Split(n, ";")
+	var given string
+	if len(parts) > 1 {
+		given = parts[1]
+	}
+	return strings.TrimSpace(unescapeICS(given) + " " + unescapeICS(parts[0]))
+}
+
+// NormalizeEmail lower-cases an address and strips a "mailto:" prefix, so
+// the same address written two ways matches. Anything without an @ is
+// not an address and yields "".
+func NormalizeEmail(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "mailto:")
+	if !strings.Contains(s, "@") {
+		return ""
+	}
+	return s
+}
+
+// NormalizeURL gives a URL a scheme (https when it has none), lower-cases
+// its scheme and host, and drops a bare trailing slash. Unparseable
+// values yield "".
+func NormalizeURL(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	return u.String()
+}
+
+func appendNew(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// Slug derives the readable part of a new node's ID from a name or text:
+// lower-cased letters and digits, other runs turned into "-".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(name) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// contactIndex finds the Person node a contact belongs to.
+type contactIndex struct {
+	byEmail map[string][]string
+	byUID   map[string]string
+}
+
+func (r *Repository) contactIndex() *contactIndex {
+	idx := &contactIndex{byEmail: r.personsByEmail(), byUID: make(map[string]string)}
+	for _, id := range r.Search.FilterByType(PersonType, 0) {
+		if node, err := r.GetNode(id); err == nil {
+			if uid, _ := node.Meta["vcard_uid"].(string); uid != "" {
+				idx.byUID[uid] = id
+			}
+		}
+	}
+	return idx
+}
+
+// find returns the ID of the live Person node c merges into, or "" if it
+// is new. A person sharing an email matches first, then one imported from
+// the same vCard UID, then one whose ID is c's slug and whose name, if it
+// has one, is c's.
+func (idx *contactIndex) find(r *Repository, c Contact) string {
+	for _, email := range c.Emails {
+		if ids := idx.byEmail[email]; len(ids) > 0 {
+			return ids[0]
+		}
+	}
+	if id := idx.byUID[c.UID]; c.UID != "" && id != "" {
+		return id
+	}
+	if slug := Slug(c.Name); slug != "" {
+		node, err := r.GetNode("person:" + slug)
+		if err == nil && node.Type == PersonType {
+			if name, _ := node.Meta["name"].(string); name == "" || strings.EqualFold(name, c.Name) {
+				return node.ID
+			}
+		}
+	}
+	return ""
+}
+
+func (idx *contactIndex) add(id string, c Contact) {
+	for _, email := range c.Emails {
+		if !slices.Contains(idx.byEmail[email], id) {
+			idx.byEmail[email] = append(idx.byEmail[email], id)
+		}
+	}
+	if c.UID != "" {
+		idx.byUID[c.UID] = id
+	}
+}
+
+// newPersonID picks an unused ID for c: "person:" and its name's slug
+// (or, nameless, its first email's), numbered when taken.
+func (r *Repository) newPersonID(c Contact) string {
+	slug := Slug(c.Name)
+	if slug == "" && len(c.Emails) > 0 {
+		slug = Slug(c.Emails[0][:strings.IndexByte(c.Emails[0], '@')])
+	}
+	if slug == "" {
+		sum := sha256.Sum256([]byte(c.UID + "\x00" + strings.Join(c.Phones, ",")))
+		slug = hex.EncodeToString(sum[:4])
+	}
+	id := "person:" + slug
+	for n := 2; r.Refs.Has(id); n++ {
+		id = fmt.Sprintf("person:%s-%d", slug, n)
+	}
+	return id
+}
+
+// contactMeta returns the meta a new Person node records for c.
+func contactMeta(c Contact) map[string]interface{} {
+	meta := make(map[string]interface{})
+	for k, v := range map[string]string{
+		"name": c.Name, "org": c.Org, "title": c.Title, "birthday": c.Birthday, "vcard_uid": c.UID,
+	} {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	for k, v := range map[string][]string{"emails": c.Emails, "urls": c.URLs, "phones": c.Phones} {
+		if len(v) > 0 {
+			meta[k] = toInterfaces(v)
+		}
+	}
+	return meta
+}
+
+func toInterfaces(list []string) []interface{} {
+	out := make([]interface{}, len(list))
+	for i, s := range list {
+		out[i] = s
+	}
+	return out
+}
+
+// mergeContactMeta returns the meta updates that fold c into node: its
+// emails, urls and phones are added to the node's, and its other fields
+// fill in what the node lacks. What the node already says is kept.
+func mergeContactMeta(node *NodeEnvelope, c Contact) map[string]interface{} {
+	updates := make(map[string]interface{})
+	for k, v := range map[string]string{
+		"name": c.Name, "org": c.Org, "title": c.Title, "birthday": c.Birthday, "vcard_uid": c.UID,
+	} {
+		if cur, _ := node.Meta[k].(string); cur == "" && v != "" {
+			updates[k] = v
+		}
+	}
+	for k, v := range map[string][]string{"emails": c.Emails, "urls": c.URLs, "phones": c.Phones} {
+		merged := metaStrings(node.Meta[k])
+		if k == "emails" {
+			// A hand-written single "email" joins the list.
+			for _, e := range metaStrings(node.Meta["email"]) {
+				if e = NormalizeEmail(e); e != "" {
+					merged = appendNew(merged, e)
+				}
+			}
+		}
+		for _, s := range v {
+			merged = appendNew(merged, s)
+		}
+		if len(merged) > 0 && !reflect.DeepEqual(toInterfaces(merged), node.Meta[k]) {
+			updates[k] = toInterfaces(merged)
+		}
+	}
+	return updates
+}
+
+// ImportContacts merges contacts into the Person nodes of the graph: a
+// contact matching an existing person (by email, then by vCard UID, then
+// by name) fills in and extends that node, and any other becomes a new
+// person:{name} node with its note as content. When Config.Self names a
+// Person node, it is linked KNOWS to every imported person. New people
+// and links are committed at once; if ctx is cancelled midway, what was
+// imported so far is committed and ctx's error returned.
+func (r *Repository) ImportContacts(ctx context.Context, contacts []Contact) (ImportStats, error) {
+	var stats ImportStats
+	self := r.Config.Self
+	if self != "" {
+		if node, err := r.GetNode(self); err != nil {
+			return stats, fmt.Errorf("self node %s: %w", self, err)
+		} else if node.Type != PersonType {
+			return stats, fmt.Errorf("self node %s is a %s, not a %s", self, node.Type, PersonType)
+		}
+	}
+	idx := r.contactIndex()
+	var created []*NodeEnvelope
+	var links []LinkEntry
+	var err error
+	for _, c := range contacts {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		id := idx.find(r, c)
+		if id == "" {
+			id = r.newPersonID(c)
+		}
+
+		unlock := r.lockNode(id)
+		var node *NodeEnvelope
+		if cur, gerr := r.getNodeEnvelope(id); gerr == nil && !cur.Deleted {
+			updates := mergeContactMeta(cur, c)
+			content := cur.Content
+			if len(content) == 0 {
+				content = []byte(c.Note)
+			}
+			if len(updates) == 0 && len(content) == len(cur.Content) {
+				stats.Skipped++
+			} else if _, err = r.updateContent(id, content, updates); err == nil {
+				stats.Updated++
+			}
+		} else {
+			node, err = r.storeNewNode(id, PersonType, []byte(c.Note), contactMeta(c))
+		}
+		unlock()
+		if err != nil {
+			break
+		}
+		if node != nil {
+			stats.Created++
+			created = append(created, node)
+		}
+		idx.add(id, c)
+
+		if self == "" || self == id {
+			continue
+		}
+		link := LinkEntry{Source: self, Target: id, Type: LinkKnows}
+		if slices.Contains(r.Links.LinksFrom(self), link) {
+			continue
+		}
+		if err = r.Links.Add(link); err != nil {
+			break
+		}
+		links = append(links, link)
+		stats.Linked++
+	}
+	if stats.Created > 0 || len(links) > 0 {
+		r.commit(fmt.Sprintf("import %d contacts (%d merged, %d links)", stats.Created, stats.Updated, len(links)))
+		for _, node := range created {
+			r.afterCreate(node)
+		}
+		for i := range links {
+			r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &links[i]})
+		}
+	}
+	return stats, err
+}
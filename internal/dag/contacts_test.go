@@ -0,0 +1,105 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testVCF = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:c-ann\r\n" +
+	"FN:Ann Lee\r\n" +
+	"EMAIL;TYPE=work:Ann.Lee@Example.com\r\n" +
+	"item1.EMAIL:mailto:ann@home.example\r\n" +
+	"URL:Example.com/\r\n" +
+	"ORG:Acme\\, Inc.;Research\r\n" +
+	"NOTE:Met at the\r\n" +
+	"  workshop\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"N:Stone;Bob;;;\r\n" +
+	"TEL;TYPE=cell:tel:+1-555-0100\r\n" +
+	"END:VCARD\r\n"
+
+func TestParseVCard(t *testing.T) {
+	contacts, err := ParseVCard(strings.NewReader(testVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("contacts = %+v", contacts)
+	}
+	ann := contacts[0]
+	if ann.Name != "Ann Lee" || ann.Org != "Acme, Inc." || ann.Note != "Met at the workshop" {
+		t.Errorf("ann = %+v", ann)
+	}
+	if !reflect.DeepEqual(ann.Emails, []string{"ann.lee@example.com", "ann@home.example"}) ||
+		!reflect.DeepEqual(ann.URLs, []string{"https://example.com"}) {
+		t.Errorf("ann emails, urls = %q, %q", ann.Emails, ann.URLs)
+	}
+	if bob := contacts[1]; bob.Name != "Bob Stone" || !reflect.DeepEqual(bob.Phones, []string{"+1-555-0100"}) {
+		t.Errorf("bob = %+v", bob)
+	}
+}
+
+func TestImportContacts(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("person:me", PersonType, nil, nil)
+	repo.CreateNode("person:annie", PersonType, []byte("colleague"), map[string]interface{}{
+		"name": "Annie", "email": "ANN.LEE@example.com",
+	})
+	repo.Config.Self = "person:me"
+	contacts, _ := ParseVCard(strings.NewReader(testVCF))
+
+	stats, err := repo.ImportContacts(t.Context(), contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Created: 1, Updated: 1, Linked: 2}) {
+		t.Errorf("stats = %+v", stats)
+	}
+	annie, _ := repo.GetNode("person:annie")
+	if annie.Meta["name"] != "Annie" || string(annie.Content) != "colleague" || annie.Meta["org"] != "Acme, Inc." {
+		t.Errorf("merged node = %q %v", annie.Content, annie.Meta)
+	}
+	if got := annie.Meta["emails"]; !reflect.DeepEqual(got, []interface{}{"ann.lee@example.com", "ann@home.example"}) {
+		t.Errorf("merged emails = %v", got)
+	}
+	bob, err := repo.GetNode("person:bob-stone")
+	if err != nil || bob.Meta["name"] != "Bob Stone" {
+		t.Fatalf("bob = %v, %v", bob, err)
+	}
+	var known []string
+	for _, l := range repo.Links.LinksFrom("person:me") {
+		if l.Type == LinkKnows {
+			known = append(known, l.Target)
+		}
+	}
+	if !reflect.DeepEqual(known, []string{"person:annie", "person:bob-stone"}) {
+		t.Errorf("KNOWS = %v", known)
+	}
+
+	// Importing again finds everyone: Ann by email, Bob by name.
+	stats, err = repo.ImportContacts(t.Context(), contacts)
+	if err != nil || stats != (ImportStats{Skipped: 2}) {
+		t.Errorf("re-import = %+v, %v", stats, err)
+	}
+
+	repo.Config.Self = "person:nobody"
+	if _, err := repo.ImportContacts(t.Context(), contacts); err == nil {
+		t.Error("import with a missing self node succeeded")
+	}
+}
+
+func TestImportEvents_MatchesImportedContacts(t *testing.T) {
+	repo := openTestRepo(t)
+	contacts, _ := ParseVCard(strings.NewReader(testVCF))
+	repo.ImportContacts(t.Context(), contacts)
+	events, _ := ParseICS(strings.NewReader("BEGIN:VEVENT\nUID:x\nDTSTART:20250101T100000Z\n" +
+		"ATTENDEE:mailto:ann@home.example\nEND:VEVENT\n"))
+	if stats, _ := repo.ImportEvents(t.Context(), events); stats.Linked != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
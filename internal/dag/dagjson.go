@@ -0,0 +1,235 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// Repositories whose ObjectFormat codec is "dag-json" store node versions
+// and commits as IPLD DAG-JSON, so IPFS can traverse them once pushed
+// (`ipfs dag get <cid>/prev/content`):
+//
+//   - a node's prev and a commit's parent are links, {"/": "<cid>"};
+//   - each value of a commit's refs is a link to the node version;
+//   - a node's content is a raw block of its own, linked as content, so
+//     replicating a node's history need not fetch every old body.
+//
+// Everything else is encoded as CanonicalJSON would. Readers accept both
+// encodings whatever the repository's current format: DecodeNode and
+// DecodeCommit turn either into the plain structs.
+
+// linkMarker is how every DAG-JSON link starts. Plain JSON cannot contain
+// it outside a string, where the quotes would be escaped.
+var linkMarker = []byte(`{"/":`)
+
+func linkTo(name string) map[string]interface{} {
+	return map[string]interface{}{"/": name}
+}
+
+// linkTarget returns the CID a DAG-JSON link names.
+func linkTarget(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	s, ok := m["/"].(string)
+	return s, ok
+}
+
+// toRaw re-decodes v's JSON form into generic maps for rewriting.
+func toRaw(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// fromRaw decodes rewritten generic maps into v.
+func fromRaw(raw map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// DecodeNode decodes a stored node version in either encoding. A linked
+// content block is read through blob; with a nil blob, Content is left
+// empty.
+func DecodeNode(data []byte, blob func(gocid.Cid) ([]byte, error)) (*NodeEnvelope, error) {
+	var node NodeEnvelope
+	if !bytes.Contains(data, linkMarker) {
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("unmarshal node: %w", err)
+		}
+		return &node, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal node: %w", err)
+	}
+	if prev, ok := linkTarget(raw["prev"]); ok {
+		raw["prev"] = prev
+	}
+	if name, ok := linkTarget(raw["content"]); ok {
+		delete(raw, "content")
+		if blob != nil {
+			c, err := cidFromFilename(name)
+			if err != nil {
+				return nil, err
+			}
+			content, err := blob(c)
+			if err != nil {
+				return nil, fmt.Errorf("read content: %w", err)
+			}
+			raw["content"] = base64.StdEncoding.EncodeToString(content)
+		}
+	}
+	if err := fromRaw(raw, &node); err != nil {
+		return nil, fmt.Errorf("unmarshal node: %w", err)
+	}
+	return &node, nil
+}
+
+// ContentLink returns the content block a stored node version links to,
+// if it is DAG-JSON with content.
+func ContentLink(data []byte) (gocid.Cid, bool) {
+	if !bytes.Contains(data, linkMarker) {
+		return gocid.Undef, false
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return gocid.Undef, false
+	}
+	name, ok := linkTarget(raw["content"])
+	if !ok {
+		return gocid.Undef, false
+	}
+	c, err := cidFromFilename(name)
+	return c, err == nil
+}
+
+// DecodeCommit decodes a stored commit in either encoding.
+func DecodeCommit(data []byte) (*CommitObject, error) {
+	var commit CommitObject
+	if !bytes.Contains(data, linkMarker) {
+		if err := json.Unmarshal(data, &commit); err != nil {
+			return nil, fmt.Errorf("unmarshal commit: %w", err)
+		}
+		return &commit, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal commit: %w", err)
+	}
+	for _, key := range []string{"parent", "merged"} {
+		if parent, ok := linkTarget(raw[key]); ok {
+			raw[key] = parent
+		}
+	}
+	if refs, ok := raw["refs"].(map[string]interface{}); ok {
+		for id, v := range refs {
+			if name, ok := linkTarget(v); ok {
+				refs[id] = name
+			}
+		}
+	}
+	if err := fromRaw(raw, &commit); err != nil {
+		return nil, fmt.Errorf("unmarshal commit: %w", err)
+	}
+	return &commit, nil
+}
+
+// PutNode stores a node version in the store's format.
+func (s *ObjectStore) PutNode(node *NodeEnvelope) (gocid.Cid, error) {
+	if s.prefix.Codec != gocid.DagJSON {
+		data, err := CanonicalJSON(node)
+		if err != nil {
+			return gocid.Undef, fmt.Errorf("serialize node: %w", err)
+		}
+		return s.Put(data)
+	}
+	raw, err := toRaw(node)
+	if err != nil {
+		return gocid.Undef, fmt.Errorf("serialize node: %w", err)
+	}
+	if node.Prev != "" {
+		raw["prev"] = linkTo(node.Prev)
+	}
+	if len(node.Content) > 0 {
+		c, err := s.Put(node.Content)
+		if err != nil {
+			return gocid.Undef, fmt.Errorf("store content: %w", err)
+		}
+		raw["content"] = linkTo(CIDToFilename(c))
+	}
+	return s.putDagJSON(raw)
+}
+
+// PutCommit stores a commit in the store's format.
+func (s *ObjectStore) PutCommit(commit *CommitObject) (gocid.Cid, error) {
+	if s.prefix.Codec != gocid.DagJSON {
+		data, err := CanonicalJSON(commit)
+		if err != nil {
+			return gocid.Undef, fmt.Errorf("serialize commit: %w", err)
+		}
+		return s.Put(data)
+	}
+	raw, err := toRaw(commit)
+	if err != nil {
+		return gocid.Undef, fmt.Errorf("serialize commit: %w", err)
+	}
+	if commit.Parent != "" {
+		raw["parent"] = linkTo(commit.Parent)
+	}
+	if commit.Merged != "" {
+		raw["merged"] = linkTo(commit.Merged)
+	}
+	refs := make(map[string]interface{}, len(commit.Refs))
+	for id, name := range commit.Refs {
+		refs[id] = linkTo(name)
+	}
+	raw["refs"] = refs
+	return s.putDagJSON(raw)
+}
+
+// putDagJSON encodes raw with sorted keys and no whitespace, as DAG-JSON
+// requires, and stores it under a dag-json CID.
+func (s *ObjectStore) putDagJSON(raw map[string]interface{}) (gocid.Cid, error) {
+	data, err := canonicalEncode(raw)
+	if err != nil {
+		return gocid.Undef, err
+	}
+	c, err := s.prefix.Sum(data)
+	if err != nil {
+		return gocid.Undef, fmt.Errorf("multihash: %w", err)
+	}
+	return c, s.write(c, data)
+}
+
+// GetNode reads and decodes the node version stored at c.
+func (s *ObjectStore) GetNode(c gocid.Cid) (*NodeEnvelope, error) {
+	data, err := s.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeNode(data, s.Get)
+}
+
+// GetCommit reads and decodes the commit stored at c.
+func (s *ObjectStore) GetCommit(c gocid.Cid) (*CommitObject, error) {
+	data, err := s.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeCommit(data)
+}
@@ -0,0 +1,122 @@
+package dag
+
+import (
+	"bytes"
+	"testing"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+func TestDagJSON_LinksAndContentBlocks(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	setObjectFormat(t, dir, "sha2-256", "dag-json")
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateNode("a", "Note", []byte("first"), map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	node, err := repo.UpdateContent("a", []byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := repo.Refs.Get("a")
+	if ref.Prefix().Codec != gocid.DagJSON {
+		t.Fatalf("node codec = %#x, want dag-json", ref.Prefix().Codec)
+	}
+	data, err := repo.Store.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"prev":{"/":"`+node.Prev+`"}`)) {
+		t.Errorf("prev is not a link: %s", data)
+	}
+	blob, ok := ContentLink(data)
+	if !ok {
+		t.Fatalf("content is not a link: %s", data)
+	}
+	if blob.Prefix().Codec != gocid.Raw {
+		t.Errorf("content block codec = %#x, want raw", blob.Prefix().Codec)
+	}
+	if got, _ := repo.Store.Get(blob); string(got) != "second" {
+		t.Errorf("content block = %q", got)
+	}
+
+	got, err := repo.GetNode("a")
+	if err != nil || string(got.Content) != "second" || got.Meta["k"] != "v" {
+		t.Fatalf("GetNode = %+v, %v", got, err)
+	}
+	prev, err := repo.envelopeAt(node.Prev)
+	if err != nil || string(prev.Content) != "first" {
+		t.Fatalf("prev = %+v, %v", prev, err)
+	}
+
+	head, _ := repo.Commits.Head()
+	data, err = repo.Store.Get(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"a":{"/":"`+CIDToFilename(ref)+`"}`)) || !bytes.Contains(data, []byte(`"parent":{"/":`)) {
+		t.Errorf("commit refs and parent are not links: %s", data)
+	}
+	commit, err := repo.Commits.GetCommit(head)
+	if err != nil || commit.Refs["a"] != CIDToFilename(ref) {
+		t.Fatalf("GetCommit = %+v, %v", commit, err)
+	}
+	snap, err := NewSnapshot(commit, repo.Store).GetNode("a")
+	if err != nil || string(snap.Content) != "second" {
+		t.Fatalf("snapshot node = %+v, %v", snap, err)
+	}
+}
+
+func TestDagJSON_BundleToPlainRepo(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	setObjectFormat(t, dir, "sha2-256", "dag-json")
+	src, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.identity = testIdentity(t)
+	if _, err := src.CreateNode("n", "Note", []byte("one"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.UpdateContent("n", []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := src.ExportBundle("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Blobs) != 2 {
+		t.Errorf("bundle carries %d content blocks, want 2", len(b.Blobs))
+	}
+
+	dst := openTestRepo(t)
+	head, err := dst.ImportBundle(b)
+	if err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	if string(head.Content) != "two" {
+		t.Errorf("head content = %q", head.Content)
+	}
+	prev, err := dst.envelopeAt(head.Prev)
+	if err != nil || string(prev.Content) != "one" {
+		t.Fatalf("imported prev = %+v, %v", prev, err)
+	}
+
+	for name := range b.Blobs {
+		delete(b.Blobs, name)
+		break
+	}
+	if _, err := b.Verify(); err == nil {
+		t.Error("bundle missing a content block verified")
+	}
+}
@@ -0,0 +1,364 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// AliasType is the type a node merged into another becomes: an empty
+// node whose meta "redirect" names the node that absorbed it, so paths
+// and references to the old ID still lead somewhere.
+const (
+	AliasType     = "Alias"
+	MetaRedirect  = "redirect"
+	MetaMergedIDs = "merged_from"
+)
+
+const (
+	// dupMaxDistance is the most simhash bits two nodes may differ in to
+	// count as near-duplicates; a tenth of a short note rewritten moves
+	// about five. It must stay below dupBands, so that two such hashes
+	// agree on at least one whole 8-bit band.
+	dupMaxDistance = 7
+	dupBands       = 8
+	// dupShingleWords is the shingle length; content with fewer than
+	// dupMinShingles shingles is too short to compare meaningfully.
+	dupShingleWords = 3
+	dupMinShingles  = 4
+)
+
+// Simhash returns the 64-bit simhash of content's three-word shingles,
+// and false when content is too short to fingerprint. Near-identical texts
+// get hashes a few bits apart.
+func Simhash(content []byte) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(string(content)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	n := len(words) - dupShingleWords + 1
+	if n < dupMinShingles {
+		return 0, false
+	}
+	var v [64]int
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+dupShingleWords], " ")))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+	var hash uint64
+	for b := 0; b < 64; b++ {
+		if v[b] > 0 {
+			hash |= 1 << b
+		}
+	}
+	return hash, true
+}
+
+// DuplicateIndex keeps the simhash of every live node's content, banded
+// so near-duplicates are found without comparing every pair.
+type DuplicateIndex struct {
+	mu     sync.RWMutex
+	hashes map[string]uint64
+	bands  [dupBands]map[uint8]map[string]bool
+}
+
+// NewDuplicateIndex creates an empty DuplicateIndex.
+func NewDuplicateIndex() *DuplicateIndex {
+	d := &DuplicateIndex{hashes: make(map[string]uint64)}
+	for i := range d.bands {
+		d.bands[i] = make(map[uint8]map[string]bool)
+	}
+	return d
+}
+
+func band(hash uint64, i int) uint8 {
+	return uint8(hash >> (8 * i))
+}
+
+// IndexNode fingerprints node's content. Aliases and content too short
+// to fingerprint are left out.
+func (d *DuplicateIndex) IndexNode(id string, node *NodeEnvelope) {
+	if node.Type == AliasType {
+		return
+	}
+	hash, ok := Simhash(node.Content)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hashes[id] = hash
+	for i := range d.bands {
+		b := band(hash, i)
+		if d.bands[i][b] == nil {
+			d.bands[i][b] = make(map[string]bool)
+		}
+		d.bands[i][b][id] = true
+	}
+}
+
+// RemoveNode drops id from the index.
+func (d *DuplicateIndex) RemoveNode(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	hash, ok := d.hashes[id]
+	if !ok {
+		return
+	}
+	delete(d.hashes, id)
+	for i := range d.bands {
+		b := band(hash, i)
+		delete(d.bands[i][b], id)
+		if len(d.bands[i][b]) == 0 {
+			delete(d.bands[i], b)
+		}
+	}
+}
+
+// Clusters returns the groups of near-duplicate nodes: nodes whose hashes
+// are at most dupMaxDistance bits apart, joined transitively. Each
+// cluster is sorted, and clusters are ordered by their first ID.
+func (d *DuplicateIndex) Clusters() [][]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		p, ok := parent[id]
+		if !ok || p == id {
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+	for i := range d.bands {
+		for _, ids := range d.bands[i] {
+			if len(ids) < 2 {
+				continue
+			}
+			members := make([]string, 0, len(ids))
+			for id := range ids {
+				members = append(members, id)
+			}
+			for a := 0; a < len(members); a++ {
+				for b := a + 1; b < len(members); b++ {
+					x, y := members[a], members[b]
+					if bits.OnesCount64(d.hashes[x]^d.hashes[y]) > dupMaxDistance {
+						continue
+					}
+					if rx, ry := find(x), find(y); rx != ry {
+						parent[rx] = ry
+					}
+				}
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for id := range parent {
+		root := find(id)
+		groups[root] = append(groups[root], id)
+	}
+	var clusters [][]string
+	for root, ids := range groups {
+		ids = append(ids, root)
+		ids = dedupeSorted(ids)
+		if len(ids) > 1 {
+			clusters = append(clusters, ids)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+	return clusters
+}
+
+func dedupeSorted(ids []string) []string {
+	sort.Strings(ids)
+	out := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ResolveAlias follows the redirects of merged nodes from id to the node
+// now holding its content. IDs that are not aliases resolve to themselves.
+func (r *Repository) ResolveAlias(id string) string {
+	for hops := 0; hops < 16; hops++ {
+		node, err := r.GetNode(id)
+		if err != nil || node.Type != AliasType {
+			return id
+		}
+		next, _ := node.Meta[MetaRedirect].(string)
+		if next == "" || next == id {
+			return id
+		}
+		id = next
+	}
+	return id
+}
+
+// MergeNodes folds each of ids into into, in one commit. into keeps its
+// own content and gains every paragraph of theirs it lacks; meta keys it
+// lacks are taken from them; their links, in and out, are moved onto it;
+// and each becomes an Alias redirecting to it. into's meta records the
+// merged IDs under "merged_from".
+func (r *Repository) MergeNodes(ctx context.Context, into string, ids []string) (*NodeEnvelope, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("nothing to merge into %s", into)
+	}
+	all := dedupeSorted(append([]string{into}, ids...))
+	if len(all) != len(ids)+1 {
+		return nil, fmt.Errorf("merge: %s listed twice or merged into itself", into)
+	}
+	for _, id := range all { // sorted, so concurrent merges lock in one order
+		unlock := r.lockNode(id)
+		defer unlock()
+	}
+
+	nodes := make(map[string]*NodeEnvelope)
+	for _, id := range all {
+		if err := r.EditLocks.Check(id); err != nil {
+			return nil, err
+		}
+		node, err := r.getNodeEnvelope(id)
+		if err != nil {
+			return nil, err
+		}
+		if node.Deleted || node.Type == AliasType {
+			return nil, fmt.Errorf("cannot merge %s: not a live node", id)
+		}
+		nodes[id] = node
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	target := nodes[into]
+	content := strings.TrimRight(string(target.Content), "\n")
+	have := make(map[string]bool)
+	for _, b := range Blocks(target.Content) {
+		have[b.Text] = true
+	}
+	meta := make(map[string]interface{}, len(target.Meta)+1)
+	for k, v := range target.Meta {
+		meta[k] = v
+	}
+	merged := metaStrings(meta[MetaMergedIDs])
+	for _, id := range ids {
+		for _, b := range Blocks(nodes[id].Content) {
+			if !have[b.Text] {
+				have[b.Text] = true
+				if content != "" {
+					content += "\n\n"
+				}
+				content += b.Text
+			}
+		}
+		for k, v := range nodes[id].Meta {
+			if _, ok := meta[k]; !ok && k != MetaMergedIDs {
+				meta[k] = v
+			}
+		}
+		merged = append(merged, id)
+	}
+	if content != "" {
+		content += "\n"
+	}
+	meta[MetaMergedIDs] = toInterfaces(merged)
+
+	now := time.Now().UTC()
+	node, err := r.putVersion(target, target.Type, []byte(content), meta, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, err := r.putVersion(nodes[id], AliasType, nil, map[string]interface{}{MetaRedirect: into}, now); err != nil {
+			return nil, err
+		}
+	}
+	var moved []LinkEntry
+	for _, id := range ids {
+		links, err := r.moveLinks(id, into)
+		if err != nil {
+			return nil, err
+		}
+		moved = append(moved, links...)
+	}
+	r.commit(fmt.Sprintf("merge %s into %s", strings.Join(ids, ", "), into))
+	r.Hooks.postWrite(HookUpdate, node)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeUpdated, ID: into, Node: node})
+	for i := range moved {
+		r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &moved[i]})
+	}
+	return node, nil
+}
+
+// moveLinks re-points every link from or to id, including to its blocks,
+// at into instead, dropping any that would then link into to itself.
+// Returns the links as moved. Nothing is committed.
+func (r *Repository) moveLinks(id, into string) ([]LinkEntry, error) {
+	var moved []LinkEntry
+	for _, l := range r.Links.AllLinks(id) {
+		nl := l
+		if nl.Source == id {
+			nl.Source = into
+		}
+		if LinkTargetParent(nl.Target) == id {
+			nl.Target = into + strings.TrimPrefix(nl.Target, id)
+		}
+		if _, err := r.Links.Remove(l); err != nil {
+			return nil, err
+		}
+		if nl.Source == LinkTargetParent(nl.Target) {
+			continue // the link joined id and into
+		}
+		if err := r.Links.Add(nl); err != nil {
+			return nil, err
+		}
+		moved = append(moved, nl)
+	}
+	return moved, nil
+}
+
+// putVersion writes a new version of current with the given type, content
+// and meta, and reindexes it, without committing. Callers hold its lock.
+func (r *Repository) putVersion(current *NodeEnvelope, typ string, content []byte, meta map[string]interface{}, now time.Time) (*NodeEnvelope, error) {
+	prevCID, _ := r.Refs.Get(current.ID)
+	node := &NodeEnvelope{
+		V:        1,
+		ID:       current.ID,
+		Type:     typ,
+		Content:  content,
+		Meta:     meta,
+		Created:  current.Created,
+		Modified: now,
+		Prev:     CIDToFilename(prevCID),
+		Author:   r.author(),
+	}
+	if err := r.Hooks.preWrite(HookUpdate, node); err != nil {
+		return nil, err
+	}
+	if err := r.putNode(current.ID, node); err != nil {
+		return nil, err
+	}
+	r.unindexNode(current.ID)
+	r.indexNode(current.ID, node)
+	return node, nil
+}
@@ -0,0 +1,87 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+const dupText = "The airship was moored at Lakehurst overnight before the crossing.\n\n" +
+	"Passengers boarded at dawn while the ground crew checked the gas cells and the engines."
+
+func TestDuplicateClusters(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte(dupText), nil)
+	repo.CreateNode("note:b", "Note", []byte(dupText+" Weather was fine."), nil)
+	repo.CreateNode("note:c", "Note", []byte("Completely different notes about growing tomatoes on a sunny balcony in summer."), nil)
+	repo.CreateNode("note:d", "Note", []byte("too short"), nil)
+
+	if got := repo.Duplicates.Clusters(); !reflect.DeepEqual(got, [][]string{{"note:a", "note:b"}}) {
+		t.Errorf("Clusters = %v", got)
+	}
+	if h1, _ := Simhash([]byte(dupText)); h1 == 0 {
+		t.Error("Simhash = 0")
+	}
+	if _, ok := Simhash([]byte("too short")); ok {
+		t.Error("Simhash fingerprinted a two-word text")
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("First paragraph.\n\nShared paragraph."), map[string]interface{}{"title": "A"})
+	repo.CreateNode("note:b", "Note", []byte("Shared paragraph.\n\nOnly in b."), map[string]interface{}{"title": "B", "source": "web"})
+	repo.CreateNode("person:x", "Person", nil, nil)
+	repo.CreateLink("note:b", "person:x", "mentions")
+	repo.CreateLink("person:x", "note:b#b2", "cites")
+	repo.CreateLink("note:a", "note:b", "same")
+
+	node, err := repo.MergeNodes(t.Context(), "note:a", []string{"note:b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(node.Content) != "First paragraph.\n\nShared paragraph.\n\nOnly in b.\n" {
+		t.Errorf("content = %q", node.Content)
+	}
+	if node.Meta["title"] != "A" || node.Meta["source"] != "web" ||
+		!reflect.DeepEqual(node.Meta[MetaMergedIDs], []interface{}{"note:b"}) {
+		t.Errorf("meta = %v", node.Meta)
+	}
+
+	want := []LinkEntry{
+		{Source: "note:a", Target: "person:x", Type: "mentions"},
+		{Source: "person:x", Target: "note:a#b2", Type: "cites"},
+	}
+	if got := repo.Links.AllLinks("note:a"); !sameLinks(got, want) {
+		t.Errorf("links of note:a = %+v", got)
+	}
+	if got := repo.Links.AllLinks("note:b"); len(got) != 0 {
+		t.Errorf("links left on note:b = %+v", got)
+	}
+
+	alias, err := repo.GetNode("note:b")
+	if err != nil || alias.Type != AliasType || alias.Meta[MetaRedirect] != "note:a" {
+		t.Fatalf("note:b = %+v, %v", alias, err)
+	}
+	if got := repo.ResolveAlias("note:b"); got != "note:a" {
+		t.Errorf("ResolveAlias = %s", got)
+	}
+	if _, err := repo.MergeNodes(t.Context(), "note:a", []string{"note:b"}); err == nil {
+		t.Error("merged an alias again")
+	}
+}
+
+func sameLinks(got, want []LinkEntry) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			found = found || g == w
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultEditLockTTL bounds how long a forgotten or crashed holder can
+// keep a node locked.
+const DefaultEditLockTTL = 30 * time.Minute
+
+// EditLock is an advisory claim on a node for collaborative editing. While
+// it is live, writes to the node from any other holder are refused. A
+// holder is an identity plus a process, so two mounts of one repo under
+// the same DID still exclude each other.
+type EditLock struct {
+	Holder   string    `json:"holder"` // DID of the locking identity
+	PID      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Expired reports whether the lock has lapsed at now.
+func (l *EditLock) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+func (l *EditLock) sameHolder(o *EditLock) bool {
+	return l.Holder == o.Holder && l.PID == o.PID
+}
+
+// LockedError is returned for a write to a node someone else has locked.
+type LockedError struct {
+	ID   string
+	Lock EditLock
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("node %s is locked by %s (pid %d) until %s",
+		e.ID, e.Lock.Holder, e.Lock.PID, e.Lock.Expires.Format(time.RFC3339))
+}
+
+// EditLockSet stores edit locks as one JSON file per node under
+// .mx/locks/, so every process sharing the repo sees them.
+type EditLockSet struct {
+	dir  string
+	self EditLock // Holder and PID this process locks as
+}
+
+// NewEditLockSet creates an EditLockSet in dir that acquires locks as
+// holder (a DID) and the current process.
+func NewEditLockSet(dir, holder string) (*EditLockSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create locks dir: %w", err)
+	}
+	return &EditLockSet{dir: dir, self: EditLock{Holder: holder, PID: os.Getpid()}}, nil
+}
+
+func (s *EditLockSet) path(id string) string {
+	return filepath.Join(s.dir, refFilename(id)+".json")
+}
+
+// Get returns id's lock if one is live. Expired locks are ignored.
+func (s *EditLockSet) Get(id string) (*EditLock, bool) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, false
+	}
+	var l EditLock
+	if err := json.Unmarshal(data, &l); err != nil || l.Expired(time.Now()) {
+		return nil, false
+	}
+	return &l, true
+}
+
+// Check returns a *LockedError if another holder has a live lock on id.
+func (s *EditLockSet) Check(id string) error {
+	if l, ok := s.Get(id); ok && !l.sameHolder(&s.self) {
+		return &LockedError{ID: id, Lock: *l}
+	}
+	return nil
+}
+
+// Acquire locks id for ttl, or renews the lock if this process already
+// holds it. Fails with a *LockedError while another holder's lock is live.
+func (s *EditLockSet) Acquire(id string, ttl time.Duration) (*EditLock, error) {
+	if ttl <= 0 {
+		ttl = DefaultEditLockTTL
+	}
+	now := time.Now().UTC()
+	l := s.self
+	l.Acquired = now
+	l.Expires = now.Add(ttl)
+	data, err := json.MarshalIndent(&l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// Exclusive create settles the common race between two processes
+	// locking a free node; replacing an expired or own lock goes through
+	// SafeWrite.
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		_, werr := f.Write(data)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			os.Remove(s.path(id))
+			return nil, fmt.Errorf("write lock: %w", werr)
+		}
+		return &l, nil
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("create lock: %w", err)
+	}
+	if err := s.Check(id); err != nil {
+		return nil, err
+	}
+	if err := SafeWrite(s.path(id), data, 0644); err != nil {
+		return nil, fmt.Errorf("write lock: %w", err)
+	}
+	return &l, nil
+}
+
+// Release drops this process's lock on id. Releasing an absent or expired
+// lock is a no-op; releasing someone else's live lock is a *LockedError.
+func (s *EditLockSet) Release(id string) error {
+	if err := s.Check(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package dag
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEditLock_BlocksOtherHolders(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("v1"), nil)
+
+	// Another identity sharing the repo, e.g. a second mount after sync.
+	other, err := NewEditLockSet(filepath.Join(repo.MxDir(), "locks"), "did:key:zOther")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Acquire("note:a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var locked *LockedError
+	if _, err := repo.UpdateContent("note:a", []byte("v2")); !errors.As(err, &locked) {
+		t.Fatalf("UpdateContent under foreign lock: err = %v, want *LockedError", err)
+	}
+	if locked.Lock.Holder != "did:key:zOther" {
+		t.Errorf("lock holder = %q", locked.Lock.Holder)
+	}
+	if _, err := repo.Retype("note:a", "Idea"); !errors.As(err, &locked) {
+		t.Errorf("Retype under foreign lock: err = %v", err)
+	}
+	if err := repo.DeleteNode("note:a", false); !errors.As(err, &locked) {
+		t.Errorf("DeleteNode under foreign lock: err = %v", err)
+	}
+	if _, err := repo.EditLocks.Acquire("note:a", time.Hour); !errors.As(err, &locked) {
+		t.Errorf("Acquire of a held lock: err = %v", err)
+	}
+	if err := repo.EditLocks.Release("note:a"); !errors.As(err, &locked) {
+		t.Errorf("Release of another holder's lock: err = %v", err)
+	}
+
+	if err := other.Release("note:a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateContent("note:a", []byte("v2")); err != nil {
+		t.Fatalf("UpdateContent after release: %v", err)
+	}
+}
+
+func TestEditLock_OwnLockAndExpiry(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("v1"), nil)
+
+	if _, err := repo.EditLocks.Acquire("note:a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateContent("note:a", []byte("v2")); err != nil {
+		t.Errorf("holder's own write refused: %v", err)
+	}
+
+	other, _ := NewEditLockSet(filepath.Join(repo.MxDir(), "locks"), "did:key:zOther")
+	if err := other.Check("note:a"); err == nil {
+		t.Error("other holder not blocked by live lock")
+	}
+
+	// An expired lock no longer blocks anyone and can be taken over.
+	if _, err := repo.EditLocks.Acquire("note:a", time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := other.Get("note:a"); ok {
+		t.Error("expired lock still reported")
+	}
+	if _, err := other.Acquire("note:a", time.Hour); err != nil {
+		t.Errorf("take over expired lock: %v", err)
+	}
+}
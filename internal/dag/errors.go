@@ -0,0 +1,41 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of failure callers outside the package tell apart with
+// errors.Is, whatever the message: the mount picks an errno by them and
+// the web API a status code. Repository, RefStore and ObjectStore return
+// errors that match one of these wherever the kind applies; ErrDeleted,
+// ErrExists, ErrInvalidID, ConflictError and MetaError each match one too.
+var (
+	// ErrNotFound is a node, ref, object, link or commit that does not
+	// exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is a write that lost to the repository's current state:
+	// a stale version, or a name already taken.
+	ErrConflict = errors.New("conflict")
+	// ErrValidation is input the repository will not store or cannot
+	// parse: a bad ID, name or meta value.
+	ErrValidation = errors.New("invalid")
+)
+
+// ErrDeleted is returned for a node that existed but has been deleted. It
+// matches ErrNotFound.
+var ErrDeleted error = &kindError{"node deleted", ErrNotFound}
+
+// kindError is an error with its own message that also matches kind.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+// errorOf formats an error that matches kind, without kind's message.
+func errorOf(kind error, format string, args ...interface{}) error {
+	return &kindError{fmt.Sprintf(format, args...), kind}
+}
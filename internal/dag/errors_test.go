@@ -0,0 +1,44 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors_Kinds(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	repo.DeleteNode("note:b", false)
+	cid, _ := repo.NodeCID("note:a")
+	repo.Tag("v1", "", false)
+	repo.UpdateContent("note:a", []byte("a2"))
+	_, tagTaken := repo.Tag("v1", "", false)
+	_, missingCommit := repo.Commits.Resolve("2000-01-01T00:00:00Z")
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"missing node", second(repo.GetNode("note:missing")), ErrNotFound},
+		{"missing ref", second(repo.Refs.Get("note:missing")), ErrNotFound},
+		{"deleted node", second(repo.GetNode("note:b")), ErrDeleted},
+		{"deleted node", second(repo.GetNode("note:b")), ErrNotFound},
+		{"missing link", repo.RemoveLink("note:a", "note:b", "cites"), ErrNotFound},
+		{"missing commit", missingCommit, ErrNotFound},
+		{"invalid ID", second(repo.CreateNode("a/b", "Note", nil, nil)), ErrValidation},
+		{"invalid tag", second(repo.Tag("..", "", false)), ErrValidation},
+		{"stale version", second(repo.UpdateContentIfMatch("note:a", cid, []byte("a3"))), ErrConflict},
+		{"tag taken", tagTaken, ErrConflict},
+	} {
+		if !errors.Is(tc.err, tc.kind) {
+			t.Errorf("%s: %v is not %v", tc.name, tc.err, tc.kind)
+		}
+	}
+	if err := second(repo.GetNode("note:missing")); errors.Is(err, ErrDeleted) {
+		t.Errorf("missing node reported deleted: %v", err)
+	}
+}
+
+func second[T any](_ T, err error) error { return err }
@@ -0,0 +1,324 @@
+package dag
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// extractTimeout bounds a single external extractor run.
+	extractTimeout = 30 * time.Second
+	// maxExtractedText bounds the text kept and indexed per document.
+	maxExtractedText = 4 << 20
+)
+
+// Extractor pulls the searchable text out of a document's bytes.
+type Extractor func(data []byte) (string, error)
+
+var (
+	extractorsMu sync.RWMutex
+	// extractors are the built-in extractors by Source format. They use
+	// only the standard library; PDF extraction is best-effort (see
+	// extractPDF), so configuring pdftotext is worthwhile for real use.
+	extractors = map[string]Extractor{
+		"html":  extractHTML,
+		"htm":   extractHTML,
+		"xhtml": extractHTML,
+		"docx":  extractDOCX,
+		"epub":  extractEPUB,
+		"pdf":   extractPDF,
+	}
+)
+
+// RegisterExtractor makes e the built-in extractor for Source nodes of
+// format, replacing any existing one. Extractors set in Config.Extractors
+// still take precedence.
+func RegisterExtractor(format string, e Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[strings.ToLower(format)] = e
+}
+
+// Source nodes holding documents (PDF, EPUB, DOCX, HTML, ...) are indexed
+// by the text extracted from them, under the Source node's own ID: a search
+// for words inside a document finds the binary node that holds it, and
+// from there the nodes it is attached to. Text is extracted once per
+// distinct content, when it is ingested, and cached under .mx/text/ so
+// reopening the repository does not parse every document again.
+
+// extractor returns the extractor for format: the configured command if
+// there is one, else the built-in. nil if format has neither.
+func (r *Repository) extractor(format string) Extractor {
+	format = strings.ToLower(format)
+	if argv := r.Config.Extractors[format]; len(argv) > 0 {
+		return commandExtractor(argv)
+	}
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	return extractors[format]
+}
+
+// commandExtractor runs argv with the document on stdin and takes its
+// stdout as the text, e.g. ["pdftotext", "-", "-"].
+func commandExtractor(argv []string) Extractor {
+	return func(data []byte) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), extractTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("%s: %s", argv[0], msg)
+			}
+			return "", fmt.Errorf("%s: %w", argv[0], err)
+		}
+		return stdout.String(), nil
+	}
+}
+
+// ExtractedText returns the text extracted from Source node id, or "" if
+// its format has no extractor.
+func (r *Repository) ExtractedText(id string) (string, error) {
+	node, err := r.GetNode(id)
+	if err != nil {
+		return "", err
+	}
+	return r.extractText(node)
+}
+
+// extractText returns node's extracted text, from the cache when it has
+// been extracted before.
+func (r *Repository) extractText(node *NodeEnvelope) (string, error) {
+	if node.Type != "Source" || len(node.Content) == 0 {
+		return "", nil
+	}
+	format, _ := node.Meta["format"].(string)
+	extract := r.extractor(format)
+	if extract == nil {
+		return "", nil
+	}
+	sum := sha256.Sum256(node.Content)
+	cache := filepath.Join(r.MxDir(), "text", hex.EncodeToString(sum[:]))
+	if data, err := os.ReadFile(cache); err == nil {
+		return string(data), nil
+	}
+
+	text, err := extract(node.Content)
+	if err != nil {
+		return "", fmt.Errorf("extract %s text: %w", format, err)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > maxExtractedText {
+		text = text[:maxExtractedText]
+	}
+	if err := os.MkdirAll(filepath.Dir(cache), 0755); err == nil {
+		SafeWrite(cache, []byte(text), 0644)
+	}
+	return text, nil
+}
+
+// indexExtractedText feeds node's extracted text to the SearchIndex under
+// id. Failures are logged: a document that cannot be parsed is still
+// stored and found by its meta.
+func (r *Repository) indexExtractedText(id string, node *NodeEnvelope) {
+	text, err := r.extractText(node)
+	if err != nil {
+		fmt.Printf("memex-fs: %s: %v\n", id, err)
+		return
+	}
+	if text != "" {
+		r.Search.IndexText(id, text)
+	}
+}
+
+// extractHTML returns the text of an HTML or XHTML document: tags are
+// dropped, as are script and style bodies, and entities are decoded.
+func extractHTML(data []byte) (string, error) {
+	var b strings.Builder
+	s := string(data)
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:lt])
+		s = s[lt:]
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				break
+			}
+			s = s[end+3:]
+			continue
+		}
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			break
+		}
+		inner := s[1:gt]
+		tag := strings.ToLower(inner)
+		if i := strings.IndexAny(tag, " \t\r\n/"); i >= 0 {
+			tag = tag[:i]
+		}
+		s = s[gt+1:]
+		// Script and style bodies are not text; skip to their end tag.
+		if (tag == "script" || tag == "style") && !strings.HasSuffix(inner, "/") {
+			end := strings.Index(strings.ToLower(s), "</"+tag)
+			if end < 0 {
+				break
+			}
+			s = s[end:]
+		}
+		b.WriteByte(' ')
+	}
+	return html.UnescapeString(b.String()), nil
+}
+
+// extractDOCX returns the text of a Word document's body, one paragraph
+// per line.
+func extractDOCX(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("open docx: %w", err)
+	}
+	body, err := readZipFile(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("parse docx: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab", "br":
+				b.WriteByte(' ')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// extractEPUB returns the text of an EPUB's content documents in reading
+// (spine) order. Archives without a readable package document fall back
+// to every XHTML file in name order.
+func extractEPUB(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("open epub: %w", err)
+	}
+	docs := epubSpine(zr)
+	if len(docs) == 0 {
+		for _, f := range zr.File {
+			switch strings.ToLower(path.Ext(f.Name)) {
+			case ".xhtml", ".html", ".htm":
+				docs = append(docs, f.Name)
+			}
+		}
+		sort.Strings(docs)
+	}
+	var parts []string
+	for _, name := range docs {
+		doc, err := readZipFile(zr, name)
+		if err != nil {
+			continue
+		}
+		text, _ := extractHTML(doc)
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// epubSpine returns the archive paths of an EPUB's spine documents, or nil
+// if its container or package document cannot be read.
+func epubSpine(zr *zip.Reader) []string {
+	data, err := readZipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(data, &container); err != nil || len(container.Rootfiles) == 0 {
+		return nil
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	data, err = readZipFile(zr, opfPath)
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Items []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	hrefs := make(map[string]string, len(pkg.Items))
+	for _, it := range pkg.Items {
+		hrefs[it.ID] = it.Href
+	}
+	var docs []string
+	for _, ref := range pkg.Spine {
+		if href, ok := hrefs[ref.IDRef]; ok {
+			docs = append(docs, path.Join(path.Dir(opfPath), href))
+		}
+	}
+	return docs
+}
+
+// readZipFile returns the contents of the archive member name.
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxExtractedText*4))
+}
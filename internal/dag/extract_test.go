@@ -0,0 +1,145 @@
+package dag
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"strings"
+	"testing"
+)
+
+func zipFiles(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[i+1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractHTML(t *testing.T) {
+	text, err := extractHTML([]byte(`<html><head><style>p { color: red }</style>
+<script>var hidden = 1;</script></head><body><p>Caf&eacute; <b>menu</b></p><!-- note --></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(text), " "); got != "Café menu" {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestExtractDOCX(t *testing.T) {
+	doc := zipFiles(t, "word/document.xml", `<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+<w:p><w:r><w:t>Second paragraph</w:t></w:r></w:p>
+</w:body></w:document>`)
+	text, err := extractDOCX(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Quarterly report\nSecond paragraph\n" {
+		t.Errorf("text = %q", text)
+	}
+	if _, err := extractDOCX([]byte("not a zip")); err == nil {
+		t.Error("extracted a non-zip docx")
+	}
+}
+
+func TestExtractEPUB_SpineOrder(t *testing.T) {
+	book := zipFiles(t,
+		"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf", `<package><manifest>
+<item id="c1" href="a.xhtml"/><item id="c2" href="text/b.xhtml"/>
+</manifest><spine><itemref idref="c2"/><itemref idref="c1"/></spine></package>`,
+		"OEBPS/a.xhtml", `<html><body><p>second chapter</p></body></html>`,
+		"OEBPS/text/b.xhtml", `<html><body><p>first chapter</p></body></html>`,
+	)
+	text, err := extractEPUB(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(text), " "); got != "first chapter second chapter" {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestExtractPDF(t *testing.T) {
+	var flate bytes.Buffer
+	zw := zlib.NewWriter(&flate)
+	zw.Write([]byte("BT /F1 12 Tf 72 700 Td [(Hello)-300(W)20(orld)] TJ ET"))
+	zw.Close()
+
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 712 Td (Plain \\(text\\)) Tj ET\nendstream\nendobj\n" +
+		"2 0 obj\n<< /Length 9 /Filter /FlateDecode >>\nstream\n" + flate.String() + "\nendstream\nendobj\n" +
+		"3 0 obj\n<< /Subtype /Image /Length 3 >>\nstream\n(x) Tj\nendstream\nendobj\n%%EOF\n")
+	text, err := extractPDF(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(text), " "); got != "Plain (text) Hello World" {
+		t.Errorf("text = %q", got)
+	}
+	if _, err := extractPDF([]byte("plain text")); err == nil {
+		t.Error("extracted a file without a PDF header")
+	}
+}
+
+func TestAttachment_SearchFindsDocumentText(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.CreateNode("note:1", "Note", []byte("meeting"), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Zip deflates the body, so its words are not in the raw bytes.
+	doc := zipFiles(t, "word/document.xml", `<w:document xmlns:w="w"><w:body>
+<w:p><w:r><w:t>The zeppelin fleet is over budget.</w:t></w:r></w:p></w:body></w:document>`)
+	id, err := repo.AddAttachment("note:1", "minutes.docx", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := repo.Search.Search("zeppelin", 10)
+	if len(hits) != 1 || hits[0] != id {
+		t.Fatalf("Search(zeppelin) = %v, want [%s]", hits, id)
+	}
+	if text, err := repo.ExtractedText(id); err != nil || !strings.Contains(text, "zeppelin fleet") {
+		t.Errorf("ExtractedText = %q, %v", text, err)
+	}
+
+	// Reopening indexes the cached text again.
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := reopened.SearchNodes(context.Background(), "zeppelin", 10)
+	if err != nil || len(nodes) != 1 || nodes[0].ID != id {
+		t.Fatalf("SearchNodes after reopen = %v, %v", nodes, err)
+	}
+}
+
+func TestExtract_ConfiguredCommand(t *testing.T) {
+	repo := openTestRepo(t)
+	// rot13, so the words are only found through the extractor.
+	repo.Config.Extractors = map[string][]string{"rtf": {"tr", "a-z", "n-za-m"}}
+	id, _, err := repo.Ingest("mrccryva", "rtf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits := repo.Search.Search("zeppelin", 10); len(hits) != 1 || hits[0] != id {
+		t.Errorf("Search(zeppelin) = %v, want [%s]", hits, id)
+	}
+
+	repo.Config.Extractors["pdf"] = []string{"false"}
+	if _, _, err := repo.Ingest("%PDF-1.4 broken", "pdf"); err != nil {
+		t.Errorf("ingest with a failing extractor: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// These targets cover the parsers that see untrusted bytes: envelopes and
+// commits pulled from remotes, DIDs named by peers, and link journals that
+// may have been hand-edited or torn by a crash. Interesting inputs live in
+// testdata/fuzz/.
+
+func FuzzCanonicalJSON(f *testing.F) {
+	f.Add([]byte(`{"b":1,"a":[true,null,"x"],"c":{"z":0.5,"y":"<"}}`))
+	f.Add([]byte(`{"v":1,"id":"note:a","type":"Note","content":"aGk=","created":"2025-01-01T00:00:00Z","modified":"2025-01-01T00:00:00+01:00"}`))
+	f.Add([]byte(`[1e300, -0, 12345678901234567890, "\ud800"]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v interface{}
+		if json.Unmarshal(data, &v) != nil {
+			return
+		}
+		first, err := CanonicalJSON(v)
+		if err != nil {
+			t.Fatalf("CanonicalJSON of decoded JSON: %v", err)
+		}
+		var again interface{}
+		if err := json.Unmarshal(first, &again); err != nil {
+			t.Fatalf("canonical output is not JSON: %v\n%s", err, first)
+		}
+		second, err := CanonicalJSON(again)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Fatalf("not idempotent:\n%s\n%s", first, second)
+		}
+
+		// The same bytes must hash the same whichever way a node is read.
+		var node NodeEnvelope
+		if json.Unmarshal(data, &node) != nil {
+			return
+		}
+		a, err := CanonicalJSON(&node)
+		if err != nil {
+			return // e.g. a year Marshal refuses
+		}
+		var reread NodeEnvelope
+		if err := json.Unmarshal(a, &reread); err != nil {
+			t.Fatalf("canonical envelope does not decode: %v\n%s", err, a)
+		}
+		b, err := CanonicalJSON(&reread)
+		if err != nil || !bytes.Equal(a, b) {
+			t.Fatalf("envelope round trip changed:\n%s\n%s (%v)", a, b, err)
+		}
+	})
+}
+
+func FuzzDecodeDIDKey(f *testing.F) {
+	f.Add(testDID)
+	f.Add("did:key:z")
+	f.Add("did:key:z1" + testDID[9:])
+	f.Add("did:key:z6MkehRgf7yJbgaGfYsdoAsKdBPE3dj2CYhowQdcjqSJgvVdd")
+	f.Fuzz(func(t *testing.T, did string) {
+		key, err := DecodeDIDKey(did)
+		if err != nil {
+			return
+		}
+		if len(key) != 32 {
+			t.Fatalf("DecodeDIDKey(%q) = %d-byte key", did, len(key))
+		}
+		if enc := encodeDIDKey(key); enc != did {
+			t.Fatalf("DecodeDIDKey accepted non-canonical %q (canonical %q)", did, enc)
+		}
+	})
+}
+
+func FuzzLinkJournal(f *testing.F) {
+	f.Add([]byte(`{"source":"a","target":"b","type":"cites"}` + "\n" +
+		`{"source":"a","target":"b#b2","type":"quotes"}` + "\n" +
+		`{"source":"a","target":"b","type":"cites","deleted":true}` + "\n"))
+	f.Add([]byte("not json\n{\"source\":\"x\"}\n\n{}"))
+	f.Add(append(bytes.Repeat([]byte("x"), 70_000), []byte("\n{\"source\":\"a\",\"target\":\"b\",\"type\":\"t\"}\n")...))
+	f.Fuzz(func(t *testing.T, journal []byte) {
+		path := filepath.Join(t.TempDir(), "links.jsonl")
+		if err := os.WriteFile(path, journal, 0644); err != nil {
+			t.Fatal(err)
+		}
+		idx, err := NewLinkIndex(path)
+		if err != nil {
+			t.Fatalf("NewLinkIndex: %v", err)
+		}
+		for source, links := range idx.forward {
+			for _, l := range links {
+				if l.Source != source {
+					t.Fatalf("forward[%q] holds %+v", source, l)
+				}
+				found := false
+				for _, back := range idx.LinksTo(LinkTargetParent(l.Target)) {
+					found = found || back == l
+				}
+				if !found {
+					t.Fatalf("%+v missing from reverse index", l)
+				}
+			}
+		}
+	})
+}
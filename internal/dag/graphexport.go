@@ -0,0 +1,203 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GraphFilter selects the part of the graph an export renders: nodes
+// matching its SparseFilter and, when From is set, within Depth links of
+// From in either direction. The zero filter selects the whole graph.
+type GraphFilter struct {
+	SparseFilter
+	From  string
+	Depth int // with From; 0 means 1
+}
+
+// ParseGraphFilter parses a comma-separated filter spec such as
+// "type=Note,tag=work,from=note:a,depth=2". type and tag may repeat.
+func ParseGraphFilter(spec string) (GraphFilter, error) {
+	var f GraphFilter
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return f, fmt.Errorf("graph filter %q: want key=value", part)
+		}
+		switch key {
+		case "type":
+			f.Types = append(f.Types, value)
+		case "tag":
+			f.Tags = append(f.Tags, value)
+		case "from":
+			f.From = value
+		case "depth":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return f, fmt.Errorf("graph filter: bad depth %q", value)
+			}
+			f.Depth = n
+		default:
+			return f, fmt.Errorf("graph filter: unknown key %q", key)
+		}
+	}
+	return f, nil
+}
+
+// GraphExport is a rendered selection of nodes and the links among them.
+type GraphExport struct {
+	Nodes []GraphExportNode `json:"nodes"`
+	Links []GraphExportLink `json:"links"`
+}
+
+// GraphExportNode is one node of an export. Label is its title or name
+// meta, or its ID.
+type GraphExportNode struct {
+	ID    string   `json:"id"`
+	Type  string   `json:"type"`
+	Label string   `json:"label"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// GraphExportLink is a link between two exported nodes. Links to a block
+// ("id#frag") are drawn to the node holding it.
+type GraphExportLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// ExportGraph collects the live nodes f selects and the links whose both
+// ends are among them, sorted so the same graph always renders the same.
+func (r *Repository) ExportGraph(ctx context.Context, f GraphFilter) (*GraphExport, error) {
+	var nodes []*NodeEnvelope
+	if f.From != "" {
+		depth := f.Depth
+		if depth < 1 {
+			depth = 1
+		}
+		if _, err := r.GetNode(f.From); err != nil {
+			return nil, err
+		}
+		reached, err := r.Traverse(ctx, f.From, depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = reached
+	} else {
+		ids, err := r.ListNodes(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if node, err := r.GetNode(id); err == nil {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+
+	g := &GraphExport{Nodes: []GraphExportNode{}, Links: []GraphExportLink{}}
+	in := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Type == AliasType || !f.Match(node) {
+			continue
+		}
+		in[node.ID] = true
+		label, _ := node.Meta["title"].(string)
+		if label == "" {
+			label, _ = node.Meta["name"].(string)
+		}
+		if label == "" {
+			label = node.ID
+		}
+		g.Nodes = append(g.Nodes, GraphExportNode{
+			ID: node.ID, Type: node.Type, Label: label, Tags: metaStrings(node.Meta[MetaTags]),
+		})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+
+	seen := make(map[GraphExportLink]bool)
+	for _, n := range g.Nodes {
+		for _, l := range r.Links.LinksFrom(n.ID) {
+			gl := GraphExportLink{Source: l.Source, Target: LinkTargetParent(l.Target), Type: l.Type}
+			if in[gl.Target] && !seen[gl] {
+				seen[gl] = true
+				g.Links = append(g.Links, gl)
+			}
+		}
+	}
+	sort.Slice(g.Links, func(i, j int) bool {
+		a, b := g.Links[i], g.Links[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+	return g, nil
+}
+
+// DOT renders the export for Graphviz: nodes labelled with their label and
+// type, edges with the link type.
+func (g *GraphExport) DOT() []byte {
+	var b strings.Builder
+	b.WriteString("digraph memex {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, type=%s];\n", strconv.Quote(n.ID), strconv.Quote(n.Label+"\n"+n.Type), strconv.Quote(n.Type))
+	}
+	for _, l := range g.Links {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(l.Source), strconv.Quote(l.Target), strconv.Quote(l.Type))
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// JSON renders the export as {"nodes": [...], "links": [...]}, the shape
+// D3's force layouts take.
+func (g *GraphExport) JSON() []byte {
+	data, _ := json.MarshalIndent(g, "", "  ")
+	return append(data, '\n')
+}
+
+// GraphML renders the export as GraphML, for Gephi and yEd, with type,
+// label and tags as node data and type as edge data.
+func (g *GraphExport) GraphML() []byte {
+	var b strings.Builder
+	esc := func(s string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	b.WriteString(`  <key id="label" for="node" attr.name="label" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="type" for="node" attr.name="type" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="tags" for="node" attr.name="tags" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="linktype" for="edge" attr.name="type" attr.type="string"/>` + "\n")
+	b.WriteString(`  <graph id="memex" edgedefault="directed">` + "\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "    <node id=\"%s\">\n", esc(n.ID))
+		fmt.Fprintf(&b, "      <data key=\"label\">%s</data>\n", esc(n.Label))
+		fmt.Fprintf(&b, "      <data key=\"type\">%s</data>\n", esc(n.Type))
+		if len(n.Tags) > 0 {
+			fmt.Fprintf(&b, "      <data key=\"tags\">%s</data>\n", esc(strings.Join(n.Tags, ",")))
+		}
+		b.WriteString("    </node>\n")
+	}
+	for _, l := range g.Links {
+		fmt.Fprintf(&b, "    <edge source=\"%s\" target=\"%s\">\n", esc(l.Source), esc(l.Target))
+		fmt.Fprintf(&b, "      <data key=\"linktype\">%s</data>\n", esc(l.Type))
+		b.WriteString("    </edge>\n")
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	return []byte(b.String())
+}
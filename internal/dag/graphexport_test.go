@@ -0,0 +1,74 @@
+package dag
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportGraph(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", nil, map[string]interface{}{"title": "Alpha", "tags": []interface{}{"work"}})
+	repo.CreateNode("note:b", "Note", []byte("B.\n\nSecond block."), nil)
+	repo.CreateNode("person:c", "Person", nil, map[string]interface{}{"name": "Cee"})
+	repo.CreateNode("note:d", "Note", nil, nil)
+	repo.CreateLink("note:a", "note:b#b2", "cites")
+	repo.CreateLink("note:b", "person:c", "mentions")
+	repo.CreateLink("person:c", "note:d", "wrote")
+
+	g, err := repo.ExportGraph(t.Context(), GraphFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 4 || len(g.Links) != 3 {
+		t.Fatalf("whole graph = %d nodes, %d links", len(g.Nodes), len(g.Links))
+	}
+	if g.Nodes[0].Label != "Alpha" || g.Nodes[3].Label != "Cee" || g.Nodes[1].Label != "note:b" {
+		t.Errorf("labels = %+v", g.Nodes)
+	}
+	if g.Links[0] != (GraphExportLink{Source: "note:a", Target: "note:b", Type: "cites"}) {
+		t.Errorf("block link = %+v", g.Links[0])
+	}
+
+	g, _ = repo.ExportGraph(t.Context(), GraphFilter{SparseFilter: SparseFilter{Types: []string{"Note"}}})
+	if len(g.Nodes) != 3 || len(g.Links) != 1 {
+		t.Errorf("type filter = %+v", g)
+	}
+	g, _ = repo.ExportGraph(t.Context(), GraphFilter{From: "note:b", Depth: 1})
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"note:a", "note:b", "person:c"}) {
+		t.Errorf("from note:b depth 1 = %v", ids)
+	}
+
+	dot := string(g.DOT())
+	if !strings.HasPrefix(dot, "digraph memex {") || !strings.Contains(dot, `"note:b" -> "person:c" [label="mentions"];`) {
+		t.Errorf("DOT =\n%s", dot)
+	}
+	var decoded GraphExport
+	if err := json.Unmarshal(g.JSON(), &decoded); err != nil || !reflect.DeepEqual(&decoded, g) {
+		t.Errorf("JSON round trip = %+v, %v", decoded, err)
+	}
+	if ml := string(g.GraphML()); !strings.Contains(ml, `<edge source="note:b" target="person:c">`) {
+		t.Errorf("GraphML =\n%s", ml)
+	}
+}
+
+func TestParseGraphFilter(t *testing.T) {
+	f, err := ParseGraphFilter("type=Note,type=Person,tag=work,from=note:a,depth=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GraphFilter{SparseFilter: SparseFilter{Types: []string{"Note", "Person"}, Tags: []string{"work"}}, From: "note:a", Depth: 2}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("filter = %+v", f)
+	}
+	for _, bad := range []string{"depth=0", "color=red", "type"} {
+		if _, err := ParseGraphFilter(bad); err == nil {
+			t.Errorf("ParseGraphFilter(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookTimeout bounds a single hook run.
+const hookTimeout = 10 * time.Second
+
+// Hook events, passed to hooks as MEMEX_EVENT.
+const (
+	HookCreate = "create"
+	HookUpdate = "update"
+)
+
+// HookSet runs the executables in .mx/hooks/ around node writes:
+//
+//	pre-write*   run before a create or update is stored, in name order.
+//	             The node envelope arrives on stdin in its on-disk JSON
+//	             form (content is base64); a JSON object on stdout is
+//	             merged into the node's meta (null deletes a key). A
+//	             non-zero exit rejects the write.
+//	post-write*  run after the write is committed, with the stored
+//	             envelope on stdin. Output is ignored and failures are
+//	             logged; they run in the background so writes never wait.
+//
+// Both get MEMEX_EVENT (create or update), MEMEX_NODE_ID and MEMEX_REPO in
+// their environment. The directory is read on every write, so hooks can be
+// added or removed while mounted. Hooks are plain executables rather than Go
+// plugins so they can be written in anything and survive daemon upgrades.
+type HookSet struct {
+	dir  string
+	repo string // repository root, for MEMEX_REPO
+
+	wg sync.WaitGroup // in-flight post-write hooks
+}
+
+// NewHookSet creates a HookSet over dir. A missing dir means no hooks.
+func NewHookSet(dir, repoRoot string) *HookSet {
+	return &HookSet{dir: dir, repo: repoRoot}
+}
+
+// HookError is returned when a pre-write hook rejects a write.
+type HookError struct {
+	Hook   string
+	ID     string
+	Reason string // the hook's stderr, or how it failed
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s rejected write to %s: %s", e.Hook, e.ID, e.Reason)
+}
+
+// list returns the executables whose names start with prefix, sorted.
+func (h *HookSet) list(prefix string) []string {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		if fi, err := e.Info(); err != nil || fi.Mode()&0111 == 0 {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *HookSet) run(name, event string, node *NodeEnvelope) ([]byte, error) {
+	input, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, filepath.Join(h.dir, name))
+	cmd.Dir = h.repo
+	cmd.Env = append(os.Environ(),
+		"MEMEX_EVENT="+event,
+		"MEMEX_NODE_ID="+node.ID,
+		"MEMEX_REPO="+h.repo,
+	)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// preWrite runs the pre-write hooks over node, merging each one's meta
+// changes into node before the next runs.
+func (h *HookSet) preWrite(event string, node *NodeEnvelope) error {
+	for _, name := range h.list("pre-write") {
+		out, err := h.run(name, event, node)
+		if err != nil {
+			return &HookError{Hook: name, ID: node.ID, Reason: err.Error()}
+		}
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		var updates map[string]interface{}
+		if err := json.Unmarshal(out, &updates); err != nil {
+			return &HookError{Hook: name, ID: node.ID, Reason: "output is not a JSON object: " + err.Error()}
+		}
+		if node.Meta == nil {
+			node.Meta = make(map[string]interface{})
+		}
+		for k, v := range updates {
+			if v == nil {
+				delete(node.Meta, k)
+			} else {
+				node.Meta[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// postWrite starts the post-write hooks for node in the background.
+func (h *HookSet) postWrite(event string, node *NodeEnvelope) {
+	names := h.list("post-write")
+	if len(names) == 0 {
+		return
+	}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		for _, name := range names {
+			if _, err := h.run(name, event, node); err != nil {
+				fmt.Printf("memex-fs: hook %s on %s: %v\n", name, node.ID, err)
+			}
+		}
+	}()
+}
+
+// Wait blocks until every post-write hook started so far has finished.
+func (h *HookSet) Wait() {
+	h.wg.Wait()
+}
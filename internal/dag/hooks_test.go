@@ -0,0 +1,82 @@
+package dag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeHook(t *testing.T, repo *Repository, name, script string) {
+	t.Helper()
+	dir := filepath.Join(repo.MxDir(), "hooks")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHooks_PreWriteEnrichesMeta(t *testing.T) {
+	repo := openTestRepo(t)
+	writeHook(t, repo, "pre-write-10-event", `cat >/dev/null; echo "{\"event\": \"$MEMEX_EVENT\", \"stale\": null}"`)
+	writeHook(t, repo, "pre-write-20-size", `echo "{\"bytes\": $(wc -c)}"`)
+
+	node, err := repo.CreateNode("note:a", "Note", []byte("hi"), map[string]interface{}{"stale": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Meta["event"] != "create" || node.Meta["stale"] != nil || node.Meta["bytes"] == nil {
+		t.Errorf("meta after create = %v", node.Meta)
+	}
+
+	node, err = repo.UpdateContent("note:a", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Meta["event"] != "update" {
+		t.Errorf("meta after update = %v", node.Meta)
+	}
+	if stored, _ := repo.GetNode("note:a"); stored.Meta["event"] != "update" {
+		t.Errorf("hook meta not stored: %v", stored.Meta)
+	}
+}
+
+func TestHooks_PreWriteRejects(t *testing.T) {
+	repo := openTestRepo(t)
+	writeHook(t, repo, "pre-write-validate", `grep -q '"draft":true' && { echo "drafts stay local" >&2; exit 1; }; exit 0`)
+
+	if _, err := repo.CreateNode("note:ok", "Note", []byte("done"), nil); err != nil {
+		t.Fatalf("clean create rejected: %v", err)
+	}
+	_, err := repo.UpdateNode("note:ok", map[string]interface{}{"draft": true})
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || hookErr.Reason != "drafts stay local" {
+		t.Fatalf("err = %v, want HookError with the hook's stderr", err)
+	}
+	if node, _ := repo.GetNode("note:ok"); node.Meta["draft"] != nil {
+		t.Errorf("rejected write was stored: %v", node.Meta)
+	}
+}
+
+func TestHooks_PostWriteRunsAfterCommit(t *testing.T) {
+	repo := openTestRepo(t)
+	out := filepath.Join(t.TempDir(), "seen")
+	writeHook(t, repo, "post-write-log", `echo "$MEMEX_EVENT $MEMEX_NODE_ID" >> `+out)
+	// Not executable, so never run.
+	os.WriteFile(filepath.Join(repo.MxDir(), "hooks", "post-write-disabled"), []byte("#!/bin/sh\nexit 1\n"), 0644)
+
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.UpdateNode("note:a", map[string]interface{}{"k": "v"})
+	repo.Hooks.Wait()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "create note:a\nupdate note:a" {
+		t.Errorf("post-write saw %q", got)
+	}
+}
@@ -60,8 +60,8 @@ func LoadIdentity() (*Identity, error) {
 	return generateIdentity(path)
 }
 
-// generateIdentity creates a new Ed25519 keypair and writes it to disk.
-func generateIdentity(path string) (*Identity, error) {
+// NewIdentity creates a new Ed25519 keypair without storing it.
+func NewIdentity() (*Identity, error) {
 	pub, priv, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("generate key: %w", err)
@@ -70,13 +70,20 @@ func generateIdentity(path string) (*Identity, error) {
 	// ed25519.PrivateKey is 64 bytes (seed+public), we store just the 32-byte seed
 	seed := priv.Seed()
 
-	did := encodeDIDKey([]byte(pub))
-
-	id := &Identity{
-		DID:        did,
+	return &Identity{
+		DID:        encodeDIDKey([]byte(pub)),
 		PublicKey:  base64.StdEncoding.EncodeToString(pub),
 		PrivateKey: base64.StdEncoding.EncodeToString(seed),
+	}, nil
+}
+
+// generateIdentity creates a new Ed25519 keypair and writes it to disk.
+func generateIdentity(path string) (*Identity, error) {
+	id, err := NewIdentity()
+	if err != nil {
+		return nil, err
 	}
+	did := id.DID
 
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return nil, fmt.Errorf("create identity dir: %w", err)
@@ -97,11 +104,17 @@ func generateIdentity(path string) (*Identity, error) {
 }
 
 // DecodeDIDKey decodes a did:key:z... string to a raw 32-byte Ed25519 public key.
+// Only the canonical encoding is accepted, so each key has exactly one DID.
 func DecodeDIDKey(did string) ([]byte, error) {
 	if !strings.HasPrefix(did, "did:key:z") {
 		return nil, fmt.Errorf("invalid did:key format: %s", did)
 	}
 	encoded := did[9:] // strip "did:key:z"
+	// An Ed25519 did:key is 48 base58 characters; bound the work before
+	// decoding, which is quadratic in the length.
+	if len(encoded) > 64 || strings.HasPrefix(encoded, "1") {
+		return nil, fmt.Errorf("invalid did:key length or leading zeros")
+	}
 
 	// Base58btc decode
 	num := new(big.Int)
@@ -114,16 +127,10 @@ func DecodeDIDKey(did string) ([]byte, error) {
 		num.Add(num, big.NewInt(int64(idx)))
 	}
 
-	// Convert to 34 bytes (2 prefix + 32 key)
+	// 2 prefix bytes + 32 key bytes. The 0xed prefix byte means a valid
+	// key never has leading zeros to pad.
 	prefixed := num.Bytes()
-	// Pad to 34 bytes if needed
-	if len(prefixed) < 34 {
-		padded := make([]byte, 34)
-		copy(padded[34-len(prefixed):], prefixed)
-		prefixed = padded
-	}
-
-	if len(prefixed) < 2 || prefixed[0] != 0xed || prefixed[1] != 0x01 {
+	if len(prefixed) != 34 || prefixed[0] != 0xed || prefixed[1] != 0x01 {
 		return nil, fmt.Errorf("invalid multicodec prefix for Ed25519 key")
 	}
 
@@ -0,0 +1,164 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InboxType is the type of a quick capture waiting to be refiled. Inbox
+// nodes are named by capture time, inbox:20060102T150405, so capturing
+// never asks for an ID.
+const InboxType = "Inbox"
+
+// LinkInterpretedThrough links a node to a Lens it is read through; the
+// lens's view lists every node linked to it so.
+const LinkInterpretedThrough = "INTERPRETED_THROUGH"
+
+// RefiledType is the type an inbox item takes when refiled into a lens,
+// which names no type of its own.
+const RefiledType = "Note"
+
+// ErrExists is returned when a node is to be created under an ID that is
+// taken. It matches ErrConflict.
+var ErrExists error = &kindError{"node exists", ErrConflict}
+
+// TypeFromID derives a node's type from its ID's prefix, capitalized:
+// "person:alice" is a Person. IDs without a prefix are a plain Node.
+func TypeFromID(id string) string {
+	if i := strings.Index(id, ":"); i > 0 {
+		t := id[:i]
+		return strings.ToUpper(t[:1]) + t[1:]
+	}
+	return "Node"
+}
+
+// CaptureInbox stores content as a new Inbox node named by the current
+// time. name, the file name it was captured as, if any, is kept in meta.
+func (r *Repository) CaptureInbox(name string, content []byte) (*NodeEnvelope, error) {
+	base := "inbox:" + time.Now().UTC().Format("20060102T150405")
+	var meta map[string]interface{}
+	if name != "" {
+		meta = map[string]interface{}{"filename": name}
+	}
+	for n := 1; ; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		unlock := r.lockNode(id)
+		if r.Refs.Has(id) {
+			unlock()
+			continue
+		}
+		node, err := r.createNode(id, InboxType, content, meta)
+		unlock()
+		return node, err
+	}
+}
+
+// InboxItems returns the IDs of the Inbox nodes, oldest first.
+func (r *Repository) InboxItems() []string {
+	ids := r.Search.FilterByType(InboxType, 0)
+	sort.Strings(ids) // capture times sort as strings
+	return ids
+}
+
+// inboxItem returns the live Inbox node id.
+func (r *Repository) inboxItem(id string) (*NodeEnvelope, error) {
+	node, err := r.getNodeEnvelope(id)
+	if err != nil {
+		return nil, err
+	}
+	if node.Deleted || node.Type != InboxType {
+		return nil, fmt.Errorf("%s is not in the inbox", id)
+	}
+	return node, nil
+}
+
+// Refile files the inbox item id as newID, of type newType or, when that
+// is empty, the type newID's prefix names. Its content, meta and links
+// move to newID and id is left as an Alias redirecting there. Refiling
+// under the same ID only retypes the item.
+func (r *Repository) Refile(id, newID, newType string) (*NodeEnvelope, error) {
+	if newType == "" {
+		newType = TypeFromID(newID)
+	}
+	if newID == id {
+		unlock := r.lockNode(id)
+		defer unlock()
+		if _, err := r.inboxItem(id); err != nil {
+			return nil, err
+		}
+		node, _, err := r.retype(id, newType, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		r.commit(fmt.Sprintf("refile %s as %s", id, newType))
+		return node, nil
+	}
+
+	ids := []string{id, newID}
+	sort.Strings(ids) // lock in one order, as MergeNodes does
+	for _, lid := range ids {
+		unlock := r.lockNode(lid)
+		defer unlock()
+	}
+	item, err := r.inboxItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.EditLocks.Check(id); err != nil {
+		return nil, err
+	}
+	if r.Refs.Has(newID) {
+		return nil, fmt.Errorf("refile %s as %s: %w", id, newID, ErrExists)
+	}
+	node, err := r.storeNewNode(newID, newType, item.Content, item.Meta)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.putVersion(item, AliasType, nil, map[string]interface{}{MetaRedirect: newID}, node.Created); err != nil {
+		return nil, err
+	}
+	moved, err := r.moveLinks(id, newID)
+	if err != nil {
+		return nil, err
+	}
+	r.commit(fmt.Sprintf("refile %s as %s", id, newID))
+	r.afterCreate(node)
+	for i := range moved {
+		r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &moved[i]})
+	}
+	return node, nil
+}
+
+// RefileToLens files the inbox item id under a lens: it becomes a
+// RefiledType node, keeping its ID, linked to lensID by
+// INTERPRETED_THROUGH.
+func (r *Repository) RefileToLens(id, lensID string) (*NodeEnvelope, error) {
+	lens, err := r.GetNode(lensID)
+	if err != nil {
+		return nil, err
+	}
+	if lens.Type != "Lens" {
+		return nil, fmt.Errorf("%s is a %s, not a Lens", lensID, lens.Type)
+	}
+	unlock := r.lockNode(id)
+	defer unlock()
+	if _, err := r.inboxItem(id); err != nil {
+		return nil, err
+	}
+	node, _, err := r.retype(id, RefiledType, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	link := LinkEntry{Source: id, Target: lensID, Type: LinkInterpretedThrough}
+	if err := r.Links.Add(link); err != nil {
+		return nil, err
+	}
+	r.commit(fmt.Sprintf("refile %s into %s", id, lensID))
+	r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &link})
+	return node, nil
+}
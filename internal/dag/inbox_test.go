@@ -0,0 +1,78 @@
+package dag
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCaptureInbox(t *testing.T) {
+	repo := openTestRepo(t)
+	a, err := repo.CaptureInbox("todo.txt", []byte("call the venue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.CaptureInbox("", []byte("buy stamps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a.ID, "inbox:") || a.Type != InboxType || a.Meta["filename"] != "todo.txt" {
+		t.Errorf("captured = %+v", a)
+	}
+	if b.ID == a.ID {
+		t.Errorf("two captures share ID %s", a.ID)
+	}
+	if got := repo.InboxItems(); !reflect.DeepEqual(got, []string{a.ID, b.ID}) {
+		t.Errorf("InboxItems = %v", got)
+	}
+}
+
+func TestRefile(t *testing.T) {
+	repo := openTestRepo(t)
+	item, _ := repo.CaptureInbox("", []byte("call the venue"))
+	repo.CreateNode("person:ada", "Person", nil, nil)
+	repo.CreateLink(item.ID, "person:ada", "mentions")
+
+	node, err := repo.Refile(item.ID, "task:call-venue", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != "Task" || string(node.Content) != "call the venue" {
+		t.Errorf("refiled = %+v", node)
+	}
+	if got := repo.ResolveAlias(item.ID); got != "task:call-venue" {
+		t.Errorf("old ID resolves to %s", got)
+	}
+	if links := repo.Links.LinksFrom("task:call-venue"); len(links) != 1 || links[0].Target != "person:ada" {
+		t.Errorf("links = %+v", links)
+	}
+	if len(repo.InboxItems()) != 0 {
+		t.Errorf("inbox after refile = %v", repo.InboxItems())
+	}
+	if _, err := repo.Refile("person:ada", "note:x", ""); err == nil {
+		t.Error("refiled a node not in the inbox")
+	}
+
+	other, _ := repo.CaptureInbox("", []byte("again"))
+	if _, err := repo.Refile(other.ID, "task:call-venue", ""); !errors.Is(err, ErrExists) {
+		t.Errorf("refile onto taken ID: %v", err)
+	}
+}
+
+func TestRefileToLens(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("lens:economics", "Lens", nil, nil)
+	item, _ := repo.CaptureInbox("", []byte("prices are sticky"))
+
+	node, err := repo.RefileToLens(item.ID, "lens:economics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != RefiledType || node.ID != item.ID {
+		t.Errorf("refiled = %+v", node)
+	}
+	if links := repo.Links.LinksTo("lens:economics"); len(links) != 1 || links[0].Type != LinkInterpretedThrough {
+		t.Errorf("lens links = %+v", links)
+	}
+}
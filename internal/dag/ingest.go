@@ -0,0 +1,262 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxIngestSize bounds the content IngestReader and IngestURL take.
+const maxIngestSize = 512 << 20
+
+// ingestFetchTimeout bounds one IngestURL request.
+const ingestFetchTimeout = 5 * time.Minute
+
+// IngestOptions describes content handed to IngestReader. Every field is
+// optional.
+type IngestOptions struct {
+	// Format is the format label, e.g. "pdf"; empty sniffs it from
+	// Filename, then MIME, then the content itself.
+	Format string
+	// Filename is the content's original name, kept as meta "filename".
+	Filename string
+	// MIME is the content's media type, kept as meta "mime"; empty sniffs
+	// it like Format.
+	MIME string
+	// Meta is merged into the Source node's meta when it is created.
+	Meta map[string]interface{}
+}
+
+// formatsByMIME maps media types to the format labels Ingest callers use
+// where the type's usual extension is not the label.
+var formatsByMIME = map[string]string{
+	"text/plain":               "text",
+	"text/markdown":            "md",
+	"text/html":                "html",
+	"text/calendar":            "ics",
+	"text/vcard":               "vcf",
+	"application/pdf":          "pdf",
+	"application/json":         "json",
+	"application/rtf":          "rtf",
+	"application/x-ipynb+json": "ipynb",
+	"image/jpeg":               "jpg",
+	"audio/mpeg":               "mp3",
+	"audio/mp4":                "m4a",
+	"audio/wav":                "wav",
+	"audio/x-wav":              "wav",
+}
+
+// sniffIngest fills in opts' Format and MIME from what is known about data.
+func sniffIngest(data []byte, opts *IngestOptions) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(opts.Filename)), ".")
+	if opts.MIME == "" && ext != "" {
+		opts.MIME = mime.TypeByExtension("." + ext)
+	}
+	if opts.MIME == "" {
+		opts.MIME = http.DetectContentType(data)
+	}
+	mediaType, _, err := mime.ParseMediaType(opts.MIME)
+	if err != nil {
+		mediaType = opts.MIME
+	}
+	switch {
+	case opts.Format != "":
+	case ext != "":
+		opts.Format = ext
+	case formatsByMIME[mediaType] != "":
+		opts.Format = formatsByMIME[mediaType]
+	default:
+		opts.Format = "binary"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			opts.Format = strings.TrimPrefix(exts[0], ".")
+		}
+	}
+}
+
+// ingestMetaKeys are the meta keys ingestion sets itself.
+var ingestMetaKeys = []string{"filename", "format", "mime", "size_bytes", "source_url"}
+
+// CheckIngestMeta checks meta a caller hands to IngestReader or IngestURL
+// in IngestOptions.Meta: it may set neither reserved keys nor those
+// ingestion sets itself, and keys memex-fs interprets must hold values it
+// can read. The first offending key, in sorted order, is reported as a
+// *MetaError.
+func CheckIngestMeta(meta map[string]interface{}) error {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if slices.Contains(ingestMetaKeys, k) {
+			return &MetaError{Key: k, Reason: "set by ingestion"}
+		}
+	}
+	return ValidateMeta(&NodeEnvelope{Type: "Source"}, meta)
+}
+
+// IngestReader reads content from rd and ingests it as Ingest does, with
+// the format and media type from opts or sniffed, and opts.Meta merged
+// into a new Source node. Content already in the repository keeps the
+// meta it was first ingested with. opts.Meta must pass CheckIngestMeta.
+func (r *Repository) IngestReader(rd io.Reader, opts IngestOptions) (string, bool, error) {
+	if err := CheckIngestMeta(opts.Meta); err != nil {
+		return "", false, err
+	}
+	return r.ingestReader(rd, opts, nil)
+}
+
+// ingestReader is IngestReader with system, meta set by the caller's own
+// code, applied over opts.Meta.
+func (r *Repository) ingestReader(rd io.Reader, opts IngestOptions, system map[string]interface{}) (string, bool, error) {
+	data, err := readIngest(rd, maxIngestSize)
+	if err != nil {
+		return "", false, err
+	}
+	sniffIngest(data, &opts)
+	meta := make(map[string]interface{}, len(opts.Meta)+len(system)+2)
+	for k, v := range opts.Meta {
+		meta[k] = v
+	}
+	meta["mime"] = opts.MIME
+	if opts.Filename != "" {
+		meta["filename"] = opts.Filename
+	}
+	for k, v := range system {
+		meta[k] = v
+	}
+	return r.ingest(string(data), opts.Format, meta)
+}
+
+// readIngest reads all of rd, failing rather than truncating content
+// longer than limit bytes.
+func readIngest(rd io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(rd, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read content: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errorOf(ErrValidation, "content is over %d bytes", limit)
+	}
+	return data, nil
+}
+
+// fetchCacheEntry is one URL in .mx/ingest-cache.json: the validators of
+// the last response IngestURL ingested from it and the node it became.
+type fetchCacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ID           string `json:"id"`
+}
+
+func (r *Repository) fetchCachePath() string {
+	return filepath.Join(r.MxDir(), "ingest-cache.json")
+}
+
+func (r *Repository) loadFetchCache() (map[string]fetchCacheEntry, error) {
+	cache := make(map[string]fetchCacheEntry)
+	data, err := os.ReadFile(r.fetchCachePath())
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read ingest cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("parse ingest cache: %w", err)
+	}
+	return cache, nil
+}
+
+// IngestURL fetches url and ingests the response body as IngestReader
+// does, keeping the URL as meta "source_url". The media type comes from
+// the response unless opts sets one, and the filename from its
+// Content-Disposition or the URL's last path segment. A URL fetched before
+// is revalidated with its ETag or Last-Modified, and an unchanged one
+// returns the node it became without downloading it again.
+func (r *Repository) IngestURL(ctx context.Context, url string, opts IngestOptions) (string, bool, error) {
+	if err := CheckIngestMeta(opts.Meta); err != nil {
+		return "", false, err
+	}
+	r.shared.ingestMu.Lock()
+	cache, err := r.loadFetchCache()
+	r.shared.ingestMu.Unlock()
+	if err != nil {
+		return "", false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ingestFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	cached, ok := cache[url]
+	if ok && r.Refs.Has(cached.ID) {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && ok {
+		return cached.ID, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+
+	if opts.MIME == "" {
+		opts.MIME = resp.Header.Get("Content-Type")
+	}
+	if opts.Filename == "" {
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+			opts.Filename = path.Base(params["filename"])
+		}
+	}
+	if opts.Filename == "" || opts.Filename == "." {
+		opts.Filename = path.Base(req.URL.Path)
+		if opts.Filename == "/" || opts.Filename == "." {
+			opts.Filename = ""
+		}
+	}
+	id, created, err := r.ingestReader(resp.Body, opts, map[string]interface{}{"source_url": url})
+	if err != nil {
+		return "", false, err
+	}
+
+	entry := fetchCacheEntry{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified"), ID: id}
+	return id, created, r.cacheFetch(url, entry)
+}
+
+// cacheFetch records entry for url in the ingest cache.
+func (r *Repository) cacheFetch(url string, entry fetchCacheEntry) error {
+	r.shared.ingestMu.Lock()
+	defer r.shared.ingestMu.Unlock()
+	cache, err := r.loadFetchCache()
+	if err != nil {
+		return err
+	}
+	cache[url] = entry
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(r.fetchCachePath(), data, 0644)
+}
@@ -0,0 +1,97 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIngestReader_Sniffs(t *testing.T) {
+	repo := openTestRepo(t)
+	for _, tc := range []struct {
+		content string
+		opts    IngestOptions
+		format  string
+		mime    string
+	}{
+		{"# notes", IngestOptions{Filename: "notes.md"}, "md", "text/markdown; charset=utf-8"},
+		{"%PDF-1.4 body", IngestOptions{}, "pdf", "application/pdf"},
+		{"plain words", IngestOptions{}, "text", "text/plain; charset=utf-8"},
+		{"{}", IngestOptions{MIME: "application/json", Format: "config"}, "config", "application/json"},
+	} {
+		id, created, err := repo.IngestReader(strings.NewReader(tc.content), tc.opts)
+		if err != nil || !created {
+			t.Fatalf("IngestReader(%q) = %v, %v", tc.content, created, err)
+		}
+		node, _ := repo.GetNode(id)
+		if node.Meta["format"] != tc.format || node.Meta["mime"] != tc.mime {
+			t.Errorf("%q: format %v, mime %v; want %s, %s", tc.content, node.Meta["format"], node.Meta["mime"], tc.format, tc.mime)
+		}
+	}
+
+	id, _, err := repo.IngestReader(bytes.NewReader([]byte("tagged")), IngestOptions{Meta: map[string]interface{}{"tags": "reading"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := repo.GetNode(id); node.Meta["tags"] != "reading" || node.Type != "Source" {
+		t.Errorf("node = %+v", node)
+	}
+
+	for _, meta := range []map[string]interface{}{{"verified": true}, {"ingested_from": "did:key:z6Mk"}, {"size_bytes": 1}, {"mime": "text/x"}} {
+		if _, _, err := repo.IngestReader(strings.NewReader("other"), IngestOptions{Meta: meta}); !errors.Is(err, ErrValidation) {
+			t.Errorf("ingest with %v: err = %v, want ErrValidation", meta, err)
+		}
+	}
+}
+
+func TestIngestURL_RevalidatesWithETag(t *testing.T) {
+	repo := openTestRepo(t)
+	body, fetches := "first version", 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	url := srv.URL + "/articles/page"
+
+	id, created, err := repo.IngestURL(ctx, url, IngestOptions{})
+	if err != nil || !created {
+		t.Fatalf("IngestURL = %v, %v", created, err)
+	}
+	node, _ := repo.GetNode(id)
+	if node.Meta["source_url"] != url || node.Meta["format"] != "html" || node.Meta["filename"] != "page" {
+		t.Errorf("meta = %v", node.Meta)
+	}
+
+	again, created, err := repo.IngestURL(ctx, url, IngestOptions{})
+	if err != nil || created || again != id || fetches != 1 {
+		t.Errorf("unchanged URL: id %s, created %v, %d downloads, %v", again, created, fetches, err)
+	}
+
+	body = "second version"
+	changed, created, err := repo.IngestURL(ctx, url, IngestOptions{})
+	if err != nil || !created || changed == id || fetches != 2 {
+		t.Errorf("changed URL: id %s, created %v, %d downloads, %v", changed, created, fetches, err)
+	}
+}
+
+func TestReadIngest_Limit(t *testing.T) {
+	if data, err := readIngest(strings.NewReader("12345"), 5); err != nil || string(data) != "12345" {
+		t.Errorf("at the limit: %q, %v", data, err)
+	}
+	if _, err := readIngest(strings.NewReader("123456"), 5); !errors.Is(err, ErrValidation) {
+		t.Errorf("over the limit: err = %v, want ErrValidation", err)
+	}
+}
@@ -0,0 +1,144 @@
+package dag
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DayLayout is the YYYY-MM-DD form used for journal days and Journal IDs.
+	DayLayout = "2006-01-02"
+
+	// journalDateKey is the meta field that places a node on an explicit
+	// day in addition to its creation day (e.g. notes from a meeting that
+	// were written up later).
+	journalDateKey = "date"
+)
+
+// DateIndex groups nodes by calendar day for the /journal/ view. A node is
+// filed under the local date of its Created timestamp and, if present and
+// parseable, the date in meta["date"].
+type DateIndex struct {
+	mu     sync.RWMutex
+	days   map[string]map[string]bool // YYYY-MM-DD -> set of ref IDs
+	byNode map[string][]string        // ref ID -> days it is filed under
+}
+
+// NewDateIndex creates an empty DateIndex.
+func NewDateIndex() *DateIndex {
+	return &DateIndex{
+		days:   make(map[string]map[string]bool),
+		byNode: make(map[string][]string),
+	}
+}
+
+// nodeDays returns the distinct days a node belongs to.
+func nodeDays(node *NodeEnvelope) []string {
+	days := []string{node.Created.In(time.Local).Format(DayLayout)}
+	if s, ok := node.Meta[journalDateKey].(string); ok {
+		if day, ok := parseMetaDay(s); ok && day != days[0] {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// parseMetaDay accepts either a bare YYYY-MM-DD or a full RFC3339 timestamp.
+func parseMetaDay(s string) (string, bool) {
+	if t, err := time.ParseInLocation(DayLayout, s, time.Local); err == nil {
+		return t.Format(DayLayout), true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(time.Local).Format(DayLayout), true
+	}
+	return "", false
+}
+
+// IndexNode files a node under its days.
+func (d *DateIndex) IndexNode(id string, node *NodeEnvelope) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	days := nodeDays(node)
+	for _, day := range days {
+		if d.days[day] == nil {
+			d.days[day] = make(map[string]bool)
+		}
+		d.days[day][id] = true
+	}
+	d.byNode[id] = days
+}
+
+// RemoveNode removes a node from every day it was filed under.
+func (d *DateIndex) RemoveNode(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, day := range d.byNode[id] {
+		delete(d.days[day], id)
+		if len(d.days[day]) == 0 {
+			delete(d.days, day)
+		}
+	}
+	delete(d.byNode, id)
+}
+
+// Days returns every day with at least one node, sorted ascending.
+func (d *DateIndex) Days() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	days := make([]string, 0, len(d.days))
+	for day := range d.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days
+}
+
+// NodesOn returns the sorted IDs of nodes filed under day (YYYY-MM-DD).
+func (d *DateIndex) NodesOn(day string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ids := make([]string, 0, len(d.days[day]))
+	for id := range d.days[day] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// JournalID returns the ID of the daily Journal node for day.
+func JournalID(day time.Time) string {
+	return "journal:" + day.Format(DayLayout)
+}
+
+// AppendJournal appends text to the Journal node for day, creating the
+// node if it does not exist yet. Appends are separated by a blank line so
+// each one becomes its own block. Returns the Journal node's ID.
+func (r *Repository) AppendJournal(day time.Time, text []byte) (string, error) {
+	id := JournalID(day)
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	current, err := r.GetNode(id)
+	if err != nil {
+		meta := map[string]interface{}{journalDateKey: day.Format(DayLayout)}
+		if _, err := r.createNode(id, "Journal", text, meta); err != nil {
+			return "", err
+		}
+		return id, nil
+	}
+
+	content := current.Content
+	if len(content) > 0 {
+		content = append(append([]byte(nil), content...), "\n\n"...)
+	}
+	content = append(content, text...)
+	if _, err := r.updateContent(id, content, nil); err != nil {
+		return "", err
+	}
+	return id, nil
+}
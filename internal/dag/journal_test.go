@@ -0,0 +1,66 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateIndex_FilesByCreatedAndMetaDate(t *testing.T) {
+	repo := openTestRepo(t)
+	today := time.Now().Format(DayLayout)
+
+	repo.CreateNode("note:plain", "Note", nil, nil)
+	repo.CreateNode("note:dated", "Note", nil, map[string]interface{}{"date": "2024-06-01"})
+
+	onToday := repo.Dates.NodesOn(today)
+	if len(onToday) != 2 {
+		t.Errorf("NodesOn(today) = %v, want both notes", onToday)
+	}
+	if got := repo.Dates.NodesOn("2024-06-01"); len(got) != 1 || got[0] != "note:dated" {
+		t.Errorf("NodesOn(2024-06-01) = %v, want [note:dated]", got)
+	}
+
+	// Changing the meta date moves the node off the old day.
+	repo.UpdateNode("note:dated", map[string]interface{}{"date": nil})
+	if got := repo.Dates.NodesOn("2024-06-01"); len(got) != 0 {
+		t.Errorf("after clearing date, NodesOn(2024-06-01) = %v, want empty", got)
+	}
+
+	repo.DeleteNode("note:plain", false)
+	for _, id := range repo.Dates.NodesOn(today) {
+		if id == "note:plain" {
+			t.Error("deleted node still filed under today")
+		}
+	}
+}
+
+func TestAppendJournal_CreatesThenAppends(t *testing.T) {
+	repo := openTestRepo(t)
+	day := time.Date(2025, 3, 14, 9, 0, 0, 0, time.Local)
+
+	id, err := repo.AppendJournal(day, []byte("first entry"))
+	if err != nil {
+		t.Fatalf("AppendJournal: %v", err)
+	}
+	if id != "journal:2025-03-14" {
+		t.Errorf("id = %q, want journal:2025-03-14", id)
+	}
+	if _, err := repo.AppendJournal(day, []byte("second entry")); err != nil {
+		t.Fatalf("AppendJournal (second): %v", err)
+	}
+
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != "Journal" {
+		t.Errorf("Type = %q, want Journal", node.Type)
+	}
+	if !strings.Contains(string(node.Content), "first entry\n\nsecond entry") {
+		t.Errorf("Content = %q, want both entries separated by a blank line", node.Content)
+	}
+	if got := repo.Dates.NodesOn("2025-03-14"); len(got) != 1 || got[0] != id {
+		t.Errorf("journal node not filed under its date: %v", got)
+	}
+}
@@ -2,9 +2,14 @@ package dag
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -27,12 +32,36 @@ type LinkEntry struct {
 	Type   string `json:"type"`
 }
 
+// ID returns a short stable identifier for the link, derived from its
+// source, type and target, for naming it where its target can't be used.
+func (l LinkEntry) ID() string {
+	sum := sha256.Sum256([]byte(l.Source + "\x00" + l.Type + "\x00" + l.Target))
+	return "link-" + hex.EncodeToString(sum[:5])
+}
+
+// linkRecord is one line of the JSONL journal. Removals are appended as
+// records with Deleted set rather than rewriting the journal in place.
+type linkRecord struct {
+	LinkEntry
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// The journal is compacted — rewritten from the in-memory maps — once it
+// holds at least compactMinRecords records and more than compactRatio
+// records per live link, i.e. once duplicates and removals dominate it.
+const (
+	compactMinRecords = 1000
+	compactRatio      = 2
+)
+
 // LinkIndex maintains an append-only JSONL journal and in-memory forward/reverse maps.
 type LinkIndex struct {
 	mu      sync.RWMutex
 	path    string
 	forward map[string][]LinkEntry // source -> links
 	reverse map[string][]LinkEntry // target -> links
+	records int                    // lines in the journal, live or not
+	live    int                    // distinct links in the maps
 }
 
 // NewLinkIndex creates a LinkIndex, loading existing entries from the journal file.
@@ -45,6 +74,11 @@ func NewLinkIndex(path string) (*LinkIndex, error) {
 	if err := idx.load(); err != nil {
 		return nil, err
 	}
+	if idx.needsCompact() {
+		if err := idx.compact(); err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+		}
+	}
 	return idx, nil
 }
 
@@ -58,18 +92,89 @@ func (idx *LinkIndex) load() error {
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		var entry LinkEntry
-		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-			continue // skip malformed lines
+	// Read whole lines however long: a Scanner would fail the open on one
+	// oversized line, and a journal entry is never worth refusing to start.
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			idx.replay(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read link journal: %w", err)
+		}
+	}
+}
+
+// replay applies one journal line during load. Malformed lines are
+// skipped, and so are repeats of a link already present.
+func (idx *LinkIndex) replay(line []byte) {
+	idx.records++
+	var rec linkRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return
+	}
+	if rec.Deleted {
+		idx.drop(rec.LinkEntry)
+		return
+	}
+	if !idx.has(rec.LinkEntry) {
+		idx.insert(rec.LinkEntry)
+	}
+}
+
+// has reports whether entry is in the maps. Caller holds a lock.
+func (idx *LinkIndex) has(entry LinkEntry) bool {
+	for _, existing := range idx.forward[entry.Source] {
+		if existing == entry {
+			return true
 		}
-		idx.forward[entry.Source] = append(idx.forward[entry.Source], entry)
-		// Reverse map is keyed by the parent node so that block-scoped
-		// targets surface as backlinks on the whole node.
-		idx.reverse[LinkTargetParent(entry.Target)] = append(idx.reverse[LinkTargetParent(entry.Target)], entry)
 	}
-	return scanner.Err()
+	return false
+}
+
+// insert adds entry to the maps. Caller holds the write lock.
+func (idx *LinkIndex) insert(entry LinkEntry) {
+	idx.forward[entry.Source] = append(idx.forward[entry.Source], entry)
+	// Reverse map is keyed by the parent node so that block-scoped
+	// targets surface as backlinks on the whole node.
+	idx.reverse[LinkTargetParent(entry.Target)] = append(idx.reverse[LinkTargetParent(entry.Target)], entry)
+	idx.live++
+}
+
+// drop removes every copy of entry from the in-memory maps. Caller holds
+// the write lock (or is still loading). Returns whether anything matched.
+func (idx *LinkIndex) drop(entry LinkEntry) bool {
+	found := false
+	filter := func(links []LinkEntry) []LinkEntry {
+		// Build a fresh slice: LinksFrom/LinksTo hand out the stored
+		// slices, so filtering in place would rewrite callers' views.
+		var kept []LinkEntry
+		for _, l := range links {
+			if l == entry {
+				found = true
+				continue
+			}
+			kept = append(kept, l)
+		}
+		return kept
+	}
+	parent := LinkTargetParent(entry.Target)
+	idx.forward[entry.Source] = filter(idx.forward[entry.Source])
+	if len(idx.forward[entry.Source]) == 0 {
+		delete(idx.forward, entry.Source)
+	}
+	idx.reverse[parent] = filter(idx.reverse[parent])
+	if len(idx.reverse[parent]) == 0 {
+		delete(idx.reverse, parent)
+	}
+	if found {
+		idx.live--
+	}
+	return found
 }
 
 // Add appends a link to the journal and updates in-memory indexes.
@@ -77,11 +182,8 @@ func (idx *LinkIndex) Add(entry LinkEntry) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Check for duplicate
-	for _, existing := range idx.forward[entry.Source] {
-		if existing.Target == entry.Target && existing.Type == entry.Type {
-			return nil // already exists
-		}
+	if idx.has(entry) {
+		return nil // already exists
 	}
 
 	// Append to journal
@@ -89,9 +191,77 @@ func (idx *LinkIndex) Add(entry LinkEntry) error {
 	if err := SafeAppend(idx.path, append(data, '\n')); err != nil {
 		return fmt.Errorf("write link entry: %w", err)
 	}
+	idx.records++
+	idx.insert(entry)
+	return nil
+}
 
-	idx.forward[entry.Source] = append(idx.forward[entry.Source], entry)
-	idx.reverse[LinkTargetParent(entry.Target)] = append(idx.reverse[LinkTargetParent(entry.Target)], entry)
+// Remove deletes a link, appending a removal record to the journal.
+// Returns false if the link did not exist.
+func (idx *LinkIndex) Remove(entry LinkEntry) (bool, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.drop(entry) {
+		return false, nil
+	}
+	data, _ := json.Marshal(linkRecord{LinkEntry: entry, Deleted: true})
+	if err := SafeAppend(idx.path, append(data, '\n')); err != nil {
+		return true, fmt.Errorf("write link removal: %w", err)
+	}
+	idx.records++
+	if idx.needsCompact() {
+		if err := idx.compact(); err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+		}
+	}
+	return true, nil
+}
+
+// Compact rewrites the journal with one record per live link, dropping
+// duplicates, removals and malformed lines. Returns how many records were
+// dropped.
+func (idx *LinkIndex) Compact() (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	before := idx.records
+	if err := idx.compact(); err != nil {
+		return 0, err
+	}
+	return before - idx.records, nil
+}
+
+// needsCompact reports whether the journal has grown enough past the live
+// links to be worth rewriting. Caller holds the write lock.
+func (idx *LinkIndex) needsCompact() bool {
+	return idx.records >= compactMinRecords && idx.records > compactRatio*idx.live
+}
+
+// compact replaces the journal atomically, so a crash leaves either the
+// old journal or the new one. Caller holds the write lock.
+func (idx *LinkIndex) compact() error {
+	entries := make([]LinkEntry, 0, idx.live)
+	for _, links := range idx.forward {
+		entries = append(entries, links...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Source != entries[j].Source {
+			return entries[i].Source < entries[j].Source
+		}
+		if entries[i].Target != entries[j].Target {
+			return entries[i].Target < entries[j].Target
+		}
+		return entries[i].Type < entries[j].Type
+	})
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, _ := json.Marshal(e)
+		buf.Write(append(data, '\n'))
+	}
+	if err := SafeWrite(idx.path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("compact link journal: %w", err)
+	}
+	idx.records = len(entries)
 	return nil
 }
 
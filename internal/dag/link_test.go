@@ -0,0 +1,99 @@
+package dag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func journalLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestLinkIndex_DedupOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.jsonl")
+	line := `{"source":"a","target":"b","type":"cites"}` + "\n"
+	os.WriteFile(path, []byte(strings.Repeat(line, 3)), 0644)
+
+	idx, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.LinksFrom("a"); len(got) != 1 {
+		t.Fatalf("LinksFrom = %+v, want one link", got)
+	}
+	if got := idx.LinksTo("b"); len(got) != 1 {
+		t.Errorf("LinksTo = %+v, want one link", got)
+	}
+}
+
+func TestLinkIndex_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.jsonl")
+	idx, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Add(LinkEntry{"a", "b", "cites"})
+	idx.Add(LinkEntry{"a", "c", "cites"})
+	idx.Remove(LinkEntry{"a", "b", "cites"})
+	SafeAppend(path, []byte("not json\n"))
+
+	reloaded, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := reloaded.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 3 || journalLines(t, path) != 1 {
+		t.Errorf("dropped %d, journal has %d lines; want 3 and 1", dropped, journalLines(t, path))
+	}
+
+	again, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.AllEntries(); len(got) != 1 || got[0].Target != "c" {
+		t.Errorf("after compaction: %+v", got)
+	}
+}
+
+func TestLinkIndex_CompactsWhenMostlyDead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.jsonl")
+	idx, err := NewLinkIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := LinkEntry{"a", "keep", "cites"}
+	idx.Add(keep)
+	churn := LinkEntry{"a", "b", "cites"}
+	for range compactMinRecords / 2 {
+		idx.Add(churn)
+		idx.Remove(churn)
+	}
+	if lines := journalLines(t, path); lines >= compactMinRecords {
+		t.Errorf("journal has %d lines, want compaction below %d", lines, compactMinRecords)
+	}
+	if got := idx.AllEntries(); len(got) != 1 || got[0] != keep {
+		t.Errorf("entries = %+v", got)
+	}
+}
+
+func TestLinkEntry_ID(t *testing.T) {
+	a := LinkEntry{"note:a", "note:b", "cites"}
+	if a.ID() != (LinkEntry{"note:a", "note:b", "cites"}).ID() {
+		t.Error("ID is not stable")
+	}
+	// The old flat name "cites:x:note:b" could be read either way.
+	if (LinkEntry{"note:a", "x:note:b", "cites"}).ID() == (LinkEntry{"note:a", "note:b", "cites:x"}).ID() {
+		t.Error("links differing only in where the colon falls share an ID")
+	}
+}
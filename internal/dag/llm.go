@@ -0,0 +1,114 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrNoLLM is returned by features that need a language model when none is
+// configured.
+var ErrNoLLM = errors.New("no LLM configured")
+
+const llmTimeout = 2 * time.Minute
+
+// LLM is a language model backend: given a system instruction and a
+// prompt, it returns the model's reply.
+type LLM interface {
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// LLMConfig is the "llm" section of config.json. Any server speaking the
+// OpenAI chat completions API works, including local ones such as Ollama
+// or llama.cpp.
+type LLMConfig struct {
+	// Endpoint is the full chat completions URL, e.g.
+	// http://localhost:11434/v1/chat/completions.
+	Endpoint string `json:"endpoint"`
+	Model    string `json:"model"`
+	// APIKeyEnv names the environment variable holding the bearer token,
+	// so the key itself stays out of the repository.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+// ChatLLM is an LLM backed by an OpenAI-compatible chat completions
+// endpoint.
+type ChatLLM struct {
+	endpoint string
+	model    string
+	apiKey   string
+	client   *http.Client
+}
+
+var _ LLM = (*ChatLLM)(nil)
+
+// NewChatLLM creates a ChatLLM from cfg, reading the API key from the
+// environment.
+func NewChatLLM(cfg LLMConfig) *ChatLLM {
+	c := &ChatLLM{
+		endpoint: cfg.Endpoint,
+		model:    cfg.Model,
+		client:   &http.Client{Timeout: llmTimeout},
+	}
+	if cfg.APIKeyEnv != "" {
+		c.apiKey = os.Getenv(cfg.APIKeyEnv)
+	}
+	return c
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (c *ChatLLM) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": c.model,
+		"messages": []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llm: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("llm: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llm: status %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var out struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("llm: parse response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("llm: empty response")
+	}
+	return out.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,50 @@
+package dag
+
+import "sync"
+
+// keyedMutex hands out one mutex per key. Entries are created on demand and
+// dropped once nobody holds or waits for them, so memory follows the number
+// of concurrent writers rather than the size of the repo. The zero value is
+// ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedEntry
+}
+
+type keyedEntry struct {
+	mu   sync.Mutex
+	refs int // holders plus waiters; guarded by keyedMutex.mu
+}
+
+// Lock blocks until key is free and returns the function that releases it.
+func (k *keyedMutex) Lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedEntry)
+	}
+	e, ok := k.locks[key]
+	if !ok {
+		e = &keyedEntry{}
+		k.locks[key] = e
+	}
+	e.refs++
+	k.mu.Unlock()
+
+	e.mu.Lock()
+	return func() {
+		e.mu.Unlock()
+		k.mu.Lock()
+		if e.refs--; e.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// lockNode serializes writers of one node: every read-modify-write of a
+// ref (load the current version, build the next, point the ref at it)
+// runs under it, so concurrent edits to the same node cannot lose each
+// other's changes. Writers of different nodes proceed in parallel.
+func (r *Repository) lockNode(id string) (unlock func()) {
+	return r.shared.nodeLocks.Lock(id)
+}
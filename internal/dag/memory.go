@@ -0,0 +1,187 @@
+package dag
+
+import (
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// megabyte converts MemoryConfig sizes to bytes.
+const megabyte = 1 << 20
+
+// IndexMemory is what one in-memory index holds. Bytes are estimates from
+// the sizes of its strings plus a fixed overhead per map entry.
+type IndexMemory struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"` // postings, pairs or links in memory
+	Bytes   int64  `json:"bytes"`
+	Budget  int64  `json:"budget,omitempty"` // 0 is unbounded
+	// Spilled and DiskBytes are the entries kept on disk instead, and
+	// the space they take there.
+	Spilled   int   `json:"spilled,omitempty"`
+	DiskBytes int64 `json:"disk_bytes,omitempty"`
+}
+
+// MemoryReport is what the in-memory indexes hold, beside what the Go
+// runtime has allocated in all.
+type MemoryReport struct {
+	Indexes   []IndexMemory `json:"indexes"`
+	HeapBytes uint64        `json:"heap_bytes"` // live heap objects
+	SysBytes  uint64        `json:"sys_bytes"`  // obtained from the OS
+}
+
+// applyMemoryConfig sets the budgets cfg gives on r's indexes. It runs
+// before they are built.
+func (r *Repository) applyMemoryConfig(cfg *MemoryConfig) error {
+	var search, co int64
+	if cfg != nil {
+		search, co = int64(cfg.SearchMB)*megabyte, int64(cfg.CoIndexMB)*megabyte
+	}
+	if err := r.Search.SetMemoryBudget(filepath.Join(r.MxDir(), "search-spill"), search); err != nil {
+		return err
+	}
+	r.CoAccess.SetMemoryBudget(co)
+	r.CoChange.SetMemoryBudget(co)
+	return nil
+}
+
+// Memory reports what r's larger in-memory indexes hold.
+func (r *Repository) Memory() *MemoryReport {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return &MemoryReport{
+		Indexes: []IndexMemory{
+			r.Search.Memory(),
+			r.Links.Memory(),
+			r.CoAccess.Memory(),
+			r.CoChange.Memory(),
+		},
+		HeapBytes: ms.HeapAlloc,
+		SysBytes:  ms.Sys,
+	}
+}
+
+// Memory reports what s holds.
+func (s *SearchIndex) Memory() IndexMemory {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m := IndexMemory{Name: "search", Bytes: s.size}
+	for _, ids := range s.index {
+		m.Entries += len(ids)
+	}
+	if s.spill != nil {
+		m.Budget, m.DiskBytes = s.spill.budget, s.spill.disk
+		for _, lines := range s.spill.terms {
+			m.Spilled += lines
+		}
+	}
+	return m
+}
+
+// Memory reports what idx holds: each link is kept twice, by source and
+// by target.
+func (idx *LinkIndex) Memory() IndexMemory {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	m := IndexMemory{Name: "links", Entries: idx.live}
+	for _, side := range []map[string][]LinkEntry{idx.forward, idx.reverse} {
+		for key, links := range side {
+			m.Bytes += termBytes(key)
+			for _, l := range links {
+				m.Bytes += int64(len(l.Source)+len(l.Target)+len(l.Type)) + entryOverhead
+			}
+		}
+	}
+	return m
+}
+
+// pairsMemory estimates the size of a co-occurrence matrix.
+func pairsMemory(pairs map[string]map[string]int) (entries int, bytes int64) {
+	for a, peers := range pairs {
+		bytes += termBytes(a)
+		for b := range peers {
+			bytes += entryBytes(b)
+		}
+		entries += len(peers)
+	}
+	return entries, bytes
+}
+
+// prunePairs forgets the weakest pairs, those seen together least often,
+// until the matrix fits in budget bytes. Pairs of equal count go together,
+// so what is left does not depend on map order. Returns how many entries
+// it dropped.
+func prunePairs(pairs map[string]map[string]int, budget int64) int {
+	if budget <= 0 {
+		return 0
+	}
+	_, size := pairsMemory(pairs)
+	if size <= budget {
+		return 0
+	}
+	var counts []int
+	seen := map[int]bool{}
+	for _, peers := range pairs {
+		for _, n := range peers {
+			if !seen[n] {
+				seen[n] = true
+				counts = append(counts, n)
+			}
+		}
+	}
+	sort.Ints(counts)
+	dropped := 0
+	for _, threshold := range counts {
+		for a, peers := range pairs {
+			for b, n := range peers {
+				if n <= threshold {
+					delete(peers, b)
+					size -= entryBytes(b)
+					dropped++
+				}
+			}
+			if len(peers) == 0 {
+				delete(pairs, a)
+				size -= termBytes(a)
+			}
+		}
+		if size <= budget {
+			break
+		}
+	}
+	return dropped
+}
+
+// SetMemoryBudget bounds idx to about budget bytes, forgetting its weakest
+// pairs beyond it; 0 removes the bound.
+func (idx *CoAccessIndex) SetMemoryBudget(budget int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.budget = budget
+	prunePairs(idx.pairs, budget)
+}
+
+// Memory reports what idx holds.
+func (idx *CoAccessIndex) Memory() IndexMemory {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	entries, bytes := pairsMemory(idx.pairs)
+	return IndexMemory{Name: "coaccess", Entries: entries, Bytes: bytes, Budget: idx.budget}
+}
+
+// SetMemoryBudget bounds idx to about budget bytes, forgetting its weakest
+// pairs beyond it; 0 removes the bound.
+func (idx *CoChangeIndex) SetMemoryBudget(budget int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.budget = budget
+	prunePairs(idx.pairs, budget)
+}
+
+// Memory reports what idx holds.
+func (idx *CoChangeIndex) Memory() IndexMemory {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	entries, bytes := pairsMemory(idx.pairs)
+	return IndexMemory{Name: "cochange", Entries: entries, Bytes: bytes, Budget: idx.budget}
+}
@@ -0,0 +1,151 @@
+package dag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSearchIndex_Spill(t *testing.T) {
+	s := NewSearchIndex()
+	if err := s.SetMemoryBudget(filepath.Join(t.TempDir(), "spill"), 4096); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 50 {
+		s.IndexNode(fmt.Sprintf("note:%d", i), &NodeEnvelope{Type: "Note", Meta: map[string]interface{}{
+			"title": fmt.Sprintf("common airship word%d", i),
+		}})
+	}
+	m := s.Memory()
+	if m.Spilled == 0 || m.DiskBytes == 0 || m.Bytes > m.Budget {
+		t.Fatalf("memory = %+v, want postings spilled within budget", m)
+	}
+	if got := s.Search("common airship", 0); len(got) != 50 {
+		t.Errorf("search across spilled postings found %d, want 50", len(got))
+	}
+	if got := s.SearchPrefix("airs", 0); len(got) != 50 {
+		t.Errorf("prefix search found %d, want 50", len(got))
+	}
+
+	s.RemoveNode("note:7")
+	s.RemoveNode("note:8") // as an update does
+	s.IndexNode("note:8", &NodeEnvelope{Type: "Note", Meta: map[string]interface{}{"title": "common"}})
+	got := s.Search("airship", 0)
+	if len(got) != 48 {
+		t.Errorf("after a removal and a re-index, airship finds %d, want 48", len(got))
+	}
+	for _, id := range got {
+		if id == "note:7" {
+			t.Error("removed node still found")
+		}
+	}
+	if got := s.Search("common", 0); len(got) != 49 {
+		t.Errorf("common finds %d, want 49", len(got))
+	}
+
+	dropped, err := s.Compact()
+	if err != nil || dropped == 0 {
+		t.Errorf("Compact = %d, %v", dropped, err)
+	}
+	if got := s.Search("common airship", 0); len(got) != 49 {
+		t.Errorf("after compaction found %d, want 49", len(got))
+	}
+}
+
+func TestPrunePairs(t *testing.T) {
+	pairs := map[string]map[string]int{}
+	add := func(a, b string, n int) {
+		for _, p := range [][2]string{{a, b}, {b, a}} {
+			if pairs[p[0]] == nil {
+				pairs[p[0]] = map[string]int{}
+			}
+			pairs[p[0]][p[1]] = n
+		}
+	}
+	add("a", "b", 5)
+	add("a", "c", 1)
+	add("c", "d", 1)
+	add("b", "d", 2)
+	_, size := pairsMemory(pairs)
+
+	if dropped := prunePairs(pairs, size); dropped != 0 {
+		t.Errorf("within budget, dropped %d", dropped)
+	}
+	prunePairs(pairs, size-1)
+	if len(pairs["c"]) != 0 || pairs["a"]["b"] != 5 || pairs["b"]["d"] != 2 {
+		t.Errorf("after pruning the weakest: %v", pairs)
+	}
+	prunePairs(pairs, 1)
+	if len(pairs) != 0 {
+		t.Errorf("a tiny budget kept %v", pairs)
+	}
+}
+
+func TestRepository_Memory(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("alpha"), nil)
+	repo.CreateNode("note:b", "Note", []byte("beta"), nil)
+	repo.CreateLink("note:a", "note:b", "RELATED")
+	report := repo.Memory()
+	byName := map[string]IndexMemory{}
+	for _, m := range report.Indexes {
+		byName[m.Name] = m
+	}
+	if byName["search"].Entries == 0 || byName["links"].Entries != 1 || report.HeapBytes == 0 {
+		t.Errorf("memory report = %+v", report)
+	}
+}
+
+func TestLowMemoryProfile(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	var log []byte
+	for _, id := range []string{"note:a", "note:b"} {
+		log = append(log, fmt.Sprintf(`{"ts":%q,"node":%q,"field":"content"}`+"\n", now, id)...)
+	}
+	if err := os.WriteFile(filepath.Join(repo.MxDir(), "access.jsonl"), log, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openRepository(repo.root, false, "tiny"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+	def, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Profile() != "" || len(def.CoAccess.Related("note:a", 0)) != 1 || def.Search.Memory().Budget != 0 {
+		t.Fatalf("default profile: %q, co-access %v, %+v", def.Profile(), def.CoAccess.Related("note:a", 0), def.Search.Memory())
+	}
+
+	if err := os.WriteFile(filepath.Join(repo.MxDir(), "config.json"), []byte(`{"profile": "low-memory"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	low, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.Profile() != ProfileLowMemory {
+		t.Fatalf("profile = %q", low.Profile())
+	}
+	if got := low.CoAccess.Related("note:a", 0); len(got) != 0 {
+		t.Errorf("access log replayed under low-memory: %v", got)
+	}
+	if m := low.Search.Memory(); m.Budget != int64(lowMemoryBudget.SearchMB)*megabyte {
+		t.Errorf("search budget = %d", m.Budget)
+	}
+	if m := low.CoAccess.Memory(); m.Budget != int64(lowMemoryBudget.CoIndexMB)*megabyte {
+		t.Errorf("co-access budget = %d", m.Budget)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo.MxDir(), "config.json"), []byte(`{"profile": "small"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRepository(repo.root); err == nil {
+		t.Error("config with an unknown profile accepted")
+	}
+}
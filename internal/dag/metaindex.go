@@ -0,0 +1,336 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaType is the node type whose meta declares indexed keys. A Schema
+// node with meta {"indexes": ["due", "priority"]} adds those keys to the
+// MetaIndex for as long as it exists.
+const SchemaType = "Schema"
+
+const schemaIndexesKey = "indexes"
+
+// MetaIndex maps declared meta keys to the values each live node holds for
+// them, for exact and range lookup without a scan. Keys are declared in
+// config (meta_indexes) or by Schema nodes. Array values index each
+// element. The index is persisted by Repository.SaveIndexes and reused on
+// open when it was saved at the current HEAD.
+type MetaIndex struct {
+	mu         sync.RWMutex
+	path       string
+	configKeys []string
+	schemas    map[string][]string            // Schema node ID -> keys it declares
+	values     map[string]map[string][]string // key -> node ID -> values
+	dirty      bool
+	savedHead  string
+}
+
+// metaIndexSnapshot is the on-disk form of a MetaIndex.
+type metaIndexSnapshot struct {
+	Head       string                         `json:"head"`
+	ConfigKeys []string                       `json:"config_keys"`
+	Schemas    map[string][]string            `json:"schemas"`
+	Values     map[string]map[string][]string `json:"values"`
+}
+
+// NewMetaIndex creates an empty MetaIndex persisted at path, with
+// configKeys declared up front.
+func NewMetaIndex(path string, configKeys []string) *MetaIndex {
+	keys := append([]string(nil), configKeys...)
+	sort.Strings(keys)
+	m := &MetaIndex{
+		path:       path,
+		configKeys: keys,
+		schemas:    make(map[string][]string),
+		values:     make(map[string]map[string][]string),
+	}
+	for _, k := range keys {
+		m.values[k] = make(map[string][]string)
+	}
+	return m
+}
+
+// Load replaces the index with the snapshot on disk if it was saved at head
+// with the same config keys. Reports whether the snapshot was used; if not,
+// the caller must index every node.
+func (m *MetaIndex) Load(head string) bool {
+	if head == "" {
+		return false
+	}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return false
+	}
+	var snap metaIndexSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false
+	}
+	if snap.Head != head || strings.Join(snap.ConfigKeys, "\x00") != strings.Join(m.configKeys, "\x00") {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schemas = snap.Schemas
+	if m.schemas == nil {
+		m.schemas = make(map[string][]string)
+	}
+	m.values = snap.Values
+	if m.values == nil {
+		m.values = make(map[string]map[string][]string)
+	}
+	for k := range m.declared() {
+		if m.values[k] == nil {
+			m.values[k] = make(map[string][]string)
+		}
+	}
+	m.dirty = false
+	m.savedHead = head
+	return true
+}
+
+// Save writes the index to disk stamped with head, unless nothing changed
+// since it was last saved at head. Keys no longer declared by anything are
+// dropped first.
+func (m *MetaIndex) Save(head string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty && head == m.savedHead {
+		return nil
+	}
+	declared := m.declared()
+	for k := range m.values {
+		if !declared[k] {
+			delete(m.values, k)
+		}
+	}
+	data, err := json.Marshal(metaIndexSnapshot{
+		Head:       head,
+		ConfigKeys: m.configKeys,
+		Schemas:    m.schemas,
+		Values:     m.values,
+	})
+	if err != nil {
+		return err
+	}
+	if err := SafeWrite(m.path, data, 0644); err != nil {
+		return fmt.Errorf("save meta index: %w", err)
+	}
+	m.dirty = false
+	m.savedHead = head
+	return nil
+}
+
+// declared returns the union of config and Schema keys. Caller holds mu.
+func (m *MetaIndex) declared() map[string]bool {
+	keys := make(map[string]bool)
+	for _, k := range m.configKeys {
+		keys[k] = true
+	}
+	for _, ks := range m.schemas {
+		for _, k := range ks {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+// Keys returns every declared key, sorted.
+func (m *MetaIndex) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0)
+	for k := range m.declared() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Indexed reports whether key is declared.
+func (m *MetaIndex) Indexed(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.declared()[key]
+}
+
+// IndexNode records node's values for every tracked key. If node is a
+// Schema that declares keys not tracked before, those keys are returned and
+// the caller must feed every live node through IndexNode again to backfill
+// them.
+func (m *MetaIndex) IndexNode(id string, node *NodeEnvelope) (added []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if node.Type == SchemaType {
+		keys := schemaKeys(node)
+		m.schemas[id] = keys
+		for _, k := range keys {
+			if m.values[k] == nil {
+				m.values[k] = make(map[string][]string)
+				added = append(added, k)
+			}
+		}
+	}
+
+	// Maintain every tracked key, not just declared ones, so a key whose
+	// Schema is being replaced stays current until Save prunes it.
+	for k, byID := range m.values {
+		vals := metaValues(node.Meta[k])
+		if len(vals) == 0 {
+			delete(byID, id)
+		} else {
+			byID[id] = vals
+		}
+	}
+	m.dirty = true
+	return added
+}
+
+// RemoveNode drops id from the index. Removing a Schema undeclares its keys.
+func (m *MetaIndex) RemoveNode(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.schemas, id)
+	for _, byID := range m.values {
+		delete(byID, id)
+	}
+	m.dirty = true
+}
+
+// Get returns id's indexed values for key.
+func (m *MetaIndex) Get(key, id string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.declared()[key] {
+		return nil
+	}
+	return m.values[key][id]
+}
+
+// Exact returns the IDs of nodes with value among their values for key,
+// sorted.
+func (m *MetaIndex) Exact(key, value string) []string {
+	return m.match(key, func(v string) bool { return v == value })
+}
+
+// Range returns the IDs of nodes with a value for key in [lo, hi], sorted.
+// An empty bound is open. Values compare numerically when both sides parse
+// as numbers and as strings otherwise, so ISO dates range correctly.
+func (m *MetaIndex) Range(key, lo, hi string) []string {
+	return m.match(key, func(v string) bool {
+		return (lo == "" || compareMetaValues(v, lo) >= 0) &&
+			(hi == "" || compareMetaValues(v, hi) <= 0)
+	})
+}
+
+func (m *MetaIndex) match(key string, ok func(string) bool) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.declared()[key] {
+		return nil
+	}
+	var ids []string
+	for id, vals := range m.values[key] {
+		for _, v := range vals {
+			if ok(v) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Values returns the distinct values held for key, in comparison order.
+func (m *MetaIndex) Values(key string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.declared()[key] {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, vals := range m.values[key] {
+		for _, v := range vals {
+			if !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+	}
+	sortMetaValues(out)
+	return out
+}
+
+// schemaKeys reads the keys a Schema node declares.
+func schemaKeys(node *NodeEnvelope) []string {
+	var keys []string
+	for _, k := range metaValues(node.Meta[schemaIndexesKey]) {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// metaValues normalizes a decoded meta value to the strings it is indexed
+// under. Arrays index each element; objects are not indexed.
+func metaValues(v interface{}) []string {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{t}
+	case float64:
+		return []string{strconv.FormatFloat(t, 'f', -1, 64)}
+	case int:
+		return []string{strconv.Itoa(t)}
+	case bool:
+		return []string{strconv.FormatBool(t)}
+	case []interface{}:
+		var out []string
+		for _, e := range t {
+			out = append(out, metaValues(e)...)
+		}
+		return out
+	case []string:
+		return append([]string(nil), t...)
+	}
+	return nil
+}
+
+// compareMetaValues orders a and b numerically if both are numbers.
+func compareMetaValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// sortMetaValues sorts by compareMetaValues, breaking numeric ties ("1" vs
+// "1.0") by string so the order is stable.
+func sortMetaValues(vals []string) {
+	sort.Slice(vals, func(i, j int) bool {
+		if c := compareMetaValues(vals[i], vals[j]); c != 0 {
+			return c < 0
+		}
+		return vals[i] < vals[j]
+	})
+}
@@ -0,0 +1,96 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetaIndex_ConfigKeysExactAndRange(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".mx"), 0755)
+	os.WriteFile(filepath.Join(dir, ".mx", "config.json"), []byte(`{"meta_indexes": ["priority", "due"]}`), 0644)
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.CreateNode("task:a", "Task", nil, map[string]interface{}{"priority": 1.0, "due": "2026-01-05"})
+	repo.CreateNode("task:b", "Task", nil, map[string]interface{}{"priority": 10.0, "due": "2026-02-01"})
+	repo.CreateNode("task:c", "Task", nil, map[string]interface{}{"priority": 2.0, "owner": "x"})
+
+	if got := repo.Meta.Exact("priority", "10"); !reflect.DeepEqual(got, []string{"task:b"}) {
+		t.Errorf("Exact(priority, 10) = %v", got)
+	}
+	// Numeric, not lexical: 2 <= p <= 10 excludes 1.
+	if got := repo.Meta.Range("priority", "2", "10"); !reflect.DeepEqual(got, []string{"task:b", "task:c"}) {
+		t.Errorf("Range(priority, 2, 10) = %v", got)
+	}
+	if got := repo.Meta.Range("due", "2026-01-01", "2026-01-31"); !reflect.DeepEqual(got, []string{"task:a"}) {
+		t.Errorf("Range(due, January) = %v", got)
+	}
+	if got := repo.Meta.Exact("owner", "x"); got != nil {
+		t.Errorf("undeclared key should not be queryable, got %v", got)
+	}
+
+	repo.UpdateNode("task:a", map[string]interface{}{"priority": nil})
+	if got := repo.Meta.Get("priority", "task:a"); got != nil {
+		t.Errorf("removed meta still indexed: %v", got)
+	}
+	repo.DeleteNode("task:b", false)
+	if got := repo.Meta.Values("priority"); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("Values(priority) after delete = %v, want [2]", got)
+	}
+}
+
+func TestMetaIndex_SchemaDeclaresAndBackfills(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("book:a", "Book", nil, map[string]interface{}{"tags": []interface{}{"sf", "classic"}})
+	repo.CreateNode("book:b", "Book", nil, map[string]interface{}{"tags": []interface{}{"sf"}})
+
+	if repo.Meta.Indexed("tags") {
+		t.Fatal("tags indexed before any Schema declared it")
+	}
+	repo.CreateNode("schema:books", SchemaType, nil, map[string]interface{}{"indexes": []interface{}{"tags"}})
+	if got := repo.Meta.Exact("tags", "sf"); !reflect.DeepEqual(got, []string{"book:a", "book:b"}) {
+		t.Errorf("Exact(tags, sf) after backfill = %v", got)
+	}
+
+	repo.DeleteNode("schema:books", false)
+	if repo.Meta.Indexed("tags") {
+		t.Error("tags still declared after its Schema was deleted")
+	}
+}
+
+func TestMetaIndex_PersistsAtHead(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.CreateNode("schema:s", SchemaType, nil, map[string]interface{}{"indexes": []interface{}{"status"}})
+	repo.CreateNode("task:a", "Task", nil, map[string]interface{}{"status": "open"})
+	if err := repo.SaveIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMetaIndex(filepath.Join(dir, ".mx", "metaindex.json"), nil)
+	if !m.Load(repo.headKey()) {
+		t.Fatal("snapshot not reusable at current HEAD")
+	}
+	if got := m.Exact("status", "open"); !reflect.DeepEqual(got, []string{"task:a"}) {
+		t.Errorf("loaded Exact(status, open) = %v", got)
+	}
+	if m.Load("bafkstale") {
+		t.Error("snapshot reused at a different HEAD")
+	}
+
+	reopened, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Meta.Exact("status", "open"); !reflect.DeepEqual(got, []string{"task:a"}) {
+		t.Errorf("reopened Exact(status, open) = %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package dag
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ReservedMetaKeys are meta keys memex-fs and its import and sync paths
+// set themselves. A user's meta write may carry them through unchanged,
+// as a round-tripped meta.json does, but may not set, change or remove
+// them.
+var ReservedMetaKeys = []string{"author", "ipfs_cid", "verified", MetaImportedFrom, MetaIngestedFrom, MetaACL}
+
+// MetaError is a meta write rejected by ValidateMeta.
+type MetaError struct {
+	Key    string
+	Reason string
+}
+
+func (e *MetaError) Error() string {
+	return fmt.Sprintf("meta key %q: %s", e.Key, e.Reason)
+}
+
+// Is makes a MetaError match ErrValidation.
+func (e *MetaError) Is(target error) bool { return target == ErrValidation }
+
+// ValidateMeta checks a user's meta updates to node, in the form
+// UpdateNode takes them (nil deletes a key): reserved keys must keep
+// their current value, and keys memex-fs interprets for node's type must
+// hold values it can read. The first offending key, in sorted order, is
+// reported as a *MetaError.
+func ValidateMeta(node *NodeEnvelope, updates map[string]interface{}) error {
+	keys := make([]string, 0, len(updates))
+	for k := range updates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := updates[k]
+		if slices.Contains(ReservedMetaKeys, k) {
+			if !reflect.DeepEqual(v, node.Meta[k]) {
+				return &MetaError{Key: k, Reason: "managed by memex-fs; use another key"}
+			}
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		if reason := checkMetaValue(node.Type, k, v); reason != "" {
+			return &MetaError{Key: k, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// checkMetaValue returns why v is not a usable value of key on a node of
+// type typ, or "" if it is.
+func checkMetaValue(typ, key string, v interface{}) string {
+	switch {
+	case key == journalDateKey,
+		key == taskDueKey && (typ == TaskType || typ == FlashcardType):
+		s, _ := v.(string)
+		if _, ok := parseMetaDay(s); !ok {
+			return "want a YYYY-MM-DD day or RFC3339 timestamp"
+		}
+	case typ == TaskType && key == taskStatusKey:
+		if s, _ := v.(string); !slices.Contains(TaskStatuses, s) {
+			return "want one of " + strings.Join(TaskStatuses, ", ")
+		}
+	case key == MetaTrashRetention:
+		if _, ok := metaFloat(v); !ok {
+			return "want a number of days, negative to keep forever"
+		}
+	case typ == FlashcardType && (key == srsEaseKey || key == srsIntervalKey || key == srsRepsKey):
+		if f, ok := metaFloat(v); !ok || f < 0 {
+			return "want a non-negative number"
+		}
+	case typ == SchemaType && key == schemaIndexesKey:
+		switch t := v.(type) {
+		case string:
+		case []interface{}:
+			for _, e := range t {
+				if _, ok := e.(string); !ok {
+					return "want a key name or a list of key names"
+				}
+			}
+		default:
+			return "want a key name or a list of key names"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateMeta(t *testing.T) {
+	node := &NodeEnvelope{Type: TaskType, Meta: map[string]interface{}{"ipfs_cid": "bafyabc", "status": "open"}}
+
+	for _, tc := range []struct {
+		updates map[string]interface{}
+		bad     string // offending key; "" if valid
+	}{
+		{map[string]interface{}{"topic": "x", "status": "doing", "due": "2026-03-01"}, ""},
+		{map[string]interface{}{"ipfs_cid": "bafyabc", "status": nil}, ""}, // round-tripped reserved key
+		{map[string]interface{}{"ipfs_cid": "bafyforged"}, "ipfs_cid"},
+		{map[string]interface{}{"ipfs_cid": nil}, "ipfs_cid"},
+		{map[string]interface{}{"verified": true}, "verified"},
+		{map[string]interface{}{"status": "someday"}, "status"},
+		{map[string]interface{}{"due": "next week"}, "due"},
+		{map[string]interface{}{"date": 20260301.0}, "date"},
+	} {
+		err := ValidateMeta(node, tc.updates)
+		var me *MetaError
+		switch {
+		case tc.bad == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tc.updates, err)
+		case tc.bad != "" && (!errors.As(err, &me) || me.Key != tc.bad):
+			t.Errorf("%v: error = %v, want one for %q", tc.updates, err, tc.bad)
+		}
+	}
+
+	// Known keys are only checked on the types that interpret them.
+	if err := ValidateMeta(&NodeEnvelope{Type: "Note"}, map[string]interface{}{"status": "someday", "ease": "high"}); err != nil {
+		t.Errorf("note meta rejected: %v", err)
+	}
+	if err := ValidateMeta(&NodeEnvelope{Type: FlashcardType}, map[string]interface{}{"ease": "high"}); err == nil {
+		t.Error("non-numeric flashcard ease accepted")
+	}
+}
@@ -17,6 +17,9 @@ type NodeEnvelope struct {
 	Modified time.Time              `json:"modified"`
 	Prev     string                 `json:"prev,omitempty"`
 	Deleted  bool                   `json:"deleted,omitempty"`
+	// Author is the DID of whoever wrote this version. Empty for versions
+	// written without an identity, or before nodes recorded one.
+	Author string `json:"author,omitempty"`
 }
 
 // CanonicalJSON produces a deterministic JSON encoding with sorted keys.
@@ -0,0 +1,79 @@
+package dag
+
+import (
+	"sort"
+	"time"
+)
+
+// contextPanelLimit caps the related and co-accessed sections of a
+// NodeContext.
+const contextPanelLimit = 10
+
+// RecentNode is a node ID with the last time it was read.
+type RecentNode struct {
+	ID         string
+	LastAccess time.Time
+}
+
+// NodeContext is the context panel for one node: who links to it, what is
+// related to it and with what score, and which notes were read alongside
+// it most recently. Only live nodes are listed.
+type NodeContext struct {
+	ID         string
+	Backlinks  []LinkEntry
+	Related    []ScoredNode
+	CoAccessed []RecentNode // most recently read first
+}
+
+// Context assembles id's context panel from the link index and the usage
+// signals. It fails only if id is not a live node.
+func (r *Repository) Context(id string) (*NodeContext, error) {
+	if _, err := r.GetNode(id); err != nil {
+		return nil, err
+	}
+	live := func(other string) bool {
+		if other == id {
+			return false
+		}
+		_, err := r.GetNode(LinkTargetParent(other))
+		return err == nil
+	}
+
+	c := &NodeContext{ID: id}
+	for _, l := range r.Links.LinksTo(id) {
+		if live(l.Source) {
+			c.Backlinks = append(c.Backlinks, l)
+		}
+	}
+	sort.Slice(c.Backlinks, func(i, j int) bool {
+		a, b := c.Backlinks[i], c.Backlinks[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Type < b.Type
+	})
+
+	for _, s := range r.Relatedness.RelatedScored(id, 0) {
+		if len(c.Related) == contextPanelLimit {
+			break
+		}
+		if live(s.ID) {
+			c.Related = append(c.Related, s)
+		}
+	}
+
+	for _, peer := range r.CoAccess.Related(id, 0) {
+		if !live(peer) {
+			continue
+		}
+		last, _ := r.Recency.LastAccess(peer)
+		c.CoAccessed = append(c.CoAccessed, RecentNode{ID: peer, LastAccess: last})
+	}
+	sort.SliceStable(c.CoAccessed, func(i, j int) bool {
+		return c.CoAccessed[i].LastAccess.After(c.CoAccessed[j].LastAccess)
+	})
+	if len(c.CoAccessed) > contextPanelLimit {
+		c.CoAccessed = c.CoAccessed[:contextPanelLimit]
+	}
+	return c, nil
+}
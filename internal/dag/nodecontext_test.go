@@ -0,0 +1,43 @@
+package dag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContext(t *testing.T) {
+	repo := openTestRepo(t)
+	for _, id := range []string{"note:a", "note:b", "note:c", "note:gone"} {
+		repo.CreateNode(id, "Note", []byte(id), nil)
+	}
+	repo.CreateLink("note:b", "note:a", "cites")
+	repo.CreateLink("note:gone", "note:a", "cites")
+
+	// One reading session touching a, c and gone; the later read closes it.
+	t0 := time.Now().Add(-time.Hour)
+	for i, id := range []string{"note:a", "note:c", "note:gone"} {
+		ts := t0.Add(time.Duration(i) * time.Second)
+		repo.CoAccess.Record(id, ts)
+		repo.Recency.RecordAccess(id, ts)
+	}
+	repo.CoAccess.Record("note:b", time.Now())
+	repo.DeleteNode("note:gone", false)
+
+	c, err := repo.Context("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Backlinks) != 1 || c.Backlinks[0].Source != "note:b" || c.Backlinks[0].Type != "cites" {
+		t.Errorf("backlinks = %+v, want only the live note:b", c.Backlinks)
+	}
+	if len(c.Related) != 1 || c.Related[0] != (ScoredNode{"note:c", 1}) {
+		t.Errorf("related = %+v", c.Related)
+	}
+	if len(c.CoAccessed) != 1 || c.CoAccessed[0].ID != "note:c" || c.CoAccessed[0].LastAccess.IsZero() {
+		t.Errorf("co-accessed = %+v", c.CoAccessed)
+	}
+
+	if _, err := repo.Context("note:gone"); err == nil {
+		t.Error("Context of a deleted node should fail")
+	}
+}
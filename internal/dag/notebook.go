@@ -0,0 +1,216 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Meta keys derived from a Jupyter notebook's content each time it is
+// written; they are removed when the content stops being a notebook.
+const (
+	MetaNotebookLanguage = "notebook_language"
+	MetaNotebookCells    = "notebook_cells"
+	// MetaExecutionCounts lists each code cell's execution count, in
+	// order, null for a cell never run.
+	MetaExecutionCounts = "execution_counts"
+)
+
+// Notebook is a parsed Jupyter notebook (nbformat 4).
+type Notebook struct {
+	Language string
+	Cells    []NotebookCell
+}
+
+// NotebookCell is one cell of a notebook.
+type NotebookCell struct {
+	Type           string // "code", "markdown" or "raw"
+	Source         string
+	ExecutionCount int      // 0 for a code cell never run, and other cells
+	Outputs        []string // code cells' outputs, as text
+}
+
+// nbText is a notebook string field, which nbformat allows to be one
+// string or a list of lines to concatenate.
+type nbText string
+
+func (t *nbText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = nbText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*t = nbText(strings.Join(lines, ""))
+	return nil
+}
+
+type nbFile struct {
+	NBFormat *int `json:"nbformat"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType       string `json:"cell_type"`
+		Source         nbText `json:"source"`
+		ExecutionCount *int   `json:"execution_count"`
+		Outputs        []struct {
+			OutputType string            `json:"output_type"`
+			Text       nbText            `json:"text"`
+			Data       map[string]nbText `json:"data"`
+			EName      string            `json:"ename"`
+			EValue     string            `json:"evalue"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// ParseNotebook parses content as a Jupyter notebook. Content that is
+// not notebook JSON (nbformat 4 or later) is an error.
+func ParseNotebook(content []byte) (*Notebook, error) {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(`"nbformat"`)) {
+		return nil, fmt.Errorf("not a notebook")
+	}
+	var f nbFile
+	if err := json.Unmarshal(trimmed, &f); err != nil {
+		return nil, fmt.Errorf("parse notebook: %w", err)
+	}
+	if f.NBFormat == nil || *f.NBFormat < 4 {
+		return nil, fmt.Errorf("not a notebook (need nbformat 4)")
+	}
+	nb := &Notebook{Language: f.Metadata.LanguageInfo.Name}
+	if nb.Language == "" {
+		nb.Language = f.Metadata.KernelSpec.Language
+	}
+	for _, c := range f.Cells {
+		cell := NotebookCell{Type: c.CellType, Source: string(c.Source)}
+		if c.ExecutionCount != nil {
+			cell.ExecutionCount = *c.ExecutionCount
+		}
+		for _, o := range c.Outputs {
+			var text string
+			switch o.OutputType {
+			case "stream":
+				text = string(o.Text)
+			case "execute_result", "display_data":
+				if t, ok := o.Data["text/plain"]; ok {
+					text = string(t)
+				} else if len(o.Data) > 0 {
+					mimes := make([]string, 0, len(o.Data))
+					for mime := range o.Data {
+						mimes = append(mimes, mime)
+					}
+					sort.Strings(mimes)
+					text = "[" + mimes[0] + " output]"
+				}
+			case "error":
+				text = o.EName + ": " + o.EValue
+			}
+			if text != "" {
+				cell.Outputs = append(cell.Outputs, strings.TrimRight(text, "\n"))
+			}
+		}
+		nb.Cells = append(nb.Cells, cell)
+	}
+	return nb, nil
+}
+
+// Markdown renders the notebook as one Markdown document: markdown cells
+// as they are, code cells fenced in the notebook's language with their
+// execution count and outputs, raw cells verbatim.
+func (nb *Notebook) Markdown() []byte {
+	var b strings.Builder
+	for i, c := range nb.Cells {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if c.Type == "code" {
+			b.WriteString(nb.renderCode(c))
+		} else {
+			b.WriteString(strings.TrimRight(c.Source, "\n") + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+func (nb *Notebook) renderCode(c NotebookCell) string {
+	var b strings.Builder
+	if c.ExecutionCount > 0 {
+		fmt.Fprintf(&b, "In [%d]:\n\n", c.ExecutionCount)
+	}
+	b.WriteString("```" + nb.Language + "\n" + strings.TrimRight(c.Source, "\n") + "\n```\n")
+	if len(c.Outputs) > 0 {
+		b.WriteString("\n```\n" + strings.Join(c.Outputs, "\n") + "\n```\n")
+	}
+	return b.String()
+}
+
+// CellExt returns the file extension a cell's source is shown with:
+// "md" for markdown, the language's usual one for code, "txt" otherwise.
+func (nb *Notebook) CellExt(c NotebookCell) string {
+	switch c.Type {
+	case "markdown":
+		return "md"
+	case "code":
+		switch strings.ToLower(nb.Language) {
+		case "python":
+			return "py"
+		case "r":
+			return "r"
+		case "julia":
+			return "jl"
+		case "javascript":
+			return "js"
+		case "typescript":
+			return "ts"
+		case "scala":
+			return "scala"
+		case "bash", "sh":
+			return "sh"
+		}
+	}
+	return "txt"
+}
+
+// notebookMeta returns the meta updates that keep a node's derived
+// notebook keys in step with content: set when it is a notebook, removed
+// (nil) when it is not but meta still has them.
+func notebookMeta(content []byte, meta map[string]interface{}) map[string]interface{} {
+	nb, err := ParseNotebook(content)
+	if err != nil {
+		if _, ok := meta[MetaNotebookCells]; !ok {
+			return nil
+		}
+		return map[string]interface{}{MetaNotebookLanguage: nil, MetaNotebookCells: nil, MetaExecutionCounts: nil}
+	}
+	counts := []interface{}{}
+	for _, c := range nb.Cells {
+		if c.Type != "code" {
+			continue
+		}
+		if c.ExecutionCount > 0 {
+			counts = append(counts, c.ExecutionCount)
+		} else {
+			counts = append(counts, nil)
+		}
+	}
+	updates := map[string]interface{}{
+		MetaNotebookLanguage: nil,
+		MetaNotebookCells:    len(nb.Cells),
+		MetaExecutionCounts:  counts,
+	}
+	if nb.Language != "" {
+		updates[MetaNotebookLanguage] = nb.Language
+	}
+	return updates
+}
@@ -0,0 +1,75 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "nbformat": 4, "nbformat_minor": 5,
+ "metadata": {"kernelspec": {"language": "python"}, "language_info": {"name": "python"}},
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Fit\n", "A quick model."]},
+  {"cell_type": "code", "execution_count": 3, "source": "print(2 + 2)\nx", "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["4\n"]},
+   {"output_type": "execute_result", "data": {"text/plain": "42", "text/html": "<b>42</b>"}}
+  ]},
+  {"cell_type": "code", "execution_count": null, "source": "plot()", "outputs": [
+   {"output_type": "display_data", "data": {"image/png": "iVBOR"}}
+  ]}
+ ]
+}`
+
+func TestParseNotebook(t *testing.T) {
+	nb, err := ParseNotebook([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.Language != "python" || len(nb.Cells) != 3 {
+		t.Fatalf("notebook = %+v", nb)
+	}
+	if c := nb.Cells[1]; c.ExecutionCount != 3 || !reflect.DeepEqual(c.Outputs, []string{"4", "42"}) {
+		t.Errorf("code cell = %+v", c)
+	}
+	if got := nb.Cells[2].Outputs; !reflect.DeepEqual(got, []string{"[image/png output]"}) {
+		t.Errorf("image outputs = %q", got)
+	}
+	md := string(nb.Markdown())
+	for _, want := range []string{"# Fit\nA quick model.\n", "In [3]:\n\n```python\nprint(2 + 2)\nx\n```\n\n```\n4\n42\n```\n"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() lacks %q:\n%s", want, md)
+		}
+	}
+	for _, content := range []string{`{"cells": []}`, "# notes", `{"nbformat": 3, "worksheets": []}`} {
+		if _, err := ParseNotebook([]byte(content)); err == nil {
+			t.Errorf("ParseNotebook(%q) succeeded", content)
+		}
+	}
+}
+
+func TestNotebookMeta_FollowsContent(t *testing.T) {
+	repo := openTestRepo(t)
+	id, _, err := repo.Ingest(testNotebook, "ipynb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _ := repo.GetNode(id)
+	if node.Meta[MetaNotebookCells] != float64(3) || node.Meta[MetaNotebookLanguage] != "python" ||
+		!reflect.DeepEqual(node.Meta[MetaExecutionCounts], []interface{}{float64(3), nil}) {
+		t.Errorf("meta = %v", node.Meta)
+	}
+
+	node, err = repo.UpdateContent(id, []byte("no longer a notebook"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{MetaNotebookCells, MetaNotebookLanguage, MetaExecutionCounts} {
+		if _, ok := node.Meta[k]; ok {
+			t.Errorf("meta %s kept after content stopped being a notebook", k)
+		}
+	}
+	if node.Meta["format"] != "ipynb" {
+		t.Errorf("other meta lost: %v", node.Meta)
+	}
+}
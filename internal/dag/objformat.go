@@ -0,0 +1,131 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// ObjectFormat is how the ObjectStore addresses new objects: the multihash
+// function and the CID codec. It is recorded as "hash" and "codec" in
+// .mx/meta.json. Changing it affects only objects written afterwards;
+// objects are always read and verified by the format their own CID names,
+// so a repository can hold a mix.
+type ObjectFormat struct {
+	Hash  string `json:"hash"`
+	Codec string `json:"codec"`
+}
+
+// DefaultObjectFormat is the format of repositories that record none:
+// CIDv1, raw codec, SHA2-256.
+var DefaultObjectFormat = ObjectFormat{Hash: "sha2-256", Codec: "raw"}
+
+// objectHashes are the multihash functions objects may be addressed by.
+var objectHashes = map[string]uint64{
+	"sha2-256":    multihash.SHA2_256,
+	"sha2-512":    multihash.SHA2_512,
+	"sha3-256":    multihash.SHA3_256,
+	"sha3-512":    multihash.SHA3_512,
+	"blake2b-256": multihash.BLAKE2B_MIN + 31,
+	"blake3":      multihash.BLAKE3,
+}
+
+// objectCodecs are the CID codecs objects may be labeled with. "raw" is
+// the historical default and "json" labels the same plain JSON accurately;
+// "dag-json" also changes the encoding, making objects traversable as IPLD
+// (see dagjson.go).
+var objectCodecs = map[string]uint64{
+	"raw":      gocid.Raw,
+	"json":     0x0200,
+	"dag-json": gocid.DagJSON,
+}
+
+// prefix returns the CID prefix f names.
+func (f ObjectFormat) prefix() (gocid.Prefix, error) {
+	hash, ok := objectHashes[f.Hash]
+	if !ok {
+		return gocid.Prefix{}, fmt.Errorf("unsupported object hash %q (want one of %s)", f.Hash, strings.Join(sortedKeys(objectHashes), ", "))
+	}
+	codec, ok := objectCodecs[f.Codec]
+	if !ok {
+		return gocid.Prefix{}, fmt.Errorf("unsupported object codec %q (want one of %s)", f.Codec, strings.Join(sortedKeys(objectCodecs), ", "))
+	}
+	return gocid.Prefix{Version: 1, Codec: codec, MhType: hash, MhLength: -1}, nil
+}
+
+// Sum computes the CID of data in format f.
+func (f ObjectFormat) Sum(data []byte) (gocid.Cid, error) {
+	p, err := f.prefix()
+	if err != nil {
+		return gocid.Undef, err
+	}
+	return p.Sum(data)
+}
+
+// FormatOf returns the ObjectFormat c was computed with, or false if c
+// uses a hash or codec objects cannot be written with.
+func FormatOf(c gocid.Cid) (ObjectFormat, bool) {
+	p := c.Prefix()
+	var f ObjectFormat
+	for name, code := range objectHashes {
+		if code == p.MhType {
+			f.Hash = name
+		}
+	}
+	for name, code := range objectCodecs {
+		if code == p.Codec {
+			f.Codec = name
+		}
+	}
+	return f, f.Hash != "" && f.Codec != ""
+}
+
+// CIDMatches reports whether data hashes to c under c's own format.
+func CIDMatches(c gocid.Cid, data []byte) bool {
+	sum, err := c.Prefix().Sum(data)
+	return err == nil && sum.Equals(c)
+}
+
+// loadObjectFormat reads the object format recorded in the meta.json at
+// path, defaulting what it does not record.
+func loadObjectFormat(path string) (ObjectFormat, error) {
+	f := DefaultObjectFormat
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("read repo meta: %w", err)
+	}
+	var meta struct {
+		Hash  string `json:"hash"`
+		Codec string `json:"codec"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return f, fmt.Errorf("parse repo meta: %w", err)
+	}
+	if meta.Hash != "" {
+		f.Hash = meta.Hash
+	}
+	if meta.Codec != "" {
+		f.Codec = meta.Codec
+	}
+	if _, err := f.prefix(); err != nil {
+		return f, fmt.Errorf("repo meta: %w", err)
+	}
+	return f, nil
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
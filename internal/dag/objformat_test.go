@@ -0,0 +1,120 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gocid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+func setObjectFormat(t *testing.T, dir, hash, codec string) {
+	t.Helper()
+	meta := `{"version": 1, "hash": "` + hash + `", "codec": "` + codec + `"}`
+	if err := os.WriteFile(filepath.Join(dir, ".mx", "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectFormat_MixedHashes(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateNode("a", "Note", []byte("v1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := repo.Refs.Get("a")
+	if old.Prefix().MhType != multihash.SHA2_256 {
+		t.Fatalf("default hash = %#x, want sha2-256", old.Prefix().MhType)
+	}
+
+	setObjectFormat(t, dir, "blake3", "json")
+	repo, err = OpenRepository(dir)
+	if err != nil {
+		t.Fatalf("reopen with blake3: %v", err)
+	}
+	if _, err := repo.GetNode("a"); err != nil {
+		t.Fatalf("read sha2-256 node: %v", err)
+	}
+	updated, err := repo.UpdateContent("a", []byte("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, _ := repo.Refs.Get("a")
+	if p := cur.Prefix(); p.MhType != multihash.BLAKE3 || p.Codec != 0x0200 {
+		t.Errorf("new version prefix = %+v, want blake3/json", p)
+	}
+	if f, ok := FormatOf(cur); !ok || f != (ObjectFormat{Hash: "blake3", Codec: "json"}) {
+		t.Errorf("FormatOf = %+v, %v", f, ok)
+	}
+	prev, err := repo.envelopeAt(updated.Prev)
+	if err != nil || string(prev.Content) != "v1" {
+		t.Fatalf("prev across formats = %v, %v", prev, err)
+	}
+	if _, err := repo.Commits.History(10); err != nil {
+		t.Fatalf("History across formats: %v", err)
+	}
+
+	for _, c := range []gocid.Cid{old, cur} {
+		data, err := repo.Store.Get(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !CIDMatches(c, data) {
+			t.Errorf("%s does not match its own bytes", CIDToFilename(c))
+		}
+	}
+	if CIDMatches(cur, []byte("other")) {
+		t.Error("CIDMatches accepted foreign bytes")
+	}
+	if err := repo.Store.PutCID(cur, []byte("other")); err == nil {
+		t.Error("PutCID accepted bytes that do not hash to the CID")
+	}
+}
+
+func TestObjectFormat_Unsupported(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	setObjectFormat(t, dir, "md5", "raw")
+	if _, err := OpenRepository(dir); err == nil {
+		t.Error("opened a repo recording an unsupported hash")
+	}
+}
+
+func TestObjectFormat_BundleAcrossFormats(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	setObjectFormat(t, dir, "sha3-256", "raw")
+	src, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.identity = testIdentity(t)
+	if _, err := src.CreateNode("n", "Note", []byte("one"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.UpdateContent("n", []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := src.ExportBundle("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestRepo(t)
+	head, err := dst.ImportBundle(b)
+	if err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	prev, err := dst.envelopeAt(head.Prev)
+	if err != nil || string(prev.Content) != "one" {
+		t.Fatalf("imported prev = %v, %v", prev, err)
+	}
+}
@@ -0,0 +1,214 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaOCRText is the meta key holding the text recognized in an image node.
+// Meta values are indexed like content, so search finds the image by it.
+const MetaOCRText = "ocr_text"
+
+// ErrNoOCR is returned by Recognizer.Recognize when no OCR is configured.
+var ErrNoOCR = errors.New("no OCR configured")
+
+const ocrTimeout = 2 * time.Minute
+
+// imageFormats are the Source formats treated as images.
+var imageFormats = map[string]bool{
+	"png": true, "jpg": true, "jpeg": true, "gif": true,
+	"tif": true, "tiff": true, "bmp": true, "webp": true,
+}
+
+// OCR is a text recognition backend: given an image, it returns the text
+// in it.
+type OCR interface {
+	Recognize(ctx context.Context, image []byte) (string, error)
+}
+
+// OCRConfig is the "ocr" section of config.json. Set Command to run a
+// local engine, or Endpoint to use an HTTP service.
+type OCRConfig struct {
+	// Command gets the image on stdin and prints the text, e.g.
+	// ["tesseract", "stdin", "stdout"].
+	Command []string `json:"command,omitempty"`
+	// Endpoint receives the image as the body of a POST and replies with
+	// the text, either plain or as a JSON object's "text" field.
+	Endpoint string `json:"endpoint,omitempty"`
+	// APIKeyEnv names the environment variable holding the bearer token
+	// for Endpoint.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+// NewOCR creates the OCR cfg describes, or nil if it names none.
+func NewOCR(cfg OCRConfig) OCR {
+	switch {
+	case len(cfg.Command) > 0:
+		return &CommandOCR{argv: cfg.Command}
+	case cfg.Endpoint != "":
+		o := &HTTPOCR{endpoint: cfg.Endpoint, client: &http.Client{Timeout: ocrTimeout}}
+		if cfg.APIKeyEnv != "" {
+			o.apiKey = os.Getenv(cfg.APIKeyEnv)
+		}
+		return o
+	}
+	return nil
+}
+
+// CommandOCR is an OCR backed by an external program such as tesseract.
+type CommandOCR struct {
+	argv []string
+}
+
+var _ OCR = (*CommandOCR)(nil)
+
+func (o *CommandOCR) Recognize(ctx context.Context, image []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, o.argv[0], o.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("ocr: %s: %s", o.argv[0], msg)
+		}
+		return "", fmt.Errorf("ocr: %s: %w", o.argv[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// HTTPOCR is an OCR backed by an HTTP service.
+type HTTPOCR struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+var _ OCR = (*HTTPOCR)(nil)
+
+func (o *HTTPOCR) Recognize(ctx context.Context, image []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(image))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(image))
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ocr: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ocr: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ocr: status %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var out struct {
+		Text *string `json:"text"`
+	}
+	if json.Unmarshal(data, &out) == nil && out.Text != nil {
+		return *out.Text, nil
+	}
+	return string(data), nil
+}
+
+// Recognizer fills in the MetaOCRText of image nodes using the
+// repository's OCR. Besides explicit Recognize calls, every content write
+// to an image starts it in the background, replacing text recognized in
+// an earlier version.
+type Recognizer struct {
+	repo *Repository
+
+	wg sync.WaitGroup // background recognitions
+}
+
+// NewRecognizer creates a Recognizer for repo.
+func NewRecognizer(repo *Repository) *Recognizer {
+	return &Recognizer{repo: repo}
+}
+
+// IsImage reports whether node holds an image: a Source of an image format,
+// or content that sniffs as one.
+func IsImage(node *NodeEnvelope) bool {
+	if format, _ := node.Meta["format"].(string); imageFormats[strings.ToLower(format)] {
+		return true
+	}
+	return len(node.Content) > 0 && strings.HasPrefix(http.DetectContentType(node.Content), "image/")
+}
+
+// Recognize runs OCR over image node id and stores the text in its meta.
+func (g *Recognizer) Recognize(ctx context.Context, id string) (*NodeEnvelope, error) {
+	r := g.repo
+	if r.OCR == nil {
+		return nil, ErrNoOCR
+	}
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if !IsImage(node) {
+		return nil, fmt.Errorf("%s is not an image", id)
+	}
+	text, err := r.OCR.Recognize(ctx, node.Content)
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+
+	unlock := r.lockNode(id)
+	defer unlock()
+	current, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	// The image changed while it was read; the write that changed it
+	// starts its own recognition.
+	if sha256.Sum256(current.Content) != sha256.Sum256(node.Content) {
+		return current, nil
+	}
+	if old, _ := current.Meta[MetaOCRText].(string); old == text {
+		return current, nil
+	}
+	var value interface{} = text
+	if text == "" {
+		value = nil
+	}
+	return r.updateMeta(id, map[string]interface{}{MetaOCRText: value}, "ocr "+id)
+}
+
+// onWrite starts a background recognition of node if it is an image. It
+// is called after every content write.
+func (g *Recognizer) onWrite(node *NodeEnvelope) {
+	if g.repo.OCR == nil || !IsImage(node) {
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+		defer cancel()
+		if _, err := g.Recognize(ctx, node.ID); err != nil {
+			fmt.Printf("memex-fs: ocr %s: %v\n", node.ID, err)
+		}
+	}()
+}
+
+// Wait blocks until every background recognition started so far has
+// finished.
+func (g *Recognizer) Wait() {
+	g.wg.Wait()
+}
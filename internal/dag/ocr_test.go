@@ -0,0 +1,75 @@
+package dag
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecognizer_CommandOCR(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.Recognizer.Recognize(t.Context(), "x"); !errors.Is(err, ErrNoOCR) {
+		t.Fatalf("without OCR: err = %v, want ErrNoOCR", err)
+	}
+	// rot13 stands in for an engine: the text differs from the image bytes.
+	repo.OCR = NewOCR(OCRConfig{Command: []string{"tr", "a-z", "n-za-m"}})
+
+	repo.CreateNode("note:1", "Note", []byte("standup"), nil)
+	id, err := repo.AddAttachment("note:1", "board.png", []byte("mrccryva ebnqznc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Recognizer.Wait()
+
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := node.Meta[MetaOCRText]; got != "zeppelin roadmap" {
+		t.Errorf("ocr_text = %v", got)
+	}
+	if hits := repo.Search.Search("roadmap", 10); len(hits) != 1 || hits[0] != id {
+		t.Errorf("Search(roadmap) = %v, want [%s]", hits, id)
+	}
+
+	// Unchanged text writes no new version.
+	before, _ := repo.Refs.Get(id)
+	if _, err := repo.Recognizer.Recognize(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := repo.Refs.Get(id); !after.Equals(before) {
+		t.Error("recognizing the same text again wrote a new version")
+	}
+
+	if _, err := repo.Recognizer.Recognize(t.Context(), "note:1"); err == nil {
+		t.Error("recognized a note that is not an image")
+	}
+}
+
+func TestRecognizer_HTTPOCR(t *testing.T) {
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("Content-Type")
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"text": "whiteboard sketch\n"}`))
+	}))
+	defer srv.Close()
+
+	repo := openTestRepo(t)
+	repo.OCR = NewOCR(OCRConfig{Endpoint: srv.URL})
+	png := []byte("\x89PNG\r\n\x1a\nfake image data")
+	if _, err := repo.CreateNode("img:1", "Photo", png, nil); err != nil {
+		t.Fatal(err)
+	}
+	repo.Recognizer.Wait()
+
+	node, _ := repo.GetNode("img:1")
+	if got := node.Meta[MetaOCRText]; got != "whiteboard sketch" {
+		t.Errorf("ocr_text = %v", got)
+	}
+	if gotType != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", gotType)
+	}
+}
@@ -0,0 +1,239 @@
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// overlayFile marks a .mx directory as an overlay's.
+const overlayFile = "overlay.json"
+
+// Overlay is a scratch copy of the repository as of one commit, for
+// experiments such as bulk edits that should not touch the real graph
+// until they are known to be right. It is a data directory of its own
+// under .mx/overlays/, opened and mounted like any other, with its own
+// refs, links and HEAD, which starts at Base; the commit it was made from
+// is never changed. Its objects go to the repository's store, which it
+// shares but never deletes from. When done, MergeOverlay merges its
+// commits into the repository, or DiscardOverlay drops them.
+type Overlay struct {
+	Name    string    `json:"name"`
+	Base    string    `json:"base"` // commit it was made from
+	Created time.Time `json:"created"`
+	// Objects is the repository's object store, relative to the
+	// overlay's .mx directory.
+	Objects string `json:"objects"`
+	// Head is the overlay's latest commit: Base until it changes.
+	Head string `json:"-"`
+}
+
+// loadOverlay returns the overlay mx belongs to, or nil if it is not one.
+func loadOverlay(mx string) (*Overlay, error) {
+	data, err := os.ReadFile(filepath.Join(mx, overlayFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read overlay: %w", err)
+	}
+	ov := &Overlay{}
+	if err := json.Unmarshal(data, ov); err != nil {
+		return nil, fmt.Errorf("parse overlay: %w", err)
+	}
+	ov.Head = ov.Base
+	if head, err := os.ReadFile(filepath.Join(mx, "HEAD")); err == nil && len(head) > 0 {
+		ov.Head = strings.TrimSpace(string(head))
+	}
+	return ov, nil
+}
+
+// Overlay returns the overlay r is, or nil if r is a repository proper.
+func (r *Repository) Overlay() *Overlay {
+	return r.overlay
+}
+
+func (r *Repository) overlaysDir() string {
+	return filepath.Join(r.MxDir(), "overlays")
+}
+
+// OverlayRoot returns the data directory of the overlay name, to open or
+// mount. It returns ErrNotFound if there is no such overlay.
+func (r *Repository) OverlayRoot(name string) (string, error) {
+	if err := validOverlayName(name); err != nil {
+		return "", err
+	}
+	root := filepath.Join(r.overlaysDir(), name)
+	if _, err := os.Stat(filepath.Join(root, ".mx", overlayFile)); err != nil {
+		return "", errorOf(ErrNotFound, "no overlay %q", name)
+	}
+	return root, nil
+}
+
+// validOverlayName rejects names that can't be a directory under
+// .mx/overlays/.
+func validOverlayName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\\x00") {
+		return errorOf(ErrValidation, "invalid overlay name %q", name)
+	}
+	return nil
+}
+
+// CreateOverlay makes the overlay name from commit, a CID, tag or
+// timestamp; "" is HEAD.
+func (r *Repository) CreateOverlay(name, commit string) (*Overlay, error) {
+	if r.overlay != nil {
+		return nil, errorOf(ErrValidation, "%s is itself an overlay", r.root)
+	}
+	if err := validOverlayName(name); err != nil {
+		return nil, err
+	}
+	if commit == "" {
+		if commit = r.headKey(); commit == "" {
+			return nil, errorOf(ErrValidation, "no commits to make an overlay from")
+		}
+	}
+	base, _, err := r.resolveCID(commit)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Join(r.overlaysDir(), name)
+	mx := filepath.Join(root, ".mx")
+	if _, err := os.Stat(root); err == nil {
+		return nil, errorOf(ErrConflict, "overlay %q exists", name)
+	}
+	if err := os.MkdirAll(mx, 0755); err != nil {
+		return nil, fmt.Errorf("create overlay: %w", err)
+	}
+	ov, err := r.writeOverlay(name, base, mx)
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	return ov, nil
+}
+
+func (r *Repository) writeOverlay(name, base, mx string) (*Overlay, error) {
+	objects, err := filepath.Rel(mx, filepath.Join(r.MxDir(), "objects"))
+	if err != nil {
+		return nil, err
+	}
+	ov := &Overlay{Name: name, Base: base, Created: time.Now().UTC(), Objects: objects, Head: base}
+	data, _ := json.MarshalIndent(ov, "", "  ")
+	if err := SafeWrite(filepath.Join(mx, overlayFile), data, 0644); err != nil {
+		return nil, err
+	}
+	// A shallow repository's history stops where it does in the overlay.
+	if data, err := os.ReadFile(shallowPath(r.MxDir())); err == nil {
+		if err := SafeWrite(shallowPath(mx), data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	scratch, err := OpenRepository(filepath.Dir(mx))
+	if err != nil {
+		return nil, err
+	}
+	c, err := cidFromFilename(base)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := scratch.Checkout(c, SparseFilter{}); err != nil {
+		return nil, fmt.Errorf("check out %s: %w", base, err)
+	}
+	return ov, nil
+}
+
+// Overlays returns r's overlays, by name.
+func (r *Repository) Overlays() ([]*Overlay, error) {
+	entries, err := os.ReadDir(r.overlaysDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list overlays: %w", err)
+	}
+	var out []*Overlay
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		ov, err := loadOverlay(filepath.Join(r.overlaysDir(), e.Name(), ".mx"))
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %w", e.Name(), err)
+		}
+		if ov != nil {
+			out = append(out, ov)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// DiscardOverlay removes the overlay name with everything done in it.
+// Objects it wrote stay in the store, unreferenced. Unmount it first.
+func (r *Repository) DiscardOverlay(name string) error {
+	root, err := r.OverlayRoot(name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return fmt.Errorf("discard overlay: %w", err)
+	}
+	return nil
+}
+
+// MergeOverlay merges the commits made in the overlay name into r, as
+// MergeCommit does a pulled commit, and then removes the overlay. Nodes
+// changed on both sides since it was made are left in conflict. Unmount
+// it first.
+func (r *Repository) MergeOverlay(name string) (*MergeResult, error) {
+	root, err := r.OverlayRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	ov, err := loadOverlay(filepath.Join(root, ".mx"))
+	if err != nil {
+		return nil, err
+	}
+	result := &MergeResult{Base: ov.Base}
+	if ov.Head != ov.Base {
+		head, err := cidFromFilename(ov.Head)
+		if err != nil {
+			return nil, fmt.Errorf("overlay HEAD: %w", err)
+		}
+		if result, err = r.MergeCommit(head); err != nil {
+			return nil, err
+		}
+	}
+	return result, r.DiscardOverlay(name)
+}
+
+// overlayBases returns the commits r's overlays were made from. Their
+// node versions, and the chains behind them, are shared with the
+// overlays, so retention and trash purges must keep them.
+func (r *Repository) overlayBases() ([]string, error) {
+	overlays, err := r.Overlays()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(overlays))
+	for _, ov := range overlays {
+		out = append(out, ov.Base)
+	}
+	return out, nil
+}
+
+// objectsDir returns where the objects of the repository in mx are kept:
+// its own objects/, or for an overlay the store it shares.
+func objectsDir(mx string, ov *Overlay) string {
+	if ov != nil {
+		return filepath.Clean(filepath.Join(mx, ov.Objects))
+	}
+	return filepath.Join(mx, "objects")
+}
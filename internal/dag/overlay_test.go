@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	base := repo.headKey()
+
+	ov, err := repo.CreateOverlay("bulk", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ov.Base != base || ov.Head != base {
+		t.Fatalf("overlay = %+v, want base and head %s", ov, base)
+	}
+	if _, err := repo.CreateOverlay("bulk", ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("second create: %v", err)
+	}
+	if _, err := repo.CreateOverlay("../x", ""); !errors.Is(err, ErrValidation) {
+		t.Errorf("bad name: %v", err)
+	}
+
+	root, err := repo.OverlayRoot("bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scratch, err := OpenRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scratch.Overlay() == nil || scratch.Overlay().Name != "bulk" {
+		t.Fatalf("opened overlay = %+v", scratch.Overlay())
+	}
+	if _, err := os.Stat(filepath.Join(scratch.MxDir(), "objects")); !os.IsNotExist(err) {
+		t.Error("overlay has an object store of its own")
+	}
+	if _, err := scratch.CreateOverlay("nested", ""); !errors.Is(err, ErrValidation) {
+		t.Errorf("nested overlay: %v", err)
+	}
+	scratch.UpdateContent("note:a", []byte("a, rewritten"))
+	scratch.CreateNode("note:c", "Note", []byte("c"), nil)
+	if got := scratch.Search.Search("rewritten", 0); len(got) != 1 {
+		t.Errorf("overlay search = %v", got)
+	}
+	if node, _ := repo.GetNode("note:a"); string(node.Content) != "a" {
+		t.Errorf("overlay write reached the repository: %q", node.Content)
+	}
+	if _, err := repo.GetNode("note:c"); err == nil {
+		t.Error("overlay node visible in the repository")
+	}
+
+	// Purges in the overlay must not delete objects the repository uses.
+	head, _ := repo.Refs.Get("note:b")
+	if err := scratch.Store.Delete(head); err != nil || !repo.Store.Has(head) {
+		t.Errorf("overlay deleted a shared object: %v", err)
+	}
+	if commits, err := repo.ProtectedCommits(); err != nil || !commits[base] {
+		t.Errorf("overlay base not protected: %v, %v", commits, err)
+	}
+
+	overlays, err := repo.Overlays()
+	if err != nil || len(overlays) != 1 || overlays[0].Head == base {
+		t.Fatalf("overlays = %+v, %v", overlays, err)
+	}
+	repo.UpdateContent("note:b", []byte("b2"))
+	result, err := repo.MergeOverlay("bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Updated) != 2 || len(result.Conflicts) != 0 {
+		t.Errorf("merge = %+v", result)
+	}
+	for id, want := range map[string]string{"note:a": "a, rewritten", "note:b": "b2", "note:c": "c"} {
+		if node, err := repo.GetNode(id); err != nil || string(node.Content) != want {
+			t.Errorf("%s after merge: %v", id, err)
+		}
+	}
+	if _, err := repo.OverlayRoot("bulk"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("merged overlay left behind: %v", err)
+	}
+
+	if _, err := repo.CreateOverlay("scratch", base); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DiscardOverlay("scratch"); err != nil {
+		t.Fatal(err)
+	}
+	if overlays, _ := repo.Overlays(); len(overlays) != 0 {
+		t.Errorf("overlays after discard = %+v", overlays)
+	}
+	if err := repo.DiscardOverlay("scratch"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("discard twice: %v", err)
+	}
+}
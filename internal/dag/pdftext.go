@@ -0,0 +1,266 @@
+package dag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// extractPDF returns the text shown by a PDF's content streams. It is a
+// best-effort reader for the common case: uncompressed or FlateDecode
+// streams whose fonts use a byte encoding close to Latin-1. Text in
+// CID-keyed fonts, scanned pages and encrypted files come out empty or
+// garbled and are dropped; configure an external extractor such as
+// pdftotext for those.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF")
+	}
+	var parts []string
+	for _, stream := range pdfStreams(data) {
+		if text := pdfContentText(stream); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// pdfStreams returns the decoded bodies of data's streams that may hold
+// page content: unfiltered or FlateDecode, and not images, fonts or object
+// streams.
+func pdfStreams(data []byte) [][]byte {
+	var streams [][]byte
+	for {
+		start := bytes.Index(data, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := data[:start]
+		if i := bytes.LastIndex(dict, []byte("<<")); i >= 0 {
+			dict = dict[i:]
+		}
+		body := data[start+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		data = body[end+len("endstream"):]
+		body = body[:end]
+
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/ObjStm")) ||
+			bytes.Contains(dict, []byte("/Length1")) || bytes.Contains(dict, []byte("/FontFile")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			// A truncated stream still yields what was inflated.
+			body, _ = io.ReadAll(io.LimitReader(zr, maxExtractedText*4))
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		streams = append(streams, body)
+	}
+	return streams
+}
+
+// pdfContentText returns the strings a content stream shows with the Tj,
+// TJ, ' and " operators, with line and word breaks where it moves to a
+// new line or kerns widely.
+func pdfContentText(stream []byte) string {
+	var b strings.Builder
+	var pending []string // strings since the last operator
+	inArray := false
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := pdfLiteralString(stream[i:])
+			pending = append(pending, s)
+			i += n
+		case c == '<' && i+1 < len(stream) && stream[i+1] != '<':
+			end := bytes.IndexByte(stream[i:], '>')
+			if end < 0 {
+				return b.String()
+			}
+			pending = append(pending, pdfHexString(stream[i+1:i+end]))
+			i += end + 1
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case inArray && (c == '-' || c == '.' || (c >= '0' && c <= '9')):
+			j := i + 1
+			for j < len(stream) && (stream[j] == '.' || (stream[j] >= '0' && stream[j] <= '9')) {
+				j++
+			}
+			// Kerning back by more than a fifth of an em separates words.
+			if c == '-' && j-i > 3 {
+				pending = append(pending, " ")
+			}
+			i = j
+		case isPDFRegular(c):
+			j := i
+			for j < len(stream) && isPDFRegular(stream[j]) {
+				j++
+			}
+			switch op := string(stream[i:j]); op {
+			case "Tj", "TJ":
+				b.WriteString(strings.Join(pending, ""))
+			case "'", "\"":
+				b.WriteByte('\n')
+				b.WriteString(strings.Join(pending, ""))
+			case "T*", "Td", "TD", "ET":
+				b.WriteByte('\n')
+			}
+			if !inArray {
+				pending = pending[:0]
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	text := b.String()
+	if !mostlyPrintable(text) {
+		return ""
+	}
+	return text
+}
+
+// isPDFRegular reports whether c can be part of a PDF operator or name.
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+// pdfLiteralString decodes the (...) string at the start of s, returning
+// it and the number of bytes it spans.
+func pdfLiteralString(s []byte) (string, int) {
+	var out []byte
+	depth := 0
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfDecodeText(out), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(s) {
+				break
+			}
+			switch e := s[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					j := 0
+					for ; j < 3 && i+j < len(s) && s[i+j] >= '0' && s[i+j] <= '7'; j++ {
+						v = v*8 + int(s[i+j]-'0')
+					}
+					i += j - 1
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return pdfDecodeText(out), i
+}
+
+// pdfHexString decodes the body of a <...> string.
+func pdfHexString(s []byte) string {
+	var out []byte
+	var hi byte
+	half := false
+	for _, c := range s {
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if half {
+			out = append(out, hi<<4|v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	if half {
+		out = append(out, hi<<4)
+	}
+	return pdfDecodeText(out)
+}
+
+// pdfDecodeText turns string bytes into text: UTF-16BE when they carry its
+// byte order mark, else Latin-1.
+func pdfDecodeText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// mostlyPrintable reports whether s looks like text rather than glyph IDs
+// decoded as Latin-1.
+func mostlyPrintable(s string) bool {
+	total, ok := 0, 0
+	for _, r := range s {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			ok++
+		}
+	}
+	return total > 0 && ok*10 >= total*9
+}
@@ -0,0 +1,94 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// PinSet is the user's ordered list of favorite nodes, persisted as a JSON
+// array at .mx/pinned.json. Pins are user preference rather than graph
+// content, so they live beside the repo instead of in commits.
+type PinSet struct {
+	mu   sync.RWMutex
+	path string
+	ids  []string // in pin order
+}
+
+// NewPinSet loads the pin list at path. A missing file is an empty set.
+func NewPinSet(path string) (*PinSet, error) {
+	p := &PinSet{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read pins: %w", err)
+	}
+	if err := json.Unmarshal(data, &p.ids); err != nil {
+		return nil, fmt.Errorf("parse pins: %w", err)
+	}
+	return p, nil
+}
+
+func (p *PinSet) save() error {
+	data, err := json.MarshalIndent(p.ids, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(p.path, append(data, '\n'), 0644)
+}
+
+// Add pins id. Pinning an already-pinned node is a no-op.
+func (p *PinSet) Add(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, existing := range p.ids {
+		if existing == id {
+			return nil
+		}
+	}
+	p.ids = append(p.ids, id)
+	return p.save()
+}
+
+// Remove unpins id. Returns false if it was not pinned.
+func (p *PinSet) Remove(id string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, existing := range p.ids {
+		if existing == id {
+			p.ids = append(p.ids[:i], p.ids[i+1:]...)
+			return true, p.save()
+		}
+	}
+	return false, nil
+}
+
+// Has reports whether id is pinned.
+func (p *PinSet) Has(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, existing := range p.ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the pinned IDs in pin order.
+func (p *PinSet) List() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.ids...)
+}
+
+// Pin marks an existing node as a favorite.
+func (r *Repository) Pin(id string) error {
+	if _, err := r.GetNode(id); err != nil {
+		return err
+	}
+	return r.Pins.Add(id)
+}
@@ -0,0 +1,52 @@
+package dag
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPinSet_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pinned.json")
+
+	p, err := NewPinSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Add("note:b")
+	p.Add("note:a")
+	p.Add("note:b") // duplicate is a no-op
+
+	reopened, err := NewPinSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reopened.List()
+	if len(got) != 2 || got[0] != "note:b" || got[1] != "note:a" {
+		t.Errorf("List = %v, want [note:b note:a] (pin order)", got)
+	}
+
+	removed, err := reopened.Remove("note:b")
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v", removed, err)
+	}
+	if reopened.Has("note:b") {
+		t.Error("note:b still pinned after Remove")
+	}
+	if removed, _ := reopened.Remove("note:b"); removed {
+		t.Error("second Remove should report not pinned")
+	}
+}
+
+func TestRepositoryPin_RequiresLiveNode(t *testing.T) {
+	repo := openTestRepo(t)
+	if err := repo.Pin("note:missing"); err == nil {
+		t.Error("expected error pinning a missing node")
+	}
+	repo.CreateNode("note:1", "Note", nil, nil)
+	if err := repo.Pin("note:1"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if !repo.Pins.Has("note:1") {
+		t.Error("note:1 not pinned")
+	}
+}
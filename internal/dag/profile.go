@@ -0,0 +1,55 @@
+package dag
+
+import "fmt"
+
+// ProfileLowMemory tunes a repository for Raspberry Pi-class devices: the
+// co-access index is not replayed from the access log on open, so it
+// starts empty and learns from reads as they come; the search postings
+// spill to disk and the co-indexes are bounded, as Memory would (with
+// lowMemoryBudget unless Memory is set); and /ask/ caches fewer answers.
+// The mount command also lengthens its background intervals under it.
+const ProfileLowMemory = "low-memory"
+
+// lowMemoryBudget bounds the indexes under ProfileLowMemory when the
+// config sets no Memory of its own.
+var lowMemoryBudget = MemoryConfig{SearchMB: 16, CoIndexMB: 4}
+
+// lowMemoryAskCacheSize replaces askCacheSize under ProfileLowMemory.
+const lowMemoryAskCacheSize = 8
+
+// checkProfile returns an error unless profile is "" or a known profile.
+func checkProfile(profile string) error {
+	if profile != "" && profile != ProfileLowMemory {
+		return fmt.Errorf("unknown profile %q", profile)
+	}
+	return nil
+}
+
+// Profile returns the resource profile r was opened with, or "" for the
+// default.
+func (r *Repository) Profile() string {
+	return r.profile
+}
+
+// lowMemory reports whether r runs under ProfileLowMemory.
+func (r *Repository) lowMemory() bool {
+	return r.profile == ProfileLowMemory
+}
+
+// memoryConfig is the Memory r's indexes are bounded by: the config's,
+// or under ProfileLowMemory lowMemoryBudget if the config sets none.
+func (r *Repository) memoryConfig() *MemoryConfig {
+	if r.Config.Memory == nil && r.lowMemory() {
+		budget := lowMemoryBudget
+		return &budget
+	}
+	return r.Config.Memory
+}
+
+// askCacheLimit is how many /ask/ answers r caches.
+func (r *Repository) askCacheLimit() int {
+	if r.lowMemory() {
+		return lowMemoryAskCacheSize
+	}
+	return askCacheSize
+}
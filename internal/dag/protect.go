@@ -0,0 +1,226 @@
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrProtected is returned for a change to history that a protection,
+// see Protect, rules out.
+var ErrProtected = errors.New("protected history")
+
+func (r *Repository) protectedPath() string {
+	return filepath.Join(r.MxDir(), "protected.json")
+}
+
+func (r *Repository) loadProtected() ([]string, error) {
+	var specs []string
+	data, err := os.ReadFile(r.protectedPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read protections: %w", err)
+	}
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("parse protections: %w", err)
+	}
+	return specs, nil
+}
+
+func (r *Repository) saveProtected(specs []string) error {
+	data, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(r.protectedPath(), append(data, '\n'), 0644)
+}
+
+// Protected returns the protection specs, sorted.
+func (r *Repository) Protected() ([]string, error) {
+	return r.loadProtected()
+}
+
+// Protect adds spec to the history that retention, trash purges and tag
+// moves must leave alone. A spec is one of:
+//
+//	weekly-*          every tag matching the pattern, now or later
+//	v1                a tag, or else a commit CID or RFC3339 timestamp
+//	A..B              the commits from A to B along B's first parents;
+//	                  either end may be a tag, CID or timestamp, and an
+//	                  empty one means the first commit or HEAD
+//
+// A protected commit keeps every node version it names, with the version
+// chain behind it, so /at/ shows it exactly as it was. A spec that is not
+// a tag pattern must resolve now.
+func (r *Repository) Protect(spec string) error {
+	if _, err := r.protectedBy(spec); err != nil {
+		return err
+	}
+	r.shared.protectMu.Lock()
+	defer r.shared.protectMu.Unlock()
+	specs, err := r.loadProtected()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(specs, spec) {
+		return nil
+	}
+	specs = append(specs, spec)
+	slices.Sort(specs)
+	return r.saveProtected(specs)
+}
+
+// Unprotect removes spec. Returns false if it was not protected.
+func (r *Repository) Unprotect(spec string) (bool, error) {
+	r.shared.protectMu.Lock()
+	defer r.shared.protectMu.Unlock()
+	specs, err := r.loadProtected()
+	if err != nil {
+		return false, err
+	}
+	i := slices.Index(specs, spec)
+	if i < 0 {
+		return false, nil
+	}
+	return true, r.saveProtected(slices.Delete(specs, i, i+1))
+}
+
+// tagProtected reports whether a spec protects the tag name.
+func (r *Repository) tagProtected(name string) (bool, error) {
+	specs, err := r.loadProtected()
+	if err != nil {
+		return false, err
+	}
+	for _, spec := range specs {
+		if ok, _ := path.Match(spec, name); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ProtectedCommits returns the CIDs of every protected commit, along with
+// the commits overlays were made from.
+func (r *Repository) ProtectedCommits() (map[string]bool, error) {
+	specs, err := r.loadProtected()
+	if err != nil {
+		return nil, err
+	}
+	bases, err := r.overlayBases()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool)
+	for _, c := range bases {
+		out[c] = true
+	}
+	for _, spec := range specs {
+		commits, err := r.protectedBy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("protection %s: %w", spec, err)
+		}
+		for _, c := range commits {
+			out[c] = true
+		}
+	}
+	return out, nil
+}
+
+// protectedVersions returns the CIDs of every node version a protected
+// commit names.
+func (r *Repository) protectedVersions() (map[string]bool, error) {
+	commits, err := r.ProtectedCommits()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool)
+	for name := range commits {
+		c, err := cidFromFilename(name)
+		if err != nil {
+			continue
+		}
+		commit, err := r.Commits.GetCommit(c)
+		if err != nil {
+			return nil, fmt.Errorf("load protected commit %s: %w", name, err)
+		}
+		for _, v := range commit.Refs {
+			out[v] = true
+		}
+	}
+	return out, nil
+}
+
+// protectedBy returns the commits spec covers.
+func (r *Repository) protectedBy(spec string) ([]string, error) {
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		return r.commitRange(from, to)
+	}
+	if strings.ContainsAny(spec, "*?[") {
+		if _, err := path.Match(spec, ""); err != nil {
+			return nil, fmt.Errorf("bad tag pattern %q: %w", spec, err)
+		}
+		var out []string
+		for _, t := range r.Tags.List() {
+			if ok, _ := path.Match(spec, t.Name); ok {
+				out = append(out, t.Commit)
+			}
+		}
+		return out, nil
+	}
+	c, _, err := r.resolveCID(spec)
+	if err != nil {
+		return nil, err
+	}
+	return []string{c}, nil
+}
+
+// commitRange returns the commits from from to to along to's first
+// parents, both included.
+func (r *Repository) commitRange(from, to string) ([]string, error) {
+	if to == "" {
+		to = r.headKey()
+		if to == "" {
+			return nil, nil
+		}
+	}
+	last, _, err := r.resolveCID(to)
+	if err != nil {
+		return nil, err
+	}
+	first := ""
+	if from != "" {
+		if first, _, err = r.resolveCID(from); err != nil {
+			return nil, err
+		}
+	}
+	var out []string
+	for name := last; name != ""; {
+		c, err := cidFromFilename(name)
+		if err != nil {
+			return nil, err
+		}
+		if !r.Store.Has(c) {
+			break // history cut short by a shallow pull
+		}
+		out = append(out, name)
+		if name == first {
+			return out, nil
+		}
+		commit, err := r.Commits.GetCommit(c)
+		if err != nil {
+			return nil, err
+		}
+		name = commit.Parent
+	}
+	if first != "" {
+		return nil, fmt.Errorf("%s is not an ancestor of %s", from, to)
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProtect_Retention(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Config.Retention = map[string]int{"Log": 1}
+	repo.CreateNode("log:a", "Log", []byte("v1"), nil)
+	repo.UpdateContent("log:a", []byte("v2"))
+	if _, err := repo.Tag("audit", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Protect("audit"); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"v3", "v4", "v5"} {
+		repo.UpdateContent("log:a", []byte(v))
+	}
+
+	// v2, which the protected tag names, survives with v1 behind it; only
+	// v3 goes.
+	if got := strings.Join(versions(t, repo, "log:a"), ","); got != "v5,v4,v2,v1" {
+		t.Errorf("history = %s, want v5,v4,v2,v1", got)
+	}
+	commit, err := repo.ResolveCommit("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, err := repo.envelopeAt(commit.Refs["log:a"]); err != nil || string(node.Content) != "v2" {
+		t.Errorf("log:a at audit = %v, %v", node, err)
+	}
+
+	if _, err := repo.Tag("audit", "", true); !errors.Is(err, ErrProtected) {
+		t.Errorf("moving a protected tag: %v", err)
+	}
+	if _, err := repo.DeleteTag("audit"); !errors.Is(err, ErrProtected) {
+		t.Errorf("deleting a protected tag: %v", err)
+	}
+	if ok, err := repo.Unprotect("audit"); !ok || err != nil {
+		t.Fatalf("Unprotect = %v, %v", ok, err)
+	}
+	repo.UpdateContent("log:a", []byte("v6"))
+	if got := strings.Join(versions(t, repo, "log:a"), ","); got != "v6,v5" {
+		t.Errorf("history after unprotect = %s, want v6,v5", got)
+	}
+}
+
+func TestProtect_RangeKeepsTrash(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Config.TrashRetentionDays = 1
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	first := repo.headKey()
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	last := repo.headKey()
+	repo.CreateNode("note:c", "Note", []byte("c"), nil)
+	for _, id := range []string{"note:a", "note:c"} {
+		repo.DeleteNode(id, false)
+	}
+
+	if err := repo.Protect("nope..nope"); err == nil {
+		t.Error("protected an unresolvable range")
+	}
+	if err := repo.Protect(first + ".." + last); err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.ProtectedCommits()
+	if err != nil || len(commits) != 2 || !commits[first] || !commits[last] {
+		t.Errorf("ProtectedCommits = %v, %v", commits, err)
+	}
+	purged, err := repo.ExpireTrash(context.Background(), time.Now().Add(48*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].ID != "note:c" {
+		t.Errorf("purged %+v, want only note:c", purged)
+	}
+	if !repo.Refs.Has("note:a") {
+		t.Error("purged a node a protected commit names")
+	}
+}
@@ -0,0 +1,104 @@
+package dag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Meta keys that importers set to record where a node came from. They are
+// conventions, not enforced; Provenance reads them when present.
+const (
+	// MetaImportedFrom is the file or URL a node was imported from.
+	MetaImportedFrom = "imported_from"
+	// MetaIngestedFrom is the DID (or other remote identity) whose
+	// published graph a node was ingested from.
+	MetaIngestedFrom = "ingested_from"
+)
+
+// Provenance is where a node came from and every commit that touched it.
+type Provenance struct {
+	ID string
+	// Origin describes how the node entered the graph, e.g. "created
+	// locally" or "imported from notes.md".
+	Origin  string
+	Created time.Time
+	// History lists the commits that created, changed or deleted the
+	// node, oldest first.
+	History []ProvenanceEvent
+	// Truncated is set when the commit chain could not be walked to its
+	// root, so the oldest events may be missing.
+	Truncated bool
+}
+
+// ProvenanceEvent is one commit in a node's history.
+type ProvenanceEvent struct {
+	Commit    string // CID (base32)
+	Author    string // DID of the committer; empty for unsigned commits
+	Timestamp time.Time
+	Message   string
+	Action    string // "created", "changed" or "deleted"
+}
+
+// Provenance traces a node back to its origin: the meta keys importers
+// leave behind, plus every commit in which the node's ref changed, from
+// the commit index.
+func (r *Repository) Provenance(id string) (*Provenance, error) {
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provenance{ID: id, Created: node.Created}
+
+	log, truncated, err := r.Commits.Changelog(id)
+	if err != nil {
+		return nil, err
+	}
+	p.Truncated = truncated
+	for i := len(log) - 1; i >= 0; i-- {
+		c := log[i]
+		ch, _ := c.Change(id)
+		p.History = append(p.History, ProvenanceEvent{
+			Commit:    c.CID,
+			Author:    c.Author,
+			Timestamp: c.Timestamp,
+			Message:   c.Message,
+			Action:    ch.Action(),
+		})
+	}
+
+	p.Origin = r.origin(node, p.History)
+	return p, nil
+}
+
+// origin describes how node entered the graph. Meta recorded by an
+// importer wins; otherwise the author of the creating commit decides
+// between local and foreign.
+func (r *Repository) origin(node *NodeEnvelope, history []ProvenanceEvent) string {
+	if s, ok := node.Meta[MetaIngestedFrom].(string); ok && s != "" {
+		return "ingested from " + s
+	}
+	if s, ok := node.Meta[MetaImportedFrom].(string); ok && s != "" {
+		return "imported from " + s
+	}
+	if node.Type == SummaryType {
+		if s, ok := node.Meta["source"].(string); ok {
+			return "generated summary of " + s
+		}
+	}
+	// The latest creation counts: a deleted and recreated node starts over.
+	for i := len(history) - 1; i >= 0; i-- {
+		ev := history[i]
+		if ev.Action != "created" {
+			continue
+		}
+		if signer, ok := strings.CutPrefix(ev.Message, "import "+node.ID+" from "); ok {
+			return "imported from a bundle signed by " + signer
+		}
+		if ev.Author == "" || ev.Author == r.Commits.author {
+			return "created locally"
+		}
+		return fmt.Sprintf("created by %s", ev.Author)
+	}
+	return "created locally"
+}
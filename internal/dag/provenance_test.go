@@ -0,0 +1,53 @@
+package dag
+
+import "testing"
+
+func TestProvenance_History(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("first"), nil)
+	repo.CreateNode("note:b", "Note", []byte("other"), nil)
+	repo.UpdateContent("note:a", []byte("second"))
+
+	p, err := repo.Provenance("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Origin != "created locally" {
+		t.Errorf("origin = %q", p.Origin)
+	}
+	if len(p.History) != 2 {
+		t.Fatalf("history = %+v, want create and update", p.History)
+	}
+	if p.History[0].Action != "created" || p.History[0].Message != "create note:a" {
+		t.Errorf("first event = %+v", p.History[0])
+	}
+	if p.History[1].Action != "changed" {
+		t.Errorf("second event = %+v", p.History[1])
+	}
+	if p.Truncated {
+		t.Error("history truncated")
+	}
+}
+
+func TestProvenance_OriginFromMeta(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:imported", "Note", []byte("x"), map[string]interface{}{MetaImportedFrom: "notes/old.md"})
+	repo.CreateNode("note:remote", "Note", []byte("y"), map[string]interface{}{MetaIngestedFrom: "did:key:z6MkExample"})
+
+	for id, want := range map[string]string{
+		"note:imported": "imported from notes/old.md",
+		"note:remote":   "ingested from did:key:z6MkExample",
+	} {
+		p, err := repo.Provenance(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Origin != want {
+			t.Errorf("%s origin = %q, want %q", id, p.Origin, want)
+		}
+	}
+
+	if _, err := repo.Provenance("note:missing"); err == nil {
+		t.Error("missing node should fail")
+	}
+}
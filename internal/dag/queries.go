@@ -0,0 +1,91 @@
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// QueryType is the type of a node that saves a search query under a
+// name: the content of query:{name} is the query "@name" stands for.
+const QueryType = "Query"
+
+// maxQueryAliasDepth bounds how deeply aliases may refer to aliases, so a
+// cycle is reported rather than followed forever.
+const maxQueryAliasDepth = 8
+
+// ErrUnknownQueryAlias is returned when a query names an alias that is
+// defined nowhere.
+var ErrUnknownQueryAlias = errors.New("unknown query alias")
+
+func (r *Repository) queryAliasesPath() string {
+	return filepath.Join(r.root, ".mx", "queries.json")
+}
+
+// QueryAliases returns every named query: those in .mx/queries.json, a JSON
+// object of name → query, and those saved as Query nodes, which win when
+// both define a name. The file is read on each call, so edits to it take
+// effect at once.
+func (r *Repository) QueryAliases() (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := os.ReadFile(r.queryAliasesPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read query aliases: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &aliases); err != nil {
+			return nil, fmt.Errorf("parse query aliases: %w", err)
+		}
+	}
+	for _, id := range r.Search.FilterByType(QueryType, 0) {
+		name, ok := strings.CutPrefix(id, "query:")
+		if !ok {
+			continue
+		}
+		if node, err := r.GetNode(id); err == nil {
+			aliases[name] = strings.TrimSpace(string(node.Content))
+		}
+	}
+	return aliases, nil
+}
+
+// ExpandQuery replaces each "@name" word of query with the query saved
+// under name, so searching the expansion gives exactly the results of
+// typing it out. Aliases may use other aliases. A query without "@" is
+// returned as is.
+func (r *Repository) ExpandQuery(query string) (string, error) {
+	if !strings.Contains(query, "@") {
+		return query, nil
+	}
+	aliases, err := r.QueryAliases()
+	if err != nil {
+		return "", err
+	}
+	return expandQuery(query, aliases, 0)
+}
+
+func expandQuery(query string, aliases map[string]string, depth int) (string, error) {
+	words := strings.Fields(query)
+	for i, w := range words {
+		name, ok := strings.CutPrefix(w, "@")
+		if !ok || name == "" {
+			continue
+		}
+		saved, ok := aliases[name]
+		if !ok {
+			return "", fmt.Errorf("%w: @%s", ErrUnknownQueryAlias, name)
+		}
+		if depth == maxQueryAliasDepth {
+			return "", fmt.Errorf("query alias @%s: aliases nested too deeply (a cycle?)", name)
+		}
+		expanded, err := expandQuery(saved, aliases, depth+1)
+		if err != nil {
+			return "", err
+		}
+		words[i] = expanded
+	}
+	return strings.Join(words, " "), nil
+}
@@ -0,0 +1,46 @@
+package dag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandQuery(t *testing.T) {
+	repo := openTestRepo(t)
+	os.WriteFile(filepath.Join(repo.root, ".mx", "queries.json"),
+		[]byte(`{"projectx": "zeppelin hangar", "all": "@projectx @people", "loop": "@loop"}`), 0644)
+	repo.CreateNode("query:people", QueryType, []byte("hugo eckener\n"), nil)
+	repo.CreateNode("note:a", "Note", []byte("the zeppelin hangar"), nil)
+	repo.CreateNode("note:b", "Note", []byte("hugo eckener"), nil)
+
+	for _, tc := range []struct{ query, want string }{
+		{"budget", "budget"},
+		{"@projectx budget", "zeppelin hangar budget"},
+		{"@all", "zeppelin hangar hugo eckener"},
+	} {
+		if got, err := repo.ExpandQuery(tc.query); err != nil || got != tc.want {
+			t.Errorf("ExpandQuery(%q) = %q, %v; want %q", tc.query, got, err, tc.want)
+		}
+	}
+	if _, err := repo.ExpandQuery("@nope"); !errors.Is(err, ErrUnknownQueryAlias) {
+		t.Errorf("unknown alias: %v", err)
+	}
+	if _, err := repo.ExpandQuery("@loop"); err == nil {
+		t.Error("cyclic alias expanded")
+	}
+
+	nodes, err := repo.SearchNodes(t.Context(), "@projectx", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	if want := repo.Search.Search("zeppelin hangar", 0); !reflect.DeepEqual(ids, want) {
+		t.Errorf("SearchNodes(@projectx) = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,134 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Names of the in-memory indexes a repository builds when it opens. Until
+// one is ready, what depends on it sees partial results.
+const (
+	// IndexSearch covers everything built from the nodes themselves: the
+	// search, type, date, duplicate and meta indexes.
+	IndexSearch   = "search"
+	IndexCoAccess = "coaccess" // replayed from the access log
+	IndexCoChange = "cochange" // built from recent commits
+)
+
+// IndexState is how far building one index has got.
+type IndexState struct {
+	Name  string        `json:"name"`
+	Ready bool          `json:"ready"`
+	Took  time.Duration `json:"took"` // building time, or time so far if not ready
+	Error string        `json:"error,omitempty"`
+}
+
+// readiness tracks the indexes still building.
+type readiness struct {
+	mu      sync.Mutex
+	started time.Time
+	states  []IndexState
+	done    chan struct{} // closed once every index is ready
+}
+
+func newReadiness(names ...string) *readiness {
+	rd := &readiness{started: time.Now(), done: make(chan struct{})}
+	for _, name := range names {
+		rd.states = append(rd.states, IndexState{Name: name})
+	}
+	return rd
+}
+
+// finish marks index name built, with err if building it failed.
+func (rd *readiness) finish(name string, err error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	all := true
+	for i := range rd.states {
+		st := &rd.states[i]
+		if st.Name == name && !st.Ready {
+			st.Ready, st.Took = true, time.Since(rd.started)
+			if err != nil {
+				st.Error = err.Error()
+			}
+		}
+		all = all && st.Ready
+	}
+	if all {
+		select {
+		case <-rd.done:
+		default:
+			close(rd.done)
+		}
+	}
+}
+
+// OpenRepositoryBackground is OpenRepository for a daemon that should serve
+// as soon as it can: it returns once the repository's files are open and
+// builds the in-memory indexes in the background. Reads and writes of
+// nodes work at once; searches and other views built on the indexes see
+// partial results until Ready says otherwise. A non-empty profile
+// overrides Config.Profile.
+func OpenRepositoryBackground(root, profile string) (*Repository, error) {
+	return openRepository(root, true, profile)
+}
+
+// buildIndexes builds the indexes openRepository leaves empty, marking
+// each ready as it finishes. Returns the first failure. Under
+// ProfileLowMemory the co-access index is left to fill from new reads.
+func (r *Repository) buildIndexes(accessLogPath string, withMeta bool) error {
+	rd := r.shared.ready
+	if !r.lowMemory() {
+		r.CoAccess.loadLocked(accessLogPath)
+	}
+	rd.finish(IndexCoAccess, nil)
+	err := r.rebuildSearchIndex(withMeta)
+	rd.finish(IndexSearch, err)
+	r.CoChange.Build()
+	rd.finish(IndexCoChange, nil)
+	if err != nil {
+		return fmt.Errorf("rebuild search index: %w", err)
+	}
+	return nil
+}
+
+// Ready reports whether the named indexes, or with none named every
+// index, have been built.
+func (r *Repository) Ready(names ...string) bool {
+	rd := r.shared.ready
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	for _, st := range rd.states {
+		if !st.Ready && (len(names) == 0 || slices.Contains(names, st.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitReady blocks until every index has been built or ctx is done.
+func (r *Repository) WaitReady(ctx context.Context) error {
+	select {
+	case <-r.shared.ready.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IndexStates returns the state of each index, in building order.
+func (r *Repository) IndexStates() []IndexState {
+	rd := r.shared.ready
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	out := slices.Clone(rd.states)
+	for i := range out {
+		if !out[i].Ready {
+			out[i].Took = time.Since(rd.started)
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package dag
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOpenRepositoryBackground(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("zeppelin"), nil)
+	repo.CreateNode("note:b", "Note", []byte("airship"), nil)
+	if !repo.Ready() {
+		t.Error("OpenRepository returned before its indexes were built")
+	}
+
+	reopened, err := OpenRepositoryBackground(repo.root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, err := reopened.GetNode("note:a"); err != nil || string(node.Content) != "zeppelin" {
+		t.Errorf("GetNode while building = %v, %v", node, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := reopened.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Search.FilterByType("Note", 0); len(got) != 2 {
+		t.Errorf("notes after building = %v", got)
+	}
+	for _, st := range reopened.IndexStates() {
+		if !st.Ready || st.Error != "" {
+			t.Errorf("index state %+v", st)
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.shared.ready = newReadiness(IndexSearch, IndexCoChange)
+	if repo.Ready() || repo.Ready(IndexSearch) || !repo.Ready(IndexCoAccess) {
+		t.Error("nothing built yet, but reported ready")
+	}
+	repo.Config.Schedule = map[string]string{"compact": "@every 1m"}
+	if err := repo.Scheduler.RunDue(context.Background(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if st := repo.Scheduler.Status(); !st[0].LastRun.IsZero() {
+		t.Error("a scheduled job ran while the indexes were building")
+	}
+
+	repo.shared.ready.finish(IndexSearch, nil)
+	if !repo.Ready(IndexSearch) || repo.Ready() {
+		t.Error("search built, cochange not")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := repo.WaitReady(ctx); err == nil {
+		t.Error("WaitReady returned with cochange still building")
+	}
+	repo.shared.ready.finish(IndexCoChange, nil)
+	if err := repo.WaitReady(context.Background()); err != nil || !repo.Ready() {
+		t.Errorf("WaitReady = %v after everything was built", err)
+	}
+}
@@ -0,0 +1,136 @@
+package dag
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RecencyIndex tracks when each live node was last modified and last read,
+// backing the /recent/ views. Modification times come from the envelopes
+// themselves; access times are replayed from the access log at startup and
+// then fed by FUSE read events.
+type RecencyIndex struct {
+	mu       sync.RWMutex
+	modified map[string]time.Time // live nodes only
+	accessed map[string]time.Time // may include nodes that are no longer live
+}
+
+// NewRecencyIndex creates a RecencyIndex, loading last-access times from the
+// access log at logPath. A missing log is not an error.
+func NewRecencyIndex(logPath string) *RecencyIndex {
+	idx := &RecencyIndex{
+		modified: make(map[string]time.Time),
+		accessed: make(map[string]time.Time),
+	}
+	idx.load(logPath)
+	return idx
+}
+
+func (idx *RecencyIndex) load(logPath string) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return // no log yet
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry accessLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			continue
+		}
+		if ts.After(idx.accessed[entry.NodeID]) {
+			idx.accessed[entry.NodeID] = ts
+		}
+	}
+}
+
+// IndexNode records a live node's modification time.
+func (idx *RecencyIndex) IndexNode(id string, node *NodeEnvelope) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.modified[id] = node.Modified
+}
+
+// RemoveNode marks a node as no longer live. Its access time is kept so a
+// recreated node keeps its place in /recent/accessed/.
+func (idx *RecencyIndex) RemoveNode(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.modified, id)
+}
+
+// RecordAccess notes a read of nodeID at ts.
+func (idx *RecencyIndex) RecordAccess(nodeID string, ts time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if ts.After(idx.accessed[nodeID]) {
+		idx.accessed[nodeID] = ts
+	}
+}
+
+// LastAccess returns when nodeID was last read, if ever.
+func (idx *RecencyIndex) LastAccess(nodeID string) (time.Time, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	ts, ok := idx.accessed[nodeID]
+	return ts, ok
+}
+
+// RecentlyModified returns up to limit live node IDs, most recently
+// modified first.
+func (idx *RecencyIndex) RecentlyModified(limit int) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return newestFirst(idx.modified, nil, limit)
+}
+
+// RecentlyAccessed returns up to limit live node IDs, most recently read
+// first.
+func (idx *RecencyIndex) RecentlyAccessed(limit int) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return newestFirst(idx.accessed, idx.modified, limit)
+}
+
+// newestFirst ranks times descending (ties by ID). If live is non-nil, only
+// IDs present in it are considered.
+func newestFirst(times map[string]time.Time, live map[string]time.Time, limit int) []string {
+	type stamped struct {
+		id string
+		ts time.Time
+	}
+	results := make([]stamped, 0, len(times))
+	for id, ts := range times {
+		if live != nil {
+			if _, ok := live[id]; !ok {
+				continue
+			}
+		}
+		results = append(results, stamped{id, ts})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].ts.Equal(results[j].ts) {
+			return results[i].ts.After(results[j].ts)
+		}
+		return results[i].id < results[j].id
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.id
+	}
+	return ids
+}
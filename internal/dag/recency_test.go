@@ -0,0 +1,64 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecency_ModifiedOrder(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	time.Sleep(2 * time.Millisecond)
+	repo.UpdateContent("note:a", []byte("a2"))
+
+	got := repo.Recency.RecentlyModified(10)
+	if len(got) != 2 || got[0] != "note:a" {
+		t.Errorf("RecentlyModified = %v, want note:a first", got)
+	}
+
+	repo.DeleteNode("note:a", false)
+	got = repo.Recency.RecentlyModified(10)
+	if len(got) != 1 || got[0] != "note:b" {
+		t.Errorf("after delete, RecentlyModified = %v, want [note:b]", got)
+	}
+}
+
+func TestRecency_AccessedSkipsDeadNodes(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateNode("note:b", "Note", nil, nil)
+
+	now := time.Now()
+	repo.Recency.RecordAccess("note:a", now)
+	repo.Recency.RecordAccess("note:b", now.Add(time.Second))
+	repo.Recency.RecordAccess("note:ghost", now.Add(2*time.Second))
+
+	got := repo.Recency.RecentlyAccessed(10)
+	if len(got) != 2 || got[0] != "note:b" || got[1] != "note:a" {
+		t.Errorf("RecentlyAccessed = %v, want [note:b note:a]", got)
+	}
+}
+
+func TestRecency_LoadsAccessLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "access.jsonl")
+	lines := `{"ts":"2025-01-01T10:00:00Z","node":"note:a","field":"content"}
+{"ts":"2025-01-01T11:00:00Z","node":"note:b","field":"content"}
+{"ts":"2025-01-01T12:00:00Z","node":"note:a","field":"meta"}
+`
+	if err := os.WriteFile(logPath, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewRecencyIndex(logPath)
+	idx.IndexNode("note:a", &NodeEnvelope{})
+	idx.IndexNode("note:b", &NodeEnvelope{})
+
+	got := idx.RecentlyAccessed(0)
+	if len(got) != 2 || got[0] != "note:a" {
+		t.Errorf("RecentlyAccessed = %v, want note:a first (latest access at 12:00)", got)
+	}
+}
@@ -1,23 +1,40 @@
 package dag
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	gocid "github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multibase"
 )
 
 // RefStore manages human-readable ID -> CID mappings as files.
-// Each ref is a file in the refs/ directory whose content is the CID string.
-// Filenames use URL-safe encoding: colons become double underscores.
+// Each ref is a file whose content is the CID string, kept in one of 256
+// shard subdirectories of refs/ (refs/3f/note%3Aa) so that no directory
+// grows past a few hundred entries at 100k+ refs. Filenames are the
+// percent-encoded ID; see refFilename.
 type RefStore struct {
 	dir string
 }
 
-// NewRefStore creates a RefStore at the given directory.
+// refFormat names the current on-disk layout; it is written to
+// refs/.format once older layouts have been migrated, so that rerunning
+// the migration does not take current names for old ones.
+const (
+	refFormatFile = ".format"
+	refFormat     = "2"
+)
+
+// NewRefStore creates a RefStore at the given directory. Refs in an older
+// layout are moved by the format v3 migration; see formatMigrations.
 func NewRefStore(dir string) (*RefStore, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("create refs dir: %w", err)
@@ -25,27 +42,157 @@ func NewRefStore(dir string) (*RefStore, error) {
 	return &RefStore{dir: dir}, nil
 }
 
+// refShard is the shard directory for a ref filename: the first byte of
+// its SHA-256, in hex.
+func refShard(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:1])
+}
+
+// path returns the file holding id's ref.
+func (r *RefStore) path(id string) string {
+	name := refFilename(id)
+	return filepath.Join(r.dir, refShard(name), name)
+}
+
+// migrate brings refs from older layouts into the current one: files
+// directly in refs/, and files named with the old encoding, where colons
+// became double underscores. That encoding could not tell "a__b" from
+// "a:b"; old refs keep the colon reading they always had. Renames are
+// atomic, so an interrupted migration resumes on the next open.
+func (r *RefStore) migrate() error {
+	if data, err := os.ReadFile(filepath.Join(r.dir, refFormatFile)); err == nil && strings.TrimSpace(string(data)) == refFormat {
+		return nil
+	}
+	var legacy []string // paths relative to r.dir
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("list refs: %w", err)
+	}
+	for _, e := range entries {
+		switch {
+		case e.Name() == refFormatFile || strings.HasPrefix(e.Name(), ".tmp-"):
+		case !e.IsDir():
+			legacy = append(legacy, e.Name())
+		default:
+			shard, err := os.ReadDir(filepath.Join(r.dir, e.Name()))
+			if err != nil {
+				return fmt.Errorf("list refs: %w", err)
+			}
+			for _, f := range shard {
+				if !f.IsDir() && !strings.HasPrefix(f.Name(), ".tmp-") {
+					legacy = append(legacy, filepath.Join(e.Name(), f.Name()))
+				}
+			}
+		}
+	}
+
+	moved := 0
+	for _, rel := range legacy {
+		from := filepath.Join(r.dir, rel)
+		to := r.path(strings.ReplaceAll(filepath.Base(rel), "__", ":"))
+		if from == to {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return fmt.Errorf("create ref shard: %w", err)
+		}
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("migrate ref %s: %w", rel, err)
+		}
+		moved++
+	}
+	if moved > 0 {
+		fmt.Printf("memex-fs: migrated %d refs to the current layout\n", moved)
+	}
+	return SafeWrite(filepath.Join(r.dir, refFormatFile), []byte(refFormat+"\n"), 0644)
+}
+
+// refFilename encodes id as a filename. Bytes that are unsafe in a
+// filename on some platform — "%", "/", backslash, ":", control characters and
+// a leading "." — are percent-encoded; everything else, including non-ASCII
+// UTF-8, is kept as is. The encoding is reversible by refIDFromFilename.
 func refFilename(id string) string {
-	return strings.ReplaceAll(id, ":", "__")
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c == '%' || c == '/' || c == '\\' || c == ':' || c < 0x20 || c == 0x7f || (i == 0 && c == '.') {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
 }
 
+// refIDFromFilename decodes a refFilename name. Malformed escapes are
+// kept literally.
 func refIDFromFilename(name string) string {
-	return strings.ReplaceAll(name, "__", ":")
+	if !strings.Contains(name, "%") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '%' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// ErrInvalidID is returned, wrapped, for IDs ValidateID rejects. It
+// matches ErrValidation.
+var ErrInvalidID error = &kindError{"invalid node ID", ErrValidation}
+
+// maxRefFilename leaves room for the ".json" an edit lock file adds to a
+// ref filename within the usual 255-byte limit.
+const maxRefFilename = 250
+
+// ValidateID reports whether id can name a node. "/" is reserved because
+// IDs are path components of every mounted view, and "#" because it
+// separates a node from a block in link targets ("paper:abc#b3"). Control
+// characters, invalid UTF-8, "." and ".." are rejected too, as is an ID
+// too long to store.
+func ValidateID(id string) error {
+	switch {
+	case id == "" || id == "." || id == "..":
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	case strings.ContainsAny(id, "/#"):
+		return fmt.Errorf("%w: %q contains a reserved character (/ or #)", ErrInvalidID, id)
+	case !utf8.ValidString(id):
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidID, id)
+	case strings.IndexFunc(id, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w: %q contains a control character", ErrInvalidID, id)
+	case len(refFilename(id)) > maxRefFilename:
+		return fmt.Errorf("%w: too long", ErrInvalidID)
+	}
+	return nil
 }
 
 // Set writes a ref mapping id -> cid.
 func (r *RefStore) Set(id string, c gocid.Cid) error {
-	path := filepath.Join(r.dir, refFilename(id))
+	path := r.path(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create ref shard: %w", err)
+	}
 	encoded, _ := multibase.Encode(multibase.Base32, c.Bytes())
 	return SafeWrite(path, []byte(encoded), 0644)
 }
 
 // Get resolves a human-readable ID to a CID.
 func (r *RefStore) Get(id string) (gocid.Cid, error) {
-	path := filepath.Join(r.dir, refFilename(id))
+	path := r.path(id)
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return gocid.Undef, fmt.Errorf("ref %w: %s", ErrNotFound, id)
+	}
 	if err != nil {
-		return gocid.Undef, fmt.Errorf("ref not found: %s", id)
+		return gocid.Undef, fmt.Errorf("read ref %s: %w", id, err)
 	}
 	_, cidBytes, err := multibase.Decode(strings.TrimSpace(string(data)))
 	if err != nil {
@@ -56,29 +203,40 @@ func (r *RefStore) Get(id string) (gocid.Cid, error) {
 
 // Delete removes a ref.
 func (r *RefStore) Delete(id string) error {
-	path := filepath.Join(r.dir, refFilename(id))
+	path := r.path(id)
 	return os.Remove(path)
 }
 
 // Has checks if a ref exists.
 func (r *RefStore) Has(id string) bool {
-	path := filepath.Join(r.dir, refFilename(id))
+	path := r.path(id)
 	_, err := os.Stat(path)
 	return err == nil
 }
 
-// List returns all ref IDs.
+// List returns all ref IDs, sorted.
 func (r *RefStore) List() ([]string, error) {
-	entries, err := os.ReadDir(r.dir)
+	shards, err := os.ReadDir(r.dir)
 	if err != nil {
 		return nil, fmt.Errorf("list refs: %w", err)
 	}
-	ids := make([]string, 0, len(entries))
-	for _, e := range entries {
-		if e.IsDir() {
+	var ids []string
+	for _, shard := range shards {
+		if !shard.IsDir() {
 			continue
 		}
-		ids = append(ids, refIDFromFilename(e.Name()))
+		entries, err := os.ReadDir(filepath.Join(r.dir, shard.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("list refs: %w", err)
+		}
+		for _, e := range entries {
+			// Skip SafeWrite temp files from a Set in flight.
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+				continue
+			}
+			ids = append(ids, refIDFromFilename(e.Name()))
+		}
 	}
+	sort.Strings(ids)
 	return ids, nil
 }
@@ -0,0 +1,93 @@
+package dag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRefStore_ShardedLayout(t *testing.T) {
+	dir := t.TempDir()
+	refs, err := NewRefStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := ComputeCID([]byte("x"))
+	if err := refs.Set("note:a", c); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, refShard("note%3Aa"), "note%3Aa")
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("ref not at %s: %v", want, err)
+	}
+	if got, err := refs.Get("note:a"); err != nil || got != c {
+		t.Errorf("Get = %v, %v", got, err)
+	}
+	if err := refs.Delete("note:a"); err != nil || refs.Has("note:a") {
+		t.Errorf("Delete: err %v, still present %v", err, refs.Has("note:a"))
+	}
+}
+
+func TestRefStore_MigratesOlderLayouts(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := ComputeCID([]byte("x"))
+	encoded := CIDToFilename(c)
+	// One ref in the original flat layout, one sharded under its old name.
+	os.WriteFile(filepath.Join(dir, "note__a"), []byte(encoded), 0644)
+	os.MkdirAll(filepath.Join(dir, refShard("person__bob")), 0755)
+	os.WriteFile(filepath.Join(dir, refShard("person__bob"), "person__bob"), []byte(encoded), 0644)
+
+	refs, err := NewRefStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := refs.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := refs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"note:a", "person:bob"}) {
+		t.Errorf("List = %v", ids)
+	}
+	if got, err := refs.Get("person:bob"); err != nil || got != c {
+		t.Errorf("Get after migration = %v, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "note__a")); !os.IsNotExist(err) {
+		t.Error("flat ref file left behind")
+	}
+}
+
+func TestRefFilename_RoundTrip(t *testing.T) {
+	for _, id := range []string{
+		"note:a", "my__note", "50%:done", `back\slash`, ".hidden", "café:été", "a:b:c",
+	} {
+		name := refFilename(id)
+		if strings.ContainsAny(name, ":/\\") || strings.HasPrefix(name, ".") {
+			t.Errorf("refFilename(%q) = %q is not filename-safe", id, name)
+		}
+		if got := refIDFromFilename(name); got != id {
+			t.Errorf("round trip %q -> %q -> %q", id, name, got)
+		}
+	}
+	if refFilename("my__note") == refFilename("my:note") {
+		t.Error(`"my__note" and "my:note" share a filename`)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"note:a", "café", "my__note", "50%"} {
+		if err := ValidateID(id); err != nil {
+			t.Errorf("ValidateID(%q) = %v", id, err)
+		}
+	}
+	for _, id := range []string{"", ".", "..", "a/b", "paper#b3", "tab\there", "bad\xff", strings.Repeat("x", 300)} {
+		if err := ValidateID(id); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("ValidateID(%q) = %v, want ErrInvalidID", id, err)
+		}
+	}
+}
@@ -2,21 +2,50 @@ package dag
 
 import "sort"
 
-// RelatednessIndex combines co-access and co-change signals into a single ranking.
+// RelatednessIndex combines co-access and co-change signals, and links of
+// the types in relatedLinkTypes, into a single ranking.
 type RelatednessIndex struct {
 	coAccess *CoAccessIndex
 	coChange *CoChangeIndex
+	links    *LinkIndex
+}
+
+// relatedLinkTypes are link types that record an association rather than
+// a reference, with the weight each link adds in either direction. Nodes
+// created in bulk, such as imported shell commands, have no access or
+// edit history to relate them by.
+var relatedLinkTypes = map[string]float64{
+	LinkRanIn: 3.0,
 }
 
 // NewRelatednessIndex creates a combined relatedness index.
-func NewRelatednessIndex(coAccess *CoAccessIndex, coChange *CoChangeIndex) *RelatednessIndex {
-	return &RelatednessIndex{coAccess: coAccess, coChange: coChange}
+func NewRelatednessIndex(coAccess *CoAccessIndex, coChange *CoChangeIndex, links *LinkIndex) *RelatednessIndex {
+	return &RelatednessIndex{coAccess: coAccess, coChange: coChange, links: links}
+}
+
+// ScoredNode is a node ID with the relevance score that ranked it.
+type ScoredNode struct {
+	ID    string
+	Score float64
 }
 
 // Related returns the top related nodes, merging co-access (weight 1.0) and
 // co-change (weight 2.0) scores. Co-change is weighted higher because it
 // represents intentional editing, not just observation.
 func (r *RelatednessIndex) Related(nodeID string, limit int) []string {
+	scored := r.RelatedScored(nodeID, limit)
+	if scored == nil {
+		return nil
+	}
+	ids := make([]string, len(scored))
+	for i, s := range scored {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+// RelatedScored is Related with each node's combined score.
+func (r *RelatednessIndex) RelatedScored(nodeID string, limit int) []ScoredNode {
 	scores := make(map[string]float64)
 
 	// Co-access scores (weight 1.0)
@@ -33,32 +62,35 @@ func (r *RelatednessIndex) Related(nodeID string, limit int) []string {
 	}
 	r.coChange.mu.RUnlock()
 
+	for _, l := range r.links.AllLinks(nodeID) {
+		w, ok := relatedLinkTypes[l.Type]
+		if !ok {
+			continue
+		}
+		peer := LinkTargetParent(l.Target)
+		if peer == nodeID {
+			peer = l.Source
+		}
+		scores[peer] += w
+	}
+
 	if len(scores) == 0 {
 		return nil
 	}
 
-	type scored struct {
-		id    string
-		score float64
-	}
-	var results []scored
+	var results []ScoredNode
 	for id, score := range scores {
-		results = append(results, scored{id, score})
+		results = append(results, ScoredNode{id, score})
 	}
 	sort.Slice(results, func(i, j int) bool {
-		if results[i].score != results[j].score {
-			return results[i].score > results[j].score
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
 		}
-		return results[i].id < results[j].id
+		return results[i].ID < results[j].ID
 	})
 
 	if limit > 0 && len(results) > limit {
 		results = results[:limit]
 	}
-
-	ids := make([]string, len(results))
-	for i, r := range results {
-		ids[i] = r.id
-	}
-	return ids
+	return results
 }
@@ -0,0 +1,103 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultRemote is the name clone gives the repository it cloned from.
+const DefaultRemote = "origin"
+
+// Remote is a repository pushed to and pulled from by name: its source, as
+// given to pull (a DID, an IPNS name, a commit CID, or the ssh:// or http://
+// address of another memex), and the commit last exchanged with it.
+type Remote struct {
+	Source string    `json:"source"`
+	Head   string    `json:"head,omitempty"`
+	Pulled time.Time `json:"pulled,omitzero"`
+}
+
+func (r *Repository) remotesPath() string {
+	return filepath.Join(r.MxDir(), "remotes.json")
+}
+
+func (r *Repository) loadRemotes() (map[string]*Remote, error) {
+	remotes := make(map[string]*Remote)
+	data, err := os.ReadFile(r.remotesPath())
+	if os.IsNotExist(err) {
+		return remotes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read remotes: %w", err)
+	}
+	if err := json.Unmarshal(data, &remotes); err != nil {
+		return nil, fmt.Errorf("parse remotes: %w", err)
+	}
+	return remotes, nil
+}
+
+// Remotes returns the repository's remotes by name.
+func (r *Repository) Remotes() (map[string]*Remote, error) {
+	return r.loadRemotes()
+}
+
+// GetRemote returns the remote name, or nil if there is none.
+func (r *Repository) GetRemote(name string) (*Remote, error) {
+	remotes, err := r.loadRemotes()
+	if err != nil {
+		return nil, err
+	}
+	return remotes[name], nil
+}
+
+// AddRemote adds the remote name at source.
+func (r *Repository) AddRemote(name, source string) error {
+	return r.updateRemotes(func(remotes map[string]*Remote) error {
+		if remotes[name] != nil {
+			return errorOf(ErrConflict, "remote %s already exists", name)
+		}
+		remotes[name] = &Remote{Source: source}
+		return nil
+	})
+}
+
+// RemoveRemote removes the remote name.
+func (r *Repository) RemoveRemote(name string) error {
+	return r.updateRemotes(func(remotes map[string]*Remote) error {
+		if remotes[name] == nil {
+			return fmt.Errorf("no remote %s", name)
+		}
+		delete(remotes, name)
+		return nil
+	})
+}
+
+// SetRemote records that head was exchanged with source as the remote
+// name, adding the remote if it is new.
+func (r *Repository) SetRemote(name, source, head string) error {
+	return r.updateRemotes(func(remotes map[string]*Remote) error {
+		remotes[name] = &Remote{Source: source, Head: head, Pulled: time.Now().UTC().Truncate(time.Second)}
+		return nil
+	})
+}
+
+// updateRemotes applies fn to the remotes and saves them.
+func (r *Repository) updateRemotes(fn func(map[string]*Remote) error) error {
+	r.shared.remotesMu.Lock()
+	defer r.shared.remotesMu.Unlock()
+	remotes, err := r.loadRemotes()
+	if err != nil {
+		return err
+	}
+	if err := fn(remotes); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(remotes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(r.remotesPath(), data, 0644)
+}
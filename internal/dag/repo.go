@@ -1,12 +1,15 @@
 package dag
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -22,22 +25,73 @@ type Repository struct {
 	Refs        *RefStore
 	Links       *LinkIndex
 	Search      *SearchIndex
+	Duplicates  *DuplicateIndex
+	Dates       *DateIndex
+	Recency     *RecencyIndex
+	Meta        *MetaIndex
 	Commits     *CommitLog
 	CoAccess    *CoAccessIndex
 	CoChange    *CoChangeIndex
 	Relatedness *RelatednessIndex
 	Neighbors   *NeighborsIndex
 	Emergent    *EmergentIndex
+	Pins        *PinSet
+	Rejections  *RejectionSet
+	Tags        *TagSet
+	Audit       *IntegrityAudit
+	Scheduler   *Scheduler
+	EditLocks   *EditLockSet
+	Hooks       *HookSet
+	Webhooks    *Webhooks
+	Summarizer  *Summarizer
+	LLM         LLM // nil when no model is configured
+	Recognizer  *Recognizer
+	OCR         OCR // nil when no OCR is configured
+	Transcriber *Transcriber
+	Speech      SpeechToText // nil when no transcription is configured
+	Config      *Config
+
+	identity *Identity     // signs bundles; nil when the identity failed to load
+	shallow  *shallowState // what partial pulls left out; see MarkShallow
+	actor    string        // DID writes are attributed to, if not identity's; see As
+	profile  string        // resource profile; see Config.Profile
+	overlay  *Overlay      // what r is an overlay of; nil for a repository proper
+	shared   *repoShared
+}
+
+// repoShared is the state a Repository shares with the views As returns.
+type repoShared struct {
+	nodeLocks   keyedMutex // per-node write locks, see lockNode
+	commitMu    sync.Mutex // keeps HEAD a single chain under concurrent commits
+	tokensMu    sync.Mutex // serializes edits to .mx/tokens.json
+	conflictsMu sync.Mutex // serializes edits to .mx/conflicts.json
+	remotesMu   sync.Mutex // serializes edits to .mx/remotes.json
+	protectMu   sync.Mutex // serializes edits to .mx/protected.json
+	ingestMu    sync.Mutex // serializes edits to .mx/ingest-cache.json
+	activity    activityCache
+	storage     storageCache
+	asks        askCache
+	ready       *readiness // indexes still building; see OpenRepositoryBackground
 }
 
 // OpenRepository opens or creates a repository at the given path.
 func OpenRepository(root string) (*Repository, error) {
+	return openRepository(root, false, "")
+}
+
+// openRepository is OpenRepository, building the in-memory indexes in the
+// background when background is set.
+func openRepository(root string, background bool, profile string) (*Repository, error) {
 	mxDir := filepath.Join(root, ".mx")
+	overlay, err := loadOverlay(mxDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// Ensure directory structure
 	for _, dir := range []string{
 		mxDir,
-		filepath.Join(mxDir, "objects"),
+		objectsDir(mxDir, overlay),
 		filepath.Join(mxDir, "refs"),
 		filepath.Join(mxDir, "dagit"),
 	} {
@@ -50,17 +104,31 @@ func OpenRepository(root string) (*Repository, error) {
 	metaPath := filepath.Join(mxDir, "meta.json")
 	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
 		meta := map[string]interface{}{
-			"version": 1,
+			"version": RepoFormat,
 			"created": time.Now().UTC().Format(time.RFC3339),
+			"hash":    DefaultObjectFormat.Hash,
+			"codec":   DefaultObjectFormat.Codec,
 		}
 		data, _ := json.MarshalIndent(meta, "", "  ")
 		os.WriteFile(metaPath, data, 0644)
 	}
 
-	store, err := NewObjectStore(filepath.Join(mxDir, "objects"))
+	if err := upgradeRepo(context.Background(), mxDir); err != nil {
+		return nil, err
+	}
+
+	store, err := NewObjectStore(objectsDir(mxDir, overlay))
 	if err != nil {
 		return nil, err
 	}
+	store.shared = overlay != nil
+	format, err := loadObjectFormat(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetFormat(format); err != nil {
+		return nil, err
+	}
 
 	refs, err := NewRefStore(filepath.Join(mxDir, "refs"))
 	if err != nil {
@@ -74,24 +142,61 @@ func OpenRepository(root string) (*Repository, error) {
 
 	search := NewSearchIndex()
 
+	cfg, err := LoadConfig(filepath.Join(mxDir, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProfile(profile); err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = cfg.Profile
+	}
+
+	pins, err := NewPinSet(filepath.Join(mxDir, "pinned.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	rejections, err := NewRejectionSet(filepath.Join(mxDir, "rejected-suggestions.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := NewTagSet(filepath.Join(mxDir, "tags.json"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Load shared identity for commit authorship
 	author := ""
-	if id, err := LoadIdentity(); err != nil {
+	identity, err := LoadIdentity()
+	if err != nil {
 		fmt.Printf("memex-fs: identity warning: %v\n", err)
 	} else {
-		author = id.DID
+		author = identity.DID
 	}
 
 	commits := NewCommitLog(filepath.Join(mxDir, "HEAD"), store, author)
+	shallow, err := loadShallow(shallowPath(mxDir))
+	if err != nil {
+		return nil, err
+	}
+	if shallow.info != nil {
+		commits.setBoundary(shallow.info.Boundary)
+	}
+
+	editLocks, err := NewEditLockSet(filepath.Join(mxDir, "locks"), author)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build advisory indexes (failures are warnings, not fatal)
 	accessLogPath := filepath.Join(mxDir, "access.jsonl")
-	coAccess := NewCoAccessIndex(accessLogPath, coAccessWindow)
-
+	coAccess := newCoAccessIndex(coAccessWindow)
 	coChange := NewCoChangeIndex(commits, coChangeWindow)
-	coChange.Build()
 
-	relatedness := NewRelatednessIndex(coAccess, coChange)
+	relatedness := NewRelatednessIndex(coAccess, coChange, links)
 
 	repo := &Repository{
 		root:        root,
@@ -99,22 +204,90 @@ func OpenRepository(root string) (*Repository, error) {
 		Refs:        refs,
 		Links:       links,
 		Search:      search,
+		Duplicates:  NewDuplicateIndex(),
+		Dates:       NewDateIndex(),
+		Recency:     NewRecencyIndex(accessLogPath),
+		Meta:        NewMetaIndex(filepath.Join(mxDir, "metaindex.json"), cfg.MetaIndexes),
 		Commits:     commits,
 		CoAccess:    coAccess,
 		CoChange:    coChange,
 		Relatedness: relatedness,
+		Pins:        pins,
+		Rejections:  rejections,
+		Tags:        tags,
+		EditLocks:   editLocks,
+		Hooks:       NewHookSet(filepath.Join(mxDir, "hooks"), root),
+		Webhooks:    NewWebhooks(cfg.Webhooks),
+		Config:      cfg,
+		profile:     profile,
+		overlay:     overlay,
+		identity:    identity,
+		shallow:     shallow,
+		shared:      &repoShared{ready: newReadiness(IndexCoAccess, IndexSearch, IndexCoChange)},
 	}
 	repo.Neighbors = NewNeighborsIndex(links, search, coChange, coAccess, repo)
 	repo.Emergent = NewEmergentIndex(repo.Neighbors, refs)
+	repo.Audit = NewIntegrityAudit(repo)
+	repo.Scheduler = NewScheduler(repo)
+	repo.Summarizer = NewSummarizer(repo, cfg.SummarizeMinBytes)
+	if cfg.LLM != nil && cfg.LLM.Endpoint != "" {
+		repo.LLM = NewChatLLM(*cfg.LLM)
+	}
+	repo.Recognizer = NewRecognizer(repo)
+	if cfg.OCR != nil {
+		repo.OCR = NewOCR(*cfg.OCR)
+	}
+	repo.Transcriber = NewTranscriber(repo)
+	if cfg.Transcription != nil {
+		repo.Speech = NewSpeechToText(*cfg.Transcription)
+	}
 
-	// Rebuild search index from all refs
-	if err := repo.rebuildSearchIndex(); err != nil {
-		return nil, fmt.Errorf("rebuild search index: %w", err)
+	if cfg.AppendOnly {
+		if err := repo.openAppendOnly(); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.applyMemoryConfig(repo.memoryConfig()); err != nil {
+		return nil, err
+	}
+
+	// Rebuild in-memory indexes from all refs. The MetaIndex is reused from
+	// disk when it was saved at the current HEAD.
+	metaFresh := repo.Meta.Load(repo.headKey())
+	if background {
+		go func() {
+			if err := repo.buildIndexes(accessLogPath, !metaFresh); err != nil {
+				fmt.Printf("memex-fs: %v\n", err)
+			}
+		}()
+	} else if err := repo.buildIndexes(accessLogPath, !metaFresh); err != nil {
+		return nil, err
 	}
 
 	return repo, nil
 }
 
+// As returns a view of r whose writes are attributed to did, in node
+// versions' Author and in commits, instead of to the local identity. The
+// view shares everything else with r, including its locks, and may be
+// used alongside it. An empty did returns r.
+func (r *Repository) As(did string) *Repository {
+	if did == "" || did == r.actor {
+		return r
+	}
+	view := *r
+	view.actor = did
+	return &view
+}
+
+// author returns the DID r's writes are attributed to.
+func (r *Repository) author() string {
+	if r.actor != "" {
+		return r.actor
+	}
+	return r.Commits.author
+}
+
 // MxDir returns the path to the .mx/ data directory.
 func (r *Repository) MxDir() string {
 	return filepath.Join(r.root, ".mx")
@@ -123,48 +296,167 @@ func (r *Repository) MxDir() string {
 // commit is a helper that creates a commit after a mutation.
 // Failures are logged but do not propagate — commits are metadata, not essential.
 func (r *Repository) commit(message string) {
-	if _, err := r.Commits.Commit(r.Refs, r.Links, message); err != nil {
+	r.commitMerge(message, "")
+}
+
+// commitMerge is commit for a merge of the commit merged.
+func (r *Repository) commitMerge(message, merged string) {
+	r.shared.commitMu.Lock()
+	defer r.shared.commitMu.Unlock()
+	if _, err := r.Commits.commitMerge(r.author(), merged, r.Refs, r.Links, message); err != nil {
 		fmt.Printf("memex-fs: commit warning: %v\n", err)
 	}
 }
 
-// rebuildSearchIndex scans all refs and indexes every node.
-func (r *Repository) rebuildSearchIndex() error {
+// SaveIndexes writes the MetaIndex to disk stamped with HEAD, for the
+// next open to reuse instead of reindexing every node. Commits leave it
+// to this, which the mount calls on each maintenance pass and on
+// unmount, as the scheduled "indexes" job does. An index still being
+// built is not saved.
+func (r *Repository) SaveIndexes() error {
+	head := r.headKey()
+	if head == "" || !r.Ready() {
+		return nil
+	}
+	return r.Meta.Save(head)
+}
+
+// headKey returns HEAD as a string, or "" if there are no commits yet.
+func (r *Repository) headKey() string {
+	head, err := r.Commits.Head()
+	if err != nil || !head.Defined() {
+		return ""
+	}
+	return CIDToFilename(head)
+}
+
+// rebuildSearchIndex scans all refs and indexes every node. withMeta is
+// false when the MetaIndex was restored from disk and needs no rebuild.
+// Each node is read and indexed under its lock, so a rebuild running in
+// the background never indexes a version a concurrent write replaced.
+func (r *Repository) rebuildSearchIndex(withMeta bool) error {
 	ids, err := r.Refs.List()
 	if err != nil {
 		return err
 	}
+	backfill := false
 	for _, id := range ids {
+		unlock := r.lockNode(id)
 		node, err := r.getNodeEnvelope(id)
-		if err != nil {
-			continue // skip broken refs
-		}
-		if !node.Deleted {
-			r.Search.IndexNode(id, node)
+		if err == nil && !node.Deleted && r.addToIndexes(id, node, withMeta) {
+			backfill = true
 		}
+		unlock()
+	}
+	if backfill {
+		r.backfillMeta()
 	}
 	return nil
 }
 
+// indexNode adds a live node to every in-memory index. A Schema node that
+// declares new meta keys triggers a MetaIndex backfill.
+func (r *Repository) indexNode(id string, node *NodeEnvelope) {
+	if r.addToIndexes(id, node, true) {
+		r.backfillMeta()
+	}
+}
+
+// addToIndexes does the work of indexNode, reporting whether new meta keys
+// were declared. The backfill is left to the caller so a full rebuild only
+// pays for it once.
+func (r *Repository) addToIndexes(id string, node *NodeEnvelope, withMeta bool) bool {
+	r.Search.IndexNode(id, node)
+	r.indexExtractedText(id, node)
+	r.Duplicates.IndexNode(id, node)
+	r.Dates.IndexNode(id, node)
+	r.Recency.IndexNode(id, node)
+	return withMeta && len(r.Meta.IndexNode(id, node)) > 0
+}
+
+// backfillMeta feeds every live node through the MetaIndex again, picking
+// up keys a Schema node has just declared.
+func (r *Repository) backfillMeta() {
+	ids, err := r.Refs.List()
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		node, err := r.getNodeEnvelope(id)
+		if err != nil || node.Deleted {
+			continue
+		}
+		r.Meta.IndexNode(id, node)
+	}
+}
+
+// unindexNode drops a node from every in-memory index. Callers replacing a
+// node follow it with indexNode.
+func (r *Repository) unindexNode(id string) {
+	r.Search.RemoveNode(id)
+	r.Duplicates.RemoveNode(id)
+	r.Dates.RemoveNode(id)
+	r.Recency.RemoveNode(id)
+	r.Meta.RemoveNode(id)
+}
+
 // getNodeEnvelope resolves a ref to its NodeEnvelope.
 func (r *Repository) getNodeEnvelope(id string) (*NodeEnvelope, error) {
 	c, err := r.Refs.Get(id)
 	if err != nil {
 		return nil, err
 	}
-	data, err := r.Store.Get(c)
+	return r.Store.GetNode(c)
+}
+
+// putNode serializes a node version, stores it, and points id's ref at it.
+func (r *Repository) putNode(id string, node *NodeEnvelope) error {
+	c, err := r.Store.PutNode(node)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("store object: %w", err)
 	}
-	var node NodeEnvelope
-	if err := json.Unmarshal(data, &node); err != nil {
-		return nil, fmt.Errorf("unmarshal node: %w", err)
+	if err := r.Refs.Set(id, c); err != nil {
+		return fmt.Errorf("set ref: %w", err)
 	}
-	return &node, nil
+	return nil
 }
 
 // CreateNode creates a new node and stores it.
 func (r *Repository) CreateNode(id, typ string, content []byte, meta map[string]interface{}) (*NodeEnvelope, error) {
+	unlock := r.lockNode(id)
+	defer unlock()
+	return r.createNode(id, typ, content, meta)
+}
+
+// createNode is CreateNode for callers already holding id's lock.
+func (r *Repository) createNode(id, typ string, content []byte, meta map[string]interface{}) (*NodeEnvelope, error) {
+	node, err := r.storeNewNode(id, typ, content, meta)
+	if err != nil {
+		return nil, err
+	}
+	r.commit("create " + id)
+	r.afterCreate(node)
+	return node, nil
+}
+
+// storeNewNode stores and indexes a new node without committing, so bulk
+// importers can create many nodes under one commit. They call afterCreate
+// for each once it is committed. Callers hold id's lock.
+func (r *Repository) storeNewNode(id, typ string, content []byte, meta map[string]interface{}) (*NodeEnvelope, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	if err := r.EditLocks.Check(id); err != nil {
+		return nil, err
+	}
+	for k, v := range notebookMeta(content, meta) {
+		if meta == nil {
+			meta = make(map[string]interface{})
+		}
+		if v != nil {
+			meta[k] = v
+		}
+	}
 	now := time.Now().UTC()
 	node := &NodeEnvelope{
 		V:        1,
@@ -174,27 +466,30 @@ func (r *Repository) CreateNode(id, typ string, content []byte, meta map[string]
 		Meta:     meta,
 		Created:  now,
 		Modified: now,
+		Author:   r.author(),
 	}
-
-	data, err := CanonicalJSON(node)
-	if err != nil {
-		return nil, fmt.Errorf("serialize node: %w", err)
-	}
-
-	c, err := r.Store.Put(data)
-	if err != nil {
-		return nil, fmt.Errorf("store object: %w", err)
+	if err := r.Hooks.preWrite(HookCreate, node); err != nil {
+		return nil, err
 	}
 
-	if err := r.Refs.Set(id, c); err != nil {
-		return nil, fmt.Errorf("set ref: %w", err)
+	if err := r.putNode(id, node); err != nil {
+		return nil, err
 	}
 
-	r.Search.IndexNode(id, node)
-	r.commit("create " + id)
+	r.indexNode(id, node)
 	return node, nil
 }
 
+// afterCreate runs what follows a committed create: post-write hooks,
+// webhooks and the background summary, OCR and transcription.
+func (r *Repository) afterCreate(node *NodeEnvelope) {
+	r.Hooks.postWrite(HookCreate, node)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeCreated, ID: node.ID, Node: node})
+	r.Summarizer.onWrite(node)
+	r.Recognizer.onWrite(node)
+	r.Transcriber.onWrite(node)
+}
+
 // GetNode retrieves a node by its human-readable ID.
 func (r *Repository) GetNode(id string) (*NodeEnvelope, error) {
 	node, err := r.getNodeEnvelope(id)
@@ -202,13 +497,15 @@ func (r *Repository) GetNode(id string) (*NodeEnvelope, error) {
 		return nil, err
 	}
 	if node.Deleted {
-		return nil, fmt.Errorf("node deleted: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrDeleted, id)
 	}
 	return node, nil
 }
 
-// ListNodes returns all non-deleted node IDs with optional limit.
-func (r *Repository) ListNodes(limit int) ([]string, error) {
+// ListNodes returns all non-deleted node IDs with optional limit. It
+// reads every envelope, so it stops early with ctx's error if ctx is
+// cancelled.
+func (r *Repository) ListNodes(ctx context.Context, limit int) ([]string, error) {
 	ids, err := r.Refs.List()
 	if err != nil {
 		return nil, err
@@ -216,6 +513,9 @@ func (r *Repository) ListNodes(limit int) ([]string, error) {
 	// Filter out deleted nodes
 	var result []string
 	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		node, err := r.getNodeEnvelope(id)
 		if err != nil || node.Deleted {
 			continue
@@ -230,6 +530,28 @@ func (r *Repository) ListNodes(limit int) ([]string, error) {
 
 // UpdateNode patches a node's metadata, creating a new version.
 func (r *Repository) UpdateNode(id string, metaUpdates map[string]interface{}) (*NodeEnvelope, error) {
+	return r.UpdateNodeIfMatch(id, "", metaUpdates)
+}
+
+// UpdateNodeIfMatch is UpdateNode with compare-and-swap: when ifMatch is
+// set and id's ref no longer points at that CID, nothing is written and a
+// *ConflictError is returned.
+func (r *Repository) UpdateNodeIfMatch(id, ifMatch string, metaUpdates map[string]interface{}) (*NodeEnvelope, error) {
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	if err := r.checkMatch(id, ifMatch); err != nil {
+		return nil, err
+	}
+	return r.updateMeta(id, metaUpdates, "update meta "+id)
+}
+
+// updateMeta merges metaUpdates into id's meta and commits with msg. The
+// caller must hold id's node lock.
+func (r *Repository) updateMeta(id string, metaUpdates map[string]interface{}, msg string) (*NodeEnvelope, error) {
+	if err := r.EditLocks.Check(id); err != nil {
+		return nil, err
+	}
 	current, err := r.getNodeEnvelope(id)
 	if err != nil {
 		return nil, err
@@ -263,37 +585,43 @@ func (r *Repository) UpdateNode(id string, metaUpdates map[string]interface{}) (
 		Created:  current.Created,
 		Modified: now,
 		Prev:     CIDToFilename(prevCID),
+		Author:   r.author(),
 	}
-
-	data, err := CanonicalJSON(node)
-	if err != nil {
-		return nil, fmt.Errorf("serialize node: %w", err)
-	}
-
-	c, err := r.Store.Put(data)
-	if err != nil {
-		return nil, fmt.Errorf("store object: %w", err)
+	if err := r.Hooks.preWrite(HookUpdate, node); err != nil {
+		return nil, err
 	}
 
-	if err := r.Refs.Set(id, c); err != nil {
-		return nil, fmt.Errorf("update ref: %w", err)
+	if err := r.putNode(id, node); err != nil {
+		return nil, err
 	}
 
-	r.Search.RemoveNode(id)
-	r.Search.IndexNode(id, node)
-	r.commit("update meta " + id)
+	r.unindexNode(id)
+	r.indexNode(id, node)
+	r.commit(msg)
+	r.Hooks.postWrite(HookUpdate, node)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeUpdated, ID: id, Node: node})
 	return node, nil
 }
 
 // DeleteNode soft-deletes a node by creating a tombstone.
 func (r *Repository) DeleteNode(id string, force bool) error {
+	unlock := r.lockNode(id)
+	defer unlock()
+	if err := r.EditLocks.Check(id); err != nil {
+		return err
+	}
+
 	if force {
+		if r.Config.AppendOnly {
+			return ErrAppendOnly
+		}
 		// Hard delete: just remove the ref
-		r.Search.RemoveNode(id)
+		r.unindexNode(id)
 		if err := r.Refs.Delete(id); err != nil {
 			return err
 		}
 		r.commit("delete " + id)
+		r.Webhooks.Notify(WebhookEvent{Event: EventNodeDeleted, ID: id})
 		return nil
 	}
 
@@ -313,29 +641,43 @@ func (r *Repository) DeleteNode(id string, force bool) error {
 		Modified: time.Now().UTC(),
 		Prev:     CIDToFilename(prevCID),
 		Deleted:  true,
+		Author:   r.author(),
 	}
 
-	data, err := CanonicalJSON(tombstone)
-	if err != nil {
-		return fmt.Errorf("serialize tombstone: %w", err)
-	}
-
-	c, err := r.Store.Put(data)
-	if err != nil {
-		return fmt.Errorf("store tombstone: %w", err)
-	}
-
-	if err := r.Refs.Set(id, c); err != nil {
-		return fmt.Errorf("update ref: %w", err)
+	if err := r.putNode(id, tombstone); err != nil {
+		return err
 	}
 
-	r.Search.RemoveNode(id)
+	r.unindexNode(id)
 	r.commit("delete " + id)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeDeleted, ID: id, Node: tombstone})
 	return nil
 }
 
 // UpdateContent replaces a node's content, creating a new version.
 func (r *Repository) UpdateContent(id string, content []byte) (*NodeEnvelope, error) {
+	return r.UpdateContentIfMatch(id, "", content)
+}
+
+// UpdateContentIfMatch is UpdateContent with compare-and-swap, as in
+// UpdateNodeIfMatch.
+func (r *Repository) UpdateContentIfMatch(id, ifMatch string, content []byte) (*NodeEnvelope, error) {
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	if err := r.checkMatch(id, ifMatch); err != nil {
+		return nil, err
+	}
+	return r.updateContent(id, content, nil)
+}
+
+// updateContent is UpdateContent for callers already holding id's lock.
+// metaUpdates, if any, are merged into the meta of the same version as in
+// updateMeta.
+func (r *Repository) updateContent(id string, content []byte, metaUpdates map[string]interface{}) (*NodeEnvelope, error) {
+	if err := r.EditLocks.Check(id); err != nil {
+		return nil, err
+	}
 	current, err := r.getNodeEnvelope(id)
 	if err != nil {
 		return nil, err
@@ -346,6 +688,23 @@ func (r *Repository) UpdateContent(id string, content []byte) (*NodeEnvelope, er
 
 	prevCID, _ := r.Refs.Get(id)
 
+	if derived := notebookMeta(content, current.Meta); derived != nil {
+		for k, v := range metaUpdates {
+			derived[k] = v
+		}
+		metaUpdates = derived
+	}
+	if len(metaUpdates) > 0 && current.Meta == nil {
+		current.Meta = make(map[string]interface{})
+	}
+	for k, v := range metaUpdates {
+		if v == nil {
+			delete(current.Meta, k)
+		} else {
+			current.Meta[k] = v
+		}
+	}
+
 	now := time.Now().UTC()
 	node := &NodeEnvelope{
 		V:        1,
@@ -356,34 +715,59 @@ func (r *Repository) UpdateContent(id string, content []byte) (*NodeEnvelope, er
 		Created:  current.Created,
 		Modified: now,
 		Prev:     CIDToFilename(prevCID),
+		Author:   r.author(),
 	}
-
-	data, err := CanonicalJSON(node)
-	if err != nil {
-		return nil, fmt.Errorf("serialize node: %w", err)
-	}
-
-	c, err := r.Store.Put(data)
-	if err != nil {
-		return nil, fmt.Errorf("store object: %w", err)
+	if err := r.Hooks.preWrite(HookUpdate, node); err != nil {
+		return nil, err
 	}
 
-	if err := r.Refs.Set(id, c); err != nil {
-		return nil, fmt.Errorf("update ref: %w", err)
+	if err := r.putNode(id, node); err != nil {
+		return nil, err
 	}
-
-	r.Search.RemoveNode(id)
-	r.Search.IndexNode(id, node)
-	r.commit("update content " + id)
+	msg := "update content " + id
+	pruned, err := r.pruneHistory(id, node)
+	if err != nil {
+		fmt.Printf("memex-fs: retention %s: %v\n", id, err)
+	}
+	switch {
+	case pruned == 1:
+		msg += " (pruned 1 old version)"
+	case pruned > 1:
+		msg += fmt.Sprintf(" (pruned %d old versions)", pruned)
+	}
+
+	r.unindexNode(id)
+	r.indexNode(id, node)
+	r.commit(msg)
+	r.Hooks.postWrite(HookUpdate, node)
+	r.Webhooks.Notify(WebhookEvent{Event: EventNodeUpdated, ID: id, Node: node})
+	r.Summarizer.onWrite(node)
+	r.Recognizer.onWrite(node)
+	r.Transcriber.onWrite(node)
 	return node, nil
 }
 
 // CreateLink creates a link between two nodes.
 func (r *Repository) CreateLink(source, target, linkType string) error {
-	if err := r.Links.Add(LinkEntry{Source: source, Target: target, Type: linkType}); err != nil {
+	link := LinkEntry{Source: source, Target: target, Type: linkType}
+	if err := r.Links.Add(link); err != nil {
 		return err
 	}
 	r.commit(fmt.Sprintf("link %s -[%s]-> %s", source, linkType, target))
+	r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &link})
+	return nil
+}
+
+// RemoveLink deletes a link between two nodes.
+func (r *Repository) RemoveLink(source, target, linkType string) error {
+	removed, err := r.Links.Remove(LinkEntry{Source: source, Target: target, Type: linkType})
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("link %w: %s -[%s]-> %s", ErrNotFound, source, linkType, target)
+	}
+	r.commit(fmt.Sprintf("unlink %s -[%s]-> %s", source, linkType, target))
 	return nil
 }
 
@@ -393,22 +777,36 @@ func (r *Repository) GetLinks(id string) []LinkEntry {
 }
 
 // Ingest content-addresses raw content and creates a Source node.
+// IngestReader and IngestURL take content from a reader or the web, with
+// its format sniffed and extra meta.
 func (r *Repository) Ingest(content string, format string) (string, bool, error) {
+	return r.ingest(content, format, nil)
+}
+
+// ingest is Ingest with extra meta merged into a newly created Source node.
+// extra is ignored when the content already exists: the first writer's meta
+// wins, since the node is shared by every caller that ingests the same bytes.
+func (r *Repository) ingest(content string, format string, extra map[string]interface{}) (string, bool, error) {
 	hash := sha256.Sum256([]byte(content))
 	hexHash := hex.EncodeToString(hash[:])
 	id := "sha256:" + hexHash
 
+	unlock := r.lockNode(id)
+	defer unlock()
+
 	// Check for dedup
 	if r.Refs.Has(id) {
 		return id, false, nil // already exists
 	}
 
-	meta := map[string]interface{}{
-		"format":     format,
-		"size_bytes": len(content),
+	meta := make(map[string]interface{}, len(extra)+2)
+	for k, v := range extra {
+		meta[k] = v
 	}
+	meta["format"] = format
+	meta["size_bytes"] = len(content)
 
-	_, err := r.CreateNode(id, "Source", []byte(content), meta)
+	_, err := r.createNode(id, "Source", []byte(content), meta)
 	if err != nil {
 		return "", false, err
 	}
@@ -416,25 +814,52 @@ func (r *Repository) Ingest(content string, format string) (string, bool, error)
 	return id, true, nil
 }
 
-// SearchNodes searches the index and returns full nodes.
-func (r *Repository) SearchNodes(query string, limit int) ([]*NodeEnvelope, error) {
-	ids := r.Search.Search(query, limit)
+// SearchNodes searches the index and returns full nodes. "@name" query
+// aliases are expanded first (see ExpandQuery).
+func (r *Repository) SearchNodes(ctx context.Context, query string, limit int) ([]*NodeEnvelope, error) {
+	query, err := r.ExpandQuery(query)
+	if err != nil {
+		return nil, err
+	}
 	var nodes []*NodeEnvelope
-	for _, id := range ids {
-		node, err := r.GetNode(id)
-		if err != nil {
-			continue
-		}
+	for node := range r.SearchIter(ctx, query, limit) {
 		nodes = append(nodes, node)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
 
+// SearchIter yields up to limit matching nodes in rank order, loading each
+// envelope only when the consumer asks for it. Ranking is done over IDs
+// alone, so the first result is available without reading every hit.
+// Unreadable nodes are skipped; iteration ends once ctx is cancelled.
+func (r *Repository) SearchIter(ctx context.Context, query string, limit int) iter.Seq[*NodeEnvelope] {
+	return func(yield func(*NodeEnvelope) bool) {
+		for _, id := range r.Search.Search(query, limit) {
+			if ctx.Err() != nil {
+				return
+			}
+			node, err := r.GetNode(id)
+			if err != nil {
+				continue
+			}
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
 // FilterNodes returns nodes matching a type filter.
-func (r *Repository) FilterNodes(typ string, limit int) ([]*NodeEnvelope, error) {
+func (r *Repository) FilterNodes(ctx context.Context, typ string, limit int) ([]*NodeEnvelope, error) {
 	ids := r.Search.FilterByType(typ, limit)
 	var nodes []*NodeEnvelope
 	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		node, err := r.GetNode(id)
 		if err != nil {
 			continue
@@ -445,17 +870,20 @@ func (r *Repository) FilterNodes(typ string, limit int) ([]*NodeEnvelope, error)
 }
 
 // Traverse does a BFS from a start node to the given depth.
-func (r *Repository) Traverse(startID string, depth int) ([]*NodeEnvelope, error) {
+func (r *Repository) Traverse(ctx context.Context, startID string, depth int) ([]*NodeEnvelope, error) {
 	visited := make(map[string]bool)
 	queue := []string{startID}
 	visited[startID] = true
 
 	for d := 0; d < depth && len(queue) > 0; d++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var next []string
 		for _, id := range queue {
 			links := r.Links.AllLinks(id)
 			for _, l := range links {
-				neighbor := l.Target
+				neighbor := LinkTargetParent(l.Target)
 				if neighbor == id {
 					neighbor = l.Source
 				}
@@ -470,6 +898,9 @@ func (r *Repository) Traverse(startID string, depth int) ([]*NodeEnvelope, error
 
 	var nodes []*NodeEnvelope
 	for id := range visited {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		node, err := r.GetNode(id)
 		if err != nil {
 			continue
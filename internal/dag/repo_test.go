@@ -1,6 +1,12 @@
 package dag
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -64,6 +70,35 @@ func TestUpdateContent(t *testing.T) {
 	}
 }
 
+func TestNodeAuthor_StampedOnWrites(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Commits.author = testDID
+	repo.CreateNode("note:a", "Note", []byte("mine"), nil)
+	if n, _ := repo.GetNode("note:a"); n.Author != testDID {
+		t.Errorf("created author = %q", n.Author)
+	}
+
+	// A version written elsewhere keeps its author until it is edited here.
+	other := openTestRepo(t)
+	other.Commits.author = "did:key:z6MkOther"
+	other.identity = testIdentity(t)
+	other.CreateNode("note:b", "Note", []byte("theirs"), nil)
+	b, err := other.ExportBundle("note:b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ImportBundle(b); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.GetNode("note:b"); n.Author != "did:key:z6MkOther" {
+		t.Errorf("imported author = %q", n.Author)
+	}
+	repo.UpdateNode("note:b", map[string]interface{}{"read": true})
+	if n, _ := repo.GetNode("note:b"); n.Author != testDID {
+		t.Errorf("author after local edit = %q", n.Author)
+	}
+}
+
 func TestUpdateNode_MetaMerge(t *testing.T) {
 	repo := openTestRepo(t)
 
@@ -160,7 +195,7 @@ func TestSearchNodes(t *testing.T) {
 	repo.CreateNode("sr-1", "Note", []byte("the quick brown fox"), nil)
 	repo.CreateNode("sr-2", "Note", []byte("lazy dog sleeps"), nil)
 
-	results, err := repo.SearchNodes("quick fox", 10)
+	results, err := repo.SearchNodes(context.Background(), "quick fox", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -172,6 +207,61 @@ func TestSearchNodes(t *testing.T) {
 	}
 }
 
+func TestSearchIter_RankOrderAndEarlyStop(t *testing.T) {
+	repo := openTestRepo(t)
+
+	repo.CreateNode("si-b", "Note", []byte("fox"), nil)
+	repo.CreateNode("si-a", "Note", []byte("fox"), nil)
+	repo.CreateNode("si-top", "Note", []byte("quick fox"), nil)
+
+	var got []string
+	for node := range repo.SearchIter(context.Background(), "quick fox", 0) {
+		got = append(got, node.ID)
+	}
+	want := []string{"si-top", "si-a", "si-b"} // ties broken by ID
+	if len(got) != len(want) {
+		t.Fatalf("SearchIter = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SearchIter = %v, want %v", got, want)
+		}
+	}
+
+	n := 0
+	for range repo.SearchIter(context.Background(), "fox", 0) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("iteration did not stop after break: %d", n)
+	}
+}
+
+func TestScans_StopOnCancelledContext(t *testing.T) {
+	repo := openTestRepo(t)
+	for _, id := range []string{"note:a", "note:b"} {
+		repo.CreateNode(id, "Note", []byte("fox"), nil)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.ListNodes(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListNodes: err = %v, want context.Canceled", err)
+	}
+	if _, err := repo.SearchNodes(ctx, "fox", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchNodes: err = %v, want context.Canceled", err)
+	}
+	head, _ := repo.Commits.Head()
+	n, err := repo.RetypeAll(ctx, "Note", "Idea")
+	if n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("RetypeAll = %d, %v; want 0, context.Canceled", n, err)
+	}
+	if after, _ := repo.Commits.Head(); after != head {
+		t.Error("cancelled RetypeAll committed")
+	}
+}
+
 func TestFilterNodes(t *testing.T) {
 	repo := openTestRepo(t)
 
@@ -179,7 +269,7 @@ func TestFilterNodes(t *testing.T) {
 	repo.CreateNode("ft-2", "Task", []byte("b"), nil)
 	repo.CreateNode("ft-3", "Note", []byte("c"), nil)
 
-	notes, err := repo.FilterNodes("Note", 0)
+	notes, err := repo.FilterNodes(context.Background(), "Note", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -187,7 +277,7 @@ func TestFilterNodes(t *testing.T) {
 		t.Fatalf("Note filter: got %d, want 2", len(notes))
 	}
 
-	tasks, err := repo.FilterNodes("Task", 0)
+	tasks, err := repo.FilterNodes(context.Background(), "Task", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -203,7 +293,7 @@ func TestListNodes(t *testing.T) {
 		repo.CreateNode("ls-"+string(rune('a'+i)), "Note", []byte("x"), nil)
 	}
 
-	all, err := repo.ListNodes(0)
+	all, err := repo.ListNodes(context.Background(), 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -211,7 +301,7 @@ func TestListNodes(t *testing.T) {
 		t.Errorf("ListNodes(0): got %d, want 5", len(all))
 	}
 
-	limited, err := repo.ListNodes(3)
+	limited, err := repo.ListNodes(context.Background(), 3)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -264,3 +354,86 @@ func TestUpdateContent_DeletedNode(t *testing.T) {
 		t.Error("expected error updating deleted node")
 	}
 }
+
+func TestAs_AttributesWrites(t *testing.T) {
+	repo := openTestRepo(t)
+	alice := newDID(t)
+	repo.CreateNode("note:mine", "Note", nil, nil)
+	node, err := repo.As(alice).CreateNode("note:hers", "Note", []byte("hi"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Author != alice {
+		t.Errorf("node author = %q, want %q", node.Author, alice)
+	}
+	byAlice, _ := repo.Commits.HistoryBy(alice, 10)
+	if len(byAlice) != 1 || byAlice[0].Author != alice {
+		t.Errorf("HistoryBy(alice) = %+v", byAlice)
+	}
+	authors, _ := repo.Commits.Authors()
+	if len(authors) != 2 || !slices.Contains(authors, alice) {
+		t.Errorf("Authors() = %v", authors)
+	}
+
+	cfg := &Config{Users: map[string]string{"1001": alice}}
+	if cfg.UserDID(1001) != alice || cfg.UserDID(1002) != "" {
+		t.Errorf("UserDID: %q, %q", cfg.UserDID(1001), cfg.UserDID(1002))
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"users": {"1001": "not-a-did"}}`), 0644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig accepted a bad DID")
+	}
+}
+
+func TestOpenRepository_Format(t *testing.T) {
+	repo := openTestRepo(t)
+	metaPath := filepath.Join(repo.MxDir(), "meta.json")
+	if v, err := readRepoFormat(metaPath); err != nil || v != RepoFormat {
+		t.Fatalf("new repository format = %d, %v", v, err)
+	}
+	repo.CreateNode("note:a", "Note", []byte("hello"), nil)
+
+	// A v1 repository, with an object and a ref in the flat layouts.
+	legacy, _ := repo.Store.Put([]byte("legacy"))
+	objects := filepath.Join(repo.MxDir(), "objects")
+	name := CIDToFilename(legacy)
+	if err := os.Rename(repo.Store.path(name), filepath.Join(objects, name)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(repo.Refs.path("note:a"), filepath.Join(repo.MxDir(), "refs", "note__a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRepoFormat(metaPath, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := readRepoFormat(metaPath); v != RepoFormat {
+		t.Errorf("format after migration = %d", v)
+	}
+	if _, err := os.Stat(filepath.Join(objects, name)); !os.IsNotExist(err) {
+		t.Error("flat object not migrated")
+	}
+	if node, err := reopened.GetNode("note:a"); err != nil || string(node.Content) != "hello" {
+		t.Errorf("node after migration: %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(repo.root, fmt.Sprintf(".mx.before-v%d-*%s", RepoFormat, backupSuffix)))
+	if len(backups) != 1 {
+		t.Fatalf("pre-migration backups = %v", backups)
+	}
+	if check, err := VerifyBackup(t.Context(), backups[0]); err != nil || !check.OK() {
+		t.Errorf("pre-migration backup: %+v, %v", check, err)
+	}
+
+	if err := writeRepoFormat(metaPath, RepoFormat+1); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenRepository(repo.root)
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("opening a newer format: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RepoFormat is the version of the .mx layout this build reads and writes,
+// recorded as "version" in .mx/meta.json. Opening a repository of an
+// older version migrates it, after archiving it beside .mx; a newer one is
+// refused. A change to the layout that older builds would misread bumps it
+// and adds a step to formatMigrations.
+const RepoFormat = 3
+
+// formatMigration takes a repository from version to-1 to version to.
+// Steps must be safe to rerun: an interrupted one runs again on the next
+// open.
+type formatMigration struct {
+	to   int
+	what string
+	run  func(ctx context.Context, mx string) error
+}
+
+// formatMigrations are the steps from version 1, in order.
+var formatMigrations = []formatMigration{
+	{2, "shard the object store", func(ctx context.Context, mx string) error {
+		ov, err := loadOverlay(mx)
+		if err != nil {
+			return err
+		}
+		store, err := NewObjectStore(objectsDir(mx, ov))
+		if err != nil {
+			return err
+		}
+		_, err = store.Migrate(ctx)
+		return err
+	}},
+	{3, "shard refs and percent-encode their names", func(ctx context.Context, mx string) error {
+		refs, err := NewRefStore(filepath.Join(mx, "refs"))
+		if err != nil {
+			return err
+		}
+		return refs.migrate()
+	}},
+}
+
+// readRepoFormat returns the version recorded in the meta.json at path.
+// Repositories from before versioning record 1.
+func readRepoFormat(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("read repo meta: %w", err)
+	}
+	var meta struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0, fmt.Errorf("parse repo meta: %w", err)
+	}
+	return max(meta.Version, 1), nil
+}
+
+// writeRepoFormat records version in the meta.json at path, keeping the
+// rest of it.
+func writeRepoFormat(path string, version int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read repo meta: %w", err)
+	}
+	meta := map[string]interface{}{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("parse repo meta: %w", err)
+	}
+	meta["version"] = version
+	data, _ = json.MarshalIndent(meta, "", "  ")
+	return SafeWrite(path, data, 0644)
+}
+
+// upgradeRepo brings the repository in mx to RepoFormat. Before the first
+// step it archives mx as .mx.before-v{N}-{time}.tar.gz beside it, which
+// "memex-fs backup restore" can put back.
+func upgradeRepo(ctx context.Context, mx string) error {
+	metaPath := filepath.Join(mx, "meta.json")
+	version, err := readRepoFormat(metaPath)
+	if err != nil {
+		return err
+	}
+	if version > RepoFormat {
+		return errorOf(ErrValidation, "repository format v%d is newer than this memex-fs supports (v%d); upgrade memex-fs to open it", version, RepoFormat)
+	}
+	if version == RepoFormat {
+		return nil
+	}
+
+	backup := fmt.Sprintf("%s.before-v%d-%s%s", mx, RepoFormat, time.Now().UTC().Format("20060102T150405Z"), backupSuffix)
+	if err := writeBackup(ctx, mx, backup); err != nil {
+		os.Remove(backup)
+		return fmt.Errorf("back up before migrating: %w", err)
+	}
+	fmt.Printf("memex-fs: archived the v%d repository to %s before migrating\n", version, backup)
+	for _, step := range formatMigrations {
+		if step.to <= version {
+			continue
+		}
+		if err := step.run(ctx, mx); err != nil {
+			return fmt.Errorf("migrate to format v%d (%s): %w", step.to, step.what, err)
+		}
+		if err := writeRepoFormat(metaPath, step.to); err != nil {
+			return err
+		}
+		fmt.Printf("memex-fs: migrated repository to format v%d: %s\n", step.to, step.what)
+	}
+	return nil
+}
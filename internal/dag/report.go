@@ -0,0 +1,222 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Report nodes hold a text/template rendered over the repository, such as
+// a weekly review of new nodes and finished tasks. A Report whose meta
+// "schedule" is "daily" or "weekly" is rendered once a period into a
+// ReportOutput node, linked back to it by RENDERED_FROM.
+const (
+	ReportType       = "Report"
+	ReportOutputType = "ReportOutput"
+	LinkRenderedFrom = "RENDERED_FROM"
+)
+
+// maxReportList bounds the nodes one template function returns.
+const maxReportList = 200
+
+// ReportData is what a report template sees as ".".
+type ReportData struct {
+	Report *NodeEnvelope
+	Now    time.Time
+}
+
+// reportFuncs are the functions report templates call:
+//
+//	search "query"   nodes matching a search query, "@name" aliases allowed
+//	type "Task"      nodes of a type, by ID
+//	tasks "done"     tasks in a status, by ID
+//	created "7d"     nodes created within a window, newest first
+//	modified "7d"    nodes modified within a window, newest first
+//	node "id"        one node, or nil
+//	title .          a node's title meta, or its ID
+//	text .           a node's content as text
+//	ago "7d"         the time a window before now
+//
+// Windows are Go durations ("36h") or whole days or weeks ("7d", "2w").
+func (r *Repository) reportFuncs(ctx context.Context, now time.Time) template.FuncMap {
+	nodes := func(ids []string) []*NodeEnvelope {
+		out := []*NodeEnvelope{}
+		for _, id := range ids {
+			if ctx.Err() != nil || len(out) == maxReportList {
+				break
+			}
+			if node, err := r.GetNode(id); err == nil {
+				out = append(out, node)
+			}
+		}
+		return out
+	}
+	within := func(window string, at func(*NodeEnvelope) time.Time) ([]*NodeEnvelope, error) {
+		d, err := parseWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := r.ListNodes(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		var out []*NodeEnvelope
+		for _, id := range ids {
+			if node, err := r.GetNode(id); err == nil && !at(node).Before(now.Add(-d)) {
+				out = append(out, node)
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return at(out[i]).After(at(out[j])) })
+		if len(out) > maxReportList {
+			out = out[:maxReportList]
+		}
+		return out, ctx.Err()
+	}
+	return template.FuncMap{
+		"search": func(query string) ([]*NodeEnvelope, error) {
+			expanded, err := r.ExpandQuery(query)
+			if err != nil {
+				return nil, err
+			}
+			return nodes(r.Search.Search(expanded, maxReportList)), nil
+		},
+		"type":  func(typ string) []*NodeEnvelope { return nodes(r.Search.FilterByType(typ, 0)) },
+		"tasks": func(status string) []*NodeEnvelope { return nodes(r.TasksByStatus(status)) },
+		"created": func(window string) ([]*NodeEnvelope, error) {
+			return within(window, func(n *NodeEnvelope) time.Time { return n.Created })
+		},
+		"modified": func(window string) ([]*NodeEnvelope, error) {
+			return within(window, func(n *NodeEnvelope) time.Time { return n.Modified })
+		},
+		"node": func(id string) *NodeEnvelope {
+			node, _ := r.GetNode(id)
+			return node
+		},
+		"title": func(node *NodeEnvelope) string {
+			if title, _ := node.Meta["title"].(string); title != "" {
+				return title
+			}
+			return node.ID
+		},
+		"text": func(node *NodeEnvelope) string { return string(node.Content) },
+		"ago": func(window string) (time.Time, error) {
+			d, err := parseWindow(window)
+			return now.Add(-d), err
+		},
+	}
+}
+
+// parseWindow reads a report window: a Go duration, or a whole number of
+// days ("7d") or weeks ("2w").
+func parseWindow(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, errorOf(ErrValidation, "bad window %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errorOf(ErrValidation, "bad window %q", s)
+	}
+	return d, nil
+}
+
+// RenderReport renders Report node id's template as of now.
+func (r *Repository) RenderReport(ctx context.Context, id string, now time.Time) ([]byte, error) {
+	report, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if report.Type != ReportType {
+		return nil, errorOf(ErrValidation, "%s is a %s, not a %s", id, report.Type, ReportType)
+	}
+	tmpl, err := template.New(id).Funcs(r.reportFuncs(ctx, now)).Parse(string(report.Content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, ReportData{Report: report, Now: now}); err != nil {
+		return nil, fmt.Errorf("render %s: %w", id, err)
+	}
+	return out.Bytes(), nil
+}
+
+// ReportOutputID returns the ID of report id's output for the period
+// starting on day.
+func ReportOutputID(id string, day time.Time) string {
+	return id + ":" + day.Format("2006-01-02")
+}
+
+// reportPeriod returns the first day of the period schedule puts now in:
+// today for "daily", and this week's Monday for "weekly".
+func reportPeriod(schedule string, now time.Time) (time.Time, bool) {
+	now = now.In(time.Local)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch schedule {
+	case "daily":
+		return today, true
+	case "weekly":
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7), true
+	}
+	return time.Time{}, false
+}
+
+// SnapshotReport renders report id as of now into a ReportOutput node
+// for the period starting on day, replacing the content of one already
+// made for that period.
+func (r *Repository) SnapshotReport(ctx context.Context, id string, day, now time.Time) (*NodeEnvelope, error) {
+	content, err := r.RenderReport(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+	outID := ReportOutputID(id, day)
+	if r.Refs.Has(outID) {
+		return r.UpdateContent(outID, content)
+	}
+	meta := map[string]interface{}{"report": id, "date": day.Format("2006-01-02")}
+	node, err := r.CreateNode(outID, ReportOutputType, content, meta)
+	if err != nil {
+		return nil, err
+	}
+	return node, r.CreateLink(outID, id, LinkRenderedFrom)
+}
+
+// SnapshotReports snapshots every scheduled Report whose current period
+// has no output yet. A report that fails to render does not hold up the
+// rest; the first failure is returned. Returns the IDs of the outputs made.
+func (r *Repository) SnapshotReports(ctx context.Context, now time.Time) ([]string, error) {
+	var made []string
+	var firstErr error
+	for _, id := range r.Search.FilterByType(ReportType, 0) {
+		if ctx.Err() != nil {
+			return made, ctx.Err()
+		}
+		report, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		schedule, _ := report.Meta["schedule"].(string)
+		day, ok := reportPeriod(schedule, now)
+		if !ok || r.Refs.Has(ReportOutputID(id, day)) {
+			continue
+		}
+		out, err := r.SnapshotReport(ctx, id, day, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("snapshot %s: %w", id, err)
+			}
+			continue
+		}
+		made = append(made, out.ID)
+	}
+	return made, firstErr
+}
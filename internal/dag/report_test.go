@@ -0,0 +1,74 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const weeklyReview = `Week of {{.Now.Format "2006-01-02"}}
+New:{{range created "7d"}}{{if ne .Type "Report"}} {{title .}}{{end}}{{end}}
+Done:{{range tasks "done"}} {{.ID}}{{end}}
+`
+
+func TestRenderReport(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	now := time.Now()
+	repo.CreateNode("note:a", "Note", nil, map[string]interface{}{"title": "Airships"})
+	repo.CreateNode("task:ship", "Task", nil, map[string]interface{}{"status": "done"})
+	repo.CreateNode("task:later", "Task", nil, nil)
+	repo.CreateNode("report:weekly", ReportType, []byte(weeklyReview), nil)
+
+	out, err := repo.RenderReport(ctx, "report:weekly", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Week of " + now.Format("2006-01-02") + "\nNew: task:later task:ship Airships\nDone: task:ship\n"
+	if string(out) != want {
+		t.Errorf("rendered %q, want %q", out, want)
+	}
+
+	repo.CreateNode("report:bad", ReportType, []byte(`{{range created "soon"}}{{end}}`), nil)
+	if _, err := repo.RenderReport(ctx, "report:bad", now); !errors.Is(err, ErrValidation) {
+		t.Errorf("bad window: %v", err)
+	}
+	if _, err := repo.RenderReport(ctx, "note:a", now); !errors.Is(err, ErrValidation) {
+		t.Errorf("rendering a Note: %v", err)
+	}
+}
+
+func TestSnapshotReports(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	repo.CreateNode("report:daily", ReportType, []byte("{{len (type \"Note\")}} notes"), map[string]interface{}{"schedule": "daily"})
+	repo.CreateNode("report:adhoc", ReportType, []byte("unscheduled"), nil)
+	repo.CreateNode("note:a", "Note", nil, nil)
+
+	// A Wednesday: a weekly report's period starts on the Monday before.
+	wed := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+	if day, _ := reportPeriod("weekly", wed); day.Format("2006-01-02") != "2026-10-12" {
+		t.Errorf("weekly period of %s starts %s", wed, day)
+	}
+
+	made, err := repo.SnapshotReports(ctx, wed)
+	if err != nil || len(made) != 1 || made[0] != "report:daily:2026-10-14" {
+		t.Fatalf("SnapshotReports = %v, %v", made, err)
+	}
+	node, _ := repo.GetNode(made[0])
+	if node.Type != ReportOutputType || string(node.Content) != "1 notes" {
+		t.Errorf("output = %+v", node)
+	}
+	if links := repo.Links.LinksTo("report:daily"); len(links) != 1 || links[0].Type != LinkRenderedFrom {
+		t.Errorf("links to report = %+v", links)
+	}
+
+	repo.CreateNode("note:b", "Note", nil, nil)
+	if made, err := repo.SnapshotReports(ctx, wed.Add(time.Hour)); err != nil || len(made) != 0 {
+		t.Errorf("same day again: %v, %v", made, err)
+	}
+	if made, _ := repo.SnapshotReports(ctx, wed.AddDate(0, 0, 1)); len(made) != 1 {
+		t.Errorf("next day: %v", made)
+	}
+}
@@ -0,0 +1,390 @@
+package dag
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupCheck is what verifying a backup archive found. The archive can
+// be trusted when OK.
+type BackupCheck struct {
+	Objects int    `json:"objects"` // objects hash-checked
+	Commits int    `json:"commits"` // commits reachable from HEAD
+	Refs    int    `json:"refs"`    // nodes in the working refs
+	Head    string `json:"head,omitempty"`
+	// Corrupt are objects whose bytes do not hash to their CID.
+	Corrupt []string `json:"corrupt,omitempty"`
+	// Missing are commits, and node versions HEAD or the working refs
+	// name, that the archive lacks or cannot decode.
+	Missing []string `json:"missing,omitempty"`
+	// Chain is why the commit chain failed append-only verification.
+	Chain string `json:"chain,omitempty"`
+}
+
+// OK reports whether the check found nothing wrong.
+func (c *BackupCheck) OK() bool {
+	return len(c.Corrupt) == 0 && len(c.Missing) == 0 && c.Chain == ""
+}
+
+// VerifyBackup unpacks the archive Backup wrote at path into a temporary
+// directory and checks it: every object's bytes must hash to its CID,
+// every commit reachable from HEAD must be present (down to the boundary
+// of a shallow repository), HEAD's and the working refs' node versions
+// must decode, and an append-only repository's chain must verify.
+func VerifyBackup(ctx context.Context, path string) (*BackupCheck, error) {
+	dir, err := os.MkdirTemp("", "memex-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := extractBackup(ctx, path, dir); err != nil {
+		return nil, err
+	}
+	return checkMx(ctx, filepath.Join(dir, ".mx"))
+}
+
+// extractBackup unpacks the archive at path into dir. Every entry must
+// lie under .mx/; anything else means the file is not one of ours.
+func extractBackup(ctx context.Context, path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrValidation, path, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrValidation, path, err)
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if !filepath.IsLocal(name) || (name != ".mx" && !strings.HasPrefix(name, ".mx"+string(filepath.Separator))) {
+			return errorOf(ErrValidation, "%s: unexpected entry %q", path, hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm()|0200)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("extract %s: %w", hdr.Name, err)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".mx")); err != nil {
+		return errorOf(ErrValidation, "%s: no .mx directory in archive", path)
+	}
+	return nil
+}
+
+// checkMx verifies the repository directory mx without opening it as a
+// Repository, which would rebuild indexes it does not need.
+func checkMx(ctx context.Context, mx string) (*BackupCheck, error) {
+	check := &BackupCheck{}
+	objDir := filepath.Join(mx, "objects")
+	err := filepath.WalkDir(objDir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		check.Objects++
+		c, err := cidFromFilename(d.Name())
+		if err != nil {
+			check.Corrupt = append(check.Corrupt, d.Name())
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !CIDMatches(c, data) {
+			check.Corrupt = append(check.Corrupt, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewObjectStore(objDir)
+	if err != nil {
+		return nil, err
+	}
+	commits := NewCommitLog(filepath.Join(mx, "HEAD"), store, "")
+	shallow, err := loadShallow(shallowPath(mx))
+	if err != nil {
+		return nil, err
+	}
+	if shallow.info != nil {
+		commits.setBoundary(shallow.info.Boundary)
+	}
+	head, err := commits.Head()
+	if err != nil {
+		return nil, err
+	}
+	missing := map[string]bool{}
+	if head.Defined() {
+		check.Head = CIDToFilename(head)
+	}
+
+	// Walk history, both parents of merges included.
+	var headCommit *CommitObject
+	seen := map[string]bool{}
+	for stack := []string{check.Head}; len(stack) > 0; {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		c, err := cidFromFilename(name)
+		if err != nil {
+			missing["commit "+name] = true
+			continue
+		}
+		commit, err := store.GetCommit(c)
+		if err != nil {
+			missing["commit "+name] = true
+			continue
+		}
+		check.Commits++
+		if headCommit == nil {
+			headCommit = commit
+		}
+		if !commits.boundary[name] {
+			stack = append(stack, commit.Parent, commit.Merged)
+		}
+	}
+
+	// Node versions a restore would serve. A sparse pull leaves some out
+	// on purpose.
+	sparse := shallow.info != nil && !shallow.info.Sparse.Empty()
+	nodeOK := func(id, cidStr string) {
+		c, err := cidFromFilename(cidStr)
+		if err == nil {
+			if sparse && !store.Has(c) {
+				return
+			}
+			_, err = store.GetNode(c)
+		}
+		if err != nil {
+			missing["node "+id+" "+cidStr] = true
+		}
+	}
+	if headCommit != nil {
+		for id, c := range headCommit.Refs {
+			nodeOK(id, c)
+		}
+	}
+	refs, err := archiveRefs(mx)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := refs.List()
+	if err != nil {
+		return nil, err
+	}
+	check.Refs = len(ids)
+	for _, id := range ids {
+		c, err := refs.Get(id)
+		if err != nil {
+			missing["ref "+id] = true
+			continue
+		}
+		nodeOK(id, CIDToFilename(c))
+	}
+	for m := range missing {
+		check.Missing = append(check.Missing, m)
+	}
+	sort.Strings(check.Missing)
+	sort.Strings(check.Corrupt)
+
+	if data, err := os.ReadFile(filepath.Join(mx, appendOnlyAnchorFile)); err == nil {
+		if _, err := commits.VerifyChain(strings.TrimSpace(string(data))); err != nil {
+			check.Chain = err.Error()
+		}
+	}
+	return check, ctx.Err()
+}
+
+// archiveRefs opens the refs of the extracted archive mx, moving them
+// into the current layout if the archive is older than format v3.
+func archiveRefs(mx string) (*RefStore, error) {
+	refs, err := NewRefStore(filepath.Join(mx, "refs"))
+	if err != nil {
+		return nil, err
+	}
+	if version, err := readRepoFormat(filepath.Join(mx, "meta.json")); err == nil && version < 3 {
+		return refs, refs.migrate()
+	}
+	return refs, nil
+}
+
+// RestorePlan is what restoring a backup over a repository would change.
+type RestorePlan struct {
+	Check *BackupCheck `json:"check"`
+	// HeadFrom and HeadTo are the repository's HEAD now and after.
+	HeadFrom string `json:"head_from,omitempty"`
+	HeadTo   string `json:"head_to,omitempty"`
+	// Nodes are the working refs that would be created, changed or
+	// deleted, by ID.
+	Nodes        []RefChange `json:"nodes,omitempty"`
+	LinksAdded   int         `json:"links_added"`
+	LinksRemoved int         `json:"links_removed"`
+}
+
+// PlanRestore reports, without changing anything, what RestoreBackup of
+// the archive at path would do to r: the archive's check, and the nodes
+// and links that would differ.
+func (r *Repository) PlanRestore(ctx context.Context, path string) (*RestorePlan, error) {
+	dir, err := os.MkdirTemp("", "memex-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := extractBackup(ctx, path, dir); err != nil {
+		return nil, err
+	}
+	mx := filepath.Join(dir, ".mx")
+	check, err := checkMx(ctx, mx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &RestorePlan{Check: check, HeadTo: check.Head, HeadFrom: r.headKey()}
+
+	current, err := refMap(r.Refs)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := archiveRefs(mx)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := refMap(refs)
+	if err != nil {
+		return nil, err
+	}
+	plan.Nodes = refChanges(current, restored)
+
+	links, err := NewLinkIndex(filepath.Join(mx, "links.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	had := make(map[LinkEntry]bool)
+	for _, l := range r.Links.AllEntries() {
+		had[l] = true
+	}
+	for _, l := range links.AllEntries() {
+		if had[l] {
+			delete(had, l)
+		} else {
+			plan.LinksAdded++
+		}
+	}
+	plan.LinksRemoved = len(had)
+	return plan, nil
+}
+
+// refMap returns every ref in refs, ID to base32 CID.
+func refMap(refs *RefStore) (map[string]string, error) {
+	ids, err := refs.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(ids))
+	for _, id := range ids {
+		c, err := refs.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = CIDToFilename(c)
+	}
+	return out, nil
+}
+
+// RestoreBackup replaces the .mx directory under root with the one in the
+// archive at path, after checking the archive as VerifyBackup does; an
+// archive that fails the check is refused. The replaced directory is kept
+// beside it, and its path returned ("" if root had none). Nothing may
+// have the repository open: unmount it first.
+func RestoreBackup(ctx context.Context, path, root string) (string, error) {
+	tmp, err := os.MkdirTemp(root, ".mx-restore-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractBackup(ctx, path, tmp); err != nil {
+		return "", err
+	}
+	check, err := checkMx(ctx, filepath.Join(tmp, ".mx"))
+	if err != nil {
+		return "", err
+	}
+	if !check.OK() {
+		return "", errorOf(ErrValidation, "%s failed verification: %d corrupt, %d missing objects%s",
+			path, len(check.Corrupt), len(check.Missing), chainSuffix(check.Chain))
+	}
+
+	mx := filepath.Join(root, ".mx")
+	saved := ""
+	if _, err := os.Stat(mx); err == nil {
+		saved = mx + ".before-restore-" + time.Now().UTC().Format("20060102T150405Z")
+		if err := os.Rename(mx, saved); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(filepath.Join(tmp, ".mx"), mx); err != nil {
+		if saved != "" {
+			os.Rename(saved, mx)
+		}
+		return "", err
+	}
+	return saved, nil
+}
+
+func chainSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ", chain: " + reason
+}
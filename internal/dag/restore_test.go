@@ -0,0 +1,148 @@
+package dag
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// corruptBackup copies the archive at path with one byte of its first
+// object flipped.
+func corruptBackup(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	outGz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(outGz)
+	tr := tar.NewReader(gz)
+	flipped := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		if !flipped && hdr.Typeflag == tar.TypeReg && strings.HasPrefix(hdr.Name, ".mx/objects/") {
+			data[0] ^= 0xff
+			flipped = true
+		}
+		tw.WriteHeader(hdr)
+		tw.Write(data)
+	}
+	tw.Close()
+	outGz.Close()
+	bad := filepath.Join(t.TempDir(), "bad.tar.gz")
+	if err := os.WriteFile(bad, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return bad
+}
+
+func TestVerifyBackup(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	repo.CreateNode("note:a", "Note", []byte("alpha"), nil)
+	repo.CreateNode("note:b", "Note", []byte("beta"), nil)
+	repo.CreateLink("note:a", "note:b", "RELATED")
+	repo.Config.Backup = &BackupConfig{Dir: t.TempDir()}
+	path, err := repo.Backup(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := VerifyBackup(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.OK() || check.Refs != 2 || check.Commits == 0 || check.Head != repo.headKey() {
+		t.Errorf("check = %+v", check)
+	}
+
+	check, err = VerifyBackup(ctx, corruptBackup(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.OK() || len(check.Corrupt) != 1 {
+		t.Errorf("corrupt archive check = %+v", check)
+	}
+
+	notArchive := filepath.Join(t.TempDir(), "x.tar.gz")
+	os.WriteFile(notArchive, []byte("hello"), 0644)
+	if _, err := VerifyBackup(ctx, notArchive); !errors.Is(err, ErrValidation) {
+		t.Errorf("not an archive: %v", err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	repo.CreateNode("note:a", "Note", []byte("alpha"), nil)
+	repo.CreateNode("note:b", "Note", []byte("beta"), nil)
+	repo.Config.Backup = &BackupConfig{Dir: t.TempDir()}
+	path, err := repo.Backup(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.UpdateContent("note:a", []byte("alpha, revised"))
+	repo.CreateNode("note:c", "Note", nil, nil)
+	repo.CreateLink("note:a", "note:c", "RELATED")
+	plan, err := repo.PlanRestore(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range plan.Nodes {
+		got = append(got, c.Action()+" "+c.ID)
+	}
+	if strings.Join(got, ", ") != "changed note:a, deleted note:c" || plan.LinksRemoved != 1 || plan.LinksAdded != 0 {
+		t.Errorf("plan = %v, +%d -%d links", got, plan.LinksAdded, plan.LinksRemoved)
+	}
+	if plan.HeadFrom == plan.HeadTo {
+		t.Errorf("plan HEAD unchanged: %s", plan.HeadFrom)
+	}
+	if node, _ := repo.GetNode("note:a"); string(node.Content) != "alpha, revised" {
+		t.Error("dry run changed the repository")
+	}
+
+	root := filepath.Dir(repo.MxDir())
+	if _, err := RestoreBackup(ctx, corruptBackup(t, path), root); !errors.Is(err, ErrValidation) {
+		t.Errorf("restoring a corrupt archive: %v", err)
+	}
+	saved, err := RestoreBackup(ctx, path, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(saved, "HEAD")); err != nil {
+		t.Errorf("previous .mx not kept: %v", err)
+	}
+	restored, err := OpenRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, err := restored.GetNode("note:a"); err != nil || string(node.Content) != "alpha" {
+		t.Errorf("restored note:a = %v, %v", node, err)
+	}
+	if restored.Refs.Has("note:c") {
+		t.Error("note:c survived the restore")
+	}
+}
@@ -0,0 +1,109 @@
+package dag
+
+import "fmt"
+
+// pruneHistory applies the retention policy for head's type to id's
+// version chain, where head is the version id's ref now points at. Versions
+// past the limit are deleted from the object store.
+//
+// A version's CID covers its Prev pointer, so cutting the chain means
+// rewriting every version that is kept: the oldest kept one loses its Prev,
+// and each newer one is re-stored pointing at its rewritten predecessor.
+// The originals are deleted with the pruned versions, leaving a chain with
+// no dangling pointers for push, pull and audits to walk. Commits that
+// named a pruned version no longer show the node in /at/.
+//
+// A version a protected commit names (see Protect) is never rewritten, so
+// it and the chain behind it stay whole, and only the versions between it
+// and the kept ones are dropped.
+//
+// Append-only repositories keep every version whatever the policy says.
+//
+// Returns the number of versions dropped; head.Prev is updated in place.
+// The caller holds id's node lock.
+func (r *Repository) pruneHistory(id string, head *NodeEnvelope) (int, error) {
+	if r.Config.AppendOnly {
+		return 0, nil
+	}
+	keep, ok := r.Config.Retention[head.Type]
+	if !ok || keep < 0 {
+		return 0, nil
+	}
+
+	// Older versions, newest first.
+	var chain []*NodeEnvelope
+	var chainCIDs []string
+	for prev := head.Prev; prev != ""; {
+		v, err := r.envelopeAt(prev)
+		if err != nil {
+			break // already cut, or an object lost outside our control
+		}
+		chain = append(chain, v)
+		chainCIDs = append(chainCIDs, prev)
+		prev = v.Prev
+	}
+	if len(chain) <= keep {
+		return 0, nil
+	}
+	headCID, err := r.Refs.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	all := append([]*NodeEnvelope{head}, chain...)
+	allCIDs := append([]string{CIDToFilename(headCID)}, chainCIDs...)
+
+	// The newest protected version stays, with everything behind it, and
+	// only the versions between it and the kept ones are dropped.
+	protected, err := r.protectedVersions()
+	if err != nil {
+		return 0, err
+	}
+	cut := len(all)
+	for i, name := range allCIDs {
+		if protected[name] {
+			cut = i
+			break
+		}
+	}
+	if cut <= keep+1 {
+		return 0, nil
+	}
+	prev := ""
+	if cut < len(all) {
+		prev = allCIDs[cut]
+	}
+
+	// Re-store head and the kept versions oldest first.
+	kept := all[:keep+1]
+	written := make(map[string]bool, len(kept))
+	for i := len(kept) - 1; i >= 0; i-- {
+		v := *kept[i]
+		v.Prev = prev
+		c, err := r.Store.PutNode(&v)
+		if err != nil {
+			return 0, fmt.Errorf("store object: %w", err)
+		}
+		prev = CIDToFilename(c)
+		written[prev] = true
+		if i == 0 {
+			if err := r.Refs.Set(id, c); err != nil {
+				return 0, fmt.Errorf("set ref: %w", err)
+			}
+			head.Prev = v.Prev
+		}
+	}
+
+	for _, name := range allCIDs[:cut] {
+		if written[name] {
+			continue
+		}
+		c, err := cidFromFilename(name)
+		if err != nil {
+			continue
+		}
+		if err := r.Store.Delete(c); err != nil {
+			return 0, err
+		}
+	}
+	return cut - keep - 1, nil
+}
@@ -0,0 +1,76 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+// versions walks id's chain and returns each version's content, newest
+// first, failing on a dangling Prev.
+func versions(t *testing.T, repo *Repository, id string) []string {
+	t.Helper()
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := []string{string(node.Content)}
+	for prev := node.Prev; prev != ""; {
+		v, err := repo.envelopeAt(prev)
+		if err != nil {
+			t.Fatalf("dangling prev %s: %v", prev, err)
+		}
+		out = append(out, string(v.Content))
+		prev = v.Prev
+	}
+	return out
+}
+
+func TestRetention_KeepsLastN(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Config.Retention = map[string]int{"Log": 1, "Clipboard": 0}
+
+	repo.CreateNode("log:a", "Log", []byte("v1"), nil)
+	repo.CreateNode("clip:a", "Clipboard", []byte("v1"), nil)
+	repo.CreateNode("note:a", "Note", []byte("v1"), nil)
+	for _, v := range []string{"v2", "v3", "v4"} {
+		for _, id := range []string{"log:a", "clip:a", "note:a"} {
+			if _, err := repo.UpdateContent(id, []byte(v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if got := strings.Join(versions(t, repo, "log:a"), ","); got != "v4,v3" {
+		t.Errorf("Log history = %s, want v4,v3", got)
+	}
+	if got := strings.Join(versions(t, repo, "clip:a"), ","); got != "v4" {
+		t.Errorf("Clipboard history = %s, want v4", got)
+	}
+	if got := strings.Join(versions(t, repo, "note:a"), ","); got != "v4,v3,v2,v1" {
+		t.Errorf("Note history = %s, want everything", got)
+	}
+
+	// Pruned versions are gone from the store: per node, only what the
+	// chain still holds remains.
+	sizes, _ := repo.Store.Sizes()
+	envelopes := 0
+	for name := range sizes {
+		if v, err := repo.envelopeAt(name); err == nil && v.ID != "" {
+			envelopes++
+		}
+	}
+	if envelopes != 2+1+4 {
+		t.Errorf("%d node versions stored, want 7", envelopes)
+	}
+
+	log, _ := repo.Commits.Log(10)
+	var pruneMsgs []string
+	for _, c := range log {
+		if strings.Contains(c.Message, "pruned") {
+			pruneMsgs = append(pruneMsgs, c.Message)
+		}
+	}
+	if len(pruneMsgs) == 0 || !strings.Contains(strings.Join(pruneMsgs, "\n"), "update content clip:a (pruned 1 old version)") {
+		t.Errorf("pruning commits = %q", pruneMsgs)
+	}
+}
@@ -0,0 +1,95 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Retype changes a node's Type, writing a new version that points back at
+// the old one. Content and meta are carried over unchanged.
+func (r *Repository) Retype(id, newType string) (*NodeEnvelope, error) {
+	unlock := r.lockNode(id)
+	node, changed, err := r.retype(id, newType, time.Now().UTC())
+	unlock()
+	if err != nil || !changed {
+		return node, err
+	}
+	r.commit(fmt.Sprintf("retype %s -> %s", id, node.Type))
+	return node, nil
+}
+
+// RetypeAll moves every live node of type from to type to in a single
+// commit, e.g. to fold "note" into "Note". Returns how many nodes changed.
+// If ctx is cancelled midway, the nodes already retyped are committed as a
+// partial change and ctx's error is returned.
+func (r *Repository) RetypeAll(ctx context.Context, from, to string) (int, error) {
+	if from == to {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	n := 0
+	for _, id := range r.Search.FilterByType(from, 0) {
+		err := ctx.Err()
+		if err == nil {
+			unlock := r.lockNode(id)
+			_, _, err = r.retype(id, to, now)
+			unlock()
+		}
+		if err != nil {
+			if n > 0 {
+				r.commit(fmt.Sprintf("retype %d nodes %s -> %s (partial)", n, from, to))
+			}
+			return n, err
+		}
+		n++
+	}
+	if n > 0 {
+		r.commit(fmt.Sprintf("retype %d nodes %s -> %s", n, from, to))
+	}
+	return n, nil
+}
+
+// retype writes the new version and reindexes it without committing.
+// changed is false when the node already had newType. Callers hold id's
+// lock.
+func (r *Repository) retype(id, newType string, now time.Time) (node *NodeEnvelope, changed bool, err error) {
+	newType = strings.TrimSpace(newType)
+	if newType == "" {
+		return nil, false, fmt.Errorf("empty type for %s", id)
+	}
+	if err := r.EditLocks.Check(id); err != nil {
+		return nil, false, err
+	}
+	current, err := r.getNodeEnvelope(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if current.Deleted {
+		return nil, false, fmt.Errorf("cannot retype deleted node: %s", id)
+	}
+	if current.Type == newType {
+		return current, false, nil
+	}
+
+	prevCID, _ := r.Refs.Get(id)
+	node = &NodeEnvelope{
+		V:        1,
+		ID:       id,
+		Type:     newType,
+		Content:  current.Content,
+		Meta:     current.Meta,
+		Created:  current.Created,
+		Modified: now,
+		Prev:     CIDToFilename(prevCID),
+		Author:   r.author(),
+	}
+	if err := r.putNode(id, node); err != nil {
+		return nil, false, err
+	}
+
+	r.unindexNode(id)
+	r.indexNode(id, node)
+	return node, true, nil
+}
@@ -0,0 +1,60 @@
+package dag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRetype(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "note", []byte("hello"), map[string]interface{}{"k": "v"})
+
+	node, err := repo.Retype("note:a", "Note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != "Note" || string(node.Content) != "hello" || node.Meta["k"] != "v" {
+		t.Errorf("retyped node = %+v", node)
+	}
+	if node.Prev == "" {
+		t.Error("retyped node should point at its previous version")
+	}
+	if got := repo.Search.FilterByType("note", 0); len(got) != 0 {
+		t.Errorf("old type still indexed: %v", got)
+	}
+	if got := repo.Search.FilterByType("Note", 0); len(got) != 1 {
+		t.Errorf("FilterByType(Note) = %v, want [note:a]", got)
+	}
+
+	if _, err := repo.Retype("note:a", "  "); err == nil {
+		t.Error("empty type should be rejected")
+	}
+	if _, err := repo.Retype("note:missing", "Note"); err == nil {
+		t.Error("retyping a missing node should fail")
+	}
+}
+
+func TestRetypeAll_SingleCommit(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "note", nil, nil)
+	repo.CreateNode("note:b", "note", nil, nil)
+	repo.CreateNode("note:c", "Note", nil, nil)
+	before, _ := repo.Commits.Head()
+
+	n, err := repo.RetypeAll(context.Background(), "note", "Note")
+	if err != nil || n != 2 {
+		t.Fatalf("RetypeAll = %d, %v; want 2", n, err)
+	}
+	if got := repo.Search.FilterByType("Note", 0); len(got) != 3 {
+		t.Errorf("FilterByType(Note) = %v, want 3 nodes", got)
+	}
+
+	head, _ := repo.Commits.Head()
+	c, err := repo.Commits.GetCommit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Parent != CIDToFilename(before) {
+		t.Errorf("RetypeAll made more than one commit")
+	}
+}
@@ -0,0 +1,360 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scheduleTick is how often the scheduler checks for due jobs; cron specs
+// have minute resolution.
+const scheduleTick = time.Minute
+
+// ScheduledJob is one maintenance job Config.Schedule can name. It
+// returns a one-line account of what it did.
+type ScheduledJob func(ctx context.Context, r *Repository, now time.Time) (string, error)
+
+// scheduledJobs are the jobs by name:
+//
+//	gc       purge tombstones past their retention (see ExpireTrash)
+//	compact  rewrite the link journal without dead records
+//	indexes  save the meta index, so the next open need not rebuild it, and
+//	         compact the search postings spilled to disk
+//	reports  snapshot the scheduled Reports that are due, such as digests
+//	tags     create the daily and weekly snapshot tags that are due
+//	backup   archive the repository into Config.Backup (see Backup)
+var scheduledJobs = map[string]ScheduledJob{
+	"gc": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		if r.Config.AppendOnly {
+			return "skipped: append-only", nil
+		}
+		expired, err := r.ExpireTrash(ctx, now, false)
+		return fmt.Sprintf("purged %d tombstones", len(expired)), err
+	},
+	"compact": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		dropped, err := r.Links.Compact()
+		return fmt.Sprintf("dropped %d link records", dropped), err
+	},
+	"indexes": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		dropped, err := r.Search.Compact()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("saved meta index, dropped %d spilled postings", dropped), r.SaveIndexes()
+	},
+	"reports": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		made, err := r.SnapshotReports(ctx, now)
+		return fmt.Sprintf("made %d report outputs", len(made)), err
+	},
+	"tags": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		created, err := r.AutoTag(now)
+		return fmt.Sprintf("created %d tags", len(created)), err
+	},
+	"backup": func(ctx context.Context, r *Repository, now time.Time) (string, error) {
+		path, err := r.Backup(ctx, now)
+		return "wrote " + path, err
+	},
+}
+
+// ScheduledJobs returns the names of the jobs Config.Schedule can name,
+// sorted.
+func ScheduledJobs() []string {
+	names := make([]string, 0, len(scheduledJobs))
+	for name := range scheduledJobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JobStatus is how a scheduled job last went.
+type JobStatus struct {
+	Job      string        `json:"job"`
+	Spec     string        `json:"spec"`
+	LastRun  time.Time     `json:"last_run,omitzero"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Result   string        `json:"result,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Next     time.Time     `json:"next,omitzero"`
+}
+
+// Scheduler runs the jobs Config.Schedule names at the times its cron
+// specs give, and keeps each job's last run in .mx/schedule.json so a
+// restart neither repeats a run nor forgets a failure. A job whose time
+// passed while the daemon was down runs once at the next check.
+type Scheduler struct {
+	repo    *Repository
+	started time.Time // base for jobs that have never run
+
+	mu     sync.Mutex
+	status map[string]*JobStatus
+}
+
+// NewScheduler creates a Scheduler over repo. Nothing runs until Start
+// or RunDue.
+func NewScheduler(repo *Repository) *Scheduler {
+	return &Scheduler{repo: repo, started: time.Now()}
+}
+
+func (s *Scheduler) path() string {
+	return filepath.Join(s.repo.MxDir(), "schedule.json")
+}
+
+// load reads the saved statuses once. The caller holds s.mu.
+func (s *Scheduler) load() {
+	if s.status != nil {
+		return
+	}
+	s.status = make(map[string]*JobStatus)
+	data, err := os.ReadFile(s.path())
+	if err != nil {
+		return
+	}
+	var saved []*JobStatus
+	if err := json.Unmarshal(data, &saved); err != nil {
+		fmt.Printf("memex-fs: parse schedule status: %v\n", err)
+		return
+	}
+	for _, st := range saved {
+		s.status[st.Job] = st
+	}
+}
+
+// save writes the statuses. The caller holds s.mu.
+func (s *Scheduler) save() error {
+	out := make([]*JobStatus, 0, len(s.status))
+	for _, st := range s.status {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Job < out[j].Job })
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(s.path(), append(data, '\n'), 0644)
+}
+
+// Status returns every scheduled job's status, by name. Next is zero for
+// a spec that never matches, and in the past for a job that is due.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	var out []JobStatus
+	for job, spec := range s.repo.Config.Schedule {
+		st := JobStatus{Job: job, Spec: spec}
+		if saved := s.status[job]; saved != nil {
+			st = *saved
+			st.Spec = spec
+		}
+		base := st.LastRun
+		if base.IsZero() {
+			base = s.started
+		}
+		if cron, err := parseCron(spec); err == nil {
+			st.Next = cron.next(base)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Job < out[j].Job })
+	return out
+}
+
+// RunDue runs, one after another, every scheduled job whose time has come
+// by now. Failures are recorded in the job's status and returned joined.
+// Nothing runs while the indexes are still building after startup.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	if !s.repo.Ready() {
+		return nil
+	}
+	var errs []string
+	for _, st := range s.Status() {
+		if st.Next.IsZero() || st.Next.After(now) || ctx.Err() != nil {
+			continue
+		}
+		if err := s.run(ctx, st.Job, st.Spec, now); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", st.Job, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("scheduled jobs failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// run runs job now and records how it went.
+func (s *Scheduler) run(ctx context.Context, job, spec string, now time.Time) error {
+	began := time.Now()
+	result, err := scheduledJobs[job](ctx, s.repo, now)
+	st := &JobStatus{Job: job, Spec: spec, LastRun: now, Duration: time.Since(began).Round(time.Millisecond), Result: result}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	s.status[job] = st
+	if serr := s.save(); serr != nil {
+		fmt.Printf("memex-fs: save schedule status: %v\n", serr)
+	}
+	return err
+}
+
+// Start checks for due jobs every minute until the returned stop function
+// is called. Failures are logged and, like every run, recorded in the
+// job's status. Stopping also cancels a job that is still running.
+func (s *Scheduler) Start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(scheduleTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := s.RunDue(ctx, now); err != nil && ctx.Err() == nil {
+					fmt.Printf("memex-fs: %v\n", err)
+				}
+			}
+		}
+	}()
+	return cancel
+}
+
+// cronSchedule is a parsed cron spec: five fields, minute hour
+// day-of-month month day-of-week, each "*", a number, a range "a-b" or a
+// comma list of them, optionally stepped ("*/15", "9-17/2"). Sunday is 0
+// or 7. As in cron, a day matches when either day field does if both are
+// restricted. "@hourly", "@daily", "@weekly" and "@monthly" abbreviate
+// common specs, and "@every 6h" runs at a fixed interval instead.
+type cronSchedule struct {
+	every                         time.Duration
+	minute, hour, dom, month, dow uint64 // bit n set: value n matches
+	domAny, dowAny                bool
+}
+
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Minute {
+			return nil, errorOf(ErrValidation, "bad interval in %q (want at least 1m)", spec)
+		}
+		return &cronSchedule{every: d}, nil
+	}
+	if alias, ok := cronAliases[spec]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errorOf(ErrValidation, "cron spec %q: want 5 fields", spec)
+	}
+	c := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 7}} {
+		bits, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, errorOf(ErrValidation, "cron spec %q: %v", spec, err)
+		}
+		*f.bits = bits
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	return c, nil
+}
+
+// parseCronField parses one field whose values run from min to max.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad range %q", part)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after after that c matches, to the minute.
+func (c *cronSchedule) next(after time.Time) time.Time {
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Every match recurs within a few years (Feb 29 on a given weekday);
+	// give up past that rather than loop on a spec like "0 0 31 2 *".
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,110 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	cases := []struct {
+		spec, after, want string
+	}{
+		{"30 3 * * *", "2026-10-16 12:00", "2026-10-17 03:30"},
+		{"*/15 * * * *", "2026-10-16 12:07", "2026-10-16 12:15"},
+		{"0 0 * * 7", "2026-10-16 12:00", "2026-10-18 00:00"},  // Sunday
+		{"0 9 1 * 1", "2026-10-16 12:00", "2026-10-19 09:00"},  // 1st or Monday
+		{"0 0 29 2 *", "2026-10-16 12:00", "2028-02-29 00:00"}, // leap day
+		{"@daily", "2026-10-16 00:00", "2026-10-17 00:00"},
+		{"@every 90m", "2026-10-16 12:00", "2026-10-16 13:30"},
+		{"0 0 31 2 *", "2026-10-16 12:00", ""},
+	}
+	for _, c := range cases {
+		cron, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		got := cron.next(at(c.after))
+		if c.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%s after %s = %v, want never", c.spec, c.after, got)
+			}
+			continue
+		}
+		if !got.Equal(at(c.want)) {
+			t.Errorf("%s after %s = %v, want %s", c.spec, c.after, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * * 0 *", "*/0 * * * *", "5-1 * * * *", "@every 30s", "@sometimes"} {
+		if _, err := parseCron(bad); !errors.Is(err, ErrValidation) {
+			t.Errorf("parseCron(%q) = %v, want a validation error", bad, err)
+		}
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	repo.Config.Schedule = map[string]string{"compact": "@every 1h", "backup": "@daily"}
+	now := time.Now().Add(25 * time.Hour)
+
+	err := repo.Scheduler.RunDue(ctx, now)
+	if err == nil {
+		t.Fatal("backup without a directory did not fail")
+	}
+	status := repo.Scheduler.Status()
+	if len(status) != 2 || status[0].Job != "backup" || status[1].Job != "compact" {
+		t.Fatalf("status = %+v", status)
+	}
+	if status[0].Error == "" || !status[0].LastRun.Equal(now) {
+		t.Errorf("backup status = %+v", status[0])
+	}
+	if status[1].Error != "" || status[1].Result == "" || !status[1].Next.Equal(now.Add(time.Hour)) {
+		t.Errorf("compact status = %+v", status[1])
+	}
+
+	// Not due again yet; a reopened scheduler remembers the last run.
+	reopened := NewScheduler(repo)
+	if err := reopened.RunDue(ctx, now.Add(time.Minute)); err != nil {
+		t.Errorf("nothing due, got %v", err)
+	}
+	if st := reopened.Status(); !st[1].LastRun.Equal(now) {
+		t.Errorf("reloaded compact last run = %v, want %v", st[1].LastRun, now)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	repo := openTestRepo(t)
+	ctx := context.Background()
+	repo.CreateNode("note:a", "Note", []byte("kept"), nil)
+	dir := t.TempDir()
+	repo.Config.Backup = &BackupConfig{Dir: dir, Keep: 2}
+
+	start := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range 3 {
+		path, err := repo.Backup(ctx, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Fatalf("archive %s: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(left) != 2 || left[0] != paths[1] || left[1] != paths[2] {
+		t.Errorf("kept %v, want the newest two of %v", left, paths)
+	}
+}
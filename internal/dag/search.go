@@ -7,13 +7,18 @@ import (
 	"strings"
 	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
-// SearchIndex is an in-memory inverted index for full-text search.
+// SearchIndex is an in-memory inverted index for full-text search. With a
+// memory budget (see SetMemoryBudget) the largest postings move to disk
+// once the ones in memory outgrow it.
 type SearchIndex struct {
 	mu    sync.RWMutex
 	index map[string]map[string]bool // term -> set of ref IDs
 	types map[string]map[string]bool // type -> set of ref IDs
+	size  int64                      // estimated bytes of index
+	spill *searchSpill               // postings on disk; nil without a budget
 }
 
 // NewSearchIndex creates an empty SearchIndex.
@@ -71,13 +76,11 @@ func (s *SearchIndex) IndexNode(id string, node *NodeEnvelope) {
 	}
 
 	// Tokenize and index
-	terms := tokenize(strings.Join(parts, " "))
-	for _, term := range terms {
-		if s.index[term] == nil {
-			s.index[term] = make(map[string]bool)
-		}
-		s.index[term][id] = true
+	s.spill.track(id)
+	for _, term := range tokenize(strings.Join(parts, " ")) {
+		s.add(term, id)
 	}
+	s.maybeSpill()
 
 	// Type index
 	if node.Type != "" {
@@ -89,17 +92,64 @@ func (s *SearchIndex) IndexNode(id string, node *NodeEnvelope) {
 	}
 }
 
+// IndexText adds the terms of text to id's entry, for text that belongs to
+// a node without being in its envelope, such as the text extracted from a
+// document. RemoveNode drops it along with the rest.
+func (s *SearchIndex) IndexText(id, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spill.track(id)
+	for _, term := range tokenize(text) {
+		s.add(term, id)
+	}
+	s.maybeSpill()
+}
+
+// add indexes id under term. The caller holds s.mu.
+func (s *SearchIndex) add(term, id string) {
+	if s.spill.has(term) {
+		s.spill.append(term, id)
+		return
+	}
+	ids := s.index[term]
+	if ids == nil {
+		ids = make(map[string]bool)
+		s.index[term] = ids
+		s.size += termBytes(term)
+	}
+	if !ids[id] {
+		ids[id] = true
+		s.size += entryBytes(id)
+	}
+}
+
+// postings returns the IDs indexed under term, from memory or disk. The
+// caller holds s.mu and must not modify the result.
+func (s *SearchIndex) postings(term string) map[string]bool {
+	if ids, ok := s.index[term]; ok {
+		return ids
+	}
+	return s.spill.read(term)
+}
+
 // RemoveNode removes a node from the search and type indexes.
 func (s *SearchIndex) RemoveNode(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for term, ids := range s.index {
+		if !ids[id] {
+			continue
+		}
 		delete(ids, id)
+		s.size -= entryBytes(id)
 		if len(ids) == 0 {
 			delete(s.index, term)
+			s.size -= termBytes(term)
 		}
 	}
+	s.spill.forget(id)
 	for typ, ids := range s.types {
 		delete(ids, id)
 		if len(ids) == 0 {
@@ -120,11 +170,61 @@ func (s *SearchIndex) Search(query string, limit int) []string {
 
 	scores := make(map[string]int)
 	for _, term := range terms {
-		for id := range s.index[term] {
+		for id := range s.postings(term) {
 			scores[id]++
 		}
 	}
+	return rankScores(scores, limit)
+}
 
+// SearchPrefix is Search for a query still being typed: its last word, if
+// the query does not end in a space or punctuation, also matches every
+// indexed term it begins, so "zep" finds "zeppelin".
+func (s *SearchIndex) SearchPrefix(query string, limit int) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	last, _ := utf8.DecodeLastRuneInString(query)
+	if len(words) == 0 || !unicode.IsLetter(last) && !unicode.IsDigit(last) {
+		return s.Search(query, limit)
+	}
+	partial := words[len(words)-1]
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := make(map[string]int)
+	for _, term := range tokenize(strings.Join(words[:len(words)-1], " ")) {
+		if term == partial {
+			continue // counted below
+		}
+		for id := range s.postings(term) {
+			scores[id]++
+		}
+	}
+	matched := make(map[string]bool)
+	for term, ids := range s.index {
+		if !strings.HasPrefix(term, partial) {
+			continue
+		}
+		for id := range ids {
+			matched[id] = true
+		}
+	}
+	for _, term := range s.spill.prefixed(partial) {
+		for id := range s.spill.read(term) {
+			matched[id] = true
+		}
+	}
+	for id := range matched {
+		scores[id]++
+	}
+	return rankScores(scores, limit)
+}
+
+// rankScores orders the scored IDs best first, ties by ID, and keeps the
+// first limit.
+func rankScores(scores map[string]int, limit int) []string {
 	type scored struct {
 		id    string
 		score int
@@ -134,7 +234,10 @@ func (s *SearchIndex) Search(query string, limit int) []string {
 		results = append(results, scored{id, score})
 	}
 	sort.Slice(results, func(i, j int) bool {
-		return results[i].score > results[j].score
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].id < results[j].id
 	})
 
 	if limit > 0 && len(results) > limit {
@@ -148,6 +251,31 @@ func (s *SearchIndex) Search(query string, limit int) []string {
 	return ids
 }
 
+// sharedTerms scores the nodes holding any of terms, the terms of one
+// indexed node: a term held by n nodes adds 1/(n-1) to each, so a term
+// only one other node shares counts fully. Terms held by more than maxDF
+// nodes are skipped as too common to relate anything.
+func (s *SearchIndex) sharedTerms(terms []string, maxDF int) map[string]float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		if s.spill.has(term) && s.spill.count(term) < 2 {
+			continue // not worth a read
+		}
+		ids := s.postings(term)
+		if len(ids) < 2 || len(ids) > maxDF {
+			continue
+		}
+		w := 1 / float64(len(ids)-1)
+		for id := range ids {
+			scores[id] += w
+		}
+	}
+	return scores
+}
+
 // AllTypes returns a sorted list of all known type strings.
 func (s *SearchIndex) AllTypes() []string {
 	s.mu.RLock()
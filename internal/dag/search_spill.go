@@ -0,0 +1,241 @@
+package dag
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Estimated bytes of a term's map in the index, and of one ID in it,
+// beyond the strings themselves.
+const (
+	termOverhead  = 96
+	entryOverhead = 48
+)
+
+func termBytes(term string) int64 { return int64(len(term) + termOverhead) }
+func entryBytes(id string) int64  { return int64(len(id) + entryOverhead) }
+
+// searchSpill keeps the postings of some terms on disk, one file per term
+// of "id generation" lines that are only ever appended to. Removing a node
+// from them would mean rewriting every file it is in, so instead each
+// indexed node has a generation, and a line counts only while its
+// generation is the node's current one: RemoveNode just forgets the
+// node's generation, and the next IndexNode gives it a new one. Compact
+// drops the lines that no longer count.
+type searchSpill struct {
+	dir    string
+	budget int64            // estimated bytes of postings kept in memory
+	terms  map[string]int   // spilled term -> lines in its file
+	gen    map[string]int64 // indexed ID -> its current generation
+	next   int64
+	disk   int64 // bytes in the files
+}
+
+// SetMemoryBudget bounds the postings s keeps in memory to about budget
+// bytes, spilling the largest into files under dir beyond it; 0 removes
+// the bound. Call it on an empty index: dir is emptied, since the index is
+// rebuilt each time the repository opens.
+func (s *SearchIndex) SetMemoryBudget(dir string, budget int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clear search spill: %w", err)
+	}
+	if budget <= 0 {
+		s.spill = nil
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create search spill: %w", err)
+	}
+	s.spill = &searchSpill{dir: dir, budget: budget, terms: make(map[string]int), gen: make(map[string]int64)}
+	return nil
+}
+
+// maybeSpill moves the largest postings to disk until those left in
+// memory fit in three quarters of the budget, so spilling does not recur
+// on every write. The caller holds s.mu.
+func (s *SearchIndex) maybeSpill() {
+	if s.spill == nil || s.size <= s.spill.budget {
+		return
+	}
+	terms := make([]string, 0, len(s.index))
+	for term := range s.index {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(s.index[terms[i]]) > len(s.index[terms[j]]) })
+	for _, term := range terms {
+		if s.size <= s.spill.budget*3/4 {
+			break
+		}
+		ids := s.index[term]
+		if err := s.spill.write(term, ids); err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+			return
+		}
+		for id := range ids {
+			s.size -= entryBytes(id)
+		}
+		s.size -= termBytes(term)
+		delete(s.index, term)
+	}
+}
+
+// Compact rewrites the spilled postings without the lines that no longer
+// count, returning how many it dropped.
+func (s *SearchIndex) Compact() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spill == nil {
+		return 0, nil
+	}
+	dropped := 0
+	for term, lines := range s.spill.terms {
+		live := s.spill.read(term)
+		if len(live) == lines {
+			continue
+		}
+		path := s.spill.path(term)
+		if info, err := os.Stat(path); err == nil {
+			s.spill.disk -= info.Size()
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return dropped, fmt.Errorf("compact search spill: %w", err)
+		}
+		delete(s.spill.terms, term)
+		if len(live) > 0 {
+			if err := s.spill.write(term, live); err != nil {
+				return dropped, err
+			}
+		}
+		dropped += lines - len(live)
+	}
+	return dropped, nil
+}
+
+// path is where term's postings are kept. Terms are hashed, since they
+// may hold anything a filename cannot.
+func (sp *searchSpill) path(term string) string {
+	sum := sha1.Sum([]byte(term))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(sp.dir, name[:2], name)
+}
+
+func (sp *searchSpill) has(term string) bool {
+	if sp == nil {
+		return false
+	}
+	_, ok := sp.terms[term]
+	return ok
+}
+
+// count is how many lines term's file holds, an upper bound on its IDs.
+func (sp *searchSpill) count(term string) int {
+	return sp.terms[term]
+}
+
+// track gives id a generation if it has none.
+func (sp *searchSpill) track(id string) {
+	if sp == nil {
+		return
+	}
+	if _, ok := sp.gen[id]; !ok {
+		sp.next++
+		sp.gen[id] = sp.next
+	}
+}
+
+// forget invalidates every line for id written so far.
+func (sp *searchSpill) forget(id string) {
+	if sp != nil {
+		delete(sp.gen, id)
+	}
+}
+
+// prefixed returns the spilled terms that begin with prefix.
+func (sp *searchSpill) prefixed(prefix string) []string {
+	if sp == nil {
+		return nil
+	}
+	var out []string
+	for term := range sp.terms {
+		if strings.HasPrefix(term, prefix) {
+			out = append(out, term)
+		}
+	}
+	return out
+}
+
+// write appends ids to term's file, making term spilled.
+func (sp *searchSpill) write(term string, ids map[string]bool) error {
+	var b strings.Builder
+	for id := range ids {
+		fmt.Fprintf(&b, "%s %d\n", id, sp.gen[id])
+	}
+	if err := sp.appendLines(term, b.String()); err != nil {
+		return err
+	}
+	sp.terms[term] += len(ids)
+	return nil
+}
+
+// append adds id to spilled term's postings.
+func (sp *searchSpill) append(term, id string) {
+	if err := sp.appendLines(term, fmt.Sprintf("%s %d\n", id, sp.gen[id])); err != nil {
+		fmt.Printf("memex-fs: %v\n", err)
+		return
+	}
+	sp.terms[term]++
+}
+
+func (sp *searchSpill) appendLines(term, lines string) error {
+	path := sp.path(term)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("spill search postings: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("spill search postings: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(lines); err != nil {
+		return fmt.Errorf("spill search postings: %w", err)
+	}
+	sp.disk += int64(len(lines))
+	return nil
+}
+
+// read returns the IDs of term's lines that still count.
+func (sp *searchSpill) read(term string) map[string]bool {
+	if !sp.has(term) {
+		return nil
+	}
+	f, err := os.Open(sp.path(term))
+	if err != nil {
+		fmt.Printf("memex-fs: read search spill: %v\n", err)
+		return nil
+	}
+	defer f.Close()
+	ids := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		id := line[:i]
+		gen, err := strconv.ParseInt(line[i+1:], 10, 64)
+		if err == nil && gen == sp.gen[id] && gen != 0 {
+			ids[id] = true
+		}
+	}
+	return ids
+}
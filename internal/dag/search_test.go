@@ -0,0 +1,28 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchPrefix(t *testing.T) {
+	s := NewSearchIndex()
+	s.IndexNode("note:a", &NodeEnvelope{Type: "Note", Content: []byte("zeppelin mooring mast")})
+	s.IndexNode("note:b", &NodeEnvelope{Type: "Note", Content: []byte("zebra crossing")})
+	s.IndexNode("note:c", &NodeEnvelope{Type: "Note", Content: []byte("mooring lines")})
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"z", []string{"note:a", "note:b"}},
+		{"zep", []string{"note:a"}},
+		{"mooring ze", []string{"note:a", "note:b", "note:c"}},
+		{"mooring zep", []string{"note:a", "note:c"}},
+		{"zep ", []string{}}, // a finished word must match whole
+	} {
+		if got := s.SearchPrefix(tc.query, 0); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchPrefix(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
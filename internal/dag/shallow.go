@@ -0,0 +1,190 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// MetaTags is the meta key SparseFilter.Tags matches: a tag name or a list
+// of them.
+const MetaTags = "tags"
+
+// SparseFilter selects the nodes a partial pull or import fetches in full.
+// An empty filter selects every node.
+type SparseFilter struct {
+	Types []string `json:"types,omitempty"` // node types to keep
+	Tags  []string `json:"tags,omitempty"`  // keep nodes tagged with any of these
+}
+
+// Empty reports whether f selects every node.
+func (f SparseFilter) Empty() bool {
+	return len(f.Types) == 0 && len(f.Tags) == 0
+}
+
+// Match reports whether f selects node: its type is listed, or it carries
+// one of the listed tags.
+func (f SparseFilter) Match(node *NodeEnvelope) bool {
+	if f.Empty() || slices.Contains(f.Types, node.Type) {
+		return true
+	}
+	switch t := node.Meta[MetaTags].(type) {
+	case string:
+		return slices.Contains(f.Tags, t)
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && slices.Contains(f.Tags, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ShallowInfo records what a partial pull left out, at .mx/shallow.json.
+// Objects it did not fetch are fetched on first read when the store has a
+// fetcher (see ObjectStore.SetFetcher).
+type ShallowInfo struct {
+	Depth  int          `json:"depth,omitempty"` // commits fetched per pull; 0 is all
+	Sparse SparseFilter `json:"sparse,omitempty"`
+	// Boundary lists the pulled commits whose parents were not fetched.
+	// History stops at them.
+	Boundary []string `json:"boundary,omitempty"`
+}
+
+// ObjectFetcher fetches an object missing from the local store, e.g. from
+// IPFS.
+type ObjectFetcher func(c gocid.Cid) ([]byte, error)
+
+// shallowState is the repository's ShallowInfo and where it is kept.
+type shallowState struct {
+	path string
+	mu   sync.Mutex
+	info *ShallowInfo
+}
+
+func loadShallow(path string) (*shallowState, error) {
+	s := &shallowState{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read shallow info: %w", err)
+	}
+	s.info = &ShallowInfo{}
+	if err := json.Unmarshal(data, s.info); err != nil {
+		return nil, fmt.Errorf("parse shallow info: %w", err)
+	}
+	return s, nil
+}
+
+// Shallow returns what partial pulls into r left out, or nil if r holds
+// complete history.
+func (r *Repository) Shallow() *ShallowInfo {
+	r.shallow.mu.Lock()
+	defer r.shallow.mu.Unlock()
+	if r.shallow.info == nil {
+		return nil
+	}
+	info := *r.shallow.info
+	info.Boundary = slices.Clone(info.Boundary)
+	return &info
+}
+
+// MarkShallow records a partial pull: its depth and filter, and the
+// commits it stopped at. Boundaries of earlier pulls are kept, except
+// commits whose parents this pull fetched.
+func (r *Repository) MarkShallow(depth int, sparse SparseFilter, boundary, complete []string) error {
+	r.shallow.mu.Lock()
+	defer r.shallow.mu.Unlock()
+	info := &ShallowInfo{Depth: depth, Sparse: sparse}
+	if r.shallow.info != nil {
+		for _, c := range r.shallow.info.Boundary {
+			if !slices.Contains(complete, c) && !slices.Contains(boundary, c) {
+				info.Boundary = append(info.Boundary, c)
+			}
+		}
+	}
+	info.Boundary = append(info.Boundary, boundary...)
+	slices.Sort(info.Boundary)
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := SafeWrite(r.shallow.path, data, 0644); err != nil {
+		return fmt.Errorf("write shallow info: %w", err)
+	}
+	r.shallow.info = info
+	r.Commits.setBoundary(info.Boundary)
+	return nil
+}
+
+// shallowPath is where r's ShallowInfo lives.
+func shallowPath(mxDir string) string {
+	return filepath.Join(mxDir, "shallow.json")
+}
+
+// Checkout makes a pulled commit the head of r, which must have no
+// commits or nodes yet: the nodes sparse selects get refs (the rest are
+// not checked out, as in a sparse git checkout, so a later commit no
+// longer lists them), along with the links from them. Returns the number
+// of nodes checked out.
+func (r *Repository) Checkout(head gocid.Cid, sparse SparseFilter) (int, error) {
+	r.shared.commitMu.Lock()
+	defer r.shared.commitMu.Unlock()
+	if cur, err := r.Commits.Head(); err != nil || cur.Defined() {
+		return 0, fmt.Errorf("checkout needs an empty repository")
+	}
+	if ids, err := r.Refs.List(); err != nil || len(ids) > 0 {
+		return 0, fmt.Errorf("checkout needs an empty repository")
+	}
+	commit, err := r.Commits.GetCommit(head)
+	if err != nil {
+		return 0, fmt.Errorf("load commit: %w", err)
+	}
+
+	kept := make(map[string]bool)
+	for id, name := range commit.Refs {
+		c, err := cidFromFilename(name)
+		if err != nil {
+			return len(kept), err
+		}
+		// Match on the envelope alone, so unselected DAG-JSON content
+		// blocks are never fetched.
+		data, err := r.Store.Get(c)
+		if err != nil {
+			return len(kept), fmt.Errorf("load %s: %w", id, err)
+		}
+		node, err := DecodeNode(data, nil)
+		if err != nil || !sparse.Match(node) {
+			continue
+		}
+		if node, err = DecodeNode(data, r.Store.Get); err != nil {
+			return len(kept), fmt.Errorf("load %s: %w", id, err)
+		}
+		if err := r.Refs.Set(id, c); err != nil {
+			return len(kept), fmt.Errorf("set ref: %w", err)
+		}
+		kept[id] = true
+		if !node.Deleted {
+			r.indexNode(id, node)
+		}
+	}
+	for _, l := range commit.Links {
+		if kept[l.Source] {
+			if err := r.Links.Add(l); err != nil {
+				return len(kept), err
+			}
+		}
+	}
+	if err := r.Commits.setHead(head); err != nil {
+		return len(kept), err
+	}
+	return len(kept), nil
+}
@@ -0,0 +1,86 @@
+package dag
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSparseFilter_Match(t *testing.T) {
+	f := SparseFilter{Types: []string{"Note"}, Tags: []string{"keep"}}
+	for _, tc := range []struct {
+		node *NodeEnvelope
+		want bool
+	}{
+		{&NodeEnvelope{Type: "Note"}, true},
+		{&NodeEnvelope{Type: "Task"}, false},
+		{&NodeEnvelope{Type: "Task", Meta: map[string]interface{}{MetaTags: "keep"}}, true},
+		{&NodeEnvelope{Type: "Task", Meta: map[string]interface{}{MetaTags: []interface{}{"x", "keep"}}}, true},
+		{&NodeEnvelope{Type: "Task", Meta: map[string]interface{}{MetaTags: []interface{}{"x"}}}, false},
+	} {
+		if got := f.Match(tc.node); got != tc.want {
+			t.Errorf("Match(%+v) = %v, want %v", tc.node, got, tc.want)
+		}
+	}
+	if !(SparseFilter{}).Match(&NodeEnvelope{Type: "Anything"}) {
+		t.Error("empty filter rejected a node")
+	}
+}
+
+func TestMarkShallow(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Shallow() != nil {
+		t.Fatal("new repository is shallow")
+	}
+	if err := repo.MarkShallow(2, SparseFilter{}, []string{"c2"}, []string{"c3"}); err != nil {
+		t.Fatal(err)
+	}
+	// A deeper pull completes c2 and stops at c1.
+	if err := repo.MarkShallow(3, SparseFilter{}, []string{"c1"}, []string{"c2", "c3"}); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := reopened.Shallow()
+	if info == nil || info.Depth != 3 || !slices.Equal(info.Boundary, []string{"c1"}) {
+		t.Errorf("Shallow = %+v, want depth 3 and boundary [c1]", info)
+	}
+}
+
+func TestImportBundleWith_Depth(t *testing.T) {
+	src := openTestRepo(t)
+	src.identity = testIdentity(t)
+	for i, content := range []string{"one", "two", "three"} {
+		var err error
+		if i == 0 {
+			_, err = src.CreateNode("n", "Note", []byte(content), nil)
+		} else {
+			_, err = src.UpdateContent("n", []byte(content))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := src.ExportBundle("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestRepo(t)
+	head, err := dst.ImportBundleWith(b, ImportOptions{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := dst.envelopeAt(head.Prev)
+	if err != nil || string(prev.Content) != "two" {
+		t.Fatalf("kept prev = %+v, %v", prev, err)
+	}
+	if _, err := dst.envelopeAt(prev.Prev); err == nil {
+		t.Error("version past the depth was imported")
+	}
+}
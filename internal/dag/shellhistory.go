@@ -0,0 +1,409 @@
+package dag
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shell history import creates a Command node per history entry, linked
+// by RAN_IN to the Project nodes it was run for, which puts commands in
+// their projects' /related/ lists.
+const (
+	CommandType = "Command"
+	ProjectType = "Project"
+	LinkRanIn   = "RAN_IN"
+)
+
+// HistoryEntry is one command read from a shell history file.
+type HistoryEntry struct {
+	Command  string
+	Time     time.Time     // zero when the history records none
+	Duration time.Duration // zsh extended history only
+	// Dir is the working directory, inferred from the cd commands before
+	// it, since histories do not record it; "" when unknown.
+	Dir   string
+	Paths []string // paths named by the command, as fish records them
+
+	seq int // position in the file, identifying entries without a Time
+}
+
+// ImportStats counts what a bulk import did.
+type ImportStats struct {
+	Created int // new nodes
+	Updated int // nodes changed since an earlier import
+	Skipped int // entries already imported
+	Linked  int // links created
+}
+
+var zshExtended = regexp.MustCompile(`^: *(\d+):(\d+);`)
+
+// ParseShellHistory reads a bash, zsh or fish history file. home resolves
+// ~ in cd commands when inferring working directories.
+func ParseShellHistory(shell string, r io.Reader, home string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	var err error
+	switch shell {
+	case "bash":
+		entries, err = parseBashHistory(r)
+	case "zsh":
+		entries, err = parseZshHistory(r)
+	case "fish":
+		entries, err = parseFishHistory(r)
+	default:
+		return nil, fmt.Errorf("unknown shell %q (want bash, zsh or fish)", shell)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s history: %w", shell, err)
+	}
+	inferDirs(entries, home)
+	return entries, nil
+}
+
+// parseBashHistory reads ~/.bash_history. With HISTTIMEFORMAT set, bash
+// writes a "#<unix time>" line before each command, and a command spans
+// every line up to the next one.
+func parseBashHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	var cur *HistoryEntry
+	timed := false
+	sc := newHistoryScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if ts, ok := bashTimestamp(line); ok {
+			timed = true
+			entries = append(entries, HistoryEntry{Time: ts})
+			cur = &entries[len(entries)-1]
+			continue
+		}
+		switch {
+		case timed && cur != nil && cur.Command != "":
+			cur.Command += "\n" + line
+		case timed && cur != nil:
+			cur.Command = line
+		default:
+			entries = append(entries, HistoryEntry{Command: line})
+		}
+	}
+	return finishEntries(entries), sc.Err()
+}
+
+func bashTimestamp(line string) (time.Time, bool) {
+	if len(line) < 2 || line[0] != '#' {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0).UTC(), true
+}
+
+// parseZshHistory reads ~/.zsh_history, plain or in EXTENDED_HISTORY
+// form (": <start>:<elapsed>;<command>"). A trailing backslash continues
+// a command on the next line.
+func parseZshHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	cont := false
+	sc := newHistoryScanner(r)
+	for sc.Scan() {
+		line := zshUnmetafy(sc.Text())
+		if cont {
+			e := &entries[len(entries)-1]
+			e.Command = strings.TrimSuffix(e.Command, "\\") + "\n" + line
+		} else if m := zshExtended.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.ParseInt(m[1], 10, 64)
+			elapsed, _ := strconv.ParseInt(m[2], 10, 64)
+			entries = append(entries, HistoryEntry{
+				Command:  line[len(m[0]):],
+				Time:     time.Unix(start, 0).UTC(),
+				Duration: time.Duration(elapsed) * time.Second,
+			})
+		} else {
+			entries = append(entries, HistoryEntry{Command: line})
+		}
+		cont = strings.HasSuffix(line, "\\")
+	}
+	return finishEntries(entries), sc.Err()
+}
+
+// zshUnmetafy undoes zsh's escaping of bytes >= 0x83 in its history file.
+func zshUnmetafy(s string) string {
+	if !strings.Contains(s, "\x83") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x83 && i+1 < len(s) {
+			i++
+			b = append(b, s[i]^32)
+		} else {
+			b = append(b, s[i])
+		}
+	}
+	return string(b)
+}
+
+// parseFishHistory reads fish's YAML-like history file, where each entry
+// is a "- cmd: <command>" line followed by indented "when: <unix time>"
+// and "paths:" fields, the latter listing one path per "- " line.
+func parseFishHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	inPaths := false
+	sc := newHistoryScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "- cmd: "):
+			entries = append(entries, HistoryEntry{Command: fishUnescape(line[len("- cmd: "):])})
+			inPaths = false
+		case len(entries) == 0:
+		case strings.HasPrefix(line, "  when: "):
+			if sec, err := strconv.ParseInt(strings.TrimSpace(line[len("  when: "):]), 10, 64); err == nil {
+				entries[len(entries)-1].Time = time.Unix(sec, 0).UTC()
+			}
+		case strings.HasPrefix(line, "  paths:"):
+			inPaths = true
+		case inPaths && strings.HasPrefix(line, "    - "):
+			e := &entries[len(entries)-1]
+			e.Paths = append(e.Paths, fishUnescape(line[len("    - "):]))
+		}
+	}
+	return finishEntries(entries), sc.Err()
+}
+
+func fishUnescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(s)
+}
+
+func newHistoryScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	return sc
+}
+
+// finishEntries drops blank commands and numbers the rest.
+func finishEntries(entries []HistoryEntry) []HistoryEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		e.Command = strings.TrimSpace(e.Command)
+		if e.Command == "" {
+			continue
+		}
+		e.seq = len(out)
+		out = append(out, e)
+	}
+	return out
+}
+
+// inferDirs fills in each entry's Dir by following cd commands: absolute
+// and ~ targets set it, relative ones resolve against it once known, and
+// anything else ("cd -", "cd $VAR") makes it unknown again.
+func inferDirs(entries []HistoryEntry, home string) {
+	dir := ""
+	for i := range entries {
+		entries[i].Dir = dir
+		for _, part := range strings.FieldsFunc(entries[i].Command, func(r rune) bool {
+			return r == ';' || r == '&' || r == '|' || r == '\n'
+		}) {
+			f := strings.Fields(part)
+			if len(f) == 0 || f[0] != "cd" {
+				continue
+			}
+			target := "~"
+			if len(f) > 1 {
+				target = strings.Trim(f[1], `'"`)
+			}
+			dir = resolveDir(dir, target, home)
+		}
+	}
+}
+
+func resolveDir(dir, target, home string) string {
+	switch {
+	case target == "~" && home != "":
+		return home
+	case strings.HasPrefix(target, "~/") && home != "":
+		return filepath.Join(home, target[2:])
+	case filepath.IsAbs(target):
+		return filepath.Clean(target)
+	case dir != "" && !strings.ContainsAny(target, "$`~") && target != "-":
+		return filepath.Join(dir, target)
+	}
+	return ""
+}
+
+// commandNodeID derives a Command node's ID from the entry, so importing
+// the same history twice skips what is already there.
+func commandNodeID(e HistoryEntry) string {
+	when := "#" + strconv.Itoa(e.seq)
+	if !e.Time.IsZero() {
+		when = strconv.FormatInt(e.Time.Unix(), 10)
+	}
+	sum := sha256.Sum256([]byte(when + "\x00" + e.Command))
+	return "cmd:" + hex.EncodeToString(sum[:8])
+}
+
+// projectMatcher decides which Project nodes a command belongs to.
+type projectMatcher struct {
+	dirs  map[string][]string // project ID -> its meta "path" directories
+	names map[string]string   // project ID -> name, for projects without a path
+}
+
+// projectMatcher collects the live Project nodes. A project is matched by
+// the directories in its "path" meta, or, lacking one, by its name (its
+// "name" meta or the last segment of its ID) appearing as a directory in
+// the command's paths.
+func (r *Repository) projectMatcher() *projectMatcher {
+	m := &projectMatcher{dirs: make(map[string][]string), names: make(map[string]string)}
+	for _, id := range r.Search.FilterByType(ProjectType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		switch p := node.Meta["path"].(type) {
+		case string:
+			m.dirs[id] = append(m.dirs[id], filepath.Clean(p))
+		case []interface{}:
+			for _, v := range p {
+				if s, ok := v.(string); ok {
+					m.dirs[id] = append(m.dirs[id], filepath.Clean(s))
+				}
+			}
+		}
+		if len(m.dirs[id]) > 0 {
+			continue
+		}
+		name, _ := node.Meta["name"].(string)
+		if name == "" {
+			name = id[strings.LastIndex(id, ":")+1:]
+		}
+		if len(name) >= 3 {
+			m.names[id] = strings.ToLower(name)
+		}
+	}
+	return m
+}
+
+// match returns the IDs of the projects e was run for.
+func (m *projectMatcher) match(e HistoryEntry) []string {
+	var paths []string
+	if e.Dir != "" {
+		paths = append(paths, e.Dir)
+	}
+	for _, p := range e.Paths {
+		if !filepath.IsAbs(p) && e.Dir != "" {
+			p = filepath.Join(e.Dir, p)
+		}
+		paths = append(paths, p)
+	}
+	for _, f := range strings.Fields(e.Command) {
+		if filepath.IsAbs(f) {
+			paths = append(paths, filepath.Clean(f))
+		}
+	}
+
+	var ids []string
+	for id, dirs := range m.dirs {
+	dirs:
+		for _, d := range dirs {
+			for _, p := range paths {
+				if p == d || strings.HasPrefix(p, d+string(filepath.Separator)) {
+					ids = append(ids, id)
+					break dirs
+				}
+			}
+		}
+	}
+	for id, name := range m.names {
+	names:
+		for _, p := range paths {
+			for _, part := range strings.Split(p, string(filepath.Separator)) {
+				if strings.ToLower(part) == name {
+					ids = append(ids, id)
+					break names
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// ImportShellHistory creates a Command node for each entry not imported
+// before, with its shell, time ("date", which files it in the journal),
+// duration and inferred working directory in meta, and links it RAN_IN to
+// the projects it matches. Everything is committed at once; if ctx is
+// cancelled midway, what was imported so far is committed and ctx's error
+// returned.
+func (r *Repository) ImportShellHistory(ctx context.Context, shell string, entries []HistoryEntry) (ImportStats, error) {
+	var stats ImportStats
+	projects := r.projectMatcher()
+	var created []*NodeEnvelope
+	var links []LinkEntry
+	var err error
+	for _, e := range entries {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		id := commandNodeID(e)
+		meta := map[string]interface{}{"shell": shell}
+		if !e.Time.IsZero() {
+			meta[journalDateKey] = e.Time.Format(time.RFC3339)
+		}
+		if e.Duration > 0 {
+			meta["duration_s"] = int(e.Duration / time.Second)
+		}
+		if e.Dir != "" {
+			meta["cwd"] = e.Dir
+		}
+		if len(e.Paths) > 0 {
+			meta["paths"] = e.Paths
+		}
+
+		unlock := r.lockNode(id)
+		var node *NodeEnvelope
+		if r.Refs.Has(id) {
+			stats.Skipped++
+		} else {
+			node, err = r.storeNewNode(id, CommandType, []byte(e.Command), meta)
+		}
+		unlock()
+		if err != nil {
+			break
+		}
+		if node == nil {
+			continue
+		}
+		stats.Created++
+		created = append(created, node)
+		for _, pid := range projects.match(e) {
+			link := LinkEntry{Source: id, Target: pid, Type: LinkRanIn}
+			if err = r.Links.Add(link); err != nil {
+				break
+			}
+			links = append(links, link)
+			stats.Linked++
+		}
+		if err != nil {
+			break
+		}
+	}
+	if stats.Created > 0 {
+		r.commit(fmt.Sprintf("import %d commands from %s history", stats.Created, shell))
+		for _, node := range created {
+			r.afterCreate(node)
+		}
+		for i := range links {
+			r.Webhooks.Notify(WebhookEvent{Event: EventLinkCreated, Link: &links[i]})
+		}
+	}
+	return stats, err
+}
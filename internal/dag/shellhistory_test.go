@@ -0,0 +1,104 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseShellHistory(t *testing.T) {
+	tests := []struct {
+		shell, input string
+		want         []string
+		time         int64 // of the first entry; 0 for none
+	}{
+		{"bash", "ls\ncd /src/app\nmake\n", []string{"ls", "cd /src/app", "make"}, 0},
+		{"bash", "#1700000000\ngit status\n#1700000060\nfor f in *; do\necho $f\ndone\n",
+			[]string{"git status", "for f in *; do\necho $f\ndone"}, 1700000000},
+		{"zsh", ": 1700000000:3;make test\n: 1700000010:0;echo a \\\nb\nplain\n",
+			[]string{"make test", "echo a \nb", "plain"}, 1700000000},
+		{"fish", "- cmd: vim notes.md\n  when: 1700000000\n  paths:\n    - notes.md\n- cmd: echo a\\nb\n  when: 1700000001\n",
+			[]string{"vim notes.md", "echo a\nb"}, 1700000000},
+	}
+	for _, tt := range tests {
+		entries, err := ParseShellHistory(tt.shell, strings.NewReader(tt.input), "/home/u")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.shell, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Command)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: commands = %q, want %q", tt.shell, got, tt.want)
+		}
+		if tt.time != 0 && !entries[0].Time.Equal(time.Unix(tt.time, 0)) {
+			t.Errorf("%s: time = %v", tt.shell, entries[0].Time)
+		}
+	}
+	if _, err := ParseShellHistory("tcsh", strings.NewReader(""), ""); err == nil {
+		t.Error("parsed an unknown shell")
+	}
+}
+
+func TestInferDirs(t *testing.T) {
+	entries, _ := ParseShellHistory("bash", strings.NewReader(
+		"pwd\ncd ~/src\ncd app && make\ngo test\ncd -\nls\n"), "/home/u")
+	want := []string{"", "", "/home/u/src", "/home/u/src/app", "/home/u/src/app", ""}
+	for i, e := range entries {
+		if e.Dir != want[i] {
+			t.Errorf("%q: dir = %q, want %q", e.Command, e.Dir, want[i])
+		}
+	}
+}
+
+func TestImportShellHistory_LinksProjects(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("project:memex", "Project", nil, map[string]interface{}{"path": "/home/u/src/memex"})
+	repo.CreateNode("project:website", "Project", nil, nil)
+
+	entries, err := ParseShellHistory("zsh", strings.NewReader(
+		": 1700000000:0;cd ~/src/memex\n"+
+			": 1700000005:2;go test ./...\n"+
+			": 1700000010:0;rsync -a build/ /srv/website/\n"), "/home/u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := repo.ImportShellHistory(t.Context(), "zsh", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Created != 3 || stats.Linked != 3 {
+		t.Errorf("stats = %+v, want 3 created, 3 linked", stats)
+	}
+
+	id := commandNodeID(entries[1])
+	node, err := repo.GetNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Type != CommandType || node.Meta["cwd"] != "/home/u/src/memex" || node.Meta["duration_s"] != float64(2) {
+		t.Errorf("node = %s %v", node.Type, node.Meta)
+	}
+	if node.Meta["date"] != "2023-11-14T22:13:25Z" {
+		t.Errorf("date = %v", node.Meta["date"])
+	}
+	related := repo.Relatedness.Related("project:memex", 10)
+	if len(related) != 2 || !strings.HasPrefix(related[0], "cmd:") {
+		t.Errorf("Related(project:memex) = %v", related)
+	}
+	// The rsync runs from memex's directory but names the website's.
+	rsync := commandNodeID(entries[2])
+	var targets []string
+	for _, l := range repo.Links.LinksFrom(rsync) {
+		targets = append(targets, l.Target)
+	}
+	if strings.Join(targets, ",") != "project:memex,project:website" && strings.Join(targets, ",") != "project:website,project:memex" {
+		t.Errorf("rsync links to %v", targets)
+	}
+
+	again, err := repo.ImportShellHistory(t.Context(), "zsh", entries)
+	if err != nil || again.Created != 0 || again.Skipped != 3 {
+		t.Errorf("reimport = %+v, %v", again, err)
+	}
+}
@@ -1,7 +1,6 @@
 package dag
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -68,18 +67,14 @@ func (s *Snapshot) GetNode(id string) (*NodeEnvelope, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse ref CID: %w", err)
 	}
-	data, err := s.store.Get(c)
+	node, err := s.store.GetNode(c)
 	if err != nil {
 		return nil, err
 	}
-	var node NodeEnvelope
-	if err := json.Unmarshal(data, &node); err != nil {
-		return nil, fmt.Errorf("unmarshal node: %w", err)
-	}
 	if node.Deleted {
-		return nil, fmt.Errorf("node deleted: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrDeleted, id)
 	}
-	return &node, nil
+	return node, nil
 }
 
 // ListNodes returns all non-deleted node IDs in this snapshot.
@@ -0,0 +1,151 @@
+package dag
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FlashcardType is the node type the review scheduler works on.
+const FlashcardType = "Flashcard"
+
+// Scheduling state lives in a flashcard's meta so it is versioned, synced
+// and editable like any other field:
+//
+//	ease      SM-2 easiness factor, starts at 2.5, never below 1.3
+//	interval  days until the next review
+//	reps      consecutive successful reviews
+//	due       next review day, YYYY-MM-DD
+const (
+	srsEaseKey     = "ease"
+	srsIntervalKey = "interval"
+	srsRepsKey     = "reps"
+	srsDueKey      = "due"
+
+	srsDefaultEase = 2.5
+	srsMinEase     = 1.3
+)
+
+// MaxGrade is the best review grade; 0 is a complete blackout and anything
+// below 3 counts as a lapse.
+const MaxGrade = 5
+
+// SRSState is a flashcard's scheduling state.
+type SRSState struct {
+	Ease     float64
+	Interval int // days
+	Reps     int
+	Due      string // YYYY-MM-DD, empty for a card never reviewed
+}
+
+// cardState reads a flashcard's scheduling state from its meta, falling
+// back to the defaults for a new card.
+func cardState(node *NodeEnvelope) SRSState {
+	s := SRSState{Ease: srsDefaultEase}
+	if v, ok := metaFloat(node.Meta[srsEaseKey]); ok && v >= srsMinEase {
+		s.Ease = v
+	}
+	if v, ok := metaFloat(node.Meta[srsIntervalKey]); ok && v > 0 {
+		s.Interval = int(v)
+	}
+	if v, ok := metaFloat(node.Meta[srsRepsKey]); ok && v > 0 {
+		s.Reps = int(v)
+	}
+	if due, ok := node.Meta[srsDueKey].(string); ok {
+		if day, ok := parseMetaDay(due); ok {
+			s.Due = day
+		}
+	}
+	return s
+}
+
+// metaFloat accepts a number as decoded from JSON, as set in-process, or
+// as a string written by hand into meta.json.
+func metaFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Schedule applies one SM-2 review with grade to s and returns the new
+// state, due interval days after now.
+func (s SRSState) Schedule(grade int, now time.Time) SRSState {
+	next := s
+	if grade < 3 {
+		next.Reps = 0
+		next.Interval = 1
+	} else {
+		switch s.Reps {
+		case 0:
+			next.Interval = 1
+		case 1:
+			next.Interval = 6
+		default:
+			next.Interval = int(math.Round(float64(s.Interval) * s.Ease))
+		}
+		next.Reps = s.Reps + 1
+	}
+
+	q := float64(MaxGrade - grade)
+	next.Ease = s.Ease + 0.1 - q*(0.08+q*0.02)
+	if next.Ease < srsMinEase {
+		next.Ease = srsMinEase
+	}
+	next.Ease = math.Round(next.Ease*100) / 100
+
+	next.Due = now.In(time.Local).AddDate(0, 0, next.Interval).Format(DayLayout)
+	return next
+}
+
+// DueCards returns the sorted IDs of flashcards due on or before now's
+// local day. Cards that have never been reviewed are always due.
+func (r *Repository) DueCards(now time.Time) []string {
+	today := now.In(time.Local).Format(DayLayout)
+	var due []string
+	for _, id := range r.Search.FilterByType(FlashcardType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		if s := cardState(node); s.Due == "" || s.Due <= today {
+			due = append(due, id)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// ReviewCard records a review of flashcard id with grade (0-5), reschedules
+// it with SM-2 and commits.
+func (r *Repository) ReviewCard(id string, grade int, now time.Time) (*NodeEnvelope, error) {
+	if grade < 0 || grade > MaxGrade {
+		return nil, fmt.Errorf("grade %d out of range 0-%d", grade, MaxGrade)
+	}
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if node.Type != FlashcardType {
+		return nil, fmt.Errorf("node %s is a %s, not a %s", id, node.Type, FlashcardType)
+	}
+
+	next := cardState(node).Schedule(grade, now)
+	return r.updateMeta(id, map[string]interface{}{
+		srsEaseKey:     next.Ease,
+		srsIntervalKey: next.Interval,
+		srsRepsKey:     next.Reps,
+		srsDueKey:      next.Due,
+	}, fmt.Sprintf("review %s grade %d", id, grade))
+}
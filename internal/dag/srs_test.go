@@ -0,0 +1,87 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSRSState_ScheduleSM2(t *testing.T) {
+	now := time.Date(2025, 3, 14, 9, 0, 0, 0, time.Local)
+	s := SRSState{Ease: srsDefaultEase}
+
+	// Three good reviews: 1 day, 6 days, then interval × ease.
+	s = s.Schedule(4, now)
+	if s.Interval != 1 || s.Reps != 1 || s.Ease != 2.5 || s.Due != "2025-03-15" {
+		t.Fatalf("first review = %+v", s)
+	}
+	s = s.Schedule(5, now)
+	if s.Interval != 6 || s.Reps != 2 || s.Ease != 2.6 || s.Due != "2025-03-20" {
+		t.Fatalf("second review = %+v", s)
+	}
+	s = s.Schedule(3, now)
+	if s.Interval != 16 || s.Reps != 3 || s.Ease != 2.46 {
+		t.Fatalf("third review = %+v", s)
+	}
+
+	// A lapse restarts the interval and lowers the ease.
+	s = s.Schedule(1, now)
+	if s.Interval != 1 || s.Reps != 0 || s.Ease != 1.92 {
+		t.Fatalf("lapse = %+v", s)
+	}
+
+	// Ease never drops below the SM-2 floor.
+	for range 5 {
+		s = s.Schedule(0, now)
+	}
+	if s.Ease != srsMinEase {
+		t.Errorf("ease after repeated blackouts = %v, want %v", s.Ease, srsMinEase)
+	}
+}
+
+func TestReviewCard_ReschedulesAndCommits(t *testing.T) {
+	repo := openTestRepo(t)
+	now := time.Date(2025, 3, 14, 9, 0, 0, 0, time.Local)
+
+	repo.CreateNode("card:new", FlashcardType, []byte("Q?"), nil)
+	repo.CreateNode("card:later", FlashcardType, []byte("Q?"), map[string]interface{}{"due": "2025-04-01"})
+	repo.CreateNode("card:overdue", FlashcardType, []byte("Q?"), map[string]interface{}{"due": "2025-03-01"})
+	repo.CreateNode("note:a", "Note", nil, map[string]interface{}{"due": "2025-03-01"})
+
+	due := repo.DueCards(now)
+	if strings.Join(due, ",") != "card:new,card:overdue" {
+		t.Fatalf("DueCards = %v, want [card:new card:overdue]", due)
+	}
+
+	node, err := repo.ReviewCard("card:new", 4, now)
+	if err != nil {
+		t.Fatalf("ReviewCard: %v", err)
+	}
+	if node.Meta["due"] != "2025-03-15" || node.Meta["reps"] != 1 {
+		t.Errorf("meta after review = %v", node.Meta)
+	}
+	if due := repo.DueCards(now); len(due) != 1 || due[0] != "card:overdue" {
+		t.Errorf("DueCards after review = %v, want [card:overdue]", due)
+	}
+
+	head, err := repo.Commits.Log(1)
+	if err != nil || len(head) != 1 {
+		t.Fatalf("Log: %v, %v", head, err)
+	}
+	if head[0].Message != "review card:new grade 4" {
+		t.Errorf("commit message = %q", head[0].Message)
+	}
+
+	// The stored state round-trips through JSON as float64s.
+	reloaded, _ := repo.GetNode("card:new")
+	if s := cardState(reloaded); s.Reps != 1 || s.Interval != 1 || s.Ease != 2.5 {
+		t.Errorf("reloaded state = %+v", s)
+	}
+
+	if _, err := repo.ReviewCard("card:new", 6, now); err == nil {
+		t.Error("grade 6 accepted")
+	}
+	if _, err := repo.ReviewCard("note:a", 4, now); err == nil {
+		t.Error("reviewing a non-flashcard succeeded")
+	}
+}
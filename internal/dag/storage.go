@@ -0,0 +1,182 @@
+package dag
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// storageGrowthWindow is how far back the growth trend looks.
+const storageGrowthWindow = 30 * 24 * time.Hour
+
+// TypeStorage is the space taken by every node of one type.
+type TypeStorage struct {
+	Nodes        int   `json:"nodes"`         // refs, tombstones included
+	Bytes        int64 `json:"bytes"`         // current versions
+	HistoryBytes int64 `json:"history_bytes"` // every older version
+}
+
+// StorageReport is a breakdown of the object store and how fast it grows.
+type StorageReport struct {
+	Objects      int                    `json:"objects"`
+	Bytes        int64                  `json:"bytes"`
+	CurrentBytes int64                  `json:"current_bytes"` // node versions refs point at
+	HistoryBytes int64                  `json:"history_bytes"` // node versions reachable only through prev
+	OtherBytes   int64                  `json:"other_bytes"`   // commits and unreferenced objects
+	HistoryPct   float64                `json:"history_pct"`   // HistoryBytes as a share of Bytes
+	ByType       map[string]TypeStorage `json:"by_type"`
+
+	// GrowthPerDay is bytes written per day over the last 30 days (or since the
+	// first commit, if more recent), from the node versions and commit
+	// objects each commit in that window added.
+	GrowthPerDay  int64 `json:"growth_bytes_per_day"`
+	FreeBytes     int64 `json:"free_bytes"`                // available on the repo's filesystem
+	DaysUntilFull int   `json:"days_until_full,omitempty"` // at GrowthPerDay; omitted when not growing
+}
+
+// storageCache holds the last report, keyed by HEAD: every write that adds
+// objects also commits.
+type storageCache struct {
+	mu     sync.Mutex
+	key    string
+	report *StorageReport
+}
+
+// Storage returns the storage report, recomputing it only after a commit.
+func (r *Repository) Storage(ctx context.Context) (*StorageReport, error) {
+	key := r.headKey()
+	r.shared.storage.mu.Lock()
+	defer r.shared.storage.mu.Unlock()
+	if r.shared.storage.report == nil || r.shared.storage.key != key {
+		report, err := r.buildStorage(ctx, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		r.shared.storage.key, r.shared.storage.report = key, report
+	}
+	// Free space changes without commits; always report it fresh.
+	report := *r.shared.storage.report
+	report.FreeBytes = freeBytes(r.MxDir())
+	if report.GrowthPerDay > 0 {
+		report.DaysUntilFull = int(report.FreeBytes / report.GrowthPerDay)
+	}
+	return &report, nil
+}
+
+func (r *Repository) buildStorage(ctx context.Context, now time.Time) (*StorageReport, error) {
+	sizes, err := r.Store.Sizes()
+	if err != nil {
+		return nil, err
+	}
+	report := &StorageReport{Objects: len(sizes), ByType: make(map[string]TypeStorage)}
+	for _, n := range sizes {
+		report.Bytes += n
+	}
+
+	ids, err := r.Refs.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, err := r.Refs.Get(id)
+		if err != nil {
+			continue
+		}
+		name := CIDToFilename(c)
+		node, err := r.envelopeAt(name)
+		if err != nil {
+			continue
+		}
+		t := report.ByType[node.Type]
+		t.Nodes++
+		if !seen[name] {
+			seen[name] = true
+			t.Bytes += sizes[name]
+		}
+		// Walk the version chain. Identical versions of different nodes
+		// share an object and are counted once.
+		for prev := node.Prev; prev != "" && !seen[prev]; {
+			seen[prev] = true
+			t.HistoryBytes += sizes[prev]
+			older, err := r.envelopeAt(prev)
+			if err != nil {
+				break
+			}
+			prev = older.Prev
+		}
+		report.ByType[node.Type] = t
+	}
+	for _, t := range report.ByType {
+		report.CurrentBytes += t.Bytes
+		report.HistoryBytes += t.HistoryBytes
+	}
+	report.OtherBytes = report.Bytes - report.CurrentBytes - report.HistoryBytes
+	if report.Bytes > 0 {
+		report.HistoryPct = float64(report.HistoryBytes) * 100 / float64(report.Bytes)
+	}
+
+	growth, err := r.growthPerDay(ctx, now, sizes)
+	if err != nil {
+		return nil, err
+	}
+	report.GrowthPerDay = growth
+	return report, nil
+}
+
+// growthPerDay averages the bytes added by each commit in the growth
+// window: its own object plus the node versions it introduced.
+func (r *Repository) growthPerDay(ctx context.Context, now time.Time, sizes map[string]int64) (int64, error) {
+	commits, err := r.Commits.History(activityCommitLimit)
+	if err != nil || len(commits) == 0 {
+		return 0, err
+	}
+
+	since := now.Add(-storageGrowthWindow)
+	var added int64
+	oldest := now
+	for _, c := range commits {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if c.Timestamp.Before(since) {
+			break
+		}
+		oldest = c.Timestamp
+		added += sizes[c.CID]
+		for _, ch := range c.Changes {
+			if ch.New != "" {
+				added += sizes[ch.New]
+			}
+		}
+	}
+
+	days := now.Sub(oldest).Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	return int64(float64(added) / days), nil
+}
+
+// envelopeAt reads the node version stored under a base32 CID filename.
+func (r *Repository) envelopeAt(name string) (*NodeEnvelope, error) {
+	c, err := cidFromFilename(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.Store.GetNode(c)
+}
+
+// freeBytes returns the space available to unprivileged writes on the
+// filesystem holding dir, or 0 if it cannot be determined.
+func freeBytes(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
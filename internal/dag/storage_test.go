@@ -0,0 +1,43 @@
+package dag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStorage_SplitsCurrentAndHistory(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("first"), nil)
+	repo.UpdateContent("note:a", []byte("second"))
+	repo.UpdateContent("note:a", []byte("third"))
+	repo.CreateNode("person:alice", "Person", []byte("Alice"), nil)
+
+	report, err := repo.buildStorage(t.Context(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, _ := repo.Store.Sizes()
+	if report.Objects != len(sizes) {
+		t.Errorf("Objects = %d, want %d", report.Objects, len(sizes))
+	}
+	note, person := report.ByType["Note"], report.ByType["Person"]
+	if note.Nodes != 1 || note.Bytes == 0 || note.HistoryBytes == 0 {
+		t.Errorf("Note = %+v, want one node with current and history bytes", note)
+	}
+	if person.Nodes != 1 || person.HistoryBytes != 0 {
+		t.Errorf("Person = %+v, want one node without history", person)
+	}
+	// Commits are the only objects outside the node chains.
+	if report.OtherBytes <= 0 || report.CurrentBytes+report.HistoryBytes+report.OtherBytes != report.Bytes {
+		t.Errorf("bytes don't add up: %+v", report)
+	}
+	if report.HistoryPct <= 0 || report.HistoryPct >= 100 {
+		t.Errorf("HistoryPct = %v", report.HistoryPct)
+	}
+	// Everything was written just now, so growth is bounded by the repo's
+	// whole size over the one-day minimum.
+	if report.GrowthPerDay <= 0 || report.GrowthPerDay > report.Bytes {
+		t.Errorf("GrowthPerDay = %d, Bytes = %d", report.GrowthPerDay, report.Bytes)
+	}
+}
@@ -1,9 +1,12 @@
 package dag
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	gocid "github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multibase"
@@ -14,8 +17,16 @@ import (
 var CidUndef = gocid.Undef
 
 // ObjectStore manages CID-addressed immutable objects on disk.
+//
+// Objects live two directory levels down, objects/x/y/{cid}, so no
+// directory holds more than a small share of a large store. Stores written
+// before sharding keep objects directly in objects/; lookups fall back to
+// that flat layout, and Migrate moves such objects into place.
 type ObjectStore struct {
-	dir string // path to objects/ directory
+	dir    string        // path to objects/ directory
+	prefix gocid.Prefix  // format Put addresses new objects by
+	fetch  ObjectFetcher // fills in objects a partial pull left out; nil if none
+	shared bool          // an overlay's view of another store; see Delete
 }
 
 // NewObjectStore creates an ObjectStore at the given directory.
@@ -23,10 +34,24 @@ func NewObjectStore(dir string) (*ObjectStore, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("create objects dir: %w", err)
 	}
-	return &ObjectStore{dir: dir}, nil
+	prefix, _ := DefaultObjectFormat.prefix()
+	return &ObjectStore{dir: dir, prefix: prefix}, nil
 }
 
-// ComputeCID computes a CIDv1 (raw codec, SHA2-256) for the given data.
+// SetFormat makes Put address new objects by f. Objects already stored
+// keep their CIDs.
+func (s *ObjectStore) SetFormat(f ObjectFormat) error {
+	prefix, err := f.prefix()
+	if err != nil {
+		return err
+	}
+	s.prefix = prefix
+	return nil
+}
+
+// ComputeCID computes a CID in DefaultObjectFormat (CIDv1, raw codec,
+// SHA2-256) for the given data. Use CIDMatches to check data against a
+// CID of any format.
 func ComputeCID(data []byte) (gocid.Cid, error) {
 	mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
 	if err != nil {
@@ -41,36 +66,204 @@ func CIDToFilename(c gocid.Cid) string {
 	return encoded
 }
 
-// Put writes data to the object store, returning the CID.
-// If the object already exists, this is a no-op.
+// cidFromFilename parses a CIDToFilename string back into a CID.
+func cidFromFilename(name string) (gocid.Cid, error) {
+	_, cidBytes, err := multibase.Decode(name)
+	if err != nil {
+		return gocid.Undef, fmt.Errorf("decode CID %s: %w", name, err)
+	}
+	return gocid.Cast(cidBytes)
+}
+
+// objectShard returns the shard directories for an object filename. CIDs
+// of one format share their leading multibase, version, codec and hash
+// header, so the shard comes from the digest at the end; the final
+// character carries only padding bits and is skipped.
+func objectShard(name string) (string, bool) {
+	n := len(name)
+	if n < 4 {
+		return "", false
+	}
+	return filepath.Join(name[n-2:n-1], name[n-3:n-2]), true
+}
+
+// path returns where the object named name belongs.
+func (s *ObjectStore) path(name string) string {
+	if shard, ok := objectShard(name); ok {
+		return filepath.Join(s.dir, shard, name)
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// locate returns the path an existing object is stored at, checking the
+// flat layout after the sharded one.
+func (s *ObjectStore) locate(c gocid.Cid) (string, error) {
+	name := CIDToFilename(c)
+	path := s.path(name)
+	_, err := os.Stat(path)
+	if err == nil {
+		return path, nil
+	}
+	flat := filepath.Join(s.dir, name)
+	if _, ferr := os.Stat(flat); ferr == nil {
+		return flat, nil
+	}
+	return path, err
+}
+
+// Put writes data to the object store in the store's format, returning
+// the CID. If the object already exists, this is a no-op. Bare bytes are
+// never DAG-JSON, so a dag-json store puts them as raw blocks; node
+// versions and commits go through PutNode and PutCommit.
 func (s *ObjectStore) Put(data []byte) (gocid.Cid, error) {
-	c, err := ComputeCID(data)
+	prefix := s.prefix
+	if prefix.Codec == gocid.DagJSON {
+		prefix.Codec = gocid.Raw
+	}
+	c, err := prefix.Sum(data)
 	if err != nil {
-		return gocid.Undef, err
+		return gocid.Undef, fmt.Errorf("multihash: %w", err)
+	}
+	return c, s.write(c, data)
+}
+
+// PutCID writes data under c, which may be of any format, after checking
+// that data hashes to c. It is how objects made elsewhere — pulled or
+// imported — keep the CIDs that point at them.
+func (s *ObjectStore) PutCID(c gocid.Cid, data []byte) error {
+	if !CIDMatches(c, data) {
+		return fmt.Errorf("object does not match CID %s", CIDToFilename(c))
+	}
+	return s.write(c, data)
+}
+
+// write stores data as c unless it is already there.
+func (s *ObjectStore) write(c gocid.Cid, data []byte) error {
+	if s.Has(c) {
+		return nil // already exists
 	}
-	path := filepath.Join(s.dir, CIDToFilename(c))
-	if _, err := os.Stat(path); err == nil {
-		return c, nil // already exists
+	path := s.path(CIDToFilename(c))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create object shard: %w", err)
 	}
 	if err := SafeWrite(path, data, 0644); err != nil {
-		return gocid.Undef, fmt.Errorf("write object: %w", err)
+		return fmt.Errorf("write object: %w", err)
 	}
-	return c, nil
+	return nil
+}
+
+// SetFetcher makes Get fetch objects missing locally through fetch, and
+// keep them. Has still reports only what is local.
+func (s *ObjectStore) SetFetcher(fetch ObjectFetcher) {
+	s.fetch = fetch
 }
 
 // Get reads an object by CID.
 func (s *ObjectStore) Get(c gocid.Cid) ([]byte, error) {
-	path := filepath.Join(s.dir, CIDToFilename(c))
-	data, err := os.ReadFile(path)
+	name := CIDToFilename(c)
+	data, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		if flat, ferr := os.ReadFile(filepath.Join(s.dir, name)); ferr == nil {
+			return flat, nil
+		}
+		if s.fetch != nil {
+			return s.fetchMissing(c)
+		}
+		return nil, fmt.Errorf("object %w: %s", ErrNotFound, c)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("read object %s: %w", c, err)
 	}
 	return data, nil
 }
 
+// fetchMissing fetches c through s.fetch and stores it.
+func (s *ObjectStore) fetchMissing(c gocid.Cid) ([]byte, error) {
+	data, err := s.fetch(c)
+	if err != nil {
+		return nil, fmt.Errorf("fetch object %s: %w", c, err)
+	}
+	if err := s.PutCID(c, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // Has checks if an object exists.
 func (s *ObjectStore) Has(c gocid.Cid) bool {
-	path := filepath.Join(s.dir, CIDToFilename(c))
-	_, err := os.Stat(path)
+	_, err := s.locate(c)
 	return err == nil
 }
+
+// Delete removes an object. Deleting a missing object is a no-op. Objects
+// are shared by CID, so callers must know nothing else still needs it; an
+// overlay cannot know that of the store it shares, so there Delete keeps
+// the object.
+func (s *ObjectStore) Delete(c gocid.Cid) error {
+	if s.shared {
+		return nil
+	}
+	path, err := s.locate(c)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete object %s: %w", c, err)
+	}
+	return nil
+}
+
+// Migrate moves objects stored in the flat layout into their shards,
+// returning how many moved. Each move is a rename, so the store stays
+// readable throughout and an interrupted migration can simply be rerun.
+func (s *ObjectStore) Migrate(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("list objects: %w", err)
+	}
+	moved := 0
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		path := s.path(e.Name())
+		if path == filepath.Join(s.dir, e.Name()) {
+			continue // too short to shard
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return moved, fmt.Errorf("create object shard: %w", err)
+		}
+		if err := os.Rename(filepath.Join(s.dir, e.Name()), path); err != nil {
+			return moved, fmt.Errorf("migrate object %s: %w", e.Name(), err)
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// Sizes returns the size in bytes of every stored object, keyed by its
+// base32 CID filename, across both layouts.
+func (s *ObjectStore) Sizes() (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	err := filepath.WalkDir(s.dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil
+		}
+		sizes[e.Name()] = fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,55 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestObjectStore_ShardedWithFlatFallback(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewObjectStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded, err := store.Put([]byte("sharded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := CIDToFilename(sharded)
+	if _, err := os.Stat(filepath.Join(dir, name[len(name)-2:len(name)-1], name[len(name)-3:len(name)-2], name)); err != nil {
+		t.Errorf("object not sharded: %v", err)
+	}
+
+	// An object written by a store that predates sharding.
+	legacy, _ := ComputeCID([]byte("legacy"))
+	os.WriteFile(filepath.Join(dir, CIDToFilename(legacy)), []byte("legacy"), 0644)
+	if data, err := store.Get(legacy); err != nil || string(data) != "legacy" {
+		t.Errorf("flat Get = %q, %v", data, err)
+	}
+	if !store.Has(legacy) {
+		t.Error("flat object not found by Has")
+	}
+	// Re-putting a flat object must not store a second copy.
+	store.Put([]byte("legacy"))
+	if _, err := os.Stat(store.path(CIDToFilename(legacy))); !os.IsNotExist(err) {
+		t.Error("Put duplicated a flat object into its shard")
+	}
+	if sizes, _ := store.Sizes(); len(sizes) != 2 {
+		t.Errorf("Sizes = %v, want both layouts", sizes)
+	}
+
+	moved, err := store.Migrate(t.Context())
+	if err != nil || moved != 1 {
+		t.Fatalf("Migrate = %d, %v; want 1 moved", moved, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, CIDToFilename(legacy))); !os.IsNotExist(err) {
+		t.Error("flat object left behind")
+	}
+	if data, err := store.Get(legacy); err != nil || string(data) != "legacy" {
+		t.Errorf("Get after migration = %q, %v", data, err)
+	}
+	if err := store.Delete(legacy); err != nil || store.Has(legacy) {
+		t.Errorf("Delete: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SuggestedLinkType is the type of the link an accepted suggestion
+// creates when the user names none.
+const SuggestedLinkType = "related"
+
+// Suggestion signal weights. A term shared only by the two nodes counts
+// 1.0; a term shared with others counts less, and one on more than
+// suggestTermMaxDF nodes is too common to count at all.
+const (
+	weightSuggestTerm     = 1.0
+	weightSuggestRelated  = 1.0 // times log(1+relatedness score)
+	weightSuggestTag      = 2.0 // per shared tag
+	suggestTermMaxDF      = 25
+	minSuggestionScore    = 2.0
+	suggestCandidateLimit = 50
+)
+
+// LinkSuggestion is a node proposed as the target of a new link.
+type LinkSuggestion struct {
+	Target string
+	Score  float64
+}
+
+// RejectionSet records node pairs the user declined to link, so they are
+// not suggested again, as a JSON object (node ID → IDs) at
+// .mx/rejected-suggestions.json. Like pins it is user preference, kept
+// beside the repo rather than in commits. A rejection holds in both
+// directions.
+type RejectionSet struct {
+	mu    sync.RWMutex
+	path  string
+	pairs map[string][]string
+}
+
+// NewRejectionSet loads the rejections at path. A missing file is an
+// empty set.
+func NewRejectionSet(path string) (*RejectionSet, error) {
+	s := &RejectionSet{path: path, pairs: make(map[string][]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read rejected suggestions: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.pairs); err != nil {
+		return nil, fmt.Errorf("parse rejected suggestions: %w", err)
+	}
+	return s, nil
+}
+
+// Add records that source and target should not be linked.
+func (s *RejectionSet) Add(source, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.has(source, target) {
+		return nil
+	}
+	s.pairs[source] = append(s.pairs[source], target)
+	data, err := json.MarshalIndent(s.pairs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(s.path, append(data, '\n'), 0644)
+}
+
+// Has reports whether linking a and b was rejected, either way round.
+func (s *RejectionSet) Has(a, b string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.has(a, b)
+}
+
+func (s *RejectionSet) has(a, b string) bool {
+	return slices.Contains(s.pairs[a], b) || slices.Contains(s.pairs[b], a)
+}
+
+// SuggestLinks proposes up to limit nodes to link id to, best first. A
+// candidate scores for the rare search terms it shares with id's content
+// and tags, for how related the two are by access, edits and association
+// links (RelatedScored), and for each tag they share. Nodes already linked
+// to id either way, and pairs the user rejected, are never suggested.
+func (r *Repository) SuggestLinks(id string, limit int) []LinkSuggestion {
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil
+	}
+	tags := metaStrings(node.Meta[MetaTags])
+	terms := tokenize(string(node.Content) + " " + strings.Join(tags, " "))
+	scores := r.Search.sharedTerms(terms, suggestTermMaxDF)
+	for peer, s := range scores {
+		scores[peer] = s * weightSuggestTerm
+	}
+	for _, s := range r.Relatedness.RelatedScored(id, suggestCandidateLimit) {
+		scores[s.ID] += weightSuggestRelated * math.Log1p(s.Score)
+	}
+	if r.Meta.Indexed(MetaTags) {
+		for _, tag := range tags {
+			for _, peer := range r.Meta.Exact(MetaTags, tag) {
+				if _, ok := scores[peer]; !ok {
+					scores[peer] = 0 // scored for the tag below
+				}
+			}
+		}
+	}
+
+	linked := map[string]bool{id: true}
+	for _, l := range r.Links.AllLinks(id) {
+		linked[LinkTargetParent(l.Target)] = true
+		linked[l.Source] = true
+	}
+	var out []LinkSuggestion
+	for peer, score := range scores {
+		if linked[peer] || r.Rejections.Has(id, peer) {
+			continue
+		}
+		other, err := r.GetNode(peer)
+		if err != nil {
+			continue
+		}
+		for _, tag := range metaStrings(other.Meta[MetaTags]) {
+			if slices.Contains(tags, tag) {
+				score += weightSuggestTag
+			}
+		}
+		if score >= minSuggestionScore {
+			out = append(out, LinkSuggestion{Target: peer, Score: score})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Target < out[j].Target
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// AcceptSuggestion links source to target with linkType, or
+// SuggestedLinkType when linkType is empty.
+func (r *Repository) AcceptSuggestion(source, target, linkType string) error {
+	if linkType == "" {
+		linkType = SuggestedLinkType
+	}
+	if _, err := r.GetNode(target); err != nil {
+		return err
+	}
+	return r.CreateLink(source, target, linkType)
+}
+
+// RejectSuggestion records that source and target should not be linked,
+// so neither is suggested for the other again.
+func (r *Repository) RejectSuggestion(source, target string) error {
+	return r.Rejections.Add(source, target)
+}
@@ -0,0 +1,47 @@
+package dag
+
+import (
+	"testing"
+)
+
+func TestSuggestLinks(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("zeppelin mooring mast at lakehurst"), nil)
+	repo.CreateNode("note:b", "Note", []byte("the lakehurst mooring mast"), nil)
+	repo.CreateNode("note:c", "Note", []byte("a zeppelin"), nil)
+	repo.CreateNode("note:d", "Note", []byte("tomatoes"), map[string]interface{}{MetaTags: []interface{}{"airships"}})
+	repo.CreateNode("note:e", "Note", []byte("roses"), map[string]interface{}{MetaTags: "airships"})
+
+	got := repo.SuggestLinks("note:a", 0)
+	if len(got) != 1 || got[0].Target != "note:b" {
+		t.Fatalf("SuggestLinks(note:a) = %+v, want only note:b", got)
+	}
+	// A shared tag relates nodes with nothing else in common.
+	if got := repo.SuggestLinks("note:d", 0); len(got) != 1 || got[0].Target != "note:e" {
+		t.Errorf("SuggestLinks(note:d) = %+v", got)
+	}
+
+	if err := repo.AcceptSuggestion("note:a", "note:b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if links := repo.Links.LinksFrom("note:a"); len(links) != 1 || links[0].Type != SuggestedLinkType {
+		t.Errorf("links = %+v", links)
+	}
+	if got := repo.SuggestLinks("note:b", 0); len(got) != 0 {
+		t.Errorf("linked node still suggested: %+v", got)
+	}
+
+	if err := repo.RejectSuggestion("note:e", "note:d"); err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.SuggestLinks("note:d", 0); len(got) != 0 {
+		t.Errorf("rejected pair suggested: %+v", got)
+	}
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Rejections.Has("note:d", "note:e") {
+		t.Error("rejection not persisted")
+	}
+}
@@ -0,0 +1,166 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Summary nodes hold an LLM-written summary of another node, linked to it
+// by a SUMMARIZES link and refreshed when the source changes materially.
+const (
+	SummaryType    = "Summary"
+	LinkSummarizes = "SUMMARIZES"
+)
+
+const (
+	// summaryRefreshThreshold is the share of distinct terms that must
+	// differ between the summarized version and the current one before a
+	// summary is regenerated.
+	summaryRefreshThreshold = 0.2
+	// maxSummaryInput bounds the content sent to the model.
+	maxSummaryInput = 64 << 10
+
+	summaryPrompt = "Summarize the following note in one short paragraph. " +
+		"Keep names, dates and decisions. Reply with the summary only."
+)
+
+// SummaryID returns the ID of the Summary node for id.
+func SummaryID(id string) string {
+	return "summary:" + id
+}
+
+// Summarizer writes Summary nodes using the repository's LLM. Besides
+// explicit Summarize calls, content writes trigger it in the background:
+// a node without a summary is summarized once its content reaches the
+// configured minimum length, and an existing summary is regenerated when
+// its source has drifted past summaryRefreshThreshold. Deleting a summary
+// stops automatic summaries for its source.
+type Summarizer struct {
+	repo     *Repository
+	minBytes int // 0 disables automatic summaries of new content
+
+	wg sync.WaitGroup // background summaries
+}
+
+// NewSummarizer creates a Summarizer for repo. Nodes with at least
+// minBytes of content are summarized automatically; 0 disables that.
+func NewSummarizer(repo *Repository, minBytes int) *Summarizer {
+	return &Summarizer{repo: repo, minBytes: minBytes}
+}
+
+// Summarize generates or regenerates id's Summary node.
+func (s *Summarizer) Summarize(ctx context.Context, id string) (*NodeEnvelope, error) {
+	r := s.repo
+	if r.LLM == nil {
+		return nil, ErrNoLLM
+	}
+	source, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if source.Type == SummaryType {
+		return nil, fmt.Errorf("%s is a summary", id)
+	}
+	version, err := r.Refs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	input := source.Content
+	if len(input) > maxSummaryInput {
+		input = input[:maxSummaryInput]
+	}
+	text, err := r.LLM.Complete(ctx, summaryPrompt, string(input))
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("summarize %s: empty reply", id)
+	}
+
+	sid := SummaryID(id)
+	unlock := r.lockNode(sid)
+	defer unlock()
+	meta := map[string]interface{}{
+		"source":         id,
+		"source_version": CIDToFilename(version),
+	}
+	if _, err := r.GetNode(sid); err == nil {
+		return r.updateContent(sid, []byte(text), meta)
+	}
+	node, err := r.createNode(sid, SummaryType, []byte(text), meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.CreateLink(sid, id, LinkSummarizes); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// onWrite starts a background summary of node when it needs one. It is
+// called after every content write.
+func (s *Summarizer) onWrite(node *NodeEnvelope) {
+	r := s.repo
+	if r.LLM == nil || node.Type == SummaryType {
+		return
+	}
+	summary, err := r.getNodeEnvelope(SummaryID(node.ID))
+	switch {
+	case err == nil && summary.Deleted:
+		return
+	case err == nil:
+		if !s.stale(summary, node) {
+			return
+		}
+	case s.minBytes <= 0 || len(node.Content) < s.minBytes:
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if _, err := s.Summarize(context.Background(), node.ID); err != nil {
+			fmt.Printf("memex-fs: summarize %s: %v\n", node.ID, err)
+		}
+	}()
+}
+
+// stale reports whether source has changed materially since summary was
+// written.
+func (s *Summarizer) stale(summary, source *NodeEnvelope) bool {
+	version, _ := summary.Meta["source_version"].(string)
+	prev, err := s.repo.envelopeAt(version)
+	if err != nil {
+		return true // unknown or pruned: regenerate to be safe
+	}
+	return contentChange(prev.Content, source.Content) >= summaryRefreshThreshold
+}
+
+// contentChange is the Jaccard distance between the term sets of a and b:
+// 0 for the same vocabulary, 1 for nothing in common.
+func contentChange(a, b []byte) float64 {
+	ta, tb := tokenize(string(a)), tokenize(string(b))
+	if len(ta)+len(tb) == 0 {
+		return 0
+	}
+	terms := make(map[string]bool, len(ta))
+	for _, t := range ta {
+		terms[t] = true
+	}
+	shared := 0
+	for _, t := range tb {
+		if terms[t] {
+			shared++
+		}
+	}
+	return 1 - float64(shared)/float64(len(ta)+len(tb)-shared)
+}
+
+// Wait blocks until every background summary started so far has finished.
+func (s *Summarizer) Wait() {
+	s.wg.Wait()
+}
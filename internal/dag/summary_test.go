@@ -0,0 +1,111 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoLLM replies with the first words of the prompt and counts calls.
+type echoLLM struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (l *echoLLM) Complete(ctx context.Context, system, prompt string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	words := strings.Fields(prompt)
+	return fmt.Sprintf("summary #%d: %s", l.calls, strings.Join(words[:min(3, len(words))], " ")), nil
+}
+
+func (l *echoLLM) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls
+}
+
+func TestSummarize_CreatesLinkedSummary(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.Summarizer.Summarize(t.Context(), "note:a"); !errors.Is(err, ErrNoLLM) {
+		t.Fatalf("without LLM: err = %v, want ErrNoLLM", err)
+	}
+	repo.LLM = &echoLLM{}
+
+	repo.CreateNode("note:a", "Note", []byte("alpha beta gamma delta"), nil)
+	summary, err := repo.Summarizer.Summarize(t.Context(), "note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != "summary:note:a" || summary.Type != SummaryType {
+		t.Errorf("summary = %s (%s)", summary.ID, summary.Type)
+	}
+	if got := string(summary.Content); got != "summary #1: alpha beta gamma" {
+		t.Errorf("content = %q", got)
+	}
+	links := repo.Links.LinksFrom(summary.ID)
+	if len(links) != 1 || links[0].Target != "note:a" || links[0].Type != LinkSummarizes {
+		t.Errorf("links = %+v", links)
+	}
+	if _, err := repo.Summarizer.Summarize(t.Context(), summary.ID); err == nil {
+		t.Error("summarizing a summary should fail")
+	}
+}
+
+func TestSummarizer_AutoAndRefresh(t *testing.T) {
+	repo := openTestRepo(t)
+	llm := &echoLLM{}
+	repo.LLM = llm
+	repo.Summarizer.minBytes = 20
+
+	repo.CreateNode("note:short", "Note", []byte("tiny"), nil)
+	repo.CreateNode("note:long", "Note", []byte("one two three four five six seven eight nine ten"), nil)
+	repo.Summarizer.Wait()
+	if _, err := repo.GetNode(SummaryID("note:short")); err == nil {
+		t.Error("short node was summarized")
+	}
+	if _, err := repo.GetNode(SummaryID("note:long")); err != nil {
+		t.Fatalf("long node not summarized: %v", err)
+	}
+
+	// One new word in ten is below the refresh threshold.
+	repo.UpdateContent("note:long", []byte("one two three four five six seven eight nine ten eleven"))
+	repo.Summarizer.Wait()
+	if llm.count() != 1 {
+		t.Errorf("minor edit: %d LLM calls, want 1", llm.count())
+	}
+
+	repo.UpdateContent("note:long", []byte("completely different text about something else entirely"))
+	repo.Summarizer.Wait()
+	summary, _ := repo.GetNode(SummaryID("note:long"))
+	if llm.count() != 2 || !strings.HasPrefix(string(summary.Content), "summary #2: completely") {
+		t.Errorf("after rewrite: %d calls, summary %q", llm.count(), summary.Content)
+	}
+
+	repo.DeleteNode(SummaryID("note:long"), false)
+	repo.UpdateContent("note:long", []byte("yet another unrelated rewrite of the whole note"))
+	repo.Summarizer.Wait()
+	if llm.count() != 2 {
+		t.Errorf("deleted summary was regenerated")
+	}
+}
+
+func TestContentChange(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 0},
+		{"alpha beta", "beta alpha", 0},
+		{"alpha beta", "gamma delta", 1},
+		{"alpha beta gamma", "alpha beta delta", 0.5},
+	} {
+		if got := contentChange([]byte(tc.a), []byte(tc.b)); got != tc.want {
+			t.Errorf("contentChange(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
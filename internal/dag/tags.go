@@ -0,0 +1,242 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Auto-tag name prefixes. Daily tags name the day they close; weekly tags
+// name the Sunday that closes the week.
+const (
+	dailyTagPrefix  = "daily-"
+	weeklyTagPrefix = "weekly-"
+)
+
+// Tag is a name for a commit, like a git tag.
+type Tag struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"` // base32 CID
+}
+
+// TagSet holds the repo's tags as a JSON object (name → commit CID) at
+// .mx/tags.json. Like pins they are labels on history rather than history
+// itself, so they live beside the commit chain, not in it.
+type TagSet struct {
+	mu   sync.RWMutex
+	path string
+	tags map[string]string
+}
+
+// NewTagSet loads the tags at path. A missing file is an empty set.
+func NewTagSet(path string) (*TagSet, error) {
+	t := &TagSet{path: path, tags: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read tags: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.tags); err != nil {
+		return nil, fmt.Errorf("parse tags: %w", err)
+	}
+	return t, nil
+}
+
+func (t *TagSet) save() error {
+	data, err := json.MarshalIndent(t.tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(t.path, append(data, '\n'), 0644)
+}
+
+// validTagName rejects names that can't be a single path segment under
+// /log/tags/ and /at/.
+func validTagName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return errorOf(ErrValidation, "invalid tag name %q", name)
+	}
+	return nil
+}
+
+// Get returns the commit CID tagged name.
+func (t *TagSet) Get(name string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c, ok := t.tags[name]
+	return c, ok
+}
+
+// Delete removes a tag. Returns false if it did not exist.
+func (t *TagSet) Delete(name string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.tags[name]; !ok {
+		return false, nil
+	}
+	delete(t.tags, name)
+	return true, t.save()
+}
+
+// List returns every tag sorted by name.
+func (t *TagSet) List() []Tag {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tags := make([]Tag, 0, len(t.tags))
+	for name, c := range t.tags {
+		tags = append(tags, Tag{Name: name, Commit: c})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags
+}
+
+// set records name → commit, replacing any existing tag when force is set.
+// Reports whether the tag was written.
+func (t *TagSet) set(name, commit string, force bool) (bool, error) {
+	if err := validTagName(name); err != nil {
+		return false, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.tags[name]; ok && (!force || old == commit) {
+		return false, nil
+	}
+	t.tags[name] = commit
+	return true, t.save()
+}
+
+// Tag names the commit key resolves to (a tag, commit CID or RFC3339
+// timestamp; "" for HEAD). An existing tag is only moved when force is set,
+// and a protected one never.
+func (r *Repository) Tag(name, key string, force bool) (*Tag, error) {
+	if key == "" {
+		key = r.headKey()
+		if key == "" {
+			return nil, fmt.Errorf("no commits yet")
+		}
+	}
+	c, _, err := r.resolveCID(key)
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok := r.Tags.Get(name); ok && existing != c {
+		if !force {
+			return nil, errorOf(ErrConflict, "tag %s already exists", name)
+		}
+		if err := r.checkTagUnprotected(name); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := r.Tags.set(name, c, force); err != nil {
+		return nil, err
+	}
+	return &Tag{Name: name, Commit: c}, nil
+}
+
+// DeleteTag removes the tag name unless it is protected. Returns false if
+// it did not exist.
+func (r *Repository) DeleteTag(name string) (bool, error) {
+	if err := r.checkTagUnprotected(name); err != nil {
+		return false, err
+	}
+	return r.Tags.Delete(name)
+}
+
+// checkTagUnprotected fails with ErrProtected if a spec protects name.
+func (r *Repository) checkTagUnprotected(name string) error {
+	protected, err := r.tagProtected(name)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return fmt.Errorf("tag %s: %w", name, ErrProtected)
+	}
+	return nil
+}
+
+// ResolveCommit resolves key to a commit: a tag name first, then anything
+// CommitLog.Resolve accepts.
+func (r *Repository) ResolveCommit(key string) (*CommitObject, error) {
+	_, commit, err := r.resolveCID(key)
+	return commit, err
+}
+
+func (r *Repository) resolveCID(key string) (string, *CommitObject, error) {
+	if tagged, ok := r.Tags.Get(key); ok {
+		key = tagged
+	}
+	c, commit, err := r.Commits.ResolveCID(key)
+	if err != nil {
+		return "", nil, err
+	}
+	return CIDToFilename(c), commit, nil
+}
+
+// AutoTag creates the snapshot tags due at now: daily-{yesterday} on the
+// last commit of yesterday, and weekly-{last Sunday} on the last commit of
+// the most recent Sunday before today. Existing tags are left alone, and a
+// day with no commit at or before its end gets no tag. Returns the names
+// created.
+func (r *Repository) AutoTag(now time.Time) ([]string, error) {
+	now = now.In(time.Local)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	back := int(today.Weekday())
+	if back == 0 {
+		back = 7
+	}
+	sunday := today.AddDate(0, 0, -back)
+
+	var created []string
+	for _, snap := range []struct {
+		name string
+		day  time.Time
+	}{
+		{dailyTagPrefix + yesterday.Format(DayLayout), yesterday},
+		{weeklyTagPrefix + sunday.Format(DayLayout), sunday},
+	} {
+		if _, ok := r.Tags.Get(snap.name); ok {
+			continue
+		}
+		c, _, err := r.Commits.At(snap.day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+		if err != nil {
+			continue
+		}
+		ok, err := r.Tags.set(snap.name, CIDToFilename(c), false)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created = append(created, snap.name)
+		}
+	}
+	return created, nil
+}
+
+// StartAutoTag runs AutoTag every interval until the returned stop
+// function is called. Failures are logged and retried on the next tick.
+func (r *Repository) StartAutoTag(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.AutoTag(time.Now()); err != nil {
+				fmt.Printf("memex-fs: auto-tag warning: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
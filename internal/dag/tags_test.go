@@ -0,0 +1,87 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTag_ResolvesThroughAt(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("v1"), nil)
+	first := repo.headKey()
+	repo.UpdateContent("note:a", []byte("v2"))
+
+	if _, err := repo.Tag("before-edit", first, false); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	commit, err := repo.ResolveCommit("before-edit")
+	if err != nil {
+		t.Fatalf("ResolveCommit: %v", err)
+	}
+	if commit.Message != "create note:a" {
+		t.Errorf("tag resolved to %q, want the create commit", commit.Message)
+	}
+
+	if _, err := repo.Tag("before-edit", "", false); err == nil {
+		t.Error("moving a tag without force succeeded")
+	}
+	if _, err := repo.Tag("before-edit", "", true); err != nil {
+		t.Fatalf("Tag force: %v", err)
+	}
+	if c, _ := repo.Tags.Get("before-edit"); c != repo.headKey() {
+		t.Errorf("forced tag = %s, want HEAD", c)
+	}
+	if _, err := repo.Tag("a/b", "", false); err == nil {
+		t.Error("tag name with a slash accepted")
+	}
+
+	// Tags survive a reopen.
+	reopened, err := OpenRepository(repo.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Tags.List(); len(got) != 1 || got[0].Name != "before-edit" {
+		t.Errorf("tags after reopen = %v", got)
+	}
+}
+
+func TestAutoTag_DailyAndWeekly(t *testing.T) {
+	repo := openTestRepo(t)
+
+	// Before the first commit there is nothing to tag.
+	if created, err := repo.AutoTag(time.Now()); err != nil || len(created) != 0 {
+		t.Fatalf("AutoTag on empty repo = %v, %v", created, err)
+	}
+
+	repo.CreateNode("note:a", "Note", nil, nil)
+	head := repo.headKey()
+
+	// The first Monday at least a week out: yesterday is a Sunday after
+	// every commit, so both tags land on HEAD.
+	monday := time.Now().AddDate(0, 0, 7)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	sunday := monday.AddDate(0, 0, -1).Format(DayLayout)
+
+	created, err := repo.AutoTag(monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"daily-" + sunday, "weekly-" + sunday}
+	if !reflect.DeepEqual(created, want) {
+		t.Fatalf("AutoTag created %v, want %v", created, want)
+	}
+	for _, name := range want {
+		if c, _ := repo.Tags.Get(name); c != head {
+			t.Errorf("%s = %s, want HEAD %s", name, c, head)
+		}
+	}
+
+	// Later ticks the same day leave the tags alone.
+	repo.UpdateContent("note:a", []byte("later"))
+	if created, _ := repo.AutoTag(monday.Add(time.Hour)); len(created) != 0 {
+		t.Errorf("second AutoTag created %v", created)
+	}
+}
@@ -0,0 +1,95 @@
+package dag
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// TaskType is the node type the /tasks/ views work on.
+const TaskType = "Task"
+
+// taskStatusKey and taskDueKey are the Task meta fields. A task without a
+// status is open; due is a YYYY-MM-DD day or RFC3339 timestamp.
+const (
+	taskStatusKey = "status"
+	taskDueKey    = "due"
+)
+
+// TaskStatuses are the workflow states a task moves through, in order.
+var TaskStatuses = []string{"open", "doing", "done"}
+
+// TaskStatus returns node's workflow state.
+func TaskStatus(node *NodeEnvelope) string {
+	if s, ok := node.Meta[taskStatusKey].(string); ok && s != "" {
+		return s
+	}
+	return TaskStatuses[0]
+}
+
+// TasksByStatus returns the sorted IDs of live tasks in status.
+func (r *Repository) TasksByStatus(status string) []string {
+	var ids []string
+	for _, id := range r.Search.FilterByType(TaskType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil {
+			continue
+		}
+		if TaskStatus(node) == status {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// UpcomingTask is a task that is not done and has a due day.
+type UpcomingTask struct {
+	ID  string
+	Due string // YYYY-MM-DD, local
+}
+
+// UpcomingTasks returns every unfinished task with a due day, soonest
+// first. Overdue tasks are included, ahead of everything else.
+func (r *Repository) UpcomingTasks() []UpcomingTask {
+	var tasks []UpcomingTask
+	for _, id := range r.Search.FilterByType(TaskType, 0) {
+		node, err := r.GetNode(id)
+		if err != nil || TaskStatus(node) == "done" {
+			continue
+		}
+		s, _ := node.Meta[taskDueKey].(string)
+		if due, ok := parseMetaDay(s); ok {
+			tasks = append(tasks, UpcomingTask{ID: id, Due: due})
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Due != tasks[j].Due {
+			return tasks[i].Due < tasks[j].Due
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// SetTaskStatus moves task id to status and commits.
+func (r *Repository) SetTaskStatus(id, status string) (*NodeEnvelope, error) {
+	if !slices.Contains(TaskStatuses, status) {
+		return nil, fmt.Errorf("unknown task status %q", status)
+	}
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if node.Type != TaskType {
+		return nil, fmt.Errorf("node %s is a %s, not a %s", id, node.Type, TaskType)
+	}
+	if TaskStatus(node) == status {
+		return node, nil
+	}
+	return r.updateMeta(id, map[string]interface{}{taskStatusKey: status},
+		fmt.Sprintf("task %s %s", id, status))
+}
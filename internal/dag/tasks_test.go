@@ -0,0 +1,41 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTasks_StatusAndUpcoming(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.CreateNode("task:write", TaskType, nil, map[string]interface{}{"due": "2025-03-20"})
+	repo.CreateNode("task:review", TaskType, nil, map[string]interface{}{"status": "doing", "due": "2025-03-10"})
+	repo.CreateNode("task:ship", TaskType, nil, map[string]interface{}{"status": "done", "due": "2025-03-01"})
+	repo.CreateNode("task:someday", TaskType, nil, nil)
+	repo.CreateNode("note:a", "Note", nil, map[string]interface{}{"due": "2025-03-01"})
+
+	if got := repo.TasksByStatus("open"); !reflect.DeepEqual(got, []string{"task:someday", "task:write"}) {
+		t.Errorf("open = %v", got)
+	}
+	want := []UpcomingTask{{"task:review", "2025-03-10"}, {"task:write", "2025-03-20"}}
+	if got := repo.UpcomingTasks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UpcomingTasks = %v, want %v", got, want)
+	}
+
+	if _, err := repo.SetTaskStatus("task:write", "done"); err != nil {
+		t.Fatalf("SetTaskStatus: %v", err)
+	}
+	if got := repo.TasksByStatus("done"); !reflect.DeepEqual(got, []string{"task:ship", "task:write"}) {
+		t.Errorf("done = %v", got)
+	}
+	head, _ := repo.Commits.Log(1)
+	if len(head) != 1 || head[0].Message != "task task:write done" {
+		t.Errorf("head commit = %+v", head)
+	}
+
+	if _, err := repo.SetTaskStatus("task:write", "blocked"); err == nil {
+		t.Error("unknown status accepted")
+	}
+	if _, err := repo.SetTaskStatus("note:a", "done"); err == nil {
+		t.Error("non-task accepted")
+	}
+}
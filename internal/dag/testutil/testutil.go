@@ -0,0 +1,282 @@
+// Package testutil generates synthetic repositories for benchmarks, fuzz
+// tests and the FUSE integration harness. Everything Generate writes is a
+// function of its Spec: the same seed yields the same nodes, links,
+// history and access log, down to the HEAD CID, so scale behavior can be
+// reproduced exactly.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Types are the node types of generated nodes, assigned round-robin by
+// index.
+var Types = []string{"Note", "Person", "Project", "Task"}
+
+// LinkTypes are the types of generated links.
+var LinkTypes = []string{"cites", "mentions", "related"}
+
+// Words is the vocabulary of generated content. It is small so that
+// searches hit a realistic fraction of a large repo.
+var Words = []string{
+	"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel",
+	"india", "juliet", "kilo", "lima", "mike", "november", "oscar", "papa",
+}
+
+// DefaultStart is the first commit's time when Spec.Start is zero.
+var DefaultStart = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// Spec describes a synthetic repository.
+type Spec struct {
+	Seed  uint64
+	Nodes int
+	// Links is the number of distinct links between random nodes. It is
+	// capped by what the node count allows.
+	Links int
+	// Commits is the number of history commits after the initial one.
+	// Each rewrites the content of a few random nodes, an hour apart.
+	Commits int
+	// Accesses is the number of access log entries, in sessions of
+	// related reads after the last commit.
+	Accesses int
+	// Start is the time of the initial commit; zero means DefaultStart.
+	Start time.Time
+}
+
+// NodeID returns the ID of the i-th generated node, e.g. note:0000004.
+func NodeID(i int) string {
+	return fmt.Sprintf("%s:%07d", strings.ToLower(Types[i%len(Types)]), i)
+}
+
+// Generate writes the repository described by spec into dir, which must
+// be empty or absent. Open it with dag.OpenRepository afterwards; the
+// in-memory indexes are built on open.
+func Generate(dir string, spec Spec) error {
+	repo, err := dag.OpenRepository(dir)
+	if err != nil {
+		return err
+	}
+	g := &generator{
+		repo:    repo,
+		rng:     rand.New(rand.NewPCG(spec.Seed, spec.Seed^0x9e3779b97f4a7c15)),
+		refs:    make(map[string]string, spec.Nodes),
+		nodes:   make([]*dag.NodeEnvelope, spec.Nodes),
+		forward: make(map[string][]string),
+		now:     spec.Start,
+	}
+	if g.now.IsZero() {
+		g.now = DefaultStart
+	}
+
+	for i := range spec.Nodes {
+		node := &dag.NodeEnvelope{
+			V:        1,
+			ID:       NodeID(i),
+			Type:     Types[i%len(Types)],
+			Content:  []byte(g.sentence(3, 8)),
+			Meta:     map[string]interface{}{"n": float64(i)},
+			Created:  g.now,
+			Modified: g.now,
+		}
+		if err := g.put(i, node); err != nil {
+			return err
+		}
+	}
+	if err := g.addLinks(spec.Links); err != nil {
+		return err
+	}
+	if err := g.commit("seed"); err != nil {
+		return err
+	}
+
+	for range spec.Commits {
+		if spec.Nodes == 0 {
+			break
+		}
+		g.now = g.now.Add(time.Hour)
+		batch := g.sample(min(1+g.rng.IntN(4), spec.Nodes))
+		ids := make([]string, len(batch))
+		for j, i := range batch {
+			prev := *g.nodes[i]
+			next := prev
+			next.Content = append(bytes.Clone(prev.Content), " "+g.sentence(1, 3)...)
+			next.Modified = g.now
+			next.Prev = g.refs[prev.ID]
+			if err := g.put(i, &next); err != nil {
+				return err
+			}
+			ids[j] = prev.ID
+		}
+		if err := g.commit("update content " + strings.Join(ids, ", ")); err != nil {
+			return err
+		}
+	}
+
+	return g.accesses(filepath.Join(repo.MxDir(), "access.jsonl"), spec.Accesses)
+}
+
+// Open generates spec into a temporary directory and opens it, failing tb
+// on any error.
+func Open(tb testing.TB, spec Spec) *dag.Repository {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := Generate(dir, spec); err != nil {
+		tb.Fatalf("generate repo: %v", err)
+	}
+	repo, err := dag.OpenRepository(dir)
+	if err != nil {
+		tb.Fatalf("open generated repo: %v", err)
+	}
+	return repo
+}
+
+type generator struct {
+	repo    *dag.Repository
+	rng     *rand.Rand
+	refs    map[string]string // id → current version CID, for commits
+	nodes   []*dag.NodeEnvelope
+	links   []dag.LinkEntry     // sorted as CommitLog sorts them
+	forward map[string][]string // source → targets
+	now     time.Time
+}
+
+func (g *generator) sentence(minWords, maxWords int) string {
+	n := minWords + g.rng.IntN(maxWords-minWords+1)
+	words := make([]string, n)
+	for i := range words {
+		words[i] = Words[g.rng.IntN(len(Words))]
+	}
+	return strings.Join(words, " ")
+}
+
+// sample returns n distinct node indexes.
+func (g *generator) sample(n int) []int {
+	picked := make([]int, 0, n)
+	for len(picked) < n {
+		i := g.rng.IntN(len(g.nodes))
+		if !slices.Contains(picked, i) {
+			picked = append(picked, i)
+		}
+	}
+	return picked
+}
+
+func (g *generator) put(i int, node *dag.NodeEnvelope) error {
+	c, err := g.repo.Store.PutNode(node)
+	if err != nil {
+		return err
+	}
+	if err := g.repo.Refs.Set(node.ID, c); err != nil {
+		return err
+	}
+	g.nodes[i] = node
+	g.refs[node.ID] = dag.CIDToFilename(c)
+	return nil
+}
+
+// addLinks picks n distinct links and writes them as the link journal in one
+// go; LinkIndex.Add would fsync per link.
+func (g *generator) addLinks(n int) error {
+	nodes := len(g.nodes)
+	n = min(n, nodes*(nodes-1)*len(LinkTypes))
+	seen := make(map[dag.LinkEntry]bool, n)
+	var journal bytes.Buffer
+	for len(seen) < n {
+		src, dst := g.rng.IntN(nodes), g.rng.IntN(nodes)
+		link := dag.LinkEntry{
+			Source: NodeID(src),
+			Target: NodeID(dst),
+			Type:   LinkTypes[g.rng.IntN(len(LinkTypes))],
+		}
+		if src == dst || seen[link] {
+			continue
+		}
+		seen[link] = true
+		g.links = append(g.links, link)
+		g.forward[link.Source] = append(g.forward[link.Source], link.Target)
+		data, err := json.Marshal(link)
+		if err != nil {
+			return err
+		}
+		journal.Write(append(data, '\n'))
+	}
+	sort.Slice(g.links, func(i, j int) bool {
+		if g.links[i].Source != g.links[j].Source {
+			return g.links[i].Source < g.links[j].Source
+		}
+		if g.links[i].Target != g.links[j].Target {
+			return g.links[i].Target < g.links[j].Target
+		}
+		return g.links[i].Type < g.links[j].Type
+	})
+	return os.WriteFile(filepath.Join(g.repo.MxDir(), "links.jsonl"), journal.Bytes(), 0644)
+}
+
+// commit writes a commit of the current refs and links at g.now. It is
+// built here rather than through CommitLog so that its timestamp, and so
+// its CID, is fixed; it carries no author for the same reason.
+func (g *generator) commit(message string) error {
+	parent := ""
+	if head, err := g.repo.Commits.Head(); err == nil && head.Defined() {
+		parent = dag.CIDToFilename(head)
+	}
+	c, err := g.repo.Store.PutCommit(&dag.CommitObject{
+		V:         1,
+		Parent:    parent,
+		Timestamp: g.now,
+		Refs:      g.refs,
+		Links:     g.links,
+		Message:   message,
+	})
+	if err != nil {
+		return err
+	}
+	head := filepath.Join(g.repo.MxDir(), "HEAD")
+	return dag.SafeWrite(head, []byte(dag.CIDToFilename(c)+"\n"), 0644)
+}
+
+// accesses writes n access log entries in the fuse.AccessLog format:
+// sessions of up to six reads of a node and its link targets, with
+// sessions separated by more than the co-access window.
+func (g *generator) accesses(path string, n int) error {
+	if n == 0 || len(g.nodes) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	fields := []string{"content", "meta", "links"}
+	ts := g.now.Add(time.Hour)
+	for written := 0; written < n; {
+		id := NodeID(g.rng.IntN(len(g.nodes)))
+		session := append([]string{id}, g.forward[id]...)
+		for _, read := range session[:min(len(session), 2+g.rng.IntN(5))] {
+			if written == n {
+				break
+			}
+			ts = ts.Add(time.Duration(1+g.rng.IntN(60)) * time.Second)
+			err := enc.Encode(struct {
+				Timestamp string `json:"ts"`
+				NodeID    string `json:"node"`
+				Field     string `json:"field"`
+			}{ts.Format(time.RFC3339Nano), read, fields[g.rng.IntN(len(fields))]})
+			if err != nil {
+				return err
+			}
+			written++
+		}
+		ts = ts.Add(time.Hour)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,59 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+var spec = Spec{Seed: 7, Nodes: 200, Links: 400, Commits: 30, Accesses: 500}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	a, b := Open(t, spec), Open(t, spec)
+	headA, _ := a.Commits.Head()
+	headB, _ := b.Commits.Head()
+	if !headA.Defined() || headA != headB {
+		t.Fatalf("HEADs differ for one seed: %s vs %s", headA, headB)
+	}
+
+	other := spec
+	other.Seed++
+	headC, _ := Open(t, other).Commits.Head()
+	if headC == headA {
+		t.Error("different seeds produced the same HEAD")
+	}
+}
+
+func TestGenerate_Shape(t *testing.T) {
+	repo := Open(t, spec)
+
+	ids, err := repo.ListNodes(t.Context(), 0)
+	if err != nil || len(ids) != spec.Nodes {
+		t.Fatalf("ListNodes = %d nodes, %v; want %d", len(ids), err, spec.Nodes)
+	}
+	if n := len(repo.Links.AllEntries()); n != spec.Links {
+		t.Errorf("%d links, want %d", n, spec.Links)
+	}
+	log, err := repo.Commits.Log(spec.Commits + 10)
+	if err != nil || len(log) != spec.Commits+1 {
+		t.Fatalf("Log = %d commits, %v; want %d", len(log), err, spec.Commits+1)
+	}
+	if !log[0].Timestamp.Equal(DefaultStart.Add(time.Duration(spec.Commits) * time.Hour)) {
+		t.Errorf("last commit at %v", log[0].Timestamp)
+	}
+
+	node, err := repo.GetNode(NodeID(5))
+	if err != nil || node.Type != Types[1] {
+		t.Fatalf("GetNode(%s) = %+v, %v", NodeID(5), node, err)
+	}
+	if hits := repo.Search.Search(Words[0], 0); len(hits) == 0 {
+		t.Errorf("no node mentions %q", Words[0])
+	}
+
+	related := 0
+	for _, id := range ids {
+		related += len(repo.Relatedness.Related(id, 0))
+	}
+	if related == 0 {
+		t.Error("access log and history produced no relatedness")
+	}
+}
@@ -0,0 +1,272 @@
+package dag
+
+import (
+	"cmp"
+	"context"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Operations a capability token may grant. OpWrite implies OpRead.
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+// tokenPrefix marks a string as a memex-fs capability token.
+const tokenPrefix = "mxt_"
+
+// Errors VerifyToken returns. Interfaces should answer all of them alike,
+// as unauthorized.
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+// Capability is what a token grants: operations, optionally only on nodes
+// of some types, optionally until a time. A nil *Capability is the
+// unrestricted access of a local user and allows everything.
+type Capability struct {
+	ID      string    `json:"id"`
+	Label   string    `json:"label,omitempty"`
+	Holder  string    `json:"holder,omitempty"` // DID ACLs know the bearer as; "" is anonymous
+	Ops     []string  `json:"ops"`
+	Types   []string  `json:"types,omitempty"` // empty: every type
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitzero"` // zero: never
+}
+
+// Allows reports whether c grants op on a node of type typ. An empty typ
+// asks about the operation alone, for requests not tied to one node.
+func (c *Capability) Allows(op, typ string) bool {
+	if c == nil {
+		return true
+	}
+	if !slices.Contains(c.Ops, op) && !(op == OpRead && slices.Contains(c.Ops, OpWrite)) {
+		return false
+	}
+	return typ == "" || len(c.Types) == 0 || slices.Contains(c.Types, typ)
+}
+
+// Expired reports whether c has lapsed at now.
+func (c *Capability) Expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires)
+}
+
+type capabilityKey struct{}
+
+// ContextWithCapability returns ctx carrying c, for code serving a
+// token-holder to check with CapabilityFrom.
+func ContextWithCapability(ctx context.Context, c *Capability) context.Context {
+	return context.WithValue(ctx, capabilityKey{}, c)
+}
+
+// CapabilityFrom returns the capability ctx carries, or nil, allowing
+// everything, when it carries none.
+func CapabilityFrom(ctx context.Context) *Capability {
+	c, _ := ctx.Value(capabilityKey{}).(*Capability)
+	return c
+}
+
+// tokenFile is .mx/tokens.json: the salt the signing key is derived with,
+// and every token not revoked, by ID.
+type tokenFile struct {
+	Salt   string                 `json:"salt"`
+	Tokens map[string]*Capability `json:"tokens"`
+}
+
+func (r *Repository) tokensPath() string {
+	return filepath.Join(r.MxDir(), "tokens.json")
+}
+
+func (r *Repository) loadTokens() (*tokenFile, error) {
+	f := &tokenFile{Tokens: map[string]*Capability{}}
+	data, err := os.ReadFile(r.tokensPath())
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read tokens: %w", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parse tokens: %w", err)
+	}
+	if f.Tokens == nil {
+		f.Tokens = map[string]*Capability{}
+	}
+	return f, nil
+}
+
+func (r *Repository) saveTokens(f *tokenFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SafeWrite(r.tokensPath(), data, 0600)
+}
+
+// tokenKey derives the key tokens are signed with from the identity's
+// seed and the repo's salt, so a token is good for this repo only and
+// dies with either.
+func (r *Repository) tokenKey(f *tokenFile) ([]byte, error) {
+	if r.identity == nil {
+		return nil, fmt.Errorf("tokens need an identity")
+	}
+	seed, err := base64.StdEncoding.DecodeString(r.identity.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode private key: %w", err)
+	}
+	salt, err := hex.DecodeString(f.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("bad token salt in %s", r.tokensPath())
+	}
+	return hkdf.Key(sha256.New, seed, salt, "memex-fs capability tokens", 32)
+}
+
+func tokenMAC(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// MintToken issues a token granting ops, on nodes of types if any are
+// given, for ttl if it is positive, to holder, the DID node ACLs are
+// checked against. It returns the token, which is shown only now, and its
+// capability; label is a reminder of what it is for.
+func (r *Repository) MintToken(label, holder string, ops, types []string, ttl time.Duration) (string, *Capability, error) {
+	if len(ops) == 0 {
+		return "", nil, fmt.Errorf("a token needs at least one operation")
+	}
+	for _, op := range ops {
+		if op != OpRead && op != OpWrite {
+			return "", nil, fmt.Errorf("unknown operation %q (want %s or %s)", op, OpRead, OpWrite)
+		}
+	}
+	if holder != "" {
+		if _, err := DecodeDIDKey(holder); err != nil {
+			return "", nil, fmt.Errorf("token holder: %w", err)
+		}
+	}
+	r.shared.tokensMu.Lock()
+	defer r.shared.tokensMu.Unlock()
+	f, err := r.loadTokens()
+	if err != nil {
+		return "", nil, err
+	}
+	if f.Salt == "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		f.Salt = hex.EncodeToString(salt)
+	}
+	key, err := r.tokenKey(f)
+	if err != nil {
+		return "", nil, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &Capability{ID: hex.EncodeToString(id), Label: label, Holder: holder, Ops: ops, Types: types, Created: now}
+	if ttl > 0 {
+		c.Expires = now.Add(ttl)
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", nil, err
+	}
+	f.Tokens[c.ID] = c
+	if err := r.saveTokens(f); err != nil {
+		return "", nil, err
+	}
+	enc := base64.RawURLEncoding
+	return tokenPrefix + enc.EncodeToString(payload) + "." + enc.EncodeToString(tokenMAC(key, payload)), c, nil
+}
+
+// Tokens returns the capabilities of every token not revoked, oldest
+// first, including expired ones.
+func (r *Repository) Tokens() ([]*Capability, error) {
+	f, err := r.loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Capability, 0, len(f.Tokens))
+	for _, c := range f.Tokens {
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b *Capability) int {
+		return cmp.Or(a.Created.Compare(b.Created), strings.Compare(a.ID, b.ID))
+	})
+	return out, nil
+}
+
+// RevokeToken withdraws the token id at once, everywhere it is verified.
+func (r *Repository) RevokeToken(id string) error {
+	r.shared.tokensMu.Lock()
+	defer r.shared.tokensMu.Unlock()
+	f, err := r.loadTokens()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Tokens[id]; !ok {
+		return fmt.Errorf("no token %s", id)
+	}
+	delete(f.Tokens, id)
+	return r.saveTokens(f)
+}
+
+// VerifyToken checks token's signature and that it is neither revoked nor
+// expired, returning what it grants. Revocation is read from disk on each
+// call, so it takes effect in running servers too.
+func (r *Repository) VerifyToken(token string) (*Capability, error) {
+	body, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	payloadB64, macB64, ok := strings.Cut(body, ".")
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	enc := base64.RawURLEncoding
+	payload, err1 := enc.DecodeString(payloadB64)
+	mac, err2 := enc.DecodeString(macB64)
+	if err1 != nil || err2 != nil {
+		return nil, ErrTokenInvalid
+	}
+	f, err := r.loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	if f.Salt == "" {
+		return nil, ErrTokenInvalid
+	}
+	key, err := r.tokenKey(f)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, tokenMAC(key, payload)) {
+		return nil, ErrTokenInvalid
+	}
+	var c Capability
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrTokenInvalid
+	}
+	if _, ok := f.Tokens[c.ID]; !ok {
+		return nil, ErrTokenRevoked
+	}
+	if c.Expired(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	return &c, nil
+}
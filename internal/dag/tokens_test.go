@@ -0,0 +1,61 @@
+package dag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	repo := openTestRepo(t)
+	token, c, err := repo.MintToken("reader", "", []string{OpRead}, []string{"Note"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.VerifyToken(token)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if got.ID != c.ID || !got.Allows(OpRead, "Note") || got.Allows(OpRead, "Person") || got.Allows(OpWrite, "Note") {
+		t.Errorf("capability = %+v", got)
+	}
+
+	// A token altered to widen its scope no longer verifies.
+	payload, mac, _ := strings.Cut(strings.TrimPrefix(token, tokenPrefix), ".")
+	forged := tokenPrefix + payload[:len(payload)-2] + "xx." + mac
+	if _, err := repo.VerifyToken(forged); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("forged token: err = %v, want ErrTokenInvalid", err)
+	}
+
+	// So does one minted for another repo, even under the same identity.
+	other := openTestRepo(t)
+	if _, _, err := other.MintToken("", "", []string{OpRead}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.VerifyToken(token); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("token from another repo: err = %v, want ErrTokenInvalid", err)
+	}
+
+	expired, _, _ := repo.MintToken("", "", []string{OpWrite}, nil, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := repo.VerifyToken(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired token: err = %v, want ErrTokenExpired", err)
+	}
+
+	if err := repo.RevokeToken(c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.VerifyToken(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked token: err = %v, want ErrTokenRevoked", err)
+	}
+	list, _ := repo.Tokens()
+	if len(list) != 1 || list[0].Ops[0] != OpWrite {
+		t.Errorf("Tokens() = %+v, want only the write token", list)
+	}
+
+	var local *Capability
+	if !local.Allows(OpWrite, "Anything") {
+		t.Error("nil capability should allow everything")
+	}
+}
@@ -0,0 +1,355 @@
+package dag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript nodes hold the text of an audio node, linked to it by a
+// TRANSCRIBES link. Their meta tracks the transcription:
+//
+//	transcript_status  running, done or failed
+//	progress           percent done, when the backend reports it
+//	error              why the last run failed
+//	source_version     the audio version transcribed
+const (
+	TranscriptType  = "Transcript"
+	LinkTranscribes = "TRANSCRIBES"
+)
+
+// Transcript statuses, kept in a Transcript node's transcript_status meta.
+const (
+	TranscriptRunning = "running"
+	TranscriptDone    = "done"
+	TranscriptFailed  = "failed"
+)
+
+// ErrNoTranscription is returned by Transcriber.Transcribe when no speech
+// to text backend is configured.
+var ErrNoTranscription = errors.New("no transcription configured")
+
+const (
+	transcribeTimeout = 30 * time.Minute
+	// progressStep is how far a transcription must advance before its
+	// progress is written, bounding the versions a long run creates.
+	progressStep = 25
+)
+
+// audioFormats are the Source formats treated as audio.
+var audioFormats = map[string]bool{
+	"wav": true, "mp3": true, "m4a": true, "ogg": true, "oga": true,
+	"opus": true, "flac": true, "aac": true, "webm": true,
+}
+
+// TranscriptID returns the ID of the Transcript node for id.
+func TranscriptID(id string) string {
+	return "transcript:" + id
+}
+
+// SpeechToText is a transcription backend. progress, if the backend can
+// tell, is called with the percentage done as it advances.
+type SpeechToText interface {
+	Transcribe(ctx context.Context, audio []byte, progress func(percent int)) (string, error)
+}
+
+// TranscriptionConfig is the "transcription" section of config.json. Set
+// Command to run a local engine such as whisper.cpp, or Endpoint to use
+// an OpenAI-compatible transcription API.
+type TranscriptionConfig struct {
+	// Command transcribes the audio and prints the text. An argument
+	// "{file}" is replaced by the path of a temporary copy of the audio;
+	// without one, the audio arrives on stdin. Lines on stderr reporting
+	// "progress = N%", as whisper.cpp's --print-progress does, update the
+	// Transcript's progress. E.g.
+	// ["whisper-cli", "-m", "ggml-base.en.bin", "-nt", "-pp", "-f", "{file}"].
+	Command []string `json:"command,omitempty"`
+	// Endpoint is the full transcriptions URL, e.g.
+	// https://api.openai.com/v1/audio/transcriptions.
+	Endpoint string `json:"endpoint,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// APIKeyEnv names the environment variable holding the bearer token
+	// for Endpoint.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+// NewSpeechToText creates the backend cfg describes, or nil if it names
+// none.
+func NewSpeechToText(cfg TranscriptionConfig) SpeechToText {
+	switch {
+	case len(cfg.Command) > 0:
+		return &CommandSpeechToText{argv: cfg.Command}
+	case cfg.Endpoint != "":
+		s := &HTTPSpeechToText{
+			endpoint: cfg.Endpoint,
+			model:    cfg.Model,
+			client:   &http.Client{Timeout: transcribeTimeout},
+		}
+		if cfg.APIKeyEnv != "" {
+			s.apiKey = os.Getenv(cfg.APIKeyEnv)
+		}
+		return s
+	}
+	return nil
+}
+
+// CommandSpeechToText is a SpeechToText backed by an external program.
+type CommandSpeechToText struct {
+	argv []string
+}
+
+var _ SpeechToText = (*CommandSpeechToText)(nil)
+
+// progressLine matches the progress reports of whisper.cpp and similar
+// tools.
+var progressLine = regexp.MustCompile(`progress\s*=\s*(\d+)\s*%`)
+
+func (s *CommandSpeechToText) Transcribe(ctx context.Context, audio []byte, progress func(int)) (string, error) {
+	args := make([]string, len(s.argv))
+	copy(args, s.argv)
+	viaFile := false
+	for i, a := range args {
+		if strings.Contains(a, "{file}") {
+			viaFile = true
+			f, err := os.CreateTemp("", "memex-audio-*")
+			if err != nil {
+				return "", err
+			}
+			defer os.Remove(f.Name())
+			_, err = f.Write(audio)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return "", err
+			}
+			args[i] = strings.ReplaceAll(a, "{file}", f.Name())
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if !viaFile {
+		cmd.Stdin = bytes.NewReader(audio)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("transcribe: %s: %w", filepath.Base(args[0]), err)
+	}
+	var lastErr string
+	sc := bufio.NewScanner(stderr)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := progressLine.FindStringSubmatch(line); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil && progress != nil {
+				progress(n)
+			}
+		} else if strings.TrimSpace(line) != "" {
+			lastErr = strings.TrimSpace(line)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if lastErr != "" {
+			return "", fmt.Errorf("transcribe: %s: %s", filepath.Base(args[0]), lastErr)
+		}
+		return "", fmt.Errorf("transcribe: %s: %w", filepath.Base(args[0]), err)
+	}
+	return stdout.String(), nil
+}
+
+// HTTPSpeechToText is a SpeechToText backed by an OpenAI-compatible
+// /audio/transcriptions endpoint.
+type HTTPSpeechToText struct {
+	endpoint string
+	model    string
+	apiKey   string
+	client   *http.Client
+}
+
+var _ SpeechToText = (*HTTPSpeechToText)(nil)
+
+func (s *HTTPSpeechToText) Transcribe(ctx context.Context, audio []byte, progress func(int)) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if s.model != "" {
+		mw.WriteField("model", s.model)
+	}
+	mw.WriteField("response_format", "json")
+	fw, err := mw.CreateFormFile("file", "audio")
+	if err != nil {
+		return "", err
+	}
+	fw.Write(audio)
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcribe: status %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("transcribe: parse response: %w", err)
+	}
+	return out.Text, nil
+}
+
+// Transcriber writes Transcript nodes using the repository's SpeechToText.
+// Besides explicit Transcribe calls, every content write to an audio node
+// starts one in the background. Deleting a transcript stops automatic
+// transcription of its audio.
+type Transcriber struct {
+	repo *Repository
+
+	wg sync.WaitGroup // background transcriptions
+}
+
+// NewTranscriber creates a Transcriber for repo.
+func NewTranscriber(repo *Repository) *Transcriber {
+	return &Transcriber{repo: repo}
+}
+
+// IsAudio reports whether node holds audio: a Source of an audio format,
+// or content that sniffs as audio.
+func IsAudio(node *NodeEnvelope) bool {
+	if format, _ := node.Meta["format"].(string); audioFormats[strings.ToLower(format)] {
+		return true
+	}
+	return len(node.Content) > 0 && strings.HasPrefix(http.DetectContentType(node.Content), "audio/")
+}
+
+// Transcribe transcribes audio node id into its Transcript node, creating
+// the node, marked running, before the backend starts.
+func (t *Transcriber) Transcribe(ctx context.Context, id string) (*NodeEnvelope, error) {
+	r := t.repo
+	if r.Speech == nil {
+		return nil, ErrNoTranscription
+	}
+	audio, err := r.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if !IsAudio(audio) {
+		return nil, fmt.Errorf("%s is not audio", id)
+	}
+	version, err := r.Refs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tid := TranscriptID(id)
+	if err := t.setStatus(tid, id, map[string]interface{}{
+		"transcript_status": TranscriptRunning,
+		"progress":          0,
+		"error":             nil,
+		"source_version":    CIDToFilename(version),
+	}); err != nil {
+		return nil, err
+	}
+	reported := 0
+	text, err := r.Speech.Transcribe(ctx, audio.Content, func(percent int) {
+		if percent < 100 && percent-reported >= progressStep {
+			reported = percent
+			t.setStatus(tid, id, map[string]interface{}{"progress": percent})
+		}
+	})
+	if err != nil {
+		t.setStatus(tid, id, map[string]interface{}{
+			"transcript_status": TranscriptFailed,
+			"error":             err.Error(),
+		})
+		return nil, err
+	}
+
+	unlock := r.lockNode(tid)
+	defer unlock()
+	return r.updateContent(tid, []byte(strings.TrimSpace(text)), map[string]interface{}{
+		"transcript_status": TranscriptDone,
+		"progress":          100,
+	})
+}
+
+// setStatus merges meta into the Transcript node tid of audio node id,
+// creating and linking the node if it does not exist.
+func (t *Transcriber) setStatus(tid, id string, meta map[string]interface{}) error {
+	r := t.repo
+	unlock := r.lockNode(tid)
+	defer unlock()
+	if _, err := r.GetNode(tid); err == nil {
+		_, err := r.updateMeta(tid, meta, "transcribe "+id)
+		return err
+	}
+	for k, v := range meta {
+		if v == nil {
+			delete(meta, k)
+		}
+	}
+	if _, err := r.createNode(tid, TranscriptType, nil, meta); err != nil {
+		return err
+	}
+	return r.CreateLink(tid, id, LinkTranscribes)
+}
+
+// onWrite starts a background transcription of node if it is audio. It
+// is called after every content write.
+func (t *Transcriber) onWrite(node *NodeEnvelope) {
+	r := t.repo
+	if r.Speech == nil || !IsAudio(node) {
+		return
+	}
+	if tr, err := r.getNodeEnvelope(TranscriptID(node.ID)); err == nil && tr.Deleted {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), transcribeTimeout)
+		defer cancel()
+		if _, err := t.Transcribe(ctx, node.ID); err != nil {
+			fmt.Printf("memex-fs: transcribe %s: %v\n", node.ID, err)
+		}
+	}()
+}
+
+// Wait blocks until every background transcription started so far has
+// finished.
+func (t *Transcriber) Wait() {
+	t.wg.Wait()
+}
@@ -0,0 +1,108 @@
+package dag
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTranscriber_CommandWithProgress(t *testing.T) {
+	repo := openTestRepo(t)
+	if _, err := repo.Transcriber.Transcribe(t.Context(), "x"); !errors.Is(err, ErrNoTranscription) {
+		t.Fatalf("without backend: err = %v, want ErrNoTranscription", err)
+	}
+	repo.Speech = NewSpeechToText(TranscriptionConfig{Command: []string{"sh", "-c",
+		`tr a-z n-za-m < "$1"; echo "progress = 30%" >&2; echo "progress = 60%" >&2`, "sh", "{file}"}})
+
+	id, _, err := repo.Ingest("mrccryva ynaqvat", "m4a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Transcriber.Wait()
+
+	tr, err := repo.GetNode(TranscriptID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Type != TranscriptType || string(tr.Content) != "zeppelin landing" {
+		t.Errorf("transcript = %s %q", tr.Type, tr.Content)
+	}
+	if tr.Meta["transcript_status"] != TranscriptDone || tr.Meta["progress"] != float64(100) {
+		t.Errorf("meta = %v", tr.Meta)
+	}
+	links := repo.Links.LinksFrom(tr.ID)
+	if len(links) != 1 || links[0].Target != id || links[0].Type != LinkTranscribes {
+		t.Errorf("links = %+v", links)
+	}
+	if hits := repo.Search.Search("landing", 10); len(hits) != 1 || hits[0] != tr.ID {
+		t.Errorf("Search(landing) = %v", hits)
+	}
+
+	// Progress reports were recorded along the way.
+	sawProgress := false
+	for v := tr; v.Prev != ""; {
+		if v, err = repo.envelopeAt(v.Prev); err != nil {
+			t.Fatal(err)
+		}
+		if v.Meta["progress"] == float64(30) {
+			sawProgress = true
+		}
+	}
+	if !sawProgress {
+		t.Error("no version recorded 30% progress")
+	}
+}
+
+func TestTranscriber_FailureAndOptOut(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Speech = NewSpeechToText(TranscriptionConfig{Command: []string{"sh", "-c", "echo model not found >&2; exit 1"}})
+	id, _, _ := repo.Ingest("audio bytes", "wav")
+	repo.Transcriber.Wait()
+
+	tr, err := repo.GetNode(TranscriptID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Meta["transcript_status"] != TranscriptFailed || tr.Meta["error"] != "transcribe: sh: model not found" {
+		t.Errorf("meta = %v", tr.Meta)
+	}
+
+	if err := repo.DeleteNode(tr.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := repo.Refs.Get(tr.ID)
+	if _, err := repo.UpdateContent(id, []byte("new audio")); err != nil {
+		t.Fatal(err)
+	}
+	repo.Transcriber.Wait()
+	if after, _ := repo.Refs.Get(tr.ID); !after.Equals(before) {
+		t.Error("deleted transcript was regenerated")
+	}
+}
+
+func TestTranscriber_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil || r.FormValue("model") != "whisper-1" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		audio, _ := io.ReadAll(f)
+		w.Write([]byte(`{"text": "heard ` + string(audio) + `"}`))
+	}))
+	defer srv.Close()
+
+	repo := openTestRepo(t)
+	repo.Speech = NewSpeechToText(TranscriptionConfig{Endpoint: srv.URL, Model: "whisper-1"})
+	id, _, _ := repo.Ingest("memo", "mp3")
+	tr, err := repo.Transcriber.Transcribe(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Transcriber.Wait()
+	if string(tr.Content) != "heard memo" {
+		t.Errorf("transcript = %q", tr.Content)
+	}
+}
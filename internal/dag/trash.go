@@ -0,0 +1,202 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// MetaTrashRetention overrides Config.TrashRetentionDays for one node: the
+// days its tombstone is kept once deleted, or a negative number to keep it
+// forever. It is read from the tombstone, so set it before deleting.
+const MetaTrashRetention = "trash_retention_days"
+
+// ExpiredTombstone is a deleted node whose retention has run out.
+type ExpiredTombstone struct {
+	ID       string
+	Deleted  time.Time
+	Versions int // stored versions, tombstone included
+}
+
+// trashRetention returns how long tomb is kept after deletion, and false
+// if it is kept forever.
+func (r *Repository) trashRetention(tomb *NodeEnvelope) (time.Duration, bool) {
+	days := r.Config.TrashRetentionDays
+	if v, ok := metaFloat(tomb.Meta[MetaTrashRetention]); ok {
+		if v < 0 {
+			return 0, false
+		}
+		days = int(v)
+	} else if days <= 0 {
+		return 0, false
+	}
+	return time.Duration(days) * 24 * time.Hour, true
+}
+
+// ExpireTrash purges tombstones older than their retention as of now: every
+// stored version of the node, its ref, and the links to and from it, in a
+// single commit. With dryRun nothing changes, and the result is what would
+// be purged. Commits from before the purge no longer show the node in
+// /at/, except protected ones (see Protect), whose nodes are never
+// purged. Append-only repositories refuse to purge with ErrAppendOnly.
+func (r *Repository) ExpireTrash(ctx context.Context, now time.Time, dryRun bool) ([]ExpiredTombstone, error) {
+	if !dryRun && r.Config.AppendOnly {
+		return nil, ErrAppendOnly
+	}
+	ids, err := r.Refs.List()
+	if err != nil {
+		return nil, err
+	}
+	protected, err := r.protectedVersions()
+	if err != nil {
+		return nil, err
+	}
+	var expired []ExpiredTombstone
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return expired, err
+		}
+		e, ok, err := r.expireTombstone(id, now, dryRun, protected)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired = append(expired, e)
+		}
+	}
+	if !dryRun && len(expired) > 0 {
+		dropped, err := r.dropLinksOf(expired)
+		if err != nil {
+			return expired, err
+		}
+		msg := fmt.Sprintf("purge %d expired tombstones", len(expired))
+		if dropped > 0 {
+			msg += fmt.Sprintf(" and %d links", dropped)
+		}
+		r.commit(msg)
+	}
+	return expired, nil
+}
+
+// expireTombstone purges id if it is a tombstone past its retention, or
+// only reports it with dryRun. A node with a version in protected is kept.
+func (r *Repository) expireTombstone(id string, now time.Time, dryRun bool, protected map[string]bool) (ExpiredTombstone, bool, error) {
+	unlock := r.lockNode(id)
+	defer unlock()
+
+	tomb, err := r.getNodeEnvelope(id)
+	if err != nil || !tomb.Deleted {
+		return ExpiredTombstone{}, false, nil
+	}
+	keep, ok := r.trashRetention(tomb)
+	if !ok || now.Sub(tomb.Modified) < keep {
+		return ExpiredTombstone{}, false, nil
+	}
+	head, err := r.Refs.Get(id)
+	if err != nil {
+		return ExpiredTombstone{}, false, nil
+	}
+
+	chain := []string{CIDToFilename(head)}
+	for prev := tomb.Prev; prev != ""; {
+		v, err := r.envelopeAt(prev)
+		if err != nil {
+			break
+		}
+		chain = append(chain, prev)
+		prev = v.Prev
+	}
+	if slices.ContainsFunc(chain, func(name string) bool { return protected[name] }) {
+		return ExpiredTombstone{}, false, nil
+	}
+	e := ExpiredTombstone{ID: id, Deleted: tomb.Modified, Versions: len(chain)}
+	if dryRun {
+		return e, true, nil
+	}
+
+	// Ref first: a failure part way leaves unreferenced objects, not a
+	// ref to a missing one.
+	if err := r.Refs.Delete(id); err != nil {
+		return e, false, fmt.Errorf("delete ref %s: %w", id, err)
+	}
+	for _, name := range chain {
+		c, err := cidFromFilename(name)
+		if err != nil {
+			continue
+		}
+		if err := r.Store.Delete(c); err != nil {
+			return e, true, err
+		}
+	}
+	return e, true, nil
+}
+
+// dropLinksOf removes every link from or to a purged node.
+func (r *Repository) dropLinksOf(purged []ExpiredTombstone) (int, error) {
+	gone := make(map[string]bool, len(purged))
+	for _, e := range purged {
+		gone[e.ID] = true
+	}
+	var doomed []LinkEntry
+	for _, l := range r.Links.AllEntries() {
+		if gone[l.Source] || gone[LinkTargetParent(l.Target)] {
+			doomed = append(doomed, l)
+		}
+	}
+	sort.Slice(doomed, func(i, j int) bool { return doomed[i].ID() < doomed[j].ID() })
+	dropped := 0
+	for _, l := range doomed {
+		removed, err := r.Links.Remove(l)
+		if err != nil {
+			return dropped, err
+		}
+		if removed {
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+// Maintain runs the periodic maintenance pass as of now. It snapshots
+// the scheduled reports that are due, saves the indexes and expires
+// tombstones, which append-only repositories keep.
+func (r *Repository) Maintain(ctx context.Context, now time.Time) error {
+	made, err := r.SnapshotReports(ctx, now)
+	if len(made) > 0 {
+		fmt.Printf("memex-fs: snapshotted %d reports\n", len(made))
+	}
+	err = errors.Join(err, r.SaveIndexes())
+	if r.Config.AppendOnly {
+		return err
+	}
+	expired, expireErr := r.ExpireTrash(ctx, now, false)
+	if len(expired) > 0 {
+		fmt.Printf("memex-fs: purged %d expired tombstones\n", len(expired))
+	}
+	return errors.Join(err, expireErr)
+}
+
+// StartMaintenance runs Maintain every interval until the returned stop
+// function is called. Failures are logged and retried on the next tick.
+// Stopping also cancels a pass that is still in flight.
+func (r *Repository) StartMaintenance(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := r.Maintain(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				fmt.Printf("memex-fs: maintenance warning: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
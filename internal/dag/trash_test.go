@@ -0,0 +1,70 @@
+package dag
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpireTrash(t *testing.T) {
+	repo := openTestRepo(t)
+	repo.Config.TrashRetentionDays = 30
+	repo.CreateNode("note:a", "Note", []byte("a"), nil)
+	repo.UpdateContent("note:a", []byte("a2"))
+	repo.CreateNode("note:b", "Note", []byte("b"), nil)
+	repo.CreateNode("note:keep", "Note", []byte("k"), map[string]interface{}{MetaTrashRetention: -1.0})
+	repo.CreateNode("note:live", "Note", []byte("l"), nil)
+	repo.CreateLink("note:live", "note:a", "cites")
+	repo.CreateLink("note:b", "note:live", "cites")
+	for _, id := range []string{"note:a", "note:b", "note:keep"} {
+		if err := repo.DeleteNode(id, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aHead, _ := repo.Refs.Get("note:a")
+	ctx := context.Background()
+
+	if got, _ := repo.ExpireTrash(ctx, time.Now().Add(24*time.Hour), false); len(got) != 0 {
+		t.Errorf("purged before retention ran out: %+v", got)
+	}
+
+	later := time.Now().Add(31 * 24 * time.Hour)
+	dry, err := repo.ExpireTrash(ctx, later, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(es []ExpiredTombstone) []string {
+		var out []string
+		for _, e := range es {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+	if got := ids(dry); !reflect.DeepEqual(got, []string{"note:a", "note:b"}) {
+		t.Fatalf("dry run = %v", got)
+	}
+	if dry[0].Versions != 3 {
+		t.Errorf("note:a versions = %d, want 3", dry[0].Versions)
+	}
+	if !repo.Refs.Has("note:a") || len(repo.Links.AllEntries()) != 2 {
+		t.Fatal("dry run changed the repo")
+	}
+
+	purged, err := repo.ExpireTrash(ctx, later, false)
+	if err != nil || !reflect.DeepEqual(ids(purged), ids(dry)) {
+		t.Fatalf("purge = %v, %v", ids(purged), err)
+	}
+	if repo.Refs.Has("note:a") || repo.Refs.Has("note:b") || !repo.Refs.Has("note:keep") {
+		t.Error("refs not purged as expected")
+	}
+	if repo.Store.Has(aHead) {
+		t.Error("tombstone object still stored")
+	}
+	if n := len(repo.Links.AllEntries()); n != 0 {
+		t.Errorf("%d links left to purged nodes", n)
+	}
+	if log, _ := repo.Commits.History(1); log[0].Message != "purge 2 expired tombstones and 2 links" {
+		t.Errorf("commit = %q", log[0].Message)
+	}
+}
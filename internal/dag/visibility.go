@@ -0,0 +1,67 @@
+package dag
+
+import (
+	"fmt"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// MetaVisibility is the meta key that publishes a node: a node whose
+// visibility is VisibilityPublic may be served by the public gateway.
+// Every other node is private.
+const (
+	MetaVisibility   = "visibility"
+	VisibilityPublic = "public"
+)
+
+// ErrNotPublic is returned for a node, or a version of one, that is not
+// published. Callers serving the public should answer it as not found,
+// so private IDs are not confirmed to exist; it matches ErrNotFound.
+var ErrNotPublic error = &kindError{"not public", ErrNotFound}
+
+// IsPublic reports whether node is marked visibility=public.
+func IsPublic(node *NodeEnvelope) bool {
+	v, _ := node.Meta[MetaVisibility].(string)
+	return v == VisibilityPublic
+}
+
+// PublicNode returns the live node id and the CID of its current version,
+// if it is public and no ACL keeps anonymous readers from it.
+func (r *Repository) PublicNode(id string) (*NodeEnvelope, string, error) {
+	node, err := r.GetNode(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !IsPublic(node) {
+		return nil, "", ErrNotPublic
+	}
+	if acl, _ := r.ACLFor(id); !acl.CanRead("") {
+		return nil, "", ErrNotPublic
+	}
+	c, err := r.Refs.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return node, c.String(), nil
+}
+
+// PublicVersion returns the node version stored at cid. Both that version
+// and the node as it is now must be public, so unpublishing a node also
+// withdraws links to its old versions.
+func (r *Repository) PublicVersion(cid string) (*NodeEnvelope, error) {
+	c, err := gocid.Decode(cid)
+	if err != nil {
+		return nil, fmt.Errorf("bad cid %q: %w", cid, err)
+	}
+	version, err := r.Store.GetNode(c)
+	if err != nil {
+		return nil, err
+	}
+	if !IsPublic(version) {
+		return nil, ErrNotPublic
+	}
+	if _, _, err := r.PublicNode(version.ID); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
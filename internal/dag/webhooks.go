@@ -0,0 +1,142 @@
+package dag
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Webhook event names.
+const (
+	EventNodeCreated = "node.created"
+	EventNodeUpdated = "node.updated"
+	EventNodeDeleted = "node.deleted"
+	EventLinkCreated = "link.created"
+)
+
+const (
+	webhookAttempts = 4
+	webhookTimeout  = 10 * time.Second
+)
+
+// WebhookConfig is one webhook endpoint in config.json.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Secret, if set, signs every delivery: the X-Memex-Signature-256
+	// header carries "sha256=" and the hex HMAC-SHA256 of the body.
+	Secret string `json:"secret,omitempty"`
+	// Events limits deliveries to these event names; empty means all.
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookEvent is the JSON body POSTed to a webhook.
+type WebhookEvent struct {
+	Event string        `json:"event"`
+	Time  time.Time     `json:"time"`
+	ID    string        `json:"id,omitempty"`   // the node, for node events
+	Node  *NodeEnvelope `json:"node,omitempty"` // the stored version; absent after a hard delete
+	Link  *LinkEntry    `json:"link,omitempty"`
+}
+
+// Webhooks delivers WebhookEvents to the configured endpoints. Delivery is
+// in the background, so events can arrive out of order; use Time to
+// order them. A failed delivery (network error, 5xx or 429) is retried
+// with exponential backoff; any other response is final.
+type Webhooks struct {
+	hooks   []WebhookConfig
+	client  *http.Client
+	backoff time.Duration // first retry delay, doubled each attempt
+
+	wg sync.WaitGroup // in-flight deliveries
+}
+
+// NewWebhooks creates a Webhooks delivering to hooks.
+func NewWebhooks(hooks []WebhookConfig) *Webhooks {
+	return &Webhooks{
+		hooks:   hooks,
+		client:  &http.Client{Timeout: webhookTimeout},
+		backoff: time.Second,
+	}
+}
+
+// Notify sends ev to every webhook subscribed to it.
+func (w *Webhooks) Notify(ev WebhookEvent) {
+	if len(w.hooks) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		fmt.Printf("memex-fs: webhook %s: %v\n", ev.Event, err)
+		return
+	}
+	for _, hook := range w.hooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, ev.Event) {
+			continue
+		}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			if err := w.deliver(hook, ev.Event, body); err != nil {
+				fmt.Printf("memex-fs: webhook %s to %s: %v\n", ev.Event, hook.URL, err)
+			}
+		}()
+	}
+}
+
+func (w *Webhooks) deliver(hook WebhookConfig, event string, body []byte) error {
+	delay := w.backoff
+	var lastErr error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Memex-Event", event)
+		if hook.Secret != "" {
+			req.Header.Set("X-Memex-Signature-256", SignWebhook(hook.Secret, body))
+		}
+		resp, err := w.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			lastErr = fmt.Errorf("status %s", resp.Status)
+		default:
+			return fmt.Errorf("status %s", resp.Status)
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", webhookAttempts, lastErr)
+}
+
+// SignWebhook returns the X-Memex-Signature-256 value for body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Wait blocks until every delivery started so far has succeeded or given
+// up.
+func (w *Webhooks) Wait() {
+	w.wg.Wait()
+}
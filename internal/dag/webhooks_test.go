@@ -0,0 +1,90 @@
+package dag
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookSink records deliveries, failing the first failN with a 503.
+type webhookSink struct {
+	mu     sync.Mutex
+	failN  int
+	events []WebhookEvent
+	sigs   []string
+}
+
+func (s *webhookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failN > 0 {
+		s.failN--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var ev WebhookEvent
+	json.Unmarshal(body, &ev)
+	s.events = append(s.events, ev)
+	if r.Header.Get("X-Memex-Signature-256") != SignWebhook("s3cret", body) {
+		s.sigs = append(s.sigs, "bad")
+	}
+}
+
+func webhookRepo(t *testing.T, hooks ...WebhookConfig) *Repository {
+	t.Helper()
+	repo := openTestRepo(t)
+	repo.Webhooks = NewWebhooks(hooks)
+	repo.Webhooks.backoff = time.Millisecond
+	return repo
+}
+
+func TestWebhooks_DeliversSignedEvents(t *testing.T) {
+	sink := &webhookSink{}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+	repo := webhookRepo(t, WebhookConfig{URL: srv.URL, Secret: "s3cret"})
+
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateNode("note:b", "Note", nil, nil)
+	repo.UpdateContent("note:a", []byte("x"))
+	repo.CreateLink("note:a", "note:b", "cites")
+	repo.DeleteNode("note:b", false)
+	repo.Webhooks.Wait()
+
+	counts := make(map[string]int)
+	for _, ev := range sink.events {
+		counts[ev.Event]++
+	}
+	want := map[string]int{EventNodeCreated: 2, EventNodeUpdated: 1, EventLinkCreated: 1, EventNodeDeleted: 1}
+	for event, n := range want {
+		if counts[event] != n {
+			t.Errorf("%s delivered %d times, want %d (all: %v)", event, counts[event], n, counts)
+		}
+	}
+	if len(sink.sigs) != 0 {
+		t.Errorf("%d deliveries with a bad signature", len(sink.sigs))
+	}
+}
+
+func TestWebhooks_FiltersAndRetries(t *testing.T) {
+	sink := &webhookSink{failN: 2}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+	repo := webhookRepo(t, WebhookConfig{URL: srv.URL, Events: []string{EventLinkCreated}})
+
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateLink("note:a", "note:a", "self")
+	repo.Webhooks.Wait()
+
+	if len(sink.events) != 1 || sink.events[0].Event != EventLinkCreated {
+		t.Fatalf("events = %+v, want one link.created after retries", sink.events)
+	}
+	if l := sink.events[0].Link; l == nil || l.Type != "self" {
+		t.Errorf("link payload = %+v", l)
+	}
+}
@@ -0,0 +1,113 @@
+package dagit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// nameResolver resolves IPNS names; IPFSClient satisfies it.
+type nameResolver interface {
+	NameResolve(ctx context.Context, ipnsName string) (string, error)
+}
+
+// ResolveSource turns what a user gives pull or clone into a commit CID.
+// source may be a commit CID, a did:key DID or an IPNS name (resolved to
+// the latest HEAD published under it), either bare or as an /ipfs/ or
+// /ipns/ path, an ipfs:// or ipns:// URL, or a gateway URL such as
+// https://peer.example/ipns/k51....
+func ResolveSource(ctx context.Context, names nameResolver, source string) (string, error) {
+	s := strings.TrimSpace(source)
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", fmt.Errorf("peer URL: %w", err)
+		}
+		if !strings.HasPrefix(u.Path, "/ipfs/") && !strings.HasPrefix(u.Path, "/ipns/") {
+			return "", fmt.Errorf("peer URL %s: want an /ipfs/ or /ipns/ path", source)
+		}
+		s = u.Path
+	}
+	for _, p := range []string{"ipfs://", "/ipfs/"} {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return strings.Trim(rest, "/"), nil
+		}
+	}
+	name := ""
+	for _, p := range []string{"ipns://", "/ipns/"} {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			name = strings.Trim(rest, "/")
+		}
+	}
+	switch {
+	case name != "":
+	case strings.HasPrefix(s, "did:key:"):
+		n, err := DIDToIPNSName(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid DID: %w", err)
+		}
+		name = n
+	default:
+		c, err := gocid.Decode(s)
+		if err != nil {
+			return "", fmt.Errorf("%s is not a CID, DID or IPNS name", source)
+		}
+		if c.Prefix().Codec != gocid.Libp2pKey {
+			return s, nil
+		}
+		name = s
+	}
+	head, err := names.NameResolve(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("IPNS resolve: %w", err)
+	}
+	return head, nil
+}
+
+// CloneResult is what Clone brought in.
+type CloneResult struct {
+	Head     string // commit checked out
+	Nodes    int    // nodes checked out
+	Signed   int    // pulled commits with a verified signature
+	Unsigned int    // pulled commits without a signature
+}
+
+// Clone bootstraps the empty repository repo from source, as given to
+// ResolveSource: it pulls the source's HEAD as PullWith does, verifies
+// every pulled commit's hash and any signature it carries, checks HEAD
+// out, and records source as the remote dag.DefaultRemote for later
+// pulls. With requireSigned, an unsigned commit fails the clone.
+func Clone(ctx context.Context, repo *dag.Repository, client IPFSClient, source string, opts PullOptions, requireSigned bool) (*CloneResult, error) {
+	if head, err := repo.Commits.Head(); err != nil || head.Defined() {
+		return nil, fmt.Errorf("clone needs an empty repository")
+	}
+	headStr, err := ResolveSource(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := PullWith(ctx, repo, client, headStr, opts); err != nil {
+		return nil, err
+	}
+	head, err := decodeCID(headStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID: %w", err)
+	}
+	res := &CloneResult{Head: dag.CIDToFilename(head)}
+	if res.Signed, res.Unsigned, err = repo.Commits.VerifyHistory(head); err != nil {
+		return nil, fmt.Errorf("verify: %w", err)
+	}
+	if requireSigned && res.Unsigned > 0 {
+		return nil, fmt.Errorf("verify: %d unsigned commits", res.Unsigned)
+	}
+	if res.Nodes, err = repo.Checkout(head, opts.Sparse); err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	if err := repo.SetRemote(dag.DefaultRemote, source, res.Head); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package dagit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+type fakeNames map[string]string
+
+func (f fakeNames) NameResolve(ctx context.Context, name string) (string, error) {
+	return f[name], nil
+}
+
+func TestResolveSource(t *testing.T) {
+	const did = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	name, err := DIDToIPNSName(did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const head = "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+	names := fakeNames{name: head}
+	for _, source := range []string{
+		head, "/ipfs/" + head, "ipfs://" + head,
+		did, name, "/ipns/" + name, "ipns://" + name + "/", "https://peer.example/ipns/" + name,
+	} {
+		if got, err := ResolveSource(context.Background(), names, source); err != nil || got != head {
+			t.Errorf("ResolveSource(%q) = %q, %v", source, got, err)
+		}
+	}
+	for _, source := range []string{"not-a-cid", "https://peer.example/notes"} {
+		if _, err := ResolveSource(context.Background(), names, source); err == nil {
+			t.Errorf("ResolveSource(%q) succeeded", source)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	kubo := newFakeKubo()
+	src := openFreshRepo(t)
+	src.CreateNode("a", "Note", []byte("hello"), nil)
+	src.CreateNode("b", "Note", []byte("world"), nil)
+	head, err := Push(ctx, src, kubo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := dag.LoadIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureKey(ctx, kubo, identity, HeadKeyName); err != nil {
+		t.Fatal(err)
+	}
+	kubo.NamePublish(ctx, head, HeadKeyName)
+
+	if _, err := Clone(ctx, openFreshRepo(t), kubo, identity.DID, PullOptions{}, true); err == nil {
+		t.Error("--require-signed cloned unsigned history")
+	}
+	dst := openFreshRepo(t)
+	res, err := Clone(ctx, dst, kubo, identity.DID, PullOptions{}, false)
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if res.Head != head || res.Nodes != 2 || res.Unsigned != 2 {
+		t.Errorf("result = %+v", res)
+	}
+	if node, err := dst.GetNode("b"); err != nil || string(node.Content) != "world" {
+		t.Errorf("GetNode(b) = %v, %v", node, err)
+	}
+	remote, _ := dst.GetRemote(dag.DefaultRemote)
+	if remote == nil || remote.Source != identity.DID || remote.Head != head {
+		t.Errorf("origin = %+v", remote)
+	}
+	if _, err := Clone(ctx, dst, kubo, identity.DID, PullOptions{}, false); err == nil {
+		t.Error("cloned into a repository with history")
+	}
+}
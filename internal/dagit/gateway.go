@@ -0,0 +1,347 @@
+package dagit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// DefaultGateways are the public gateways used when config.json lists
+// none.
+var DefaultGateways = []string{"https://ipfs.io", "https://dweb.link"}
+
+// ErrPublishQueued is returned by FallbackClient.NamePublish when Kubo is
+// unreachable and the publish was queued instead.
+var ErrPublishQueued = errors.New("kubo unreachable; publish queued")
+
+const (
+	gatewayTimeout = 30 * time.Second
+	// maxGatewayBody bounds what a gateway may send back for one object.
+	maxGatewayBody = 64 << 20
+	// availabilityTTL is how long a Kubo probe result is trusted.
+	availabilityTTL = 10 * time.Second
+)
+
+// GatewayClient reads from public HTTP gateways. It covers only the read
+// side of IPFSClient: blocks, files and IPNS resolution. Gateways are
+// tried in order until one answers.
+//
+// Blocks are fetched in raw form (the trustless gateway format), so Pull
+// verifies them against their CID like any other block. IPNS resolution
+// takes the gateway's word for the current record.
+type GatewayClient struct {
+	gateways []string
+	client   *http.Client
+}
+
+// NewGatewayClient creates a client for the given gateway base URLs, e.g.
+// https://ipfs.io.
+func NewGatewayClient(gateways []string) *GatewayClient {
+	trimmed := make([]string, len(gateways))
+	for i, g := range gateways {
+		trimmed[i] = strings.TrimRight(g, "/")
+	}
+	return &GatewayClient{
+		gateways: trimmed,
+		client:   &http.Client{Timeout: gatewayTimeout},
+	}
+}
+
+// get tries each gateway in turn, returning the first 200 response.
+func (g *GatewayClient) get(ctx context.Context, method, path, accept string) (*http.Response, error) {
+	if len(g.gateways) == 0 {
+		return nil, fmt.Errorf("no gateways configured")
+	}
+	var errs []error
+	for _, base := range g.gateways {
+		req, err := http.NewRequestWithContext(ctx, method, base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+		errs = append(errs, fmt.Errorf("%s: status %s", base, resp.Status))
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (g *GatewayClient) read(ctx context.Context, path, accept string) ([]byte, error) {
+	resp, err := g.get(ctx, http.MethodGet, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxGatewayBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxGatewayBody {
+		return nil, fmt.Errorf("%s: response over %d bytes", path, maxGatewayBody)
+	}
+	return data, nil
+}
+
+// BlockGet fetches the raw bytes of a block.
+func (g *GatewayClient) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	data, err := g.read(ctx, "/ipfs/"+cid+"?format=raw", "application/vnd.ipld.raw")
+	if err != nil {
+		return nil, fmt.Errorf("gateway block/get: %w", err)
+	}
+	return data, nil
+}
+
+// Cat fetches a file by CID.
+func (g *GatewayClient) Cat(ctx context.Context, cid string) ([]byte, error) {
+	data, err := g.read(ctx, "/ipfs/"+cid, "")
+	if err != nil {
+		return nil, fmt.Errorf("gateway cat: %w", err)
+	}
+	return data, nil
+}
+
+// NameResolve resolves an IPNS name to a CID. Gateways report the CID
+// their answer came from in X-Ipfs-Roots (or X-Ipfs-Path), so a HEAD
+// request suffices.
+func (g *GatewayClient) NameResolve(ctx context.Context, ipnsName string) (string, error) {
+	resp, err := g.get(ctx, http.MethodHead, "/ipns/"+ipnsName, "application/vnd.ipld.raw")
+	if err != nil {
+		return "", fmt.Errorf("gateway name/resolve: %w", err)
+	}
+	resp.Body.Close()
+	if roots := resp.Header.Get("X-Ipfs-Roots"); roots != "" {
+		return strings.TrimSpace(strings.Split(roots, ",")[0]), nil
+	}
+	if path := resp.Header.Get("X-Ipfs-Path"); strings.HasPrefix(path, "/ipfs/") {
+		return strings.SplitN(strings.TrimPrefix(path, "/ipfs/"), "/", 2)[0], nil
+	}
+	return "", fmt.Errorf("gateway name/resolve: %s: no CID in response headers", ipnsName)
+}
+
+// FallbackClient is an IPFSClient that uses Kubo while it is reachable
+// and degrades when it is not: reads go to public gateways, publishes are
+// queued on disk and sent the next time Kubo is reachable, and every other
+// write fails.
+type FallbackClient struct {
+	kubo      IPFSClient
+	gateways  *GatewayClient
+	queuePath string
+
+	mu      sync.Mutex
+	checked time.Time // when up was last probed
+	up      bool
+}
+
+var _ IPFSClient = (*FallbackClient)(nil)
+
+// QueuedPublish is a name/publish waiting for Kubo.
+type QueuedPublish struct {
+	Key    string    `json:"key"`
+	CID    string    `json:"cid"`
+	Queued time.Time `json:"queued"`
+}
+
+// NewFallbackClient wraps kubo with gateway reads and a publish queue
+// stored at queuePath.
+func NewFallbackClient(kubo IPFSClient, gateways *GatewayClient, queuePath string) *FallbackClient {
+	return &FallbackClient{kubo: kubo, gateways: gateways, queuePath: queuePath}
+}
+
+// online reports whether Kubo is reachable, probing at most once per
+// availabilityTTL. When Kubo comes back, queued publishes are sent.
+func (f *FallbackClient) online(ctx context.Context) bool {
+	f.mu.Lock()
+	if time.Since(f.checked) < availabilityTTL {
+		up := f.up
+		f.mu.Unlock()
+		return up
+	}
+	f.mu.Unlock()
+
+	up := f.kubo.IsAvailable(ctx)
+	f.mu.Lock()
+	wasUp := f.up && !f.checked.IsZero()
+	f.up, f.checked = up, time.Now()
+	f.mu.Unlock()
+	if up && !wasUp {
+		if _, err := f.FlushQueue(ctx); err != nil {
+			fmt.Printf("memex-fs: publish queue: %v\n", err)
+		}
+	}
+	return up
+}
+
+// IsAvailable reports whether Kubo itself is reachable; reads work
+// through the gateways either way.
+func (f *FallbackClient) IsAvailable(ctx context.Context) bool {
+	return f.online(ctx)
+}
+
+func (f *FallbackClient) offlineErr(op string) error {
+	return fmt.Errorf("ipfs %s: kubo unreachable and gateways are read-only", op)
+}
+
+func (f *FallbackClient) Add(ctx context.Context, content []byte) (string, error) {
+	if !f.online(ctx) {
+		return "", f.offlineErr("add")
+	}
+	return f.kubo.Add(ctx, content)
+}
+
+func (f *FallbackClient) Cat(ctx context.Context, cid string) ([]byte, error) {
+	if f.online(ctx) {
+		return f.kubo.Cat(ctx, cid)
+	}
+	return f.gateways.Cat(ctx, cid)
+}
+
+func (f *FallbackClient) Pin(ctx context.Context, cid string) error {
+	if !f.online(ctx) {
+		return f.offlineErr("pin")
+	}
+	return f.kubo.Pin(ctx, cid)
+}
+
+func (f *FallbackClient) BlockPut(ctx context.Context, data []byte, cidCodec, mhType string) (string, error) {
+	if !f.online(ctx) {
+		return "", f.offlineErr("block/put")
+	}
+	return f.kubo.BlockPut(ctx, data, cidCodec, mhType)
+}
+
+func (f *FallbackClient) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	if f.online(ctx) {
+		return f.kubo.BlockGet(ctx, cid)
+	}
+	return f.gateways.BlockGet(ctx, cid)
+}
+
+func (f *FallbackClient) KeyList(ctx context.Context) ([]KeyInfo, error) {
+	if !f.online(ctx) {
+		return nil, f.offlineErr("key/list")
+	}
+	return f.kubo.KeyList(ctx)
+}
+
+func (f *FallbackClient) KeyImport(ctx context.Context, name, pemBody string) error {
+	if !f.online(ctx) {
+		return f.offlineErr("key/import")
+	}
+	return f.kubo.KeyImport(ctx, name, pemBody)
+}
+
+// NamePublish publishes through Kubo, or queues the publish and returns
+// ErrPublishQueued while Kubo is unreachable. A queued publish replaces
+// any earlier one for the same key.
+func (f *FallbackClient) NamePublish(ctx context.Context, cid, keyName string) error {
+	if f.online(ctx) {
+		return f.kubo.NamePublish(ctx, cid, keyName)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queue, err := f.loadQueue()
+	if err != nil {
+		return err
+	}
+	kept := queue[:0]
+	for _, q := range queue {
+		if q.Key != keyName {
+			kept = append(kept, q)
+		}
+	}
+	kept = append(kept, QueuedPublish{Key: keyName, CID: cid, Queued: time.Now().UTC()})
+	if err := f.saveQueue(kept); err != nil {
+		return err
+	}
+	return ErrPublishQueued
+}
+
+func (f *FallbackClient) NameResolve(ctx context.Context, ipnsName string) (string, error) {
+	if f.online(ctx) {
+		return f.kubo.NameResolve(ctx, ipnsName)
+	}
+	return f.gateways.NameResolve(ctx, ipnsName)
+}
+
+// Queue returns the publishes waiting for Kubo.
+func (f *FallbackClient) Queue() ([]QueuedPublish, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loadQueue()
+}
+
+// FlushQueue sends every queued publish through Kubo, keeping the ones
+// that fail. Returns how many were published.
+func (f *FallbackClient) FlushQueue(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queue, err := f.loadQueue()
+	if err != nil || len(queue) == 0 {
+		return 0, err
+	}
+	var failed []QueuedPublish
+	var errs []error
+	for _, q := range queue {
+		if err := f.kubo.NamePublish(ctx, q.CID, q.Key); err != nil {
+			failed = append(failed, q)
+			errs = append(errs, err)
+		}
+	}
+	if err := f.saveQueue(failed); err != nil {
+		errs = append(errs, err)
+	}
+	return len(queue) - len(failed), errors.Join(errs...)
+}
+
+// loadQueue reads the queue file. Callers hold f.mu.
+func (f *FallbackClient) loadQueue() ([]QueuedPublish, error) {
+	data, err := os.ReadFile(f.queuePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read publish queue: %w", err)
+	}
+	var queue []QueuedPublish
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, fmt.Errorf("parse publish queue: %w", err)
+	}
+	return queue, nil
+}
+
+// saveQueue writes the queue file, removing it when empty. Callers hold
+// f.mu.
+func (f *FallbackClient) saveQueue(queue []QueuedPublish) error {
+	if len(queue) == 0 {
+		if err := os.Remove(f.queuePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	return dag.SafeWrite(f.queuePath, data, 0644)
+}
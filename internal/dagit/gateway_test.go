@@ -0,0 +1,101 @@
+package dagit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeGateway serves network's blocks and names over the gateway API.
+func newFakeGateway(t *testing.T, network *MemoryIPFS) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/ipfs/"):
+			data, err := network.BlockGet(r.Context(), strings.TrimPrefix(r.URL.Path, "/ipfs/"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case strings.HasPrefix(r.URL.Path, "/ipns/"):
+			cid, err := network.NameResolve(r.Context(), strings.TrimPrefix(r.URL.Path, "/ipns/"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("X-Ipfs-Roots", cid)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFallbackClient_ReadsThroughGateway(t *testing.T) {
+	src := openFreshRepo(t)
+	src.CreateNode("a", "N", []byte("hello"), nil)
+	network := NewMemoryIPFS()
+	head, err := Push(t.Context(), src, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	network.keys["head"] = "k51name"
+	network.NamePublish(t.Context(), head, "head")
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	kubo := NewMemoryIPFS()
+	kubo.SetOffline(true)
+	client := NewFallbackClient(kubo,
+		NewGatewayClient([]string{dead.URL, newFakeGateway(t, network).URL + "/"}),
+		filepath.Join(t.TempDir(), "queue.json"))
+
+	resolved, err := client.NameResolve(t.Context(), "k51name")
+	if err != nil || resolved != head {
+		t.Fatalf("NameResolve = %q, %v; want %s", resolved, err, head)
+	}
+	dst := openFreshRepo(t)
+	if err := Pull(t.Context(), dst, client, resolved); err != nil {
+		t.Fatalf("Pull through gateway: %v", err)
+	}
+	if _, err := dst.Commits.Resolve(head); err != nil {
+		t.Errorf("pulled commit missing: %v", err)
+	}
+	if _, err := client.BlockPut(t.Context(), []byte("x"), BlockCIDCodec, BlockMhType); err == nil {
+		t.Error("BlockPut succeeded with Kubo down")
+	}
+}
+
+func TestFallbackClient_QueuesPublishUntilKuboReturns(t *testing.T) {
+	kubo := NewMemoryIPFS()
+	kubo.keys["head"] = "k51name"
+	kubo.SetOffline(true)
+	client := NewFallbackClient(kubo, NewGatewayClient(nil), filepath.Join(t.TempDir(), "queue.json"))
+
+	if err := client.NamePublish(t.Context(), "bafyold", "head"); !errors.Is(err, ErrPublishQueued) {
+		t.Fatalf("NamePublish offline: err = %v, want ErrPublishQueued", err)
+	}
+	client.NamePublish(t.Context(), "bafynew", "head")
+	queue, err := client.Queue()
+	if err != nil || len(queue) != 1 || queue[0].CID != "bafynew" {
+		t.Fatalf("queue = %+v, %v; want only the latest publish", queue, err)
+	}
+
+	kubo.SetOffline(false)
+	client.checked = time.Time{} // expire the cached probe
+	if !client.IsAvailable(t.Context()) {
+		t.Fatal("Kubo back but not available")
+	}
+	if got, _ := kubo.NameResolve(t.Context(), "k51name"); got != "bafynew" {
+		t.Errorf("after Kubo returned, name resolves to %q, want bafynew", got)
+	}
+	if queue, _ := client.Queue(); len(queue) != 0 {
+		t.Errorf("queue not drained: %+v", queue)
+	}
+}
@@ -2,6 +2,7 @@ package dagit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,34 @@ import (
 	"time"
 )
 
+// IPFSClient is the IPFS surface memex-fs depends on. KuboClient talks to a
+// real daemon; MemoryIPFS stands in for one in tests. Every call honors
+// ctx cancellation and deadlines.
+type IPFSClient interface {
+	IsAvailable(ctx context.Context) bool
+	Add(ctx context.Context, content []byte) (string, error)
+	Cat(ctx context.Context, cid string) ([]byte, error)
+	Pin(ctx context.Context, cid string) error
+	BlockPut(ctx context.Context, data []byte, cidCodec, mhType string) (string, error)
+	BlockGet(ctx context.Context, cid string) ([]byte, error)
+	KeyList(ctx context.Context) ([]KeyInfo, error)
+	KeyImport(ctx context.Context, name, pemBody string) error
+	NamePublish(ctx context.Context, cid, keyName string) error
+	NameResolve(ctx context.Context, ipnsName string) (string, error)
+}
+
+var _ IPFSClient = (*KuboClient)(nil)
+
+// Default per-call timeouts, applied only when the caller's context has no
+// deadline of its own. IPNS operations walk the DHT and need far longer
+// than plain block traffic.
+const (
+	kuboTimeout        = 10 * time.Second
+	kuboProbeTimeout   = 2 * time.Second
+	kuboPublishTimeout = 60 * time.Second
+	kuboResolveTimeout = 30 * time.Second
+)
+
 // KuboClient is an HTTP client for the Kubo (IPFS) daemon API.
 type KuboClient struct {
 	apiURL string
@@ -27,14 +56,49 @@ type KeyInfo struct {
 func NewKuboClient(apiURL string) *KuboClient {
 	return &KuboClient{
 		apiURL: strings.TrimRight(apiURL, "/"),
-		client: &http.Client{Timeout: 10 * time.Second},
+		client: &http.Client{},
+	}
+}
+
+// post issues an RPC call bound to ctx. If ctx has no deadline, timeout
+// applies; its timer is released when the response body is closed.
+func (k *KuboClient) post(ctx context.Context, timeout time.Duration, url, contentType string, body io.Reader) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's timeout once its body is consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
 }
 
 // IsAvailable checks if the Kubo daemon is reachable.
-func (k *KuboClient) IsAvailable() bool {
-	c := &http.Client{Timeout: 2 * time.Second}
-	resp, err := c.Post(k.apiURL+"/id", "", nil)
+func (k *KuboClient) IsAvailable(ctx context.Context) bool {
+	resp, err := k.post(ctx, kuboProbeTimeout, k.apiURL+"/id", "", nil)
 	if err != nil {
 		return false
 	}
@@ -43,7 +107,7 @@ func (k *KuboClient) IsAvailable() bool {
 }
 
 // Add uploads content to IPFS and returns the CID.
-func (k *KuboClient) Add(content []byte) (string, error) {
+func (k *KuboClient) Add(ctx context.Context, content []byte) (string, error) {
 	var buf bytes.Buffer
 	w := multipart.NewWriter(&buf)
 	part, err := w.CreateFormFile("file", "data")
@@ -55,7 +119,7 @@ func (k *KuboClient) Add(content []byte) (string, error) {
 	}
 	w.Close()
 
-	resp, err := k.client.Post(k.apiURL+"/add", w.FormDataContentType(), &buf)
+	resp, err := k.post(ctx, kuboTimeout, k.apiURL+"/add", w.FormDataContentType(), &buf)
 	if err != nil {
 		return "", fmt.Errorf("ipfs add: %w", err)
 	}
@@ -76,8 +140,8 @@ func (k *KuboClient) Add(content []byte) (string, error) {
 }
 
 // Cat retrieves content from IPFS by CID.
-func (k *KuboClient) Cat(cid string) ([]byte, error) {
-	resp, err := k.client.Post(k.apiURL+"/cat?arg="+cid, "", nil)
+func (k *KuboClient) Cat(ctx context.Context, cid string) ([]byte, error) {
+	resp, err := k.post(ctx, kuboTimeout, k.apiURL+"/cat?arg="+cid, "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("ipfs cat: %w", err)
 	}
@@ -92,8 +156,8 @@ func (k *KuboClient) Cat(cid string) ([]byte, error) {
 }
 
 // Pin pins content to prevent garbage collection.
-func (k *KuboClient) Pin(cid string) error {
-	resp, err := k.client.Post(k.apiURL+"/pin/add?arg="+cid, "", nil)
+func (k *KuboClient) Pin(ctx context.Context, cid string) error {
+	resp, err := k.post(ctx, kuboTimeout, k.apiURL+"/pin/add?arg="+cid, "", nil)
 	if err != nil {
 		return fmt.Errorf("ipfs pin: %w", err)
 	}
@@ -109,7 +173,7 @@ func (k *KuboClient) Pin(cid string) error {
 // multihash type. The returned CID preserves the input — no unixfs wrapping
 // — so CIDs computed by memex-fs (CIDv1, raw codec, sha2-256) round-trip
 // through IPFS unchanged. This is the primitive behind memex-fs push.
-func (k *KuboClient) BlockPut(data []byte, cidCodec, mhType string) (string, error) {
+func (k *KuboClient) BlockPut(ctx context.Context, data []byte, cidCodec, mhType string) (string, error) {
 	var buf bytes.Buffer
 	w := multipart.NewWriter(&buf)
 	part, err := w.CreateFormFile("data", "block")
@@ -122,7 +186,7 @@ func (k *KuboClient) BlockPut(data []byte, cidCodec, mhType string) (string, err
 	w.Close()
 
 	url := fmt.Sprintf("%s/block/put?cid-codec=%s&mhtype=%s", k.apiURL, cidCodec, mhType)
-	resp, err := k.client.Post(url, w.FormDataContentType(), &buf)
+	resp, err := k.post(ctx, kuboTimeout, url, w.FormDataContentType(), &buf)
 	if err != nil {
 		return "", fmt.Errorf("ipfs block/put: %w", err)
 	}
@@ -145,8 +209,8 @@ func (k *KuboClient) BlockPut(data []byte, cidCodec, mhType string) (string, err
 // BlockGet fetches the raw bytes of an IPLD block by CID. The bytes are
 // returned exactly as stored — no unixfs unwrapping — so they can be fed
 // back into memex-fs's ObjectStore under the same CID.
-func (k *KuboClient) BlockGet(cid string) ([]byte, error) {
-	resp, err := k.client.Post(k.apiURL+"/block/get?arg="+cid, "", nil)
+func (k *KuboClient) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	resp, err := k.post(ctx, kuboTimeout, k.apiURL+"/block/get?arg="+cid, "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("ipfs block/get: %w", err)
 	}
@@ -160,8 +224,8 @@ func (k *KuboClient) BlockGet(cid string) ([]byte, error) {
 }
 
 // KeyList lists all keys in the Kubo keystore.
-func (k *KuboClient) KeyList() ([]KeyInfo, error) {
-	resp, err := k.client.Post(k.apiURL+"/key/list", "", nil)
+func (k *KuboClient) KeyList(ctx context.Context) ([]KeyInfo, error) {
+	resp, err := k.post(ctx, kuboTimeout, k.apiURL+"/key/list", "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("ipfs key/list: %w", err)
 	}
@@ -182,7 +246,7 @@ func (k *KuboClient) KeyList() ([]KeyInfo, error) {
 }
 
 // KeyImport imports a PEM-encoded PKCS8 private key into the Kubo keystore.
-func (k *KuboClient) KeyImport(name, pemBody string) error {
+func (k *KuboClient) KeyImport(ctx context.Context, name, pemBody string) error {
 	var buf bytes.Buffer
 	w := multipart.NewWriter(&buf)
 	part, err := w.CreateFormFile("file", "key.pem")
@@ -195,7 +259,7 @@ func (k *KuboClient) KeyImport(name, pemBody string) error {
 	w.Close()
 
 	url := fmt.Sprintf("%s/key/import?arg=%s&format=pem-pkcs8-cleartext", k.apiURL, name)
-	resp, err := k.client.Post(url, w.FormDataContentType(), &buf)
+	resp, err := k.post(ctx, kuboTimeout, url, w.FormDataContentType(), &buf)
 	if err != nil {
 		return fmt.Errorf("ipfs key/import: %w", err)
 	}
@@ -208,10 +272,9 @@ func (k *KuboClient) KeyImport(name, pemBody string) error {
 }
 
 // NamePublish publishes a CID under an IPNS name using the given key.
-func (k *KuboClient) NamePublish(cid, keyName string) error {
-	c := &http.Client{Timeout: 60 * time.Second}
+func (k *KuboClient) NamePublish(ctx context.Context, cid, keyName string) error {
 	url := fmt.Sprintf("%s/name/publish?arg=/ipfs/%s&key=%s", k.apiURL, cid, keyName)
-	resp, err := c.Post(url, "", nil)
+	resp, err := k.post(ctx, kuboPublishTimeout, url, "", nil)
 	if err != nil {
 		return fmt.Errorf("ipfs name/publish: %w", err)
 	}
@@ -224,9 +287,8 @@ func (k *KuboClient) NamePublish(cid, keyName string) error {
 }
 
 // NameResolve resolves an IPNS name to a CID (without /ipfs/ prefix).
-func (k *KuboClient) NameResolve(ipnsName string) (string, error) {
-	c := &http.Client{Timeout: 30 * time.Second}
-	resp, err := c.Post(k.apiURL+"/name/resolve?arg="+ipnsName, "", nil)
+func (k *KuboClient) NameResolve(ctx context.Context, ipnsName string) (string, error) {
+	resp, err := k.post(ctx, kuboResolveTimeout, k.apiURL+"/name/resolve?arg="+ipnsName, "", nil)
 	if err != nil {
 		return "", fmt.Errorf("ipfs name/resolve: %w", err)
 	}
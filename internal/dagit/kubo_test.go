@@ -0,0 +1,104 @@
+package dagit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+	"github.com/systemshift/memex-fs/internal/dagit"
+	"github.com/systemshift/memex-fs/internal/dagit/kubotest"
+)
+
+// TestKuboClient_PublishAndPullByDID runs the push --publish / pull <did>
+// flow through the real HTTP client against a mock Kubo.
+func TestKuboClient_PublishAndPullByDID(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := kubotest.NewServer()
+	defer server.Close()
+	kubo := dagit.NewKuboClient(server.APIURL())
+
+	if !kubo.IsAvailable(context.Background()) {
+		t.Fatal("mock Kubo not available")
+	}
+
+	repoA, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoA.CreateNode("note:a", "Note", []byte("shared"), nil)
+	repoA.UpdateContent("note:a", []byte("shared, edited"))
+
+	head, err := dagit.Push(context.Background(), repoA, kubo)
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if server.IPFS.Len() < 3 {
+		t.Errorf("mock holds %d blocks after push, want commit + node versions", server.IPFS.Len())
+	}
+
+	identity, err := dag.LoadIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dagit.EnsureKey(context.Background(), kubo, identity, dagit.HeadKeyName); err != nil {
+		t.Fatalf("EnsureKey: %v", err)
+	}
+	if err := dagit.EnsureKey(context.Background(), kubo, identity, dagit.HeadKeyName); err != nil {
+		t.Fatalf("EnsureKey should be idempotent: %v", err)
+	}
+	if err := kubo.NamePublish(context.Background(), head, dagit.HeadKeyName); err != nil {
+		t.Fatalf("NamePublish: %v", err)
+	}
+
+	ipnsName, err := dagit.DIDToIPNSName(identity.DID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := kubo.NameResolve(context.Background(), ipnsName)
+	if err != nil {
+		t.Fatalf("NameResolve: %v", err)
+	}
+	if resolved != head {
+		t.Fatalf("resolved %s, want %s", resolved, head)
+	}
+
+	repoB, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dagit.Pull(context.Background(), repoB, kubo, resolved); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	commit, err := repoB.Commits.Resolve(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := dag.NewSnapshot(commit, repoB.Store).GetNode("note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(node.Content) != "shared, edited" {
+		t.Errorf("pulled content = %q", node.Content)
+	}
+}
+
+func TestKuboClient_ErrorsAndOffline(t *testing.T) {
+	server := kubotest.NewServer()
+	defer server.Close()
+	kubo := dagit.NewKuboClient(server.APIURL())
+
+	if _, err := kubo.BlockGet(context.Background(), "bafkreimissing"); err == nil {
+		t.Error("BlockGet of a missing block should fail")
+	}
+	if err := kubo.NamePublish(context.Background(), "bafkreimissing", "no-such-key"); err == nil {
+		t.Error("NamePublish with an unknown key should fail")
+	}
+
+	server.IPFS.SetOffline(true)
+	if kubo.IsAvailable(context.Background()) {
+		t.Error("IsAvailable = true while offline")
+	}
+	if _, err := kubo.Add(context.Background(), []byte("x")); err == nil {
+		t.Error("Add should fail while offline")
+	}
+}
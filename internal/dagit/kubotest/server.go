@@ -0,0 +1,190 @@
+// Package kubotest serves a mock Kubo RPC API for tests, so the real
+// KuboClient can be exercised over HTTP without an IPFS daemon.
+package kubotest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/systemshift/memex-fs/internal/dagit"
+)
+
+// Server is an httptest server speaking the subset of the Kubo RPC API
+// that KuboClient uses, backed by a MemoryIPFS. Tests can reach into IPFS
+// to seed or inspect state, or take it offline.
+type Server struct {
+	*httptest.Server
+	IPFS *dagit.MemoryIPFS
+}
+
+// NewServer starts a mock Kubo. Call Close when done.
+func NewServer() *Server {
+	s := &Server{IPFS: dagit.NewMemoryIPFS()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/id", s.id)
+	mux.HandleFunc("/api/v0/add", s.add)
+	mux.HandleFunc("/api/v0/cat", s.cat)
+	mux.HandleFunc("/api/v0/pin/add", s.pinAdd)
+	mux.HandleFunc("/api/v0/block/put", s.blockPut)
+	mux.HandleFunc("/api/v0/block/get", s.blockGet)
+	mux.HandleFunc("/api/v0/key/list", s.keyList)
+	mux.HandleFunc("/api/v0/key/import", s.keyImport)
+	mux.HandleFunc("/api/v0/name/publish", s.namePublish)
+	mux.HandleFunc("/api/v0/name/resolve", s.nameResolve)
+	s.Server = httptest.NewServer(rpcOnly(mux))
+	return s
+}
+
+// APIURL is the value to pass to dagit.NewKuboClient.
+func (s *Server) APIURL() string {
+	return s.URL + "/api/v0"
+}
+
+// rpcOnly rejects anything but POST, as Kubo does.
+func rpcOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fail writes a Kubo-style error body.
+func fail(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Message": err.Error(),
+		"Code":    0,
+		"Type":    "error",
+	})
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// formFile reads the first file part of a multipart request.
+func formFile(r *http.Request) ([]byte, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+	return io.ReadAll(part)
+}
+
+func (s *Server) id(w http.ResponseWriter, r *http.Request) {
+	if !s.IPFS.IsAvailable(r.Context()) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	reply(w, map[string]string{"ID": "12D3KooWMockKubo"})
+}
+
+func (s *Server) add(w http.ResponseWriter, r *http.Request) {
+	data, err := formFile(r)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	c, err := s.IPFS.Add(r.Context(), data)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]string{"Name": "data", "Hash": c})
+}
+
+func (s *Server) cat(w http.ResponseWriter, r *http.Request) {
+	data, err := s.IPFS.Cat(r.Context(), r.URL.Query().Get("arg"))
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) pinAdd(w http.ResponseWriter, r *http.Request) {
+	c := r.URL.Query().Get("arg")
+	if err := s.IPFS.Pin(r.Context(), c); err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string][]string{"Pins": {c}})
+}
+
+func (s *Server) blockPut(w http.ResponseWriter, r *http.Request) {
+	data, err := formFile(r)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	q := r.URL.Query()
+	c, err := s.IPFS.BlockPut(r.Context(), data, q.Get("cid-codec"), q.Get("mhtype"))
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]interface{}{"Key": c, "Size": len(data)})
+}
+
+func (s *Server) blockGet(w http.ResponseWriter, r *http.Request) {
+	data, err := s.IPFS.BlockGet(r.Context(), r.URL.Query().Get("arg"))
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) keyList(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.IPFS.KeyList(r.Context())
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]interface{}{"Keys": keys})
+}
+
+func (s *Server) keyImport(w http.ResponseWriter, r *http.Request) {
+	body, err := formFile(r)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	name := r.URL.Query().Get("arg")
+	if err := s.IPFS.KeyImport(r.Context(), name, string(body)); err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]string{"Name": name})
+}
+
+func (s *Server) namePublish(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := s.IPFS.NamePublish(r.Context(), q.Get("arg"), q.Get("key")); err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]string{"Value": q.Get("arg")})
+}
+
+func (s *Server) nameResolve(w http.ResponseWriter, r *http.Request) {
+	c, err := s.IPFS.NameResolve(r.Context(), r.URL.Query().Get("arg"))
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	reply(w, map[string]string{"Path": "/ipfs/" + strings.TrimPrefix(c, "/ipfs/")})
+}
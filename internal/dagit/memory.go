@@ -0,0 +1,196 @@
+package dagit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// MemoryIPFS is an in-memory IPFSClient. Blocks are hashed with the
+// codecs and hashes the ObjectStore supports, so CIDs survive a push/pull
+// round-trip, and imported keys get the same IPNS names Kubo
+// would derive, so DID resolution works end to end. Tests use it in place
+// of a daemon; kubotest serves one over the Kubo HTTP API.
+type MemoryIPFS struct {
+	mu      sync.Mutex
+	blocks  map[string][]byte
+	pinned  map[string]bool
+	keys    map[string]string // key name -> IPNS name
+	names   map[string]string // IPNS name -> CID
+	offline bool
+}
+
+var _ IPFSClient = (*MemoryIPFS)(nil)
+
+// NewMemoryIPFS creates an empty, available MemoryIPFS.
+func NewMemoryIPFS() *MemoryIPFS {
+	return &MemoryIPFS{
+		blocks: make(map[string][]byte),
+		pinned: make(map[string]bool),
+		keys:   make(map[string]string),
+		names:  make(map[string]string),
+	}
+}
+
+// SetOffline makes every call fail as if the daemon were unreachable.
+func (m *MemoryIPFS) SetOffline(offline bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offline = offline
+}
+
+// Pinned reports whether cid has been pinned.
+func (m *MemoryIPFS) Pinned(cid string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pinned[cid]
+}
+
+// Len returns how many blocks are stored.
+func (m *MemoryIPFS) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.blocks)
+}
+
+var errOffline = fmt.Errorf("memory ipfs: offline")
+
+// check fails a call whose context is done, or any call while offline.
+// Callers hold m.mu.
+func (m *MemoryIPFS) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.offline {
+		return errOffline
+	}
+	return nil
+}
+
+func (m *MemoryIPFS) IsAvailable(ctx context.Context) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.check(ctx) == nil
+}
+
+// Add stores content as a raw block. Unlike Kubo there is no unixfs
+// wrapping, so Add and BlockPut of the same bytes give the same CID.
+func (m *MemoryIPFS) Add(ctx context.Context, content []byte) (string, error) {
+	return m.BlockPut(ctx, content, BlockCIDCodec, BlockMhType)
+}
+
+func (m *MemoryIPFS) Cat(ctx context.Context, cid string) ([]byte, error) {
+	return m.BlockGet(ctx, cid)
+}
+
+func (m *MemoryIPFS) Pin(ctx context.Context, cid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	if _, ok := m.blocks[cid]; !ok {
+		return fmt.Errorf("memory ipfs: pin: not found: %s", cid)
+	}
+	m.pinned[cid] = true
+	return nil
+}
+
+func (m *MemoryIPFS) BlockPut(ctx context.Context, data []byte, cidCodec, mhType string) (string, error) {
+	c, err := dag.ObjectFormat{Hash: mhType, Codec: cidCodec}.Sum(data)
+	if err != nil {
+		return "", fmt.Errorf("memory ipfs: %w", err)
+	}
+	key := dag.CIDToFilename(c)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return "", err
+	}
+	m.blocks[key] = append([]byte(nil), data...)
+	return key, nil
+}
+
+func (m *MemoryIPFS) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	data, ok := m.blocks[cid]
+	if !ok {
+		return nil, fmt.Errorf("memory ipfs: not found: %s", cid)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *MemoryIPFS) KeyList(ctx context.Context) ([]KeyInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	keys := make([]KeyInfo, 0, len(m.keys))
+	for name, id := range m.keys {
+		keys = append(keys, KeyInfo{Name: name, ID: id})
+	}
+	return keys, nil
+}
+
+// KeyImport accepts the PEM PKCS8 Ed25519 keys EnsureKey produces.
+func (m *MemoryIPFS) KeyImport(ctx context.Context, name, pemBody string) error {
+	block, _ := pem.Decode([]byte(pemBody))
+	if block == nil || len(block.Bytes) != len(pkcs8Prefix)+ed25519.SeedSize {
+		return fmt.Errorf("memory ipfs: key import: not a PKCS8 Ed25519 key")
+	}
+	seed := block.Bytes[len(pkcs8Prefix):]
+	pub := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	ipnsName, err := ipnsNameForKey(pub)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	if _, ok := m.keys[name]; ok {
+		return fmt.Errorf("memory ipfs: key import: key with name %q already exists", name)
+	}
+	m.keys[name] = ipnsName
+	return nil
+}
+
+func (m *MemoryIPFS) NamePublish(ctx context.Context, cid, keyName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	ipnsName, ok := m.keys[keyName]
+	if !ok {
+		return fmt.Errorf("memory ipfs: name publish: no key named %q", keyName)
+	}
+	m.names[ipnsName] = strings.TrimPrefix(cid, "/ipfs/")
+	return nil
+}
+
+func (m *MemoryIPFS) NameResolve(ctx context.Context, ipnsName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx); err != nil {
+		return "", err
+	}
+	cid, ok := m.names[strings.TrimPrefix(ipnsName, "/ipns/")]
+	if !ok {
+		return "", fmt.Errorf("memory ipfs: name resolve: %s not published", ipnsName)
+	}
+	return cid, nil
+}
@@ -1,29 +1,41 @@
 package dagit
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	gocid "github.com/ipfs/go-cid"
 	"github.com/multiformats/go-multibase"
 	"github.com/systemshift/memex-fs/internal/dag"
 )
 
-// BlockCIDCodec and BlockMhType mirror the encoding memex-fs uses for its
-// ObjectStore: CIDv1, raw codec (0x55), SHA2-256. Preserving these through
-// IPFS block/put means the same CID survives a push/pull round-trip.
+// BlockCIDCodec and BlockMhType mirror the default encoding memex-fs uses
+// for its ObjectStore: CIDv1, raw codec (0x55), SHA2-256. Objects in
+// another format are put with their own codec and hash (see blockFormat),
+// so the same CID survives a push/pull round-trip either way.
 const (
 	BlockCIDCodec = "raw"
 	BlockMhType   = "sha2-256"
 )
 
+// blockFormat returns the block/put codec and mhtype that reproduce c.
+func blockFormat(c gocid.Cid) (codec, mhType string) {
+	if f, ok := dag.FormatOf(c); ok {
+		return f.Codec, f.Hash
+	}
+	return BlockCIDCodec, BlockMhType
+}
+
 // kuboAPI is the subset of KuboClient that Push/Pull need. Extracting it
 // lets the tests swap in a fake backend without standing up a real Kubo
 // daemon.
 type kuboAPI interface {
-	BlockPut(data []byte, codec, mhType string) (string, error)
-	BlockGet(cid string) ([]byte, error)
-	Pin(cid string) error
+	BlockPut(ctx context.Context, data []byte, codec, mhType string) (string, error)
+	BlockGet(ctx context.Context, cid string) ([]byte, error)
+	Pin(ctx context.Context, cid string) error
 }
 
 // Push uploads every object reachable from HEAD (the commit, all ref node
@@ -32,7 +44,97 @@ type kuboAPI interface {
 // as memex-fs, the returned HEAD CID is identical to repo.Commits.Head().
 //
 // After push, share the HEAD CID and any peer can `memex-fs pull <cid>`.
-func Push(repo *dag.Repository, kubo kuboAPI) (string, error) {
+// Cancelling ctx stops the walk between objects and aborts any upload in
+// flight; blocks already uploaded stay in IPFS, so a rerun resumes cheaply.
+func Push(ctx context.Context, repo *dag.Repository, kubo kuboAPI) (string, error) {
+	return PushWith(ctx, repo, kubo, PushOptions{})
+}
+
+// PushOptions makes a push incremental and resumable. The zero value
+// uploads everything reachable from HEAD.
+type PushOptions struct {
+	// Known is a commit the remote already holds with everything reachable
+	// from it, usually its HEAD; the walk stops there and at the node
+	// versions it names. Ignored if the commit is not held locally.
+	Known string
+	// Journal is a file recording each object uploaded, so a push that was
+	// interrupted skips them when run again. It is removed once the push
+	// completes.
+	Journal string
+	// Progress, if set, is called after each object uploaded with the
+	// number uploaded so far.
+	Progress func(n int)
+}
+
+// pushState tracks one push: the objects the remote has, and the journal
+// an interrupted push resumes from.
+type pushState struct {
+	pushed   map[string]bool // on the remote with all they reach; walks stop here
+	resumed  map[string]bool // uploaded by an interrupted push; walked, not re-sent
+	journal  *os.File
+	progress func(n int)
+	n        int
+}
+
+// newPushState seeds a push with what opts says the remote already has.
+func newPushState(repo *dag.Repository, opts PushOptions) (*pushState, error) {
+	st := &pushState{pushed: make(map[string]bool), resumed: make(map[string]bool), progress: opts.Progress}
+	if c, err := decodeCID(opts.Known); err == nil && repo.Store.Has(c) {
+		if known, err := repo.Commits.GetCommit(c); err == nil {
+			st.pushed[opts.Known] = true
+			for _, v := range known.Refs {
+				st.pushed[v] = true
+			}
+		}
+	}
+	if opts.Journal == "" {
+		return st, nil
+	}
+	if data, err := os.ReadFile(opts.Journal); err == nil {
+		for _, key := range strings.Fields(string(data)) {
+			st.resumed[key] = true
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Journal), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(opts.Journal, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open push journal: %w", err)
+	}
+	st.journal = f
+	return st, nil
+}
+
+// put uploads the object c unless an interrupted push already did, and
+// records it.
+func (st *pushState) put(ctx context.Context, kubo kuboAPI, c gocid.Cid, data []byte) error {
+	key := dag.CIDToFilename(c)
+	st.pushed[key] = true
+	if st.resumed[key] {
+		return nil
+	}
+	codec, mhType := blockFormat(c)
+	returned, err := kubo.BlockPut(ctx, data, codec, mhType)
+	if err != nil {
+		return fmt.Errorf("push %s: %w", key, err)
+	}
+	if returned != key {
+		return fmt.Errorf("CID mismatch pushing %s: remote returned %s", key, returned)
+	}
+	if st.journal != nil {
+		fmt.Fprintln(st.journal, key)
+	}
+	st.n++
+	if st.progress != nil {
+		st.progress(st.n)
+	}
+	return nil
+}
+
+// PushWith is Push narrowed and journaled by opts. HEAD's commit is
+// uploaded last, so a remote never holds it without what it names.
+func PushWith(ctx context.Context, repo *dag.Repository, kubo kuboAPI, opts PushOptions) (string, error) {
 	head, err := repo.Commits.Head()
 	if err != nil {
 		return "", fmt.Errorf("read HEAD: %w", err)
@@ -41,22 +143,30 @@ func Push(repo *dag.Repository, kubo kuboAPI) (string, error) {
 		return "", fmt.Errorf("nothing to push: no commits yet")
 	}
 
-	pushed := make(map[string]bool)
-	if err := pushObject(repo, kubo, head, pushed); err != nil {
+	st, err := newPushState(repo, opts)
+	if err != nil {
 		return "", err
 	}
-
 	commit, err := repo.Commits.GetCommit(head)
 	if err != nil {
 		return "", fmt.Errorf("load HEAD commit: %w", err)
 	}
-	if err := pushCommitRefs(repo, kubo, commit, pushed); err != nil {
-		return "", err
+	err = pushCommitRefs(ctx, repo, kubo, commit, st)
+	if err == nil {
+		err = pushCommitParents(ctx, repo, kubo, commit, st)
+	}
+	if err == nil {
+		err = pushObject(ctx, repo, kubo, head, st)
 	}
-	if err := pushCommitParents(repo, kubo, commit, pushed); err != nil {
+	if st.journal != nil {
+		st.journal.Close()
+		if err == nil {
+			os.Remove(opts.Journal)
+		}
+	}
+	if err != nil {
 		return "", err
 	}
-
 	return dag.CIDToFilename(head), nil
 }
 
@@ -64,36 +174,31 @@ func Push(repo *dag.Repository, kubo kuboAPI) (string, error) {
 // to IPFS via block/put. No-op if already pushed in this run. The local
 // store is authoritative for bytes — if a CID isn't present, that's an
 // error (corrupted repo), not a reason to skip.
-func pushObject(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, pushed map[string]bool) error {
+func pushObject(ctx context.Context, repo *dag.Repository, kubo kuboAPI, c gocid.Cid, st *pushState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := dag.CIDToFilename(c)
-	if pushed[key] {
+	if st.pushed[key] {
 		return nil
 	}
 	data, err := repo.Store.Get(c)
 	if err != nil {
 		return fmt.Errorf("read local object %s: %w", key, err)
 	}
-	returned, err := kubo.BlockPut(data, BlockCIDCodec, BlockMhType)
-	if err != nil {
-		return fmt.Errorf("push %s: %w", key, err)
-	}
-	if returned != key {
-		return fmt.Errorf("CID mismatch pushing %s: ipfs returned %s", key, returned)
-	}
-	pushed[key] = true
-	return nil
+	return st.put(ctx, kubo, c, data)
 }
 
 // pushCommitRefs uploads every referenced node envelope, then recurses
 // through each node's Prev pointer so the full history of each node is
 // preserved (not just the latest version).
-func pushCommitRefs(repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, pushed map[string]bool) error {
+func pushCommitRefs(ctx context.Context, repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, st *pushState) error {
 	for _, cidStr := range commit.Refs {
 		c, err := decodeCID(cidStr)
 		if err != nil {
 			return err
 		}
-		if err := pushNodeAndPrev(repo, kubo, c, pushed); err != nil {
+		if err := pushNodeAndPrev(ctx, repo, kubo, c, st); err != nil {
 			return err
 		}
 	}
@@ -102,23 +207,27 @@ func pushCommitRefs(repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject
 
 // pushNodeAndPrev walks a single node's version chain (newest to oldest)
 // via NodeEnvelope.Prev, uploading each version.
-func pushNodeAndPrev(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, pushed map[string]bool) error {
+func pushNodeAndPrev(ctx context.Context, repo *dag.Repository, kubo kuboAPI, c gocid.Cid, st *pushState) error {
 	current := c
 	for {
-		if pushed[dag.CIDToFilename(current)] {
+		if st.pushed[dag.CIDToFilename(current)] {
 			return nil
 		}
 		data, err := repo.Store.Get(current)
 		if err != nil {
 			return fmt.Errorf("read node object: %w", err)
 		}
-		if _, err := kubo.BlockPut(data, BlockCIDCodec, BlockMhType); err != nil {
-			return fmt.Errorf("push node: %w", err)
+		if err := st.put(ctx, kubo, current, data); err != nil {
+			return err
+		}
+		if blob, ok := dag.ContentLink(data); ok {
+			if err := pushObject(ctx, repo, kubo, blob, st); err != nil {
+				return err
+			}
 		}
-		pushed[dag.CIDToFilename(current)] = true
 
-		var node dag.NodeEnvelope
-		if err := json.Unmarshal(data, &node); err != nil {
+		node, err := dag.DecodeNode(data, nil)
+		if err != nil {
 			// Non-fatal: if the object isn't a node envelope, we've pushed
 			// its bytes and stop here.
 			return nil
@@ -135,65 +244,164 @@ func pushNodeAndPrev(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, pushed map
 }
 
 // pushCommitParents walks the commit chain backward and uploads each
-// ancestor commit. Node refs from older commits are NOT re-pushed —
-// the per-node Prev chains already covered them.
-func pushCommitParents(repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, pushed map[string]bool) error {
+// ancestor commit, and the chains merge commits brought in. Node refs from
+// older commits are NOT re-pushed — the per-node Prev chains already
+// covered them.
+func pushCommitParents(ctx context.Context, repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, st *pushState) error {
+	if commit.Merged != "" {
+		if err := pushCommitParents(ctx, repo, kubo, &dag.CommitObject{Parent: commit.Merged}, st); err != nil {
+			return err
+		}
+	}
 	parentStr := commit.Parent
 	for parentStr != "" {
 		c, err := decodeCID(parentStr)
 		if err != nil {
 			return err
 		}
-		if pushed[dag.CIDToFilename(c)] {
+		if st.pushed[dag.CIDToFilename(c)] {
 			return nil
 		}
-		if err := pushObject(repo, kubo, c, pushed); err != nil {
+		if err := pushObject(ctx, repo, kubo, c, st); err != nil {
 			return err
 		}
 		parent, err := repo.Commits.GetCommit(c)
 		if err != nil {
 			return fmt.Errorf("load parent commit: %w", err)
 		}
+		if parent.Merged != "" {
+			if err := pushCommitParents(ctx, repo, kubo, &dag.CommitObject{Parent: parent.Merged}, st); err != nil {
+				return err
+			}
+		}
 		parentStr = parent.Parent
 	}
 	return nil
 }
 
+// PullOptions narrows a pull to part of the source history. The zero value
+// pulls everything.
+type PullOptions struct {
+	// Depth pulls only the newest Depth commits and, of each node, only the
+	// versions those commits name; 0 pulls all history.
+	Depth int
+	// Sparse limits the nodes pulled in full. The envelope a commit names
+	// is still fetched for every node, to match it against the filter.
+	Sparse dag.SparseFilter
+	// Progress, if set, is called after each object fetched with the
+	// number fetched so far. Objects already held are not fetched, so a
+	// pull that was interrupted picks up where it stopped.
+	Progress func(n int)
+}
+
+// countingAPI reports each block fetched through it.
+type countingAPI struct {
+	kuboAPI
+	progress func(n int)
+	n        int
+}
+
+func (c *countingAPI) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	data, err := c.kuboAPI.BlockGet(ctx, cid)
+	if err == nil {
+		c.n++
+		c.progress(c.n)
+	}
+	return data, err
+}
+
 // Pull fetches a commit CID and every object reachable from it into the
 // local ObjectStore. Does NOT update local refs or HEAD — the pulled
 // snapshot is browsable via /at/{cid}/, and importing specific nodes is
-// left as an explicit user action.
+// left as an explicit user action (or Repository.Checkout, into an empty
+// repository).
 //
 // If the same CID has been pulled before, the local ObjectStore already
-// has the bytes and we skip the network call.
-func Pull(repo *dag.Repository, kubo kuboAPI, headCIDStr string) error {
+// has the bytes and we skip the network call. That also makes a pull
+// cancelled through ctx cheap to resume.
+func Pull(ctx context.Context, repo *dag.Repository, kubo kuboAPI, headCIDStr string) error {
+	return PullWith(ctx, repo, kubo, headCIDStr, PullOptions{})
+}
+
+// PullWith is Pull narrowed by opts. A partial pull is recorded with
+// Repository.MarkShallow; Fetcher fetches what it left out on demand.
+// The pulled history is checked against the ACLs in force along it, and
+// a *dag.ACLError is returned if any commit changed what its author could
+// not write; the objects stay pulled, but should not be checked out.
+func PullWith(ctx context.Context, repo *dag.Repository, kubo kuboAPI, headCIDStr string, opts PullOptions) error {
 	head, err := decodeCID(headCIDStr)
 	if err != nil {
 		return fmt.Errorf("invalid CID: %w", err)
 	}
 
+	if opts.Progress != nil {
+		kubo = &countingAPI{kuboAPI: kubo, progress: opts.Progress}
+	}
 	fetched := make(map[string]bool)
-	if err := pullObject(repo, kubo, head, fetched); err != nil {
+	if err := pullObject(ctx, repo, kubo, head, fetched); err != nil {
 		return err
 	}
-
 	commit, err := repo.Commits.GetCommit(head)
 	if err != nil {
 		return fmt.Errorf("load pulled commit: %w", err)
 	}
-	if err := pullCommitRefs(repo, kubo, commit, fetched); err != nil {
-		return err
+
+	// Walk the commit chain. A full pull takes each node's history from its
+	// prev chain at HEAD; a shallow one takes the versions each pulled
+	// commit names.
+	var pulled, boundary []string
+	current := head
+	for depth := 1; ; depth++ {
+		if depth == 1 || opts.Depth > 0 {
+			if err := pullCommitRefs(ctx, repo, kubo, commit, opts, fetched); err != nil {
+				return err
+			}
+		}
+		if commit.Parent == "" {
+			pulled = append(pulled, dag.CIDToFilename(current))
+			break
+		}
+		if opts.Depth > 0 && depth >= opts.Depth {
+			boundary = append(boundary, dag.CIDToFilename(current))
+			break
+		}
+		pulled = append(pulled, dag.CIDToFilename(current))
+		parent, err := decodeCID(commit.Parent)
+		if err != nil {
+			return err
+		}
+		if err := pullObject(ctx, repo, kubo, parent, fetched); err != nil {
+			return err
+		}
+		if commit, err = repo.Commits.GetCommit(parent); err != nil {
+			return fmt.Errorf("load pulled parent: %w", err)
+		}
+		current = parent
 	}
-	if err := pullCommitParents(repo, kubo, commit, fetched); err != nil {
-		return err
+
+	if opts.Depth > 0 || !opts.Sparse.Empty() || repo.Shallow() != nil {
+		if err := repo.MarkShallow(opts.Depth, opts.Sparse, boundary, pulled); err != nil {
+			return err
+		}
+	}
+	return repo.CheckACLs(head)
+}
+
+// Fetcher reads objects missing from the local store through kubo, for
+// repositories a partial pull left incomplete (see dag.ObjectStore.SetFetcher).
+func Fetcher(ctx context.Context, kubo kuboAPI) dag.ObjectFetcher {
+	return func(c gocid.Cid) ([]byte, error) {
+		return kubo.BlockGet(ctx, dag.CIDToFilename(c))
 	}
-	return nil
 }
 
-// pullObject fetches a single block from IPFS if not already local. After
-// the network fetch, it re-Puts into the local store so the CID is
-// canonicalized (and a disk-format consistency check happens).
-func pullObject(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, fetched map[string]bool) error {
+// pullObject fetches a single block from IPFS if not already local. The
+// block is stored under the CID it was asked for, after checking that its
+// bytes hash to it.
+func pullObject(ctx context.Context, repo *dag.Repository, kubo kuboAPI, c gocid.Cid, fetched map[string]bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := dag.CIDToFilename(c)
 	if fetched[key] {
 		return nil
@@ -202,84 +410,69 @@ func pullObject(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, fetched map[str
 		fetched[key] = true
 		return nil
 	}
-	data, err := kubo.BlockGet(key)
+	data, err := kubo.BlockGet(ctx, key)
 	if err != nil {
 		return fmt.Errorf("fetch %s: %w", key, err)
 	}
-	gotCID, err := repo.Store.Put(data)
-	if err != nil {
+	if err := repo.Store.PutCID(c, data); err != nil {
 		return fmt.Errorf("store %s locally: %w", key, err)
 	}
-	if dag.CIDToFilename(gotCID) != key {
-		return fmt.Errorf("CID mismatch on pull: asked %s, stored as %s", key, dag.CIDToFilename(gotCID))
-	}
 	// Best-effort pin so the remote doesn't GC out from under us.
-	_ = kubo.Pin(key)
+	_ = kubo.Pin(ctx, key)
 	fetched[key] = true
 	return nil
 }
 
-func pullCommitRefs(repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, fetched map[string]bool) error {
+func pullCommitRefs(ctx context.Context, repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, opts PullOptions, fetched map[string]bool) error {
 	for _, cidStr := range commit.Refs {
 		c, err := decodeCID(cidStr)
 		if err != nil {
 			return err
 		}
-		if err := pullNodeAndPrev(repo, kubo, c, fetched); err != nil {
+		if err := pullNodeAndPrev(ctx, repo, kubo, c, opts, fetched); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func pullNodeAndPrev(repo *dag.Repository, kubo kuboAPI, c gocid.Cid, fetched map[string]bool) error {
+// pullNodeAndPrev fetches the node version c with its content and, for a
+// full pull, its prev chain. A version opts.Sparse does not select is
+// fetched alone.
+func pullNodeAndPrev(ctx context.Context, repo *dag.Repository, kubo kuboAPI, c gocid.Cid, opts PullOptions, fetched map[string]bool) error {
 	current := c
 	for {
 		if fetched[dag.CIDToFilename(current)] {
 			return nil
 		}
-		if err := pullObject(repo, kubo, current, fetched); err != nil {
+		if err := pullObject(ctx, repo, kubo, current, fetched); err != nil {
 			return err
 		}
 		data, err := repo.Store.Get(current)
 		if err != nil {
 			return err
 		}
-		var node dag.NodeEnvelope
-		if err := json.Unmarshal(data, &node); err != nil {
+		node, err := dag.DecodeNode(data, nil)
+		if err != nil {
 			return nil // not a node — we've fetched its bytes, done
 		}
-		if node.Prev == "" {
+		if current == c && !opts.Sparse.Match(node) {
 			return nil
 		}
-		prev, err := decodeCID(node.Prev)
-		if err != nil {
-			return err
-		}
-		current = prev
-	}
-}
-
-func pullCommitParents(repo *dag.Repository, kubo kuboAPI, commit *dag.CommitObject, fetched map[string]bool) error {
-	parentStr := commit.Parent
-	for parentStr != "" {
-		c, err := decodeCID(parentStr)
-		if err != nil {
-			return err
+		if blob, ok := dag.ContentLink(data); ok {
+			if err := pullObject(ctx, repo, kubo, blob, fetched); err != nil {
+				return err
+			}
 		}
-		if fetched[dag.CIDToFilename(c)] {
+		if node.Prev == "" || opts.Depth > 0 {
 			return nil
 		}
-		if err := pullObject(repo, kubo, c, fetched); err != nil {
-			return err
-		}
-		parent, err := repo.Commits.GetCommit(c)
+		prev, err := decodeCID(node.Prev)
 		if err != nil {
-			return fmt.Errorf("load pulled parent: %w", err)
+			return err
 		}
-		parentStr = parent.Parent
+		current = prev
 	}
-	return nil
 }
 
 // decodeCID parses a base32-encoded CID string (as produced by CIDToFilename).
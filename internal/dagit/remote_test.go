@@ -1,9 +1,11 @@
 package dagit
 
 import (
+	"context"
 	"crypto/sha256"
-	"fmt"
-	"sync"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	gocid "github.com/ipfs/go-cid"
@@ -12,54 +14,9 @@ import (
 	"github.com/systemshift/memex-fs/internal/dag"
 )
 
-// fakeKubo is an in-memory implementation of the KuboClient subset that
-// Push/Pull use. It hashes uploaded blocks with the same scheme memex-fs
-// uses (CIDv1 raw + sha2-256), so round-trips preserve CIDs.
-type fakeKubo struct {
-	mu      sync.Mutex
-	blocks  map[string][]byte
-	pinned  map[string]bool
-}
-
-func newFakeKubo() *fakeKubo {
-	return &fakeKubo{
-		blocks: make(map[string][]byte),
-		pinned: make(map[string]bool),
-	}
-}
-
-func (f *fakeKubo) BlockPut(data []byte, codec, mhType string) (string, error) {
-	if codec != "raw" || mhType != "sha2-256" {
-		return "", fmt.Errorf("fakeKubo: unsupported codec/mhtype (%s/%s)", codec, mhType)
-	}
-	mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
-	if err != nil {
-		return "", err
-	}
-	c := gocid.NewCidV1(gocid.Raw, mh)
-	key, _ := multibase.Encode(multibase.Base32, c.Bytes())
-
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	f.blocks[key] = append([]byte(nil), data...)
-	return key, nil
-}
-
-func (f *fakeKubo) BlockGet(cidStr string) ([]byte, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	data, ok := f.blocks[cidStr]
-	if !ok {
-		return nil, fmt.Errorf("fakeKubo: not found: %s", cidStr)
-	}
-	return append([]byte(nil), data...), nil
-}
-
-func (f *fakeKubo) Pin(cidStr string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	f.pinned[cidStr] = true
-	return nil
+// newFakeKubo returns an in-memory stand-in for the Kubo daemon.
+func newFakeKubo() *MemoryIPFS {
+	return NewMemoryIPFS()
 }
 
 // openFreshRepo creates a repo in a temp dir. Mirrors dag.openTestRepo
@@ -88,7 +45,7 @@ func TestPush_UploadsAllObjects(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	headCID, err := Push(repo, kubo)
+	headCID, err := Push(context.Background(), repo, kubo)
 	if err != nil {
 		t.Fatalf("Push: %v", err)
 	}
@@ -111,12 +68,37 @@ func TestPush_EmptyRepoFails(t *testing.T) {
 	repo := openFreshRepo(t)
 	kubo := newFakeKubo()
 
-	_, err := Push(repo, kubo)
+	_, err := Push(context.Background(), repo, kubo)
 	if err == nil {
 		t.Error("Push on empty repo should fail")
 	}
 }
 
+func TestPushPull_CancelledContext(t *testing.T) {
+	repo := openFreshRepo(t)
+	kubo := newFakeKubo()
+	if _, err := repo.CreateNode("a", "N", []byte("hello"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Push(ctx, repo, kubo); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Push with cancelled ctx: err = %v, want context.Canceled", err)
+	}
+	if kubo.Len() != 0 {
+		t.Errorf("cancelled push uploaded %d blocks", kubo.Len())
+	}
+
+	head, err := Push(context.Background(), repo, kubo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Pull(ctx, openFreshRepo(t), kubo, head); !errors.Is(err, context.Canceled) {
+		t.Errorf("Pull with cancelled ctx: err = %v, want context.Canceled", err)
+	}
+}
+
 func TestPush_CIDMatchesLocalHead(t *testing.T) {
 	repo := openFreshRepo(t)
 	kubo := newFakeKubo()
@@ -125,7 +107,7 @@ func TestPush_CIDMatchesLocalHead(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	headCID, err := Push(repo, kubo)
+	headCID, err := Push(context.Background(), repo, kubo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -157,14 +139,14 @@ func TestPushPull_RoundTrip(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	headCID, err := Push(repoA, kubo)
+	headCID, err := Push(context.Background(), repoA, kubo)
 	if err != nil {
 		t.Fatalf("Push: %v", err)
 	}
 
 	// Repo B: empty, pull from kubo.
 	repoB := openFreshRepo(t)
-	if err := Pull(repoB, kubo, headCID); err != nil {
+	if err := Pull(context.Background(), repoB, kubo, headCID); err != nil {
 		t.Fatalf("Pull: %v", err)
 	}
 
@@ -208,17 +190,17 @@ func TestPull_IsIdempotent(t *testing.T) {
 	if _, err := repoA.CreateNode("n", "N", []byte("data"), nil); err != nil {
 		t.Fatal(err)
 	}
-	head, err := Push(repoA, kubo)
+	head, err := Push(context.Background(), repoA, kubo)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	repoB := openFreshRepo(t)
-	if err := Pull(repoB, kubo, head); err != nil {
+	if err := Pull(context.Background(), repoB, kubo, head); err != nil {
 		t.Fatal(err)
 	}
 	// Second pull should be a no-op (all objects already local).
-	if err := Pull(repoB, kubo, head); err != nil {
+	if err := Pull(context.Background(), repoB, kubo, head); err != nil {
 		t.Errorf("second pull failed: %v", err)
 	}
 }
@@ -229,7 +211,7 @@ func TestPull_CIDMismatchDetected(t *testing.T) {
 	if _, err := repoA.CreateNode("n", "N", []byte("data"), nil); err != nil {
 		t.Fatal(err)
 	}
-	head, err := Push(repoA, kubo)
+	head, err := Push(context.Background(), repoA, kubo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -243,7 +225,7 @@ func TestPull_CIDMismatchDetected(t *testing.T) {
 	}
 
 	repoB := openFreshRepo(t)
-	err = Pull(repoB, kubo, head)
+	err = Pull(context.Background(), repoB, kubo, head)
 	if err == nil {
 		t.Error("Pull should have detected the CID mismatch")
 	}
@@ -257,7 +239,7 @@ func TestFakeKuboCIDMatchesLocalObjectStore(t *testing.T) {
 	want, _ := multibase.Encode(multibase.Base32, gocid.NewCidV1(gocid.Raw, mh).Bytes())
 
 	kubo := newFakeKubo()
-	got, err := kubo.BlockPut(data, "raw", "sha2-256")
+	got, err := kubo.BlockPut(context.Background(), data, "raw", "sha2-256")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -271,3 +253,259 @@ func TestFakeKuboCIDMatchesLocalObjectStore(t *testing.T) {
 		t.Error("sha256 hash length unexpected")
 	}
 }
+
+func TestPushPull_NonDefaultObjectFormat(t *testing.T) {
+	for _, meta := range []string{
+		`{"version": 1, "hash": "blake3", "codec": "json"}`,
+		`{"version": 1, "hash": "sha2-256", "codec": "dag-json"}`,
+	} {
+		dir := t.TempDir()
+		if _, err := dag.OpenRepository(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".mx", "meta.json"), []byte(meta), 0644); err != nil {
+			t.Fatal(err)
+		}
+		repoA, err := dag.OpenRepository(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repoA.CreateNode("n", "N", []byte("data"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repoA.UpdateContent("n", []byte("more data")); err != nil {
+			t.Fatal(err)
+		}
+		kubo := newFakeKubo()
+		head, err := Push(context.Background(), repoA, kubo)
+		if err != nil {
+			t.Fatalf("%s: Push: %v", meta, err)
+		}
+
+		// The pulling repo keeps the default format; pulled objects keep
+		// theirs, content blocks included.
+		repoB := openFreshRepo(t)
+		if err := Pull(context.Background(), repoB, kubo, head); err != nil {
+			t.Fatalf("%s: Pull: %v", meta, err)
+		}
+		commit, err := repoB.Commits.Resolve(head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := dag.NewSnapshot(commit, repoB.Store).GetNode("n")
+		if err != nil || string(n.Content) != "more data" {
+			t.Fatalf("%s: pulled node = %v, %v", meta, n, err)
+		}
+		prev, err := dag.DecodeNode(mustGet(t, repoB, n.Prev), repoB.Store.Get)
+		if err != nil || string(prev.Content) != "data" {
+			t.Fatalf("%s: pulled prev = %v, %v", meta, prev, err)
+		}
+	}
+}
+
+func mustGet(t *testing.T, repo *dag.Repository, name string) []byte {
+	t.Helper()
+	c, err := decodeCID(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := repo.Store.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestPullWith_ShallowSparseCheckout(t *testing.T) {
+	repoA := openFreshRepo(t)
+	kubo := newFakeKubo()
+	if _, err := repoA.CreateNode("a", "Note", []byte("v1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repoA.CreateNode("b", "Task", []byte("task"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repoA.CreateNode("c", "Other", []byte("tagged"), map[string]interface{}{"tags": []interface{}{"keep"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repoA.CreateLink("a", "b", "mentions"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repoA.UpdateContent("a", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	head, err := Push(context.Background(), repoA, kubo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoB := openFreshRepo(t)
+	sparse := dag.SparseFilter{Types: []string{"Note"}, Tags: []string{"keep"}}
+	if err := PullWith(context.Background(), repoB, kubo, head, PullOptions{Depth: 1, Sparse: sparse}); err != nil {
+		t.Fatalf("PullWith: %v", err)
+	}
+	headCID, _ := decodeCID(head)
+	commit, err := repoB.Commits.GetCommit(headCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, _ := decodeCID(commit.Parent)
+	if repoB.Store.Has(parent) {
+		t.Error("depth 1 pulled the parent commit")
+	}
+	aHead, _ := decodeCID(commit.Refs["a"])
+	a, err := repoB.Store.GetNode(aHead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aPrev, _ := decodeCID(a.Prev)
+	if repoB.Store.Has(aPrev) {
+		t.Error("depth 1 pulled an older version of a")
+	}
+	info := repoB.Shallow()
+	if info == nil || len(info.Boundary) != 1 || info.Boundary[0] != head {
+		t.Fatalf("Shallow = %+v, want boundary at HEAD", info)
+	}
+
+	n, err := repoB.Checkout(headCID, sparse)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("checked out %d nodes, want 2", n)
+	}
+	if _, err := repoB.GetNode("b"); err == nil {
+		t.Error("unselected node b was checked out")
+	}
+	if got, err := repoB.GetNode("a"); err != nil || string(got.Content) != "v2" {
+		t.Errorf("GetNode(a) = %v, %v", got, err)
+	}
+	if len(repoB.Links.LinksFrom("a")) != 1 {
+		t.Error("links from a were not checked out")
+	}
+	history, err := repoB.Commits.History(10)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("History = %d commits, %v; want 1, stopping at the boundary", len(history), err)
+	}
+
+	// With a fetcher, what the pull left out is read on demand.
+	repoB.Store.SetFetcher(Fetcher(context.Background(), kubo))
+	old, err := repoB.Store.GetNode(aPrev)
+	if err != nil || string(old.Content) != "v1" {
+		t.Fatalf("lazy prev = %v, %v", old, err)
+	}
+	if !repoB.Store.Has(aPrev) {
+		t.Error("lazily fetched object was not kept")
+	}
+}
+
+func TestMergeCommit(t *testing.T) {
+	ctx := context.Background()
+	kubo := newFakeKubo()
+	repoA := openFreshRepo(t)
+	for id, content := range map[string]string{"a": "one", "b": "b1", "c": "c1"} {
+		if _, err := repoA.CreateNode(id, "Note", []byte(content), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, err := Push(ctx, repoA, kubo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoB := openFreshRepo(t)
+	if err := Pull(ctx, repoB, kubo, head); err != nil {
+		t.Fatal(err)
+	}
+	headCID, _ := decodeCID(head)
+	if _, err := repoB.Checkout(headCID, dag.SparseFilter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repoA.UpdateContent("a", []byte("theirs"))
+	repoA.UpdateContent("b", []byte("b2"))
+	repoA.CreateLink("a", "c", "rel")
+	if head, err = Push(ctx, repoA, kubo); err != nil {
+		t.Fatal(err)
+	}
+	repoB.UpdateContent("a", []byte("ours"))
+	repoB.UpdateContent("c", []byte("c2"))
+	if err := Pull(ctx, repoB, kubo, head); err != nil {
+		t.Fatal(err)
+	}
+	theirs, _ := decodeCID(head)
+	res, err := repoB.MergeCommit(theirs)
+	if err != nil {
+		t.Fatalf("MergeCommit: %v", err)
+	}
+	if len(res.Updated) != 1 || res.Updated[0] != "b" || len(res.Conflicts) != 1 || res.Conflicts[0] != "a" {
+		t.Fatalf("result = %+v, want b updated and a in conflict", res)
+	}
+	for id, want := range map[string]string{"a": "ours", "b": "b2", "c": "c2"} {
+		if node, _ := repoB.GetNode(id); node == nil || string(node.Content) != want {
+			t.Errorf("%s = %v, want %q", id, node, want)
+		}
+	}
+	if len(repoB.Links.LinksFrom("a")) != 1 {
+		t.Error("their link was not merged")
+	}
+	c, _ := repoB.GetConflict("a")
+	if c == nil || string(repoB.ConflictVersion(c.Base).Content) != "one" || string(repoB.ConflictVersion(c.Theirs).Content) != "theirs" {
+		t.Fatalf("conflict = %+v", c)
+	}
+	mergeCID, _ := repoB.Commits.Head()
+	merge, _ := repoB.Commits.GetCommit(mergeCID)
+	if merge == nil || merge.Merged != head {
+		t.Errorf("HEAD = %+v, want a merge of %s", merge, head)
+	}
+
+	if _, err := repoB.ResolveConflict("a", []byte("both")); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := repoB.GetNode("a"); string(node.Content) != "both" {
+		t.Errorf("a after resolving = %q", node.Content)
+	}
+	if conflicts, _ := repoB.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("conflicts after resolving = %v", conflicts)
+	}
+	if res, err := repoB.MergeCommit(theirs); err != nil || len(res.Updated)+len(res.Conflicts) != 0 {
+		t.Errorf("merging again = %+v, %v; want nothing to do", res, err)
+	}
+}
+
+// TestPull_OwnerEditsUnderACL pulls, twice, the history of an owner
+// editing the node their ACL is on, which no commit signs.
+func TestPull_OwnerEditsUnderACL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	kubo := newFakeKubo()
+	owner := openFreshRepo(t)
+	identity, err := dag.LoadIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner.CreateNode("project:x", "Project", []byte("one"), nil)
+	if _, err := owner.SetACL("project:x", &dag.ACL{Owner: identity.DID}); err != nil {
+		t.Fatal(err)
+	}
+	owner.UpdateContent("project:x", []byte("two"))
+	head, err := Push(ctx, owner, kubo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := openFreshRepo(t)
+	if err := Pull(ctx, other, kubo, head); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	headCID, _ := decodeCID(head)
+	if _, err := other.Checkout(headCID, dag.SparseFilter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	owner.UpdateContent("project:x", []byte("three"))
+	if head, err = Push(ctx, owner, kubo); err != nil {
+		t.Fatal(err)
+	}
+	if err := Pull(ctx, other, kubo, head); err != nil {
+		t.Fatalf("second Pull: %v", err)
+	}
+}
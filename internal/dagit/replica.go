@@ -0,0 +1,98 @@
+package dagit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Replica is a repository's own IPNS name, under which Replicate publishes
+// its HEAD so the user's other devices can pull the whole graph by name.
+// Its key belongs to the repository, not to the user's identity: devices
+// sharing one identity each publish under their own name, and publishing
+// a replica never moves what is published under the DID.
+type Replica struct {
+	Key  dag.Identity `json:"key"`
+	Name string       `json:"name"`           // IPNS name of Key
+	Head string       `json:"head,omitempty"` // commit last replicated
+}
+
+func replicaPath(repo *dag.Repository) string {
+	return filepath.Join(repo.MxDir(), "dagit", "replica.json")
+}
+
+// LoadReplica returns repo's replica, creating its key on first use.
+func LoadReplica(repo *dag.Repository) (*Replica, error) {
+	data, err := os.ReadFile(replicaPath(repo))
+	if err == nil {
+		var r Replica
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("parse replica: %w", err)
+		}
+		return &r, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read replica: %w", err)
+	}
+	key, err := dag.NewIdentity()
+	if err != nil {
+		return nil, err
+	}
+	name, err := DIDToIPNSName(key.DID)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replica{Key: *key, Name: name}
+	return r, r.save(repo)
+}
+
+// save writes the replica, key included, readable only by the owner.
+func (r *Replica) save(repo *dag.Repository) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(replicaPath(repo)), 0755); err != nil {
+		return err
+	}
+	return dag.SafeWrite(replicaPath(repo), data, 0600)
+}
+
+// KeyName is the Kubo keystore name the replica publishes under.
+func (r *Replica) KeyName() string {
+	return "memex-replica-" + r.Name
+}
+
+// Replicate pushes every object reachable from repo's HEAD to IPFS, skipping
+// what the last replication already sent, and publishes HEAD under the
+// replica's IPNS name. Another device follows with `memex-fs clone` or
+// `memex-fs remote add` on that name, then pulls to merge what changed.
+// An interrupted replication resumes from a journal under .mx/dagit/.
+// progress, if set, is reported as for PushOptions. A publish the client
+// queued comes back as the replica with ErrPublishQueued.
+func Replicate(ctx context.Context, repo *dag.Repository, client IPFSClient, progress func(n int)) (*Replica, error) {
+	r, err := LoadReplica(repo)
+	if err != nil {
+		return nil, err
+	}
+	head, err := PushWith(ctx, repo, client, PushOptions{
+		Known:    r.Head,
+		Journal:  filepath.Join(repo.MxDir(), "dagit", "replicate.journal"),
+		Progress: progress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.Head = head
+	if err := r.save(repo); err != nil {
+		return nil, err
+	}
+	if err := EnsureKey(ctx, client, &r.Key, r.KeyName()); err != nil {
+		return nil, fmt.Errorf("key import: %w", err)
+	}
+	return r, client.NamePublish(ctx, head, r.KeyName())
+}
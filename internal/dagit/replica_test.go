@@ -0,0 +1,62 @@
+package dagit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+func TestReplicate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	kubo := newFakeKubo()
+	laptop := openFreshRepo(t)
+	laptop.CreateNode("a", "Note", []byte("one"), nil)
+	laptop.CreateNode("b", "Note", []byte("two"), nil)
+
+	r, err := Replicate(ctx, laptop, kubo, nil)
+	if err != nil {
+		t.Fatalf("Replicate: %v", err)
+	}
+	identity, _ := dag.LoadIdentity()
+	if didName, _ := DIDToIPNSName(identity.DID); r.Name == didName {
+		t.Error("replica publishes under the identity's name")
+	}
+	if again, _ := LoadReplica(laptop); again.Name != r.Name {
+		t.Errorf("replica name changed: %s then %s", r.Name, again.Name)
+	}
+
+	phone := openFreshRepo(t)
+	if _, err := Clone(ctx, phone, kubo, r.Name, PullOptions{}, false); err != nil {
+		t.Fatalf("Clone by replica name: %v", err)
+	}
+	if node, err := phone.GetNode("b"); err != nil || string(node.Content) != "two" {
+		t.Fatalf("cloned node b = %v, %v", node, err)
+	}
+
+	// A second replication sends only the new version and its commit, and
+	// the other device follows by name.
+	laptop.UpdateContent("a", []byte("one, revised"))
+	var sent int
+	if r, err = Replicate(ctx, laptop, kubo, func(n int) { sent = n }); err != nil {
+		t.Fatalf("Replicate: %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("second replication sent %d objects, want 2", sent)
+	}
+	head, err := ResolveSource(ctx, kubo, r.Name)
+	if err != nil || head != r.Head {
+		t.Fatalf("resolve %s = %s, %v; want %s", r.Name, head, err, r.Head)
+	}
+	if err := Pull(ctx, phone, kubo, head); err != nil {
+		t.Fatal(err)
+	}
+	c, _, _ := phone.Commits.ResolveCID(head)
+	if _, err := phone.MergeCommit(c); err != nil {
+		t.Fatal(err)
+	}
+	if node, err := phone.GetNode("a"); err != nil || string(node.Content) != "one, revised" {
+		t.Errorf("replicated node a = %v, %v", node, err)
+	}
+}
@@ -0,0 +1,335 @@
+package dagit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Transport is a remote a repository pushes to and pulls from: a store of
+// objects, as for IPFS, and the remote's HEAD.
+type Transport interface {
+	kuboAPI
+	// Head returns the commit CID the remote is at, "" if it has none.
+	Head(ctx context.Context) (string, error)
+	// Update makes the remote take head, whose objects are pushed already.
+	Update(ctx context.Context, head string) (*UpdateResult, error)
+	Close() error
+}
+
+// UpdateResult is how a remote took a pushed HEAD.
+type UpdateResult struct {
+	Head      string   `json:"head"`                // the remote's HEAD after the update
+	Updated   []string `json:"updated,omitempty"`   // nodes it took from the push
+	Conflicts []string `json:"conflicts,omitempty"` // nodes left in conflict on the remote
+}
+
+// OpenTransport connects to the remote at source:
+//
+//   - ssh://[user@]host[:port]/path runs `memex-fs sync-serve --stdio` on
+//     host over ssh, for the repository at path (/~/path for one under the
+//     remote home directory);
+//   - an http:// or https:// URL is a memex sync server (memex-fs
+//     sync-serve), reached with token if it requires one;
+//   - anything else — a DID, an IPNS name, a CID or an IPFS gateway URL —
+//     is IPFS, through client, as ResolveSource reads it.
+func OpenTransport(source string, client IPFSClient, token string) (Transport, error) {
+	switch {
+	case strings.HasPrefix(source, "ssh://"):
+		return dialSSH(source)
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(u.Path, "/ipfs/") && !strings.HasPrefix(u.Path, "/ipns/") {
+			return &httpTransport{base: strings.TrimSuffix(source, "/"), token: token, client: http.DefaultClient}, nil
+		}
+	}
+	return &ipfsTransport{IPFSClient: client, source: source}, nil
+}
+
+// ipfsTransport is a remote on IPFS, its HEAD published under IPNS.
+type ipfsTransport struct {
+	IPFSClient
+	source string
+}
+
+func (t *ipfsTransport) Head(ctx context.Context) (string, error) {
+	return ResolveSource(ctx, t.IPFSClient, t.source)
+}
+
+// Update publishes head under the IPNS name of the local identity, which
+// must be the remote's DID.
+func (t *ipfsTransport) Update(ctx context.Context, head string) (*UpdateResult, error) {
+	identity, err := dag.LoadIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("load identity: %w", err)
+	}
+	if t.source != identity.DID {
+		return nil, fmt.Errorf("can publish only under your own DID %s, not %s", identity.DID, t.source)
+	}
+	if err := EnsureKey(ctx, t.IPFSClient, identity, HeadKeyName); err != nil {
+		return nil, fmt.Errorf("key import: %w", err)
+	}
+	if err := t.NamePublish(ctx, head, HeadKeyName); err != nil {
+		return nil, err
+	}
+	return &UpdateResult{Head: head}, nil
+}
+
+func (t *ipfsTransport) Close() error { return nil }
+
+// httpTransport is a memex sync server (see web.SyncServer).
+type httpTransport struct {
+	base   string
+	token  string
+	client *http.Client
+	close  func() error
+}
+
+func (t *httpTransport) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, e.Error)
+		}
+		return nil, fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+	return data, nil
+}
+
+func (t *httpTransport) Head(ctx context.Context) (string, error) {
+	data, err := t.do(ctx, http.MethodGet, "/sync/head", nil)
+	if err != nil {
+		return "", err
+	}
+	var out UpdateResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("sync head: %w", err)
+	}
+	return out.Head, nil
+}
+
+func (t *httpTransport) Update(ctx context.Context, head string) (*UpdateResult, error) {
+	body, _ := json.Marshal(UpdateResult{Head: head})
+	data, err := t.do(ctx, http.MethodPost, "/sync/update", body)
+	if err != nil {
+		return nil, err
+	}
+	var out UpdateResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("sync update: %w", err)
+	}
+	return &out, nil
+}
+
+func (t *httpTransport) BlockGet(ctx context.Context, cid string) ([]byte, error) {
+	return t.do(ctx, http.MethodGet, "/sync/objects/"+cid, nil)
+}
+
+// BlockPut uploads data under the CID it hashes to with codec and mhType.
+func (t *httpTransport) BlockPut(ctx context.Context, data []byte, codec, mhType string) (string, error) {
+	c, err := dag.ObjectFormat{Hash: mhType, Codec: codec}.Sum(data)
+	if err != nil {
+		return "", err
+	}
+	key := dag.CIDToFilename(c)
+	if _, err := t.do(ctx, http.MethodPut, "/sync/objects/"+key, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Pin is a no-op: a memex keeps every object it stores.
+func (t *httpTransport) Pin(ctx context.Context, cid string) error { return nil }
+
+func (t *httpTransport) Close() error {
+	if t.close != nil {
+		return t.close()
+	}
+	return nil
+}
+
+// dialSSH starts `memex-fs sync-serve --stdio` on the host source names
+// and speaks the sync protocol over its stdin and stdout.
+func dialSSH(source string) (Transport, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || u.Path == "" {
+		return nil, fmt.Errorf("%s: want ssh://[user@]host[:port]/path", source)
+	}
+	args := []string{}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	target := u.Hostname()
+	if u.User != nil {
+		target = u.User.Username() + "@" + target
+	}
+	path := shellQuote(u.Path)
+	if rest, ok := strings.CutPrefix(u.Path, "/~/"); ok {
+		path = "~/" + shellQuote(rest)
+	}
+	args = append(args, target, "memex-fs sync-serve --stdio --data "+path)
+	cmd := exec.Command("ssh", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	t := newConnTransport(StdioConn(stdout, stdin))
+	t.close = func() error {
+		stdin.Close()
+		return cmd.Wait()
+	}
+	return t, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// newConnTransport speaks the sync protocol over the one connection conn.
+func newConnTransport(conn net.Conn) *httpTransport {
+	var once sync.Once
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var c net.Conn
+		once.Do(func() { c = conn })
+		if c == nil {
+			return nil, errors.New("sync connection closed")
+		}
+		return c, nil
+	}
+	client := &http.Client{Transport: &http.Transport{DialContext: dial, MaxConnsPerHost: 1}}
+	return &httpTransport{base: "http://memex", client: client, close: conn.Close}
+}
+
+// StdioConn joins a reader and a writer, such as a process's stdout and
+// stdin, into a net.Conn for the sync protocol. Deadlines are passed on to
+// either end that supports them.
+func StdioConn(r io.Reader, w io.WriteCloser) net.Conn {
+	return &stdioConn{r: r, w: w}
+}
+
+type stdioConn struct {
+	r io.Reader
+	w io.WriteCloser
+}
+
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+func (c *stdioConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *stdioConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+func (c *stdioConn) Close() error {
+	if rc, ok := c.r.(io.Closer); ok {
+		rc.Close()
+	}
+	return c.w.Close()
+}
+func (c *stdioConn) LocalAddr() net.Addr  { return stdioAddr{} }
+func (c *stdioConn) RemoteAddr() net.Addr { return stdioAddr{} }
+func (c *stdioConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+func (c *stdioConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.r.(deadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+func (c *stdioConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.w.(deadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+type stdioAddr struct{}
+
+func (stdioAddr) Network() string { return "stdio" }
+func (stdioAddr) String() string  { return "stdio" }
+
+// ServeConn serves h over the one connection conn until the peer closes
+// it, as `memex-fs sync-serve --stdio` does for a client over ssh.
+func ServeConn(h http.Handler, conn net.Conn) error {
+	ln := &connListener{conn: conn, done: make(chan struct{})}
+	srv := &http.Server{Handler: h, ConnState: func(c net.Conn, state http.ConnState) {
+		if state == http.StateClosed || state == http.StateHijacked {
+			ln.Close()
+		}
+	}}
+	err := srv.Serve(ln)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// connListener accepts conn once, then blocks until it is closed.
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+	shut sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var c net.Conn
+	l.once.Do(func() { c = l.conn })
+	if c != nil {
+		return c, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *connListener) Close() error {
+	l.shut.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr { return stdioAddr{} }
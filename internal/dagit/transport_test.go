@@ -0,0 +1,292 @@
+package dagit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+	"github.com/systemshift/memex-fs/internal/web"
+)
+
+// exchange pushes src to the remote repository dst through tr and pulls
+// back what dst gained, checking that only missing objects move.
+func exchange(t *testing.T, tr Transport, src, dst *dag.Repository) {
+	t.Helper()
+	ctx := context.Background()
+	if head, err := tr.Head(ctx); err != nil || head != "" {
+		t.Fatalf("Head of an empty remote = %q, %v", head, err)
+	}
+	src.CreateNode("a", "Note", []byte("one"), nil)
+	src.CreateNode("b", "Note", []byte("two"), nil)
+	head, err := PushWith(ctx, src, tr, PushOptions{})
+	if err != nil {
+		t.Fatalf("PushWith: %v", err)
+	}
+	if res, err := tr.Update(ctx, head); err != nil || res.Head != head {
+		t.Fatalf("Update = %+v, %v", res, err)
+	}
+	if node, err := dst.GetNode("b"); err != nil || string(node.Content) != "two" {
+		t.Fatalf("remote node b = %v, %v", node, err)
+	}
+
+	// Only what the remote lacks moves: one node version and one commit.
+	src.UpdateContent("a", []byte("one, revised"))
+	var sent int
+	if _, err := PushWith(ctx, src, tr, PushOptions{Known: head, Progress: func(n int) { sent = n }}); err != nil {
+		t.Fatalf("incremental PushWith: %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("incremental push sent %d objects, want 2", sent)
+	}
+	newHead, _ := src.Commits.Head()
+	if _, err := tr.Update(ctx, dag.CIDToFilename(newHead)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	dst.CreateNode("c", "Note", []byte("remote"), nil)
+	remoteHead, err := tr.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetched int
+	if err := PullWith(ctx, src, tr, remoteHead, PullOptions{Progress: func(n int) { fetched = n }}); err != nil {
+		t.Fatalf("PullWith: %v", err)
+	}
+	if fetched != 3 { // the node, its commit and the remote's merge commit
+		t.Errorf("pull fetched %d objects, want 3", fetched)
+	}
+	c, _, _ := src.Commits.ResolveCID(remoteHead)
+	if _, err := src.MergeCommit(c); err != nil {
+		t.Fatalf("MergeCommit: %v", err)
+	}
+	if node, err := src.GetNode("c"); err != nil || string(node.Content) != "remote" {
+		t.Errorf("pulled node c = %v, %v", node, err)
+	}
+}
+
+func TestTransport_HTTP(t *testing.T) {
+	dst := openFreshRepo(t)
+	srv := httptest.NewServer(web.NewSyncServer(dst, false))
+	defer srv.Close()
+	tr, err := OpenTransport(srv.URL, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	exchange(t, tr, openFreshRepo(t), dst)
+}
+
+func TestTransport_HTTPRequiresToken(t *testing.T) {
+	dst := openFreshRepo(t)
+	srv := httptest.NewServer(web.NewSyncServer(dst, true))
+	defer srv.Close()
+	tr, _ := OpenTransport(srv.URL, nil, "")
+	if _, err := tr.Head(context.Background()); err == nil {
+		t.Error("Head without a token succeeded")
+	}
+	token, _, err := dst.MintToken("sync", "", []string{dag.OpRead, dag.OpWrite}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _ = OpenTransport(srv.URL, nil, token)
+	if _, err := tr.Head(context.Background()); err != nil {
+		t.Errorf("Head with a token: %v", err)
+	}
+}
+
+// syncHolderDID holds the tokens of the ACL tests; ipnsTestDID owns the
+// ACLs.
+const syncHolderDID = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+
+// TestTransport_HTTPUpdateChecksLocalACLs pushes an unrelated history,
+// which no commit in it governs, that links from a node here under an ACL
+// the token holder is not on.
+func TestTransport_HTTPUpdateChecksLocalACLs(t *testing.T) {
+	ctx := context.Background()
+	dst := openFreshRepo(t)
+	dst.CreateNode("project:x", "Project", nil, nil)
+	if _, err := dst.SetACL("project:x", &dag.ACL{Owner: ipnsTestDID}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(web.NewSyncServer(dst, true))
+	defer srv.Close()
+	token, _, err := dst.MintToken("sync", syncHolderDID, []string{dag.OpWrite}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := OpenTransport(srv.URL, nil, token)
+	defer tr.Close()
+
+	src := openFreshRepo(t)
+	src.CreateNode("project:x", "Project", nil, nil)
+	src.CreateNode("note:z", "Note", []byte("planted"), nil)
+	src.CreateLink("project:x", "note:z", "rel")
+	head, err := PushWith(ctx, src, tr, PushOptions{})
+	if err != nil {
+		t.Fatalf("PushWith: %v", err)
+	}
+	if _, err := tr.Update(ctx, head); err == nil {
+		t.Fatal("Update linking from a node under someone else's ACL succeeded")
+	}
+	if links := dst.Links.LinksFrom("project:x"); len(links) != 0 {
+		t.Errorf("links from project:x = %v, want none", links)
+	}
+	if _, err := dst.GetNode("note:z"); err == nil {
+		t.Error("the refused update was partly merged")
+	}
+}
+
+// TestTransport_HTTPPushesAfterACLEdit pushes twice from a repository
+// whose owner edited the node their ACL is on, with the owner's token.
+func TestTransport_HTTPPushesAfterACLEdit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	identity, err := dag.LoadIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := openFreshRepo(t)
+	srv := httptest.NewServer(web.NewSyncServer(dst, true))
+	defer srv.Close()
+	token, _, err := dst.MintToken("sync", identity.DID, []string{dag.OpWrite}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := OpenTransport(srv.URL, nil, token)
+	defer tr.Close()
+
+	src := openFreshRepo(t)
+	src.CreateNode("project:x", "Project", []byte("one"), nil)
+	if _, err := src.SetACL("project:x", &dag.ACL{Owner: identity.DID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"two", "three"} {
+		src.UpdateContent("project:x", []byte(content))
+		head, err := PushWith(ctx, src, tr, PushOptions{})
+		if err != nil {
+			t.Fatalf("PushWith: %v", err)
+		}
+		if _, err := tr.Update(ctx, head); err != nil {
+			t.Fatalf("Update after editing to %q: %v", content, err)
+		}
+		if node, err := dst.GetNode("project:x"); err != nil || string(node.Content) != content {
+			t.Fatalf("remote project:x = %v, %v; want %q", node, err, content)
+		}
+	}
+}
+
+func TestTransport_HTTPHidesUnreadableNodes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := dag.OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Content is a block of its own under DAG-JSON.
+	meta := fmt.Sprintf(`{"version": %d, "hash": "sha2-256", "codec": "dag-json"}`, dag.RepoFormat)
+	if err := os.WriteFile(filepath.Join(dir, ".mx", "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := dag.OpenRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst.CreateNode("project:x", "Project", nil, nil)
+	dst.SetACL("project:x", &dag.ACL{Owner: ipnsTestDID})
+	dst.CreateNode("note:secret", "Note", []byte("secret"), nil)
+	dst.CreateLink("note:secret", "project:x", dag.LinkPartOf)
+	dst.UpdateContent("note:secret", []byte("secret, revised"))
+	dst.CreateNode("note:open", "Note", []byte("open"), nil)
+
+	srv := httptest.NewServer(web.NewSyncServer(dst, true))
+	defer srv.Close()
+	token, _, err := dst.MintToken("sync", syncHolderDID, []string{dag.OpRead}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := OpenTransport(srv.URL, nil, token)
+	defer tr.Close()
+
+	// objects returns the versions of id and their content blocks.
+	objects := func(id string) []string {
+		var out []string
+		c, _ := dst.Refs.Get(id)
+		for c.Defined() {
+			data, err := dst.Store.Get(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, dag.CIDToFilename(c))
+			if block, ok := dag.ContentLink(data); ok {
+				out = append(out, dag.CIDToFilename(block))
+			}
+			node, _ := dag.DecodeNode(data, nil)
+			c, _ = decodeCID(node.Prev)
+		}
+		return out
+	}
+	secret := objects("note:secret")
+	if len(secret) != 4 {
+		t.Fatalf("note:secret objects = %v, want two versions and their content", secret)
+	}
+	for _, c := range secret {
+		if _, err := tr.BlockGet(ctx, c); err == nil {
+			t.Errorf("fetched %s of note:secret", c)
+		}
+	}
+	for _, c := range objects("note:open") {
+		if _, err := tr.BlockGet(ctx, c); err != nil {
+			t.Errorf("fetch %s of note:open: %v", c, err)
+		}
+	}
+}
+
+// TestTransport_Conn runs the protocol over one connection, as an ssh://
+// remote does over the stdio of `memex-fs sync-serve --stdio`.
+func TestTransport_Conn(t *testing.T) {
+	dst := openFreshRepo(t)
+	client, server := net.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- ServeConn(web.NewSyncServer(dst, false), server) }()
+	tr := newConnTransport(client)
+	exchange(t, tr, openFreshRepo(t), dst)
+	tr.Close()
+	if err := <-served; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+}
+
+func TestPushWith_ResumesFromJournal(t *testing.T) {
+	ctx := context.Background()
+	repo := openFreshRepo(t)
+	repo.CreateNode("a", "Note", []byte("one"), nil)
+	repo.CreateNode("b", "Note", []byte("two"), nil)
+	repo.UpdateContent("a", []byte("one, revised"))
+	kubo := newFakeKubo()
+	journal := filepath.Join(t.TempDir(), "push.journal")
+
+	// The remote goes away after two uploads.
+	_, err := PushWith(ctx, repo, kubo, PushOptions{Journal: journal, Progress: func(n int) {
+		if n == 2 {
+			kubo.SetOffline(true)
+		}
+	}})
+	if err == nil {
+		t.Fatal("push to an offline remote succeeded")
+	}
+	kubo.SetOffline(false)
+	var sent int
+	if _, err := PushWith(ctx, repo, kubo, PushOptions{Journal: journal, Progress: func(n int) { sent = n }}); err != nil {
+		t.Fatalf("resumed PushWith: %v", err)
+	}
+	if sent != kubo.Len()-2 {
+		t.Errorf("resumed push sent %d objects of %d, want all but 2", sent, kubo.Len())
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal left after a complete push: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// AskDir is /ask/. Looking up any name asks it as a question of the
+// graph; see AskAnswerDir.
+type AskDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*AskDir)(nil))
+var _ = (fs.NodeReaddirer)((*AskDir)(nil))
+var _ = (fs.NodeGetattrer)((*AskDir)(nil))
+
+func (d *AskDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("ask")
+	return fs.OK
+}
+
+func (d *AskDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	// Empty listing — questions are provided via Lookup
+	return fs.NewListDirStream(nil), fs.OK
+}
+
+func (d *AskDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	child := d.NewInode(ctx, &AskAnswerDir{repo: d.repo, question: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("ask/" + name),
+	})
+	return child, fs.OK
+}
+
+// AskAnswerDir is /ask/{question}/: an answer file plus a symlink to each
+// supporting node. The answer is computed on first access and kept until
+// the next commit.
+type AskAnswerDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	question string
+}
+
+var _ = (fs.NodeLookuper)((*AskAnswerDir)(nil))
+var _ = (fs.NodeReaddirer)((*AskAnswerDir)(nil))
+var _ = (fs.NodeGetattrer)((*AskAnswerDir)(nil))
+
+func (d *AskAnswerDir) path() string {
+	return "ask/" + d.question
+}
+
+func (d *AskAnswerDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.path())
+	return fs.OK
+}
+
+func (d *AskAnswerDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	answer := d.repo.Ask(ctx, d.question)
+	entries := []fuse.DirEntry{{Name: "answer", Mode: syscall.S_IFREG, Ino: stableIno(d.path() + "/answer")}}
+	for _, id := range answer.Sources {
+		entries = append(entries, fuse.DirEntry{Name: id, Mode: syscall.S_IFLNK, Ino: stableIno(d.path() + "/" + id)})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *AskAnswerDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "answer" {
+		return newGeneratedFile(ctx, &d.Inode, d.path()+"/answer", d.answer), fs.OK
+	}
+	for _, id := range d.repo.Ask(ctx, d.question).Sources {
+		if id == name {
+			child := d.NewInode(ctx, &LinkSymlink{target: "../../nodes/" + id}, fs.StableAttr{
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno(d.path() + "/" + id),
+			})
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// answer renders the answer text followed by its sources.
+func (d *AskAnswerDir) answer(ctx context.Context) []byte {
+	a := d.repo.Ask(ctx, d.question)
+	var b strings.Builder
+	b.WriteString(a.Text + "\n")
+	if a.Generated && len(a.Sources) > 0 {
+		fmt.Fprintf(&b, "\nSources: %s\n", strings.Join(a.Sources, ", "))
+	}
+	return []byte(b.String())
+}
@@ -11,13 +11,13 @@ import (
 	"github.com/systemshift/memex-fs/internal/dag"
 )
 
-// AtRootDir is /at/ — a lookup-only directory that resolves a key (either a
-// commit CID in base32 form, or an RFC3339 timestamp) to a read-only
-// snapshot of the repo at that point in time.
+// AtRootDir is /at/ — a lookup-only directory that resolves a key (a tag
+// name, a commit CID in base32 form, or an RFC3339 timestamp) to a
+// read-only snapshot of the repo at that point in time.
 //
 // It intentionally lists nothing on Readdir: the set of valid keys is
 // effectively unbounded (any commit CID, any timestamp in the project's
-// lifetime). Use /log/ to browse commits.
+// lifetime). Use /log/ to browse commits and /log/tags/ to browse tags.
 type AtRootDir struct {
 	fs.Inode
 	repo *dag.Repository
@@ -38,7 +38,7 @@ func (d *AtRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 }
 
 func (d *AtRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	commit, err := d.repo.Commits.Resolve(name)
+	commit, err := d.repo.ResolveCommit(name)
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
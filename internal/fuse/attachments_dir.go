@@ -0,0 +1,98 @@
+package fuse
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// AttachmentsDir is /nodes/{id}/attachments/. Existing attachments are
+// symlinks to their Source nodes (../../sha256:...). Creating a file here
+// stores its bytes as a new content-addressed Source node on close and
+// links it from {id} via HAS_ATTACHMENT, so `cp paper.pdf attachments/`
+// just works.
+type AttachmentsDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeLookuper)((*AttachmentsDir)(nil))
+var _ = (fs.NodeReaddirer)((*AttachmentsDir)(nil))
+var _ = (fs.NodeGetattrer)((*AttachmentsDir)(nil))
+var _ = (fs.NodeCreater)((*AttachmentsDir)(nil))
+
+func (d *AttachmentsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("nodes/" + d.nodeID + "/attachments")
+	return fs.OK
+}
+
+func (d *AttachmentsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.repo.Attachments(d.nodeID)
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("nodes/" + d.nodeID + "/attachments/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *AttachmentsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, id := range d.repo.Attachments(d.nodeID) {
+		if id == name {
+			sym := &LinkSymlink{target: "../../" + id}
+			child := d.NewInode(ctx, sym, fs.StableAttr{
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno("nodes/" + d.nodeID + "/attachments/" + name),
+			})
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// Create hands back a write handle that buffers the file and ingests it on
+// flush. The returned inode is a placeholder: once ingested, the attachment
+// is listed under its content-addressed ID, not the name it was written as.
+func (d *AttachmentsDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if _, err := d.repo.GetNode(d.nodeID); err != nil {
+		return nil, nil, 0, syscall.ENOENT
+	}
+	wh := &WriteHandle{
+		repo:     d.repo,
+		nodeID:   d.nodeID,
+		field:    "attachment",
+		filename: name,
+	}
+	child := d.NewInode(ctx, &pendingFile{}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno("nodes/" + d.nodeID + "/attachments/.pending/" + name),
+	})
+	return child, wh, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+// pendingFile backs the inode returned from a Create whose bytes are only
+// committed on flush (attachments, journal appends). It has no content of
+// its own; the write handle holds the buffer.
+type pendingFile struct {
+	fs.Inode
+}
+
+var _ = (fs.NodeGetattrer)((*pendingFile)(nil))
+var _ = (fs.NodeSetattrer)((*pendingFile)(nil))
+
+func (f *pendingFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0644
+	return fs.OK
+}
+
+func (f *pendingFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
@@ -0,0 +1,33 @@
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag/testutil"
+)
+
+// benchMount generates an n-node repo and mounts it, returning the
+// mountpoint.
+func benchMount(b *testing.B, n int) string {
+	b.Helper()
+	return mountRepo(b, testutil.Open(b, testutil.Spec{Seed: 1, Nodes: n}))
+}
+
+func BenchmarkReaddir(b *testing.B) {
+	for _, n := range []int{1_000, 10_000} {
+		mnt := benchMount(b, n)
+		for _, dir := range []string{"nodes", "types/Note", "search/note"} {
+			b.Run(fmt.Sprintf("%s/nodes=%d", dir, n), func(b *testing.B) {
+				path := filepath.Join(mnt, dir)
+				for i := 0; i < b.N; i++ {
+					if _, err := os.ReadDir(path); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
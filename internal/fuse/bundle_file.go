@@ -0,0 +1,29 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+// bundleFileName is the per-node export: a signed bundle another memex can
+// import with `memex-fs import`.
+const bundleFileName = ".bundle"
+
+// newBundleFile is /nodes/{id}/.bundle — the node's versions and outgoing
+// links as a signed JSON bundle.
+func (d *NodeDir) newBundleFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/"+bundleFileName, func(context.Context) []byte {
+		b, err := d.repo.ExportBundle(d.nodeID)
+		if err != nil {
+			return []byte(fmt.Sprintf("bundle unavailable: %v\n", err))
+		}
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return []byte(fmt.Sprintf("bundle unavailable: %v\n", err))
+		}
+		return append(data, '\n')
+	})
+}
@@ -0,0 +1,115 @@
+package fuse
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// CalendarDir is /calendar/ — Event nodes by the month they start in:
+//
+//	YYYY-MM/  symlinks to ../../nodes/{id}, named "{start}_{id}" so `ls`
+//	          lists them in order; the start is "YYYY-MM-DDTHHMM" local
+//	          time, or "YYYY-MM-DD" for all-day events
+type CalendarDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*CalendarDir)(nil))
+var _ = (fs.NodeReaddirer)((*CalendarDir)(nil))
+var _ = (fs.NodeGetattrer)((*CalendarDir)(nil))
+
+func (d *CalendarDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("calendar")
+	return fs.OK
+}
+
+func (d *CalendarDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	var entries []fuse.DirEntry
+	for _, month := range d.repo.CalendarMonths() {
+		entries = append(entries, fuse.DirEntry{
+			Name: month,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("calendar/" + month),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *CalendarDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	if !slices.Contains(d.repo.CalendarMonths(), name) {
+		return nil, syscall.ENOENT
+	}
+	return d.NewInode(ctx, &CalendarMonthDir{repo: d.repo, month: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("calendar/" + name),
+	}), fs.OK
+}
+
+// CalendarMonthDir is /calendar/{YYYY-MM}/.
+type CalendarMonthDir struct {
+	fs.Inode
+	repo  *dag.Repository
+	month string
+}
+
+var _ = (fs.NodeLookuper)((*CalendarMonthDir)(nil))
+var _ = (fs.NodeReaddirer)((*CalendarMonthDir)(nil))
+var _ = (fs.NodeGetattrer)((*CalendarMonthDir)(nil))
+
+func (d *CalendarMonthDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("calendar/" + d.month)
+	return fs.OK
+}
+
+func (d *CalendarMonthDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, ev := range d.repo.EventsIn(d.month) {
+		name := calendarEntryName(ev)
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("calendar/" + d.month + "/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *CalendarMonthDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	_, id, ok := strings.Cut(name, "_")
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	// Accept the name only while the event still starts then.
+	if !slices.ContainsFunc(d.repo.EventsIn(d.month), func(ev dag.CalendarEvent) bool {
+		return ev.ID == id && calendarEntryName(ev) == name
+	}) {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../nodes/" + id}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("calendar/" + d.month + "/" + name),
+	}), fs.OK
+}
+
+func calendarEntryName(ev dag.CalendarEvent) string {
+	if ev.AllDay {
+		return ev.Start.Format(dag.DayLayout) + "_" + ev.ID
+	}
+	return ev.Start.Format("2006-01-02T1504") + "_" + ev.ID
+}
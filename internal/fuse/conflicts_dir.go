@@ -0,0 +1,191 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// conflictSides are the read-only files in /conflicts/{id}/, each the
+// content of one version.
+var conflictSides = []string{"base", "ours", "theirs"}
+
+// ConflictsDir is /conflicts/ — one directory per node a merge left in
+// conflict. Each holds the content of the base, ours and theirs versions,
+// and a resolve file: writing the resolved content to it commits it as
+// the node's new content and clears the conflict.
+//
+//	cat /conflicts/note:a/theirs > /conflicts/note:a/resolve
+type ConflictsDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ConflictsDir)(nil))
+var _ = (fs.NodeReaddirer)((*ConflictsDir)(nil))
+var _ = (fs.NodeGetattrer)((*ConflictsDir)(nil))
+
+func (d *ConflictsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("conflicts")
+	return fs.OK
+}
+
+func (d *ConflictsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	conflicts, err := d.repo.Conflicts()
+	if err != nil {
+		return nil, syscall.EIO
+	}
+	entries := make([]fuse.DirEntry, len(conflicts))
+	for i, c := range conflicts {
+		entries[i] = fuse.DirEntry{Name: c.ID, Mode: syscall.S_IFDIR, Ino: stableIno("conflicts/" + c.ID)}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ConflictsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	c, err := d.repo.GetConflict(name)
+	if err != nil || c == nil {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ConflictDir{repo: d.repo, nodeID: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("conflicts/" + name),
+	})
+	return child, fs.OK
+}
+
+// ConflictDir is /conflicts/{id}/.
+type ConflictDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeLookuper)((*ConflictDir)(nil))
+var _ = (fs.NodeReaddirer)((*ConflictDir)(nil))
+var _ = (fs.NodeGetattrer)((*ConflictDir)(nil))
+
+func (d *ConflictDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("conflicts/" + d.nodeID)
+	return fs.OK
+}
+
+func (d *ConflictDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, name := range []string{"base", "ours", "theirs", "resolve"} {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFREG, Ino: stableIno("conflicts/" + d.nodeID + "/" + name)})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ConflictDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	path := "conflicts/" + d.nodeID + "/" + name
+	if name == "resolve" {
+		child := d.NewInode(ctx, &ResolveFile{repo: d.repo, nodeID: d.nodeID}, fs.StableAttr{
+			Mode: syscall.S_IFREG,
+			Ino:  stableIno(path),
+		})
+		return child, fs.OK
+	}
+	for _, side := range conflictSides {
+		if name == side {
+			return newGeneratedFile(ctx, &d.Inode, path, func(ctx context.Context) []byte {
+				return d.side(side)
+			}), fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// side returns the content of one version in the conflict, empty for a
+// base the node did not have.
+func (d *ConflictDir) side(side string) []byte {
+	c, err := d.repo.GetConflict(d.nodeID)
+	if err != nil || c == nil {
+		return nil
+	}
+	name := map[string]string{"base": c.Base, "ours": c.Ours, "theirs": c.Theirs}[side]
+	if node := d.repo.ConflictVersion(name); node != nil {
+		return node.Content
+	}
+	return nil
+}
+
+// ResolveFile is /conflicts/{id}/resolve. It reads as empty; the content
+// written to it resolves the conflict when the file is closed.
+type ResolveFile struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeGetattrer)((*ResolveFile)(nil))
+var _ = (fs.NodeSetattrer)((*ResolveFile)(nil))
+var _ = (fs.NodeOpener)((*ResolveFile)(nil))
+var _ = (fs.NodeReader)((*ResolveFile)(nil))
+
+func (f *ResolveFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0200
+	out.Ino = stableIno("conflicts/" + f.nodeID + "/resolve")
+	return fs.OK
+}
+
+func (f *ResolveFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
+func (f *ResolveFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 {
+		if err := f.repo.EditLocks.Check(f.nodeID); err != nil {
+			return nil, 0, syscall.EBUSY
+		}
+		return &resolveHandle{repo: f.repo, nodeID: f.nodeID}, fuse.FOPEN_DIRECT_IO, fs.OK
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+func (f *ResolveFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	return fuse.ReadResultData(nil), fs.OK
+}
+
+// resolveHandle buffers the resolved content and applies it on flush.
+type resolveHandle struct {
+	repo    *dag.Repository
+	nodeID  string
+	buf     []byte
+	written bool
+	done    bool
+}
+
+var _ = (fs.FileWriter)((*resolveHandle)(nil))
+var _ = (fs.FileFlusher)((*resolveHandle)(nil))
+
+func (h *resolveHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	h.written = true
+	return uint32(len(data)), fs.OK
+}
+
+func (h *resolveHandle) Flush(ctx context.Context) syscall.Errno {
+	// Flush runs once per close of a dup'd descriptor; resolve only once.
+	if !h.written || h.done {
+		return fs.OK
+	}
+	h.done = true
+	if _, err := actingRepo(ctx, h.repo).ResolveConflict(h.nodeID, h.buf); err != nil {
+		fmt.Printf("memex-fs: resolve %s: %v\n", h.nodeID, err)
+		return lastErrors.fail(h.nodeID, "resolve", err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(h.nodeID)
+	return fs.OK
+}
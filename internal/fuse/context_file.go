@@ -0,0 +1,58 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// newContextFile is /nodes/{id}/context — a Markdown context panel any
+// editor can show beside the note: backlinks, related nodes with their
+// scores, and the notes most recently read alongside this one.
+func (d *NodeDir) newContextFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/context", func(context.Context) []byte {
+		c, err := d.repo.Context(d.nodeID)
+		if err != nil {
+			return []byte(fmt.Sprintf("context unavailable: %v\n", err))
+		}
+		return renderContext(c)
+	})
+}
+
+func renderContext(c *dag.NodeContext) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Context for %s\n", c.ID)
+
+	b.WriteString("\n## Backlinks\n\n")
+	if len(c.Backlinks) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, l := range c.Backlinks {
+		fmt.Fprintf(&b, "- %s (%s)\n", l.Source, l.Type)
+	}
+
+	b.WriteString("\n## Related\n\n")
+	if len(c.Related) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, s := range c.Related {
+		fmt.Fprintf(&b, "- %s (score %.1f)\n", s.ID, s.Score)
+	}
+
+	b.WriteString("\n## Recently read alongside\n\n")
+	if len(c.CoAccessed) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, n := range c.CoAccessed {
+		if n.LastAccess.IsZero() {
+			fmt.Fprintf(&b, "- %s\n", n.ID)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s (last read %s)\n", n.ID, n.LastAccess.In(time.Local).Format("2006-01-02 15:04"))
+	}
+	return []byte(b.String())
+}
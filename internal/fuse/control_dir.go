@@ -0,0 +1,277 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// controlCommand runs one /control/ command with the whitespace-separated
+// arguments written to its file. An error wrapping a syscall.Errno fails
+// the close with that errno.
+type controlCommand func(ctx context.Context, repo *dag.Repository, args []string) error
+
+// controlCommands are the files in /control/.
+var controlCommands = map[string]controlCommand{
+	"compact":   controlCompact,
+	"ingest":    controlIngest,
+	"merge":     controlMerge,
+	"migrate":   controlMigrate,
+	"summarize": controlSummarize,
+}
+
+// ControlDir is /control/ — write-only files that trigger repository
+// actions. Writing to a file runs its command when the file is closed, and
+// the close fails if the command does.
+//
+//	echo note:meeting > /control/summarize
+type ControlDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ControlDir)(nil))
+var _ = (fs.NodeReaddirer)((*ControlDir)(nil))
+var _ = (fs.NodeGetattrer)((*ControlDir)(nil))
+
+func (d *ControlDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("control")
+	return fs.OK
+}
+
+func (d *ControlDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	names := make([]string, 0, len(controlCommands))
+	for name := range controlCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]fuse.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = fuse.DirEntry{Name: name, Mode: syscall.S_IFREG, Ino: stableIno("control/" + name)}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ControlDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	run, ok := controlCommands[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ControlFile{repo: d.repo, name: name, run: run}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno("control/" + name),
+	})
+	return child, fs.OK
+}
+
+// ControlFile is one command in /control/. It reads as empty.
+type ControlFile struct {
+	fs.Inode
+	repo *dag.Repository
+	name string
+	run  controlCommand
+}
+
+var _ = (fs.NodeGetattrer)((*ControlFile)(nil))
+var _ = (fs.NodeSetattrer)((*ControlFile)(nil))
+var _ = (fs.NodeOpener)((*ControlFile)(nil))
+var _ = (fs.NodeReader)((*ControlFile)(nil))
+
+func (f *ControlFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0200
+	out.Ino = stableIno("control/" + f.name)
+	return fs.OK
+}
+
+func (f *ControlFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
+func (f *ControlFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 {
+		return &controlHandle{file: f}, fuse.FOPEN_DIRECT_IO, fs.OK
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+func (f *ControlFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	return fuse.ReadResultData(nil), fs.OK
+}
+
+// controlHandle buffers the written arguments and runs the command on
+// flush.
+type controlHandle struct {
+	file *ControlFile
+	buf  []byte
+	done bool
+}
+
+var _ = (fs.FileWriter)((*controlHandle)(nil))
+var _ = (fs.FileFlusher)((*controlHandle)(nil))
+
+func (h *controlHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+func (h *controlHandle) Flush(ctx context.Context) syscall.Errno {
+	// Flush runs once per close of a dup'd descriptor; run only once.
+	args := strings.Fields(string(h.buf))
+	if len(args) == 0 || h.done {
+		return fs.OK
+	}
+	h.done = true
+	scope := "control/" + h.file.name
+	if err := h.file.run(ctx, h.file.repo, args); err != nil {
+		errno := writeErrno(err, syscall.EIO)
+		errors.As(err, &errno) // an errno the command chose wins
+		return lastErrors.fail(scope, h.file.name+" "+strings.Join(args, " "), err, errno)
+	}
+	lastErrors.ok(scope)
+	return fs.OK
+}
+
+// controlSummarize regenerates the Summary node of each node ID given.
+func controlSummarize(ctx context.Context, repo *dag.Repository, ids []string) error {
+	for _, id := range ids {
+		if _, err := repo.GetNode(id); err != nil {
+			return fmt.Errorf("%w: %w", err, syscall.ENOENT)
+		}
+		if _, err := repo.Summarizer.Summarize(ctx, id); err != nil {
+			fmt.Printf("memex-fs: summarize %s: %v\n", id, err)
+			if errors.Is(err, dag.ErrNoLLM) {
+				return fmt.Errorf("%w: %w", err, syscall.ENOTSUP)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// controlIngest ingests each URL or absolute file path as a Source node
+// (see Repository.IngestURL and IngestReader). key=value arguments set
+// meta on every node the command creates, and format=... its format.
+//
+//	echo https://example.com/paper.pdf /tmp/notes.md tags=reading > /control/ingest
+func controlIngest(ctx context.Context, repo *dag.Repository, args []string) error {
+	repo = actingRepo(ctx, repo)
+	opts := dag.IngestOptions{Meta: make(map[string]interface{})}
+	var sources []string
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		switch {
+		case ok && key == "format":
+			opts.Format = value
+		case ok && !strings.Contains(key, "/"):
+			opts.Meta[key] = value
+		case strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") || filepath.IsAbs(arg):
+			sources = append(sources, arg)
+		default:
+			return fmt.Errorf("ingest %q: want a URL, an absolute path or key=value: %w", arg, syscall.EINVAL)
+		}
+	}
+	if err := dag.CheckIngestMeta(opts.Meta); err != nil {
+		return fmt.Errorf("ingest: %v: %w", err, syscall.EINVAL)
+	}
+	var failed []string
+	for _, source := range sources {
+		id, created, err := ingestSource(ctx, repo, source, opts)
+		if err != nil {
+			fmt.Printf("memex-fs: ingest %s: %v\n", source, err)
+			failed = append(failed, source)
+			continue
+		}
+		if created {
+			fmt.Printf("memex-fs: ingested %s as %s\n", source, id)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("ingest failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ingestSource ingests one URL or file for controlIngest.
+func ingestSource(ctx context.Context, repo *dag.Repository, source string, opts dag.IngestOptions) (string, bool, error) {
+	if filepath.IsAbs(source) {
+		f, err := os.Open(source)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+		opts.Filename = filepath.Base(source)
+		return repo.IngestReader(f, opts)
+	}
+	return repo.IngestURL(ctx, source, opts)
+}
+
+// controlMerge merges the nodes after the first into the first, leaving
+// them as aliases that redirect to it.
+//
+//	echo note:a note:b note:c > /control/merge
+func controlMerge(ctx context.Context, repo *dag.Repository, ids []string) error {
+	if len(ids) < 2 {
+		return fmt.Errorf("merge needs the node to keep and at least one other: %w", syscall.EINVAL)
+	}
+	if _, err := actingRepo(ctx, repo).MergeNodes(ctx, ids[0], ids[1:]); err != nil {
+		fmt.Printf("memex-fs: merge: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// controlCompact rewrites the named on-disk journals without their dead
+// records. Only "links" is compactable.
+//
+//	echo links > /control/compact
+func controlCompact(ctx context.Context, repo *dag.Repository, targets []string) error {
+	for _, target := range targets {
+		if target != "links" {
+			return fmt.Errorf("cannot compact %q, only links: %w", target, syscall.EINVAL)
+		}
+		dropped, err := repo.Links.Compact()
+		if err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+			return err
+		}
+		fmt.Printf("memex-fs: compacted link journal, dropped %d records\n", dropped)
+	}
+	return nil
+}
+
+// controlMigrate moves data in an older on-disk layout into the current
+// one. Only "objects", the flat object store, needs an explicit migration:
+// opening a repository of an older format already migrates it (see
+// dag.RepoFormat), so this is for objects an older build wrote since.
+//
+//	echo objects > /control/migrate
+func controlMigrate(ctx context.Context, repo *dag.Repository, targets []string) error {
+	for _, target := range targets {
+		if target != "objects" {
+			return fmt.Errorf("cannot migrate %q, only objects: %w", target, syscall.EINVAL)
+		}
+		moved, err := repo.Store.Migrate(ctx)
+		if moved > 0 {
+			fmt.Printf("memex-fs: moved %d objects into sharded layout\n", moved)
+		}
+		if err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package fuse
+
+import (
+	"context"
+	"slices"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// DuplicatesDir is /duplicates/ — clusters of near-duplicate nodes, each a
+// directory named after its first member ID:
+//
+//	{id}/  symlinks to ../../nodes/{member} for every node in the cluster
+//
+// Merge a cluster with /control/merge, naming the node to keep first:
+//
+//	echo note:a note:b note:c > /control/merge
+type DuplicatesDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*DuplicatesDir)(nil))
+var _ = (fs.NodeReaddirer)((*DuplicatesDir)(nil))
+var _ = (fs.NodeGetattrer)((*DuplicatesDir)(nil))
+
+func (d *DuplicatesDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("duplicates")
+	return fs.OK
+}
+
+func (d *DuplicatesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	var entries []fuse.DirEntry
+	for _, cluster := range d.repo.Duplicates.Clusters() {
+		entries = append(entries, fuse.DirEntry{
+			Name: cluster[0],
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("duplicates/" + cluster[0]),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+// cluster returns the cluster named name, or nil.
+func (d *DuplicatesDir) cluster(name string) []string {
+	for _, c := range d.repo.Duplicates.Clusters() {
+		if c[0] == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (d *DuplicatesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	if d.cluster(name) == nil {
+		return nil, syscall.ENOENT
+	}
+	return d.NewInode(ctx, &DuplicateClusterDir{parent: d, name: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("duplicates/" + name),
+	}), fs.OK
+}
+
+// DuplicateClusterDir is /duplicates/{id}/.
+type DuplicateClusterDir struct {
+	fs.Inode
+	parent *DuplicatesDir
+	name   string
+}
+
+var _ = (fs.NodeLookuper)((*DuplicateClusterDir)(nil))
+var _ = (fs.NodeReaddirer)((*DuplicateClusterDir)(nil))
+var _ = (fs.NodeGetattrer)((*DuplicateClusterDir)(nil))
+
+func (d *DuplicateClusterDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("duplicates/" + d.name)
+	return fs.OK
+}
+
+func (d *DuplicateClusterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, id := range d.parent.cluster(d.name) {
+		entries = append(entries, fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("duplicates/" + d.name + "/" + id),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *DuplicateClusterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !slices.Contains(d.parent.cluster(d.name), name) {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../nodes/" + name}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("duplicates/" + d.name + "/" + name),
+	}), fs.OK
+}
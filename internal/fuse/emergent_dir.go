@@ -35,6 +35,9 @@ func (d *EmergentRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Er
 }
 
 func (d *EmergentRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo); errno != fs.OK {
+		return nil, errno
+	}
 	if name == "clusters" {
 		child := d.NewInode(ctx, &EmergentClustersDir{repo: d.repo}, fs.StableAttr{
 			Mode: syscall.S_IFDIR,
@@ -0,0 +1,62 @@
+package fuse
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// GeneratedFile is a read-only file whose bytes are rendered by gen on each
+// access, with the request's context so a slow render stops when the
+// caller is interrupted. Report-style views (audits, stats, exports) use it rather than
+// repeating the Getattr/Open/Read boilerplate per file.
+type GeneratedFile struct {
+	fs.Inode
+	path string // mount-relative path, for the inode number
+	gen  func(ctx context.Context) []byte
+}
+
+var _ = (fs.NodeGetattrer)((*GeneratedFile)(nil))
+var _ = (fs.NodeOpener)((*GeneratedFile)(nil))
+var _ = (fs.NodeReader)((*GeneratedFile)(nil))
+
+// newGeneratedFile wires a GeneratedFile as a child inode of parent.
+func newGeneratedFile(ctx context.Context, parent *fs.Inode, path string, gen func(ctx context.Context) []byte) *fs.Inode {
+	return parent.NewInode(ctx, &GeneratedFile{path: path, gen: gen}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno(path),
+	})
+}
+
+func (f *GeneratedFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0444
+	out.Size = uint64(len(f.gen(ctx)))
+	out.Ino = stableIno(f.path)
+	return fs.OK
+}
+
+func (f *GeneratedFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	// Contents change between reads; don't let the kernel cache them.
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+func (f *GeneratedFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	return readAt(f.gen(ctx), dest, off), fs.OK
+}
+
+// readAt returns the slice of data that a read of len(dest) at off sees.
+func readAt(data, dest []byte, off int64) fuse.ReadResult {
+	if off >= int64(len(data)) {
+		return fuse.ReadResultData(nil)
+	}
+	end := off + int64(len(dest))
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return fuse.ReadResultData(data[off:end])
+}
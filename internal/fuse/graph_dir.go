@@ -0,0 +1,234 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// GraphDir is /graph/ — whole-graph views. broken-links is a text report of
+// links whose endpoints no longer exist; orphans/ holds symlinks to nodes
+// with no links that nobody has read in a while. Both come from the cached
+// background audit, so reading them is cheap. export.dot, export.graphml
+// and export.json render the whole graph for Graphviz, Gephi and D3;
+// filter/{spec}/ holds the same files for part of it, where spec is e.g.
+// "type=Note,tag=work" or "from=note:a,depth=2" (see dag.ParseGraphFilter).
+type GraphDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*GraphDir)(nil))
+var _ = (fs.NodeReaddirer)((*GraphDir)(nil))
+var _ = (fs.NodeGetattrer)((*GraphDir)(nil))
+
+func (d *GraphDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("graph")
+	return fs.OK
+}
+
+func (d *GraphDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{
+		{Name: "broken-links", Mode: syscall.S_IFREG, Ino: stableIno("graph/broken-links")},
+		{Name: "orphans", Mode: syscall.S_IFDIR, Ino: stableIno("graph/orphans")},
+		{Name: "filter", Mode: syscall.S_IFDIR, Ino: stableIno("graph/filter")},
+	}
+	for _, name := range graphExportFiles {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFREG, Ino: stableIno("graph/" + name)})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *GraphDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	switch name {
+	case "broken-links":
+		return newGeneratedFile(ctx, &d.Inode, "graph/broken-links", d.brokenLinks), fs.OK
+	case "orphans":
+		child := d.NewInode(ctx, &OrphansDir{repo: d.repo}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("graph/orphans"),
+		})
+		return child, fs.OK
+	case "filter":
+		child := d.NewInode(ctx, &GraphFilterDir{repo: d.repo}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("graph/filter"),
+		})
+		return child, fs.OK
+	}
+	if gen := graphExporter(d.repo, dag.GraphFilter{}, name); gen != nil {
+		return newGeneratedFile(ctx, &d.Inode, "graph/"+name, gen), fs.OK
+	}
+	return nil, syscall.ENOENT
+}
+
+// graphExportFiles are the export renderings /graph/ and each
+// /graph/filter/{spec}/ offer.
+var graphExportFiles = []string{"export.dot", "export.graphml", "export.json"}
+
+// graphExporter returns the generator of the named export file over the
+// nodes f selects, or nil if name is not an export file.
+func graphExporter(repo *dag.Repository, f dag.GraphFilter, name string) func(ctx context.Context) []byte {
+	var render func(*dag.GraphExport) []byte
+	switch name {
+	case "export.dot":
+		render = (*dag.GraphExport).DOT
+	case "export.graphml":
+		render = (*dag.GraphExport).GraphML
+	case "export.json":
+		render = (*dag.GraphExport).JSON
+	default:
+		return nil
+	}
+	return func(ctx context.Context) []byte {
+		g, err := repo.ExportGraph(ctx, f)
+		if err != nil {
+			return []byte(fmt.Sprintf("export failed: %v\n", err))
+		}
+		return render(g)
+	}
+}
+
+// GraphFilterDir is /graph/filter/. Lookup treats the name as a filter
+// spec; nothing is listed.
+type GraphFilterDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*GraphFilterDir)(nil))
+var _ = (fs.NodeReaddirer)((*GraphFilterDir)(nil))
+var _ = (fs.NodeGetattrer)((*GraphFilterDir)(nil))
+
+func (d *GraphFilterDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("graph/filter")
+	return fs.OK
+}
+
+func (d *GraphFilterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	return fs.NewListDirStream(nil), fs.OK
+}
+
+func (d *GraphFilterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	f, err := dag.ParseGraphFilter(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+	if f.From != "" {
+		if _, err := d.repo.GetNode(f.From); err != nil {
+			return nil, syscall.ENOENT
+		}
+	}
+	child := d.NewInode(ctx, &GraphExportDir{repo: d.repo, spec: name, filter: f}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("graph/filter/" + name),
+	})
+	return child, fs.OK
+}
+
+// GraphExportDir is /graph/filter/{spec}/ — the export files for the
+// nodes spec selects.
+type GraphExportDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	spec   string
+	filter dag.GraphFilter
+}
+
+var _ = (fs.NodeLookuper)((*GraphExportDir)(nil))
+var _ = (fs.NodeReaddirer)((*GraphExportDir)(nil))
+var _ = (fs.NodeGetattrer)((*GraphExportDir)(nil))
+
+func (d *GraphExportDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("graph/filter/" + d.spec)
+	return fs.OK
+}
+
+func (d *GraphExportDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := make([]fuse.DirEntry, len(graphExportFiles))
+	for i, name := range graphExportFiles {
+		entries[i] = fuse.DirEntry{Name: name, Mode: syscall.S_IFREG, Ino: stableIno("graph/filter/" + d.spec + "/" + name)}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *GraphExportDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if gen := graphExporter(d.repo, d.filter, name); gen != nil {
+		return newGeneratedFile(ctx, &d.Inode, "graph/filter/"+d.spec+"/"+name, gen), fs.OK
+	}
+	return nil, syscall.ENOENT
+}
+
+// brokenLinks renders one line per broken link:
+//
+//	source -[type]-> target  (missing target)
+func (d *GraphDir) brokenLinks(ctx context.Context) []byte {
+	report, err := d.repo.Audit.Report(ctx)
+	if err != nil {
+		return []byte(fmt.Sprintf("audit failed: %v\n", err))
+	}
+	var b strings.Builder
+	for _, l := range report.BrokenLinks {
+		fmt.Fprintf(&b, "%s -[%s]-> %s  (missing %s)\n", l.Source, l.Type, l.Target, l.Missing)
+	}
+	return []byte(b.String())
+}
+
+// OrphansDir is /graph/orphans/ — symlinks to orphaned nodes.
+type OrphansDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*OrphansDir)(nil))
+var _ = (fs.NodeReaddirer)((*OrphansDir)(nil))
+var _ = (fs.NodeGetattrer)((*OrphansDir)(nil))
+
+func (d *OrphansDir) orphans(ctx context.Context) []string {
+	report, err := d.repo.Audit.Report(ctx)
+	if err != nil {
+		return nil
+	}
+	return report.Orphans
+}
+
+func (d *OrphansDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("graph/orphans")
+	return fs.OK
+}
+
+func (d *OrphansDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.orphans(ctx)
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("graph/orphans/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *OrphansDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, id := range d.orphans(ctx) {
+		if id == name {
+			sym := &LinkSymlink{target: "../../nodes/" + id}
+			child := d.NewInode(ctx, sym, fs.StableAttr{
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno("graph/orphans/" + id),
+			})
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
@@ -0,0 +1,132 @@
+package fuse
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/systemshift/memex-fs/internal/dag"
+	"github.com/systemshift/memex-fs/internal/dag/testutil"
+)
+
+// mountRepo mounts repo on a fresh temp dir until tb finishes and returns
+// the mountpoint. Tests and benchmarks are skipped where FUSE can't be
+// mounted (no /dev/fuse, no privileges, no fusermount).
+func mountRepo(tb testing.TB, repo *dag.Repository) string {
+	tb.Helper()
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		tb.Skip("/dev/fuse not available")
+	}
+	mnt := tb.TempDir()
+	opts := mountOptions(false)
+	// Mount directly when running as root so no fusermount is needed;
+	// go-fuse falls back to fusermount otherwise.
+	opts.DirectMount = true
+	server, err := fs.Mount(mnt, &RootNode{repo: repo}, opts)
+	if err != nil {
+		tb.Skipf("mount: %v", err)
+	}
+	tb.Cleanup(func() {
+		if err := server.Unmount(); err != nil {
+			tb.Errorf("unmount: %v", err)
+		}
+	})
+	return mnt
+}
+
+// testMount is a mounted repo driven through ordinary file operations.
+// Helpers fail the test on any OS error; use path() with the os package
+// directly when an error is the expected outcome.
+type testMount struct {
+	t    *testing.T
+	repo *dag.Repository
+	root string
+}
+
+// newTestMount opens an empty repo in a temp dir and mounts it.
+func newTestMount(t *testing.T) *testMount {
+	t.Helper()
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenRepository: %v", err)
+	}
+	return &testMount{t: t, repo: repo, root: mountRepo(t, repo)}
+}
+
+// newSyntheticMount mounts a repo generated from spec.
+func newSyntheticMount(t *testing.T, spec testutil.Spec) *testMount {
+	t.Helper()
+	repo := testutil.Open(t, spec)
+	return &testMount{t: t, repo: repo, root: mountRepo(t, repo)}
+}
+
+// path joins a mount-relative slash path onto the mountpoint.
+func (m *testMount) path(rel string) string {
+	return filepath.Join(m.root, filepath.FromSlash(rel))
+}
+
+func (m *testMount) mkdir(rel string) {
+	m.t.Helper()
+	if err := os.Mkdir(m.path(rel), 0755); err != nil {
+		m.t.Fatalf("mkdir %s: %v", rel, err)
+	}
+}
+
+func (m *testMount) read(rel string) string {
+	m.t.Helper()
+	data, err := os.ReadFile(m.path(rel))
+	if err != nil {
+		m.t.Fatalf("read %s: %v", rel, err)
+	}
+	return string(data)
+}
+
+func (m *testMount) write(rel, data string) {
+	m.t.Helper()
+	if err := os.WriteFile(m.path(rel), []byte(data), 0644); err != nil {
+		m.t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func (m *testMount) symlink(target, rel string) {
+	m.t.Helper()
+	if err := os.Symlink(target, m.path(rel)); err != nil {
+		m.t.Fatalf("symlink %s -> %s: %v", rel, target, err)
+	}
+}
+
+func (m *testMount) readlink(rel string) string {
+	m.t.Helper()
+	target, err := os.Readlink(m.path(rel))
+	if err != nil {
+		m.t.Fatalf("readlink %s: %v", rel, err)
+	}
+	return target
+}
+
+// list returns the sorted entry names of a mount directory.
+func (m *testMount) list(rel string) []string {
+	m.t.Helper()
+	entries, err := os.ReadDir(m.path(rel))
+	if err != nil {
+		m.t.Fatalf("readdir %s: %v", rel, err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+// node fetches id straight from the repository, bypassing the mount.
+func (m *testMount) node(id string) *dag.NodeEnvelope {
+	m.t.Helper()
+	node, err := m.repo.GetNode(id)
+	if err != nil {
+		m.t.Fatalf("GetNode %s: %v", id, err)
+	}
+	return node
+}
@@ -0,0 +1,128 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// InboxDir is /inbox/ — quick capture. Any file written here becomes an
+// Inbox node named by the time, inbox:20060102T150405, when it is closed;
+// items are listed as symlinks to their nodes. Refile an item by moving
+// it: into /nodes/ under a new ID, which takes the type that ID's prefix
+// names, or into /lenses/{lens}/, which makes it a Note read through
+// that lens.
+//
+//	echo "call the venue" > /inbox/todo
+//	mv /inbox/inbox:20261016T091500 /nodes/task:call-venue
+type InboxDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*InboxDir)(nil))
+var _ = (fs.NodeReaddirer)((*InboxDir)(nil))
+var _ = (fs.NodeGetattrer)((*InboxDir)(nil))
+var _ = (fs.NodeCreater)((*InboxDir)(nil))
+var _ = (fs.NodeRenamer)((*InboxDir)(nil))
+
+func (d *InboxDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("inbox")
+	return fs.OK
+}
+
+func (d *InboxDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.repo.InboxItems()
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("inbox/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *InboxDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !slices.Contains(d.repo.InboxItems(), name) {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../nodes/" + name}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("inbox/" + name),
+	}), fs.OK
+}
+
+// Create hands back a write handle that captures the file on flush. The
+// inode is a placeholder: the item is listed under its generated ID.
+func (d *InboxDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	wh := &InboxWriteHandle{repo: d.repo, name: name}
+	child := d.NewInode(ctx, &pendingFile{}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno("inbox/.pending/" + name),
+	})
+	return child, wh, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+// Rename refiles an item: into /nodes/ as the new name, or into a lens.
+func (d *InboxDir) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	var err error
+	switch target := newParent.(type) {
+	case *NodesDir:
+		_, err = actingRepo(ctx, d.repo).Refile(name, newName, "")
+	case *LensViewDir:
+		if newName != name {
+			return syscall.EINVAL
+		}
+		_, err = actingRepo(ctx, d.repo).RefileToLens(name, target.lensID)
+	default:
+		return syscall.EXDEV
+	}
+	if err != nil {
+		fmt.Printf("memex-fs: refile %s: %v\n", name, err)
+		return lastErrors.fail(name, "refile as "+newName, err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(name)
+	return fs.OK
+}
+
+// InboxWriteHandle buffers a file written into /inbox/ and captures it on
+// flush.
+type InboxWriteHandle struct {
+	repo *dag.Repository
+	name string
+	buf  []byte
+}
+
+var _ = (fs.FileWriter)((*InboxWriteHandle)(nil))
+var _ = (fs.FileFlusher)((*InboxWriteHandle)(nil))
+
+func (h *InboxWriteHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+func (h *InboxWriteHandle) Flush(ctx context.Context) syscall.Errno {
+	if h.buf == nil {
+		return fs.OK
+	}
+	if _, err := actingRepo(ctx, h.repo).CaptureInbox(h.name, h.buf); err != nil {
+		fmt.Printf("memex-fs: inbox capture %s: %v\n", h.name, err)
+		return writeErrno(err, syscall.EIO)
+	}
+	// Flush runs once per close(2) of a dup'd fd; only capture once.
+	h.buf = nil
+	return fs.OK
+}
@@ -1,10 +1,131 @@
 package fuse
 
-import "hash/fnv"
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
 
-// stableIno returns a stable inode number for a given path string.
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// remappedIno marks inode numbers handed out after a hash collision. Hashed
+// inode numbers have this bit cleared, so the two ranges never meet.
+const remappedIno = 1 << 63
+
+// inodes is the allocator behind stableIno. A process serves one mount, so
+// one table covers every path the kernel can see.
+var inodes = newInodeTable(pathHash)
+
+// stableIno returns a stable inode number for a given path string. Two
+// distinct paths never share one; see inodeTable.
 func stableIno(path string) uint64 {
+	return inodes.ino(path)
+}
+
+// pathHash is FNV-1a with the remapped bit cleared.
+func pathHash(path string) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(path))
+	return h.Sum64() &^ remappedIno
+}
+
+// pathCheck is a second, independent hash identifying which path owns an
+// inode number without keeping every path string in memory.
+func pathCheck(path string) uint64 {
+	h := fnv.New64()
+	h.Write([]byte(path))
 	return h.Sum64()
 }
+
+// inodeTable hands out inode numbers by hashing paths, and detects when two
+// paths hash alike. The first path keeps the hashed number; later ones get
+// sequential numbers from the remapped range. Remaps are saved, when a
+// file is configured, so a path keeps its number across mounts.
+type inodeTable struct {
+	hash func(string) uint64
+
+	mu      sync.Mutex
+	owners  map[uint64]uint64 // hashed ino → pathCheck of the path owning it
+	remaps  map[string]uint64 // path → remapped ino
+	next    uint64            // next remapped ino
+	persist string            // remap file; "" keeps remaps in memory
+}
+
+func newInodeTable(hash func(string) uint64) *inodeTable {
+	return &inodeTable{
+		hash:   hash,
+		owners: make(map[uint64]uint64),
+		remaps: make(map[string]uint64),
+		next:   remappedIno,
+	}
+}
+
+func (t *inodeTable) ino(path string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ino, ok := t.remaps[path]; ok {
+		return ino
+	}
+	ino, check := t.hash(path), pathCheck(path)
+	// 0 and 1 are not ordinary inode numbers to the kernel or go-fuse.
+	if ino > 1 {
+		owner, taken := t.owners[ino]
+		if !taken {
+			t.owners[ino] = check
+			return ino
+		}
+		if owner == check {
+			return ino
+		}
+	}
+
+	ino = t.next
+	t.next++
+	t.remaps[path] = ino
+	if t.persist != "" {
+		if err := t.save(); err != nil {
+			fmt.Printf("memex-fs: %v\n", err)
+		}
+	}
+	return ino
+}
+
+// load reads saved remaps from path and saves new ones there from now on.
+func (t *inodeTable) load(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.persist = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read inode remaps: %w", err)
+	}
+	var saved map[string]uint64
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("parse inode remaps: %w", err)
+	}
+	for p, ino := range saved {
+		if ino&remappedIno == 0 {
+			continue
+		}
+		t.remaps[p] = ino
+		t.next = max(t.next, ino+1)
+	}
+	return nil
+}
+
+// save writes the remaps. Callers hold t.mu.
+func (t *inodeTable) save() error {
+	data, err := json.MarshalIndent(t.remaps, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := dag.SafeWrite(t.persist, data, 0644); err != nil {
+		return fmt.Errorf("save inode remaps: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package fuse
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInodeTable_RemapsCollisions(t *testing.T) {
+	// Every path hashes alike.
+	table := newInodeTable(func(string) uint64 { return 42 })
+	a, b := table.ino("nodes/a"), table.ino("nodes/b")
+	if a != 42 {
+		t.Errorf("first path got %d, want the hashed 42", a)
+	}
+	if b == a || b&remappedIno == 0 {
+		t.Errorf("colliding path got %d", b)
+	}
+	if table.ino("nodes/a") != a || table.ino("nodes/b") != b {
+		t.Error("inode numbers are not stable")
+	}
+	if c := table.ino("nodes/c"); c == a || c == b {
+		t.Errorf("third path reused %d", c)
+	}
+}
+
+func TestInodeTable_PersistsRemaps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inodes.json")
+	collide := func(string) uint64 { return 42 }
+
+	first := newInodeTable(collide)
+	first.load(path)
+	first.ino("nodes/a")
+	remapped := first.ino("nodes/b")
+
+	// A later mount that sees the paths in the other order.
+	second := newInodeTable(collide)
+	if err := second.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := second.ino("nodes/b"); got != remapped {
+		t.Errorf("remapped path got %d after reload, want %d", got, remapped)
+	}
+	if got := second.ino("nodes/a"); got != 42 {
+		t.Errorf("first path got %d after reload, want 42", got)
+	}
+	if got := second.ino("nodes/c"); got == remapped || got == 42 {
+		t.Errorf("new path reused %d", got)
+	}
+}
+
+func TestStableIno_DistinctAndStable(t *testing.T) {
+	if stableIno("nodes/x") != stableIno("nodes/x") {
+		t.Error("stableIno is not stable")
+	}
+	if stableIno("nodes/x") == stableIno("nodes/y") {
+		t.Error("distinct paths share an inode")
+	}
+}
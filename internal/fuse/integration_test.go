@@ -0,0 +1,977 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/systemshift/memex-fs/internal/dag"
+	"github.com/systemshift/memex-fs/internal/dag/testutil"
+	"github.com/systemshift/memex-fs/internal/dagit"
+)
+
+func TestMount_NodeLifecycle(t *testing.T) {
+	m := newTestMount(t)
+
+	m.mkdir("nodes/note:hello")
+	if got := m.node("note:hello").Type; got != "Note" {
+		t.Errorf("type from mkdir = %q, want Note", got)
+	}
+
+	m.write("nodes/note:hello/content", "hello world")
+	if got := string(m.node("note:hello").Content); got != "hello world" {
+		t.Errorf("repo content = %q", got)
+	}
+	if got := m.read("nodes/note:hello/content"); got != "hello world" {
+		t.Errorf("read back content = %q", got)
+	}
+
+	m.write("nodes/note:hello/meta.json", `{"status": "draft"}`)
+	if got := m.node("note:hello").Meta["status"]; got != "draft" {
+		t.Errorf("meta status = %v", got)
+	}
+
+	m.write("nodes/note:hello/type", "Idea\n")
+	if got := m.node("note:hello").Type; got != "Idea" {
+		t.Errorf("type after write = %q, want Idea", got)
+	}
+	if got := m.list("types/Idea"); !reflect.DeepEqual(got, []string{"note:hello"}) {
+		t.Errorf("types/Idea = %v", got)
+	}
+
+	if err := os.Remove(m.path("nodes/note:hello")); err != nil {
+		t.Fatalf("rmdir: %v", err)
+	}
+	if _, err := m.repo.GetNode("note:hello"); err == nil {
+		t.Error("node still live after rmdir")
+	}
+	if _, err := os.Stat(m.path("nodes/note:hello")); !os.IsNotExist(err) {
+		t.Errorf("stat after rmdir = %v, want not-exist", err)
+	}
+}
+
+func TestMount_LinksAndBacklinks(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/person:alice")
+	m.mkdir("nodes/person:bob")
+
+	m.symlink("../../person:bob", "nodes/person:alice/links/knows:person:bob")
+	links := m.repo.Links.LinksFrom("person:alice")
+	if len(links) != 1 || links[0].Target != "person:bob" || links[0].Type != "knows" {
+		t.Fatalf("links = %v", links)
+	}
+	if got := m.readlink("nodes/person:alice/links/knows:person:bob"); got != "../../person:bob" {
+		t.Errorf("link body = %q", got)
+	}
+	if got := m.list("nodes/person:bob/backlinks"); !reflect.DeepEqual(got, []string{"knows"}) {
+		t.Errorf("backlinks = %v", got)
+	}
+	if got := m.list("nodes/person:bob/backlinks/knows"); !reflect.DeepEqual(got, []string{"person:alice"}) {
+		t.Errorf("backlinks/knows = %v", got)
+	}
+	if got := m.readlink("nodes/person:bob/backlinks/knows:person:alice"); got != "../../person:alice" {
+		t.Errorf("flat backlink body = %q", got)
+	}
+
+	// Links to missing nodes are refused.
+	if err := os.Symlink("x", m.path("nodes/person:alice/links/knows:person:nobody")); err == nil {
+		t.Error("symlink to missing node succeeded")
+	}
+}
+
+func TestMount_SearchAndAttachments(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:fox")
+	m.write("nodes/note:fox/content", "the quick brown fox")
+
+	if got := m.list("search/quick"); !reflect.DeepEqual(got, []string{"note:fox"}) {
+		t.Errorf("search/quick = %v", got)
+	}
+	if _, err := os.Stat(m.path("search/zebra")); !os.IsNotExist(err) {
+		t.Errorf("search with no hits: stat = %v, want not-exist", err)
+	}
+
+	m.write("nodes/note:fox/attachments/photo.png", "\x89PNG")
+	ids := m.repo.Attachments("note:fox")
+	if len(ids) != 1 {
+		t.Fatalf("attachments = %v", ids)
+	}
+	if got := m.node(ids[0]).Meta["filename"]; got != "photo.png" {
+		t.Errorf("attachment filename = %v", got)
+	}
+	if got := m.readlink("nodes/note:fox/attachments/" + ids[0]); !strings.HasSuffix(got, ids[0]) {
+		t.Errorf("attachment link = %q", got)
+	}
+}
+
+func TestMount_EditLock(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:doc")
+
+	m.write("nodes/note:doc/.lock", "")
+	var l dag.EditLock
+	if err := json.Unmarshal([]byte(m.read("nodes/note:doc/.lock")), &l); err != nil {
+		t.Fatalf(".lock body: %v", err)
+	}
+	if l.PID != os.Getpid() || !l.Expires.After(l.Acquired) {
+		t.Errorf(".lock = %+v", l)
+	}
+	// The holder can still write.
+	m.write("nodes/note:doc/content", "mine")
+	if err := os.Remove(m.path("nodes/note:doc/.lock")); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	// Someone else takes the lock; this mount's writes are refused.
+	other, err := dag.NewEditLockSet(filepath.Join(m.repo.MxDir(), "locks"), "did:key:zOther")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Acquire("note:doc", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.path("nodes/note:doc/content"), []byte("theirs?"), 0644); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("write under foreign lock: err = %v, want EBUSY", err)
+	}
+	if err := os.Remove(m.path("nodes/note:doc/.lock")); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("remove foreign lock: err = %v, want EBUSY", err)
+	}
+	if err := os.Remove(m.path("nodes/note:doc")); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("rmdir under foreign lock: err = %v, want EBUSY", err)
+	}
+	if got := string(m.node("note:doc").Content); got != "mine" {
+		t.Errorf("content = %q after refused writes", got)
+	}
+}
+
+func TestMount_StatsActivity(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "hello")
+	m.read("nodes/note:a/content")
+
+	if got := m.list("stats/activity"); !reflect.DeepEqual(got, []string{"by-day.csv", "by-type.json", "top-nodes.txt"}) {
+		t.Errorf("stats/activity = %v", got)
+	}
+	if got := m.read("stats/activity/by-day.csv"); !strings.HasPrefix(got, "day,commits,changes,reads\n") || strings.Count(got, "\n") != 2 {
+		t.Errorf("by-day.csv = %q", got)
+	}
+	var types map[string]dag.TypeActivity
+	if err := json.Unmarshal([]byte(m.read("stats/activity/by-type.json")), &types); err != nil {
+		t.Fatal(err)
+	}
+	if types["Note"].Nodes != 1 || types["Note"].Reads < 1 {
+		t.Errorf("by-type.json Note = %+v", types["Note"])
+	}
+	if got := m.read("stats/activity/top-nodes.txt"); !strings.HasSuffix(got, "  note:a\n") {
+		t.Errorf("top-nodes.txt = %q", got)
+	}
+}
+
+func TestMount_NodeContext(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.mkdir("nodes/note:b")
+	m.symlink("../../note:a", "nodes/note:b/links/cites:note:a")
+
+	got := m.read("nodes/note:a/context")
+	for _, want := range []string{"# Context for note:a\n", "## Backlinks\n\n- note:b (cites)\n", "## Related\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("context missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMount_ReviewDue(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/flashcard:capital")
+	m.write("nodes/flashcard:capital/content", "Capital of France?")
+
+	if got := m.list("review/due"); !reflect.DeepEqual(got, []string{"flashcard:capital"}) {
+		t.Fatalf("review/due = %v, want the new card", got)
+	}
+	if got := m.read("review/due/flashcard:capital/content"); got != "Capital of France?" {
+		t.Errorf("card content = %q", got)
+	}
+	if target := m.readlink("review/due/flashcard:capital/node"); target != "../../../nodes/flashcard:capital" {
+		t.Errorf("node symlink = %q", target)
+	}
+
+	if err := os.WriteFile(m.path("review/due/flashcard:capital/grade"), []byte("9\n"), 0644); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("grade 9: err = %v, want EINVAL", err)
+	}
+	m.write("review/due/flashcard:capital/grade", "4\n")
+
+	if got := m.list("review/due"); len(got) != 0 {
+		t.Errorf("review/due after grading = %v, want empty", got)
+	}
+	node := m.node("flashcard:capital")
+	if node.Meta["reps"] != float64(1) || node.Meta["interval"] != float64(1) {
+		t.Errorf("meta after grading = %v", node.Meta)
+	}
+}
+
+func TestMount_TasksMove(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/task:write")
+	m.write("nodes/task:write/meta.json", `{"due": "2025-03-20"}`)
+	m.mkdir("nodes/task:plan")
+	m.write("nodes/task:plan/meta.json", `{"due": "2025-03-10"}`)
+
+	if got := m.list("tasks/open"); !reflect.DeepEqual(got, []string{"task:plan", "task:write"}) {
+		t.Fatalf("tasks/open = %v", got)
+	}
+	want := []string{"2025-03-10_task:plan", "2025-03-20_task:write"}
+	if got := m.list("tasks/upcoming"); !reflect.DeepEqual(got, want) {
+		t.Errorf("tasks/upcoming = %v, want %v", got, want)
+	}
+
+	if err := os.Rename(m.path("tasks/open/task:write"), m.path("tasks/done/task:write")); err != nil {
+		t.Fatalf("mv: %v", err)
+	}
+	if got := m.list("tasks/done"); !reflect.DeepEqual(got, []string{"task:write"}) {
+		t.Errorf("tasks/done = %v", got)
+	}
+	if got := m.node("task:write").Meta["status"]; got != "done" {
+		t.Errorf("status = %v, want done", got)
+	}
+	if got := m.list("tasks/upcoming"); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("tasks/upcoming after done = %v", got)
+	}
+	if err := os.Rename(m.path("tasks/open/task:plan"), m.path("pinned/task:plan")); err == nil {
+		t.Error("mv out of /tasks/ succeeded")
+	}
+}
+
+func TestMount_LogTags(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "v1")
+	if _, err := m.repo.Tag("v1", "", false); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	m.write("nodes/note:a/content", "v2")
+
+	if got := m.list("log/tags"); !reflect.DeepEqual(got, []string{"v1"}) {
+		t.Fatalf("log/tags = %v", got)
+	}
+	if target := m.readlink("log/tags/v1"); target != "../../at/v1" {
+		t.Errorf("log/tags/v1 -> %q", target)
+	}
+	if got := m.read("log/tags/v1/nodes/note:a/content"); got != "v1" {
+		t.Errorf("content at tag v1 = %q, want v1", got)
+	}
+}
+
+func TestMount_LogByAuthor(t *testing.T) {
+	m := newTestMount(t)
+	const alice = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	m.mkdir("nodes/note:a")
+	if _, err := m.repo.As(alice).CreateNode("note:b", "Note", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.list("log/by"); !slices.Contains(got, alice) {
+		t.Fatalf("log/by = %v", got)
+	}
+	if got := m.list("log/by/" + alice); !reflect.DeepEqual(got, []string{"0"}) {
+		t.Fatalf("log/by/%s = %v", alice, got)
+	}
+	var c dag.CommitObject
+	if err := json.Unmarshal([]byte(m.read("log/by/"+alice+"/0")), &c); err != nil || c.Author != alice {
+		t.Errorf("log/by/%s/0 = %+v, %v", alice, c, err)
+	}
+	if _, err := os.Stat(m.path("log/by/did:key:zNobody")); !os.IsNotExist(err) {
+		t.Errorf("stat of an unknown author = %v, want not-exist", err)
+	}
+}
+
+func TestMount_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMount(t)
+	kubo := dagit.NewMemoryIPFS()
+	peer, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer.CreateNode("note:a", "Note", []byte("one"), nil)
+	head, _ := dagit.Push(ctx, peer, kubo)
+	if err := dagit.Pull(ctx, m.repo, kubo, head); err != nil {
+		t.Fatal(err)
+	}
+	c, _, _ := m.repo.Commits.ResolveCID(head)
+	if _, err := m.repo.Checkout(c, dag.SparseFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	peer.UpdateContent("note:a", []byte("theirs"))
+	head, _ = dagit.Push(ctx, peer, kubo)
+	m.write("nodes/note:a/content", "ours")
+	if err := dagit.Pull(ctx, m.repo, kubo, head); err != nil {
+		t.Fatal(err)
+	}
+	c, _, _ = m.repo.Commits.ResolveCID(head)
+	if _, err := m.repo.MergeCommit(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.list("conflicts"); !reflect.DeepEqual(got, []string{"note:a"}) {
+		t.Fatalf("conflicts = %v", got)
+	}
+	for side, want := range map[string]string{"base": "one", "ours": "ours", "theirs": "theirs"} {
+		if got := m.read("conflicts/note:a/" + side); got != want {
+			t.Errorf("conflicts/note:a/%s = %q, want %q", side, got, want)
+		}
+	}
+	m.write("conflicts/note:a/resolve", "ours and theirs")
+	if got := string(m.node("note:a").Content); got != "ours and theirs" {
+		t.Errorf("content after resolving = %q", got)
+	}
+	if got := m.list("conflicts"); len(got) != 0 {
+		t.Errorf("conflicts after resolving = %v", got)
+	}
+}
+
+func TestMount_StatsStorage(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "hello")
+
+	var report dag.StorageReport
+	if err := json.Unmarshal([]byte(m.read("stats/storage")), &report); err != nil {
+		t.Fatalf("stats/storage is not JSON: %v", err)
+	}
+	if report.Objects == 0 || report.ByType["Note"].Nodes != 1 || report.FreeBytes <= 0 {
+		t.Errorf("stats/storage = %+v", report)
+	}
+}
+
+func TestMount_StatsMemory(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "hello")
+
+	var report dag.MemoryReport
+	if err := json.Unmarshal([]byte(m.read("stats/memory")), &report); err != nil {
+		t.Fatalf("stats/memory is not JSON: %v", err)
+	}
+	if len(report.Indexes) != 4 || report.Indexes[0].Name != "search" || report.Indexes[0].Entries == 0 {
+		t.Errorf("stats/memory = %+v", report)
+	}
+}
+
+func TestMount_HookRejectsWrite(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	hooks := filepath.Join(m.repo.MxDir(), "hooks")
+	os.MkdirAll(hooks, 0755)
+	os.WriteFile(filepath.Join(hooks, "pre-write-deny"), []byte("#!/bin/sh\necho nope >&2\nexit 1\n"), 0755)
+
+	err := os.WriteFile(m.path("nodes/note:a/content"), []byte("x"), 0644)
+	if !errors.Is(err, syscall.EPERM) {
+		t.Errorf("write under rejecting hook: err = %v, want EPERM", err)
+	}
+	if err := os.Mkdir(m.path("nodes/note:b"), 0755); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("mkdir under rejecting hook: err = %v, want EPERM", err)
+	}
+}
+
+// cannedLLM answers every prompt with itself.
+type cannedLLM string
+
+func (l cannedLLM) Complete(ctx context.Context, system, prompt string) (string, error) {
+	return string(l), nil
+}
+
+func TestMount_ControlSummarize(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "a long meeting transcript")
+
+	if err := os.WriteFile(m.path("control/summarize"), []byte("note:a\n"), 0644); !errors.Is(err, syscall.ENOTSUP) {
+		t.Errorf("summarize without LLM: err = %v, want ENOTSUP", err)
+	}
+
+	// The LLM is set before mounting: writes read it from the mount.
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.LLM = cannedLLM("We met.")
+	m = &testMount{t: t, repo: repo, root: mountRepo(t, repo)}
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "a long meeting transcript")
+	if err := os.WriteFile(m.path("control/summarize"), []byte("note:missing\n"), 0644); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("summarize unknown node: err = %v, want ENOENT", err)
+	}
+	m.write("control/summarize", "note:a\n")
+
+	if got := m.read("nodes/summary:note:a/content"); got != "We met." {
+		t.Errorf("summary content = %q", got)
+	}
+	if target := m.readlink("nodes/summary:note:a/links/SUMMARIZES:note:a"); target != "../../note:a" {
+		t.Errorf("SUMMARIZES link -> %q", target)
+	}
+}
+
+func TestMount_Ask(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/person:alice")
+	m.write("nodes/person:alice/content", "Alice leads the graph project")
+
+	q := "ask/who leads the graph project?"
+	if got := m.list(q); !reflect.DeepEqual(got, []string{"answer", "person:alice"}) {
+		t.Fatalf("%s = %v", q, got)
+	}
+	if got := m.read(q + "/answer"); !strings.Contains(got, "person:alice (Person)") {
+		t.Errorf("retrieval answer = %q", got)
+	}
+	if target := m.readlink(q + "/person:alice"); target != "../../nodes/person:alice" {
+		t.Errorf("source symlink -> %q", target)
+	}
+}
+
+func TestMount_SyntheticRepo(t *testing.T) {
+	spec := testutil.Spec{Seed: 3, Nodes: 400, Links: 800, Commits: 20, Accesses: 300}
+	m := newSyntheticMount(t, spec)
+
+	if got := len(m.list("nodes")); got != spec.Nodes {
+		t.Errorf("nodes/ lists %d entries, want %d", got, spec.Nodes)
+	}
+	perType := spec.Nodes / len(testutil.Types)
+	for _, typ := range testutil.Types {
+		if got := len(m.list("types/" + typ)); got != perType {
+			t.Errorf("types/%s lists %d entries, want %d", typ, got, perType)
+		}
+	}
+	id := testutil.NodeID(0)
+	links := m.repo.Links.LinksFrom(id)
+	listed := 0
+	for _, typ := range m.list("nodes/" + id + "/links") {
+		listed += len(m.list("nodes/" + id + "/links/" + typ))
+	}
+	if listed != len(links) {
+		t.Errorf("%s/links lists %d links, want %d", id, listed, len(links))
+	}
+	if got := len(m.list("log")); got == 0 {
+		t.Error("log/ is empty")
+	}
+}
+
+func TestMount_Provenance(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "first draft")
+	m.write("nodes/note:a/content", "second draft")
+
+	got := m.read("nodes/note:a/provenance")
+	for _, want := range []string{"Origin: created locally", "created: create note:a", "changed: update content note:a"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("provenance missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMount_Author(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "mine")
+
+	want := ""
+	if a := m.node("note:a").Author; a != "" {
+		want = a + "\n"
+	}
+	if got := m.read("nodes/note:a/author"); got != want {
+		t.Errorf("author = %q, want %q", got, want)
+	}
+	if !slices.Contains(m.list("nodes/note:a"), "author") {
+		t.Error("author not listed")
+	}
+}
+
+func TestMount_MetaRejectsReservedKeys(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+
+	err := os.WriteFile(m.path("nodes/note:a/meta.json"), []byte(`{"verified": true}`), 0644)
+	if !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("write reserved key: err = %v, want EINVAL", err)
+	}
+	if _, ok := m.node("note:a").Meta["verified"]; ok {
+		t.Error("reserved key was written")
+	}
+	if got := m.read("nodes/note:a/.last-error"); !strings.Contains(got, `meta key "verified"`) {
+		t.Errorf(".last-error = %q", got)
+	}
+
+	m.write("nodes/note:a/meta.json", `{"topic": "ok"}`)
+	if got := m.read("nodes/note:a/.last-error"); got != "" {
+		t.Errorf(".last-error after a good write = %q", got)
+	}
+}
+
+func TestMount_LastError(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+
+	if err := os.WriteFile(m.path("nodes/note:a/meta.json"), []byte("{not json"), 0644); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("write bad meta: err = %v, want EINVAL", err)
+	}
+	for _, f := range []string{"nodes/note:a/.last-error", ".memex/last-error"} {
+		if got := m.read(f); !strings.Contains(got, "write meta.json: invalid JSON") {
+			t.Errorf("%s = %q", f, got)
+		}
+	}
+
+	if err := os.WriteFile(m.path("control/compact"), []byte("refs\n"), 0644); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("bad compact: err = %v, want EINVAL", err)
+	}
+	if got := m.read(".memex/last-error"); !strings.Contains(got, `cannot compact "refs"`) {
+		t.Errorf(".memex/last-error = %q", got)
+	}
+
+	// Success clears the error for its own scope only.
+	m.write("nodes/note:a/content", "fine")
+	if got := m.read("nodes/note:a/.last-error"); got != "" {
+		t.Errorf("node .last-error after a good write = %q", got)
+	}
+	if got := m.read(".memex/last-error"); got == "" {
+		t.Error("global error cleared by an unrelated write")
+	}
+	m.write("control/compact", "links\n")
+	if got := m.read(".memex/last-error"); got != "" {
+		t.Errorf("global error after a good compact = %q", got)
+	}
+}
+
+func TestMount_NodeBundle(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.write("nodes/note:a/content", "portable knowledge")
+	m.mkdir("nodes/note:b")
+	m.symlink("../../note:b", "nodes/note:a/links/cites:note:b")
+
+	var b dag.Bundle
+	if err := json.Unmarshal([]byte(m.read("nodes/note:a/.bundle")), &b); err != nil {
+		t.Fatalf("parse bundle: %v", err)
+	}
+	node, err := b.Verify()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if string(node.Content) != "portable knowledge" || len(b.Links) != 1 {
+		t.Errorf("bundle = %q with %d links", node.Content, len(b.Links))
+	}
+
+	other, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ImportBundle(&b); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+}
+
+func TestMount_ControlCompact(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.mkdir("nodes/note:b")
+	m.symlink("../../note:b", "nodes/note:a/links/cites:note:b")
+	if err := m.repo.RemoveLink("note:a", "note:b", "cites"); err != nil {
+		t.Fatal(err)
+	}
+	m.symlink("../../note:a", "nodes/note:b/links/cites:note:a")
+
+	m.write("control/compact", "links\n")
+	data, err := os.ReadFile(filepath.Join(m.repo.MxDir(), "links.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 1 {
+		t.Errorf("journal has %d records after compaction, want 1", got)
+	}
+	if err := os.WriteFile(m.path("control/compact"), []byte("refs\n"), 0644); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("compact refs: err = %v, want EINVAL", err)
+	}
+}
+
+func TestMount_MkdirRejectsReservedID(t *testing.T) {
+	m := newTestMount(t)
+	if err := os.Mkdir(m.path("nodes/paper:x#b1"), 0755); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("mkdir with '#': err = %v, want EINVAL", err)
+	}
+	m.mkdir("nodes/note:50%__done")
+	if got := m.node("note:50%__done").ID; got != "note:50%__done" {
+		t.Errorf("node ID = %q", got)
+	}
+}
+
+func TestMount_LinksByType(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/note:a")
+	m.mkdir("nodes/note:b")
+	m.mkdir("nodes/person:bob")
+	m.symlink("../../note:b", "nodes/note:a/links/cites:note:b")
+	m.mkdir("nodes/note:a/links/ref:by")
+	m.symlink("../../../person:bob", "nodes/note:a/links/ref:by/person:bob")
+	m.repo.CreateLink("note:a", "https://example.com/x", "cites")
+
+	if got := m.list("nodes/note:a/links"); !reflect.DeepEqual(got, []string{"cites", "ref:by"}) {
+		t.Fatalf("links/ = %v", got)
+	}
+	external := dag.LinkEntry{Source: "note:a", Target: "https://example.com/x", Type: "cites"}
+	if got := m.list("nodes/note:a/links/cites"); !reflect.DeepEqual(got, []string{external.ID(), "note:b"}) {
+		t.Errorf("links/cites = %v", got)
+	}
+	if target := m.readlink("nodes/note:a/links/cites/note:b"); target != "../../../note:b" {
+		t.Errorf("links/cites/note:b -> %q", target)
+	}
+	// A colon in the type: the flat name is ambiguous but still resolves.
+	if target := m.readlink("nodes/note:a/links/ref:by:person:bob"); target != "../../person:bob" {
+		t.Errorf("flat ref:by:person:bob -> %q", target)
+	}
+	if links := m.repo.Links.LinksFrom("note:a"); len(links) != 3 {
+		t.Errorf("links = %+v", links)
+	}
+
+	// Backlinks group the same way, colon in the type included.
+	if got := m.list("nodes/person:bob/backlinks"); !reflect.DeepEqual(got, []string{"ref:by"}) {
+		t.Fatalf("backlinks/ = %v", got)
+	}
+	if got := m.list("nodes/person:bob/backlinks/ref:by"); !reflect.DeepEqual(got, []string{"note:a"}) {
+		t.Errorf("backlinks/ref:by = %v", got)
+	}
+	if target := m.readlink("nodes/person:bob/backlinks/ref:by/note:a"); target != "../../../note:a" {
+		t.Errorf("backlinks/ref:by/note:a -> %q", target)
+	}
+	if target := m.readlink("nodes/person:bob/backlinks/ref:by:note:a"); target != "../../note:a" {
+		t.Errorf("flat backlink ref:by:note:a -> %q", target)
+	}
+}
+
+func TestMount_Calendar(t *testing.T) {
+	m := newTestMount(t)
+	events, err := dag.ParseICS(strings.NewReader("BEGIN:VEVENT\nUID:a\nDTSTART;VALUE=DATE:20250402\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:b\nDTSTART:20250401T100000\nEND:VEVENT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.repo.ImportEvents(t.Context(), events); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.list("calendar"); !reflect.DeepEqual(got, []string{"2025-04"}) {
+		t.Fatalf("calendar = %v", got)
+	}
+	got := m.list("calendar/2025-04")
+	if len(got) != 2 || !strings.HasPrefix(got[0], "2025-04-01T1000_event:") || !strings.HasPrefix(got[1], "2025-04-02_event:") {
+		t.Fatalf("calendar/2025-04 = %v", got)
+	}
+	id := strings.SplitN(got[1], "_", 2)[1]
+	if target := m.readlink("calendar/2025-04/" + got[1]); target != "../../nodes/"+id {
+		t.Errorf("readlink = %q", target)
+	}
+}
+
+func TestMount_Notebook(t *testing.T) {
+	m := newTestMount(t)
+	m.mkdir("nodes/nb:fit")
+	if got := m.list("nodes/nb:fit"); slices.Contains(got, "cells") {
+		t.Errorf("empty node lists cells/: %v", got)
+	}
+	m.write("nodes/nb:fit/content", `{"nbformat": 4, "metadata": {"language_info": {"name": "python"}}, "cells": [
+		{"cell_type": "markdown", "source": "# Fit"},
+		{"cell_type": "code", "execution_count": 1, "source": "1 + 1",
+		 "outputs": [{"output_type": "execute_result", "data": {"text/plain": "2"}}]}]}`)
+
+	if got := m.read("nodes/nb:fit/content.md"); got != "# Fit\n\nIn [1]:\n\n```python\n1 + 1\n```\n\n```\n2\n```\n" {
+		t.Errorf("content.md = %q", got)
+	}
+	if got := m.list("nodes/nb:fit/cells"); !reflect.DeepEqual(got, []string{"001.md", "002.out", "002.py"}) {
+		t.Fatalf("cells = %v", got)
+	}
+	if got := m.read("nodes/nb:fit/cells/002.py"); got != "1 + 1\n" {
+		t.Errorf("cells/002.py = %q", got)
+	}
+	if got := m.node("nb:fit").Meta[dag.MetaExecutionCounts]; !reflect.DeepEqual(got, []interface{}{float64(1)}) {
+		t.Errorf("execution_counts = %v", got)
+	}
+}
+
+func TestMount_Suggestions(t *testing.T) {
+	m := newTestMount(t)
+	m.repo.CreateNode("note:a", "Note", []byte("zeppelin mooring mast at lakehurst"), nil)
+	m.repo.CreateNode("note:b", "Note", []byte("zeppelin mooring mast at lakehurst"), nil)
+	m.repo.CreateNode("note:c", "Note", []byte("zeppelin mooring mast at lakehurst"), nil)
+
+	if got := m.list("suggestions/note:a"); !reflect.DeepEqual(got, []string{"note:b", "note:c"}) {
+		t.Fatalf("suggestions/note:a = %v", got)
+	}
+	if target := m.readlink("suggestions/note:a/note:b"); target != "../../nodes/note:b" {
+		t.Errorf("readlink = %q", target)
+	}
+	if err := os.Rename(m.path("suggestions/note:a/note:b"), m.path("suggestions/note:a/cites:note:b")); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if links := m.repo.Links.LinksFrom("note:a"); len(links) != 1 || links[0].Type != "cites" || links[0].Target != "note:b" {
+		t.Errorf("links = %+v", links)
+	}
+	if err := os.Remove(m.path("suggestions/note:a/note:c")); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if got := m.list("suggestions/note:a"); len(got) != 0 {
+		t.Errorf("suggestions after review = %v", got)
+	}
+	if !m.repo.Rejections.Has("note:a", "note:c") {
+		t.Error("rejection not recorded")
+	}
+}
+
+func TestMount_Duplicates(t *testing.T) {
+	m := newTestMount(t)
+	text := "The airship was moored at Lakehurst overnight before the crossing.\n\n" +
+		"Passengers boarded at dawn while the ground crew checked the gas cells and the engines."
+	m.repo.CreateNode("note:a", "Note", []byte(text), nil)
+	m.repo.CreateNode("note:b", "Note", []byte(text+" Weather was fine."), nil)
+	m.repo.CreateNode("note:c", "Note", []byte("Grocery list: apples, oat milk, coffee beans, and a loaf of sourdough bread."), nil)
+
+	if got := m.list("duplicates"); !reflect.DeepEqual(got, []string{"note:a"}) {
+		t.Fatalf("duplicates = %v", got)
+	}
+	if got := m.list("duplicates/note:a"); !reflect.DeepEqual(got, []string{"note:a", "note:b"}) {
+		t.Fatalf("duplicates/note:a = %v", got)
+	}
+	if target := m.readlink("duplicates/note:a/note:b"); target != "../../nodes/note:b" {
+		t.Errorf("readlink = %q", target)
+	}
+
+	m.write("control/merge", "note:a note:b\n")
+	if target := m.readlink("nodes/note:b"); target != "note:a" {
+		t.Errorf("nodes/note:b -> %q, want note:a", target)
+	}
+	if got := m.read("nodes/note:a/content"); !strings.Contains(got, "engines. Weather was fine.") {
+		t.Errorf("merged content = %q", got)
+	}
+	if got := m.list("duplicates"); len(got) != 0 {
+		t.Errorf("duplicates after merge = %v", got)
+	}
+}
+
+func TestMount_GraphExport(t *testing.T) {
+	m := newTestMount(t)
+	m.repo.CreateNode("note:a", "Note", nil, nil)
+	m.repo.CreateNode("person:b", "Person", nil, nil)
+	m.repo.CreateLink("note:a", "person:b", "mentions")
+
+	if got := m.read("graph/export.dot"); !strings.Contains(got, `"note:a" -> "person:b" [label="mentions"];`) {
+		t.Errorf("export.dot =\n%s", got)
+	}
+	var g dag.GraphExport
+	if err := json.Unmarshal([]byte(m.read("graph/filter/type=Person/export.json")), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].ID != "person:b" || len(g.Links) != 0 {
+		t.Errorf("filtered export = %+v", g)
+	}
+	if got := m.list("graph/filter/from=note:a"); !reflect.DeepEqual(got, []string{"export.dot", "export.graphml", "export.json"}) {
+		t.Errorf("filter dir = %v", got)
+	}
+	if _, err := os.Stat(m.path("graph/filter/from=note:missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing from node: %v", err)
+	}
+}
+
+func TestMount_Inbox(t *testing.T) {
+	m := newTestMount(t)
+	m.repo.CreateNode("lens:economics", "Lens", nil, nil)
+
+	m.write("inbox/todo", "call the venue\n")
+	m.write("inbox/idea", "prices are sticky\n")
+	items := m.list("inbox")
+	if len(items) != 2 {
+		t.Fatalf("inbox = %v", items)
+	}
+	var todo, idea string
+	for _, id := range items {
+		switch string(m.node(id).Content) {
+		case "call the venue\n":
+			todo = id
+		case "prices are sticky\n":
+			idea = id
+		}
+	}
+	if todo == "" || idea == "" {
+		t.Fatalf("captured items = %v", items)
+	}
+
+	if err := os.Rename(m.path("inbox/"+todo), m.path("nodes/task:call-venue")); err != nil {
+		t.Fatalf("refile into nodes: %v", err)
+	}
+	if got := m.node("task:call-venue"); got.Type != "Task" || string(got.Content) != "call the venue\n" {
+		t.Errorf("refiled node = %+v", got)
+	}
+	if err := os.Rename(m.path("inbox/"+idea), m.path("lenses/lens:economics/"+idea)); err != nil {
+		t.Fatalf("refile into lens: %v", err)
+	}
+	if got := m.list("lenses/lens:economics"); !reflect.DeepEqual(got, []string{idea}) {
+		t.Errorf("lens view = %v", got)
+	}
+	if got := m.list("inbox"); len(got) != 0 {
+		t.Errorf("inbox after refiling = %v", got)
+	}
+}
+
+func TestMount_SearchAlias(t *testing.T) {
+	m := newTestMount(t)
+	m.repo.CreateNode("query:projectx", "Query", []byte("zeppelin hangar"), nil)
+	m.repo.CreateNode("note:a", "Note", []byte("the zeppelin hangar"), nil)
+	m.repo.CreateNode("note:b", "Note", []byte("a hangar for gliders"), nil)
+
+	if got, want := m.list("search/@projectx"), m.list("search/zeppelin hangar"); !reflect.DeepEqual(got, want) || len(got) == 0 {
+		t.Errorf("search/@projectx = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(m.path("search/@nope")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unknown alias: %v", err)
+	}
+}
+
+func TestMount_ControlIngest(t *testing.T) {
+	m := newTestMount(t)
+	file := filepath.Join(t.TempDir(), "reading.md")
+	if err := os.WriteFile(file, []byte("# chapter one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.write("control/ingest", file+" tags=reading\n")
+	ids := m.list("types/Source")
+	if len(ids) != 1 {
+		t.Fatalf("Source nodes = %v", ids)
+	}
+	node := m.node(ids[0])
+	if string(node.Content) != "# chapter one" || node.Meta["format"] != "md" || node.Meta["filename"] != "reading.md" || node.Meta["tags"] != "reading" {
+		t.Errorf("ingested node = %+v", node)
+	}
+
+	if err := os.WriteFile(m.path("control/ingest"), []byte("relative.txt\n"), 0644); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("relative path: err = %v, want EINVAL", err)
+	}
+	// Reserved keys, and those ingestion sets itself, are refused.
+	for _, kv := range []string{"verified=true", "acl=did:key:z6Mk", "size_bytes=1", "mime=text/x"} {
+		if err := os.WriteFile(m.path("control/ingest"), []byte(file+" "+kv+"\n"), 0644); !errors.Is(err, syscall.EINVAL) {
+			t.Errorf("ingest with %s: err = %v, want EINVAL", kv, err)
+		}
+	}
+	if ids := m.list("types/Source"); len(ids) != 1 {
+		t.Errorf("rejected ingests created %v", ids)
+	}
+}
+
+func TestMount_Subtrees(t *testing.T) {
+	RegisterSubtree("usage", func(repo *dag.Repository) fs.InodeEmbedder { return &StatsDir{repo: repo} })
+	t.Cleanup(func() {
+		subtreesMu.Lock()
+		delete(subtrees, "usage")
+		subtreesMu.Unlock()
+	})
+	script := filepath.Join(t.TempDir(), "weather")
+	body := `#!/bin/sh
+case "$1 $2" in
+"ls /") printf 'today\nforecast/\n' ;;
+"ls /forecast") echo monday ;;
+"cat /today") echo sunny ;;
+"cat /forecast/monday") echo "rain at $MEMEX_REPO" ;;
+*) echo "no $2" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Config.Subtrees = map[string][]string{"weather": {script}, "nodes": {script}}
+	m := &testMount{t: t, repo: repo, root: mountRepo(t, repo)}
+
+	if got := m.list("weather"); !reflect.DeepEqual(got, []string{"forecast", "today"}) {
+		t.Errorf("weather/ = %v", got)
+	}
+	if got := m.read("weather/today"); got != "sunny\n" {
+		t.Errorf("weather/today = %q", got)
+	}
+	if got, want := m.read("weather/forecast/monday"), "rain at "+filepath.Dir(repo.MxDir())+"\n"; got != want {
+		t.Errorf("weather/forecast/monday = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(m.path("weather/tomorrow")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stat weather/tomorrow: %v", err)
+	}
+	if got := m.list("usage"); !reflect.DeepEqual(got, []string{"activity", "memory", "storage"}) {
+		t.Errorf("registered usage/ = %v", got)
+	}
+	m.mkdir("nodes/note:a")
+	if got := m.list("nodes"); !reflect.DeepEqual(got, []string{"note:a"}) {
+		t.Errorf("nodes/ = %v, want the built-in directory kept", got)
+	}
+}
+
+func TestMount_Reports(t *testing.T) {
+	m := newTestMount(t)
+	m.repo.CreateNode("note:a", "Note", nil, map[string]interface{}{"title": "Airships"})
+	m.repo.CreateNode("report:notes", dag.ReportType, []byte(`{{range type "Note"}}- {{title .}}{{"\n"}}{{end}}`), map[string]interface{}{"schedule": "daily"})
+
+	if got := m.list("reports"); !reflect.DeepEqual(got, []string{"report:notes"}) {
+		t.Errorf("reports/ = %v", got)
+	}
+	if got := m.read("reports/report:notes/output"); got != "- Airships\n" {
+		t.Errorf("output = %q", got)
+	}
+	m.repo.CreateNode("note:b", "Note", nil, nil)
+	if got := m.read("reports/report:notes/output"); got != "- Airships\n- note:b\n" {
+		t.Errorf("output after a new note = %q", got)
+	}
+
+	made, err := m.repo.SnapshotReports(context.Background(), time.Now())
+	if err != nil || len(made) != 1 {
+		t.Fatalf("SnapshotReports = %v, %v", made, err)
+	}
+	if got := m.readlink("reports/report:notes/snapshots/" + made[0]); got != "../../../nodes/"+made[0] {
+		t.Errorf("snapshot symlink -> %s", got)
+	}
+	if got := m.read("reports/report:notes/snapshots/" + made[0] + "/content"); got != "- Airships\n- note:b\n" {
+		t.Errorf("snapshot content = %q", got)
+	}
+}
+
+func TestMount_Schedule(t *testing.T) {
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Config.Schedule = map[string]string{"compact": "@every 1h"}
+	m := &testMount{t: t, repo: repo, root: mountRepo(t, repo)}
+	if got := m.read(".memex/schedule"); !strings.HasPrefix(got, "compact\t@every 1h\tlast=never\t") {
+		t.Errorf("schedule before a run = %q", got)
+	}
+	if err := m.repo.Scheduler.RunDue(context.Background(), time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.read(".memex/schedule"); !strings.Contains(got, "ok: dropped 0 link records") {
+		t.Errorf("schedule after a run = %q", got)
+	}
+}
+
+func TestMount_Status(t *testing.T) {
+	m := newTestMount(t)
+	got := m.read(".memex/status")
+	for _, want := range []string{"ready\n", "search\tready\t", "cochange\tready\t"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("status = %q, want %q in it", got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// JournalDir is /journal/, /journal/YYYY/ or /journal/YYYY/MM/ — one type
+// for all three levels of the calendar. prefix is "", "YYYY" or "YYYY-MM";
+// children are the next date segment of every day that has nodes. Today
+// always exists so the daily note has somewhere to be written.
+type JournalDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	prefix string
+}
+
+var _ = (fs.NodeLookuper)((*JournalDir)(nil))
+var _ = (fs.NodeReaddirer)((*JournalDir)(nil))
+var _ = (fs.NodeGetattrer)((*JournalDir)(nil))
+
+// journalPath maps a date prefix to its path under the mount.
+func journalPath(prefix string) string {
+	if prefix == "" {
+		return "journal"
+	}
+	return "journal/" + strings.ReplaceAll(prefix, "-", "/")
+}
+
+// journalDays returns every day with nodes, plus today.
+func journalDays(repo *dag.Repository) []string {
+	days := repo.Dates.Days()
+	today := time.Now().Format(dag.DayLayout)
+	i := sort.SearchStrings(days, today)
+	if i == len(days) || days[i] != today {
+		days = append(days, "")
+		copy(days[i+1:], days[i:])
+		days[i] = today
+	}
+	return days
+}
+
+// children returns the distinct next segments under d.prefix, in order.
+func (d *JournalDir) children() []string {
+	var out []string
+	for _, day := range journalDays(d.repo) {
+		rest := day
+		if d.prefix != "" {
+			if !strings.HasPrefix(day, d.prefix+"-") {
+				continue
+			}
+			rest = day[len(d.prefix)+1:]
+		}
+		seg := rest
+		if i := strings.Index(rest, "-"); i >= 0 {
+			seg = rest[:i]
+		}
+		if len(out) == 0 || out[len(out)-1] != seg {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+func (d *JournalDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(journalPath(d.prefix))
+	return fs.OK
+}
+
+func (d *JournalDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	segs := d.children()
+	entries := make([]fuse.DirEntry, len(segs))
+	for i, seg := range segs {
+		entries[i] = fuse.DirEntry{
+			Name: seg,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(journalPath(d.prefix) + "/" + seg),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *JournalDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	found := false
+	for _, seg := range d.children() {
+		if seg == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, syscall.ENOENT
+	}
+
+	child := name
+	if d.prefix != "" {
+		child = d.prefix + "-" + name
+	}
+	var node fs.InodeEmbedder
+	if strings.Count(child, "-") == 2 {
+		node = &JournalDayDir{repo: d.repo, day: child}
+	} else {
+		node = &JournalDir{repo: d.repo, prefix: child}
+	}
+	inode := d.NewInode(ctx, node, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(journalPath(child)),
+	})
+	return inode, fs.OK
+}
+
+// JournalDayDir is /journal/YYYY/MM/DD/ — symlinks to every node created on
+// (or dated to) that day. Writing any file here appends its contents to the
+// day's Journal node, creating journal:YYYY-MM-DD on first write.
+type JournalDayDir struct {
+	fs.Inode
+	repo *dag.Repository
+	day  string // YYYY-MM-DD
+}
+
+var _ = (fs.NodeLookuper)((*JournalDayDir)(nil))
+var _ = (fs.NodeReaddirer)((*JournalDayDir)(nil))
+var _ = (fs.NodeGetattrer)((*JournalDayDir)(nil))
+var _ = (fs.NodeCreater)((*JournalDayDir)(nil))
+
+func (d *JournalDayDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno(journalPath(d.day))
+	return fs.OK
+}
+
+func (d *JournalDayDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.repo.Dates.NodesOn(d.day)
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno(journalPath(d.day) + "/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *JournalDayDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, id := range d.repo.Dates.NodesOn(d.day) {
+		if id == name {
+			sym := &LinkSymlink{target: "../../../../nodes/" + id}
+			child := d.NewInode(ctx, sym, fs.StableAttr{
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno(journalPath(d.day) + "/" + id),
+			})
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+func (d *JournalDayDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	day, err := time.ParseInLocation(dag.DayLayout, d.day, time.Local)
+	if err != nil {
+		return nil, nil, 0, syscall.EINVAL
+	}
+	wh := &JournalWriteHandle{repo: d.repo, day: day}
+	child := d.NewInode(ctx, &pendingFile{}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno(journalPath(d.day) + "/.pending/" + name),
+	})
+	return child, wh, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+// JournalWriteHandle buffers a file written into a day directory and
+// appends it to that day's Journal node on flush.
+type JournalWriteHandle struct {
+	repo *dag.Repository
+	day  time.Time
+	buf  []byte
+}
+
+var _ = (fs.FileWriter)((*JournalWriteHandle)(nil))
+var _ = (fs.FileFlusher)((*JournalWriteHandle)(nil))
+
+func (h *JournalWriteHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+func (h *JournalWriteHandle) Flush(ctx context.Context) syscall.Errno {
+	if h.buf == nil {
+		return fs.OK
+	}
+	if _, err := actingRepo(ctx, h.repo).AppendJournal(h.day, h.buf); err != nil {
+		fmt.Printf("memex-fs: journal append %s: %v\n", h.day.Format(dag.DayLayout), err)
+		return syscall.EIO
+	}
+	// Flush runs once per close(2) of a dup'd fd; only append once.
+	h.buf = nil
+	return fs.OK
+}
@@ -0,0 +1,170 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// lastErrorFileName is the per-node record of why the last write to the
+// node failed. FUSE can only return an errno; this is where the reason
+// behind it can be read back. /.memex/last-error holds the latest failure
+// anywhere in the mount.
+const lastErrorFileName = ".last-error"
+
+// errorLog keeps the most recent failure per scope — a node ID, or a path
+// such as "control/compact" for writes that are not to a node — until an
+// operation on that scope succeeds.
+type errorLog struct {
+	mu      sync.Mutex
+	byScope map[string]string
+	last    string // latest failure in any scope
+	scope   string // scope of last
+}
+
+// lastErrors is the mount's error log. A process serves one mount, as
+// with inodes.
+var lastErrors = &errorLog{byScope: make(map[string]string)}
+
+// fail records that op on scope failed with err and returns errno, so a
+// failing call site can report and return in one statement.
+func (l *errorLog) fail(scope, op string, err error, errno syscall.Errno) syscall.Errno {
+	msg := fmt.Sprintf("%s %s: %v\n", time.Now().UTC().Format(time.RFC3339), op, err)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.byScope[scope] = msg
+	l.last, l.scope = msg, scope
+	return errno
+}
+
+// ok forgets scope's last failure, and the global one if it was scope's.
+func (l *errorLog) ok(scope string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.byScope, scope)
+	if l.scope == scope {
+		l.last, l.scope = "", ""
+	}
+}
+
+func (l *errorLog) get(scope string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.byScope[scope]
+}
+
+func (l *errorLog) latest() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// newLastErrorFile is /nodes/{id}/.last-error — empty unless the last
+// write to the node failed.
+func (d *NodeDir) newLastErrorFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/"+lastErrorFileName, func(context.Context) []byte {
+		return []byte(lastErrors.get(d.nodeID))
+	})
+}
+
+// MemexDir is /.memex/ — the mount reporting on itself. Contains
+// last-error, the latest failed write anywhere in the mount; schedule,
+// how each scheduled maintenance job last went and when it runs next; and
+// status, whether the indexes are still building after startup.
+type MemexDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*MemexDir)(nil))
+var _ = (fs.NodeReaddirer)((*MemexDir)(nil))
+var _ = (fs.NodeGetattrer)((*MemexDir)(nil))
+
+func (d *MemexDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(".memex")
+	return fs.OK
+}
+
+func (d *MemexDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	return fs.NewListDirStream([]fuse.DirEntry{
+		{Name: "last-error", Mode: syscall.S_IFREG, Ino: stableIno(".memex/last-error")},
+		{Name: "schedule", Mode: syscall.S_IFREG, Ino: stableIno(".memex/schedule")},
+		{Name: "status", Mode: syscall.S_IFREG, Ino: stableIno(".memex/status")},
+	}), fs.OK
+}
+
+func (d *MemexDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	switch name {
+	case "last-error":
+		return newGeneratedFile(ctx, &d.Inode, ".memex/last-error", func(context.Context) []byte {
+			return []byte(lastErrors.latest())
+		}), fs.OK
+	case "schedule":
+		return newGeneratedFile(ctx, &d.Inode, ".memex/schedule", d.schedule), fs.OK
+	case "status":
+		return newGeneratedFile(ctx, &d.Inode, ".memex/status", d.status), fs.OK
+	}
+	return nil, syscall.ENOENT
+}
+
+// schedule renders one line per scheduled job: its name, spec, and when
+// it last ran, for how long and with what result, then when it runs next.
+func (d *MemexDir) schedule(context.Context) []byte {
+	var b strings.Builder
+	for _, st := range d.repo.Scheduler.Status() {
+		last, outcome := "never", "-"
+		if !st.LastRun.IsZero() {
+			last = st.LastRun.UTC().Format(time.RFC3339)
+			outcome = "ok: " + st.Result
+			if st.Error != "" {
+				outcome = "failed: " + st.Error
+			}
+		}
+		next := "never"
+		if !st.Next.IsZero() {
+			next = st.Next.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "%s\t%s\tlast=%s\ttook=%s\tnext=%s\t%s\n", st.Job, st.Spec, last, st.Duration, next, outcome)
+	}
+	return []byte(b.String())
+}
+
+// status renders "ready" or "building", then a line per index: its name,
+// whether it is ready, and how long it took or has taken so far.
+func (d *MemexDir) status(context.Context) []byte {
+	var b strings.Builder
+	if d.repo.Ready() {
+		b.WriteString("ready\n")
+	} else {
+		b.WriteString("building\n")
+	}
+	for _, st := range d.repo.IndexStates() {
+		state := "building"
+		if st.Ready {
+			state = "ready"
+		}
+		if st.Error != "" {
+			state = "failed: " + st.Error
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", st.Name, state, st.Took.Round(time.Millisecond))
+	}
+	return []byte(b.String())
+}
+
+// notReady returns EAGAIN while any of the named indexes is still being
+// built after startup, so a view built on them fails fast and can be
+// retried rather than showing partial results; /.memex/status says when.
+func notReady(repo *dag.Repository, indexes ...string) syscall.Errno {
+	if repo.Ready(indexes...) {
+		return fs.OK
+	}
+	return syscall.EAGAIN
+}
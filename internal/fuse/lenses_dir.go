@@ -26,6 +26,9 @@ func (d *LensesRootDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse
 }
 
 func (d *LensesRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
 	ids := d.repo.Search.FilterByType("Lens", 0)
 	entries := make([]fuse.DirEntry, len(ids))
 	for i, id := range ids {
@@ -74,7 +77,7 @@ func (d *LensViewDir) entities() []string {
 	links := d.repo.Links.LinksTo(d.lensID)
 	var ids []string
 	for _, l := range links {
-		if l.Type == "INTERPRETED_THROUGH" {
+		if l.Type == dag.LinkInterpretedThrough {
 			ids = append(ids, l.Source)
 		}
 	}
@@ -0,0 +1,151 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// lockFileName is the per-node advisory edit lock. Creating it locks the
+// node for this mount's identity; while it exists, writes from any other
+// holder fail with EBUSY. Writing a duration ("2h") to it renews the lock
+// for that long; removing it releases the lock.
+const lockFileName = ".lock"
+
+// LockFile is /nodes/{id}/.lock — the live lock's holder DID, pid and
+// timestamps as JSON.
+type LockFile struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeGetattrer)((*LockFile)(nil))
+var _ = (fs.NodeSetattrer)((*LockFile)(nil))
+var _ = (fs.NodeOpener)((*LockFile)(nil))
+var _ = (fs.NodeReader)((*LockFile)(nil))
+
+func (d *NodeDir) newLockFile(ctx context.Context) *fs.Inode {
+	return d.NewInode(ctx, &LockFile{repo: d.repo, nodeID: d.nodeID}, fs.StableAttr{
+		Mode: syscall.S_IFREG,
+		Ino:  stableIno("nodes/" + d.nodeID + "/" + lockFileName),
+	})
+}
+
+func (f *LockFile) lockBytes() ([]byte, bool) {
+	l, ok := f.repo.EditLocks.Get(f.nodeID)
+	if !ok {
+		return nil, false
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, false
+	}
+	return append(data, '\n'), true
+}
+
+func (f *LockFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	data, ok := f.lockBytes()
+	if !ok {
+		return syscall.ENOENT
+	}
+	out.Mode = 0644
+	out.Size = uint64(len(data))
+	out.Ino = stableIno("nodes/" + f.nodeID + "/" + lockFileName)
+	return fs.OK
+}
+
+func (f *LockFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
+func (f *LockFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 || flags&syscall.O_TRUNC != 0 {
+		if err := f.repo.EditLocks.Check(f.nodeID); err != nil {
+			return nil, 0, syscall.EBUSY
+		}
+		return &lockHandle{repo: f.repo, nodeID: f.nodeID}, fuse.FOPEN_DIRECT_IO, fs.OK
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+func (f *LockFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	data, ok := f.lockBytes()
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	return readAt(data, dest, off), fs.OK
+}
+
+// Create takes the lock. Only .lock can be created in a node directory.
+func (d *NodeDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if name != lockFileName {
+		return nil, nil, 0, syscall.EPERM
+	}
+	if _, err := d.repo.GetNode(d.nodeID); err != nil {
+		return nil, nil, 0, syscall.ENOENT
+	}
+	if _, err := d.repo.EditLocks.Acquire(d.nodeID, dag.DefaultEditLockTTL); err != nil {
+		fmt.Printf("memex-fs: lock %s: %v\n", d.nodeID, err)
+		return nil, nil, 0, lastErrors.fail(d.nodeID, "lock", err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(d.nodeID)
+	return d.newLockFile(ctx), &lockHandle{repo: d.repo, nodeID: d.nodeID}, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+// Unlink releases the lock. Another holder's live lock cannot be removed.
+func (d *NodeDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	if name != lockFileName {
+		return syscall.EPERM
+	}
+	if _, ok := d.repo.EditLocks.Get(d.nodeID); !ok {
+		return syscall.ENOENT
+	}
+	if err := d.repo.EditLocks.Release(d.nodeID); err != nil {
+		return writeErrno(err, syscall.EIO)
+	}
+	return fs.OK
+}
+
+// lockHandle renews the lock on flush when a duration was written.
+type lockHandle struct {
+	repo   *dag.Repository
+	nodeID string
+	buf    []byte
+}
+
+var _ = (fs.FileWriter)((*lockHandle)(nil))
+var _ = (fs.FileFlusher)((*lockHandle)(nil))
+
+func (h *lockHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+func (h *lockHandle) Flush(ctx context.Context) syscall.Errno {
+	body := strings.TrimSpace(string(h.buf))
+	if body == "" {
+		return fs.OK
+	}
+	ttl, err := time.ParseDuration(body)
+	if err != nil || ttl <= 0 {
+		return syscall.EINVAL
+	}
+	if _, err := h.repo.EditLocks.Acquire(h.nodeID, ttl); err != nil {
+		fmt.Printf("memex-fs: lock %s: %v\n", h.nodeID, err)
+		return lastErrors.fail(h.nodeID, "renew lock", err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(h.nodeID)
+	return fs.OK
+}
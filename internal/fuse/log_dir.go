@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fs"
@@ -14,10 +15,27 @@ import (
 const maxLogEntries = 64
 
 // LogDir exposes recent commits as files in the FUSE tree.
-// Layout: log/HEAD (CID string), log/0 (newest commit JSON), log/1, ...
+// Layout: log/HEAD (CID string), log/0 (newest commit JSON), log/1, ...,
+// log/tags/ (one symlink per tag into /at/), and log/by/{did}/, a LogDir
+// of the commits by one author alone.
 type LogDir struct {
 	fs.Inode
-	repo *dag.Repository
+	repo   *dag.Repository
+	author string // set for log/by/{author}/
+}
+
+func (d *LogDir) path() string {
+	if d.author != "" {
+		return "log/by/" + d.author
+	}
+	return "log"
+}
+
+func (d *LogDir) history(n int) ([]dag.CommitSummary, error) {
+	if d.author != "" {
+		return d.repo.Commits.HistoryBy(d.author, n)
+	}
+	return d.repo.Commits.History(n)
 }
 
 var _ = (fs.NodeLookuper)((*LogDir)(nil))
@@ -26,27 +44,42 @@ var _ = (fs.NodeGetattrer)((*LogDir)(nil))
 
 func (d *LogDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0755
-	out.Ino = stableIno("log")
+	out.Ino = stableIno(d.path())
 	return fs.OK
 }
 
 func (d *LogDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	entries := []fuse.DirEntry{
-		{Name: "HEAD", Mode: syscall.S_IFREG, Ino: stableIno("log/HEAD")},
+	var entries []fuse.DirEntry
+	if d.author == "" {
+		entries = []fuse.DirEntry{
+			{Name: "HEAD", Mode: syscall.S_IFREG, Ino: stableIno("log/HEAD")},
+			{Name: "tags", Mode: syscall.S_IFDIR, Ino: stableIno("log/tags")},
+			{Name: "by", Mode: syscall.S_IFDIR, Ino: stableIno("log/by")},
+		}
 	}
-	commits, _ := d.repo.Commits.Log(maxLogEntries)
+	commits, _ := d.history(maxLogEntries)
 	for i := range commits {
 		name := fmt.Sprintf("%d", i)
 		entries = append(entries, fuse.DirEntry{
 			Name: name,
 			Mode: syscall.S_IFREG,
-			Ino:  stableIno("log/" + name),
+			Ino:  stableIno(d.path() + "/" + name),
 		})
 	}
 	return fs.NewListDirStream(entries), fs.OK
 }
 
 func (d *LogDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if d.author != "" {
+		return d.lookupEntry(ctx, name)
+	}
+	if name == "by" {
+		child := d.NewInode(ctx, &LogByDir{repo: d.repo}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("log/by"),
+		})
+		return child, fs.OK
+	}
 	if name == "HEAD" {
 		f := &LogHeadFile{repo: d.repo}
 		child := d.NewInode(ctx, f, fs.StableAttr{
@@ -55,22 +88,73 @@ func (d *LogDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*
 		})
 		return child, fs.OK
 	}
+	if name == "tags" {
+		child := d.NewInode(ctx, &LogTagsDir{repo: d.repo}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("log/tags"),
+		})
+		return child, fs.OK
+	}
+	return d.lookupEntry(ctx, name)
+}
 
-	// Parse index
+// lookupEntry looks up the numbered commit file name.
+func (d *LogDir) lookupEntry(ctx context.Context, name string) (*fs.Inode, syscall.Errno) {
 	var idx int
 	if _, err := fmt.Sscanf(name, "%d", &idx); err != nil || idx < 0 {
 		return nil, syscall.ENOENT
 	}
 
-	commits, _ := d.repo.Commits.Log(idx + 1)
+	commits, _ := d.history(idx + 1)
 	if idx >= len(commits) {
 		return nil, syscall.ENOENT
 	}
+	commit, err := d.repo.Commits.Resolve(commits[idx].CID)
+	if err != nil {
+		return nil, syscall.EIO
+	}
 
-	f := &LogEntryFile{commit: &commits[idx], name: name}
+	f := &LogEntryFile{commit: commit, path: d.path() + "/" + name}
 	child := d.NewInode(ctx, f, fs.StableAttr{
 		Mode: syscall.S_IFREG,
-		Ino:  stableIno("log/" + name),
+		Ino:  stableIno(f.path),
+	})
+	return child, fs.OK
+}
+
+// LogByDir is /log/by/ — a directory per commit author, named by DID.
+type LogByDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*LogByDir)(nil))
+var _ = (fs.NodeReaddirer)((*LogByDir)(nil))
+var _ = (fs.NodeGetattrer)((*LogByDir)(nil))
+
+func (d *LogByDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("log/by")
+	return fs.OK
+}
+
+func (d *LogByDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	authors, _ := d.repo.Commits.Authors()
+	entries := make([]fuse.DirEntry, len(authors))
+	for i, a := range authors {
+		entries[i] = fuse.DirEntry{Name: a, Mode: syscall.S_IFDIR, Ino: stableIno("log/by/" + a)}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *LogByDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	authors, _ := d.repo.Commits.Authors()
+	if !slices.Contains(authors, name) {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &LogDir{repo: d.repo, author: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("log/by/" + name),
 	})
 	return child, fs.OK
 }
@@ -120,7 +204,7 @@ func (f *LogHeadFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, o
 type LogEntryFile struct {
 	fs.Inode
 	commit *dag.CommitObject
-	name   string
+	path   string
 }
 
 var _ = (fs.NodeGetattrer)((*LogEntryFile)(nil))
@@ -135,7 +219,7 @@ func (f *LogEntryFile) commitBytes() []byte {
 func (f *LogEntryFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0444
 	out.Size = uint64(len(f.commitBytes()))
-	out.Ino = stableIno("log/" + f.name)
+	out.Ino = stableIno(f.path)
 	return fs.OK
 }
 
@@ -154,3 +238,44 @@ func (f *LogEntryFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte,
 	}
 	return fuse.ReadResultData(data[off:end]), fs.OK
 }
+
+// LogTagsDir is /log/tags/ — each tag as a symlink to ../../at/{name}, so
+// following it opens the tagged snapshot.
+type LogTagsDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*LogTagsDir)(nil))
+var _ = (fs.NodeReaddirer)((*LogTagsDir)(nil))
+var _ = (fs.NodeGetattrer)((*LogTagsDir)(nil))
+
+func (d *LogTagsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("log/tags")
+	return fs.OK
+}
+
+func (d *LogTagsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, t := range d.repo.Tags.List() {
+		entries = append(entries, fuse.DirEntry{
+			Name: t.Name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("log/tags/" + t.Name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *LogTagsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if _, ok := d.repo.Tags.Get(name); !ok {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../at/" + name}
+	child := d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("log/tags/" + name),
+	})
+	return child, fs.OK
+}
@@ -1,6 +1,10 @@
 package fuse
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+
 	"github.com/hanwen/go-fuse/v2/fs"
 	gofuse "github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/systemshift/memex-fs/internal/dag"
@@ -9,9 +13,19 @@ import (
 // MountFS mounts the FUSE filesystem at mountpoint backed by repo.
 // Returns the server (call server.Wait() to block, server.Unmount() to stop).
 func MountFS(mountpoint string, repo *dag.Repository, debug bool) (*gofuse.Server, error) {
-	root := &RootNode{repo: repo}
+	if err := inodes.load(filepath.Join(repo.MxDir(), "inodes.json")); err != nil {
+		fmt.Printf("memex-fs: %v\n", err)
+	}
+	opts := mountOptions(debug)
+	// A shared repo is mounted for the users its config maps to DIDs,
+	// which needs user_allow_other in /etc/fuse.conf unless run as root.
+	opts.AllowOther = len(repo.Config.Users) > 0
+	return fs.Mount(mountpoint, &RootNode{repo: repo}, opts)
+}
 
-	opts := &fs.Options{
+// mountOptions are the options every memex mount uses.
+func mountOptions(debug bool) *fs.Options {
+	return &fs.Options{
 		MountOptions: gofuse.MountOptions{
 			FsName:        "memex",
 			Name:          "memex",
@@ -20,10 +34,15 @@ func MountFS(mountpoint string, repo *dag.Repository, debug bool) (*gofuse.Serve
 			Debug:         debug,
 		},
 	}
+}
 
-	server, err := fs.Mount(mountpoint, root, opts)
-	if err != nil {
-		return nil, err
+// actingRepo returns repo as the user making the FUSE request, so their
+// writes are attributed to the DID the repo's config maps their uid to.
+// Unmapped users, and requests without a caller, get repo itself.
+func actingRepo(ctx context.Context, repo *dag.Repository) *dag.Repository {
+	caller, ok := gofuse.FromContext(ctx)
+	if !ok || len(repo.Config.Users) == 0 {
+		return repo
 	}
-	return server, nil
+	return repo.As(repo.Config.UserDID(caller.Uid))
 }
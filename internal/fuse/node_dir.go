@@ -3,7 +3,9 @@ package fuse
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -14,7 +16,10 @@ import (
 )
 
 // NodeDir represents a single node directory (e.g. nodes/person:alice/).
-// Contains: content, meta.json, type, links/
+// Contains: content, meta.json, type, author, context, provenance, links/, backlinks/,
+// neighbors/, blocks/, attachments/, .bundle, .last-error, .lock while the
+// node is locked for editing, and content.md and cells/ when the content is
+// a Jupyter notebook.
 type NodeDir struct {
 	fs.Inode
 	repo      *dag.Repository
@@ -25,6 +30,8 @@ type NodeDir struct {
 var _ = (fs.NodeLookuper)((*NodeDir)(nil))
 var _ = (fs.NodeReaddirer)((*NodeDir)(nil))
 var _ = (fs.NodeGetattrer)((*NodeDir)(nil))
+var _ = (fs.NodeCreater)((*NodeDir)(nil))
+var _ = (fs.NodeUnlinker)((*NodeDir)(nil))
 
 func (d *NodeDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0755
@@ -37,10 +44,24 @@ func (d *NodeDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 		{Name: "content", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/content")},
 		{Name: "meta.json", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/meta.json")},
 		{Name: "type", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/type")},
+		{Name: "author", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/author")},
+		{Name: "context", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/context")},
+		{Name: "provenance", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/provenance")},
 		{Name: "links", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/links")},
 		{Name: "backlinks", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/backlinks")},
 		{Name: "neighbors", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/neighbors")},
 		{Name: "blocks", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/blocks")},
+		{Name: "attachments", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/attachments")},
+		{Name: bundleFileName, Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/" + bundleFileName)},
+		{Name: lastErrorFileName, Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/" + lastErrorFileName)},
+	}
+	if _, ok := d.repo.EditLocks.Get(d.nodeID); ok {
+		entries = append(entries, fuse.DirEntry{Name: lockFileName, Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/" + lockFileName)})
+	}
+	if notebook(d.repo, d.nodeID) != nil {
+		entries = append(entries,
+			fuse.DirEntry{Name: "content.md", Mode: syscall.S_IFREG, Ino: stableIno("nodes/" + d.nodeID + "/content.md")},
+			fuse.DirEntry{Name: "cells", Mode: syscall.S_IFDIR, Ino: stableIno("nodes/" + d.nodeID + "/cells")})
 	}
 	return fs.NewListDirStream(entries), fs.OK
 }
@@ -71,6 +92,15 @@ func (d *NodeDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (
 		})
 		return child, fs.OK
 
+	case "author":
+		return d.newAuthorFile(ctx), fs.OK
+
+	case "context":
+		return d.newContextFile(ctx), fs.OK
+
+	case "provenance":
+		return d.newProvenanceFile(ctx), fs.OK
+
 	case "links":
 		f := &LinksDir{repo: d.repo, nodeID: d.nodeID, accessLog: d.accessLog}
 		child := d.NewInode(ctx, f, fs.StableAttr{
@@ -103,6 +133,43 @@ func (d *NodeDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (
 		})
 		return child, fs.OK
 
+	case "attachments":
+		f := &AttachmentsDir{repo: d.repo, nodeID: d.nodeID}
+		child := d.NewInode(ctx, f, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("nodes/" + d.nodeID + "/attachments"),
+		})
+		return child, fs.OK
+
+	case bundleFileName:
+		return d.newBundleFile(ctx), fs.OK
+
+	case lastErrorFileName:
+		return d.newLastErrorFile(ctx), fs.OK
+
+	case lockFileName:
+		if _, ok := d.repo.EditLocks.Get(d.nodeID); !ok {
+			return nil, syscall.ENOENT
+		}
+		return d.newLockFile(ctx), fs.OK
+
+	case "content.md":
+		if notebook(d.repo, d.nodeID) == nil {
+			return nil, syscall.ENOENT
+		}
+		return d.newNotebookMarkdownFile(ctx), fs.OK
+
+	case "cells":
+		if notebook(d.repo, d.nodeID) == nil {
+			return nil, syscall.ENOENT
+		}
+		f := &NotebookCellsDir{repo: d.repo, nodeID: d.nodeID}
+		child := d.NewInode(ctx, f, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("nodes/" + d.nodeID + "/cells"),
+		})
+		return child, fs.OK
+
 	default:
 		return nil, syscall.ENOENT
 	}
@@ -124,7 +191,7 @@ var _ = (fs.NodeReader)((*ContentFile)(nil))
 func (f *ContentFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	node, err := f.repo.GetNode(f.nodeID)
 	if err != nil {
-		return syscall.ENOENT
+		return readErrno(err)
 	}
 	out.Mode = 0644
 	out.Size = uint64(len(node.Content))
@@ -139,12 +206,7 @@ func (f *ContentFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.Se
 
 func (f *ContentFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
 	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 || flags&syscall.O_TRUNC != 0 {
-		wh := &WriteHandle{
-			repo:   f.repo,
-			nodeID: f.nodeID,
-			field:  "content",
-		}
-		return wh, fuse.FOPEN_DIRECT_IO, fs.OK
+		return openWriteHandle(f.repo, f.nodeID, "content")
 	}
 	return nil, fuse.FOPEN_KEEP_CACHE, fs.OK
 }
@@ -152,7 +214,7 @@ func (f *ContentFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, ui
 func (f *ContentFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
 	node, err := f.repo.GetNode(f.nodeID)
 	if err != nil {
-		return nil, syscall.ENOENT
+		return nil, readErrno(err)
 	}
 	if f.accessLog != nil {
 		f.accessLog.Log(f.nodeID, "content")
@@ -200,7 +262,7 @@ func (f *MetaFile) metaBytes() ([]byte, error) {
 func (f *MetaFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	data, err := f.metaBytes()
 	if err != nil {
-		return syscall.ENOENT
+		return readErrno(err)
 	}
 	out.Mode = 0644
 	out.Size = uint64(len(data))
@@ -214,12 +276,7 @@ func (f *MetaFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAt
 
 func (f *MetaFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
 	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 || flags&syscall.O_TRUNC != 0 {
-		wh := &WriteHandle{
-			repo:   f.repo,
-			nodeID: f.nodeID,
-			field:  "meta",
-		}
-		return wh, fuse.FOPEN_DIRECT_IO, fs.OK
+		return openWriteHandle(f.repo, f.nodeID, "meta")
 	}
 	return nil, fuse.FOPEN_KEEP_CACHE, fs.OK
 }
@@ -227,7 +284,7 @@ func (f *MetaFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint3
 func (f *MetaFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
 	data, err := f.metaBytes()
 	if err != nil {
-		return nil, syscall.ENOENT
+		return nil, readErrno(err)
 	}
 	if f.accessLog != nil {
 		f.accessLog.Log(f.nodeID, "meta")
@@ -242,7 +299,7 @@ func (f *MetaFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off
 	return fuse.ReadResultData(data[off:end]), fs.OK
 }
 
-// TypeFile exposes a node's type as a read-only file.
+// TypeFile exposes a node's type. Writing a new type retypes the node.
 type TypeFile struct {
 	fs.Inode
 	repo      *dag.Repository
@@ -253,26 +310,34 @@ type TypeFile struct {
 var _ = (fs.NodeGetattrer)((*TypeFile)(nil))
 var _ = (fs.NodeOpener)((*TypeFile)(nil))
 var _ = (fs.NodeReader)((*TypeFile)(nil))
+var _ = (fs.NodeSetattrer)((*TypeFile)(nil))
 
 func (f *TypeFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	node, err := f.repo.GetNode(f.nodeID)
 	if err != nil {
-		return syscall.ENOENT
+		return readErrno(err)
 	}
-	out.Mode = 0444
+	out.Mode = 0644
 	out.Size = uint64(len(node.Type) + 1)
 	out.Ino = stableIno("nodes/" + f.nodeID + "/type")
 	return fs.OK
 }
 
+func (f *TypeFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
 func (f *TypeFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
-	return nil, fuse.FOPEN_KEEP_CACHE, fs.OK
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 || flags&syscall.O_TRUNC != 0 {
+		return openWriteHandle(f.repo, f.nodeID, "type")
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
 }
 
 func (f *TypeFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
 	node, err := f.repo.GetNode(f.nodeID)
 	if err != nil {
-		return nil, syscall.ENOENT
+		return nil, readErrno(err)
 	}
 	if f.accessLog != nil {
 		f.accessLog.Log(f.nodeID, "type")
@@ -288,7 +353,9 @@ func (f *TypeFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off
 	return fuse.ReadResultData(data[off:end]), fs.OK
 }
 
-// LinksDir lists links from a node as symlinks.
+// LinksDir lists a node's outgoing links grouped by type: links/{type}/
+// holds one symlink per target (see LinkTypeDir). The older flat names,
+// links/{type}:{target}, still resolve and can still be created.
 type LinksDir struct {
 	fs.Inode
 	repo      *dag.Repository
@@ -300,10 +367,15 @@ var _ = (fs.NodeLookuper)((*LinksDir)(nil))
 var _ = (fs.NodeReaddirer)((*LinksDir)(nil))
 var _ = (fs.NodeGetattrer)((*LinksDir)(nil))
 var _ = (fs.NodeSymlinker)((*LinksDir)(nil))
+var _ = (fs.NodeMkdirer)((*LinksDir)(nil))
+
+func (d *LinksDir) path() string {
+	return "nodes/" + d.nodeID + "/links"
+}
 
 func (d *LinksDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0755
-	out.Ino = stableIno("nodes/" + d.nodeID + "/links")
+	out.Ino = stableIno(d.path())
 	return fs.OK
 }
 
@@ -311,54 +383,80 @@ func (d *LinksDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	if d.accessLog != nil {
 		d.accessLog.Log(d.nodeID, "links")
 	}
-	links := d.repo.GetLinks(d.nodeID)
+	seen := make(map[string]bool)
 	var entries []fuse.DirEntry
-	for _, l := range links {
-		// Only show outgoing links (source = this node)
-		if l.Source != d.nodeID {
+	for _, l := range d.repo.Links.LinksFrom(d.nodeID) {
+		if seen[l.Type] || !usableName(l.Type) {
 			continue
 		}
-		name := l.Type + ":" + l.Target
+		seen[l.Type] = true
 		entries = append(entries, fuse.DirEntry{
-			Name: name,
-			Mode: syscall.S_IFLNK,
-			Ino:  stableIno("nodes/" + d.nodeID + "/links/" + name),
+			Name: l.Type,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(d.path() + "/" + l.Type),
 		})
 	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
 	return fs.NewListDirStream(entries), fs.OK
 }
 
 func (d *LinksDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	// name format: "linktype:targetid" e.g. "knows:person:bob" or
-	// "cites:paper:abc#b3" for a block-scoped link.
-	idx := strings.Index(name, ":")
-	if idx < 0 {
-		return nil, syscall.ENOENT
-	}
-	linkType := name[:idx]
-	target := name[idx+1:]
-
-	// Verify this link exists
 	links := d.repo.Links.LinksFrom(d.nodeID)
-	found := false
 	for _, l := range links {
-		if l.Type == linkType && l.Target == target {
-			found = true
-			break
+		if l.Type == name {
+			return d.newTypeDir(ctx, name), fs.OK
 		}
 	}
-	if !found {
-		return nil, syscall.ENOENT
+
+	// Flat name "linktype:targetid", e.g. "knows:person:bob" or
+	// "cites:paper:abc#b3". Types and targets may both contain colons, so
+	// every split is tried against the links that exist.
+	for i := range len(name) {
+		if name[i] != ':' {
+			continue
+		}
+		linkType, target := name[:i], name[i+1:]
+		for _, l := range links {
+			if l.Type == linkType && l.Target == target {
+				sym := &LinkSymlink{target: linkTargetPath(target)}
+				child := d.NewInode(ctx, sym, fs.StableAttr{
+					Mode: syscall.S_IFLNK,
+					Ino:  stableIno(d.path() + "/" + name),
+				})
+				return child, fs.OK
+			}
+		}
 	}
 
-	sym := &LinkSymlink{target: linkTargetPath(target)}
-	child := d.NewInode(ctx, sym, fs.StableAttr{
-		Mode: syscall.S_IFLNK,
-		Ino:  stableIno("nodes/" + d.nodeID + "/links/" + name),
+	// A type directory made by Mkdir that has no links yet.
+	if child := d.GetChild(name); child != nil {
+		if _, ok := child.Operations().(*LinkTypeDir); ok {
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// Mkdir makes an empty type directory to create the first link of a new
+// type in. It holds nothing on disk until a link is created, and lasts
+// only as long as the kernel keeps its inode.
+func (d *LinksDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !usableName(name) {
+		return nil, syscall.EINVAL
+	}
+	return d.newTypeDir(ctx, name), fs.OK
+}
+
+func (d *LinksDir) newTypeDir(ctx context.Context, linkType string) *fs.Inode {
+	dir := &LinkTypeDir{repo: d.repo, nodeID: d.nodeID, linkType: linkType}
+	return d.NewInode(ctx, dir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(d.path() + "/" + linkType),
 	})
-	return child, fs.OK
 }
 
+// Symlink creates a link from a flat name, "linktype:targetid", split at
+// the first colon. Link types containing colons need links/{type}/.
 func (d *LinksDir) Symlink(ctx context.Context, pointedTo string, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	// name format: "linktype:targetid", optionally with "#b{n}" suffix
 	idx := strings.Index(name, ":")
@@ -368,15 +466,8 @@ func (d *LinksDir) Symlink(ctx context.Context, pointedTo string, name string, o
 	linkType := name[:idx]
 	target := name[idx+1:]
 
-	// Verify the target NODE exists. Block-scoped targets (target#b3)
-	// are resolved to the parent node for the existence check; the link
-	// itself is stored with the full block-scoped target string.
-	if _, err := d.repo.GetNode(dag.LinkTargetParent(target)); err != nil {
-		return nil, syscall.ENOENT
-	}
-
-	if err := d.repo.CreateLink(d.nodeID, target, linkType); err != nil {
-		return nil, syscall.EIO
+	if errno := createLink(ctx, d.repo, d.nodeID, target, linkType); errno != fs.OK {
+		return nil, errno
 	}
 
 	// Ignore pointedTo: use the canonical form so ln -s through FUSE
@@ -385,11 +476,106 @@ func (d *LinksDir) Symlink(ctx context.Context, pointedTo string, name string, o
 	sym := &LinkSymlink{target: linkTargetPath(target)}
 	child := d.NewInode(ctx, sym, fs.StableAttr{
 		Mode: syscall.S_IFLNK,
-		Ino:  stableIno("nodes/" + d.nodeID + "/links/" + name),
+		Ino:  stableIno(d.path() + "/" + name),
 	})
 	return child, fs.OK
 }
 
+// createLink creates source -[linkType]-> target after checking that the
+// target NODE exists. Block-scoped targets (target#b3) are resolved to the
+// parent node for the existence check; the link itself is stored with the
+// full block-scoped target string.
+func createLink(ctx context.Context, repo *dag.Repository, source, target, linkType string) syscall.Errno {
+	op := "link " + linkType + " " + target
+	if _, err := repo.GetNode(dag.LinkTargetParent(target)); err != nil {
+		return lastErrors.fail(source, op, err, readErrno(err))
+	}
+	if err := actingRepo(ctx, repo).CreateLink(source, target, linkType); err != nil {
+		return lastErrors.fail(source, op, err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(source)
+	return fs.OK
+}
+
+// usableName reports whether s can be a directory entry name as is.
+func usableName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.Contains(s, "/")
+}
+
+// LinkTypeDir is /nodes/{id}/links/{type}/: one symlink per link of that
+// type, named after its target. A target that cannot be an entry name is
+// listed under the link's short ID instead; lookups accept either.
+type LinkTypeDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	nodeID   string
+	linkType string
+}
+
+var _ = (fs.NodeLookuper)((*LinkTypeDir)(nil))
+var _ = (fs.NodeReaddirer)((*LinkTypeDir)(nil))
+var _ = (fs.NodeGetattrer)((*LinkTypeDir)(nil))
+var _ = (fs.NodeSymlinker)((*LinkTypeDir)(nil))
+
+func (d *LinkTypeDir) path() string {
+	return "nodes/" + d.nodeID + "/links/" + d.linkType
+}
+
+func (d *LinkTypeDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno(d.path())
+	return fs.OK
+}
+
+// entryName is the name l is listed under.
+func (d *LinkTypeDir) entryName(l dag.LinkEntry) string {
+	if usableName(l.Target) {
+		return l.Target
+	}
+	return l.ID()
+}
+
+func (d *LinkTypeDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, l := range d.repo.Links.LinksFrom(d.nodeID) {
+		if l.Type != d.linkType {
+			continue
+		}
+		name := d.entryName(l)
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno(d.path() + "/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *LinkTypeDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, l := range d.repo.Links.LinksFrom(d.nodeID) {
+		if l.Type == d.linkType && (l.Target == name || l.ID() == name) {
+			return d.newSymlink(ctx, name, l.Target), fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// Symlink creates a link of this directory's type to the node named.
+func (d *LinkTypeDir) Symlink(ctx context.Context, pointedTo string, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := createLink(ctx, d.repo, d.nodeID, name, d.linkType); errno != fs.OK {
+		return nil, errno
+	}
+	return d.newSymlink(ctx, name, name), fs.OK
+}
+
+func (d *LinkTypeDir) newSymlink(ctx context.Context, name, target string) *fs.Inode {
+	sym := &LinkSymlink{target: "../" + linkTargetPath(target)}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno(d.path() + "/" + name),
+	})
+}
+
 // linkTargetPath formats a link target (possibly #b{n}-suffixed) as a
 // symlink body relative to /nodes/{id}/links/. Plain node targets point
 // to ../../{id}; block targets point to ../../{parent}/blocks/b{4-padded}.
@@ -404,8 +590,10 @@ func linkTargetPath(target string) string {
 	return "../../" + target
 }
 
-// BacklinksDir lists links pointing AT this node (incoming) as symlinks.
-// Name format: "{linkType}:{sourceID}" — points back to the source node.
+// BacklinksDir lists the links pointing at this node grouped by type, as
+// LinksDir does outgoing ones: backlinks/{type}/ holds one symlink per
+// source (see BacklinkTypeDir). The older flat names,
+// backlinks/{type}:{source}, still resolve.
 type BacklinksDir struct {
 	fs.Inode
 	repo      *dag.Repository
@@ -417,9 +605,13 @@ var _ = (fs.NodeLookuper)((*BacklinksDir)(nil))
 var _ = (fs.NodeReaddirer)((*BacklinksDir)(nil))
 var _ = (fs.NodeGetattrer)((*BacklinksDir)(nil))
 
+func (d *BacklinksDir) path() string {
+	return "nodes/" + d.nodeID + "/backlinks"
+}
+
 func (d *BacklinksDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0555
-	out.Ino = stableIno("nodes/" + d.nodeID + "/backlinks")
+	out.Ino = stableIno(d.path())
 	return fs.OK
 }
 
@@ -427,35 +619,110 @@ func (d *BacklinksDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno
 	if d.accessLog != nil {
 		d.accessLog.Log(d.nodeID, "backlinks")
 	}
-	links := d.repo.Links.LinksTo(d.nodeID)
+	seen := make(map[string]bool)
 	var entries []fuse.DirEntry
-	for _, l := range links {
-		name := l.Type + ":" + l.Source
+	for _, l := range d.repo.Links.LinksTo(d.nodeID) {
+		if seen[l.Type] || !usableName(l.Type) {
+			continue
+		}
+		seen[l.Type] = true
 		entries = append(entries, fuse.DirEntry{
-			Name: name,
-			Mode: syscall.S_IFLNK,
-			Ino:  stableIno("nodes/" + d.nodeID + "/backlinks/" + name),
+			Name: l.Type,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(d.path() + "/" + l.Type),
 		})
 	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
 	return fs.NewListDirStream(entries), fs.OK
 }
 
 func (d *BacklinksDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	idx := strings.Index(name, ":")
-	if idx < 0 {
-		return nil, syscall.ENOENT
+	links := d.repo.Links.LinksTo(d.nodeID)
+	for _, l := range links {
+		if l.Type == name {
+			dir := &BacklinkTypeDir{repo: d.repo, nodeID: d.nodeID, linkType: name}
+			return d.NewInode(ctx, dir, fs.StableAttr{
+				Mode: syscall.S_IFDIR,
+				Ino:  stableIno(d.path() + "/" + name),
+			}), fs.OK
+		}
 	}
-	linkType := name[:idx]
-	source := name[idx+1:]
 
+	// Flat name "linktype:sourceid". Types and sources may both contain
+	// colons, so every split is tried against the links that exist.
+	for i := range len(name) {
+		if name[i] != ':' {
+			continue
+		}
+		linkType, source := name[:i], name[i+1:]
+		for _, l := range links {
+			if l.Type == linkType && l.Source == source {
+				sym := &LinkSymlink{target: "../../" + source}
+				return d.NewInode(ctx, sym, fs.StableAttr{
+					Mode: syscall.S_IFLNK,
+					Ino:  stableIno(d.path() + "/" + name),
+				}), fs.OK
+			}
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// BacklinkTypeDir is /nodes/{id}/backlinks/{type}/: one symlink per link
+// of that type to the node, named after its source. A source that cannot
+// be an entry name, or that names another link already, is listed under
+// the link's short ID instead; lookups accept either.
+type BacklinkTypeDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	nodeID   string
+	linkType string
+}
+
+var _ = (fs.NodeLookuper)((*BacklinkTypeDir)(nil))
+var _ = (fs.NodeReaddirer)((*BacklinkTypeDir)(nil))
+var _ = (fs.NodeGetattrer)((*BacklinkTypeDir)(nil))
+
+func (d *BacklinkTypeDir) path() string {
+	return "nodes/" + d.nodeID + "/backlinks/" + d.linkType
+}
+
+func (d *BacklinkTypeDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.path())
+	return fs.OK
+}
+
+func (d *BacklinkTypeDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	seen := make(map[string]bool)
+	var entries []fuse.DirEntry
 	for _, l := range d.repo.Links.LinksTo(d.nodeID) {
-		if l.Type == linkType && l.Source == source {
-			sym := &LinkSymlink{target: "../../" + source}
-			child := d.NewInode(ctx, sym, fs.StableAttr{
+		if l.Type != d.linkType {
+			continue
+		}
+		// A source linking to the node and to a block of it has two.
+		name := l.Source
+		if !usableName(name) || seen[name] {
+			name = l.ID()
+		}
+		seen[name] = true
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno(d.path() + "/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *BacklinkTypeDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, l := range d.repo.Links.LinksTo(d.nodeID) {
+		if l.Type == d.linkType && (l.Source == name || l.ID() == name) {
+			sym := &LinkSymlink{target: "../../../" + l.Source}
+			return d.NewInode(ctx, sym, fs.StableAttr{
 				Mode: syscall.S_IFLNK,
-				Ino:  stableIno("nodes/" + d.nodeID + "/backlinks/" + name),
-			})
-			return child, fs.OK
+				Ino:  stableIno(d.path() + "/" + name),
+			}), fs.OK
 		}
 	}
 	return nil, syscall.ENOENT
@@ -482,30 +749,90 @@ func (s *LinkSymlink) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.A
 
 // WriteHandle buffers writes and commits on flush/release.
 type WriteHandle struct {
-	repo   *dag.Repository
-	nodeID string
-	field  string // "content" or "meta"
-	buf    []byte
+	repo     *dag.Repository
+	nodeID   string
+	field    string // "content", "meta", "type", or "attachment"
+	filename string // original name, for field == "attachment"
+	buf      []byte
 }
 
 const maxWriteSize = 64 << 20 // 64 MB
 
+// openWriteHandle opens field of a node for writing. A node another holder
+// has locked fails here with EBUSY, before anything is buffered.
+func openWriteHandle(repo *dag.Repository, nodeID, field string) (fs.FileHandle, uint32, syscall.Errno) {
+	if err := repo.EditLocks.Check(nodeID); err != nil {
+		return nil, 0, syscall.EBUSY
+	}
+	return &WriteHandle{repo: repo, nodeID: nodeID, field: field}, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+// writeErrno maps a failed repository write to an errno: EBUSY when the
+// node is locked by another holder, EPERM when a hook, protection or
+// append-only history rejected the write, EACCES when an ACL did, ESTALE
+// for a stale version, EEXIST for any other conflict such as a name
+// already taken, ENOENT for something missing, EINVAL for a bad ID, name or meta value, and
+// fallback otherwise.
+func writeErrno(err error, fallback syscall.Errno) syscall.Errno {
+	var locked *dag.LockedError
+	if errors.As(err, &locked) {
+		return syscall.EBUSY
+	}
+	var rejected *dag.HookError
+	var stale *dag.ConflictError
+	switch {
+	case errors.As(err, &rejected), errors.Is(err, dag.ErrProtected), errors.Is(err, dag.ErrAppendOnly):
+		return syscall.EPERM
+	case errors.Is(err, dag.ErrAccessDenied):
+		return syscall.EACCES
+	case errors.As(err, &stale):
+		return syscall.ESTALE
+	case errors.Is(err, dag.ErrConflict):
+		return syscall.EEXIST
+	case errors.Is(err, dag.ErrNotFound):
+		return syscall.ENOENT
+	case errors.Is(err, dag.ErrValidation):
+		return syscall.EINVAL
+	}
+	return fallback
+}
+
+// readErrno maps a failed repository read to an errno: ENOENT when what
+// was read does not exist or was deleted, EIO when it could not be read.
+func readErrno(err error) syscall.Errno {
+	if errors.Is(err, dag.ErrNotFound) {
+		return syscall.ENOENT
+	}
+	return syscall.EIO
+}
+
 var _ = (fs.FileWriter)((*WriteHandle)(nil))
 var _ = (fs.FileFlusher)((*WriteHandle)(nil))
 
 func (h *WriteHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+// bufferWrite copies data into buf at off, growing buf as needed. Shared by
+// every handle that buffers a whole file before committing it on flush.
+func bufferWrite(buf, data []byte, off int64) ([]byte, syscall.Errno) {
 	end := int(off) + len(data)
 	if end > maxWriteSize {
-		return 0, syscall.EFBIG
+		return buf, syscall.EFBIG
 	}
 	// Extend buffer if needed
-	if end > len(h.buf) {
+	if end > len(buf) {
 		newBuf := make([]byte, end)
-		copy(newBuf, h.buf)
-		h.buf = newBuf
+		copy(newBuf, buf)
+		buf = newBuf
 	}
-	copy(h.buf[off:], data)
-	return uint32(len(data)), fs.OK
+	copy(buf[off:], data)
+	return buf, fs.OK
 }
 
 func (h *WriteHandle) Flush(ctx context.Context) syscall.Errno {
@@ -515,22 +842,38 @@ func (h *WriteHandle) Flush(ctx context.Context) syscall.Errno {
 
 	switch h.field {
 	case "content":
-		_, err := h.repo.UpdateContent(h.nodeID, h.buf)
+		_, err := actingRepo(ctx, h.repo).UpdateContent(h.nodeID, h.buf)
 		if err != nil {
 			fmt.Printf("memex-fs: write content %s: %v\n", h.nodeID, err)
-			return syscall.EIO
+			return lastErrors.fail(h.nodeID, "write content", err, writeErrno(err, syscall.EIO))
 		}
 	case "meta":
 		var meta map[string]interface{}
 		if err := json.Unmarshal(h.buf, &meta); err != nil {
 			fmt.Printf("memex-fs: invalid meta JSON for %s: %v\n", h.nodeID, err)
-			return syscall.EINVAL
+			return lastErrors.fail(h.nodeID, "write meta.json", fmt.Errorf("invalid JSON: %w", err), syscall.EINVAL)
+		}
+		if node, err := h.repo.GetNode(h.nodeID); err == nil {
+			if err := dag.ValidateMeta(node, meta); err != nil {
+				return lastErrors.fail(h.nodeID, "write meta.json", err, writeErrno(err, syscall.EINVAL))
+			}
 		}
-		_, err := h.repo.UpdateNode(h.nodeID, meta)
+		_, err := actingRepo(ctx, h.repo).UpdateNode(h.nodeID, meta)
 		if err != nil {
 			fmt.Printf("memex-fs: write meta %s: %v\n", h.nodeID, err)
-			return syscall.EIO
+			return lastErrors.fail(h.nodeID, "write meta.json", err, writeErrno(err, syscall.EIO))
+		}
+	case "type":
+		if _, err := actingRepo(ctx, h.repo).Retype(h.nodeID, string(h.buf)); err != nil {
+			fmt.Printf("memex-fs: retype %s: %v\n", h.nodeID, err)
+			return lastErrors.fail(h.nodeID, "write type", err, writeErrno(err, syscall.EINVAL))
+		}
+	case "attachment":
+		if _, err := actingRepo(ctx, h.repo).AddAttachment(h.nodeID, h.filename, h.buf); err != nil {
+			fmt.Printf("memex-fs: attach %s to %s: %v\n", h.filename, h.nodeID, err)
+			return lastErrors.fail(h.nodeID, "attach "+h.filename, err, writeErrno(err, syscall.EIO))
 		}
 	}
+	lastErrors.ok(h.nodeID)
 	return fs.OK
 }
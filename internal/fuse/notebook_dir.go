@@ -0,0 +1,100 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// notebook parses the node's content as a Jupyter notebook, or returns
+// nil when it is not one.
+func notebook(repo *dag.Repository, id string) *dag.Notebook {
+	node, err := repo.GetNode(id)
+	if err != nil {
+		return nil
+	}
+	nb, err := dag.ParseNotebook(node.Content)
+	if err != nil {
+		return nil
+	}
+	return nb
+}
+
+// newNotebookMarkdownFile is /nodes/{id}/content.md — a notebook node's
+// cells rendered as one Markdown document, outputs included.
+func (d *NodeDir) newNotebookMarkdownFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/content.md", func(context.Context) []byte {
+		if nb := notebook(d.repo, d.nodeID); nb != nil {
+			return nb.Markdown()
+		}
+		return nil
+	})
+}
+
+// NotebookCellsDir is /nodes/{id}/cells/ — a notebook node's cells, one
+// file per cell named by position and kind ("001.md", "002.py", ...),
+// plus "{n}.out" with the outputs of each code cell that has some.
+// Read-only; re-parsed on each access, so edits to content show at once.
+type NotebookCellsDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeLookuper)((*NotebookCellsDir)(nil))
+var _ = (fs.NodeReaddirer)((*NotebookCellsDir)(nil))
+var _ = (fs.NodeGetattrer)((*NotebookCellsDir)(nil))
+
+func (d *NotebookCellsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("nodes/" + d.nodeID + "/cells")
+	return fs.OK
+}
+
+// cellFiles maps each file name in the directory to its contents.
+func (d *NotebookCellsDir) cellFiles() (names []string, files map[string][]byte) {
+	nb := notebook(d.repo, d.nodeID)
+	if nb == nil {
+		return nil, nil
+	}
+	files = make(map[string][]byte)
+	for i, c := range nb.Cells {
+		base := fmt.Sprintf("%03d", i+1)
+		name := base + "." + nb.CellExt(c)
+		names = append(names, name)
+		files[name] = []byte(strings.TrimRight(c.Source, "\n") + "\n")
+		if len(c.Outputs) > 0 {
+			names = append(names, base+".out")
+			files[base+".out"] = []byte(strings.Join(c.Outputs, "\n") + "\n")
+		}
+	}
+	return names, files
+}
+
+func (d *NotebookCellsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	names, _ := d.cellFiles()
+	entries := make([]fuse.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFREG,
+			Ino:  stableIno("nodes/" + d.nodeID + "/cells/" + name),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *NotebookCellsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if _, files := d.cellFiles(); files[name] == nil {
+		return nil, syscall.ENOENT
+	}
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/cells/"+name, func(context.Context) []byte {
+		_, files := d.cellFiles()
+		return files[name]
+	}), fs.OK
+}
@@ -0,0 +1,95 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// PinnedDir is /pinned/ — favorite nodes as symlinks to ../nodes/{id}.
+// `ln -s ../nodes/{id} /pinned/` pins a node; `rm /pinned/{id}` unpins it.
+// Pins that point at since-deleted nodes are hidden, not dropped, so
+// restoring the node restores its pin.
+type PinnedDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*PinnedDir)(nil))
+var _ = (fs.NodeReaddirer)((*PinnedDir)(nil))
+var _ = (fs.NodeGetattrer)((*PinnedDir)(nil))
+var _ = (fs.NodeSymlinker)((*PinnedDir)(nil))
+var _ = (fs.NodeUnlinker)((*PinnedDir)(nil))
+
+func (d *PinnedDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("pinned")
+	return fs.OK
+}
+
+func (d *PinnedDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, id := range d.repo.Pins.List() {
+		if _, err := d.repo.GetNode(id); err != nil {
+			continue
+		}
+		entries = append(entries, fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("pinned/" + id),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *PinnedDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !d.repo.Pins.Has(name) {
+		return nil, syscall.ENOENT
+	}
+	if _, err := d.repo.GetNode(name); err != nil {
+		return nil, syscall.ENOENT
+	}
+	return d.newSymlink(ctx, name), fs.OK
+}
+
+func (d *PinnedDir) newSymlink(ctx context.Context, id string) *fs.Inode {
+	sym := &LinkSymlink{target: "../nodes/" + id}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("pinned/" + id),
+	})
+}
+
+func (d *PinnedDir) Symlink(ctx context.Context, pointedTo string, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	// The entry name is the node ID; `ln -s ../nodes/{id}` without an
+	// explicit name already yields it. The target must name the same node
+	// so a typo in either doesn't silently pin the wrong thing.
+	if path.Base(pointedTo) != name {
+		return nil, syscall.EINVAL
+	}
+	if err := actingRepo(ctx, d.repo).Pin(name); err != nil {
+		if _, getErr := d.repo.GetNode(name); getErr != nil {
+			return nil, syscall.ENOENT
+		}
+		fmt.Printf("memex-fs: pin %s: %v\n", name, err)
+		return nil, syscall.EIO
+	}
+	return d.newSymlink(ctx, name), fs.OK
+}
+
+func (d *PinnedDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	removed, err := d.repo.Pins.Remove(name)
+	if err != nil {
+		fmt.Printf("memex-fs: unpin %s: %v\n", name, err)
+		return syscall.EIO
+	}
+	if !removed {
+		return syscall.ENOENT
+	}
+	return fs.OK
+}
@@ -0,0 +1,58 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// newProvenanceFile is /nodes/{id}/provenance — where the node came from
+// and every commit that created, changed or deleted it.
+func (d *NodeDir) newProvenanceFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/provenance", func(context.Context) []byte {
+		p, err := d.repo.Provenance(d.nodeID)
+		if err != nil {
+			return []byte(fmt.Sprintf("provenance unavailable: %v\n", err))
+		}
+		return renderProvenance(p)
+	})
+}
+
+// newAuthorFile is /nodes/{id}/author — the DID that wrote the current
+// version, or nothing if it was written without an identity.
+func (d *NodeDir) newAuthorFile(ctx context.Context) *fs.Inode {
+	return newGeneratedFile(ctx, &d.Inode, "nodes/"+d.nodeID+"/author", func(context.Context) []byte {
+		node, err := d.repo.GetNode(d.nodeID)
+		if err != nil || node.Author == "" {
+			return nil
+		}
+		return []byte(node.Author + "\n")
+	})
+}
+
+func renderProvenance(p *dag.Provenance) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Provenance for %s\n\n", p.ID)
+	fmt.Fprintf(&b, "Origin: %s\n", p.Origin)
+	fmt.Fprintf(&b, "Created: %s\n", p.Created.In(time.Local).Format("2006-01-02 15:04"))
+
+	b.WriteString("\n## History\n\n")
+	if len(p.History) == 0 {
+		b.WriteString("(not committed yet)\n")
+	}
+	for _, ev := range p.History {
+		fmt.Fprintf(&b, "- %s %s: %s", ev.Timestamp.In(time.Local).Format("2006-01-02 15:04"), ev.Action, ev.Message)
+		if ev.Author != "" {
+			fmt.Fprintf(&b, " by %s", ev.Author)
+		}
+		fmt.Fprintf(&b, " (commit %s)\n", ev.Commit)
+	}
+	if p.Truncated {
+		b.WriteString("\nOlder history is unavailable.\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,122 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// recentLimit caps how many nodes each /recent/ view lists.
+const recentLimit = 100
+
+// RecentRootDir is /recent/ — contains modified/ and accessed/.
+type RecentRootDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*RecentRootDir)(nil))
+var _ = (fs.NodeReaddirer)((*RecentRootDir)(nil))
+var _ = (fs.NodeGetattrer)((*RecentRootDir)(nil))
+
+func (d *RecentRootDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("recent")
+	return fs.OK
+}
+
+func (d *RecentRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{
+		{Name: "modified", Mode: syscall.S_IFDIR, Ino: stableIno("recent/modified")},
+		{Name: "accessed", Mode: syscall.S_IFDIR, Ino: stableIno("recent/accessed")},
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *RecentRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name != "modified" && name != "accessed" {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &RecentListDir{repo: d.repo, kind: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("recent/" + name),
+	})
+	return child, fs.OK
+}
+
+// RecentListDir is /recent/modified/ or /recent/accessed/. Entries are
+// symlinks named "{rank}_{id}" (e.g. 001_person:alice) so a plain `ls`
+// shows them newest first.
+type RecentListDir struct {
+	fs.Inode
+	repo *dag.Repository
+	kind string // "modified" or "accessed"
+}
+
+var _ = (fs.NodeLookuper)((*RecentListDir)(nil))
+var _ = (fs.NodeReaddirer)((*RecentListDir)(nil))
+var _ = (fs.NodeGetattrer)((*RecentListDir)(nil))
+
+func (d *RecentListDir) ids() []string {
+	if d.kind == "accessed" {
+		return d.repo.Recency.RecentlyAccessed(recentLimit)
+	}
+	return d.repo.Recency.RecentlyModified(recentLimit)
+}
+
+// recentName formats a 0-based rank and ID as a sortable entry name.
+func recentName(rank int, id string) string {
+	return fmt.Sprintf("%03d_%s", rank+1, id)
+}
+
+func (d *RecentListDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("recent/" + d.kind)
+	return fs.OK
+}
+
+func (d *RecentListDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.ids()
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		name := recentName(i, id)
+		entries[i] = fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("recent/" + d.kind + "/" + name),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *RecentListDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	idx := strings.Index(name, "_")
+	if idx < 0 {
+		return nil, syscall.ENOENT
+	}
+	rank, err := strconv.Atoi(name[:idx])
+	if err != nil || rank < 1 {
+		return nil, syscall.ENOENT
+	}
+	id := name[idx+1:]
+
+	// Ranks shift as nodes are touched; accept the name only if it still
+	// matches the current ordering.
+	ids := d.ids()
+	if rank > len(ids) || ids[rank-1] != id {
+		return nil, syscall.ENOENT
+	}
+
+	sym := &LinkSymlink{target: "../../nodes/" + id}
+	child := d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("recent/" + d.kind + "/" + name),
+	})
+	return child, fs.OK
+}
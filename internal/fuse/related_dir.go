@@ -27,7 +27,7 @@ func (d *RelatedRootDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fus
 
 func (d *RelatedRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	// List all node IDs so users can tab-complete
-	ids, err := d.repo.ListNodes(0)
+	ids, err := d.repo.ListNodes(ctx, 0)
 	if err != nil {
 		return fs.NewListDirStream(nil), fs.OK
 	}
@@ -43,6 +43,9 @@ func (d *RelatedRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Err
 }
 
 func (d *RelatedRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo); errno != fs.OK {
+		return nil, errno
+	}
 	// Verify node exists
 	if _, err := d.repo.GetNode(name); err != nil {
 		return nil, syscall.ENOENT
@@ -0,0 +1,162 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// ReportsDir is /reports/ — one directory per Report node.
+type ReportsDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ReportsDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReportsDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReportsDir)(nil))
+
+func (d *ReportsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("reports")
+	return fs.OK
+}
+
+func (d *ReportsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	ids := d.repo.Search.FilterByType(dag.ReportType, 0)
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{Name: id, Mode: syscall.S_IFDIR, Ino: stableIno("reports/" + id)}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ReportsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	node, err := d.repo.GetNode(name)
+	if err != nil || node.Type != dag.ReportType {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ReportDir{repo: d.repo, reportID: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("reports/" + name),
+	})
+	return child, fs.OK
+}
+
+// ReportDir is /reports/{id}/: output, the report rendered on each read,
+// and snapshots/, symlinks to the dated outputs the scheduler has made.
+type ReportDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	reportID string
+}
+
+var _ = (fs.NodeLookuper)((*ReportDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReportDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReportDir)(nil))
+
+func (d *ReportDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("reports/" + d.reportID)
+	return fs.OK
+}
+
+func (d *ReportDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	base := "reports/" + d.reportID + "/"
+	return fs.NewListDirStream([]fuse.DirEntry{
+		{Name: "output", Mode: syscall.S_IFREG, Ino: stableIno(base + "output")},
+		{Name: "snapshots", Mode: syscall.S_IFDIR, Ino: stableIno(base + "snapshots")},
+	}), fs.OK
+}
+
+func (d *ReportDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	base := "reports/" + d.reportID + "/"
+	switch name {
+	case "output":
+		return newGeneratedFile(ctx, &d.Inode, base+"output", d.render), fs.OK
+	case "snapshots":
+		child := d.NewInode(ctx, &ReportSnapshotsDir{repo: d.repo, reportID: d.reportID}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(base + "snapshots"),
+		})
+		return child, fs.OK
+	}
+	return nil, syscall.ENOENT
+}
+
+func (d *ReportDir) render(ctx context.Context) []byte {
+	out, err := d.repo.RenderReport(ctx, d.reportID, time.Now())
+	if err != nil {
+		return []byte(fmt.Sprintf("report failed: %v\n", err))
+	}
+	return out
+}
+
+// ReportSnapshotsDir is /reports/{id}/snapshots/: a symlink to each live
+// ReportOutput node RENDERED_FROM the report, oldest first by name.
+type ReportSnapshotsDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	reportID string
+}
+
+var _ = (fs.NodeLookuper)((*ReportSnapshotsDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReportSnapshotsDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReportSnapshotsDir)(nil))
+
+func (d *ReportSnapshotsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("reports/" + d.reportID + "/snapshots")
+	return fs.OK
+}
+
+// outputs returns the IDs of the report's live outputs, sorted.
+func (d *ReportSnapshotsDir) outputs() []string {
+	var ids []string
+	for _, l := range d.repo.Links.LinksTo(d.reportID) {
+		if l.Type != dag.LinkRenderedFrom || !usableName(l.Source) {
+			continue
+		}
+		if _, err := d.repo.GetNode(l.Source); err == nil {
+			ids = append(ids, l.Source)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (d *ReportSnapshotsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.outputs()
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("reports/" + d.reportID + "/snapshots/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ReportSnapshotsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, id := range d.outputs() {
+		if id != name {
+			continue
+		}
+		child := d.NewInode(ctx, &LinkSymlink{target: "../../../nodes/" + id}, fs.StableAttr{
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("reports/" + d.reportID + "/snapshots/" + id),
+		})
+		return child, fs.OK
+	}
+	return nil, syscall.ENOENT
+}
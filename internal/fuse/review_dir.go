@@ -0,0 +1,237 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// ReviewDir is /review/ — the spaced-repetition loop over Flashcard nodes.
+// Its only child is due/.
+type ReviewDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ReviewDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReviewDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReviewDir)(nil))
+
+func (d *ReviewDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("review")
+	return fs.OK
+}
+
+func (d *ReviewDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{
+		{Name: "due", Mode: syscall.S_IFDIR, Ino: stableIno("review/due")},
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ReviewDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name != "due" {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ReviewDueDir{repo: d.repo}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("review/due"),
+	})
+	return child, fs.OK
+}
+
+// ReviewDueDir is /review/due/ — one directory per flashcard due today.
+// Grading a card reschedules it, so it drops out of the listing until its
+// next due day.
+type ReviewDueDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ReviewDueDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReviewDueDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReviewDueDir)(nil))
+
+func (d *ReviewDueDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("review/due")
+	return fs.OK
+}
+
+func (d *ReviewDueDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, id := range d.repo.DueCards(time.Now()) {
+		entries = append(entries, fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("review/due/" + id),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ReviewDueDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	due := false
+	for _, id := range d.repo.DueCards(time.Now()) {
+		if id == name {
+			due = true
+			break
+		}
+	}
+	if !due {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ReviewCardDir{repo: d.repo, nodeID: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("review/due/" + name),
+	})
+	return child, fs.OK
+}
+
+// ReviewCardDir is /review/due/{id}/:
+//
+//	content  the card's content, read-only
+//	grade    write 0-5 to record a review
+//	node     symlink to ../../../nodes/{id}
+type ReviewCardDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeLookuper)((*ReviewCardDir)(nil))
+var _ = (fs.NodeReaddirer)((*ReviewCardDir)(nil))
+var _ = (fs.NodeGetattrer)((*ReviewCardDir)(nil))
+
+func (d *ReviewCardDir) path() string {
+	return "review/due/" + d.nodeID
+}
+
+func (d *ReviewCardDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.path())
+	return fs.OK
+}
+
+func (d *ReviewCardDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{
+		{Name: "content", Mode: syscall.S_IFREG, Ino: stableIno(d.path() + "/content")},
+		{Name: "grade", Mode: syscall.S_IFREG, Ino: stableIno(d.path() + "/grade")},
+		{Name: "node", Mode: syscall.S_IFLNK, Ino: stableIno(d.path() + "/node")},
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ReviewCardDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	switch name {
+	case "content":
+		gen := func(ctx context.Context) []byte {
+			node, err := d.repo.GetNode(d.nodeID)
+			if err != nil {
+				return nil
+			}
+			return node.Content
+		}
+		return newGeneratedFile(ctx, &d.Inode, d.path()+"/content", gen), fs.OK
+
+	case "grade":
+		child := d.NewInode(ctx, &GradeFile{repo: d.repo, nodeID: d.nodeID}, fs.StableAttr{
+			Mode: syscall.S_IFREG,
+			Ino:  stableIno(d.path() + "/grade"),
+		})
+		return child, fs.OK
+
+	case "node":
+		sym := &LinkSymlink{target: "../../../nodes/" + d.nodeID}
+		child := d.NewInode(ctx, sym, fs.StableAttr{
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno(d.path() + "/node"),
+		})
+		return child, fs.OK
+	}
+	return nil, syscall.ENOENT
+}
+
+// GradeFile is /review/due/{id}/grade. It reads as empty; the grade
+// written to it is applied when the file is closed.
+type GradeFile struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeGetattrer)((*GradeFile)(nil))
+var _ = (fs.NodeSetattrer)((*GradeFile)(nil))
+var _ = (fs.NodeOpener)((*GradeFile)(nil))
+var _ = (fs.NodeReader)((*GradeFile)(nil))
+
+func (f *GradeFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0644
+	out.Ino = stableIno("review/due/" + f.nodeID + "/grade")
+	return fs.OK
+}
+
+func (f *GradeFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
+func (f *GradeFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_WRONLY != 0 || flags&syscall.O_RDWR != 0 || flags&syscall.O_TRUNC != 0 {
+		if err := f.repo.EditLocks.Check(f.nodeID); err != nil {
+			return nil, 0, syscall.EBUSY
+		}
+		return &gradeHandle{repo: f.repo, nodeID: f.nodeID}, fuse.FOPEN_DIRECT_IO, fs.OK
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, fs.OK
+}
+
+func (f *GradeFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	return fuse.ReadResultData(nil), fs.OK
+}
+
+// gradeHandle buffers the written grade and reviews the card on flush.
+type gradeHandle struct {
+	repo   *dag.Repository
+	nodeID string
+	buf    []byte
+	done   bool
+}
+
+var _ = (fs.FileWriter)((*gradeHandle)(nil))
+var _ = (fs.FileFlusher)((*gradeHandle)(nil))
+
+func (h *gradeHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	buf, errno := bufferWrite(h.buf, data, off)
+	if errno != fs.OK {
+		return 0, errno
+	}
+	h.buf = buf
+	return uint32(len(data)), fs.OK
+}
+
+func (h *gradeHandle) Flush(ctx context.Context) syscall.Errno {
+	// Flush runs once per close of a dup'd descriptor; grade only once.
+	body := strings.TrimSpace(string(h.buf))
+	if body == "" || h.done {
+		return fs.OK
+	}
+	grade, err := strconv.Atoi(body)
+	if err != nil || grade < 0 || grade > dag.MaxGrade {
+		return syscall.EINVAL
+	}
+	if _, err := actingRepo(ctx, h.repo).ReviewCard(h.nodeID, grade, time.Now()); err != nil {
+		fmt.Printf("memex-fs: review %s: %v\n", h.nodeID, err)
+		return lastErrors.fail(h.nodeID, "review", err, writeErrno(err, syscall.EIO))
+	}
+	h.done = true
+	lastErrors.ok(h.nodeID)
+	return fs.OK
+}
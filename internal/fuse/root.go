@@ -3,7 +3,6 @@ package fuse
 import (
 	"context"
 	"path/filepath"
-	"strings"
 	"syscall"
 	"time"
 
@@ -60,6 +59,27 @@ func (r *RootNode) OnAdd(ctx context.Context) {
 	})
 	r.AddChild("related", relatedInode, true)
 
+	suggestionsDir := &SuggestionsRootDir{repo: r.repo}
+	suggestionsInode := r.NewPersistentInode(ctx, suggestionsDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("suggestions"),
+	})
+	r.AddChild("suggestions", suggestionsInode, true)
+
+	duplicatesDir := &DuplicatesDir{repo: r.repo}
+	duplicatesInode := r.NewPersistentInode(ctx, duplicatesDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("duplicates"),
+	})
+	r.AddChild("duplicates", duplicatesInode, true)
+
+	inboxDir := &InboxDir{repo: r.repo}
+	inboxInode := r.NewPersistentInode(ctx, inboxDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("inbox"),
+	})
+	r.AddChild("inbox", inboxInode, true)
+
 	atDir := &AtRootDir{repo: r.repo}
 	atInode := r.NewPersistentInode(ctx, atDir, fs.StableAttr{
 		Mode: syscall.S_IFDIR,
@@ -81,9 +101,102 @@ func (r *RootNode) OnAdd(ctx context.Context) {
 	})
 	r.AddChild("lenses", lensesInode, true)
 
-	// Wire co-access callback: access log → co-access index
+	journalDir := &JournalDir{repo: r.repo}
+	journalInode := r.NewPersistentInode(ctx, journalDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("journal"),
+	})
+	r.AddChild("journal", journalInode, true)
+
+	calendarDir := &CalendarDir{repo: r.repo}
+	calendarInode := r.NewPersistentInode(ctx, calendarDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("calendar"),
+	})
+	r.AddChild("calendar", calendarInode, true)
+
+	recentDir := &RecentRootDir{repo: r.repo}
+	recentInode := r.NewPersistentInode(ctx, recentDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("recent"),
+	})
+	r.AddChild("recent", recentInode, true)
+
+	pinnedDir := &PinnedDir{repo: r.repo}
+	pinnedInode := r.NewPersistentInode(ctx, pinnedDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("pinned"),
+	})
+	r.AddChild("pinned", pinnedInode, true)
+
+	graphDir := &GraphDir{repo: r.repo}
+	graphInode := r.NewPersistentInode(ctx, graphDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("graph"),
+	})
+	r.AddChild("graph", graphInode, true)
+
+	statsDir := &StatsDir{repo: r.repo}
+	statsInode := r.NewPersistentInode(ctx, statsDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("stats"),
+	})
+	r.AddChild("stats", statsInode, true)
+
+	reviewDir := &ReviewDir{repo: r.repo}
+	reviewInode := r.NewPersistentInode(ctx, reviewDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("review"),
+	})
+	r.AddChild("review", reviewInode, true)
+
+	tasksDir := &TasksDir{repo: r.repo}
+	tasksInode := r.NewPersistentInode(ctx, tasksDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("tasks"),
+	})
+	r.AddChild("tasks", tasksInode, true)
+
+	reportsDir := &ReportsDir{repo: r.repo}
+	reportsInode := r.NewPersistentInode(ctx, reportsDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("reports"),
+	})
+	r.AddChild("reports", reportsInode, true)
+
+	controlDir := &ControlDir{repo: r.repo}
+	controlInode := r.NewPersistentInode(ctx, controlDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("control"),
+	})
+	r.AddChild("control", controlInode, true)
+
+	askDir := &AskDir{repo: r.repo}
+	askInode := r.NewPersistentInode(ctx, askDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("ask"),
+	})
+	r.AddChild("ask", askInode, true)
+
+	conflictsDir := &ConflictsDir{repo: r.repo}
+	conflictsInode := r.NewPersistentInode(ctx, conflictsDir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("conflicts"),
+	})
+	r.AddChild("conflicts", conflictsInode, true)
+
+	memexInode := r.NewPersistentInode(ctx, &MemexDir{repo: r.repo}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(".memex"),
+	})
+	r.AddChild(".memex", memexInode, true)
+
+	r.addSubtrees(ctx)
+
+	// Wire access callback: access log → co-access and recency indexes
 	r.accessLog.OnAccess = func(nodeID string, ts time.Time) {
 		r.repo.CoAccess.Record(nodeID, ts)
+		r.repo.Recency.RecordAccess(nodeID, ts)
 	}
 }
 
@@ -113,15 +226,26 @@ func (n *NodesDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.Attr
 }
 
 func (n *NodesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	ids, err := n.repo.ListNodes(0)
+	ids, err := n.repo.ListNodes(ctx, 0)
+	if ctx.Err() != nil {
+		return nil, syscall.EINTR
+	}
 	if err != nil {
 		return nil, syscall.EIO
 	}
+	aliases := make(map[string]bool)
+	for _, id := range n.repo.Search.FilterByType(dag.AliasType, 0) {
+		aliases[id] = true
+	}
 	entries := make([]fuse.DirEntry, len(ids))
 	for i, id := range ids {
+		mode := uint32(syscall.S_IFDIR)
+		if aliases[id] {
+			mode = syscall.S_IFLNK
+		}
 		entries[i] = fuse.DirEntry{
 			Name: id,
-			Mode: syscall.S_IFDIR,
+			Mode: mode,
 			Ino:  stableIno("nodes/" + id),
 		}
 	}
@@ -129,10 +253,18 @@ func (n *NodesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 }
 
 func (n *NodesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	_, err := n.repo.GetNode(name)
+	node, err := n.repo.GetNode(name)
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
+	if node.Type == dag.AliasType {
+		// A node merged into another is a symlink to the one holding it.
+		sym := &LinkSymlink{target: n.repo.ResolveAlias(name)}
+		return n.NewInode(ctx, sym, fs.StableAttr{
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("nodes/" + name),
+		}), fs.OK
+	}
 	nodeDir := &NodeDir{repo: n.repo, nodeID: name, accessLog: n.accessLog}
 	child := n.NewInode(ctx, nodeDir, fs.StableAttr{
 		Mode: syscall.S_IFDIR,
@@ -142,17 +274,12 @@ func (n *NodesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut)
 }
 
 func (n *NodesDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	// Parse type from id: "person:alice" -> type="Person"
-	typ := "Node"
-	if idx := strings.Index(name, ":"); idx > 0 {
-		t := name[:idx]
-		typ = strings.ToUpper(t[:1]) + t[1:]
-	}
-
-	_, err := n.repo.CreateNode(name, typ, nil, nil)
+	// The type comes from the id: "person:alice" is a Person.
+	_, err := actingRepo(ctx, n.repo).CreateNode(name, dag.TypeFromID(name), nil, nil)
 	if err != nil {
-		return nil, syscall.EEXIST
+		return nil, lastErrors.fail(name, "create node", err, writeErrno(err, syscall.EEXIST))
 	}
+	lastErrors.ok(name)
 
 	nodeDir := &NodeDir{repo: n.repo, nodeID: name, accessLog: n.accessLog}
 	child := n.NewInode(ctx, nodeDir, fs.StableAttr{
@@ -163,9 +290,10 @@ func (n *NodesDir) Mkdir(ctx context.Context, name string, mode uint32, out *fus
 }
 
 func (n *NodesDir) Rmdir(ctx context.Context, name string) syscall.Errno {
-	err := n.repo.DeleteNode(name, false)
+	err := actingRepo(ctx, n.repo).DeleteNode(name, false)
 	if err != nil {
-		return syscall.ENOENT
+		return lastErrors.fail(name, "delete node", err, writeErrno(err, syscall.ENOENT))
 	}
+	lastErrors.ok(name)
 	return fs.OK
 }
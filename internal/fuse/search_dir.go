@@ -9,7 +9,12 @@ import (
 	"github.com/systemshift/memex-fs/internal/dag"
 )
 
-// SearchRootDir is the /search/ directory. Lookup treats the name as a query.
+// searchLimit caps how many results a /search/{query}/ directory lists.
+const searchLimit = 100
+
+// SearchRootDir is the /search/ directory. Lookup treats the name as a
+// query, in which "@name" stands for a saved query (see dag.ExpandQuery):
+// /search/@projectx lists what the query saved as projectx finds.
 type SearchRootDir struct {
 	fs.Inode
 	repo *dag.Repository
@@ -31,12 +36,19 @@ func (d *SearchRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errn
 }
 
 func (d *SearchRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
 	// Any name is treated as a search query
-	results := d.repo.Search.Search(name, 100)
+	query, err := d.repo.ExpandQuery(name)
+	if err != nil {
+		return nil, syscall.ENOENT // an unknown alias finds nothing
+	}
+	results := d.repo.Search.Search(query, 1)
 	if len(results) == 0 {
 		return nil, syscall.ENOENT
 	}
-	dir := &SearchResultsDir{repo: d.repo, query: name}
+	dir := &SearchResultsDir{repo: d.repo, name: name, query: query}
 	child := d.NewInode(ctx, dir, fs.StableAttr{
 		Mode: syscall.S_IFDIR,
 		Ino:  stableIno("search/" + name),
@@ -48,7 +60,8 @@ func (d *SearchRootDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 type SearchResultsDir struct {
 	fs.Inode
 	repo  *dag.Repository
-	query string
+	name  string // as looked up, for inode numbers
+	query string // with aliases expanded
 }
 
 var _ = (fs.NodeLookuper)((*SearchResultsDir)(nil))
@@ -57,26 +70,32 @@ var _ = (fs.NodeGetattrer)((*SearchResultsDir)(nil))
 
 func (d *SearchResultsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Mode = 0755
-	out.Ino = stableIno("search/" + d.query)
+	out.Ino = stableIno("search/" + d.name)
 	return fs.OK
 }
 
 func (d *SearchResultsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	results := d.repo.Search.Search(d.query, 100)
-	entries := make([]fuse.DirEntry, len(results))
-	for i, id := range results {
-		entries[i] = fuse.DirEntry{
-			Name: id,
-			Mode: syscall.S_IFLNK,
-			Ino:  stableIno("search/" + d.query + "/" + id),
+	// Stream so large result sets start listing before every hit is read.
+	// The stream outlives this request, and go-fuse recycles a request's
+	// context once it is answered, so it must not capture ctx; closing the
+	// directory is what stops it.
+	return newSeqDirStream(func(yield func(fuse.DirEntry) bool) {
+		for node := range d.repo.SearchIter(context.Background(), d.query, searchLimit) {
+			entry := fuse.DirEntry{
+				Name: node.ID,
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno("search/" + d.name + "/" + node.ID),
+			}
+			if !yield(entry) {
+				return
+			}
 		}
-	}
-	return fs.NewListDirStream(entries), fs.OK
+	}), fs.OK
 }
 
 func (d *SearchResultsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	// Verify the node exists and matches the query
-	results := d.repo.Search.Search(d.query, 100)
+	results := d.repo.Search.Search(d.query, searchLimit)
 	found := false
 	for _, id := range results {
 		if id == name {
@@ -91,7 +110,7 @@ func (d *SearchResultsDir) Lookup(ctx context.Context, name string, out *fuse.En
 	sym := &SearchSymlink{nodeID: name}
 	child := d.NewInode(ctx, sym, fs.StableAttr{
 		Mode: syscall.S_IFLNK,
-		Ino:  stableIno("search/" + d.query + "/" + name),
+		Ino:  stableIno("search/" + d.name + "/" + name),
 	})
 	return child, fs.OK
 }
@@ -0,0 +1,47 @@
+package fuse
+
+import (
+	"iter"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// seqDirStream adapts an iterator to fs.DirStream, so entries reach the
+// kernel as they are produced instead of after the whole listing is built.
+// Closing the stream stops the iterator.
+type seqDirStream struct {
+	next   func() (fuse.DirEntry, bool)
+	stop   func()
+	peeked bool
+	entry  fuse.DirEntry
+	ok     bool
+}
+
+var _ = (fs.DirStream)((*seqDirStream)(nil))
+
+func newSeqDirStream(seq iter.Seq[fuse.DirEntry]) *seqDirStream {
+	next, stop := iter.Pull(seq)
+	return &seqDirStream{next: next, stop: stop}
+}
+
+func (s *seqDirStream) HasNext() bool {
+	if !s.peeked {
+		s.entry, s.ok = s.next()
+		s.peeked = true
+	}
+	return s.ok
+}
+
+func (s *seqDirStream) Next() (fuse.DirEntry, syscall.Errno) {
+	if !s.HasNext() {
+		return fuse.DirEntry{}, syscall.ENOENT
+	}
+	s.peeked = false
+	return s.entry, fs.OK
+}
+
+func (s *seqDirStream) Close() {
+	s.stop()
+}
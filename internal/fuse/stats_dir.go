@@ -0,0 +1,166 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// topNodesLimit caps /stats/activity/top-nodes.txt.
+const topNodesLimit = 50
+
+// StatsDir is /stats/ — generated reports about the repository itself:
+// activity/ for usage over time; storage, a JSON breakdown of the object
+// store with its growth trend; and memory, what the in-memory indexes
+// hold against their budgets.
+type StatsDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*StatsDir)(nil))
+var _ = (fs.NodeReaddirer)((*StatsDir)(nil))
+var _ = (fs.NodeGetattrer)((*StatsDir)(nil))
+
+func (d *StatsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("stats")
+	return fs.OK
+}
+
+func (d *StatsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{
+		{Name: "activity", Mode: syscall.S_IFDIR, Ino: stableIno("stats/activity")},
+		{Name: "storage", Mode: syscall.S_IFREG, Ino: stableIno("stats/storage")},
+		{Name: "memory", Mode: syscall.S_IFREG, Ino: stableIno("stats/memory")},
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *StatsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "storage" {
+		return newGeneratedFile(ctx, &d.Inode, "stats/storage", d.storage), fs.OK
+	}
+	if name == "memory" {
+		return newGeneratedFile(ctx, &d.Inode, "stats/memory", d.memory), fs.OK
+	}
+	if name != "activity" {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &ActivityDir{repo: d.repo}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("stats/activity"),
+	})
+	return child, fs.OK
+}
+
+func (d *StatsDir) storage(ctx context.Context) []byte {
+	report, err := d.repo.Storage(ctx)
+	if err != nil {
+		return []byte(fmt.Sprintf("storage failed: %v\n", err))
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return []byte(fmt.Sprintf("storage failed: %v\n", err))
+	}
+	return append(data, '\n')
+}
+
+func (d *StatsDir) memory(ctx context.Context) []byte {
+	data, err := json.MarshalIndent(d.repo.Memory(), "", "  ")
+	if err != nil {
+		return []byte(fmt.Sprintf("memory failed: %v\n", err))
+	}
+	return append(data, '\n')
+}
+
+// ActivityDir is /stats/activity/ — how the knowledge base is written and
+// read over time, from the commit log and the access log:
+//
+//	by-day.csv     day,commits,changes,reads per local calendar day
+//	by-type.json   live nodes, changes and reads per node type
+//	top-nodes.txt  the most-read nodes, with their change counts
+type ActivityDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*ActivityDir)(nil))
+var _ = (fs.NodeReaddirer)((*ActivityDir)(nil))
+var _ = (fs.NodeGetattrer)((*ActivityDir)(nil))
+
+var activityFiles = map[string]func(*dag.ActivityReport) []byte{
+	"by-day.csv":    activityByDay,
+	"by-type.json":  activityByType,
+	"top-nodes.txt": activityTopNodes,
+}
+
+func (d *ActivityDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("stats/activity")
+	return fs.OK
+}
+
+func (d *ActivityDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, name := range []string{"by-day.csv", "by-type.json", "top-nodes.txt"} {
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFREG,
+			Ino:  stableIno("stats/activity/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *ActivityDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	render, ok := activityFiles[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	gen := func(ctx context.Context) []byte {
+		report, err := d.repo.Activity(ctx)
+		if err != nil {
+			return []byte(fmt.Sprintf("activity failed: %v\n", err))
+		}
+		return render(report)
+	}
+	return newGeneratedFile(ctx, &d.Inode, "stats/activity/"+name, gen), fs.OK
+}
+
+func activityByDay(report *dag.ActivityReport) []byte {
+	var b strings.Builder
+	b.WriteString("day,commits,changes,reads\n")
+	for _, d := range report.Days {
+		fmt.Fprintf(&b, "%s,%d,%d,%d\n", d.Day, d.Commits, d.Changes, d.Reads)
+	}
+	return []byte(b.String())
+}
+
+func activityByType(report *dag.ActivityReport) []byte {
+	data, err := json.MarshalIndent(report.Types, "", "  ")
+	if err != nil {
+		return []byte(fmt.Sprintf("activity failed: %v\n", err))
+	}
+	return append(data, '\n')
+}
+
+// activityTopNodes renders one line per node:
+//
+//	reads  changes  id
+func activityTopNodes(report *dag.ActivityReport) []byte {
+	var b strings.Builder
+	for i, n := range report.Nodes {
+		if i == topNodesLimit {
+			break
+		}
+		fmt.Fprintf(&b, "%6d %6d  %s\n", n.Reads, n.Changes, n.ID)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,205 @@
+package fuse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// subtreeCommandTimeout bounds one run of a subtree command.
+const subtreeCommandTimeout = 10 * time.Second
+
+// Subtree builds the directory an extension mounts under the root, given
+// the repository being mounted. The returned node serves everything below
+// it, including its own Getattr.
+type Subtree func(repo *dag.Repository) fs.InodeEmbedder
+
+var (
+	subtreesMu sync.RWMutex
+	// subtrees are the compiled-in extensions by directory name.
+	subtrees = map[string]Subtree{}
+)
+
+// RegisterSubtree mounts what build returns at /<name>/ on every later
+// mount, replacing any subtree registered under name. Call it from an
+// init function of a package the binary imports. Commands set in
+// Config.Subtrees take precedence, and neither replaces a built-in
+// directory.
+func RegisterSubtree(name string, build Subtree) {
+	subtreesMu.Lock()
+	defer subtreesMu.Unlock()
+	subtrees[name] = build
+}
+
+// subtreesFor returns repo's extensions by name: the registered ones, and
+// a CommandDir for each configured command.
+func subtreesFor(repo *dag.Repository) map[string]Subtree {
+	subtreesMu.RLock()
+	out := make(map[string]Subtree, len(subtrees)+len(repo.Config.Subtrees))
+	for name, build := range subtrees {
+		out[name] = build
+	}
+	subtreesMu.RUnlock()
+	for name, argv := range repo.Config.Subtrees {
+		if len(argv) == 0 {
+			continue
+		}
+		out[name] = func(repo *dag.Repository) fs.InodeEmbedder {
+			return &CommandDir{repo: repo, argv: argv, name: name}
+		}
+	}
+	return out
+}
+
+// addSubtrees mounts repo's extensions under the root in name order. A
+// name that is not a single path segment, is hidden, or is one a built-in
+// directory already uses is skipped.
+func (r *RootNode) addSubtrees(ctx context.Context) {
+	all := subtreesFor(r.repo)
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !usableName(name) || strings.HasPrefix(name, ".") || r.GetChild(name) != nil {
+			fmt.Printf("memex-fs: subtree %q: name unusable or taken\n", name)
+			continue
+		}
+		child := r.NewPersistentInode(ctx, all[name](r.repo), fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(name),
+		})
+		r.AddChild(name, child, true)
+	}
+}
+
+// CommandDir is a directory of a subtree served by an external command,
+// the way Config.Subtrees configures it. Every listing runs
+//
+//	argv... ls /some/dir
+//
+// which prints one entry per line, a directory's with a trailing "/", and
+// every read of a file runs
+//
+//	argv... cat /some/dir/file
+//
+// which prints the file's content. Paths are relative to the subtree's
+// root, "/". The command gets MEMEX_REPO, the repository root, in its
+// environment, so it can read the repository or call memex-fs itself.
+// Commands are separate processes rather than Go plugins so they can be
+// written in anything and a crash cannot take the mount down.
+type CommandDir struct {
+	fs.Inode
+	repo *dag.Repository
+	argv []string
+	name string // directory under the root
+	rel  string // path below it, "" at its root
+}
+
+var _ = (fs.NodeLookuper)((*CommandDir)(nil))
+var _ = (fs.NodeReaddirer)((*CommandDir)(nil))
+var _ = (fs.NodeGetattrer)((*CommandDir)(nil))
+
+func (d *CommandDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(path.Join(d.name, d.rel))
+	return fs.OK
+}
+
+// run runs the command for op on the subtree path rel.
+func (d *CommandDir) run(ctx context.Context, op, rel string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, subtreeCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, d.argv[0], append(slices.Clone(d.argv[1:]), op, "/"+rel)...)
+	cmd.Env = append(os.Environ(), "MEMEX_REPO="+filepath.Dir(d.repo.MxDir()))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s %s /%s: %s", d.argv[0], op, rel, msg)
+		}
+		return nil, fmt.Errorf("%s %s /%s: %w", d.argv[0], op, rel, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// entries lists the directory, by name, with whether each is a directory.
+func (d *CommandDir) entries(ctx context.Context) (map[string]bool, []string, error) {
+	out, err := d.run(ctx, "ls", d.rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs := make(map[string]bool)
+	var names []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		name, dir := strings.CutSuffix(strings.TrimSpace(sc.Text()), "/")
+		if !usableName(name) {
+			continue
+		}
+		if _, seen := dirs[name]; !seen {
+			names = append(names, name)
+		}
+		dirs[name] = dir
+	}
+	return dirs, names, sc.Err()
+}
+
+func (d *CommandDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	dirs, names, err := d.entries(ctx)
+	if err != nil {
+		fmt.Printf("memex-fs: subtree %s: %v\n", d.name, err)
+		return nil, syscall.EIO
+	}
+	out := make([]fuse.DirEntry, len(names))
+	for i, name := range names {
+		mode := uint32(syscall.S_IFREG)
+		if dirs[name] {
+			mode = syscall.S_IFDIR
+		}
+		out[i] = fuse.DirEntry{Name: name, Mode: mode, Ino: stableIno(path.Join(d.name, d.rel, name))}
+	}
+	return fs.NewListDirStream(out), fs.OK
+}
+
+func (d *CommandDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	dirs, _, err := d.entries(ctx)
+	if err != nil {
+		fmt.Printf("memex-fs: subtree %s: %v\n", d.name, err)
+		return nil, syscall.EIO
+	}
+	dir, ok := dirs[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	rel := path.Join(d.rel, name)
+	if !dir {
+		return newGeneratedFile(ctx, &d.Inode, path.Join(d.name, rel), func(ctx context.Context) []byte {
+			data, err := d.run(ctx, "cat", rel)
+			if err != nil {
+				return []byte(fmt.Sprintf("subtree read failed: %v\n", err))
+			}
+			return data
+		}), fs.OK
+	}
+	child := d.NewInode(ctx, &CommandDir{repo: d.repo, argv: d.argv, name: d.name, rel: rel}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(path.Join(d.name, rel)),
+	})
+	return child, fs.OK
+}
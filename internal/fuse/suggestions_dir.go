@@ -0,0 +1,150 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// suggestionLimit bounds how many suggestions a node's directory lists.
+const suggestionLimit = 20
+
+// SuggestionsRootDir is /suggestions/ — a review queue of proposed links.
+// Lookup by node ID:
+//
+//	{id}/{target}  symlink to ../../nodes/{target}, best first by
+//	               dag.SuggestLinks; mv it to "{type}:{target}" to link
+//	               with that type, or to any other name to link with
+//	               type "related"; rm it to never see it suggested again
+type SuggestionsRootDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*SuggestionsRootDir)(nil))
+var _ = (fs.NodeReaddirer)((*SuggestionsRootDir)(nil))
+var _ = (fs.NodeGetattrer)((*SuggestionsRootDir)(nil))
+
+func (d *SuggestionsRootDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("suggestions")
+	return fs.OK
+}
+
+func (d *SuggestionsRootDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	// List all node IDs so users can tab-complete, as /related/ does.
+	ids, err := d.repo.ListNodes(ctx, 0)
+	if err != nil {
+		return fs.NewListDirStream(nil), fs.OK
+	}
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("suggestions/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *SuggestionsRootDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo); errno != fs.OK {
+		return nil, errno
+	}
+	if _, err := d.repo.GetNode(name); err != nil {
+		return nil, syscall.ENOENT
+	}
+	dir := &SuggestionsDir{repo: d.repo, nodeID: name}
+	return d.NewInode(ctx, dir, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("suggestions/" + name),
+	}), fs.OK
+}
+
+// SuggestionsDir is /suggestions/{id}/.
+type SuggestionsDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	nodeID string
+}
+
+var _ = (fs.NodeLookuper)((*SuggestionsDir)(nil))
+var _ = (fs.NodeReaddirer)((*SuggestionsDir)(nil))
+var _ = (fs.NodeGetattrer)((*SuggestionsDir)(nil))
+var _ = (fs.NodeRenamer)((*SuggestionsDir)(nil))
+var _ = (fs.NodeUnlinker)((*SuggestionsDir)(nil))
+
+func (d *SuggestionsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("suggestions/" + d.nodeID)
+	return fs.OK
+}
+
+func (d *SuggestionsDir) suggested(target string) bool {
+	return slices.ContainsFunc(d.repo.SuggestLinks(d.nodeID, suggestionLimit), func(s dag.LinkSuggestion) bool {
+		return s.Target == target
+	})
+}
+
+func (d *SuggestionsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, s := range d.repo.SuggestLinks(d.nodeID, suggestionLimit) {
+		entries = append(entries, fuse.DirEntry{
+			Name: s.Target,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("suggestions/" + d.nodeID + "/" + s.Target),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *SuggestionsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !d.suggested(name) {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../nodes/" + name}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("suggestions/" + d.nodeID + "/" + name),
+	}), fs.OK
+}
+
+// Rename accepts a suggestion, creating the link. The new name picks the
+// link type: "{type}:{target}" uses type, anything else the default.
+func (d *SuggestionsDir) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if target, ok := newParent.(*SuggestionsDir); !ok || target.nodeID != d.nodeID {
+		return syscall.EXDEV
+	}
+	if !d.suggested(name) {
+		return syscall.ENOENT
+	}
+	linkType, ok := strings.CutSuffix(newName, ":"+name)
+	if !ok {
+		linkType = ""
+	}
+	if err := actingRepo(ctx, d.repo).AcceptSuggestion(d.nodeID, name, linkType); err != nil {
+		fmt.Printf("memex-fs: accept suggestion %s -> %s: %v\n", d.nodeID, name, err)
+		return lastErrors.fail(d.nodeID, "accept suggested link to "+name, err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(d.nodeID)
+	return fs.OK
+}
+
+// Unlink rejects a suggestion for good.
+func (d *SuggestionsDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	if !d.suggested(name) {
+		return syscall.ENOENT
+	}
+	if err := actingRepo(ctx, d.repo).RejectSuggestion(d.nodeID, name); err != nil {
+		fmt.Printf("memex-fs: reject suggestion %s -> %s: %v\n", d.nodeID, name, err)
+		return syscall.EIO
+	}
+	return fs.OK
+}
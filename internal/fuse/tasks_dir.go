@@ -0,0 +1,172 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// TasksDir is /tasks/ — Task nodes by workflow state, plus upcoming/:
+//
+//	open/ doing/ done/  symlinks to ../../nodes/{id}; mv between them
+//	                    sets meta["status"]
+//	upcoming/           unfinished tasks with a due day, as
+//	                    "{due}_{id}" so `ls` lists them soonest first
+type TasksDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*TasksDir)(nil))
+var _ = (fs.NodeReaddirer)((*TasksDir)(nil))
+var _ = (fs.NodeGetattrer)((*TasksDir)(nil))
+
+func (d *TasksDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("tasks")
+	return fs.OK
+}
+
+func (d *TasksDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, name := range append(slices.Clone(dag.TaskStatuses), "upcoming") {
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("tasks/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *TasksDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
+	var child fs.InodeEmbedder
+	switch {
+	case name == "upcoming":
+		child = &UpcomingTasksDir{repo: d.repo}
+	case slices.Contains(dag.TaskStatuses, name):
+		child = &TaskStatusDir{repo: d.repo, status: name}
+	default:
+		return nil, syscall.ENOENT
+	}
+	return d.NewInode(ctx, child, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno("tasks/" + name),
+	}), fs.OK
+}
+
+// TaskStatusDir is /tasks/{status}/. Renaming an entry into another status
+// directory moves the task to that status.
+type TaskStatusDir struct {
+	fs.Inode
+	repo   *dag.Repository
+	status string
+}
+
+var _ = (fs.NodeLookuper)((*TaskStatusDir)(nil))
+var _ = (fs.NodeReaddirer)((*TaskStatusDir)(nil))
+var _ = (fs.NodeGetattrer)((*TaskStatusDir)(nil))
+var _ = (fs.NodeRenamer)((*TaskStatusDir)(nil))
+
+func (d *TaskStatusDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	out.Ino = stableIno("tasks/" + d.status)
+	return fs.OK
+}
+
+func (d *TaskStatusDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, id := range d.repo.TasksByStatus(d.status) {
+		entries = append(entries, fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("tasks/" + d.status + "/" + id),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *TaskStatusDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	node, err := d.repo.GetNode(name)
+	if err != nil || node.Type != dag.TaskType || dag.TaskStatus(node) != d.status {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../nodes/" + name}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("tasks/" + d.status + "/" + name),
+	}), fs.OK
+}
+
+// Rename moves a task to the target directory's status. The entry keeps
+// its name: the name is the node ID.
+func (d *TaskStatusDir) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	target, ok := newParent.(*TaskStatusDir)
+	if !ok || newName != name {
+		return syscall.EXDEV
+	}
+	node, err := d.repo.GetNode(name)
+	if err != nil || node.Type != dag.TaskType || dag.TaskStatus(node) != d.status {
+		return syscall.ENOENT
+	}
+	if _, err := actingRepo(ctx, d.repo).SetTaskStatus(name, target.status); err != nil {
+		fmt.Printf("memex-fs: task %s -> %s: %v\n", name, target.status, err)
+		return lastErrors.fail(name, "move task to "+target.status, err, writeErrno(err, syscall.EIO))
+	}
+	lastErrors.ok(name)
+	return fs.OK
+}
+
+// UpcomingTasksDir is /tasks/upcoming/.
+type UpcomingTasksDir struct {
+	fs.Inode
+	repo *dag.Repository
+}
+
+var _ = (fs.NodeLookuper)((*UpcomingTasksDir)(nil))
+var _ = (fs.NodeReaddirer)((*UpcomingTasksDir)(nil))
+var _ = (fs.NodeGetattrer)((*UpcomingTasksDir)(nil))
+
+func (d *UpcomingTasksDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno("tasks/upcoming")
+	return fs.OK
+}
+
+func (d *UpcomingTasksDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, t := range d.repo.UpcomingTasks() {
+		name := t.Due + "_" + t.ID
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno("tasks/upcoming/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *UpcomingTasksDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	due, id, ok := strings.Cut(name, "_")
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	// Accept the name only while the task is still upcoming on that day.
+	if !slices.Contains(d.repo.UpcomingTasks(), dag.UpcomingTask{ID: id, Due: due}) {
+		return nil, syscall.ENOENT
+	}
+	sym := &LinkSymlink{target: "../../nodes/" + id}
+	return d.NewInode(ctx, sym, fs.StableAttr{
+		Mode: syscall.S_IFLNK,
+		Ino:  stableIno("tasks/upcoming/" + name),
+	}), fs.OK
+}
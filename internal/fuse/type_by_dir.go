@@ -0,0 +1,197 @@
+package fuse
+
+import (
+	"context"
+	"net/url"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// TypeByDir is /types/{type}/by/ — one subdirectory per indexed meta key.
+type TypeByDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	typeName string
+}
+
+var _ = (fs.NodeLookuper)((*TypeByDir)(nil))
+var _ = (fs.NodeReaddirer)((*TypeByDir)(nil))
+var _ = (fs.NodeGetattrer)((*TypeByDir)(nil))
+
+func (d *TypeByDir) prefix() string {
+	return "types/" + d.typeName + "/by"
+}
+
+func (d *TypeByDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.prefix())
+	return fs.OK
+}
+
+func (d *TypeByDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	keys := d.repo.Meta.Keys()
+	entries := make([]fuse.DirEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = fuse.DirEntry{
+			Name: k,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(d.prefix() + "/" + k),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *TypeByDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !d.repo.Meta.Indexed(name) {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, &TypeByKeyDir{repo: d.repo, typeName: d.typeName, key: name}, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(d.prefix() + "/" + name),
+	})
+	return child, fs.OK
+}
+
+// TypeByKeyDir is /types/{type}/by/{key}/ — one subdirectory per value the
+// nodes of this type hold for key. Values are path-escaped so "/" can't
+// split them.
+type TypeByKeyDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	typeName string
+	key      string
+}
+
+var _ = (fs.NodeLookuper)((*TypeByKeyDir)(nil))
+var _ = (fs.NodeReaddirer)((*TypeByKeyDir)(nil))
+var _ = (fs.NodeGetattrer)((*TypeByKeyDir)(nil))
+
+func (d *TypeByKeyDir) prefix() string {
+	return "types/" + d.typeName + "/by/" + d.key
+}
+
+// values returns the distinct values held by nodes of this type, in the
+// MetaIndex's value order.
+func (d *TypeByKeyDir) values() []string {
+	held := make(map[string]bool)
+	for _, id := range d.repo.Search.FilterByType(d.typeName, 0) {
+		for _, v := range d.repo.Meta.Get(d.key, id) {
+			held[v] = true
+		}
+	}
+	var out []string
+	for _, v := range d.repo.Meta.Values(d.key) {
+		if held[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (d *TypeByKeyDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.prefix())
+	return fs.OK
+}
+
+func (d *TypeByKeyDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, v := range d.values() {
+		name := url.PathEscape(v)
+		if name == "" || name == "." || name == ".." {
+			continue
+		}
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno(d.prefix() + "/" + name),
+		})
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *TypeByKeyDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	value, err := url.PathUnescape(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+	val := &TypeByValueDir{repo: d.repo, typeName: d.typeName, key: d.key, value: value, name: name}
+	if len(val.ids()) == 0 {
+		return nil, syscall.ENOENT
+	}
+	child := d.NewInode(ctx, val, fs.StableAttr{
+		Mode: syscall.S_IFDIR,
+		Ino:  stableIno(d.prefix() + "/" + name),
+	})
+	return child, fs.OK
+}
+
+// TypeByValueDir is /types/{type}/by/{key}/{value}/ — symlinks to the nodes
+// of this type whose key holds value.
+type TypeByValueDir struct {
+	fs.Inode
+	repo     *dag.Repository
+	typeName string
+	key      string
+	value    string
+	name     string // escaped directory name
+}
+
+var _ = (fs.NodeLookuper)((*TypeByValueDir)(nil))
+var _ = (fs.NodeReaddirer)((*TypeByValueDir)(nil))
+var _ = (fs.NodeGetattrer)((*TypeByValueDir)(nil))
+
+func (d *TypeByValueDir) prefix() string {
+	return "types/" + d.typeName + "/by/" + d.key + "/" + d.name
+}
+
+// ids intersects the MetaIndex hits with the type.
+func (d *TypeByValueDir) ids() []string {
+	ofType := make(map[string]bool)
+	for _, id := range d.repo.Search.FilterByType(d.typeName, 0) {
+		ofType[id] = true
+	}
+	var ids []string
+	for _, id := range d.repo.Meta.Exact(d.key, d.value) {
+		if ofType[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (d *TypeByValueDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	out.Ino = stableIno(d.prefix())
+	return fs.OK
+}
+
+func (d *TypeByValueDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	ids := d.ids()
+	entries := make([]fuse.DirEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = fuse.DirEntry{
+			Name: id,
+			Mode: syscall.S_IFLNK,
+			Ino:  stableIno(d.prefix() + "/" + id),
+		}
+	}
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+func (d *TypeByValueDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	for _, id := range d.ids() {
+		if id == name {
+			sym := &LinkSymlink{target: "../../../../../nodes/" + id}
+			child := d.NewInode(ctx, sym, fs.StableAttr{
+				Mode: syscall.S_IFLNK,
+				Ino:  stableIno(d.prefix() + "/" + id),
+			})
+			return child, fs.OK
+		}
+	}
+	return nil, syscall.ENOENT
+}
@@ -26,6 +26,9 @@ func (d *TypesDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.Attr
 }
 
 func (d *TypesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
 	types := d.repo.Search.AllTypes()
 	entries := make([]fuse.DirEntry, len(types))
 	for i, t := range types {
@@ -39,6 +42,9 @@ func (d *TypesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 }
 
 func (d *TypesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := notReady(d.repo, dag.IndexSearch); errno != fs.OK {
+		return nil, errno
+	}
 	// Check if this type has any nodes
 	ids := d.repo.Search.FilterByType(name, 0)
 	if len(ids) == 0 {
@@ -53,6 +59,7 @@ func (d *TypesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut)
 }
 
 // TypeGroupDir lists all nodes of a specific type as symlinks to ../../nodes/{id}.
+// When meta keys are indexed it also holds by/, which groups them by value.
 type TypeGroupDir struct {
 	fs.Inode
 	repo     *dag.Repository
@@ -71,18 +78,33 @@ func (d *TypeGroupDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.
 
 func (d *TypeGroupDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	ids := d.repo.Search.FilterByType(d.typeName, 0)
-	entries := make([]fuse.DirEntry, len(ids))
-	for i, id := range ids {
-		entries[i] = fuse.DirEntry{
+	entries := make([]fuse.DirEntry, 0, len(ids)+1)
+	if len(d.repo.Meta.Keys()) > 0 {
+		entries = append(entries, fuse.DirEntry{
+			Name: "by",
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("types/" + d.typeName + "/by"),
+		})
+	}
+	for _, id := range ids {
+		entries = append(entries, fuse.DirEntry{
 			Name: id,
 			Mode: syscall.S_IFLNK,
 			Ino:  stableIno("types/" + d.typeName + "/" + id),
-		}
+		})
 	}
 	return fs.NewListDirStream(entries), fs.OK
 }
 
 func (d *TypeGroupDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "by" && len(d.repo.Meta.Keys()) > 0 {
+		child := d.NewInode(ctx, &TypeByDir{repo: d.repo, typeName: d.typeName}, fs.StableAttr{
+			Mode: syscall.S_IFDIR,
+			Ino:  stableIno("types/" + d.typeName + "/by"),
+		})
+		return child, fs.OK
+	}
+
 	// Verify the node exists and is of this type
 	node, err := d.repo.GetNode(name)
 	if err != nil || node.Type != d.typeName {
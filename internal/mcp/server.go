@@ -0,0 +1,211 @@
+// Package mcp serves a repository over the Model Context Protocol, so LLM
+// agents can search, read, create and link nodes through structured tool
+// calls instead of scraping the FUSE mount.
+//
+// The transport is MCP's stdio transport: newline-delimited JSON-RPC 2.0
+// messages on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// protocolVersions are the MCP revisions this server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// maxMessageSize bounds one JSON-RPC message, so a create_node with a large
+// body still fits.
+const maxMessageSize = 64 << 20
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Server answers MCP requests from the tools in tools.go.
+type Server struct {
+	repo  *dag.Repository
+	token string // capability token every tool call is checked against, if set
+}
+
+// NewServer creates a Server over repo.
+func NewServer(repo *dag.Repository) *Server {
+	return &Server{repo: repo}
+}
+
+// RequireToken limits the session to what token grants. The token is
+// verified now and again on each tool call, so revoking it or letting it
+// expire ends a running session's access.
+func (s *Server) RequireToken(token string) error {
+	if _, err := s.repo.VerifyToken(token); err != nil {
+		return err
+	}
+	s.token = token
+	return nil
+}
+
+// serverVersion is the module version the binary was built from, or
+// "devel" for a local build.
+func serverVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return "devel"
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads requests from r and writes responses to w until r is
+// exhausted or ctx is cancelled. Requests are answered in order.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+	enc := json.NewEncoder(w)
+	send := func(resp *response) error { return enc.Encode(resp) }
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := send(&response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{codeParseError, err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		resp := s.handle(ctx, &req)
+		if resp == nil {
+			continue // notification
+		}
+		if err := send(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers one request; notifications get no response.
+func (s *Server) handle(ctx context.Context, req *request) *response {
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" {
+		resp.Error = &rpcError{codeInvalidRequest, "jsonrpc must be 2.0"}
+		return resp
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		resp.Result = map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "memex-fs", "version": serverVersion()},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": toolList()}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &rpcError{codeInvalidParams, err.Error()}
+			return resp
+		}
+		t, ok := tools[params.Name]
+		if !ok {
+			resp.Error = &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+			return resp
+		}
+		resp.Result = s.callTool(ctx, t, params.Arguments)
+	default:
+		resp.Error = &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+	}
+	return resp
+}
+
+// toolResult is the tools/call result: the tool's output as JSON text, or
+// its error with isError set so the model sees what went wrong.
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (s *Server) callTool(ctx context.Context, t tool, args json.RawMessage) *toolResult {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if s.token != "" {
+		c, err := s.repo.VerifyToken(s.token)
+		if err != nil {
+			return &toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}
+		}
+		op := dag.OpRead
+		if t.write {
+			op = dag.OpWrite
+		}
+		if !c.Allows(op, "") {
+			return &toolResult{Content: []textContent{{"text", "token does not grant " + op}}, IsError: true}
+		}
+		ctx = dag.ContextWithCapability(ctx, c)
+	}
+	out, err := t.call(ctx, s.repo, args)
+	if err != nil {
+		return &toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return &toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}
+	}
+	return &toolResult{Content: []textContent{{"text", string(data)}}}
+}
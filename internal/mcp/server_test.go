@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// session sends each request line through Serve and returns the decoded
+// responses in order.
+func session(t *testing.T, repo *dag.Repository, lines ...string) []response {
+	t.Helper()
+	var out bytes.Buffer
+	if err := NewServer(repo).Serve(t.Context(), strings.NewReader(strings.Join(lines, "\n")), &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	var resps []response
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var r response
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("bad response %q: %v", scanner.Text(), err)
+		}
+		resps = append(resps, r)
+	}
+	return resps
+}
+
+// toolText returns the text of a tools/call result, failing if the call
+// errored unless wantErr.
+func toolText(t *testing.T, r response, wantErr bool) string {
+	t.Helper()
+	if r.Error != nil {
+		t.Fatalf("rpc error: %+v", r.Error)
+	}
+	data, _ := json.Marshal(r.Result)
+	var res toolResult
+	json.Unmarshal(data, &res)
+	if res.IsError != wantErr || len(res.Content) != 1 {
+		t.Fatalf("tool result = %+v, want isError=%v", res, wantErr)
+	}
+	return res.Content[0].Text
+}
+
+func openRepo(t *testing.T) *dag.Repository {
+	t.Helper()
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestServe_Handshake(t *testing.T) {
+	resps := session(t, openRepo(t),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`,
+		`not json`,
+	)
+	if len(resps) != 4 {
+		t.Fatalf("%d responses, want 4 (notification unanswered)", len(resps))
+	}
+
+	init := resps[0].Result.(map[string]interface{})
+	if init["protocolVersion"] != "2024-11-05" {
+		t.Errorf("protocolVersion = %v, want the client's", init["protocolVersion"])
+	}
+
+	var names []string
+	for _, tool := range resps[1].Result.(map[string]interface{})["tools"].([]interface{}) {
+		names = append(names, tool.(map[string]interface{})["name"].(string))
+	}
+	want := "create_node,get_node,link_nodes,related_to,search_nodes,traverse"
+	if strings.Join(names, ",") != want {
+		t.Errorf("tools = %v, want %s", names, want)
+	}
+
+	if resps[2].Error == nil || resps[2].Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method: %+v", resps[2])
+	}
+	if resps[3].Error == nil || resps[3].Error.Code != codeParseError {
+		t.Errorf("bad JSON: %+v", resps[3])
+	}
+}
+
+func TestServe_Tools(t *testing.T) {
+	repo := openRepo(t)
+	resps := session(t, repo,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"create_node","arguments":{"id":"person:alice","type":"Person","content":"Alice likes graphs"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"create_node","arguments":{"id":"note:a","type":"Note","content":"about alice"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"link_nodes","arguments":{"source":"note:a","target":"person:alice","type":"mentions"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_node","arguments":{"id":"person:alice"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"search_nodes","arguments":{"query":"graphs"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"traverse","arguments":{"id":"note:a"}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"create_node","arguments":{"id":"note:a","type":"Note"}}}`,
+		`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"get_node","arguments":{"id":"note:missing"}}}`,
+		`{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"rm_rf","arguments":{}}}`,
+	)
+	if len(resps) != 9 {
+		t.Fatalf("%d responses, want 9", len(resps))
+	}
+	for _, r := range resps[:3] {
+		toolText(t, r, false)
+	}
+
+	var alice struct {
+		Content   string          `json:"content"`
+		Backlinks []dag.LinkEntry `json:"backlinks"`
+	}
+	json.Unmarshal([]byte(toolText(t, resps[3], false)), &alice)
+	if alice.Content != "Alice likes graphs" || len(alice.Backlinks) != 1 || alice.Backlinks[0].Source != "note:a" {
+		t.Errorf("get_node = %+v", alice)
+	}
+	if got := toolText(t, resps[4], false); !strings.Contains(got, `"id": "person:alice"`) {
+		t.Errorf("search_nodes = %s", got)
+	}
+	if got := toolText(t, resps[5], false); !strings.Contains(got, `"id": "person:alice"`) {
+		t.Errorf("traverse = %s", got)
+	}
+
+	if got := toolText(t, resps[6], true); !strings.Contains(got, "already exists") {
+		t.Errorf("duplicate create = %s", got)
+	}
+	toolText(t, resps[7], true)
+	if resps[8].Error == nil || resps[8].Error.Code != codeInvalidParams {
+		t.Errorf("unknown tool: %+v", resps[8])
+	}
+}
+
+func TestServe_Token(t *testing.T) {
+	repo := openRepo(t)
+	repo.CreateNode("note:a", "Note", []byte("visible"), nil)
+	repo.CreateNode("person:b", "Person", []byte("hidden"), nil)
+	repo.CreateLink("note:a", "person:b", "mentions")
+	token, c, err := repo.MintToken("agent", "", []string{dag.OpRead}, []string{"Note"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(repo)
+	if err := s.RequireToken("mxt_bogus"); err == nil {
+		t.Error("RequireToken accepted a bogus token")
+	}
+	if err := s.RequireToken(token); err != nil {
+		t.Fatal(err)
+	}
+	call := func(name, args string) *toolResult {
+		return s.callTool(t.Context(), tools[name], json.RawMessage(args))
+	}
+
+	if res := call("get_node", `{"id":"note:a"}`); res.IsError || strings.Contains(res.Content[0].Text, "person:b") {
+		t.Errorf("get_node note:a = %+v, want the node without its hidden link", res)
+	}
+	if res := call("get_node", `{"id":"person:b"}`); !res.IsError {
+		t.Errorf("get_node person:b succeeded outside the token's types")
+	}
+	if res := call("create_node", `{"id":"note:c","type":"Note"}`); !res.IsError {
+		t.Errorf("create_node succeeded with a read-only token")
+	}
+
+	repo.RevokeToken(c.ID)
+	if res := call("get_node", `{"id":"note:a"}`); !res.IsError {
+		t.Errorf("get_node succeeded after revocation")
+	}
+}
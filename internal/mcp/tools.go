@@ -0,0 +1,320 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Default and maximum result counts for the list-returning tools.
+const (
+	defaultLimit = 20
+	maxLimit     = 200
+	maxDepth     = 3
+)
+
+type tool struct {
+	description string
+	schema      map[string]interface{} // JSON Schema for the arguments
+	call        func(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error)
+	write       bool // needs a token granting write, not just read
+}
+
+var tools = map[string]tool{
+	"search_nodes": {
+		description: "Full-text search over node IDs, types, content and meta. Returns matching nodes, best first.",
+		schema: object(map[string]interface{}{
+			"query": prop("string", "Search terms"),
+			"limit": prop("integer", "Maximum results (default 20)"),
+		}, "query"),
+		call: searchNodes,
+	},
+	"get_node": {
+		description: "Read one node: content, meta, and its outgoing and incoming links.",
+		schema: object(map[string]interface{}{
+			"id": prop("string", "Node ID, e.g. person:alice"),
+		}, "id"),
+		call: getNode,
+	},
+	"create_node": {
+		description: "Create a node. The ID is conventionally {type}:{slug}, e.g. note:meeting-2025-03-14.",
+		schema: object(map[string]interface{}{
+			"id":      prop("string", "New node ID"),
+			"type":    prop("string", "Node type, e.g. Note or Person"),
+			"content": prop("string", "Node content, usually Markdown"),
+			"meta":    prop("object", "Metadata fields"),
+		}, "id", "type"),
+		call:  createNode,
+		write: true,
+	},
+	"link_nodes": {
+		description: "Create a typed link from source to target.",
+		schema: object(map[string]interface{}{
+			"source": prop("string", "Source node ID"),
+			"target": prop("string", "Target node ID"),
+			"type":   prop("string", "Link type, e.g. cites or knows"),
+		}, "source", "target", "type"),
+		call:  linkNodes,
+		write: true,
+	},
+	"traverse": {
+		description: "Breadth-first walk of the link graph from a node, up to depth hops (default 1, at most 3).",
+		schema: object(map[string]interface{}{
+			"id":    prop("string", "Start node ID"),
+			"depth": prop("integer", "Hops to follow"),
+		}, "id"),
+		call: traverse,
+	},
+	"related_to": {
+		description: "Nodes related to a node by how often they are edited and read together, with scores.",
+		schema: object(map[string]interface{}{
+			"id":    prop("string", "Node ID"),
+			"limit": prop("integer", "Maximum results (default 20)"),
+		}, "id"),
+		call: relatedTo,
+	},
+}
+
+func object(props map[string]interface{}, required ...string) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": props, "required": required}
+}
+
+func prop(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+// toolList is the tools/list result, sorted by name.
+func toolList() []map[string]interface{} {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]map[string]interface{}, len(names))
+	for i, name := range names {
+		list[i] = map[string]interface{}{
+			"name":        name,
+			"description": tools[name].description,
+			"inputSchema": tools[name].schema,
+		}
+	}
+	return list
+}
+
+// clampLimit applies the default and cap to a requested result count.
+func clampLimit(n int) int {
+	if n <= 0 {
+		return defaultLimit
+	}
+	return min(n, maxLimit)
+}
+
+// nodeSummary is a node as tools return it: content as text rather than
+// the envelope's base64.
+type nodeSummary struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Content  string                 `json:"content,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Modified time.Time              `json:"modified"`
+}
+
+func summarize(node *dag.NodeEnvelope) nodeSummary {
+	return nodeSummary{
+		ID:       node.ID,
+		Type:     node.Type,
+		Content:  string(node.Content),
+		Meta:     node.Meta,
+		Modified: node.Modified,
+	}
+}
+
+// summarizeAll summarizes the nodes the session may read.
+func summarizeAll(ctx context.Context, repo *dag.Repository, nodes []*dag.NodeEnvelope) []nodeSummary {
+	c := dag.CapabilityFrom(ctx)
+	out := make([]nodeSummary, 0, len(nodes))
+	for _, n := range nodes {
+		if repo.Authorized(c, n, dag.OpRead) {
+			out = append(out, summarize(n))
+		}
+	}
+	return out
+}
+
+// readable returns the live node id if the session's capability, and the
+// node's ACL, grant op on it. A node they hide is reported missing, as if
+// it did not exist.
+func readable(ctx context.Context, repo *dag.Repository, id, op string) (*dag.NodeEnvelope, error) {
+	node, err := repo.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	c := dag.CapabilityFrom(ctx)
+	if !repo.Authorized(c, node, dag.OpRead) {
+		return nil, fmt.Errorf("node not found: %s", id)
+	}
+	if !repo.Authorized(c, node, op) {
+		return nil, fmt.Errorf("%s on %s: %w", op, id, dag.ErrAccessDenied)
+	}
+	return node, nil
+}
+
+// visibleLinks keeps the links whose far end, as end picks it, the
+// session's capability may read.
+func visibleLinks(ctx context.Context, repo *dag.Repository, links []dag.LinkEntry, end func(dag.LinkEntry) string) []dag.LinkEntry {
+	if dag.CapabilityFrom(ctx) == nil {
+		return links
+	}
+	out := []dag.LinkEntry{}
+	for _, l := range links {
+		if _, err := readable(ctx, repo, dag.LinkTargetParent(end(l)), dag.OpRead); err == nil {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func searchNodes(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	nodes, err := repo.SearchNodes(ctx, a.Query, clampLimit(a.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return summarizeAll(ctx, repo, nodes), nil
+}
+
+func getNode(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	node, err := readable(ctx, repo, a.ID, dag.OpRead)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		nodeSummary
+		Links     []dag.LinkEntry `json:"links"`
+		Backlinks []dag.LinkEntry `json:"backlinks"`
+	}{
+		summarize(node),
+		visibleLinks(ctx, repo, repo.Links.LinksFrom(a.ID), func(l dag.LinkEntry) string { return l.Target }),
+		visibleLinks(ctx, repo, repo.Links.LinksTo(a.ID), func(l dag.LinkEntry) string { return l.Source }),
+	}, nil
+}
+
+func createNode(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		ID      string                 `json:"id"`
+		Type    string                 `json:"type"`
+		Content string                 `json:"content"`
+		Meta    map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.ID == "" || a.Type == "" {
+		return nil, fmt.Errorf("id and type are required")
+	}
+	if !dag.CapabilityFrom(ctx).Allows(dag.OpWrite, a.Type) {
+		return nil, fmt.Errorf("token does not grant write on %s nodes", a.Type)
+	}
+	if _, err := repo.GetNode(a.ID); err == nil {
+		return nil, fmt.Errorf("node %s already exists", a.ID)
+	}
+	if err := dag.ValidateMeta(&dag.NodeEnvelope{Type: a.Type}, a.Meta); err != nil {
+		return nil, err
+	}
+	node, err := repo.CreateNode(a.ID, a.Type, []byte(a.Content), a.Meta)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(node), nil
+}
+
+func linkNodes(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a dag.LinkEntry
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Source == "" || a.Target == "" || a.Type == "" {
+		return nil, fmt.Errorf("source, target and type are required")
+	}
+	if _, err := readable(ctx, repo, a.Source, dag.OpWrite); err != nil {
+		return nil, err
+	}
+	if _, err := readable(ctx, repo, dag.LinkTargetParent(a.Target), dag.OpRead); err != nil {
+		return nil, err
+	}
+	if err := repo.CreateLink(a.Source, a.Target, a.Type); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func traverse(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		ID    string `json:"id"`
+		Depth int    `json:"depth"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if _, err := readable(ctx, repo, a.ID, dag.OpRead); err != nil {
+		return nil, err
+	}
+	depth := a.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	nodes, err := repo.Traverse(ctx, a.ID, min(depth, maxDepth))
+	if err != nil {
+		return nil, err
+	}
+	return summarizeAll(ctx, repo, nodes), nil
+}
+
+func relatedTo(ctx context.Context, repo *dag.Repository, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		ID    string `json:"id"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if _, err := readable(ctx, repo, a.ID, dag.OpRead); err != nil {
+		return nil, err
+	}
+	type related struct {
+		ID    string  `json:"id"`
+		Type  string  `json:"type"`
+		Score float64 `json:"score"`
+	}
+	out := []related{}
+	for _, s := range repo.Relatedness.RelatedScored(a.ID, 0) {
+		if len(out) == clampLimit(a.Limit) {
+			break
+		}
+		node, err := readable(ctx, repo, s.ID, dag.OpRead)
+		if err != nil {
+			continue
+		}
+		out = append(out, related{ID: s.ID, Type: node.Type, Score: s.Score})
+	}
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Result is one search hit.
+type Result struct {
+	ID    string
+	Type  string
+	Title string // title or name meta, if any
+}
+
+// Backend is what the TUI reads and writes through: the repository itself,
+// or a running mount's files.
+type Backend interface {
+	Search(query string, limit int) ([]Result, error)
+	Content(id string) (string, error)
+	// Capture stores text as a new Note and returns its ID.
+	Capture(text string) (string, error)
+	Link(source, target, linkType string) error
+}
+
+// captureID picks an unused ID for a note holding text: "note:" and the
+// slug of its first few words, or of the time when it has none, numbered
+// when taken.
+func captureID(text string, taken func(id string) bool) string {
+	words := strings.Fields(text)
+	if len(words) > 6 {
+		words = words[:6]
+	}
+	slug := dag.Slug(strings.Join(words, " "))
+	if slug == "" {
+		slug = time.Now().Format("20060102-150405")
+	}
+	id := "note:" + slug
+	for n := 2; taken(id); n++ {
+		id = fmt.Sprintf("note:%s-%d", slug, n)
+	}
+	return id
+}
+
+// RepoBackend works on an open repository, with search-as-you-type
+// matching partial words. Don't use it on a repository that is mounted:
+// the mount would not see its changes until remounted.
+type RepoBackend struct {
+	Repo *dag.Repository
+}
+
+func (b RepoBackend) Search(query string, limit int) ([]Result, error) {
+	var results []Result
+	for _, id := range b.Repo.Search.SearchPrefix(query, limit) {
+		node, err := b.Repo.GetNode(id)
+		if err != nil {
+			continue
+		}
+		title, _ := node.Meta["title"].(string)
+		if title == "" {
+			title, _ = node.Meta["name"].(string)
+		}
+		results = append(results, Result{ID: id, Type: node.Type, Title: title})
+	}
+	return results, nil
+}
+
+func (b RepoBackend) Content(id string) (string, error) {
+	node, err := b.Repo.GetNode(id)
+	if err != nil {
+		return "", err
+	}
+	return string(node.Content), nil
+}
+
+func (b RepoBackend) Capture(text string) (string, error) {
+	id := captureID(text, b.Repo.Refs.Has)
+	if _, err := b.Repo.CreateNode(id, "Note", []byte(text), nil); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (b RepoBackend) Link(source, target, linkType string) error {
+	if _, err := b.Repo.GetNode(dag.LinkTargetParent(target)); err != nil {
+		return err
+	}
+	return b.Repo.CreateLink(source, target, linkType)
+}
+
+// MountBackend works through the files of a mount at Dir, so it is safe
+// beside the running memex-fs. Search goes through /search/, which matches
+// whole words only.
+type MountBackend struct {
+	Dir string
+}
+
+func (b MountBackend) path(parts ...string) string {
+	return filepath.Join(append([]string{b.Dir}, parts...)...)
+}
+
+func (b MountBackend) Search(query string, limit int) ([]Result, error) {
+	if strings.TrimSpace(query) == "" || strings.Contains(query, "/") {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(b.path("search", query))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil // no matches
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, e := range entries {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		typ, _ := os.ReadFile(b.path("nodes", e.Name(), "type"))
+		results = append(results, Result{ID: e.Name(), Type: strings.TrimSpace(string(typ))})
+	}
+	return results, nil
+}
+
+func (b MountBackend) Content(id string) (string, error) {
+	data, err := os.ReadFile(b.path("nodes", id, "content"))
+	return string(data), err
+}
+
+func (b MountBackend) Capture(text string) (string, error) {
+	id := captureID(text, func(id string) bool {
+		_, err := os.Lstat(b.path("nodes", id))
+		return err == nil
+	})
+	if err := os.Mkdir(b.path("nodes", id), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(b.path("nodes", id, "content"), []byte(text), 0644); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (b MountBackend) Link(source, target, linkType string) error {
+	dir := b.path("nodes", source, "links", linkType)
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return os.Symlink(target, filepath.Join(dir, target))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tui
+
+import "errors"
+
+// makeRaw fails: raw mode is only implemented for Linux terminals.
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
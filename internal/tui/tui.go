@@ -0,0 +1,283 @@
+// Package tui is a terminal interface to a repository for the quick
+// things: search as you type, capture a note, link two nodes. It draws
+// with plain ANSI escapes on a raw-mode terminal.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// resultLimit is how many search hits are shown.
+const resultLimit = 15
+
+// keyKind is one decoded keypress. Printable characters are keyRune, with
+// the character passed beside it.
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyBackspace
+	keyUp
+	keyDown
+	keyEsc
+	keyQuit    // Ctrl-C, Ctrl-D
+	keyCapture // Ctrl-N
+	keyLink    // Ctrl-L
+	keyOther
+)
+
+type mode int
+
+const (
+	modeSearch mode = iota
+	modeCapture
+	modeLink
+	modeView
+)
+
+// app is the TUI's state. handle applies a keypress and view renders the
+// screen, so the two can be driven without a terminal.
+type app struct {
+	backend  Backend
+	mode     mode
+	query    []rune
+	input    []rune // the note being captured, or "type target" being linked
+	results  []Result
+	selected int
+	content  string // what modeView shows
+	status   string
+}
+
+func newApp(b Backend) *app {
+	return &app{backend: b}
+}
+
+// handle applies key (with r, for keyRune) and reports whether to quit.
+func (a *app) handle(key keyKind, r rune) (quit bool) {
+	if key == keyQuit {
+		return true
+	}
+	switch a.mode {
+	case modeView:
+		a.mode = modeSearch
+	case modeCapture, modeLink:
+		a.handleInput(key, r)
+	default:
+		a.handleSearch(key, r)
+	}
+	return false
+}
+
+func (a *app) handleSearch(key keyKind, r rune) {
+	switch key {
+	case keyRune:
+		a.query = append(a.query, r)
+		a.search()
+	case keyBackspace:
+		if len(a.query) > 0 {
+			a.query = a.query[:len(a.query)-1]
+			a.search()
+		}
+	case keyEsc:
+		a.query = nil
+		a.search()
+	case keyUp:
+		if a.selected > 0 {
+			a.selected--
+		}
+	case keyDown:
+		if a.selected < len(a.results)-1 {
+			a.selected++
+		}
+	case keyEnter:
+		if sel, ok := a.current(); ok {
+			content, err := a.backend.Content(sel.ID)
+			if err != nil {
+				a.status = err.Error()
+				return
+			}
+			a.content, a.mode = content, modeView
+		}
+	case keyCapture:
+		a.mode, a.input, a.status = modeCapture, nil, ""
+	case keyLink:
+		if _, ok := a.current(); ok {
+			a.mode, a.input, a.status = modeLink, nil, ""
+		}
+	}
+}
+
+func (a *app) handleInput(key keyKind, r rune) {
+	switch key {
+	case keyRune:
+		a.input = append(a.input, r)
+	case keyBackspace:
+		if len(a.input) > 0 {
+			a.input = a.input[:len(a.input)-1]
+		}
+	case keyEsc:
+		a.mode, a.status = modeSearch, ""
+	case keyEnter:
+		a.submit(strings.TrimSpace(string(a.input)))
+	}
+}
+
+// submit finishes a capture or link with the typed text.
+func (a *app) submit(text string) {
+	if text == "" {
+		a.mode = modeSearch
+		return
+	}
+	if a.mode == modeCapture {
+		id, err := a.backend.Capture(text)
+		if err != nil {
+			a.status = "capture failed: " + err.Error()
+			return
+		}
+		a.status = "captured " + id
+	} else {
+		sel, _ := a.current()
+		linkType, target, ok := strings.Cut(text, " ")
+		if target = strings.TrimSpace(target); !ok || target == "" {
+			a.status = "link as: <type> <target-id>"
+			return
+		}
+		if err := a.backend.Link(sel.ID, target, linkType); err != nil {
+			a.status = "link failed: " + err.Error()
+			return
+		}
+		a.status = fmt.Sprintf("linked %s -[%s]-> %s", sel.ID, linkType, target)
+	}
+	a.mode = modeSearch
+	a.search()
+}
+
+func (a *app) current() (Result, bool) {
+	if a.selected < len(a.results) {
+		return a.results[a.selected], true
+	}
+	return Result{}, false
+}
+
+func (a *app) search() {
+	a.selected = 0
+	results, err := a.backend.Search(string(a.query), resultLimit)
+	if err != nil {
+		a.results, a.status = nil, err.Error()
+		return
+	}
+	a.results = results
+}
+
+// view renders the screen.
+func (a *app) view() string {
+	var b strings.Builder
+	switch a.mode {
+	case modeView:
+		sel, _ := a.current()
+		fmt.Fprintf(&b, "%s\n\n%s\n\n(any key to go back)\n", sel.ID, a.content)
+		return b.String()
+	case modeCapture:
+		fmt.Fprintf(&b, "new note> %s\n\n(enter to save, esc to cancel)\n", string(a.input))
+		return b.String()
+	case modeLink:
+		sel, _ := a.current()
+		fmt.Fprintf(&b, "link %s as <type> <target>> %s\n\n(enter to link, esc to cancel)\n", sel.ID, string(a.input))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "search> %s\n\n", string(a.query))
+	for i, r := range a.results {
+		marker := "  "
+		if i == a.selected {
+			marker = "> "
+		}
+		line := marker + r.ID
+		if r.Title != "" {
+			line += "  " + r.Title
+		}
+		if r.Type != "" {
+			line += "  [" + r.Type + "]"
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(a.query) > 0 && len(a.results) == 0 {
+		b.WriteString("  no matches\n")
+	}
+	fmt.Fprintf(&b, "\n%s\n^N new note  ^L link selected  enter view  esc clear  ^C quit\n", a.status)
+	return b.String()
+}
+
+// Run runs the TUI over b on the terminal in, drawing to out, until the
+// user quits.
+func Run(b Backend, in *os.File, out io.Writer) error {
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	defer restore()
+
+	a := newApp(b)
+	r := bufio.NewReader(in)
+	for {
+		// Clear and home, then draw. makeRaw leaves output processing
+		// on, so "\n" still starts a new line.
+		fmt.Fprint(out, "\x1b[H\x1b[2J"+a.view())
+		key, ch, err := readKey(r)
+		if err != nil {
+			fmt.Fprint(out, "\x1b[H\x1b[2J")
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if a.handle(key, ch) {
+			fmt.Fprint(out, "\x1b[H\x1b[2J")
+			return nil
+		}
+	}
+}
+
+// readKey reads one keypress from a raw-mode terminal.
+func readKey(r *bufio.Reader) (keyKind, rune, error) {
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return keyOther, 0, err
+	}
+	switch ch {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 0x7f, 0x08:
+		return keyBackspace, 0, nil
+	case 0x03, 0x04:
+		return keyQuit, 0, nil
+	case 0x0e:
+		return keyCapture, 0, nil
+	case 0x0c:
+		return keyLink, 0, nil
+	case 0x1b:
+		// A lone Esc arrives by itself; arrow keys as "\x1b[A" and so on.
+		if r.Buffered() == 0 {
+			return keyEsc, 0, nil
+		}
+		if next, _ := r.ReadByte(); next != '[' && next != 'O' {
+			return keyEsc, 0, nil
+		}
+		switch final, _ := r.ReadByte(); final {
+		case 'A':
+			return keyUp, 0, nil
+		case 'B':
+			return keyDown, 0, nil
+		}
+		return keyOther, 0, nil
+	}
+	if unicode.IsPrint(ch) {
+		return keyRune, ch, nil
+	}
+	return keyOther, 0, nil
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// typeKeys feeds s to a as printable keypresses.
+func typeKeys(a *app, s string) {
+	for _, r := range s {
+		a.handle(keyRune, r)
+	}
+}
+
+func TestApp(t *testing.T) {
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.CreateNode("note:zeppelin", "Note", []byte("zeppelin mooring"), map[string]interface{}{"title": "Airships"})
+	repo.CreateNode("person:hugo", "Person", nil, nil)
+	a := newApp(RepoBackend{Repo: repo})
+
+	typeKeys(a, "zep")
+	if len(a.results) != 1 || a.results[0].ID != "note:zeppelin" {
+		t.Fatalf("results for partial word = %+v", a.results)
+	}
+	if v := a.view(); !strings.Contains(v, "> note:zeppelin  Airships  [Note]") {
+		t.Errorf("view =\n%s", v)
+	}
+	a.handle(keyEnter, 0)
+	if a.mode != modeView || a.content != "zeppelin mooring" {
+		t.Errorf("enter: mode %d, content %q", a.mode, a.content)
+	}
+	a.handle(keyOther, 0)
+
+	a.handle(keyLink, 0)
+	typeKeys(a, "mentions person:hugo")
+	a.handle(keyEnter, 0)
+	if links := repo.Links.LinksFrom("note:zeppelin"); len(links) != 1 || links[0].Target != "person:hugo" || links[0].Type != "mentions" {
+		t.Errorf("links = %+v, status %q", links, a.status)
+	}
+
+	a.handle(keyCapture, 0)
+	typeKeys(a, "Call Hugo about the hangar")
+	a.handle(keyEnter, 0)
+	node, err := repo.GetNode("note:call-hugo-about-the-hangar")
+	if err != nil || string(node.Content) != "Call Hugo about the hangar" || node.Type != "Note" {
+		t.Errorf("captured = %+v, %v (status %q)", node, err, a.status)
+	}
+	a.handle(keyCapture, 0)
+	typeKeys(a, "call hugo about the hangar")
+	a.handle(keyEnter, 0)
+	if a.status != "captured note:call-hugo-about-the-hangar-2" {
+		t.Errorf("second capture status = %q", a.status)
+	}
+
+	if !a.handle(keyQuit, 0) {
+		t.Error("ctrl-c did not quit")
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\r\x7f\x1b[A\x1b[B\x0e\x0c\x03é"))
+	want := []keyKind{keyRune, keyEnter, keyBackspace, keyUp, keyDown, keyCapture, keyLink, keyQuit, keyRune}
+	for i, w := range want {
+		got, _, err := readKey(r)
+		if err != nil || got != w {
+			t.Fatalf("key %d = %v, %v; want %v", i, got, err, w)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// Gateway serves the repository's public nodes, those marked
+// visibility=public, to anyone. Each has two stable URLs, both as HTML or,
+// with a ".json" suffix, as JSON:
+//
+//	/n/{id}    the node as it is now
+//	/v/{cid}   one version of it, for as long as the node stays public
+//
+// Nothing else is served: private nodes, and links to them, are answered
+// and hidden as if they did not exist, and there is no index of what is
+// public.
+type Gateway struct {
+	repo *dag.Repository
+	mux  *http.ServeMux
+}
+
+// NewGateway creates a Gateway over repo.
+func NewGateway(repo *dag.Repository) *Gateway {
+	g := &Gateway{repo: repo, mux: http.NewServeMux()}
+	g.mux.HandleFunc("GET /n/{id}", g.node)
+	g.mux.HandleFunc("GET /v/{cid}", g.version)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// StartGateway serves a Gateway on addr until the returned stop function
+// is called.
+func StartGateway(repo *dag.Repository, addr string) (stop func(), err error) {
+	return listen(addr, NewGateway(repo))
+}
+
+// publicNode is a node as the gateway shows it: no meta beyond its title
+// and tags, and only the links to other public nodes.
+type publicNode struct {
+	ID       string          `json:"id"`
+	CID      string          `json:"cid"`
+	Type     string          `json:"type"`
+	Title    string          `json:"title,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Content  string          `json:"content"`
+	Binary   bool            `json:"binary,omitempty"`
+	Created  time.Time       `json:"created"`
+	Modified time.Time       `json:"modified"`
+	Links    []dag.LinkEntry `json:"links"`
+}
+
+func (g *Gateway) node(w http.ResponseWriter, r *http.Request) {
+	id, asJSON := strings.CutSuffix(r.PathValue("id"), ".json")
+	node, cid, err := g.repo.PublicNode(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	g.write(w, g.view(node, cid), asJSON)
+}
+
+func (g *Gateway) version(w http.ResponseWriter, r *http.Request) {
+	cid, asJSON := strings.CutSuffix(r.PathValue("cid"), ".json")
+	node, err := g.repo.PublicVersion(cid)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	g.write(w, g.view(node, cid), asJSON)
+}
+
+func (g *Gateway) view(node *dag.NodeEnvelope, cid string) publicNode {
+	s := summarize(node)
+	v := publicNode{
+		ID: node.ID, CID: cid, Type: node.Type, Title: s.Title,
+		Created: node.Created, Modified: node.Modified, Links: []dag.LinkEntry{},
+	}
+	switch tags := node.Meta[dag.MetaTags].(type) {
+	case string:
+		v.Tags = []string{tags}
+	case []interface{}:
+		for _, t := range tags {
+			if s, ok := t.(string); ok {
+				v.Tags = append(v.Tags, s)
+			}
+		}
+	}
+	if utf8.Valid(node.Content) {
+		v.Content = string(node.Content)
+	} else {
+		v.Binary = true
+	}
+	for _, l := range g.repo.Links.LinksFrom(node.ID) {
+		if _, _, err := g.repo.PublicNode(dag.LinkTargetParent(l.Target)); err == nil {
+			v.Links = append(v.Links, l)
+		}
+	}
+	return v
+}
+
+func (g *Gateway) write(w http.ResponseWriter, v publicNode, asJSON bool) {
+	if asJSON {
+		writeJSON(w, v)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	gatewayPage.Execute(w, v)
+}
+
+var gatewayPage = template.Must(template.New("node").Funcs(template.FuncMap{
+	"parent": dag.LinkTargetParent,
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Title}}{{.Title}}{{else}}{{.ID}}{{end}}</title>
+<link rel="alternate" type="application/json" href="/v/{{.CID}}.json">
+<style>
+  body { max-width: 42rem; margin: 2rem auto; padding: 0 1rem; font: 16px/1.6 system-ui, sans-serif; }
+  pre { white-space: pre-wrap; font: inherit; }
+  footer, .meta { color: #777; font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}{{.ID}}{{end}}</h1>
+<p class="meta">{{.Type}} · updated {{.Modified.Format "2006-01-02"}}{{range .Tags}} · #{{.}}{{end}}</p>
+{{if .Binary}}<p>(binary content)</p>{{else}}<pre>{{.Content}}</pre>{{end}}
+{{if .Links}}<h2>Links</h2>
+<ul>{{range .Links}}<li>{{.Type}}: <a href="/n/{{parent .Target}}">{{.Target}}</a></li>{{end}}</ul>{{end}}
+<footer>This version: <a href="/v/{{.CID}}">{{.CID}}</a> · <a href="/v/{{.CID}}.json">JSON</a></footer>
+</body>
+</html>
+`))
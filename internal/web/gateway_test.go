@@ -0,0 +1,80 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+func TestGateway(t *testing.T) {
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewGateway(repo))
+	t.Cleanup(srv.Close)
+
+	public := map[string]interface{}{"title": "Airships", dag.MetaVisibility: dag.VisibilityPublic}
+	repo.CreateNode("note:a", "Note", []byte("zeppelin <notes>"), public)
+	repo.CreateNode("note:b", "Note", []byte("also public"), map[string]interface{}{dag.MetaVisibility: dag.VisibilityPublic})
+	repo.CreateNode("note:secret", "Note", []byte("private"), nil)
+	repo.CreateLink("note:a", "note:b", "related")
+	repo.CreateLink("note:a", "note:secret", "related")
+
+	var node publicNode
+	if code := get(t, srv, "/n/note:a.json", &node); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if node.Content != "zeppelin <notes>" || node.Title != "Airships" || node.CID == "" {
+		t.Errorf("node = %+v", node)
+	}
+	if len(node.Links) != 1 || node.Links[0].Target != "note:b" {
+		t.Errorf("links = %+v, want only the public target", node.Links)
+	}
+
+	resp, err := http.Get(srv.URL + "/n/note:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	page := string(body)
+	if !strings.Contains(page, "zeppelin &lt;notes&gt;") || !strings.Contains(page, `href="/n/note:b"`) ||
+		strings.Contains(page, "note:secret") || !strings.Contains(page, "/v/"+node.CID) {
+		t.Errorf("page = %s", page)
+	}
+
+	for _, path := range []string{"/n/note:secret", "/n/note:secret.json", "/n/note:missing", "/v/bogus", "/api/nodes"} {
+		if code := get(t, srv, path, nil); code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, code)
+		}
+	}
+
+	// An old version stays reachable by CID while the node is public.
+	oldCID := node.CID
+	repo.UpdateContent("note:a", []byte("revised"))
+	var old publicNode
+	if code := get(t, srv, "/v/"+oldCID+".json", &old); code != http.StatusOK || old.Content != "zeppelin <notes>" {
+		t.Errorf("old version: status %d, %+v", code, old)
+	}
+
+	// An ACL keeping anonymous readers out overrides visibility=public.
+	if _, err := repo.SetACL("note:b", &dag.ACL{Owner: "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(t, srv, "/n/note:b", nil); code != http.StatusNotFound {
+		t.Errorf("GET /n/note:b under an ACL: status %d, want 404", code)
+	}
+
+	// Unpublishing withdraws the node and its versions.
+	repo.UpdateNode("note:a", map[string]interface{}{dag.MetaVisibility: "private"})
+	for _, path := range []string{"/n/note:a", "/v/" + oldCID} {
+		if code := get(t, srv, path, nil); code != http.StatusNotFound {
+			t.Errorf("GET %s after unpublishing: status %d, want 404", path, code)
+		}
+	}
+}
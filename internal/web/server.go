@@ -0,0 +1,328 @@
+// Package web serves a browser UI for a repository: node browsing,
+// search, backlinks, a force-directed graph view and a timeline of recent
+// changes. The page is embedded in the binary and talks to the read-only
+// JSON API under /api/, which scripts may use directly too.
+//
+// By default there is no authentication; serve it on a loopback address,
+// or require capability tokens (memex-fs token mint), which then also
+// limit what each client sees to the node types its token names. Gateway, by
+// contrast, is meant to face the public: it serves only the nodes marked
+// visibility=public.
+package web
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+//go:embed static
+var static embed.FS
+
+// Default and largest result counts for the list endpoints.
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Server answers the UI's requests from a repository.
+type Server struct {
+	repo   *dag.Repository
+	mux    *http.ServeMux
+	tokens bool // whether /api/ requests must carry a capability token
+}
+
+// NewServer creates a Server over repo. With requireTokens, every API
+// request must present a token granting read, as "Authorization: Bearer"
+// or a ?token= parameter; the page itself is static and served to anyone.
+func NewServer(repo *dag.Repository, requireTokens bool) *Server {
+	s := &Server{repo: repo, mux: http.NewServeMux(), tokens: requireTokens}
+	page, _ := fs.Sub(static, "static")
+	s.mux.Handle("GET /", http.FileServerFS(page))
+	s.mux.HandleFunc("GET /api/nodes", s.authorize(s.listNodes))
+	s.mux.HandleFunc("GET /api/nodes/{id}", s.authorize(s.getNode))
+	s.mux.HandleFunc("GET /api/nodes/{id}/backlinks", s.authorize(s.backlinks))
+	s.mux.HandleFunc("GET /api/search", s.authorize(s.search))
+	s.mux.HandleFunc("GET /api/graph", s.authorize(s.graph))
+	s.mux.HandleFunc("GET /api/timeline", s.authorize(s.timeline))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start serves the UI on addr until the returned stop function is called.
+// The listener is opened before Start returns, so a busy or malformed
+// address is reported to the caller.
+func Start(repo *dag.Repository, addr string, requireTokens bool) (stop func(), err error) {
+	return listen(addr, NewServer(repo, requireTokens))
+}
+
+// listen serves h on addr in the background, returning a stop function
+// that shuts the server down gracefully.
+func listen(addr string, h http.Handler) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// authorize wraps an API handler to verify the request's token, when
+// tokens are required, and pass what it grants on in the request context.
+func (s *Server) authorize(h http.HandlerFunc) http.HandlerFunc {
+	if !s.tokens {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.repo.VerifyToken(requestToken(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if !c.Allows(dag.OpRead, "") {
+			writeError(w, http.StatusForbidden, errors.New("token does not grant read"))
+			return
+		}
+		h(w, r.WithContext(dag.ContextWithCapability(r.Context(), c)))
+	}
+}
+
+// visible looks up the live node id if the request's capability, and the
+// node's ACL for the capability's holder, let it be read. A request with
+// a capability is told only that a node it may not read, or a deleted
+// one, is not found, so hidden IDs are not confirmed to exist.
+func (s *Server) visible(ctx context.Context, id string) (*dag.NodeEnvelope, error) {
+	c := dag.CapabilityFrom(ctx)
+	node, err := s.repo.GetNode(id)
+	if err != nil && c == nil {
+		return nil, err
+	}
+	if err != nil || !s.repo.Authorized(c, node, dag.OpRead) {
+		return nil, fmt.Errorf("node %w: %s", dag.ErrNotFound, id)
+	}
+	return node, nil
+}
+
+// visibleLinks keeps the links whose far end, as end picks it, is
+// visible; a link never reveals a node the capability hides.
+func (s *Server) visibleLinks(ctx context.Context, links []dag.LinkEntry, end func(dag.LinkEntry) string) []dag.LinkEntry {
+	out := []dag.LinkEntry{}
+	for _, l := range links {
+		if dag.CapabilityFrom(ctx) == nil {
+			out = append(out, l)
+		} else if _, err := s.visible(ctx, dag.LinkTargetParent(end(l))); err == nil {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// nodeSummary is a node as list endpoints return it.
+type nodeSummary struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title,omitempty"`
+	Modified time.Time `json:"modified"`
+}
+
+// nodeView is a node as /api/nodes/{id} returns it. Content is omitted,
+// and Binary set, when it is not UTF-8 text.
+type nodeView struct {
+	nodeSummary
+	Content string                 `json:"content"`
+	Binary  bool                   `json:"binary,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+	Created time.Time              `json:"created"`
+	Links   []dag.LinkEntry        `json:"links"`
+}
+
+func summarize(node *dag.NodeEnvelope) nodeSummary {
+	title, _ := node.Meta["title"].(string)
+	if title == "" {
+		title, _ = node.Meta["name"].(string)
+	}
+	return nodeSummary{ID: node.ID, Type: node.Type, Title: title, Modified: node.Modified}
+}
+
+// summaries looks up ids, skipping any no longer live or not visible.
+func (s *Server) summaries(ctx context.Context, ids []string) []nodeSummary {
+	out := []nodeSummary{}
+	for _, id := range ids {
+		if node, err := s.visible(ctx, id); err == nil {
+			out = append(out, summarize(node))
+		}
+	}
+	return out
+}
+
+// limit reads the "limit" query parameter, clamped to maxLimit.
+func limit(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n <= 0 {
+		return defaultLimit
+	}
+	return min(n, maxLimit)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// statusFor maps a repository error to the status that describes it, or
+// fallback for one the dag package does not classify.
+func statusFor(err error, fallback int) int {
+	var locked *dag.LockedError
+	switch {
+	case errors.Is(err, dag.ErrDeleted):
+		return http.StatusGone
+	case errors.Is(err, dag.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dag.ErrAccessDenied), errors.Is(err, dag.ErrProtected), errors.Is(err, dag.ErrAppendOnly):
+		return http.StatusForbidden
+	case errors.As(err, &locked):
+		return http.StatusLocked
+	case errors.Is(err, dag.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, dag.ErrValidation):
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
+// listNodes returns the nodes of ?type=, or all nodes, by ID.
+func (s *Server) listNodes(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if typ := r.URL.Query().Get("type"); typ != "" {
+		ids = s.repo.Search.FilterByType(typ, limit(r))
+	} else {
+		var err error
+		if ids, err = s.repo.ListNodes(r.Context(), limit(r)); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, s.summaries(r.Context(), ids))
+}
+
+func (s *Server) getNode(w http.ResponseWriter, r *http.Request) {
+	id := s.repo.ResolveAlias(r.PathValue("id"))
+	node, err := s.visible(r.Context(), id)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusInternalServerError), err)
+		return
+	}
+	view := nodeView{
+		nodeSummary: summarize(node),
+		Meta:        node.Meta,
+		Created:     node.Created,
+		Links:       s.visibleLinks(r.Context(), s.repo.Links.LinksFrom(node.ID), func(l dag.LinkEntry) string { return l.Target }),
+	}
+	if utf8.Valid(node.Content) {
+		view.Content = string(node.Content)
+	} else {
+		view.Binary = true
+	}
+	writeJSON(w, view)
+}
+
+func (s *Server) backlinks(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.repo.Refs.Has(id) {
+		writeError(w, http.StatusNotFound, errors.New("node not found: "+id))
+		return
+	}
+	if dag.CapabilityFrom(r.Context()) != nil {
+		if _, err := s.visible(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+	}
+	writeJSON(w, s.visibleLinks(r.Context(), s.repo.Links.LinksTo(id), func(l dag.LinkEntry) string { return l.Source }))
+}
+
+// search answers ?q=, expanding "@name" query aliases.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query, err := s.repo.ExpandQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, s.summaries(r.Context(), s.repo.Search.Search(query, limit(r))))
+}
+
+// graph returns the export of the nodes ?type=, ?tag=, ?from= and ?depth=
+// select, in the JSON shape of /graph/export.json.
+func (s *Server) graph(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := dag.GraphFilter{
+		SparseFilter: dag.SparseFilter{Types: q["type"], Tags: q["tag"]},
+		From:         q.Get("from"),
+	}
+	if d := q.Get("depth"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, errors.New("bad depth "+strconv.Quote(d)))
+			return
+		}
+		f.Depth = n
+	}
+	g, err := s.repo.ExportGraph(r.Context(), f)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusNotFound), err)
+		return
+	}
+	if dag.CapabilityFrom(r.Context()) != nil {
+		g = s.restrictGraph(r.Context(), g)
+	}
+	writeJSON(w, g)
+}
+
+// restrictGraph drops the nodes the request may not read, and links
+// touching them.
+func (s *Server) restrictGraph(ctx context.Context, g *dag.GraphExport) *dag.GraphExport {
+	out := &dag.GraphExport{Nodes: []dag.GraphExportNode{}, Links: []dag.GraphExportLink{}}
+	kept := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if _, err := s.visible(ctx, n.ID); err == nil {
+			out.Nodes = append(out.Nodes, n)
+			kept[n.ID] = true
+		}
+	}
+	for _, l := range g.Links {
+		if kept[l.Source] && kept[l.Target] {
+			out.Links = append(out.Links, l)
+		}
+	}
+	return out
+}
+
+// timeline returns the most recently modified nodes, newest first.
+func (s *Server) timeline(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.summaries(r.Context(), s.repo.Recency.RecentlyModified(limit(r))))
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+func testServer(t *testing.T) (*dag.Repository, *httptest.Server) {
+	t.Helper()
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(repo, false))
+	t.Cleanup(srv.Close)
+	return repo, srv
+}
+
+// get fetches path and decodes its JSON body into v, returning the status.
+func get(t *testing.T, srv *httptest.Server, path string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPI(t *testing.T) {
+	repo, srv := testServer(t)
+	repo.CreateNode("note:a", "Note", []byte("zeppelin notes"), map[string]interface{}{"title": "Airships"})
+	repo.CreateNode("person:b", "Person", nil, nil)
+	repo.CreateLink("note:a", "person:b", "mentions")
+
+	var nodes []nodeSummary
+	get(t, srv, "/api/nodes?type=Note", &nodes)
+	if len(nodes) != 1 || nodes[0].ID != "note:a" || nodes[0].Title != "Airships" {
+		t.Errorf("nodes = %+v", nodes)
+	}
+	var view nodeView
+	if code := get(t, srv, "/api/nodes/note:a", &view); code != http.StatusOK {
+		t.Fatalf("node status %d", code)
+	}
+	if view.Content != "zeppelin notes" || len(view.Links) != 1 || view.Links[0].Target != "person:b" {
+		t.Errorf("node = %+v", view)
+	}
+	var back []dag.LinkEntry
+	get(t, srv, "/api/nodes/person:b/backlinks", &back)
+	if len(back) != 1 || back[0].Source != "note:a" {
+		t.Errorf("backlinks = %+v", back)
+	}
+	get(t, srv, "/api/search?q=zeppelin", &nodes)
+	if len(nodes) != 1 || nodes[0].ID != "note:a" {
+		t.Errorf("search = %+v", nodes)
+	}
+	var g dag.GraphExport
+	get(t, srv, "/api/graph?from=person:b&depth=1", &g)
+	if len(g.Nodes) != 2 || len(g.Links) != 1 {
+		t.Errorf("graph = %+v", g)
+	}
+	get(t, srv, "/api/timeline", &nodes)
+	if len(nodes) != 2 {
+		t.Errorf("timeline = %+v", nodes)
+	}
+	if code := get(t, srv, "/api/nodes/note:missing", nil); code != http.StatusNotFound {
+		t.Errorf("missing node status %d", code)
+	}
+	repo.DeleteNode("person:b", false)
+	if code := get(t, srv, "/api/nodes/person:b", nil); code != http.StatusGone {
+		t.Errorf("deleted node status %d, want 410", code)
+	}
+	if code := get(t, srv, "/api/graph?depth=x", nil); code != http.StatusBadRequest {
+		t.Errorf("bad depth status %d", code)
+	}
+}
+
+func TestIndexPage(t *testing.T) {
+	_, srv := testServer(t)
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "<title>memex</title>") {
+		t.Errorf("GET / = %d\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestAPI_Tokens(t *testing.T) {
+	repo, err := dag.OpenRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(repo, true))
+	t.Cleanup(srv.Close)
+	repo.CreateNode("note:a", "Note", nil, nil)
+	repo.CreateNode("person:b", "Person", nil, nil)
+	repo.CreateLink("note:a", "person:b", "mentions")
+	token, _, err := repo.MintToken("", "", []string{dag.OpRead}, []string{"Note"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := get(t, srv, "/api/nodes", nil); code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", code)
+	}
+	if code := get(t, srv, "/", nil); code != http.StatusOK {
+		t.Errorf("page: status %d, want 200 without a token", code)
+	}
+	var nodes []nodeSummary
+	get(t, srv, "/api/nodes?token="+token, &nodes)
+	if len(nodes) != 1 || nodes[0].ID != "note:a" {
+		t.Errorf("nodes = %+v, want only the Note", nodes)
+	}
+	var view nodeView
+	get(t, srv, "/api/nodes/note:a?token="+token, &view)
+	if len(view.Links) != 0 {
+		t.Errorf("links = %+v, want the Person hidden", view.Links)
+	}
+	if code := get(t, srv, "/api/nodes/person:b?token="+token, nil); code != http.StatusNotFound {
+		t.Errorf("hidden node: status %d, want 404", code)
+	}
+}
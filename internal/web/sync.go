@@ -0,0 +1,222 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	gocid "github.com/ipfs/go-cid"
+	"github.com/systemshift/memex-fs/internal/dag"
+)
+
+// maxSyncObject bounds one object a peer may push.
+const maxSyncObject = 256 << 20
+
+// SyncServer lets another memex push to and pull from a repository, one
+// object at a time, so a transfer moves only what the other side lacks:
+//
+//	GET  /sync/head           {"head": CID}, "" for an empty repository
+//	GET  /sync/objects/{cid}  the object's bytes
+//	PUT  /sync/objects/{cid}  store an object, which must hash to cid
+//	POST /sync/update         {"head": CID} merges a pushed commit
+//
+// An update is checked against the ACLs along the pushed history this
+// repository does not already hold, as by Repository.CheckACLs, then
+// merged as by Repository.MergeCommitFor, which refuses it if what it
+// would change here is not the token holder's to change, or checked out
+// if the repository is empty; conflicts are left in /conflicts/ for the
+// owner. With tokens required, a token must grant read, or write to push,
+// on every type, and the versions of a node its holder may not read, and
+// their content, are refused.
+type SyncServer struct {
+	repo   *dag.Repository
+	mux    *http.ServeMux
+	tokens bool
+
+	mu     sync.Mutex
+	hidden map[string]bool // UnreadableContent for hiddenFor
+	// hiddenFor is the holder and HEAD hidden was worked out for.
+	hiddenFor string
+}
+
+// syncUpdate is the body of /sync/update and its reply.
+type syncUpdate struct {
+	Head      string   `json:"head"`
+	Updated   []string `json:"updated,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// NewSyncServer creates a SyncServer over repo.
+func NewSyncServer(repo *dag.Repository, requireTokens bool) *SyncServer {
+	s := &SyncServer{repo: repo, mux: http.NewServeMux(), tokens: requireTokens}
+	s.mux.HandleFunc("GET /sync/head", s.authorize(dag.OpRead, s.head))
+	s.mux.HandleFunc("GET /sync/objects/{cid}", s.authorize(dag.OpRead, s.getObject))
+	s.mux.HandleFunc("PUT /sync/objects/{cid}", s.authorize(dag.OpWrite, s.putObject))
+	s.mux.HandleFunc("POST /sync/update", s.authorize(dag.OpWrite, s.update))
+	return s
+}
+
+func (s *SyncServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// StartSync serves a SyncServer on addr until the returned stop function
+// is called.
+func StartSync(repo *dag.Repository, addr string, requireTokens bool) (stop func(), err error) {
+	return listen(addr, NewSyncServer(repo, requireTokens))
+}
+
+// authorize wraps a sync handler to require a token granting op on the
+// whole repository, when tokens are required.
+func (s *SyncServer) authorize(op string, h http.HandlerFunc) http.HandlerFunc {
+	if !s.tokens {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.repo.VerifyToken(requestToken(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if !c.Allows(op, "") || len(c.Types) > 0 {
+			writeError(w, http.StatusForbidden, fmt.Errorf("token does not grant %s on the whole repository", op))
+			return
+		}
+		h(w, r.WithContext(dag.ContextWithCapability(r.Context(), c)))
+	}
+}
+
+func (s *SyncServer) head(w http.ResponseWriter, r *http.Request) {
+	head, err := s.repo.Commits.Head()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := syncUpdate{}
+	if head.Defined() {
+		out.Head = dag.CIDToFilename(head)
+	}
+	writeJSON(w, out)
+}
+
+func (s *SyncServer) getObject(w http.ResponseWriter, r *http.Request) {
+	c, err := gocid.Decode(r.PathValue("cid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := s.repo.Store.Get(c)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusInternalServerError), err)
+		return
+	}
+	if err := s.readable(dag.CapabilityFrom(r.Context()), c, data); err != nil {
+		writeError(w, statusFor(err, http.StatusInternalServerError), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// readable returns ErrAccessDenied if the object c, holding data, is a
+// version of a node the holder of capability may not read, or its content.
+func (s *SyncServer) readable(capability *dag.Capability, c gocid.Cid, data []byte) error {
+	if capability == nil {
+		return nil
+	}
+	if node, err := dag.DecodeNode(data, nil); err == nil && node.ID != "" {
+		if !s.repo.Authorized(capability, node, dag.OpRead) {
+			return fmt.Errorf("%w: %s is a version of %s", dag.ErrAccessDenied, c, node.ID)
+		}
+		return nil
+	}
+	head, err := s.repo.Commits.Head()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key := capability.Holder + "@" + head.String(); s.hiddenFor != key {
+		hidden, err := s.repo.UnreadableContent(capability)
+		if err != nil {
+			return err
+		}
+		s.hidden, s.hiddenFor = hidden, key
+	}
+	if s.hidden[dag.CIDToFilename(c)] {
+		return fmt.Errorf("%w: %s", dag.ErrAccessDenied, c)
+	}
+	return nil
+}
+
+func (s *SyncServer) putObject(w http.ResponseWriter, r *http.Request) {
+	c, err := gocid.Decode(r.PathValue("cid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSyncObject))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err := s.repo.Store.PutCID(c, data); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *SyncServer) update(w http.ResponseWriter, r *http.Request) {
+	var in syncUpdate
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	head, err := gocid.Decode(in.Head)
+	if err != nil || !s.repo.Store.Has(head) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("head %q has not been pushed", in.Head))
+		return
+	}
+	if err := s.repo.CheckACLs(head); err != nil {
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+
+	repo, c := s.repo, dag.CapabilityFrom(r.Context())
+	if c != nil {
+		repo = repo.As(c.Holder)
+	}
+	out := syncUpdate{Head: in.Head}
+	if cur, err := repo.Commits.Head(); err == nil && !cur.Defined() {
+		if _, err := repo.Checkout(head, dag.SparseFilter{}); err != nil {
+			writeError(w, statusFor(err, http.StatusConflict), err)
+			return
+		}
+	} else {
+		res, err := repo.MergeCommitFor(head, c)
+		if err != nil {
+			writeError(w, statusFor(err, http.StatusConflict), err)
+			return
+		}
+		out.Updated, out.Conflicts = res.Updated, res.Conflicts
+		if now, err := repo.Commits.Head(); err == nil {
+			out.Head = dag.CIDToFilename(now)
+		}
+	}
+	writeJSON(w, out)
+}
+
+// requestToken returns the token r carries, as "Authorization: Bearer" or
+// a ?token= parameter.
+func requestToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	return token
+}